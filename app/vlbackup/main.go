@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/buildinfo"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/envflag"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/snapshot/snapshotutil"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/backup"
+)
+
+var (
+	dst = flag.String("dst", "", "Where to put the backup. Supported values: fs:///path/to/local/dir or s3://bucket/path/to/dir . "+
+		"Parts already present at -dst aren't uploaded again, so multiple backups can share the same -dst for incremental backups")
+	backupName = flag.String("backupName", "", "The name of the backup to create at -dst. Automatically generated name in the YYYYMMDDhhmmss-XXXXXXXXXXXXXXXX format is used by default")
+
+	storageNode = flag.String("storageNode", "http://localhost:9428", "The address of VictoriaLogs instance for creating partition snapshots via /internal/partition/snapshot/create . "+
+		"vlbackup must run on the same host as the VictoriaLogs instance, since it reads the created snapshots from the local filesystem. "+
+		"See https://docs.victoriametrics.com/victorialogs/#partitions-lifecycle")
+	partitionManageAuthKey = flagutil.NewPassword("partitionManageAuthKey", "authKey to pass to /internal/partition/* endpoints at -storageNode; "+
+		"it must match the -partitionManageAuthKey at VictoriaLogs")
+	partitions    = flagutil.NewArrayString("partition", "Optional names of partitions to backup such as YYYYMMDD for per-day partitions. All the active partitions at -storageNode are backed up by default")
+	keepSnapshots = flag.Bool("keepSnapshots", false, "Whether to keep partition snapshots created at -storageNode after the backup is complete. "+
+		"By default the created snapshots are removed after the backup")
+	snapshotPaths = flagutil.NewArrayString("snapshotPath", "Optional paths to already existing partition snapshots to backup. "+
+		"If set, then snapshots aren't created at -storageNode and the given snapshots aren't removed after the backup")
+
+	concurrency = flag.Int("concurrency", 10, "The number of parts to upload in parallel")
+
+	s3Endpoint       = flag.String("s3.endpoint", "", "Custom S3-compatible endpoint for s3:// -dst such as http://minio:9000 . By default the AWS S3 endpoint for -s3.region is used")
+	s3Region         = flag.String("s3.region", "us-east-1", "S3 region for s3:// -dst")
+	s3ForcePathStyle = flag.Bool("s3.forcePathStyle", true, "Whether to use path-style addressing (http://endpoint/bucket/key) instead of "+
+		"virtual-hosted-style addressing (http://bucket.endpoint/key) for s3:// -dst")
+)
+
+func main() {
+	// Write flags and help message to stdout, since it is easier to grep or pipe.
+	flag.CommandLine.SetOutput(os.Stdout)
+	envflag.Parse()
+	buildinfo.Init()
+	logger.Init()
+
+	if *dst == "" {
+		logger.Fatalf("missing -dst command-line flag")
+	}
+	rfs, err := backup.NewRemoteFS(*dst, getS3Config())
+	if err != nil {
+		logger.Fatalf("cannot initialize -dst=%q: %s", *dst, err)
+	}
+
+	name := *backupName
+	if name == "" {
+		name = snapshotutil.NewName()
+	}
+
+	var snapshots []backup.PartitionSnapshot
+	createdSnapshots := len(*snapshotPaths) == 0
+	if createdSnapshots {
+		snapshots, err = createSnapshots()
+		if err != nil {
+			logger.Fatalf("cannot create partition snapshots at -storageNode=%q: %s", *storageNode, err)
+		}
+	} else {
+		for _, path := range *snapshotPaths {
+			snapshots = append(snapshots, backup.PartitionSnapshot{
+				Partition: getPartitionNameFromSnapshotPath(path),
+				Path:      path,
+			})
+		}
+	}
+
+	logger.Infof("starting backup %q for %d partitions to %s", name, len(snapshots), rfs)
+	startTime := time.Now()
+	stats, err := backup.Backup(rfs, name, snapshots, *concurrency)
+
+	if createdSnapshots && !*keepSnapshots {
+		for _, ps := range snapshots {
+			fs.MustRemoveDir(ps.Path)
+		}
+	}
+
+	if err != nil {
+		logger.Fatalf("cannot create backup %q at %s: %s", name, rfs, err)
+	}
+	logger.Infof("backup %q is created at %s in %.3f seconds; uploaded parts: %d, uploaded bytes: %d, skipped parts already present at -dst: %d",
+		name, rfs, time.Since(startTime).Seconds(), stats.PartsCopied, stats.BytesCopied, stats.PartsSkipped)
+}
+
+func getS3Config() *backup.S3Config {
+	return &backup.S3Config{
+		Endpoint:        *s3Endpoint,
+		Region:          *s3Region,
+		ForcePathStyle:  *s3ForcePathStyle,
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// getPartitionNameFromSnapshotPath returns partition name from the path in the form <-storageDataPath>/partitions/<partitionName>/snapshots/<snapshotName>
+func getPartitionNameFromSnapshotPath(path string) string {
+	return filepath.Base(filepath.Dir(filepath.Dir(filepath.Clean(path))))
+}
+
+func createSnapshots() ([]backup.PartitionSnapshot, error) {
+	names := *partitions
+	if len(names) == 0 {
+		if err := callStorageNode("/internal/partition/list", nil, &names); err != nil {
+			return nil, err
+		}
+	}
+
+	var snapshots []backup.PartitionSnapshot
+	for _, name := range names {
+		var path string
+		args := url.Values{
+			"name": {name},
+		}
+		if err := callStorageNode("/internal/partition/snapshot/create", args, &path); err != nil {
+			for _, ps := range snapshots {
+				fs.MustRemoveDir(ps.Path)
+			}
+			return nil, err
+		}
+		snapshots = append(snapshots, backup.PartitionSnapshot{
+			Partition: name,
+			Path:      path,
+		})
+	}
+	return snapshots, nil
+}
+
+func callStorageNode(path string, args url.Values, dst any) error {
+	if args == nil {
+		args = url.Values{}
+	}
+	if authKey := partitionManageAuthKey.Get(); authKey != "" {
+		args.Set("authKey", authKey)
+	}
+	reqURL := strings.TrimSuffix(*storageNode, "/") + path + "?" + args.Encode()
+
+	resp, err := http.Get(reqURL)
+	if err != nil {
+		return fmt.Errorf("cannot call %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("cannot read response from %s: %w", path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response status code from %s: %d; response body: %q", path, resp.StatusCode, data)
+	}
+	if err := json.Unmarshal(data, dst); err != nil {
+		return fmt.Errorf("cannot parse response from %s: %w; response body: %q", path, err, data)
+	}
+	return nil
+}
@@ -104,7 +104,7 @@ func RequestHandler(path string, w http.ResponseWriter, r *http.Request) bool {
 		lmp := cp.NewLogMessageProcessor("elasticsearch_bulk", true)
 		encoding := r.Header.Get("Content-Encoding")
 		streamName := fmt.Sprintf("remoteAddr=%s, requestURI=%q", httpserver.GetQuotedRemoteAddr(r), r.RequestURI)
-		n, err := readBulkRequest(streamName, r.Body, encoding, cp.TimeFields, cp.MsgFields, lmp)
+		n, err := readBulkRequest(streamName, r.Body, encoding, cp.TimeFields, cp.TimeParser, cp.MsgFields, lmp)
 		lmp.MustClose()
 		if err != nil {
 			logger.Warnf("%s: cannot decode log message #%d in /_bulk request: %s, stream fields: %s", streamName, n, err, cp.StreamFields)
@@ -133,7 +133,7 @@ var (
 	bulkRequestDuration = metrics.NewSummary(`vl_http_request_duration_seconds{path="/insert/elasticsearch/_bulk"}`)
 )
 
-func readBulkRequest(streamName string, r io.Reader, encoding string, timeFields, msgFields []string, lmp insertutil.LogMessageProcessor) (int, error) {
+func readBulkRequest(streamName string, r io.Reader, encoding string, timeFields []string, timeParser *logstorage.TimeParser, msgFields []string, lmp insertutil.LogMessageProcessor) (int, error) {
 	// See https://www.elastic.co/guide/en/elasticsearch/reference/current/docs-bulk.html
 
 	reader, err := protoparserutil.GetUncompressedReader(r, encoding)
@@ -149,7 +149,7 @@ func readBulkRequest(streamName string, r io.Reader, encoding string, timeFields
 
 	n := 0
 	for {
-		ok, err := readBulkLine(lr, timeFields, msgFields, lmp)
+		ok, err := readBulkLine(lr, timeFields, timeParser, msgFields, lmp)
 		wcr.DecConcurrency()
 		if err != nil || !ok {
 			return n, err
@@ -158,7 +158,7 @@ func readBulkRequest(streamName string, r io.Reader, encoding string, timeFields
 	}
 }
 
-func readBulkLine(lr *insertutil.LineReader, timeFields, msgFields []string, lmp insertutil.LogMessageProcessor) (bool, error) {
+func readBulkLine(lr *insertutil.LineReader, timeFields []string, timeParser *logstorage.TimeParser, msgFields []string, lmp insertutil.LogMessageProcessor) (bool, error) {
 	var line []byte
 
 	// Read the command, must be "create" or "index"
@@ -200,7 +200,7 @@ func readBulkLine(lr *insertutil.LineReader, timeFields, msgFields []string, lmp
 		return false, fmt.Errorf("cannot parse json-encoded log entry: %w; last %d bytes: %q", err, len(lineTail), lineTail)
 	}
 
-	ts, err := extractTimestampFromFields(timeFields, p.Fields)
+	ts, err := extractTimestampFromFields(timeFields, timeParser, p.Fields)
 	if err != nil {
 		return false, fmt.Errorf("cannot parse timestamp: %w", err)
 	}
@@ -213,14 +213,14 @@ func readBulkLine(lr *insertutil.LineReader, timeFields, msgFields []string, lmp
 	return true, nil
 }
 
-func extractTimestampFromFields(timeFields []string, fields []logstorage.Field) (int64, error) {
+func extractTimestampFromFields(timeFields []string, timeParser *logstorage.TimeParser, fields []logstorage.Field) (int64, error) {
 	for _, timeField := range timeFields {
 		for i := range fields {
 			f := &fields[i]
 			if f.Name != timeField {
 				continue
 			}
-			timestamp, err := parseElasticsearchTimestamp(f.Value)
+			timestamp, err := parseElasticsearchTimestamp(f.Value, timeParser)
 			if err != nil {
 				return 0, err
 			}
@@ -231,12 +231,19 @@ func extractTimestampFromFields(timeFields []string, fields []logstorage.Field)
 	return 0, nil
 }
 
-func parseElasticsearchTimestamp(s string) (int64, error) {
+func parseElasticsearchTimestamp(s string, timeParser *logstorage.TimeParser) (int64, error) {
 	if s == "0" || s == "" {
 		// Special case - zero or empty timestamp must be substituted
 		// with the current time by the caller.
 		return 0, nil
 	}
+	if timeParser != nil {
+		nsecs, ok := timeParser.TryParse(s)
+		if !ok {
+			return 0, fmt.Errorf("cannot parse timestamp %q in format %q", s, timeParser.Format())
+		}
+		return nsecs, nil
+	}
 	if len(s) < len("YYYY-MM-DD") || s[len("YYYY")] != '-' {
 		// Try parsing timestamp in seconds or milliseconds
 		nsecs, ok := timeutil.TryParseUnixTimestamp(s)
@@ -16,12 +16,16 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timeutil"
 	"github.com/VictoriaMetrics/metrics"
 
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/geoip"
 	"github.com/VictoriaMetrics/VictoriaLogs/lib/logstorage"
 )
 
 var (
 	defaultMsgValue = flag.String("defaultMsgValue", "missing _msg field; see https://docs.victoriametrics.com/victorialogs/keyconcepts/#message-field",
 		"Default value for _msg field if the ingested log entry doesn't contain it; see https://docs.victoriametrics.com/victorialogs/keyconcepts/#message-field")
+	maxTenantMetrics = flag.Int("insert.maxTenantMetrics", 1000, "The maximum number of tenants to expose per-tenant ingestion metrics for at /metrics page. "+
+		`Ingestion metrics for the remaining tenants are exposed with accountID="other" and projectID="other" labels. `+
+		"See https://docs.victoriametrics.com/victorialogs/#tenants-usage")
 )
 
 // CommonParams contains common HTTP parameters used by log ingestion APIs.
@@ -36,6 +40,19 @@ type CommonParams struct {
 	DecolorizeFields []string
 	ExtraFields      []logstorage.Field
 
+	// TimeParser is used for parsing timestamps at TimeFields.
+	//
+	// Unix timestamps and RFC3339 timestamps are detected automatically if TimeParser is nil.
+	TimeParser *logstorage.TimeParser
+
+	// GeoIPField is the name of the field with IP addresses to enrich with GeoIP information.
+	//
+	// See https://docs.victoriametrics.com/victorialogs/data-ingestion/#geoip-enrichment
+	GeoIPField string
+
+	// GeoIPPrefix is the prefix for the names of fields with GeoIP information.
+	GeoIPPrefix string
+
 	IsTimeFieldSet  bool
 	Debug           bool
 	DebugRequestURI string
@@ -57,6 +74,17 @@ func GetCommonParams(r *http.Request) (*CommonParams, error) {
 		timeFields = tfs
 	}
 
+	var timeParser *logstorage.TimeParser
+	timeFormat := httputil.GetRequestValue(r, "_time_format", "VL-Time-Format")
+	timeZone := httputil.GetRequestValue(r, "_time_zone", "VL-Time-Zone")
+	if timeFormat != "" || timeZone != "" {
+		tp, err := logstorage.NewTimeParser(timeFormat, timeZone)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse _time_format=%q, _time_zone=%q: %w", timeFormat, timeZone, err)
+		}
+		timeParser = tp
+	}
+
 	msgFields := httputil.GetArray(r, "_msg_field", "VL-Msg-Field")
 	streamFields := httputil.GetArray(r, "_stream_fields", "VL-Stream-Fields")
 	ignoreFields := httputil.GetArray(r, "ignore_fields", "VL-Ignore-Fields")
@@ -67,6 +95,9 @@ func GetCommonParams(r *http.Request) (*CommonParams, error) {
 		return nil, err
 	}
 
+	geoipField := httputil.GetRequestValue(r, "geoip_field", "VL-GeoIP-Field")
+	geoipPrefix := httputil.GetRequestValue(r, "geoip_prefix", "VL-GeoIP-Prefix")
+
 	debug := false
 	if dv := httputil.GetRequestValue(r, "debug", "VL-Debug"); dv != "" {
 		debug, err = strconv.ParseBool(dv)
@@ -84,11 +115,14 @@ func GetCommonParams(r *http.Request) (*CommonParams, error) {
 	cp := &CommonParams{
 		TenantID:         tenantID,
 		TimeFields:       timeFields,
+		TimeParser:       timeParser,
 		MsgFields:        msgFields,
 		StreamFields:     streamFields,
 		IgnoreFields:     ignoreFields,
 		DecolorizeFields: decolorizeFields,
 		ExtraFields:      extraFields,
+		GeoIPField:       geoipField,
+		GeoIPPrefix:      geoipPrefix,
 
 		IsTimeFieldSet:  isTimeFieldSet,
 		Debug:           debug,
@@ -194,9 +228,32 @@ type logMessageProcessor struct {
 	cp *CommonParams
 	lr *logstorage.LogRows
 
+	// geoipFieldNames contains the names of fields with GeoIP information for cp.GeoIPField.
+	geoipFieldNames []string
+
+	// fieldsBuf is a buffer for fields with the added GeoIP information.
+	fieldsBuf []logstorage.Field
+
+	// relabelRules contains rules from -insert.relabelConfig, which must be applied to the ingested logs.
+	relabelRules []*relabelRule
+
+	// relabeledFieldsBuf and relabeledStreamFieldsBuf are buffers for fields relabeled according to -insert.relabelConfig.
+	relabeledFieldsBuf       []logstorage.Field
+	relabeledStreamFieldsBuf []logstorage.Field
+
+	// redactedFieldsBuf and redactedStreamFieldsBuf are buffers for fields with the values redacted according to -insert.redactConfig.
+	redactedFieldsBuf       []logstorage.Field
+	redactedStreamFieldsBuf []logstorage.Field
+
+	// redactedInsertRow is a buffer for the row passed to AddInsertRow with the values redacted according to -insert.redactConfig.
+	redactedInsertRow logstorage.InsertRow
+
 	rowsIngestedTotal  *metrics.Counter
 	bytesIngestedTotal *metrics.Counter
 	flushDuration      *metrics.Summary
+
+	// tc contains per-tenant ingestion counters for cp.TenantID.
+	tc *tenantCounters
 }
 
 func (lmp *logMessageProcessor) initPeriodicFlush() {
@@ -225,6 +282,26 @@ func (lmp *logMessageProcessor) initPeriodicFlush() {
 	}()
 }
 
+// getFieldNamesForLog returns comma-separated names of the given fields for logging.
+//
+// Up to maxFieldNamesForLog names are returned.
+func getFieldNamesForLog(fields []logstorage.Field) string {
+	var b []byte
+	for i, f := range fields {
+		if i >= maxFieldNamesForLog {
+			b = fmt.Appendf(b, ", ... (%d more)", len(fields)-i)
+			break
+		}
+		if i > 0 {
+			b = append(b, ", "...)
+		}
+		b = strconv.AppendQuote(b, f.Name)
+	}
+	return string(b)
+}
+
+const maxFieldNamesForLog = 100
+
 // AddRow adds new log message to lmp with the given timestamp and fields.
 //
 // If streamFields is non-nil, then it is used as log stream fields instead of the pre-configured stream fields.
@@ -232,10 +309,12 @@ func (lmp *logMessageProcessor) AddRow(timestamp int64, fields, streamFields []l
 	lmp.rowsIngestedTotal.Inc()
 	n := logstorage.EstimatedJSONRowLen(fields)
 	lmp.bytesIngestedTotal.Add(n)
+	lmp.tc.rowsIngestedTotal.Inc()
+	lmp.tc.bytesIngestedTotal.Add(n)
 
 	if len(fields) > *MaxFieldsPerLine {
-		line := logstorage.MarshalFieldsToJSON(nil, fields)
-		logger.Warnf("dropping log line with %d fields; it exceeds -insert.maxFieldsPerLine=%d; %s", len(fields), *MaxFieldsPerLine, line)
+		// Do not log field values, since the line isn't redacted yet. See -insert.redactConfig.
+		logger.Warnf("dropping log line with %d fields; it exceeds -insert.maxFieldsPerLine=%d; field names: %s", len(fields), *MaxFieldsPerLine, getFieldNamesForLog(fields))
 		rowsDroppedTotalTooManyFields.Inc()
 		return
 	}
@@ -243,6 +322,29 @@ func (lmp *logMessageProcessor) AddRow(timestamp int64, fields, streamFields []l
 	lmp.mu.Lock()
 	defer lmp.mu.Unlock()
 
+	if lmp.cp.GeoIPField != "" {
+		fields = lmp.addGeoIPFields(fields)
+	}
+	if len(lmp.relabelRules) > 0 {
+		var keep bool
+		lmp.relabeledFieldsBuf, lmp.relabeledStreamFieldsBuf, keep = relabelFields(lmp.relabeledFieldsBuf[:0], lmp.relabeledStreamFieldsBuf[:0],
+			lmp.relabelRules, fields, streamFields, lmp.lr.AppendStreamFields)
+		if !keep {
+			rowsDroppedTotalRelabel.Inc()
+			return
+		}
+		fields = lmp.relabeledFieldsBuf
+		streamFields = lmp.relabeledStreamFieldsBuf
+	}
+	if len(redactRules) > 0 {
+		lmp.redactedFieldsBuf = redactFields(lmp.redactedFieldsBuf[:0], redactRules, lmp.cp.TenantID, fields)
+		fields = lmp.redactedFieldsBuf
+		if streamFields != nil {
+			lmp.redactedStreamFieldsBuf = redactFields(lmp.redactedStreamFieldsBuf[:0], redactRules, lmp.cp.TenantID, streamFields)
+			streamFields = lmp.redactedStreamFieldsBuf
+		}
+	}
+
 	lmp.lr.MustAdd(lmp.cp.TenantID, timestamp, fields, streamFields)
 
 	if lmp.cp.Debug {
@@ -257,6 +359,41 @@ func (lmp *logMessageProcessor) AddRow(timestamp int64, fields, streamFields []l
 	}
 }
 
+// addGeoIPFields returns fields with the appended GeoIP information for the cp.GeoIPField value.
+//
+// The returned fields are valid until the next call to addGeoIPFields.
+func (lmp *logMessageProcessor) addGeoIPFields(fields []logstorage.Field) []logstorage.Field {
+	ip := ""
+	for _, f := range fields {
+		if f.Name == lmp.cp.GeoIPField {
+			ip = f.Value
+			break
+		}
+	}
+	if ip == "" {
+		return fields
+	}
+
+	var gi geoip.Info
+	if !geoip.Lookup(&gi, ip) {
+		return fields
+	}
+
+	dst := append(lmp.fieldsBuf[:0], fields...)
+	values := []string{gi.Country, gi.CountryName, gi.City, gi.ASN, gi.ASOrg}
+	for i, v := range values {
+		if v == "" {
+			continue
+		}
+		dst = append(dst, logstorage.Field{
+			Name:  lmp.geoipFieldNames[i],
+			Value: v,
+		})
+	}
+	lmp.fieldsBuf = dst
+	return dst
+}
+
 // InsertRowProcessor is used by native data ingestion protocol parser.
 type InsertRowProcessor interface {
 	// AddInsertRow must add r to the underlying storage.
@@ -268,10 +405,13 @@ func (lmp *logMessageProcessor) AddInsertRow(r *logstorage.InsertRow) {
 	lmp.rowsIngestedTotal.Inc()
 	n := logstorage.EstimatedJSONRowLen(r.Fields)
 	lmp.bytesIngestedTotal.Add(n)
+	tc := getTenantCounters(r.TenantID)
+	tc.rowsIngestedTotal.Inc()
+	tc.bytesIngestedTotal.Add(n)
 
 	if len(r.Fields) > *MaxFieldsPerLine {
-		line := logstorage.MarshalFieldsToJSON(nil, r.Fields)
-		logger.Warnf("dropping log line with %d fields; it exceeds -insert.maxFieldsPerLine=%d; %s", len(r.Fields), *MaxFieldsPerLine, line)
+		// Do not log field values, since the line isn't redacted yet. See -insert.redactConfig.
+		logger.Warnf("dropping log line with %d fields; it exceeds -insert.maxFieldsPerLine=%d; field names: %s", len(r.Fields), *MaxFieldsPerLine, getFieldNamesForLog(r.Fields))
 		rowsDroppedTotalTooManyFields.Inc()
 		return
 	}
@@ -279,6 +419,10 @@ func (lmp *logMessageProcessor) AddInsertRow(r *logstorage.InsertRow) {
 	lmp.mu.Lock()
 	defer lmp.mu.Unlock()
 
+	if len(redactRules) > 0 {
+		// Rows received via /internal/insert may come from vlagent or from other nodes without -insert.redactConfig.
+		r = redactInsertRow(&lmp.redactedInsertRow, redactRules, r)
+	}
 	lmp.lr.MustAddInsertRow(r)
 
 	if lmp.cp.Debug {
@@ -321,14 +465,26 @@ func (cp *CommonParams) NewLogMessageProcessor(protocolName string, isStreamMode
 	rowsIngestedTotal := metrics.GetOrCreateCounter(fmt.Sprintf("vl_rows_ingested_total{type=%q}", protocolName))
 	bytesIngestedTotal := metrics.GetOrCreateCounter(fmt.Sprintf("vl_bytes_ingested_total{type=%q}", protocolName))
 	flushDuration := metrics.GetOrCreateSummary(fmt.Sprintf("vl_insert_flush_duration_seconds{type=%q}", protocolName))
+	var geoipFieldNames []string
+	if cp.GeoIPField != "" {
+		for _, name := range []string{"country", "country_name", "city", "asn", "as_org"} {
+			geoipFieldNames = append(geoipFieldNames, cp.GeoIPPrefix+name)
+		}
+	}
 	lmp := &logMessageProcessor{
 		cp: cp,
 		lr: lr,
 
+		geoipFieldNames: geoipFieldNames,
+
 		rowsIngestedTotal:  rowsIngestedTotal,
 		bytesIngestedTotal: bytesIngestedTotal,
 		flushDuration:      flushDuration,
 
+		tc: getTenantCounters(cp.TenantID),
+
+		relabelRules: getRelabelRules(relabelRules, protocolName, cp.TenantID),
+
 		stopCh: make(chan struct{}),
 	}
 
@@ -340,9 +496,62 @@ func (cp *CommonParams) NewLogMessageProcessor(protocolName string, isStreamMode
 	return lmp
 }
 
+// tenantCounters contains ingestion counters for a single tenant.
+type tenantCounters struct {
+	rowsIngestedTotal  *metrics.Counter
+	bytesIngestedTotal *metrics.Counter
+}
+
+// tenantCountersMap contains tenantCounters for up to -insert.maxTenantMetrics tenants seen during data ingestion.
+var tenantCountersMap sync.Map
+
+var (
+	tenantCountersLock  sync.Mutex
+	tenantCountersCount atomic.Int64
+)
+
+// getTenantCounters returns ingestion counters for the given tenantID, which are exported at /metrics page.
+//
+// Counters shared among all the tenants are returned if the number of tracked tenants exceeds -insert.maxTenantMetrics,
+// in order to limit the number of exposed time series.
+func getTenantCounters(tenantID logstorage.TenantID) *tenantCounters {
+	if v, ok := tenantCountersMap.Load(tenantID); ok {
+		return v.(*tenantCounters)
+	}
+	if tenantCountersCount.Load() >= int64(*maxTenantMetrics) {
+		return getOtherTenantCounters()
+	}
+
+	tenantCountersLock.Lock()
+	defer tenantCountersLock.Unlock()
+
+	if v, ok := tenantCountersMap.Load(tenantID); ok {
+		return v.(*tenantCounters)
+	}
+	if tenantCountersCount.Load() >= int64(*maxTenantMetrics) {
+		return getOtherTenantCounters()
+	}
+	tc := &tenantCounters{
+		rowsIngestedTotal:  metrics.GetOrCreateCounter(fmt.Sprintf(`vl_tenant_rows_ingested_total{accountID="%d",projectID="%d"}`, tenantID.AccountID, tenantID.ProjectID)),
+		bytesIngestedTotal: metrics.GetOrCreateCounter(fmt.Sprintf(`vl_tenant_bytes_ingested_total{accountID="%d",projectID="%d"}`, tenantID.AccountID, tenantID.ProjectID)),
+	}
+	tenantCountersMap.Store(tenantID, tc)
+	tenantCountersCount.Add(1)
+	return tc
+}
+
+// getOtherTenantCounters returns ingestion counters for tenants exceeding -insert.maxTenantMetrics.
+var getOtherTenantCounters = sync.OnceValue(func() *tenantCounters {
+	return &tenantCounters{
+		rowsIngestedTotal:  metrics.GetOrCreateCounter(`vl_tenant_rows_ingested_total{accountID="other",projectID="other"}`),
+		bytesIngestedTotal: metrics.GetOrCreateCounter(`vl_tenant_bytes_ingested_total{accountID="other",projectID="other"}`),
+	}
+})
+
 var (
 	rowsDroppedTotalDebug         = metrics.NewCounter(`vl_rows_dropped_total{reason="debug"}`)
 	rowsDroppedTotalTooManyFields = metrics.NewCounter(`vl_rows_dropped_total{reason="too_many_fields"}`)
+	rowsDroppedTotalRelabel       = metrics.NewCounter(`vl_rows_dropped_total{reason="relabel"}`)
 	_                             = metrics.NewGauge(`vl_insert_processors_count`, func() float64 { return float64(messageProcessorCount.Load()) })
 	messageProcessorCount         atomic.Int64
 )
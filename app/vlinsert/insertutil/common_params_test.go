@@ -0,0 +1,46 @@
+package insertutil
+
+import (
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/logstorage"
+)
+
+func TestGetTenantCountersMaxTenantMetrics(t *testing.T) {
+	maxTenantMetricsOrig := *maxTenantMetrics
+	*maxTenantMetrics = 2
+	defer func() {
+		*maxTenantMetrics = maxTenantMetricsOrig
+		tenantCountersMap.Clear()
+		tenantCountersCount.Store(0)
+	}()
+	tenantCountersMap.Clear()
+	tenantCountersCount.Store(0)
+
+	tc1 := getTenantCounters(logstorage.TenantID{AccountID: 1})
+	tc2 := getTenantCounters(logstorage.TenantID{AccountID: 2})
+	if tc1 == tc2 {
+		t.Fatalf("expecting distinct counters for distinct tenants")
+	}
+	if tc := getTenantCounters(logstorage.TenantID{AccountID: 1}); tc != tc1 {
+		t.Fatalf("expecting the same counters for the same tenant")
+	}
+
+	// Tenants exceeding -insert.maxTenantMetrics must share the same counters
+	tcOther := getOtherTenantCounters()
+	if tc := getTenantCounters(logstorage.TenantID{AccountID: 3}); tc != tcOther {
+		t.Fatalf("expecting counters for other tenants")
+	}
+	if tc := getTenantCounters(logstorage.TenantID{AccountID: 4, ProjectID: 5}); tc != tcOther {
+		t.Fatalf("expecting counters for other tenants")
+	}
+
+	// Already tracked tenants must keep their counters
+	if tc := getTenantCounters(logstorage.TenantID{AccountID: 2}); tc != tc2 {
+		t.Fatalf("expecting the same counters for the already tracked tenant")
+	}
+
+	if n := tenantCountersCount.Load(); n != 2 {
+		t.Fatalf("unexpected number of tracked tenants; got %d; want 2", n)
+	}
+}
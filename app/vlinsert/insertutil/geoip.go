@@ -0,0 +1,31 @@
+package insertutil
+
+import (
+	"flag"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/geoip"
+)
+
+var (
+	geoipDBPath = flagutil.NewArrayString("geoip.dbPath", "Optional paths to MaxMind DB files such as GeoLite2-City.mmdb and GeoLite2-ASN.mmdb. "+
+		"They are used by geoip pipe and by GeoIP enrichment at data ingestion. The files are automatically re-read on changes; see -geoip.checkInterval. "+
+		"See https://docs.victoriametrics.com/victorialogs/logsql/#geoip-pipe")
+	geoipCheckInterval = flag.Duration("geoip.checkInterval", 30*time.Second, "The interval for checking -geoip.dbPath files for changes")
+)
+
+// MustInitGeoIP loads MaxMind DB files from -geoip.dbPath.
+//
+// It is called at the insert path, so GeoIP enrichment works both in VictoriaLogs and in vlagent.
+//
+// StopGeoIP must be called when GeoIP lookups are no longer needed.
+func MustInitGeoIP() {
+	geoip.MustInit(*geoipDBPath, *geoipCheckInterval)
+}
+
+// StopGeoIP stops watching -geoip.dbPath files for changes.
+func StopGeoIP() {
+	geoip.Stop()
+}
@@ -104,6 +104,10 @@ func (lr *LineReader) readMoreData() bool {
 	bufLen := len(lr.buf)
 	if bufLen >= MaxLineSizeBytes.IntN() {
 		lineSnippet := stringsutil.LimitStringLen(string(lr.buf), 1024)
+		if len(redactRules) > 0 {
+			// Do not log the line snippet, since it isn't redacted. See -insert.redactConfig.
+			lineSnippet = "<redacted>"
+		}
 		ok, skippedBytes := lr.skipUntilNextLine()
 		logger.Warnf("%s: the line length exceeds -insert.maxLineSizeBytes=%d; skipping it; total skipped bytes=%d; the line snippet=%q",
 			lr.name, MaxLineSizeBytes.IntN(), skippedBytes, lineSnippet)
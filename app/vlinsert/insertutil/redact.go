@@ -0,0 +1,351 @@
+package insertutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/logstorage"
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/prefixfilter"
+)
+
+var redactConfigPath = flag.String("insert.redactConfig", "", "Optional path to JSON file with rules for redacting sensitive data such as emails, card numbers and tokens from the ingested logs "+
+	"before storing them; see https://docs.victoriametrics.com/victorialogs/data-ingestion/#redaction")
+
+// redactRules contains rules loaded from -insert.redactConfig.
+var redactRules []*redactRule
+
+// MustInitRedaction loads redaction rules from -insert.redactConfig.
+func MustInitRedaction() {
+	if *redactConfigPath == "" {
+		return
+	}
+	data, err := os.ReadFile(*redactConfigPath)
+	if err != nil {
+		logger.Fatalf("cannot read -insert.redactConfig=%q: %s", *redactConfigPath, err)
+	}
+	rules, err := parseRedactConfig(data)
+	if err != nil {
+		logger.Fatalf("cannot parse -insert.redactConfig=%q: %s", *redactConfigPath, err)
+	}
+	redactRules = rules
+	logger.Infof("loaded %d redaction rules from -insert.redactConfig=%q", len(rules), *redactConfigPath)
+}
+
+// redactConfig is the config for -insert.redactConfig.
+type redactConfig struct {
+	Rules []redactRuleConfig `json:"rules"`
+}
+
+// redactRuleConfig is the config for a single redaction rule.
+type redactRuleConfig struct {
+	// Name is the rule name, which is used in vl_redact_hits_total metric.
+	Name string `json:"name"`
+
+	// Regex is the regular expression for the substrings to redact.
+	Regex string `json:"regex,omitempty"`
+
+	// Detector is the name of the built-in detector for the substrings to redact.
+	Detector string `json:"detector,omitempty"`
+
+	// Action is the action to apply to the matching substrings - mask or hash.
+	Action string `json:"action,omitempty"`
+
+	// Replacement is the replacement for the matching substrings for the mask action.
+	Replacement string `json:"replacement,omitempty"`
+
+	// Salt is the salt for the hash action.
+	Salt string `json:"salt,omitempty"`
+
+	// Fields contains field names and field name prefixes ending with '*' to apply the rule to.
+	Fields []string `json:"fields,omitempty"`
+
+	// Tenants contains tenants in the form accountID:projectID to apply the rule to.
+	Tenants []string `json:"tenants,omitempty"`
+}
+
+type redactRule struct {
+	name string
+
+	re *regexp.Regexp
+
+	// quickCheck is an optional function for fast detection of values, which cannot contain the matching substrings.
+	quickCheck func(s string) bool
+
+	// validate is an optional function for verifying the matching substrings.
+	validate func(s string) bool
+
+	hash        bool
+	replacement string
+	salt        string
+
+	// fieldFilters is nil if the rule must be applied to all the fields.
+	fieldFilters []string
+
+	// tenantIDs is nil if the rule must be applied to all the tenants.
+	tenantIDs []logstorage.TenantID
+
+	hitsTotal *metrics.Counter
+}
+
+func parseRedactConfig(data []byte) ([]*redactRule, error) {
+	var cfg redactConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	rules := make([]*redactRule, 0, len(cfg.Rules))
+	names := make(map[string]struct{}, len(cfg.Rules))
+	for i := range cfg.Rules {
+		rc := &cfg.Rules[i]
+		rule, err := newRedactRule(rc)
+		if err != nil {
+			return nil, fmt.Errorf("cannot initialize rule #%d: %w", i+1, err)
+		}
+		if _, ok := names[rule.name]; ok {
+			return nil, fmt.Errorf("duplicate rule name %q", rule.name)
+		}
+		names[rule.name] = struct{}{}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func newRedactRule(rc *redactRuleConfig) (*redactRule, error) {
+	if rc.Name == "" {
+		return nil, fmt.Errorf("missing rule name")
+	}
+
+	rule := &redactRule{
+		name: rc.Name,
+	}
+
+	switch {
+	case rc.Regex != "" && rc.Detector != "":
+		return nil, fmt.Errorf("rule %q cannot contain both regex and detector", rc.Name)
+	case rc.Regex != "":
+		re, err := regexp.Compile(rc.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse regex for rule %q: %w", rc.Name, err)
+		}
+		rule.re = re
+	case rc.Detector != "":
+		d, ok := redactDetectors[rc.Detector]
+		if !ok {
+			return nil, fmt.Errorf("unknown detector %q for rule %q; supported detectors: card, email, iban, jwt", rc.Detector, rc.Name)
+		}
+		rule.re = d.re
+		rule.quickCheck = d.quickCheck
+		rule.validate = d.validate
+	default:
+		return nil, fmt.Errorf("rule %q must contain either regex or detector", rc.Name)
+	}
+
+	switch rc.Action {
+	case "", "mask":
+		rule.replacement = rc.Replacement
+		if rule.replacement == "" {
+			rule.replacement = "***"
+		}
+		if rc.Salt != "" {
+			return nil, fmt.Errorf("salt cannot be set for the mask action at rule %q", rc.Name)
+		}
+	case "hash":
+		rule.hash = true
+		rule.salt = rc.Salt
+		if rc.Replacement != "" {
+			return nil, fmt.Errorf("replacement cannot be set for the hash action at rule %q", rc.Name)
+		}
+	default:
+		return nil, fmt.Errorf("unknown action %q for rule %q; supported actions: mask, hash", rc.Action, rc.Name)
+	}
+
+	if len(rc.Fields) > 0 {
+		rule.fieldFilters = rc.Fields
+	}
+
+	for _, s := range rc.Tenants {
+		tenantID, err := logstorage.ParseTenantID(s)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse tenant %q for rule %q: %w", s, rc.Name, err)
+		}
+		rule.tenantIDs = append(rule.tenantIDs, tenantID)
+	}
+
+	rule.hitsTotal = metrics.GetOrCreateCounter(fmt.Sprintf(`vl_redact_hits_total{rule=%q}`, rule.name))
+
+	return rule, nil
+}
+
+func (rule *redactRule) matchTenant(tenantID logstorage.TenantID) bool {
+	if rule.tenantIDs == nil {
+		return true
+	}
+	for _, t := range rule.tenantIDs {
+		if t == tenantID {
+			return true
+		}
+	}
+	return false
+}
+
+func (rule *redactRule) matchField(name string) bool {
+	if rule.fieldFilters == nil {
+		return true
+	}
+	return prefixfilter.MatchFilters(rule.fieldFilters, name)
+}
+
+// redact returns s with the redacted substrings matching the rule.
+func (rule *redactRule) redact(s string) string {
+	if rule.quickCheck != nil && !rule.quickCheck(s) {
+		return s
+	}
+	return rule.re.ReplaceAllStringFunc(s, func(match string) string {
+		if rule.validate != nil && !rule.validate(match) {
+			return match
+		}
+		rule.hitsTotal.Inc()
+		if !rule.hash {
+			return rule.replacement
+		}
+		h := sha256.Sum256([]byte(rule.salt + match))
+		return "sha256:" + hex.EncodeToString(h[:8])
+	})
+}
+
+// redactFields appends fields with the redacted values according to rules to dst and returns the result.
+//
+// fields are appended to dst as is if there are no rules for the given tenantID.
+func redactFields(dst []logstorage.Field, rules []*redactRule, tenantID logstorage.TenantID, fields []logstorage.Field) []logstorage.Field {
+	for _, f := range fields {
+		for _, rule := range rules {
+			if rule.matchTenant(tenantID) && rule.matchField(f.Name) {
+				f.Value = rule.redact(f.Value)
+			}
+		}
+		dst = append(dst, f)
+	}
+	return dst
+}
+
+// redactInsertRow returns r with the field values and the stream tag values redacted according to rules.
+//
+// r isn't modified. The returned row uses dst as a buffer, so it is valid until the next call to redactInsertRow with the same dst.
+func redactInsertRow(dst *logstorage.InsertRow, rules []*redactRule, r *logstorage.InsertRow) *logstorage.InsertRow {
+	dst.TenantID = r.TenantID
+	dst.Timestamp = r.Timestamp
+	dst.Fields = redactFields(dst.Fields[:0], rules, r.TenantID, r.Fields)
+	dst.StreamTagsCanonical = redactStreamTagsCanonical(rules, r.TenantID, r.StreamTagsCanonical)
+	return dst
+}
+
+// redactStreamTagsCanonical returns streamTagsCanonical with the tag values redacted according to rules.
+//
+// streamTagsCanonical is returned as is if it cannot be unmarshaled, so the caller could report the error.
+func redactStreamTagsCanonical(rules []*redactRule, tenantID logstorage.TenantID, streamTagsCanonical string) string {
+	st := logstorage.GetStreamTags()
+	defer logstorage.PutStreamTags(st)
+
+	tail, err := st.UnmarshalCanonical(bytesutil.ToUnsafeBytes(streamTagsCanonical))
+	if err != nil || len(tail) > 0 {
+		return streamTagsCanonical
+	}
+	fields := redactFields(nil, rules, tenantID, st.AppendFields(nil))
+
+	stRedacted := logstorage.GetStreamTags()
+	defer logstorage.PutStreamTags(stRedacted)
+
+	for _, f := range fields {
+		stRedacted.Add(f.Name, f.Value)
+	}
+	return string(stRedacted.MarshalCanonical(nil))
+}
+
+type redactDetector struct {
+	re         *regexp.Regexp
+	quickCheck func(s string) bool
+	validate   func(s string) bool
+}
+
+var redactDetectors = map[string]*redactDetector{
+	"card": {
+		re:         regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		quickCheck: containsDigits,
+		validate:   isValidCardNumber,
+	},
+	"email": {
+		re: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`),
+		quickCheck: func(s string) bool {
+			return strings.IndexByte(s, '@') >= 0
+		},
+	},
+	"iban": {
+		re:         regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]){11,30}\b`),
+		quickCheck: containsDigits,
+		validate:   isValidIBAN,
+	},
+	"jwt": {
+		re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+		quickCheck: func(s string) bool {
+			return strings.Contains(s, "eyJ")
+		},
+	},
+}
+
+func containsDigits(s string) bool {
+	return strings.IndexAny(s, "0123456789") >= 0
+}
+
+// isValidCardNumber returns true if s contains 13-19 digits with valid Luhn checksum.
+func isValidCardNumber(s string) bool {
+	sum := 0
+	n := 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && n <= 19 && sum%10 == 0
+}
+
+// isValidIBAN returns true if s contains IBAN with valid mod-97 checksum.
+func isValidIBAN(s string) bool {
+	s = strings.ReplaceAll(s, " ", "")
+	if len(s) < 15 || len(s) > 34 {
+		return false
+	}
+	s = s[4:] + s[:4]
+	rem := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= '0' && c <= '9':
+			rem = (rem*10 + int(c-'0')) % 97
+		case c >= 'A' && c <= 'Z':
+			rem = (rem*100 + int(c-'A') + 10) % 97
+		default:
+			return false
+		}
+	}
+	return rem == 1
+}
@@ -0,0 +1,359 @@
+package insertutil
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/cespare/xxhash/v2"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/logstorage"
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/prefixfilter"
+)
+
+var relabelConfigPath = flag.String("insert.relabelConfig", "", "Optional path to JSON file with Prometheus-style relabeling rules for fields and stream fields of the ingested logs; "+
+	"see https://docs.victoriametrics.com/victorialogs/data-ingestion/#relabeling")
+
+// relabelRules contains rules loaded from -insert.relabelConfig.
+var relabelRules []*relabelRule
+
+// MustInitRelabeling loads relabeling rules from -insert.relabelConfig.
+func MustInitRelabeling() {
+	if *relabelConfigPath == "" {
+		return
+	}
+	data, err := os.ReadFile(*relabelConfigPath)
+	if err != nil {
+		logger.Fatalf("cannot read -insert.relabelConfig=%q: %s", *relabelConfigPath, err)
+	}
+	rules, err := parseRelabelConfig(data)
+	if err != nil {
+		logger.Fatalf("cannot parse -insert.relabelConfig=%q: %s", *relabelConfigPath, err)
+	}
+	relabelRules = rules
+	logger.Infof("loaded %d relabeling rules from -insert.relabelConfig=%q", len(rules), *relabelConfigPath)
+}
+
+// relabelConfig is the config for -insert.relabelConfig.
+type relabelConfig struct {
+	Rules []relabelRuleConfig `json:"rules"`
+}
+
+// relabelRuleConfig is the config for a single relabeling rule.
+//
+// It follows Prometheus relabel_config, where labels are log fields.
+type relabelRuleConfig struct {
+	// Action is the action to apply - replace, keep, drop, labeldrop, labelmap or hashmod.
+	Action string `json:"action,omitempty"`
+
+	// SourceLabels contains field names, which values are joined with Separator before matching against Regex.
+	SourceLabels []string `json:"source_labels,omitempty"`
+
+	// Separator is the separator for SourceLabels values.
+	Separator *string `json:"separator,omitempty"`
+
+	// Regex is the regular expression to match. It is anchored at both ends.
+	Regex *string `json:"regex,omitempty"`
+
+	// TargetLabel is the field name to write the result to for replace and hashmod actions.
+	TargetLabel string `json:"target_label,omitempty"`
+
+	// Replacement is the replacement for replace and labelmap actions. It may refer capture groups from Regex.
+	Replacement *string `json:"replacement,omitempty"`
+
+	// Modulus is the modulus for hashmod action.
+	Modulus uint64 `json:"modulus,omitempty"`
+
+	// StreamOnly instructs applying the rule only to stream fields, while leaving the stored log fields as is.
+	StreamOnly bool `json:"stream_only,omitempty"`
+
+	// Endpoints contains data ingestion protocols and protocol prefixes ending with '*' to apply the rule to.
+	Endpoints []string `json:"endpoints,omitempty"`
+
+	// Tenants contains tenants in the form accountID:projectID to apply the rule to.
+	Tenants []string `json:"tenants,omitempty"`
+}
+
+type relabelRule struct {
+	action       string
+	sourceLabels []string
+	separator    string
+	regex        *regexp.Regexp
+	targetLabel  string
+	replacement  string
+	modulus      uint64
+	streamOnly   bool
+
+	// endpointFilters is nil if the rule must be applied to all the endpoints.
+	endpointFilters []string
+
+	// tenantIDs is nil if the rule must be applied to all the tenants.
+	tenantIDs []logstorage.TenantID
+}
+
+func parseRelabelConfig(data []byte) ([]*relabelRule, error) {
+	var cfg relabelConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	rules := make([]*relabelRule, 0, len(cfg.Rules))
+	for i := range cfg.Rules {
+		rule, err := newRelabelRule(&cfg.Rules[i])
+		if err != nil {
+			return nil, fmt.Errorf("cannot initialize rule #%d: %w", i+1, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func newRelabelRule(rc *relabelRuleConfig) (*relabelRule, error) {
+	rule := &relabelRule{
+		action:       rc.Action,
+		sourceLabels: rc.SourceLabels,
+		separator:    ";",
+		targetLabel:  rc.TargetLabel,
+		replacement:  "$1",
+		modulus:      rc.Modulus,
+		streamOnly:   rc.StreamOnly,
+	}
+	if rule.action == "" {
+		rule.action = "replace"
+	}
+	if rc.Separator != nil {
+		rule.separator = *rc.Separator
+	}
+	if rc.Replacement != nil {
+		rule.replacement = *rc.Replacement
+	}
+
+	regex := "(.*)"
+	if rc.Regex != nil {
+		regex = *rc.Regex
+	}
+	re, err := regexp.Compile("^(?:" + regex + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse regex %q: %w", regex, err)
+	}
+	rule.regex = re
+
+	switch rule.action {
+	case "replace":
+		if rule.targetLabel == "" {
+			return nil, fmt.Errorf("missing target_label for action=replace")
+		}
+	case "keep", "drop":
+		if len(rule.sourceLabels) == 0 {
+			return nil, fmt.Errorf("missing source_labels for action=%s", rule.action)
+		}
+	case "labeldrop", "labelmap":
+		if len(rule.sourceLabels) > 0 || rule.targetLabel != "" {
+			return nil, fmt.Errorf("source_labels and target_label cannot be set for action=%s", rule.action)
+		}
+	case "hashmod":
+		if len(rule.sourceLabels) == 0 {
+			return nil, fmt.Errorf("missing source_labels for action=hashmod")
+		}
+		if rule.targetLabel == "" {
+			return nil, fmt.Errorf("missing target_label for action=hashmod")
+		}
+		if rule.modulus == 0 {
+			return nil, fmt.Errorf("modulus must be bigger than 0 for action=hashmod")
+		}
+	default:
+		return nil, fmt.Errorf("unknown action %q; supported actions: replace, keep, drop, labeldrop, labelmap, hashmod", rule.action)
+	}
+	if rule.action != "hashmod" && rule.modulus != 0 {
+		return nil, fmt.Errorf("modulus cannot be set for action=%s", rule.action)
+	}
+
+	if len(rc.Endpoints) > 0 {
+		rule.endpointFilters = rc.Endpoints
+	}
+
+	for _, s := range rc.Tenants {
+		tenantID, err := logstorage.ParseTenantID(s)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse tenant %q: %w", s, err)
+		}
+		rule.tenantIDs = append(rule.tenantIDs, tenantID)
+	}
+
+	return rule, nil
+}
+
+// getRelabelRules returns rules, which must be applied to logs ingested via the given protocolName for the given tenantID.
+func getRelabelRules(rules []*relabelRule, protocolName string, tenantID logstorage.TenantID) []*relabelRule {
+	var result []*relabelRule
+	for _, rule := range rules {
+		if rule.matchEndpoint(protocolName) && rule.matchTenant(tenantID) {
+			result = append(result, rule)
+		}
+	}
+	return result
+}
+
+func (rule *relabelRule) matchEndpoint(protocolName string) bool {
+	if rule.endpointFilters == nil {
+		return true
+	}
+	return prefixfilter.MatchFilters(rule.endpointFilters, protocolName)
+}
+
+func (rule *relabelRule) matchTenant(tenantID logstorage.TenantID) bool {
+	if rule.tenantIDs == nil {
+		return true
+	}
+	for _, t := range rule.tenantIDs {
+		if t == tenantID {
+			return true
+		}
+	}
+	return false
+}
+
+// apply applies the rule to fields and returns the result.
+//
+// fields are modified in place. false is returned if the log entry must be dropped.
+func (rule *relabelRule) apply(fields []logstorage.Field) ([]logstorage.Field, bool) {
+	switch rule.action {
+	case "replace":
+		v := rule.getSourceValue(fields)
+		match := rule.regex.FindStringSubmatchIndex(v)
+		if match == nil {
+			return fields, true
+		}
+		value := rule.regex.ExpandString(nil, rule.replacement, v, match)
+		return setField(fields, rule.targetLabel, string(value)), true
+	case "keep":
+		return fields, rule.regex.MatchString(rule.getSourceValue(fields))
+	case "drop":
+		return fields, !rule.regex.MatchString(rule.getSourceValue(fields))
+	case "labeldrop":
+		dst := fields[:0]
+		for _, f := range fields {
+			if !rule.regex.MatchString(f.Name) {
+				dst = append(dst, f)
+			}
+		}
+		return dst, true
+	case "labelmap":
+		n := len(fields)
+		for i := 0; i < n; i++ {
+			f := fields[i]
+			if f.Value == "" {
+				continue
+			}
+			match := rule.regex.FindStringSubmatchIndex(f.Name)
+			if match == nil {
+				continue
+			}
+			name := rule.regex.ExpandString(nil, rule.replacement, f.Name, match)
+			fields = setField(fields, string(name), f.Value)
+		}
+		return fields, true
+	case "hashmod":
+		h := xxhash.Sum64String(rule.getSourceValue(fields)) % rule.modulus
+		return setField(fields, rule.targetLabel, strconv.FormatUint(h, 10)), true
+	default:
+		logger.Panicf("BUG: unexpected action=%q", rule.action)
+		return nil, false
+	}
+}
+
+// getSourceValue returns values for rule.sourceLabels joined with rule.separator.
+func (rule *relabelRule) getSourceValue(fields []logstorage.Field) string {
+	if len(rule.sourceLabels) == 1 {
+		return getFieldValue(fields, rule.sourceLabels[0])
+	}
+	values := make([]string, len(rule.sourceLabels))
+	for i, name := range rule.sourceLabels {
+		values[i] = getFieldValue(fields, name)
+	}
+	return strings.Join(values, rule.separator)
+}
+
+func getFieldValue(fields []logstorage.Field, name string) string {
+	for _, f := range fields {
+		if f.Name == name {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// setField sets the field with the given name to the given value in fields and returns the result.
+//
+// The field is removed if the value is empty, since empty fields are equivalent to missing fields.
+func setField(fields []logstorage.Field, name, value string) []logstorage.Field {
+	if value == "" {
+		dst := fields[:0]
+		for _, f := range fields {
+			if f.Name != name {
+				dst = append(dst, f)
+			}
+		}
+		return dst
+	}
+	for i := range fields {
+		if fields[i].Name == name {
+			fields[i].Value = value
+			return fields
+		}
+	}
+	return append(fields, logstorage.Field{
+		Name:  name,
+		Value: value,
+	})
+}
+
+// relabelFields applies rules to fields and streamFields and appends the results to dst and dstStream.
+//
+// Rules without stream_only are applied to fields and to stream fields if they are set explicitly.
+// Rules with stream_only are applied only to stream fields, which are obtained via appendStreamFields from fields if streamFields is nil.
+// The returned stream fields are nil if streamFields is nil and there are no rules with stream_only.
+//
+// false is returned if the log entry must be dropped.
+func relabelFields(dst, dstStream []logstorage.Field, rules []*relabelRule, fields, streamFields []logstorage.Field,
+	appendStreamFields func(dst, fields []logstorage.Field) []logstorage.Field) ([]logstorage.Field, []logstorage.Field, bool) {
+
+	dst = append(dst, fields...)
+	hasStream := streamFields != nil
+	if hasStream {
+		dstStream = append(dstStream, streamFields...)
+	}
+
+	keep := true
+	for _, rule := range rules {
+		if rule.streamOnly {
+			if !hasStream {
+				dstStream = appendStreamFields(dstStream, dst)
+				hasStream = true
+			}
+			dstStream, keep = rule.apply(dstStream)
+		} else {
+			dst, keep = rule.apply(dst)
+			if keep && hasStream {
+				dstStream, _ = rule.apply(dstStream)
+			}
+		}
+		if !keep {
+			return dst, dstStream, false
+		}
+	}
+
+	if !hasStream {
+		return dst, nil, true
+	}
+	if dstStream == nil {
+		// Return non-nil stream fields, so they override the pre-configured stream fields.
+		dstStream = []logstorage.Field{}
+	}
+	return dst, dstStream, true
+}
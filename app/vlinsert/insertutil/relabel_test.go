@@ -0,0 +1,156 @@
+package insertutil
+
+import (
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/logstorage"
+)
+
+func TestParseRelabelConfigFailure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+
+		if _, err := parseRelabelConfig([]byte(data)); err == nil {
+			t.Fatalf("expecting non-nil error for %s", data)
+		}
+	}
+
+	// invalid JSON
+	f(`foo`)
+	f(`{"rules":{}}`)
+
+	// unknown action
+	f(`{"rules":[{"action":"foo"}]}`)
+
+	// invalid regex
+	f(`{"rules":[{"action":"labeldrop","regex":"("}]}`)
+
+	// missing target_label
+	f(`{"rules":[{"source_labels":["a"]}]}`)
+	f(`{"rules":[{"action":"hashmod","source_labels":["a"],"modulus":2}]}`)
+
+	// missing source_labels
+	f(`{"rules":[{"action":"keep","regex":"a"}]}`)
+	f(`{"rules":[{"action":"drop","regex":"a"}]}`)
+	f(`{"rules":[{"action":"hashmod","target_label":"a","modulus":2}]}`)
+
+	// source_labels and target_label for label actions
+	f(`{"rules":[{"action":"labeldrop","source_labels":["a"]}]}`)
+	f(`{"rules":[{"action":"labelmap","target_label":"a"}]}`)
+
+	// invalid modulus
+	f(`{"rules":[{"action":"hashmod","source_labels":["a"],"target_label":"b"}]}`)
+	f(`{"rules":[{"action":"replace","source_labels":["a"],"target_label":"b","modulus":2}]}`)
+
+	// invalid tenant
+	f(`{"rules":[{"action":"labeldrop","regex":"a","tenants":["foo"]}]}`)
+}
+
+func TestRelabelFields(t *testing.T) {
+	f := func(config, protocolName string, tenantID logstorage.TenantID, fields, streamFields []logstorage.Field, resultExpected, streamResultExpected string) {
+		t.Helper()
+
+		rules, err := parseRelabelConfig([]byte(config))
+		if err != nil {
+			t.Fatalf("cannot parse config: %s", err)
+		}
+		rules = getRelabelRules(rules, protocolName, tenantID)
+
+		lr := logstorage.GetLogRows([]string{"namespace", "pod"}, nil, nil, nil, "")
+		defer logstorage.PutLogRows(lr)
+
+		result, streamResult, keep := relabelFields(nil, nil, rules, fields, streamFields, lr.AppendStreamFields)
+		if !keep {
+			if resultExpected != "" {
+				t.Fatalf("unexpected drop of the log entry; want %s", resultExpected)
+			}
+			return
+		}
+		if data := logstorage.MarshalFieldsToJSON(nil, result); string(data) != resultExpected {
+			t.Fatalf("unexpected fields\ngot\n%s\nwant\n%s", data, resultExpected)
+		}
+		streamResultStr := "nil"
+		if streamResult != nil {
+			streamResultStr = string(logstorage.MarshalFieldsToJSON(nil, streamResult))
+		}
+		if streamResultStr != streamResultExpected {
+			t.Fatalf("unexpected stream fields\ngot\n%s\nwant\n%s", streamResultStr, streamResultExpected)
+		}
+	}
+
+	fields := []logstorage.Field{
+		{Name: "_msg", Value: "foo"},
+		{Name: "namespace", Value: "Prod-EU"},
+		{Name: "pod", Value: "app-7d9f-x2"},
+		{Name: "k8s_node", Value: "n1"},
+	}
+
+	// no rules
+	f(`{}`, "jsonline", logstorage.TenantID{}, fields, nil,
+		`{"_msg":"foo","namespace":"Prod-EU","pod":"app-7d9f-x2","k8s_node":"n1"}`, `nil`)
+
+	// replace
+	f(`{"rules":[{"source_labels":["namespace"],"regex":"([a-zA-Z]+)-.*","target_label":"namespace","replacement":"${1}"}]}`,
+		"jsonline", logstorage.TenantID{}, fields, nil,
+		`{"_msg":"foo","namespace":"Prod","pod":"app-7d9f-x2","k8s_node":"n1"}`, `nil`)
+
+	// replace with multiple source labels
+	f(`{"rules":[{"source_labels":["namespace","k8s_node"],"separator":"/","target_label":"location"}]}`,
+		"jsonline", logstorage.TenantID{}, fields, nil,
+		`{"_msg":"foo","namespace":"Prod-EU","pod":"app-7d9f-x2","k8s_node":"n1","location":"Prod-EU/n1"}`, `nil`)
+
+	// replace with empty value removes the field
+	f(`{"rules":[{"source_labels":["missing"],"target_label":"pod"}]}`,
+		"jsonline", logstorage.TenantID{}, fields, nil,
+		`{"_msg":"foo","namespace":"Prod-EU","k8s_node":"n1"}`, `nil`)
+
+	// keep and drop
+	f(`{"rules":[{"action":"keep","source_labels":["namespace"],"regex":"Prod-.*"}]}`,
+		"jsonline", logstorage.TenantID{}, fields, nil,
+		`{"_msg":"foo","namespace":"Prod-EU","pod":"app-7d9f-x2","k8s_node":"n1"}`, `nil`)
+	f(`{"rules":[{"action":"keep","source_labels":["namespace"],"regex":"Dev-.*"}]}`,
+		"jsonline", logstorage.TenantID{}, fields, nil, ``, ``)
+	f(`{"rules":[{"action":"drop","source_labels":["namespace"],"regex":"Prod-.*"}]}`,
+		"jsonline", logstorage.TenantID{}, fields, nil, ``, ``)
+
+	// labeldrop and labelmap
+	f(`{"rules":[{"action":"labeldrop","regex":"pod|k8s_.*"}]}`,
+		"jsonline", logstorage.TenantID{}, fields, nil,
+		`{"_msg":"foo","namespace":"Prod-EU"}`, `nil`)
+	f(`{"rules":[{"action":"labelmap","regex":"k8s_(.+)"}]}`,
+		"jsonline", logstorage.TenantID{}, fields, nil,
+		`{"_msg":"foo","namespace":"Prod-EU","pod":"app-7d9f-x2","k8s_node":"n1","node":"n1"}`, `nil`)
+
+	// hashmod
+	f(`{"rules":[{"action":"hashmod","source_labels":["pod"],"target_label":"shard","modulus":1}]}`,
+		"jsonline", logstorage.TenantID{}, fields, nil,
+		`{"_msg":"foo","namespace":"Prod-EU","pod":"app-7d9f-x2","k8s_node":"n1","shard":"0"}`, `nil`)
+
+	// stream_only rule drops the field from stream fields obtained from the pre-configured stream fields, while leaving the log field as is
+	f(`{"rules":[{"action":"labeldrop","regex":"pod","stream_only":true}]}`,
+		"jsonline", logstorage.TenantID{}, fields, nil,
+		`{"_msg":"foo","namespace":"Prod-EU","pod":"app-7d9f-x2","k8s_node":"n1"}`, `{"namespace":"Prod-EU"}`)
+
+	// rules are applied to explicitly set stream fields
+	f(`{"rules":[{"source_labels":["namespace"],"regex":"(.+)-EU","target_label":"namespace"}]}`,
+		"loki_json", logstorage.TenantID{}, fields, fields[1:3],
+		`{"_msg":"foo","namespace":"Prod","pod":"app-7d9f-x2","k8s_node":"n1"}`, `{"namespace":"Prod","pod":"app-7d9f-x2"}`)
+
+	// empty stream fields after stream_only rule
+	f(`{"rules":[{"action":"labeldrop","regex":".*","stream_only":true}]}`,
+		"jsonline", logstorage.TenantID{}, fields, nil,
+		`{"_msg":"foo","namespace":"Prod-EU","pod":"app-7d9f-x2","k8s_node":"n1"}`, `{}`)
+
+	// the rule for another endpoint and another tenant
+	f(`{"rules":[{"action":"labeldrop","regex":"pod","endpoints":["loki*"]}]}`,
+		"jsonline", logstorage.TenantID{}, fields, nil,
+		`{"_msg":"foo","namespace":"Prod-EU","pod":"app-7d9f-x2","k8s_node":"n1"}`, `nil`)
+	f(`{"rules":[{"action":"labeldrop","regex":"pod","tenants":["1:0"]}]}`,
+		"jsonline", logstorage.TenantID{}, fields, nil,
+		`{"_msg":"foo","namespace":"Prod-EU","pod":"app-7d9f-x2","k8s_node":"n1"}`, `nil`)
+
+	// the rule for the given endpoint and tenant
+	f(`{"rules":[{"action":"labeldrop","regex":"pod","endpoints":["loki*"],"tenants":["1:0"]}]}`,
+		"loki_protobuf", logstorage.TenantID{AccountID: 1}, fields, nil,
+		`{"_msg":"foo","namespace":"Prod-EU","k8s_node":"n1"}`, `nil`)
+}
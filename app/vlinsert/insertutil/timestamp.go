@@ -15,14 +15,16 @@ import (
 // so it could be ignored during data ingestion.
 //
 // The current timestamp is returned if fields do not contain a field with timeField name or if the timeField value is empty.
-func ExtractTimestampFromFields(timeFields []string, fields []logstorage.Field) (int64, error) {
+//
+// The timestamp is parsed with tp. Unix timestamps and RFC3339 timestamps are detected automatically if tp is nil.
+func ExtractTimestampFromFields(timeFields []string, tp *logstorage.TimeParser, fields []logstorage.Field) (int64, error) {
 	for _, timeField := range timeFields {
 		for i := range fields {
 			f := &fields[i]
 			if f.Name != timeField {
 				continue
 			}
-			nsecs, err := parseTimestamp(f.Value)
+			nsecs, err := parseTimestamp(f.Value, tp)
 			if err != nil {
 				return 0, fmt.Errorf("cannot parse timestamp from field %q: %s", f.Name, err)
 			}
@@ -36,13 +38,20 @@ func ExtractTimestampFromFields(timeFields []string, fields []logstorage.Field)
 	return time.Now().UnixNano(), nil
 }
 
-func parseTimestamp(s string) (int64, error) {
+func parseTimestamp(s string, tp *logstorage.TimeParser) (int64, error) {
 	// "-" is a nil timestamp value, if the syslog
 	// application is incapable of obtaining system time
 	// https://datatracker.ietf.org/doc/html/rfc5424#section-6.2.3
 	if s == "" || s == "0" || s == "-" {
 		return time.Now().UnixNano(), nil
 	}
+	if tp != nil {
+		nsecs, ok := tp.TryParse(s)
+		if !ok {
+			return 0, fmt.Errorf("cannot parse timestamp %q in format %q", s, tp.Format())
+		}
+		return nsecs, nil
+	}
 	if len(s) <= len("YYYY") || s[len("YYYY")] != '-' {
 		nsecs, ok := timeutil.TryParseUnixTimestamp(s)
 		if !ok {
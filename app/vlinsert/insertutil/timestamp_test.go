@@ -10,7 +10,7 @@ func TestExtractTimestampFromFields_Success(t *testing.T) {
 	f := func(timeField string, fields []logstorage.Field, nsecsExpected int64) {
 		t.Helper()
 
-		nsecs, err := ExtractTimestampFromFields([]string{timeField}, fields)
+		nsecs, err := ExtractTimestampFromFields([]string{timeField}, nil, fields)
 		if err != nil {
 			t.Fatalf("unexpected error: %s", err)
 		}
@@ -80,7 +80,7 @@ func TestExtractTimestampFromFields_Now(t *testing.T) {
 	f := func(timeField string, fields []logstorage.Field) {
 		t.Helper()
 
-		nsecs, err := ExtractTimestampFromFields([]string{timeField}, fields)
+		nsecs, err := ExtractTimestampFromFields([]string{timeField}, nil, fields)
 		if err != nil {
 			t.Fatalf("unexpected error: %s", err)
 		}
@@ -110,7 +110,7 @@ func TestExtractTimestampFromFields_Error(t *testing.T) {
 		fields := []logstorage.Field{
 			{Name: "time", Value: s},
 		}
-		nsecs, err := ExtractTimestampFromFields([]string{"time"}, fields)
+		nsecs, err := ExtractTimestampFromFields([]string{"time"}, nil, fields)
 		if err == nil {
 			t.Fatalf("expecting non-nil error")
 		}
@@ -126,3 +126,40 @@ func TestExtractTimestampFromFields_Error(t *testing.T) {
 	f("2024-06-18")
 	f("2024-06-18T23:37")
 }
+
+func TestExtractTimestampFromFields_TimeParser(t *testing.T) {
+	f := func(format, timezone, s string, nsecsExpected int64) {
+		t.Helper()
+
+		tp, err := logstorage.NewTimeParser(format, timezone)
+		if err != nil {
+			t.Fatalf("cannot create time parser: %s", err)
+		}
+		fields := []logstorage.Field{
+			{Name: "time", Value: s},
+		}
+		nsecs, err := ExtractTimestampFromFields([]string{"time"}, tp, fields)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if nsecs != nsecsExpected {
+			t.Fatalf("unexpected nsecs; got %d; want %d", nsecs, nsecsExpected)
+		}
+	}
+
+	f("02/Jan/2006:15:04:05 -0700", "", "18/Jun/2024:23:37:20 +0200", 1718746640000000000)
+	f("%Y-%m-%d %H:%M:%S", "Europe/Berlin", "2024-06-18 23:37:20", 1718746640000000000)
+	f("unix_ms", "", "1718773640123", 1718773640123000000)
+
+	// The value in other format cannot be parsed
+	tp, err := logstorage.NewTimeParser("unix_s", "")
+	if err != nil {
+		t.Fatalf("cannot create time parser: %s", err)
+	}
+	fields := []logstorage.Field{
+		{Name: "time", Value: "2024-06-18T23:37:20Z"},
+	}
+	if _, err := ExtractTimestampFromFields([]string{"time"}, tp, fields); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+}
@@ -48,7 +48,7 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) {
 
 	lmp := cp.NewLogMessageProcessor("jsonline", true)
 	streamName := fmt.Sprintf("remoteAddr=%s, requestURI=%q", httpserver.GetQuotedRemoteAddr(r), r.RequestURI)
-	err = processStreamInternal(streamName, reader, cp.TimeFields, cp.MsgFields, lmp)
+	err = processStreamInternal(streamName, reader, cp.TimeFields, cp.TimeParser, cp.MsgFields, lmp)
 	lmp.MustClose()
 	if err != nil {
 		httpserver.Errorf(w, r, "cannot process jsonline request; error: %s", err)
@@ -58,7 +58,7 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) {
 	requestDuration.UpdateDuration(startTime)
 }
 
-func processStreamInternal(streamName string, r io.Reader, timeFields, msgFields []string, lmp insertutil.LogMessageProcessor) error {
+func processStreamInternal(streamName string, r io.Reader, timeFields []string, timeParser *logstorage.TimeParser, msgFields []string, lmp insertutil.LogMessageProcessor) error {
 	wcr := writeconcurrencylimiter.GetReader(r)
 	defer writeconcurrencylimiter.PutReader(wcr)
 
@@ -68,7 +68,7 @@ func processStreamInternal(streamName string, r io.Reader, timeFields, msgFields
 	errors := 0
 	var lastError error
 	for {
-		ok, err := readLine(lr, timeFields, msgFields, lmp)
+		ok, err := readLine(lr, timeFields, timeParser, msgFields, lmp)
 		wcr.DecConcurrency()
 		if err != nil {
 			lastError = err
@@ -90,7 +90,7 @@ func processStreamInternal(streamName string, r io.Reader, timeFields, msgFields
 	return nil
 }
 
-func readLine(lr *insertutil.LineReader, timeFields, msgFields []string, lmp insertutil.LogMessageProcessor) (bool, error) {
+func readLine(lr *insertutil.LineReader, timeFields []string, timeParser *logstorage.TimeParser, msgFields []string, lmp insertutil.LogMessageProcessor) (bool, error) {
 	var line []byte
 	for len(line) == 0 {
 		if !lr.NextLine() {
@@ -106,7 +106,7 @@ func readLine(lr *insertutil.LineReader, timeFields, msgFields []string, lmp ins
 	if err := p.ParseLogMessage(line); err != nil {
 		return true, fmt.Errorf("%s; line contents: %q", err, line)
 	}
-	ts, err := insertutil.ExtractTimestampFromFields(timeFields, p.Fields)
+	ts, err := insertutil.ExtractTimestampFromFields(timeFields, timeParser, p.Fields)
 	if err != nil {
 		return true, fmt.Errorf("%s; line contents: %q", err, line)
 	}
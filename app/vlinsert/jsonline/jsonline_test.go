@@ -16,7 +16,7 @@ func TestProcessStreamInternalSuccess(t *testing.T) {
 		msgFields := []string{msgField}
 		tlp := &insertutil.TestLogMessageProcessor{}
 		r := bytes.NewBufferString(data)
-		if err := processStreamInternal("test", r, timeFields, msgFields, tlp); err != nil {
+		if err := processStreamInternal("test", r, timeFields, nil, msgFields, tlp); err != nil {
 			t.Fatalf("unexpected error: %s", err)
 		}
 
@@ -72,7 +72,7 @@ func TestProcessStreamInternalFailure(t *testing.T) {
 
 		tlp := &insertutil.TestLogMessageProcessor{}
 		r := strings.NewReader(data)
-		if err := processStreamInternal("test", r, []string{"time"}, nil, tlp); err == nil {
+		if err := processStreamInternal("test", r, []string{"time"}, nil, nil, tlp); err == nil {
 			t.Fatalf("expected error, got nil")
 		}
 
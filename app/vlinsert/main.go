@@ -10,6 +10,7 @@ import (
 
 	"github.com/VictoriaMetrics/VictoriaLogs/app/vlinsert/datadog"
 	"github.com/VictoriaMetrics/VictoriaLogs/app/vlinsert/elasticsearch"
+	"github.com/VictoriaMetrics/VictoriaLogs/app/vlinsert/insertutil"
 	"github.com/VictoriaMetrics/VictoriaLogs/app/vlinsert/internalinsert"
 	"github.com/VictoriaMetrics/VictoriaLogs/app/vlinsert/journald"
 	"github.com/VictoriaMetrics/VictoriaLogs/app/vlinsert/jsonline"
@@ -25,12 +26,16 @@ var (
 
 // Init initializes vlinsert
 func Init() {
+	insertutil.MustInitRedaction()
+	insertutil.MustInitRelabeling()
+	insertutil.MustInitGeoIP()
 	syslog.MustInit()
 }
 
 // Stop stops vlinsert
 func Stop() {
 	syslog.MustStop()
+	insertutil.StopGeoIP()
 }
 
 // RequestHandler handles insert requests for VictoriaLogs
@@ -66,21 +66,54 @@ func main() {
 	buildinfo.Init()
 	logger.Init()
 
-	var remoteWriteURL *url.URL
-	if *addr != "stdout" {
-		urlParsed, err := url.Parse(*addr)
-		if err != nil {
-			logger.Fatalf("cannot parse -addr=%q: %s", *addr, err)
-		}
-		qs, err := url.ParseQuery(urlParsed.RawQuery)
-		if err != nil {
-			logger.Fatalf("cannot parse query string in -addr=%q: %w", *addr, err)
-		}
-		qs.Set("_stream_fields", "host,worker_id")
-		urlParsed.RawQuery = qs.Encode()
-		remoteWriteURL = urlParsed
+	if *replayFile != "" && *scenarioFile != "" {
+		logger.Fatalf("-replay.file and -scenario.file cannot be set simultaneously")
+	}
+
+	startTime := time.Now()
+	go writeStats()
+
+	switch {
+	case *replayFile != "":
+		runReplay()
+	case *scenarioFile != "":
+		runScenario()
+	default:
+		runGenerator()
 	}
 
+	dSecs := time.Since(startTime).Seconds()
+	currEntries := logEntriesCount.Load()
+	currBytes := bytesGenerated.Load()
+	rateEntries := float64(currEntries) / dSecs
+	rateBytes := float64(currBytes) / dSecs
+	logger.Infof("ingested %dK log entries (%dMB) in %.3f seconds; avg ingestion rate: %.0fK entries/sec, %.0fMB/sec", currEntries/1e3, currBytes/1e6, dSecs, rateEntries/1e3, rateBytes/1e6)
+}
+
+// getRemoteWriteURL returns the url for pushing logs to -addr with the given streamFields.
+//
+// It returns nil if logs must be written to stdout.
+// streamFields are ignored if -addr already contains _stream_fields query arg.
+func getRemoteWriteURL(streamFields string) *url.URL {
+	if *addr == "stdout" {
+		return nil
+	}
+	urlParsed, err := url.Parse(*addr)
+	if err != nil {
+		logger.Fatalf("cannot parse -addr=%q: %s", *addr, err)
+	}
+	qs, err := url.ParseQuery(urlParsed.RawQuery)
+	if err != nil {
+		logger.Fatalf("cannot parse query string in -addr=%q: %s", *addr, err)
+	}
+	if !qs.Has("_stream_fields") && streamFields != "" {
+		qs.Set("_stream_fields", streamFields)
+	}
+	urlParsed.RawQuery = qs.Encode()
+	return urlParsed
+}
+
+func runGenerator() {
 	if start.nsec >= end.nsec {
 		logger.Fatalf("-start=%s must be smaller than -end=%s", start, end)
 	}
@@ -95,7 +128,7 @@ func main() {
 	}
 
 	cfg := &workerConfig{
-		url:           remoteWriteURL,
+		url:           getRemoteWriteURL("host,worker_id"),
 		activeStreams: *activeStreams,
 		totalStreams:  *totalStreams,
 	}
@@ -113,44 +146,37 @@ func main() {
 	logger.Infof("start -workers=%d workers for ingesting -logsPerStream=%d log entries per each -totalStreams=%d (-activeStreams=%d) on a time range -start=%s, -end=%s to -addr=%s",
 		*workers, *logsPerStream, *totalStreams, *activeStreams, toRFC3339(start.nsec), toRFC3339(end.nsec), *addr)
 
-	startTime := time.Now()
 	var wg sync.WaitGroup
 	for i := 0; i < *workers; i++ {
 		wg.Add(1)
 		go func(workerID int) {
 			defer wg.Done()
-			generateAndPushLogs(cfg, workerID)
+			pushLogs(cfg.url, func(bw *bufio.Writer) {
+				generateLogs(bw, workerID, cfg.activeStreams, cfg.totalStreams)
+			})
 		}(i)
 	}
-
-	go func() {
-		prevEntries := uint64(0)
-		prevBytes := uint64(0)
-		ticker := time.NewTicker(*statInterval)
-		for range ticker.C {
-			currEntries := logEntriesCount.Load()
-			deltaEntries := currEntries - prevEntries
-			rateEntries := float64(deltaEntries) / statInterval.Seconds()
-
-			currBytes := bytesGenerated.Load()
-			deltaBytes := currBytes - prevBytes
-			rateBytes := float64(deltaBytes) / statInterval.Seconds()
-			logger.Infof("generated %dK log entries (%dK total) at %.0fK entries/sec, %dMB (%dMB total) at %.0fMB/sec",
-				deltaEntries/1e3, currEntries/1e3, rateEntries/1e3, deltaBytes/1e6, currBytes/1e6, rateBytes/1e6)
-
-			prevEntries = currEntries
-			prevBytes = currBytes
-		}
-	}()
-
 	wg.Wait()
+}
 
-	dSecs := time.Since(startTime).Seconds()
-	currEntries := logEntriesCount.Load()
-	currBytes := bytesGenerated.Load()
-	rateEntries := float64(currEntries) / dSecs
-	rateBytes := float64(currBytes) / dSecs
-	logger.Infof("ingested %dK log entries (%dMB) in %.3f seconds; avg ingestion rate: %.0fK entries/sec, %.0fMB/sec", currEntries/1e3, currBytes/1e6, dSecs, rateEntries/1e3, rateBytes/1e6)
+func writeStats() {
+	prevEntries := uint64(0)
+	prevBytes := uint64(0)
+	ticker := time.NewTicker(*statInterval)
+	for range ticker.C {
+		currEntries := logEntriesCount.Load()
+		deltaEntries := currEntries - prevEntries
+		rateEntries := float64(deltaEntries) / statInterval.Seconds()
+
+		currBytes := bytesGenerated.Load()
+		deltaBytes := currBytes - prevBytes
+		rateBytes := float64(deltaBytes) / statInterval.Seconds()
+		logger.Infof("generated %dK log entries (%dK total) at %.0fK entries/sec, %dMB (%dMB total) at %.0fMB/sec",
+			deltaEntries/1e3, currEntries/1e3, rateEntries/1e3, deltaBytes/1e6, currBytes/1e6, rateBytes/1e6)
+
+		prevEntries = currEntries
+		prevBytes = currBytes
+	}
 }
 
 var logEntriesCount atomic.Uint64
@@ -172,7 +198,10 @@ func (sw *statWriter) Write(p []byte) (int, error) {
 	return sw.w.Write(p)
 }
 
-func generateAndPushLogs(cfg *workerConfig, workerID int) {
+// pushLogs pushes logs generated by generate func to u.
+//
+// Logs are written to stdout if u is nil.
+func pushLogs(u *url.URL, generate func(bw *bufio.Writer)) {
 	pr, pw := io.Pipe()
 	sw := &statWriter{
 		w: pw,
@@ -183,13 +212,13 @@ func generateAndPushLogs(cfg *workerConfig, workerID int) {
 
 	doneCh := make(chan struct{})
 	go func() {
-		generateLogs(bw, workerID, cfg.activeStreams, cfg.totalStreams)
+		generate(bw)
 		_ = bw.Flush()
 		_ = pw.Close()
 		close(doneCh)
 	}()
 
-	if cfg.url == nil {
+	if u == nil {
 		_, err := io.Copy(os.Stdout, pr)
 		if err != nil {
 			logger.Fatalf("unexpected error when writing logs to stdout: %s", err)
@@ -197,22 +226,22 @@ func generateAndPushLogs(cfg *workerConfig, workerID int) {
 		return
 	}
 
-	req, err := http.NewRequest("POST", cfg.url.String(), pr)
+	req, err := http.NewRequest("POST", u.String(), pr)
 	if err != nil {
-		logger.Fatalf("cannot create request to %q: %s", cfg.url, err)
+		logger.Fatalf("cannot create request to %q: %s", u, err)
 	}
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		logger.Fatalf("cannot perform request to %q: %s", cfg.url, err)
+		logger.Fatalf("cannot perform request to %q: %s", u, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode/100 != 2 {
-		logger.Fatalf("unexpected status code got from %q: %d; want 2xx", cfg.url, resp.StatusCode)
+		logger.Fatalf("unexpected status code got from %q: %d; want 2xx", u, resp.StatusCode)
 	}
 
-	// Wait until all the generateLogs goroutine is finished.
+	// Wait until the generate goroutine is finished.
 	<-doneCh
 }
 
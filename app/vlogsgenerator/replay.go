@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/valyala/fastjson"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/logstorage"
+)
+
+var (
+	replayFile = flag.String("replay.file", "", "Path to file with logs in JSON lines format to replay to -addr instead of generating synthetic logs. "+
+		"For example, the file can be obtained via /select/logsql/query at VictoriaLogs; see https://docs.victoriametrics.com/victorialogs/querying/#querying-logs")
+	replaySpeed = flag.Float64("replay.speed", 1, "Speed multiplier for replaying logs from -replay.file according to their original timestamps. "+
+		"For example, -replay.speed=10 replays logs 10 times faster than they were originally generated. "+
+		"-replay.speed=0 replays logs as fast as possible. See also -replay.rate")
+	replayRate = flag.Float64("replay.rate", 0, "The target number of log entries per second to replay from -replay.file. "+
+		"If it is set to a value bigger than 0, then original timestamps of the replayed logs are ignored when pacing the replay. See also -replay.speed")
+	replayShiftTimestamps = flag.Bool("replay.shiftTimestamps", true, "Whether to shift _time field of logs from -replay.file to the time of replay. "+
+		"If -replay.speed=0, then timestamps are shifted, so the first replayed log entry gets -start timestamp")
+	replayStreamFields = flag.String("replay.streamFields", "", "Comma-separated list of log stream fields for logs from -replay.file; "+
+		"see https://docs.victoriametrics.com/victorialogs/keyconcepts/#stream-fields")
+)
+
+// minReplaySleep is the minimum duration to sleep when pacing the replay.
+//
+// Smaller durations are ignored in order to reduce the number of sleeps and flushes at high replay rates.
+const minReplaySleep = 10 * time.Millisecond
+
+func runReplay() {
+	if *replaySpeed < 0 {
+		logger.Fatalf("-replay.speed cannot be negative; got %v", *replaySpeed)
+	}
+	if *replayRate < 0 {
+		logger.Fatalf("-replay.rate cannot be negative; got %v", *replayRate)
+	}
+
+	f, err := os.Open(*replayFile)
+	if err != nil {
+		logger.Fatalf("cannot open -replay.file=%q: %s", *replayFile, err)
+	}
+	defer f.Close()
+
+	logger.Infof("start replaying logs from -replay.file=%q with -replay.speed=%v, -replay.rate=%v to -addr=%s", *replayFile, *replaySpeed, *replayRate, *addr)
+
+	u := getRemoteWriteURL(*replayStreamFields)
+	pushLogs(u, func(bw *bufio.Writer) {
+		replayLogs(bw, f)
+	})
+}
+
+func replayLogs(bw *bufio.Writer, r io.Reader) {
+	br := bufio.NewReaderSize(r, 1024*1024)
+
+	rs := &replayState{
+		startNsec:   time.Now().UnixNano(),
+		firstOrigTs: -1,
+	}
+	if *replayRate <= 0 && *replaySpeed == 0 {
+		// Logs are replayed as fast as possible, so they cannot get the timestamps of the replay.
+		rs.startNsec = start.nsec
+	}
+
+	var p fastjson.Parser
+	var a fastjson.Arena
+	var buf []byte
+	lineNum := 0
+	for {
+		line, err := br.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			logger.Fatalf("cannot read -replay.file=%q: %s", *replayFile, err)
+		}
+		if len(line) > 0 {
+			lineNum++
+			buf = rs.processLine(bw, buf[:0], &p, &a, line, lineNum)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+type replayState struct {
+	// startNsec is the timestamp in nanoseconds for the first replayed log entry.
+	startNsec int64
+
+	// firstOrigTs is the original timestamp for the first replayed log entry with _time field.
+	//
+	// It is set to -1 until the first log entry with _time field is replayed.
+	firstOrigTs int64
+
+	// elapsed is the duration in nanoseconds since startNsec for the last replayed log entry.
+	elapsed int64
+
+	// entries is the number of replayed log entries.
+	entries int64
+}
+
+func (rs *replayState) processLine(bw *bufio.Writer, dst []byte, p *fastjson.Parser, a *fastjson.Arena, line []byte, lineNum int) []byte {
+	v, err := p.ParseBytes(line)
+	if err != nil {
+		logger.Warnf("skipping line #%d at -replay.file=%q, since it cannot be parsed as JSON: %s", lineNum, *replayFile, err)
+		return dst
+	}
+	o, err := v.Object()
+	if err != nil {
+		logger.Warnf("skipping line #%d at -replay.file=%q, since it doesn't contain JSON object: %s", lineNum, *replayFile, err)
+		return dst
+	}
+
+	origTs, hasOrigTs := int64(0), false
+	if tv := o.Get("_time"); tv != nil {
+		origTs, hasOrigTs = logstorage.TryParseTimestampRFC3339Nano(string(tv.GetStringBytes()))
+	}
+
+	// Calculate the time for sending the log entry relative to rs.startNsec
+	switch {
+	case *replayRate > 0:
+		rs.elapsed = int64(float64(rs.entries) / *replayRate * 1e9)
+	case hasOrigTs:
+		if rs.firstOrigTs < 0 {
+			rs.firstOrigTs = origTs
+		}
+		elapsed := origTs - rs.firstOrigTs
+		if *replaySpeed > 0 {
+			elapsed = int64(float64(elapsed) / *replaySpeed)
+		}
+		rs.elapsed = max(elapsed, 0)
+	}
+	rs.entries++
+
+	if *replayRate > 0 || *replaySpeed > 0 {
+		sendTime := time.Unix(0, rs.startNsec+rs.elapsed)
+		if d := time.Until(sendTime); d >= minReplaySleep {
+			// Flush the buffered logs before sleeping, so they are sent in a timely manner.
+			_ = bw.Flush()
+			time.Sleep(d)
+		}
+	}
+
+	// The _stream and _stream_id fields are generated by VictoriaLogs during data ingestion,
+	// so they must be dropped from the logs exported from VictoriaLogs.
+	o.Del("_stream")
+	o.Del("_stream_id")
+
+	if *replayShiftTimestamps {
+		a.Reset()
+		o.Set("_time", a.NewString(toRFC3339(rs.startNsec+rs.elapsed)))
+	}
+
+	dst = v.MarshalTo(dst)
+	dst = append(dst, '\n')
+	_, _ = bw.Write(dst)
+	logEntriesCount.Add(1)
+
+	return dst
+}
@@ -0,0 +1,527 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timeutil"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/logstorage"
+)
+
+var scenarioFile = flag.String("scenario.file", "", "Path to JSON file with the scenario for generating logs instead of synthetic logs generated according to -*FieldsPerLog flags. "+
+	"The scenario describes per-stream rates, field cardinalities, message templates and load phases such as bursts and stream churn; "+
+	"see https://github.com/VictoriaMetrics/VictoriaLogs/tree/master/app/vlogsgenerator#scenarios")
+
+// instanceFieldName is the name of the stream field, which is added to every log generated by the scenario.
+//
+// It contains the instance number for the stream described in the scenario.
+const instanceFieldName = "instance"
+
+func runScenario() {
+	data, err := os.ReadFile(*scenarioFile)
+	if err != nil {
+		logger.Fatalf("cannot read -scenario.file=%q: %s", *scenarioFile, err)
+	}
+	sc, err := parseScenario(data)
+	if err != nil {
+		logger.Fatalf("cannot parse -scenario.file=%q: %s", *scenarioFile, err)
+	}
+
+	if start.nsec >= end.nsec {
+		logger.Fatalf("-start=%s must be smaller than -end=%s", start, end)
+	}
+	if *workers <= 0 {
+		logger.Fatalf("-workers must be bigger than 0; got %d", *workers)
+	}
+
+	logger.Infof("start -workers=%d workers for generating logs from -scenario.file=%q with %d streams on a time range -start=%s, -end=%s to -addr=%s",
+		*workers, *scenarioFile, len(sc.streams), toRFC3339(start.nsec), toRFC3339(end.nsec), *addr)
+
+	u := getRemoteWriteURL(sc.getStreamFields())
+	var wg sync.WaitGroup
+	for i := 0; i < *workers; i++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			pushLogs(u, func(bw *bufio.Writer) {
+				sc.generateLogs(bw, workerID, *workers, start.nsec, end.nsec)
+			})
+		}(i)
+	}
+	wg.Wait()
+}
+
+// scenarioConfig is the config for -scenario.file.
+type scenarioConfig struct {
+	Streams []scenarioStreamConfig `json:"streams"`
+	Phases  []scenarioPhaseConfig  `json:"phases,omitempty"`
+}
+
+// scenarioStreamConfig describes logs for a group of log streams with the same shape.
+type scenarioStreamConfig struct {
+	// Name is the name of the stream group. It is used for referring the group from phases.
+	Name string `json:"name,omitempty"`
+
+	// StreamFields contains log stream fields with constant values for the group.
+	StreamFields map[string]string `json:"stream_fields,omitempty"`
+
+	// Instances is the number of log streams in the group. Streams are distinguished by the instance field.
+	Instances int `json:"instances,omitempty"`
+
+	// Rate is the number of logs per second per every stream in the group.
+	Rate float64 `json:"rate"`
+
+	// Fields contains fields to generate per every log entry.
+	Fields []scenarioFieldConfig `json:"fields,omitempty"`
+
+	// Messages contains templates for _msg field. Templates may refer fields in the form {field_name}.
+	Messages []string `json:"messages"`
+}
+
+// scenarioFieldConfig describes values for a single field.
+type scenarioFieldConfig struct {
+	Name string `json:"name"`
+
+	// Values contains values to choose randomly from.
+	Values []string `json:"values,omitempty"`
+
+	// Type is the type of the generated values: string, int, float, ip or uuid.
+	Type string `json:"type,omitempty"`
+
+	// Cardinality is the number of unique values for string and int types. Zero means unlimited cardinality.
+	Cardinality int `json:"cardinality,omitempty"`
+}
+
+// scenarioPhaseConfig describes the load phase for the selected stream groups.
+type scenarioPhaseConfig struct {
+	Name string `json:"name,omitempty"`
+
+	// Streams contains stream group names the phase applies to. The phase applies to all the groups if Streams is empty.
+	Streams []string `json:"streams,omitempty"`
+
+	// Start is the offset of the phase start relative to -start.
+	Start string `json:"start"`
+
+	// Duration is the phase duration.
+	Duration string `json:"duration"`
+
+	// RateMultiplier is the multiplier for stream rates during the phase. It is used for simulating bursts.
+	RateMultiplier float64 `json:"rate_multiplier,omitempty"`
+
+	// ChurnInterval is the interval for replacing all the streams with new streams during the phase.
+	// It is used for simulating high churn rate for log streams.
+	ChurnInterval string `json:"churn_interval,omitempty"`
+}
+
+type scenario struct {
+	streams []*scenarioStream
+	phases  []*scenarioPhase
+}
+
+type scenarioStream struct {
+	name         string
+	streamFields []logstorage.Field
+	instances    int
+	rate         float64
+	fields       []*scenarioField
+	messages     []*messageTemplate
+
+	// phases contains phases, which apply to the stream group.
+	phases []*scenarioPhase
+}
+
+type scenarioField struct {
+	name        string
+	values      []string
+	typ         string
+	cardinality int
+}
+
+type scenarioPhase struct {
+	name           string
+	startOffset    int64
+	duration       int64
+	rateMultiplier float64
+	churnInterval  int64
+}
+
+func parseScenario(data []byte) (*scenario, error) {
+	var cfg scenarioConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Streams) == 0 {
+		return nil, fmt.Errorf("missing streams")
+	}
+
+	var sc scenario
+	streamsByName := make(map[string]*scenarioStream, len(cfg.Streams))
+	for i := range cfg.Streams {
+		ss, err := newScenarioStream(&cfg.Streams[i], i)
+		if err != nil {
+			return nil, fmt.Errorf("cannot initialize stream #%d: %w", i+1, err)
+		}
+		if _, ok := streamsByName[ss.name]; ok {
+			return nil, fmt.Errorf("duplicate stream name %q", ss.name)
+		}
+		streamsByName[ss.name] = ss
+		sc.streams = append(sc.streams, ss)
+	}
+
+	for i := range cfg.Phases {
+		pc := &cfg.Phases[i]
+		sp, err := newScenarioPhase(pc, i)
+		if err != nil {
+			return nil, fmt.Errorf("cannot initialize phase #%d: %w", i+1, err)
+		}
+		sc.phases = append(sc.phases, sp)
+
+		if len(pc.Streams) == 0 {
+			for _, ss := range sc.streams {
+				ss.phases = append(ss.phases, sp)
+			}
+			continue
+		}
+		for _, name := range pc.Streams {
+			ss, ok := streamsByName[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown stream %q at phase %q", name, sp.name)
+			}
+			ss.phases = append(ss.phases, sp)
+		}
+	}
+
+	return &sc, nil
+}
+
+func newScenarioStream(cfg *scenarioStreamConfig, idx int) (*scenarioStream, error) {
+	ss := &scenarioStream{
+		name:      cfg.Name,
+		instances: cfg.Instances,
+		rate:      cfg.Rate,
+	}
+	if ss.name == "" {
+		ss.name = fmt.Sprintf("stream_%d", idx)
+	}
+	if ss.instances < 0 {
+		return nil, fmt.Errorf("instances cannot be negative; got %d", ss.instances)
+	}
+	if ss.instances == 0 {
+		ss.instances = 1
+	}
+	if ss.rate <= 0 || math.IsInf(ss.rate, 0) {
+		return nil, fmt.Errorf("rate must be bigger than 0; got %v", ss.rate)
+	}
+
+	// Sort stream fields in order to generate logs with stable field order.
+	for name, value := range cfg.StreamFields {
+		if name == "" || name == instanceFieldName || strings.HasPrefix(name, "_") {
+			return nil, fmt.Errorf("invalid stream field name %q", name)
+		}
+		ss.streamFields = append(ss.streamFields, logstorage.Field{
+			Name:  name,
+			Value: value,
+		})
+	}
+	sort.Slice(ss.streamFields, func(i, j int) bool {
+		return ss.streamFields[i].Name < ss.streamFields[j].Name
+	})
+
+	fieldNames := map[string]struct{}{
+		instanceFieldName: {},
+	}
+	for _, f := range ss.streamFields {
+		fieldNames[f.Name] = struct{}{}
+	}
+	for i := range cfg.Fields {
+		sf, err := newScenarioField(&cfg.Fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("cannot initialize field #%d: %w", i+1, err)
+		}
+		if _, ok := fieldNames[sf.name]; ok {
+			return nil, fmt.Errorf("duplicate field name %q", sf.name)
+		}
+		fieldNames[sf.name] = struct{}{}
+		ss.fields = append(ss.fields, sf)
+	}
+
+	if len(cfg.Messages) == 0 {
+		return nil, fmt.Errorf("missing messages")
+	}
+	for _, s := range cfg.Messages {
+		mt, err := newMessageTemplate(s, fieldNames)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse message template %q: %w", s, err)
+		}
+		ss.messages = append(ss.messages, mt)
+	}
+
+	return ss, nil
+}
+
+func newScenarioField(cfg *scenarioFieldConfig) (*scenarioField, error) {
+	if cfg.Name == "" || strings.HasPrefix(cfg.Name, "_") {
+		return nil, fmt.Errorf("invalid field name %q", cfg.Name)
+	}
+	sf := &scenarioField{
+		name:        cfg.Name,
+		values:      cfg.Values,
+		typ:         cfg.Type,
+		cardinality: cfg.Cardinality,
+	}
+	if sf.cardinality < 0 {
+		return nil, fmt.Errorf("cardinality cannot be negative; got %d", sf.cardinality)
+	}
+	if len(sf.values) > 0 {
+		if sf.typ != "" || sf.cardinality > 0 {
+			return nil, fmt.Errorf("values cannot be mixed with type and cardinality")
+		}
+		return sf, nil
+	}
+	switch sf.typ {
+	case "":
+		sf.typ = "string"
+	case "string", "int":
+	case "float", "ip", "uuid":
+		if sf.cardinality > 0 {
+			return nil, fmt.Errorf("cardinality isn't supported for type %q", sf.typ)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported type %q; supported types: string, int, float, ip, uuid", sf.typ)
+	}
+	return sf, nil
+}
+
+func newScenarioPhase(cfg *scenarioPhaseConfig, idx int) (*scenarioPhase, error) {
+	sp := &scenarioPhase{
+		name:           cfg.Name,
+		rateMultiplier: cfg.RateMultiplier,
+	}
+	if sp.name == "" {
+		sp.name = fmt.Sprintf("phase_%d", idx)
+	}
+
+	startOffset, err := timeutil.ParseDuration(cfg.Start)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse start: %w", err)
+	}
+	sp.startOffset = startOffset.Nanoseconds()
+
+	duration, err := timeutil.ParseDuration(cfg.Duration)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse duration: %w", err)
+	}
+	if duration <= 0 {
+		return nil, fmt.Errorf("duration must be bigger than 0; got %s", cfg.Duration)
+	}
+	sp.duration = duration.Nanoseconds()
+
+	if sp.rateMultiplier < 0 {
+		return nil, fmt.Errorf("rate_multiplier cannot be negative; got %v", sp.rateMultiplier)
+	}
+	if sp.rateMultiplier == 0 {
+		sp.rateMultiplier = 1
+	}
+
+	if cfg.ChurnInterval != "" {
+		churnInterval, err := timeutil.ParseDuration(cfg.ChurnInterval)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse churn_interval: %w", err)
+		}
+		if churnInterval <= 0 {
+			return nil, fmt.Errorf("churn_interval must be bigger than 0; got %s", cfg.ChurnInterval)
+		}
+		sp.churnInterval = churnInterval.Nanoseconds()
+	}
+
+	return sp, nil
+}
+
+// getStreamFields returns comma-separated list of stream fields for logs generated by sc.
+func (sc *scenario) getStreamFields() string {
+	m := map[string]struct{}{
+		instanceFieldName: {},
+	}
+	for _, ss := range sc.streams {
+		for _, f := range ss.streamFields {
+			m[f.Name] = struct{}{}
+		}
+	}
+	a := make([]string, 0, len(m))
+	for name := range m {
+		a = append(a, name)
+	}
+	sort.Strings(a)
+	return strings.Join(a, ",")
+}
+
+// generateLogs generates logs for the stream instances assigned to the given workerID on the [startNsec ... endNsec) time range.
+func (sc *scenario) generateLogs(bw *bufio.Writer, workerID, workersCount int, startNsec, endNsec int64) {
+	var fields []logstorage.Field
+	var buf []byte
+	for ts := startNsec; ts < endNsec; ts += 1e9 {
+		for _, ss := range sc.streams {
+			rateMultiplier, churnID := ss.getPhaseState(ts - startNsec)
+			rate := ss.rate * rateMultiplier
+			for instance := workerID; instance < ss.instances; instance += workersCount {
+				// Generate the number of logs for the current second, so its average equals to rate.
+				n := int(rate)
+				if rand.Float64() < rate-float64(n) {
+					n++
+				}
+				instanceStr := strconv.Itoa(instance)
+				if churnID != "" {
+					instanceStr += "_" + churnID
+				}
+				for i := 0; i < n; i++ {
+					timestamp := ts + int64(i)*1e9/int64(n)
+					if timestamp >= endNsec {
+						break
+					}
+					fields = ss.generateFields(fields[:0], instanceStr)
+					buf = marshalScenarioLog(buf[:0], timestamp, ss, fields)
+					_, _ = bw.Write(buf)
+					logEntriesCount.Add(1)
+				}
+			}
+		}
+	}
+}
+
+// getPhaseState returns rate multiplier and churn id for ss at the given offset in nanoseconds relative to -start.
+//
+// The churn id is empty if streams are not churned at the given offset.
+func (ss *scenarioStream) getPhaseState(offset int64) (float64, string) {
+	rateMultiplier := float64(1)
+	churnID := ""
+	for _, sp := range ss.phases {
+		if offset < sp.startOffset || offset >= sp.startOffset+sp.duration {
+			continue
+		}
+		rateMultiplier *= sp.rateMultiplier
+		if sp.churnInterval > 0 {
+			generation := (offset - sp.startOffset) / sp.churnInterval
+			churnID = fmt.Sprintf("%s_%d", sp.name, generation)
+		}
+	}
+	return rateMultiplier, churnID
+}
+
+// generateFields appends generated fields for the given stream instance to dst and returns the result.
+//
+// The first two fields are reserved for _time and _msg fields, which are set by marshalScenarioLog.
+func (ss *scenarioStream) generateFields(dst []logstorage.Field, instance string) []logstorage.Field {
+	dst = append(dst, logstorage.Field{}, logstorage.Field{})
+	dst = append(dst, ss.streamFields...)
+	dst = append(dst, logstorage.Field{
+		Name:  instanceFieldName,
+		Value: instance,
+	})
+	for _, sf := range ss.fields {
+		dst = append(dst, logstorage.Field{
+			Name:  sf.name,
+			Value: sf.generateValue(),
+		})
+	}
+	return dst
+}
+
+func (sf *scenarioField) generateValue() string {
+	if len(sf.values) > 0 {
+		return sf.values[rand.Intn(len(sf.values))]
+	}
+	switch sf.typ {
+	case "int":
+		if sf.cardinality > 0 {
+			return strconv.Itoa(rand.Intn(sf.cardinality))
+		}
+		return strconv.FormatInt(int64(rand.Uint64()), 10)
+	case "float":
+		return strconv.FormatFloat(math.Round(100_000*rand.Float64())/100, 'f', -1, 64)
+	case "ip":
+		return toIPv4(rand.Uint32())
+	case "uuid":
+		return toUUID(rand.Uint64(), rand.Uint64())
+	default:
+		if sf.cardinality > 0 {
+			return sf.name + "_" + strconv.Itoa(rand.Intn(sf.cardinality))
+		}
+		return sf.name + "_" + strconv.FormatUint(rand.Uint64(), 10)
+	}
+}
+
+// marshalScenarioLog appends JSON line for the log entry with the given timestamp and fields to dst and returns the result.
+//
+// The first two fields must be reserved for _time and _msg fields.
+func marshalScenarioLog(dst []byte, timestamp int64, ss *scenarioStream, fields []logstorage.Field) []byte {
+	mt := ss.messages[rand.Intn(len(ss.messages))]
+	fields[0] = logstorage.Field{
+		Name:  "_time",
+		Value: toRFC3339(timestamp),
+	}
+	fields[1] = logstorage.Field{
+		Name:  "_msg",
+		Value: string(mt.execute(nil, fields[2:])),
+	}
+	dst = logstorage.MarshalFieldsToJSON(dst, fields)
+	dst = append(dst, '\n')
+	return dst
+}
+
+// messageTemplate is a template for _msg field with {field_name} placeholders.
+type messageTemplate struct {
+	// parts contains template parts. Odd parts contain field names for the placeholders.
+	parts []string
+}
+
+func newMessageTemplate(s string, fieldNames map[string]struct{}) (*messageTemplate, error) {
+	var parts []string
+	for {
+		n := strings.IndexByte(s, '{')
+		if n < 0 {
+			parts = append(parts, s)
+			return &messageTemplate{
+				parts: parts,
+			}, nil
+		}
+		parts = append(parts, s[:n])
+		s = s[n+1:]
+		n = strings.IndexByte(s, '}')
+		if n < 0 {
+			return nil, fmt.Errorf("missing '}' for the placeholder")
+		}
+		name := s[:n]
+		if _, ok := fieldNames[name]; !ok {
+			return nil, fmt.Errorf("unknown field %q in the placeholder", name)
+		}
+		parts = append(parts, name)
+		s = s[n+1:]
+	}
+}
+
+func (mt *messageTemplate) execute(dst []byte, fields []logstorage.Field) []byte {
+	for i, part := range mt.parts {
+		if i%2 == 0 {
+			dst = append(dst, part...)
+			continue
+		}
+		for _, f := range fields {
+			if f.Name == part {
+				dst = append(dst, f.Value...)
+				break
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseScenario_Failure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+
+		if _, err := parseScenario([]byte(data)); err == nil {
+			t.Fatalf("expecting non-nil error for %s", data)
+		}
+	}
+
+	// invalid JSON
+	f(`foo`)
+	f(`{"streams":{}}`)
+
+	// missing streams
+	f(`{}`)
+
+	// invalid rate
+	f(`{"streams":[{"messages":["foo"]}]}`)
+	f(`{"streams":[{"rate":-1,"messages":["foo"]}]}`)
+
+	// missing messages
+	f(`{"streams":[{"rate":1}]}`)
+
+	// duplicate stream name
+	f(`{"streams":[{"name":"a","rate":1,"messages":["foo"]},{"name":"a","rate":1,"messages":["bar"]}]}`)
+
+	// invalid stream fields
+	f(`{"streams":[{"rate":1,"stream_fields":{"instance":"x"},"messages":["foo"]}]}`)
+	f(`{"streams":[{"rate":1,"stream_fields":{"_msg":"x"},"messages":["foo"]}]}`)
+
+	// invalid fields
+	f(`{"streams":[{"rate":1,"fields":[{"name":""}],"messages":["foo"]}]}`)
+	f(`{"streams":[{"rate":1,"fields":[{"name":"a","type":"bar"}],"messages":["foo"]}]}`)
+	f(`{"streams":[{"rate":1,"fields":[{"name":"a","type":"ip","cardinality":10}],"messages":["foo"]}]}`)
+	f(`{"streams":[{"rate":1,"fields":[{"name":"a","values":["x"],"type":"int"}],"messages":["foo"]}]}`)
+	f(`{"streams":[{"rate":1,"fields":[{"name":"a"},{"name":"a"}],"messages":["foo"]}]}`)
+
+	// invalid message templates
+	f(`{"streams":[{"rate":1,"messages":["foo {bar}"]}]}`)
+	f(`{"streams":[{"rate":1,"fields":[{"name":"bar"}],"messages":["foo {bar"]}]}`)
+
+	// invalid phases
+	f(`{"streams":[{"rate":1,"messages":["foo"]}],"phases":[{"start":"foo","duration":"1m"}]}`)
+	f(`{"streams":[{"rate":1,"messages":["foo"]}],"phases":[{"start":"1m","duration":"0s"}]}`)
+	f(`{"streams":[{"rate":1,"messages":["foo"]}],"phases":[{"start":"1m","duration":"1m","churn_interval":"bar"}]}`)
+	f(`{"streams":[{"rate":1,"messages":["foo"]}],"phases":[{"start":"1m","duration":"1m","streams":["missing"]}]}`)
+}
+
+func TestScenarioGenerateLogs(t *testing.T) {
+	data := `{
+		"streams": [
+			{
+				"name": "app",
+				"stream_fields": {"app": "foo"},
+				"instances": 2,
+				"rate": 2,
+				"fields": [{"name": "status", "values": ["200"]}],
+				"messages": ["{app} returned {status} at {instance}"]
+			}
+		],
+		"phases": [
+			{"start": "1s", "duration": "1s", "rate_multiplier": 0.5, "churn_interval": "1s"}
+		]
+	}`
+	sc, err := parseScenario([]byte(data))
+	if err != nil {
+		t.Fatalf("cannot parse scenario: %s", err)
+	}
+	if s := sc.getStreamFields(); s != "app,instance" {
+		t.Fatalf("unexpected stream fields; got %q; want %q", s, "app,instance")
+	}
+
+	ss := sc.streams[0]
+	f := func(offset int64, rateMultiplierExpected float64, churnIDExpected string) {
+		t.Helper()
+
+		rateMultiplier, churnID := ss.getPhaseState(offset)
+		if rateMultiplier != rateMultiplierExpected {
+			t.Fatalf("unexpected rate multiplier at offset %d; got %v; want %v", offset, rateMultiplier, rateMultiplierExpected)
+		}
+		if churnID != churnIDExpected {
+			t.Fatalf("unexpected churn id at offset %d; got %q; want %q", offset, churnID, churnIDExpected)
+		}
+	}
+	f(0, 1, "")
+	f(1e9, 0.5, "phase_0_0")
+	f(2e9, 1, "")
+
+	fields := ss.generateFields(nil, "1")
+	result := string(marshalScenarioLog(nil, 0, ss, fields))
+	resultExpected := `{"_time":"1970-01-01T00:00:00Z","_msg":"foo returned 200 at 1","app":"foo","instance":"1","status":"200"}` + "\n"
+	if result != resultExpected {
+		t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/buildinfo"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/envflag"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/backup"
+)
+
+var (
+	src = flag.String("src", "", "Where to restore the backup from. Supported values: fs:///path/to/local/dir or s3://bucket/path/to/dir . "+
+		"It must match the -dst passed to vlbackup")
+	backupName  = flag.String("backupName", "", "The name of the backup to restore from -src. The latest backup at -src is restored by default")
+	listBackups = flag.Bool("listBackups", false, "Whether to print the names of backups available at -src and exit")
+
+	storageDataPath = flag.String("storageDataPath", "victoria-logs-data", "Path to VictoriaLogs data to restore the backup to. "+
+		"VictoriaLogs must be stopped during the restore. Partitions missing in the backup are removed from -storageDataPath. "+
+		"Parts already present at -storageDataPath with matching checksums aren't downloaded again")
+
+	concurrency = flag.Int("concurrency", 10, "The number of parts to download in parallel")
+
+	s3Endpoint       = flag.String("s3.endpoint", "", "Custom S3-compatible endpoint for s3:// -src such as http://minio:9000 . By default the AWS S3 endpoint for -s3.region is used")
+	s3Region         = flag.String("s3.region", "us-east-1", "S3 region for s3:// -src")
+	s3ForcePathStyle = flag.Bool("s3.forcePathStyle", true, "Whether to use path-style addressing (http://endpoint/bucket/key) instead of "+
+		"virtual-hosted-style addressing (http://bucket.endpoint/key) for s3:// -src")
+)
+
+func main() {
+	// Write flags and help message to stdout, since it is easier to grep or pipe.
+	flag.CommandLine.SetOutput(os.Stdout)
+	envflag.Parse()
+	buildinfo.Init()
+	logger.Init()
+
+	if *src == "" {
+		logger.Fatalf("missing -src command-line flag")
+	}
+	rfs, err := backup.NewRemoteFS(*src, getS3Config())
+	if err != nil {
+		logger.Fatalf("cannot initialize -src=%q: %s", *src, err)
+	}
+
+	if *listBackups {
+		names, err := backup.ListBackups(rfs)
+		if err != nil {
+			logger.Fatalf("cannot list backups at %s: %s", rfs, err)
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return
+	}
+
+	startTime := time.Now()
+	stats, err := backup.Restore(rfs, *backupName, *storageDataPath, *concurrency)
+	if err != nil {
+		logger.Fatalf("cannot restore backup from %s to -storageDataPath=%q: %s", rfs, *storageDataPath, err)
+	}
+	logger.Infof("restored -storageDataPath=%q from %s in %.3f seconds; downloaded parts: %d, downloaded bytes: %d, reused local parts with matching checksums: %d",
+		*storageDataPath, rfs, time.Since(startTime).Seconds(), stats.PartsCopied, stats.BytesCopied, stats.PartsSkipped)
+}
+
+func getS3Config() *backup.S3Config {
+	return &backup.S3Config{
+		Endpoint:        *s3Endpoint,
+		Region:          *s3Region,
+		ForcePathStyle:  *s3ForcePathStyle,
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
@@ -0,0 +1,603 @@
+package elasticsearch
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timeutil"
+	"github.com/valyala/fastjson"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/logstorage"
+)
+
+// aggregation is a parsed Elasticsearch aggregation.
+//
+// Bucket aggregations (terms and date_histogram) are converted into `stats by (...)` pipes,
+// while metric aggregations are converted into stats functions.
+//
+// See https://www.elastic.co/guide/en/elasticsearch/reference/current/search-aggregations.html
+type aggregation struct {
+	name string
+	kind string
+
+	// field is the field the aggregation is applied to.
+	field string
+
+	// size is the maximum number of buckets to return for terms aggregation.
+	size int
+
+	// minDocCount is the minimum number of hits per bucket to return.
+	minDocCount uint64
+
+	// orderByKey is set to true if buckets must be ordered by key instead of doc_count.
+	orderByKey bool
+
+	// orderAsc is set to true if buckets must be ordered in ascending order.
+	orderAsc bool
+
+	// bucketSize is the LogsQL bucket size for date_histogram aggregation, e.g. `1h` or `month`.
+	bucketSize string
+
+	// bucketSizeNsecs is the bucket size in nanoseconds for date_histogram aggregation.
+	// It is set to zero for calendar intervals with variable duration such as month and year.
+	bucketSizeNsecs int64
+
+	// bucketOffset is an optional LogsQL bucket offset for date_histogram aggregation.
+	bucketOffset string
+
+	// metrics contains metric sub-aggregations.
+	metrics []*aggregation
+
+	// child is an optional nested bucket aggregation.
+	child *aggregation
+}
+
+func (agg *aggregation) isBucket() bool {
+	return agg.kind == "terms" || agg.kind == "date_histogram"
+}
+
+// byField returns LogsQL representation of the agg field for `stats by (...)`.
+func (agg *aggregation) byField() string {
+	if agg.kind == "date_histogram" {
+		s := "_time:" + agg.bucketSize
+		if agg.bucketOffset != "" {
+			s += " offset " + agg.bucketOffset
+		}
+		return s
+	}
+	return quoteFieldName(agg.field)
+}
+
+// columnName returns the name of the column with agg results.
+func (agg *aggregation) columnName() string {
+	if isTimeField(agg.field) {
+		return "_time"
+	}
+	if agg.field == *msgField {
+		return "_msg"
+	}
+	return agg.field
+}
+
+// statsFunc returns LogsQL stats function for the metric agg.
+func (agg *aggregation) statsFunc() string {
+	field := quoteFieldName(agg.field)
+	switch agg.kind {
+	case "avg", "sum", "min", "max":
+		return agg.kind + "(" + field + ")"
+	case "cardinality":
+		return "count_uniq(" + field + ")"
+	case "value_count":
+		return "count(" + field + ")"
+	default:
+		logger.Panicf("BUG: unexpected metric aggregation %q", agg.kind)
+		return ""
+	}
+}
+
+func parseAggregations(v *fastjson.Value) ([]*aggregation, error) {
+	o, err := v.Object()
+	if err != nil {
+		return nil, fmt.Errorf("aggregations must be a JSON object; got %s", v)
+	}
+
+	var aggs []*aggregation
+	var errOuter error
+	o.Visit(func(k []byte, v *fastjson.Value) {
+		if errOuter != nil {
+			return
+		}
+		agg, err := parseAggregation(string(k), v)
+		if err != nil {
+			errOuter = fmt.Errorf("cannot parse aggregation %q: %w", k, err)
+			return
+		}
+		aggs = append(aggs, agg)
+	})
+	if errOuter != nil {
+		return nil, errOuter
+	}
+
+	// Return aggregations in a stable order, since the order of keys in JSON object may be arbitrary.
+	sort.Slice(aggs, func(i, j int) bool {
+		return aggs[i].name < aggs[j].name
+	})
+	return aggs, nil
+}
+
+func parseAggregation(name string, v *fastjson.Value) (*aggregation, error) {
+	o, err := v.Object()
+	if err != nil {
+		return nil, fmt.Errorf("aggregation must be a JSON object; got %s", v)
+	}
+
+	agg := &aggregation{
+		name: name,
+	}
+	var subAggs []*aggregation
+	var errOuter error
+	o.Visit(func(k []byte, v *fastjson.Value) {
+		if errOuter != nil {
+			return
+		}
+		switch string(k) {
+		case "aggs", "aggregations":
+			aggs, err := parseAggregations(v)
+			if err != nil {
+				errOuter = err
+				return
+			}
+			subAggs = append(subAggs, aggs...)
+		case "meta":
+			// Ignore metadata
+		default:
+			if agg.kind != "" {
+				errOuter = fmt.Errorf("aggregation must contain a single type; got %q and %q", agg.kind, k)
+				return
+			}
+			agg.kind = string(k)
+			errOuter = agg.parseParams(v)
+		}
+	})
+	if errOuter != nil {
+		return nil, errOuter
+	}
+	if agg.kind == "" {
+		return nil, fmt.Errorf("missing aggregation type")
+	}
+
+	if !agg.isBucket() {
+		if len(subAggs) > 0 {
+			return nil, fmt.Errorf("metric aggregation %q cannot contain sub-aggregations", agg.kind)
+		}
+		return agg, nil
+	}
+
+	for _, subAgg := range subAggs {
+		if !subAgg.isBucket() {
+			agg.metrics = append(agg.metrics, subAgg)
+			continue
+		}
+		if agg.child != nil {
+			return nil, fmt.Errorf("only a single nested bucket aggregation is supported; got %q and %q", agg.child.name, subAgg.name)
+		}
+		agg.child = subAgg
+	}
+	return agg, nil
+}
+
+func (agg *aggregation) parseParams(v *fastjson.Value) error {
+	agg.field = string(v.GetStringBytes("field"))
+	if agg.field == "" {
+		return fmt.Errorf("missing field in %s aggregation", agg.kind)
+	}
+
+	switch agg.kind {
+	case "avg", "sum", "min", "max", "cardinality", "value_count":
+		return nil
+	case "terms":
+		return agg.parseTermsParams(v)
+	case "date_histogram":
+		return agg.parseDateHistogramParams(v)
+	default:
+		return fmt.Errorf("unsupported aggregation type %q; supported types: terms, date_histogram, avg, sum, min, max, cardinality, value_count", agg.kind)
+	}
+}
+
+func (agg *aggregation) parseTermsParams(v *fastjson.Value) error {
+	agg.size = 10
+	if sv := v.Get("size"); sv != nil {
+		n, err := getInt(sv)
+		if err != nil {
+			return fmt.Errorf("cannot parse size: %w", err)
+		}
+		agg.size = n
+	}
+
+	agg.minDocCount = 1
+	if mv := v.Get("min_doc_count"); mv != nil {
+		n, err := getInt(mv)
+		if err != nil {
+			return fmt.Errorf("cannot parse min_doc_count: %w", err)
+		}
+		agg.minDocCount = uint64(max(n, 0))
+	}
+
+	if ov := v.Get("order"); ov != nil {
+		if ov.Type() == fastjson.TypeArray {
+			a := ov.GetArray()
+			if len(a) != 1 {
+				return fmt.Errorf("only a single order is supported; got %s", ov)
+			}
+			ov = a[0]
+		}
+		key, direction, err := getSingleField(ov)
+		if err != nil {
+			return fmt.Errorf("cannot parse order: %w", err)
+		}
+		switch key {
+		case "_count":
+		case "_key", "_term":
+			agg.orderByKey = true
+		default:
+			return fmt.Errorf("unsupported order key %q; supported keys: _count, _key", key)
+		}
+		switch string(direction.GetStringBytes()) {
+		case "asc":
+			agg.orderAsc = true
+		case "desc":
+		default:
+			return fmt.Errorf("unsupported order direction %s; supported values: asc, desc", direction)
+		}
+	}
+	return nil
+}
+
+func (agg *aggregation) parseDateHistogramParams(v *fastjson.Value) error {
+	if !isTimeField(agg.field) {
+		return fmt.Errorf("date_histogram is supported only for %q and _time fields; got %q", *timeField, agg.field)
+	}
+
+	interval := ""
+	for _, k := range []string{"fixed_interval", "calendar_interval", "interval"} {
+		if s := v.GetStringBytes(k); len(s) > 0 {
+			interval = string(s)
+			break
+		}
+	}
+	if interval == "" {
+		return fmt.Errorf("missing fixed_interval or calendar_interval")
+	}
+	bucketSize, bucketSizeNsecs, err := convertInterval(interval)
+	if err != nil {
+		return err
+	}
+	agg.bucketSize = bucketSize
+	agg.bucketSizeNsecs = bucketSizeNsecs
+
+	if offset := string(v.GetStringBytes("offset")); offset != "" {
+		offset = strings.TrimPrefix(offset, "+")
+		if _, err := timeutil.ParseDuration(offset); err != nil {
+			return fmt.Errorf("cannot parse offset %q: %w", offset, err)
+		}
+		agg.bucketOffset = offset
+	}
+
+	if mv := v.Get("min_doc_count"); mv != nil {
+		n, err := getInt(mv)
+		if err != nil {
+			return fmt.Errorf("cannot parse min_doc_count: %w", err)
+		}
+		agg.minDocCount = uint64(max(n, 0))
+	}
+	agg.orderAsc = true
+	agg.orderByKey = true
+	return nil
+}
+
+// convertInterval converts Elasticsearch date_histogram interval to LogsQL bucket size.
+func convertInterval(interval string) (string, int64, error) {
+	switch interval {
+	case "minute", "1m":
+		return "1m", int64(time.Minute), nil
+	case "hour", "1h":
+		return "1h", int64(time.Hour), nil
+	case "day", "1d":
+		return "1d", 24 * int64(time.Hour), nil
+	case "week", "1w":
+		return "week", 0, nil
+	case "month", "1M":
+		return "month", 0, nil
+	case "year", "1y":
+		return "year", 0, nil
+	}
+	d, err := timeutil.ParseDuration(interval)
+	if err != nil || d <= 0 {
+		return "", 0, fmt.Errorf("unsupported interval %q", interval)
+	}
+	return interval, int64(d), nil
+}
+
+// aggRow is a single row returned from `stats by (...)` query for aggregations.
+type aggRow struct {
+	keys     []string
+	docCount uint64
+	metrics  []string
+}
+
+// runAggregationFunc must execute the given LogsQL query and return the resulting rows.
+type runAggregationFunc func(qStr string) ([][]logstorage.Field, error)
+
+// executeAggregations executes aggs for the given LogsQL filter and returns the results in Elasticsearch format.
+func executeAggregations(aggs []*aggregation, filter string, runQuery runAggregationFunc) (map[string]any, error) {
+	result := make(map[string]any, len(aggs))
+
+	// Calculate top-level metric aggregations in a single query.
+	var metrics []*aggregation
+	for _, agg := range aggs {
+		if !agg.isBucket() {
+			metrics = append(metrics, agg)
+		}
+	}
+	if len(metrics) > 0 {
+		rows, err := runAggregationQuery(filter, nil, metrics, runQuery)
+		if err != nil {
+			return nil, err
+		}
+		var r *aggRow
+		if len(rows) > 0 {
+			r = &rows[0]
+		}
+		addMetricResults(result, metrics, r)
+	}
+
+	// Calculate bucket aggregations. Every nesting level requires a separate query.
+	for _, agg := range aggs {
+		if !agg.isBucket() {
+			continue
+		}
+		var levels []*aggregation
+		for a := agg; a != nil; a = a.child {
+			levels = append(levels, a)
+		}
+		levelRows := make([]map[string][]aggRow, len(levels))
+		for i := range levels {
+			rows, err := runAggregationQuery(filter, levels[:i+1], levels[i].metrics, runQuery)
+			if err != nil {
+				return nil, err
+			}
+			m := make(map[string][]aggRow)
+			for _, r := range rows {
+				parentKey := strings.Join(r.keys[:i], "\x00")
+				m[parentKey] = append(m[parentKey], r)
+			}
+			levelRows[i] = m
+		}
+		result[agg.name] = buildBuckets(levels, levelRows, nil)
+	}
+
+	return result, nil
+}
+
+// Result column names for the generated stats query.
+//
+// They are put into a dedicated namespace, so they do not clash with by(...) fields such as "doc_count" or "m0".
+const (
+	docCountColumnName     = "__es_doc_count"
+	metricColumnNamePrefix = "__es_metric_"
+)
+
+func runAggregationQuery(filter string, bucketAggs, metrics []*aggregation, runQuery runAggregationFunc) ([]aggRow, error) {
+	byFields := make([]string, len(bucketAggs))
+	for i, agg := range bucketAggs {
+		byFields[i] = agg.byField()
+	}
+	funcs := []string{"count() " + docCountColumnName}
+	metricColumns := make(map[string]int, len(metrics))
+	for i, agg := range metrics {
+		columnName := fmt.Sprintf("%s%d", metricColumnNamePrefix, i)
+		metricColumns[columnName] = i
+		funcs = append(funcs, agg.statsFunc()+" "+columnName)
+	}
+
+	qStr := filter + " | stats "
+	if len(byFields) > 0 {
+		qStr += "by (" + strings.Join(byFields, ", ") + ") "
+	}
+	qStr += strings.Join(funcs, ", ")
+
+	resultRows, err := runQuery(qStr)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]aggRow, 0, len(resultRows))
+	for _, fields := range resultRows {
+		r := aggRow{
+			keys:    make([]string, len(bucketAggs)),
+			metrics: make([]string, len(metrics)),
+		}
+		for _, f := range fields {
+			if f.Name == docCountColumnName {
+				n, err := strconv.ParseUint(f.Value, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("cannot parse doc_count=%q: %w", f.Value, err)
+				}
+				r.docCount = n
+				continue
+			}
+			if n, ok := metricColumns[f.Name]; ok {
+				r.metrics[n] = f.Value
+				continue
+			}
+			for i, agg := range bucketAggs {
+				if f.Name == agg.columnName() {
+					r.keys[i] = f.Value
+				}
+			}
+		}
+		rows = append(rows, r)
+	}
+	return rows, nil
+}
+
+func buildBuckets(levels []*aggregation, levelRows []map[string][]aggRow, parentKeys []string) map[string]any {
+	agg := levels[0]
+	depth := len(parentKeys)
+	rows := levelRows[depth][strings.Join(parentKeys, "\x00")]
+
+	if agg.kind == "terms" {
+		// Elasticsearch doesn't return buckets for missing field values.
+		rows = slices.DeleteFunc(slices.Clone(rows), func(r aggRow) bool {
+			return r.keys[depth] == ""
+		})
+	}
+
+	var docCountTotal uint64
+	for _, r := range rows {
+		docCountTotal += r.docCount
+	}
+
+	if agg.kind == "date_histogram" {
+		rows = fillDateHistogramGaps(agg, rows, depth)
+	}
+	rows = slices.DeleteFunc(rows, func(r aggRow) bool {
+		return r.docCount < agg.minDocCount
+	})
+	sortAggRows(agg, rows, depth)
+
+	if agg.kind == "terms" && agg.size > 0 && len(rows) > agg.size {
+		rows = rows[:agg.size]
+	}
+
+	var docCountReturned uint64
+	buckets := make([]map[string]any, 0, len(rows))
+	for _, r := range rows {
+		docCountReturned += r.docCount
+		b := map[string]any{
+			"doc_count": r.docCount,
+		}
+		key := r.keys[depth]
+		if agg.kind == "date_histogram" {
+			nsecs, _ := logstorage.TryParseTimestampRFC3339Nano(key)
+			b["key"] = nsecs / 1e6
+			b["key_as_string"] = time.Unix(0, nsecs).UTC().Format("2006-01-02T15:04:05.000Z")
+		} else {
+			b["key"] = key
+		}
+		addMetricResults(b, agg.metrics, &r)
+		if agg.child != nil {
+			b[agg.child.name] = buildBuckets(levels[1:], levelRows, r.keys[:depth+1])
+		}
+		buckets = append(buckets, b)
+	}
+
+	result := map[string]any{
+		"buckets": buckets,
+	}
+	if agg.kind == "terms" {
+		result["doc_count_error_upper_bound"] = 0
+		result["sum_other_doc_count"] = docCountTotal - docCountReturned
+	}
+	return result
+}
+
+func sortAggRows(agg *aggregation, rows []aggRow, depth int) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := &rows[i], &rows[j]
+		if !agg.orderByKey && a.docCount != b.docCount {
+			if agg.orderAsc {
+				return a.docCount < b.docCount
+			}
+			return a.docCount > b.docCount
+		}
+		ka, kb := a.keys[depth], b.keys[depth]
+		if agg.orderAsc || !agg.orderByKey {
+			return lessKeys(ka, kb)
+		}
+		return lessKeys(kb, ka)
+	})
+}
+
+func lessKeys(a, b string) bool {
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		return fa < fb
+	}
+	return a < b
+}
+
+// fillDateHistogramGaps adds empty buckets for missing time buckets between the first and the last bucket in rows,
+// since Elasticsearch returns empty buckets for date_histogram when min_doc_count is 0.
+func fillDateHistogramGaps(agg *aggregation, rows []aggRow, depth int) []aggRow {
+	if agg.minDocCount > 0 || agg.bucketSizeNsecs <= 0 || len(rows) == 0 {
+		return rows
+	}
+
+	seen := make(map[int64]struct{}, len(rows))
+	minTs := int64(math.MaxInt64)
+	maxTs := int64(math.MinInt64)
+	for _, r := range rows {
+		nsecs, ok := logstorage.TryParseTimestampRFC3339Nano(r.keys[depth])
+		if !ok {
+			return rows
+		}
+		seen[nsecs] = struct{}{}
+		minTs = min(minTs, nsecs)
+		maxTs = max(maxTs, nsecs)
+	}
+
+	const maxBuckets = 100_000
+	if (maxTs-minTs)/agg.bucketSizeNsecs > maxBuckets {
+		return rows
+	}
+
+	for ts := minTs; ts <= maxTs; ts += agg.bucketSizeNsecs {
+		if _, ok := seen[ts]; ok {
+			continue
+		}
+		keys := slices.Clone(rows[0].keys[:depth+1])
+		keys[depth] = time.Unix(0, ts).UTC().Format(time.RFC3339Nano)
+		rows = append(rows, aggRow{
+			keys:    keys,
+			metrics: make([]string, len(agg.metrics)),
+		})
+	}
+	return rows
+}
+
+func addMetricResults(dst map[string]any, metrics []*aggregation, r *aggRow) {
+	for i, agg := range metrics {
+		var value any
+		if r != nil {
+			value = getMetricValue(agg, r.metrics[i])
+		} else if agg.kind == "cardinality" || agg.kind == "value_count" {
+			value = 0
+		}
+		dst[agg.name] = map[string]any{
+			"value": value,
+		}
+	}
+}
+
+func getMetricValue(agg *aggregation, s string) any {
+	if agg.kind == "cardinality" || agg.kind == "value_count" {
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return 0
+		}
+		return n
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil
+	}
+	return f
+}
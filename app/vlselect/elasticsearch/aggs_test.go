@@ -0,0 +1,135 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/valyala/fastjson"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/logstorage"
+)
+
+func TestExecuteAggregations(t *testing.T) {
+	f := func(aggsStr string, results map[string][][]logstorage.Field, resultExpected string) {
+		t.Helper()
+
+		v, err := fastjson.Parse(aggsStr)
+		if err != nil {
+			t.Fatalf("cannot parse aggs: %s", err)
+		}
+		aggs, err := parseAggregations(v)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		runQuery := func(qStr string) ([][]logstorage.Field, error) {
+			if _, err := logstorage.ParseQuery(qStr); err != nil {
+				t.Fatalf("cannot parse the generated query [%s]: %s", qStr, err)
+			}
+			rows, ok := results[qStr]
+			if !ok {
+				t.Fatalf("unexpected query [%s]", qStr)
+			}
+			return rows, nil
+		}
+		result, err := executeAggregations(aggs, "*", runQuery)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		data, err := json.Marshal(result)
+		if err != nil {
+			t.Fatalf("cannot marshal result: %s", err)
+		}
+		if string(data) != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", data, resultExpected)
+		}
+	}
+
+	row := func(kvs ...string) []logstorage.Field {
+		fields := make([]logstorage.Field, 0, len(kvs)/2)
+		for i := 0; i < len(kvs); i += 2 {
+			fields = append(fields, logstorage.Field{
+				Name:  kvs[i],
+				Value: kvs[i+1],
+			})
+		}
+		return fields
+	}
+
+	// metric aggregations
+	f(`{"avg_duration":{"avg":{"field":"duration"}},"hosts":{"cardinality":{"field":"host"}}}`, map[string][][]logstorage.Field{
+		`* | stats count() __es_doc_count, avg("duration") __es_metric_0, count_uniq("host") __es_metric_1`: {
+			row("__es_doc_count", "10", "__es_metric_0", "1.5", "__es_metric_1", "3"),
+		},
+	}, `{"avg_duration":{"value":1.5},"hosts":{"value":3}}`)
+
+	// terms aggregation with size and missing values
+	f(`{"levels":{"terms":{"field":"level","size":2}}}`, map[string][][]logstorage.Field{
+		`* | stats by ("level") count() __es_doc_count`: {
+			row("level", "info", "__es_doc_count", "5"),
+			row("level", "", "__es_doc_count", "7"),
+			row("level", "warn", "__es_doc_count", "2"),
+			row("level", "error", "__es_doc_count", "3"),
+		},
+	}, `{"levels":{"buckets":[{"doc_count":5,"key":"info"},{"doc_count":3,"key":"error"}],"doc_count_error_upper_bound":0,"sum_other_doc_count":2}}`)
+
+	// date_histogram with gaps and nested terms with metric
+	f(`{"over_time":{"date_histogram":{"field":"@timestamp","fixed_interval":"1h"},"aggs":{"by_host":{"terms":{"field":"host","order":{"_key":"asc"}},"aggs":{"max_d":{"max":{"field":"d"}}}}}}}`, map[string][][]logstorage.Field{
+		`* | stats by (_time:1h) count() __es_doc_count`: {
+			row("_time", "2024-01-01T02:00:00Z", "__es_doc_count", "1"),
+			row("_time", "2024-01-01T00:00:00Z", "__es_doc_count", "3"),
+		},
+		`* | stats by (_time:1h, "host") count() __es_doc_count, max("d") __es_metric_0`: {
+			row("_time", "2024-01-01T00:00:00Z", "host", "b", "__es_doc_count", "1", "__es_metric_0", "7"),
+			row("_time", "2024-01-01T00:00:00Z", "host", "a", "__es_doc_count", "2", "__es_metric_0", "5"),
+			row("_time", "2024-01-01T02:00:00Z", "host", "a", "__es_doc_count", "1", "__es_metric_0", "NaN"),
+		},
+	}, `{"over_time":{"buckets":[`+
+		`{"by_host":{"buckets":[{"doc_count":2,"key":"a","max_d":{"value":5}},{"doc_count":1,"key":"b","max_d":{"value":7}}],"doc_count_error_upper_bound":0,"sum_other_doc_count":0},"doc_count":3,"key":1704067200000,"key_as_string":"2024-01-01T00:00:00.000Z"},`+
+		`{"by_host":{"buckets":[],"doc_count_error_upper_bound":0,"sum_other_doc_count":0},"doc_count":0,"key":1704070800000,"key_as_string":"2024-01-01T01:00:00.000Z"},`+
+		`{"by_host":{"buckets":[{"doc_count":1,"key":"a","max_d":{"value":null}}],"doc_count_error_upper_bound":0,"sum_other_doc_count":0},"doc_count":1,"key":1704074400000,"key_as_string":"2024-01-01T02:00:00.000Z"}]}}`)
+
+	// bucket fields with names clashing with the former result column names
+	f(`{"by_m0":{"terms":{"field":"m0"},"aggs":{"max_m1":{"max":{"field":"m1"}}}},"by_doc_count":{"terms":{"field":"doc_count"}}}`, map[string][][]logstorage.Field{
+		`* | stats by ("m0") count() __es_doc_count, max("m1") __es_metric_0`: {
+			row("m0", "foo", "__es_doc_count", "3", "__es_metric_0", "10"),
+			row("m0", "bar", "__es_doc_count", "1", "__es_metric_0", "20"),
+		},
+		`* | stats by ("doc_count") count() __es_doc_count`: {
+			row("doc_count", "42", "__es_doc_count", "2"),
+		},
+	}, `{"by_doc_count":{"buckets":[{"doc_count":2,"key":"42"}],"doc_count_error_upper_bound":0,"sum_other_doc_count":0},`+
+		`"by_m0":{"buckets":[{"doc_count":3,"key":"foo","max_m1":{"value":10}},{"doc_count":1,"key":"bar","max_m1":{"value":20}}],"doc_count_error_upper_bound":0,"sum_other_doc_count":0}}`)
+}
+
+func TestParseAggregations_Failure(t *testing.T) {
+	f := func(aggsStr string) {
+		t.Helper()
+
+		v, err := fastjson.Parse(aggsStr)
+		if err != nil {
+			t.Fatalf("cannot parse aggs: %s", err)
+		}
+		if _, err := parseAggregations(v); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// unsupported aggregation
+	f(`{"x":{"percentiles":{"field":"a"}}}`)
+
+	// missing field
+	f(`{"x":{"terms":{}}}`)
+
+	// date_histogram on non-time field
+	f(`{"x":{"date_histogram":{"field":"a","fixed_interval":"1h"}}}`)
+
+	// missing interval
+	f(`{"x":{"date_histogram":{"field":"@timestamp"}}}`)
+
+	// multiple nested bucket aggregations
+	f(`{"x":{"terms":{"field":"a"},"aggs":{"y":{"terms":{"field":"b"}},"z":{"terms":{"field":"c"}}}}}`)
+
+	// metric aggregation with sub-aggregations
+	f(`{"x":{"avg":{"field":"a"},"aggs":{"y":{"terms":{"field":"b"}}}}}`)
+}
@@ -0,0 +1,783 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httputil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/valyala/fastjson"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/app/vlselect/logsql"
+	"github.com/VictoriaMetrics/VictoriaLogs/app/vlstorage"
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/logstorage"
+)
+
+var (
+	timeField = flag.String("elasticsearch.timeField", "@timestamp", "The name of the Elasticsearch field, which is mapped to _time field "+
+		"at /select/elasticsearch/* endpoints. See https://docs.victoriametrics.com/victorialogs/querying/#elasticsearch-query-api")
+	msgField = flag.String("elasticsearch.msgField", "message", "The name of the Elasticsearch field, which is mapped to _msg field "+
+		"at /select/elasticsearch/* endpoints. See https://docs.victoriametrics.com/victorialogs/querying/#elasticsearch-query-api")
+	maxResultWindow = flag.Int("elasticsearch.maxResultWindow", 10000, "The maximum value of from+size, which can be passed to /select/elasticsearch/*/_search. "+
+		"See https://docs.victoriametrics.com/victorialogs/querying/#elasticsearch-query-api")
+	fieldCapsLookbehind = flag.Duration("elasticsearch.fieldCapsLookbehind", 24*time.Hour, "The time range for detecting field names at /select/elasticsearch/*/_field_caps "+
+		"if start and end query args are missing. See https://docs.victoriametrics.com/victorialogs/querying/#elasticsearch-query-api")
+)
+
+// defaultIndexName is returned in the _index field of hits if the index isn't specified in the request path.
+const defaultIndexName = "victorialogs"
+
+// RequestHandler processes read-only Elasticsearch API requests at /select/elasticsearch/*
+//
+// The index name in the request path is ignored, since all the logs for the given tenant are queried.
+//
+// See https://docs.victoriametrics.com/victorialogs/querying/#elasticsearch-query-api
+func RequestHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, path string) bool {
+	path = strings.TrimPrefix(path, "/select/elasticsearch")
+	path = strings.TrimPrefix(path, "/")
+
+	index := ""
+	endpoint := path
+	if n := strings.LastIndexByte(path, '/'); n >= 0 {
+		index = path[:n]
+		endpoint = path[n+1:]
+	}
+	if index == "" || strings.HasPrefix(index, "_") {
+		index = defaultIndexName
+	}
+
+	// This header is needed for Elasticsearch clients, which verify the product.
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+
+	startTime := time.Now()
+	switch endpoint {
+	case "_search":
+		searchRequests.Inc()
+		processSearchRequest(ctx, w, r, index)
+		searchDuration.UpdateDuration(startTime)
+		return true
+	case "_count":
+		countRequests.Inc()
+		processCountRequest(ctx, w, r)
+		countDuration.UpdateDuration(startTime)
+		return true
+	case "_field_caps":
+		fieldCapsRequests.Inc()
+		processFieldCapsRequest(ctx, w, r, index)
+		fieldCapsDuration.UpdateDuration(startTime)
+		return true
+	default:
+		return false
+	}
+}
+
+var (
+	searchRequests = metrics.NewCounter(`vl_http_requests_total{path="/select/elasticsearch/_search"}`)
+	searchDuration = metrics.NewSummary(`vl_http_request_duration_seconds{path="/select/elasticsearch/_search"}`)
+
+	countRequests = metrics.NewCounter(`vl_http_requests_total{path="/select/elasticsearch/_count"}`)
+	countDuration = metrics.NewSummary(`vl_http_request_duration_seconds{path="/select/elasticsearch/_count"}`)
+
+	fieldCapsRequests = metrics.NewCounter(`vl_http_requests_total{path="/select/elasticsearch/_field_caps"}`)
+	fieldCapsDuration = metrics.NewSummary(`vl_http_request_duration_seconds{path="/select/elasticsearch/_field_caps"}`)
+)
+
+// searchRequest is a parsed Elasticsearch _search or _count request.
+type searchRequest struct {
+	// filter is LogsQL filter obtained from the query.
+	filter string
+
+	from int
+	size int
+
+	// sortFields contains `sort by (...)` entries in LogsQL format.
+	sortFields []sortField
+
+	// sourceFields contains the list of fields to return in _source. All the fields are returned if it is empty.
+	sourceFields []string
+
+	// noSource is set to true if _source is disabled.
+	noSource bool
+
+	trackTotalHits bool
+
+	aggs []*aggregation
+}
+
+type sortField struct {
+	name string
+	desc bool
+}
+
+func parseSearchRequest(r *http.Request, currentTimestamp int64) (*searchRequest, error) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read request body: %w", err)
+	}
+
+	sr := &searchRequest{
+		filter:         "*",
+		size:           10,
+		trackTotalHits: true,
+	}
+
+	if len(strings.TrimSpace(string(data))) > 0 {
+		if err := sr.parseBody(data, currentTimestamp); err != nil {
+			return nil, err
+		}
+	}
+
+	// Query args override the corresponding options from the request body.
+	if q := r.FormValue("q"); q != "" {
+		defaultOperator := strings.ToUpper(r.FormValue("default_operator"))
+		if defaultOperator == "" {
+			defaultOperator = "OR"
+		}
+		f, err := convertLuceneQuery(q, r.FormValue("df"), defaultOperator, currentTimestamp)
+		if err != nil {
+			return nil, err
+		}
+		sr.filter = f
+	}
+	if r.FormValue("size") != "" {
+		n, err := httputil.GetInt(r, "size")
+		if err != nil {
+			return nil, err
+		}
+		sr.size = n
+	}
+	if r.FormValue("from") != "" {
+		n, err := httputil.GetInt(r, "from")
+		if err != nil {
+			return nil, err
+		}
+		sr.from = n
+	}
+	if s := r.FormValue("track_total_hits"); s != "" {
+		sr.trackTotalHits = s != "false"
+	}
+
+	if sr.from < 0 || sr.size < 0 {
+		return nil, fmt.Errorf("from and size cannot be negative; got from=%d, size=%d", sr.from, sr.size)
+	}
+	if sr.from+sr.size > *maxResultWindow {
+		return nil, fmt.Errorf("from+size cannot exceed -elasticsearch.maxResultWindow=%d; got from=%d, size=%d", *maxResultWindow, sr.from, sr.size)
+	}
+	return sr, nil
+}
+
+func (sr *searchRequest) parseBody(data []byte, currentTimestamp int64) error {
+	v, err := fastjson.ParseBytes(data)
+	if err != nil {
+		return fmt.Errorf("cannot parse request body: %w", err)
+	}
+	if v.Type() != fastjson.TypeObject {
+		return fmt.Errorf("request body must contain JSON object; got %s", v.Type())
+	}
+
+	if qv := v.Get("query"); qv != nil {
+		f, err := convertQueryToFilter(qv, currentTimestamp)
+		if err != nil {
+			return fmt.Errorf("cannot convert query to LogsQL: %w", err)
+		}
+		sr.filter = f
+	}
+	if sv := v.Get("size"); sv != nil {
+		n, err := getInt(sv)
+		if err != nil {
+			return fmt.Errorf("cannot parse size: %w", err)
+		}
+		sr.size = n
+	}
+	if fv := v.Get("from"); fv != nil {
+		n, err := getInt(fv)
+		if err != nil {
+			return fmt.Errorf("cannot parse from: %w", err)
+		}
+		sr.from = n
+	}
+	if tv := v.Get("track_total_hits"); tv != nil {
+		sr.trackTotalHits = tv.Type() != fastjson.TypeFalse
+	}
+	if sv := v.Get("sort"); sv != nil {
+		sortFields, err := parseSort(sv)
+		if err != nil {
+			return fmt.Errorf("cannot parse sort: %w", err)
+		}
+		sr.sortFields = sortFields
+	}
+	if sv := v.Get("_source"); sv != nil {
+		if err := sr.parseSource(sv); err != nil {
+			return fmt.Errorf("cannot parse _source: %w", err)
+		}
+	}
+
+	av := v.Get("aggs")
+	if av == nil {
+		av = v.Get("aggregations")
+	}
+	if av != nil {
+		aggs, err := parseAggregations(av)
+		if err != nil {
+			return err
+		}
+		sr.aggs = aggs
+	}
+	return nil
+}
+
+func parseSort(v *fastjson.Value) ([]sortField, error) {
+	items := []*fastjson.Value{v}
+	if v.Type() == fastjson.TypeArray {
+		items = v.GetArray()
+	}
+
+	var sortFields []sortField
+	for _, item := range items {
+		var name string
+		desc := false
+		switch item.Type() {
+		case fastjson.TypeString:
+			name = string(item.GetStringBytes())
+		case fastjson.TypeObject:
+			fieldName, ov, err := getSingleField(item)
+			if err != nil {
+				return nil, err
+			}
+			name = fieldName
+			if ov.Type() == fastjson.TypeObject {
+				ov = ov.Get("order")
+			}
+			if ov != nil {
+				switch string(ov.GetStringBytes()) {
+				case "asc":
+				case "desc":
+					desc = true
+				default:
+					return nil, fmt.Errorf("unsupported sort order for field %q: %s", fieldName, ov)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("unexpected sort item: %s", item)
+		}
+		if name == "_score" || name == "_doc" {
+			// Scoring isn't supported, while _doc sorting means arbitrary order.
+			continue
+		}
+		sortFields = append(sortFields, sortField{
+			name: name,
+			desc: desc,
+		})
+	}
+	return sortFields, nil
+}
+
+func (sr *searchRequest) parseSource(v *fastjson.Value) error {
+	switch v.Type() {
+	case fastjson.TypeFalse:
+		sr.noSource = true
+		return nil
+	case fastjson.TypeTrue:
+		return nil
+	case fastjson.TypeString:
+		sr.sourceFields = []string{string(v.GetStringBytes())}
+		return nil
+	case fastjson.TypeArray:
+		for _, item := range v.GetArray() {
+			sr.sourceFields = append(sr.sourceFields, string(item.GetStringBytes()))
+		}
+		return nil
+	case fastjson.TypeObject:
+		if iv := v.Get("includes"); iv != nil {
+			return sr.parseSource(iv)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unexpected value: %s", v)
+	}
+}
+
+// getHitsQuery returns LogsQL query for selecting hits for sr.
+func (sr *searchRequest) getHitsQuery() string {
+	var sb strings.Builder
+	sb.WriteString(sr.filter)
+
+	if len(sr.sortFields) > 0 {
+		a := make([]string, len(sr.sortFields))
+		for i, sf := range sr.sortFields {
+			a[i] = quoteFieldName(sf.name)
+			if sf.desc {
+				a[i] += " desc"
+			}
+		}
+		sb.WriteString(" | sort by (" + strings.Join(a, ", ") + ")")
+		sb.WriteString(fmt.Sprintf(" | offset %d | limit %d", sr.from, sr.size))
+	}
+
+	if len(sr.sourceFields) > 0 {
+		fields := []string{"_time", "_stream_id"}
+		for _, sf := range sr.sortFields {
+			// Sort fields are needed for returning sort values in hits.
+			fields = append(fields, quoteFieldName(sf.name))
+		}
+		for _, name := range sr.sourceFields {
+			if strings.HasSuffix(name, "*") {
+				fields = append(fields, strconv.Quote(strings.TrimSuffix(name, "*"))+"*")
+			} else {
+				fields = append(fields, quoteFieldName(name))
+			}
+		}
+		sb.WriteString(" | fields " + strings.Join(fields, ", "))
+	}
+
+	return sb.String()
+}
+
+// esQuery executes LogsQL queries on behalf of a single Elasticsearch request.
+type esQuery struct {
+	ctx       context.Context
+	r         *http.Request
+	tenantIDs []logstorage.TenantID
+	timestamp int64
+
+	allowPartialResponse bool
+
+	qs logstorage.QueryStats
+}
+
+func newESQuery(ctx context.Context, r *http.Request) (*esQuery, error) {
+	tenantID, err := logstorage.GetTenantIDFromRequest(r)
+	if err != nil {
+		return nil, fmt.Errorf("cannot obtain tenantID: %w", err)
+	}
+	eq := &esQuery{
+		ctx:       ctx,
+		r:         r,
+		tenantIDs: []logstorage.TenantID{tenantID},
+		timestamp: time.Now().UnixNano(),
+	}
+	if s := r.FormValue("allow_partial_search_results"); s != "" {
+		eq.allowPartialResponse = s == "true"
+	}
+	return eq, nil
+}
+
+// parseQuery parses LogsQL query qStr, adds extra filters from the request to it and applies the access policy for the request.
+func (eq *esQuery) parseQuery(qStr string) (*logstorage.Query, error) {
+	q, err := logstorage.ParseQueryAtTimestamp(qStr, eq.timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse the generated LogsQL query [%s]: %w", qStr, err)
+	}
+	if err := logsql.AddExtraFiltersFromRequest(q, eq.r); err != nil {
+		return nil, err
+	}
+	if _, err := logsql.ApplyAccessPolicy(q, eq.r, eq.tenantIDs); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func (eq *esQuery) newQueryContext(q *logstorage.Query) *logstorage.QueryContext {
+	qctx := logstorage.NewQueryContext(eq.ctx, &eq.qs, eq.tenantIDs, q, eq.allowPartialResponse)
+	qctx.Limits = logsql.GetQueryLimits(eq.tenantIDs)
+	return qctx
+}
+
+// runQuery executes qStr and returns all the resulting rows in the order they were returned.
+func (eq *esQuery) runQuery(qStr string) ([][]logstorage.Field, error) {
+	q, err := eq.parseQuery(qStr)
+	if err != nil {
+		return nil, err
+	}
+	return eq.runParsedQuery(q)
+}
+
+func (eq *esQuery) runParsedQuery(q *logstorage.Query) ([][]logstorage.Field, error) {
+	var rows [][]logstorage.Field
+	var rowsLock sync.Mutex
+	writeBlock := func(_ uint, db *logstorage.DataBlock) {
+		rowsCount := db.RowsCount()
+		if rowsCount == 0 {
+			return
+		}
+		columns := db.Columns
+		for i := 0; i < rowsCount; i++ {
+			fields := make([]logstorage.Field, len(columns))
+			for j, c := range columns {
+				fields[j] = logstorage.Field{
+					Name:  strings.Clone(c.Name),
+					Value: strings.Clone(c.Values[i]),
+				}
+			}
+			rowsLock.Lock()
+			rows = append(rows, fields)
+			rowsLock.Unlock()
+		}
+	}
+
+	qctx := eq.newQueryContext(q)
+	if err := vlstorage.RunQuery(qctx, writeBlock); err != nil {
+		return nil, fmt.Errorf("cannot execute query [%s]: %w", q, err)
+	}
+	return rows, nil
+}
+
+// getCount returns the number of logs matching the given filter.
+func (eq *esQuery) getCount(filter string) (uint64, error) {
+	rows, err := eq.runQuery(filter + " | stats count() hits")
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 || len(rows[0]) == 0 {
+		return 0, nil
+	}
+	hitsStr := rows[0][len(rows[0])-1].Value
+	n, err := strconv.ParseUint(hitsStr, 10, 64)
+	if err != nil {
+		logger.Panicf("BUG: cannot parse hits=%q: %s", hitsStr, err)
+	}
+	return n, nil
+}
+
+func (eq *esQuery) updatePerQueryStatsMetrics() {
+	vlstorage.UpdatePerQueryStatsMetrics(&eq.qs)
+}
+
+type shardsInfo struct {
+	Total      int `json:"total"`
+	Successful int `json:"successful"`
+	Skipped    int `json:"skipped"`
+	Failed     int `json:"failed"`
+}
+
+var oneShard = shardsInfo{
+	Total:      1,
+	Successful: 1,
+}
+
+type searchResponse struct {
+	Took         int64          `json:"took"`
+	TimedOut     bool           `json:"timed_out"`
+	Shards       shardsInfo     `json:"_shards"`
+	Hits         searchHits     `json:"hits"`
+	Aggregations map[string]any `json:"aggregations,omitempty"`
+}
+
+type searchHits struct {
+	Total    *totalHits  `json:"total,omitempty"`
+	MaxScore *float64    `json:"max_score"`
+	Hits     []searchHit `json:"hits"`
+}
+
+type totalHits struct {
+	Value    uint64 `json:"value"`
+	Relation string `json:"relation"`
+}
+
+type searchHit struct {
+	Index  string          `json:"_index"`
+	ID     string          `json:"_id"`
+	Score  *float64        `json:"_score"`
+	Source json.RawMessage `json:"_source,omitempty"`
+	Sort   []any           `json:"sort,omitempty"`
+}
+
+func processSearchRequest(ctx context.Context, w http.ResponseWriter, r *http.Request, index string) {
+	startTime := time.Now()
+
+	eq, err := newESQuery(ctx, r)
+	if err != nil {
+		httpserver.Errorf(w, r, "%s", err)
+		return
+	}
+	defer eq.updatePerQueryStatsMetrics()
+
+	sr, err := parseSearchRequest(r, eq.timestamp)
+	if err != nil {
+		httpserver.Errorf(w, r, "%s", err)
+		return
+	}
+
+	resp := &searchResponse{
+		Shards: oneShard,
+		Hits: searchHits{
+			Hits: []searchHit{},
+		},
+	}
+
+	if sr.trackTotalHits {
+		n, err := eq.getCount(sr.filter)
+		if err != nil {
+			httpserver.Errorf(w, r, "%s", err)
+			return
+		}
+		resp.Hits.Total = &totalHits{
+			Value:    n,
+			Relation: "eq",
+		}
+	}
+
+	if sr.size > 0 {
+		hits, err := eq.getHits(sr, index)
+		if err != nil {
+			httpserver.Errorf(w, r, "%s", err)
+			return
+		}
+		resp.Hits.Hits = hits
+	}
+
+	if len(sr.aggs) > 0 {
+		aggs, err := executeAggregations(sr.aggs, sr.filter, eq.runQuery)
+		if err != nil {
+			httpserver.Errorf(w, r, "cannot execute aggregations: %s", err)
+			return
+		}
+		resp.Aggregations = aggs
+	}
+
+	resp.Took = time.Since(startTime).Milliseconds()
+	writeJSONResponse(w, resp)
+}
+
+func (eq *esQuery) getHits(sr *searchRequest, index string) ([]searchHit, error) {
+	q, err := eq.parseQuery(sr.getHitsQuery())
+	if err != nil {
+		return nil, err
+	}
+	if len(sr.sortFields) == 0 {
+		// Return the last logs by default. This pattern is automatically optimized during query execution.
+		// See https://github.com/VictoriaMetrics/VictoriaLogs/issues/96
+		if q.CanReturnLastNResults() {
+			q.AddPipeSortByTimeDesc()
+		}
+		q.AddPipeOffsetLimit(uint64(sr.from), uint64(sr.size))
+	}
+
+	rows, err := eq.runParsedQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	return getHitsFromRows(sr, index, rows), nil
+}
+
+// getHitsFromRows converts rows returned by the hits query for sr to search hits.
+//
+// The order of rows is preserved, since they are already sorted by the query.
+func getHitsFromRows(sr *searchRequest, index string, rows [][]logstorage.Field) []searchHit {
+	hits := make([]searchHit, len(rows))
+	for i, fields := range rows {
+		h := &hits[i]
+		h.Index = index
+		h.ID = getHitID(fields, sr.from+i)
+		if !sr.noSource {
+			h.Source = marshalSource(fields, sr.sourceFields)
+		}
+		for _, sf := range sr.sortFields {
+			h.Sort = append(h.Sort, getSortValue(fields, sf.name))
+		}
+	}
+	return hits
+}
+
+// getHitID returns an identifier for the log entry with the given fields.
+//
+// VictoriaLogs doesn't have unique identifiers for the stored logs, so the identifier is built from _stream_id and _time.
+func getHitID(fields []logstorage.Field, n int) string {
+	streamID := getFieldValue(fields, "_stream_id")
+	timestamp := getFieldValue(fields, "_time")
+	if streamID == "" && timestamp == "" {
+		return strconv.Itoa(n)
+	}
+	return streamID + ":" + timestamp
+}
+
+// marshalSource returns _source for the hit with the given fields.
+//
+// Only fields matching sourceFields are returned if sourceFields isn't empty.
+func marshalSource(fields []logstorage.Field, sourceFields []string) json.RawMessage {
+	source := make([]logstorage.Field, 0, len(fields))
+	for _, f := range fields {
+		switch f.Name {
+		case "_time":
+			f.Name = *timeField
+		case "_msg":
+			f.Name = *msgField
+		case "_stream_id", "_stream":
+			continue
+		}
+		if len(sourceFields) > 0 && !slices.ContainsFunc(sourceFields, func(filter string) bool {
+			return matchFieldFilter(filter, f.Name)
+		}) {
+			continue
+		}
+		source = append(source, f)
+	}
+	return logstorage.MarshalFieldsToJSON(nil, source)
+}
+
+func getSortValue(fields []logstorage.Field, name string) any {
+	if isTimeField(name) {
+		nsecs, ok := logstorage.TryParseTimestampRFC3339Nano(getFieldValue(fields, "_time"))
+		if ok {
+			return nsecs / 1e6
+		}
+		return nil
+	}
+	if name == *msgField {
+		name = "_msg"
+	}
+	return getFieldValue(fields, name)
+}
+
+func getFieldValue(fields []logstorage.Field, name string) string {
+	for _, f := range fields {
+		if f.Name == name {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+func processCountRequest(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	eq, err := newESQuery(ctx, r)
+	if err != nil {
+		httpserver.Errorf(w, r, "%s", err)
+		return
+	}
+	defer eq.updatePerQueryStatsMetrics()
+
+	sr, err := parseSearchRequest(r, eq.timestamp)
+	if err != nil {
+		httpserver.Errorf(w, r, "%s", err)
+		return
+	}
+
+	n, err := eq.getCount(sr.filter)
+	if err != nil {
+		httpserver.Errorf(w, r, "%s", err)
+		return
+	}
+
+	writeJSONResponse(w, map[string]any{
+		"count":   n,
+		"_shards": oneShard,
+	})
+}
+
+type fieldCapsResponse struct {
+	Indices []string                         `json:"indices"`
+	Fields  map[string]map[string]fieldCapsT `json:"fields"`
+}
+
+type fieldCapsT struct {
+	Type         string `json:"type"`
+	Searchable   bool   `json:"searchable"`
+	Aggregatable bool   `json:"aggregatable"`
+}
+
+func processFieldCapsRequest(ctx context.Context, w http.ResponseWriter, r *http.Request, index string) {
+	eq, err := newESQuery(ctx, r)
+	if err != nil {
+		httpserver.Errorf(w, r, "%s", err)
+		return
+	}
+	defer eq.updatePerQueryStatsMetrics()
+
+	start := eq.timestamp - fieldCapsLookbehind.Nanoseconds()
+	end := eq.timestamp
+	if s := r.FormValue("start"); s != "" {
+		start, err = parseTimeString(s, "", eq.timestamp)
+		if err != nil {
+			httpserver.Errorf(w, r, "cannot parse start=%q: %s", s, err)
+			return
+		}
+	}
+	if s := r.FormValue("end"); s != "" {
+		end, err = parseTimeString(s, "", eq.timestamp)
+		if err != nil {
+			httpserver.Errorf(w, r, "cannot parse end=%q: %s", s, err)
+			return
+		}
+	}
+
+	q, err := eq.parseQuery(fmt.Sprintf("_time:[%s, %s]", formatTimestamp(start), formatTimestamp(end)))
+	if err != nil {
+		httpserver.Errorf(w, r, "%s", err)
+		return
+	}
+	fieldNames, err := vlstorage.GetFieldNames(eq.newQueryContext(q))
+	if err != nil {
+		httpserver.Errorf(w, r, "cannot obtain field names: %s", err)
+		return
+	}
+
+	var filters []string
+	for _, s := range strings.Split(r.FormValue("fields"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			filters = append(filters, s)
+		}
+	}
+
+	fields := make(map[string]map[string]fieldCapsT, len(fieldNames))
+	for _, fn := range fieldNames {
+		name := fn.Value
+		fieldType := "keyword"
+		switch name {
+		case "_time":
+			name = *timeField
+			fieldType = "date"
+		case "_msg":
+			name = *msgField
+			fieldType = "text"
+		case "_stream", "_stream_id":
+			continue
+		}
+		if len(filters) > 0 && !slices.ContainsFunc(filters, func(filter string) bool {
+			return matchFieldFilter(filter, name)
+		}) {
+			continue
+		}
+		fields[name] = map[string]fieldCapsT{
+			fieldType: {
+				Type:         fieldType,
+				Searchable:   true,
+				Aggregatable: fieldType != "text",
+			},
+		}
+	}
+
+	writeJSONResponse(w, &fieldCapsResponse{
+		Indices: []string{index},
+		Fields:  fields,
+	})
+}
+
+func matchFieldFilter(filter, name string) bool {
+	if filter == "*" {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(filter, "*"); ok {
+		return strings.HasPrefix(name, prefix)
+	}
+	return filter == name
+}
+
+func writeJSONResponse(w http.ResponseWriter, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal Elasticsearch response: %s", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,74 @@
+package elasticsearch
+
+import (
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/logstorage"
+)
+
+func TestSearchRequestGetHitsQuery(t *testing.T) {
+	f := func(body, resultExpected string) {
+		t.Helper()
+
+		sr := &searchRequest{
+			filter: "*",
+			size:   10,
+		}
+		if err := sr.parseBody([]byte(body), 0); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		qStr := sr.getHitsQuery()
+		q, err := logstorage.ParseQuery(qStr)
+		if err != nil {
+			t.Fatalf("cannot parse the generated query [%s]: %s", qStr, err)
+		}
+		result := q.String()
+		if result != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	f(`{}`, `*`)
+	f(`{"query":{"term":{"a":"b"}},"size":5,"from":10,"sort":[{"@timestamp":{"order":"asc"}},"_score"]}`, `a:=b | sort by (_time) offset 10 limit 5`)
+	f(`{"sort":[{"status":"desc"},"host"],"_source":["message","kubernetes.*"]}`,
+		`* | sort by (status desc, host) limit 10 | fields _time, _stream_id, status, host, _msg, kubernetes.*`)
+	f(`{"_source":{"includes":"user"}}`, `* | fields _time, _stream_id, user`)
+}
+
+func TestGetHitsFromRows(t *testing.T) {
+	f := func(timestamps []string) {
+		t.Helper()
+
+		sr := &searchRequest{
+			filter: "*",
+			size:   10,
+		}
+		rows := make([][]logstorage.Field, len(timestamps))
+		for i, timestamp := range timestamps {
+			rows[i] = []logstorage.Field{
+				{
+					Name:  "_stream_id",
+					Value: "foo",
+				},
+				{
+					Name:  "_time",
+					Value: timestamp,
+				},
+			}
+		}
+		hits := getHitsFromRows(sr, "logs", rows)
+		if len(hits) != len(timestamps) {
+			t.Fatalf("unexpected number of hits; got %d; want %d", len(hits), len(timestamps))
+		}
+		for i, h := range hits {
+			idExpected := "foo:" + timestamps[i]
+			if h.ID != idExpected {
+				t.Fatalf("unexpected hit #%d; got %q; want %q", i, h.ID, idExpected)
+			}
+		}
+	}
+
+	// The order of rows returned by the query must be preserved
+	f([]string{"2025-06-05T14:30:05.1Z", "2025-06-05T14:30:05Z"})
+	f([]string{"2025-06-05T14:30:06Z", "2025-06-05T14:30:05.123456789Z", "2025-06-05T14:30:05Z"})
+}
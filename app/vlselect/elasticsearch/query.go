@@ -0,0 +1,615 @@
+package elasticsearch
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timeutil"
+	"github.com/valyala/fastjson"
+)
+
+// convertQueryToFilter converts Elasticsearch query DSL at v into LogsQL filter.
+//
+// See https://www.elastic.co/guide/en/elasticsearch/reference/current/query-dsl.html
+func convertQueryToFilter(v *fastjson.Value, currentTimestamp int64) (string, error) {
+	if v == nil || v.Type() == fastjson.TypeNull {
+		return "*", nil
+	}
+	o, err := v.Object()
+	if err != nil {
+		return "", fmt.Errorf("query must be a JSON object; got %s", v)
+	}
+	if o.Len() != 1 {
+		return "", fmt.Errorf("query must contain exactly one query type; got %s", v)
+	}
+
+	var qType string
+	var qv *fastjson.Value
+	o.Visit(func(k []byte, v *fastjson.Value) {
+		qType = string(k)
+		qv = v
+	})
+
+	switch qType {
+	case "match_all":
+		return "*", nil
+	case "match_none":
+		return "!*", nil
+	case "bool":
+		return convertBoolQuery(qv, currentTimestamp)
+	case "term":
+		return convertTermQuery(qv)
+	case "terms":
+		return convertTermsQuery(qv)
+	case "match":
+		return convertMatchQuery(qv)
+	case "match_phrase":
+		return convertMatchPhraseQuery(qv)
+	case "prefix":
+		return convertPrefixQuery(qv)
+	case "wildcard":
+		return convertWildcardQuery(qv)
+	case "exists":
+		return convertExistsQuery(qv)
+	case "range":
+		return convertRangeQuery(qv, currentTimestamp)
+	case "query_string":
+		return convertQueryStringQuery(qv, currentTimestamp)
+	default:
+		return "", fmt.Errorf("unsupported query type %q", qType)
+	}
+}
+
+func convertBoolQuery(v *fastjson.Value, currentTimestamp int64) (string, error) {
+	o, err := v.Object()
+	if err != nil {
+		return "", fmt.Errorf("bool query must be a JSON object; got %s", v)
+	}
+
+	var must, should, mustNot []string
+	minimumShouldMatch := -1
+	var errOuter error
+	o.Visit(func(k []byte, v *fastjson.Value) {
+		if errOuter != nil {
+			return
+		}
+		switch string(k) {
+		case "must", "filter":
+			filters, err := convertQueryList(v, currentTimestamp)
+			if err != nil {
+				errOuter = fmt.Errorf("cannot parse bool.%s: %w", k, err)
+				return
+			}
+			must = append(must, filters...)
+		case "should":
+			filters, err := convertQueryList(v, currentTimestamp)
+			if err != nil {
+				errOuter = fmt.Errorf("cannot parse bool.should: %w", err)
+				return
+			}
+			should = append(should, filters...)
+		case "must_not":
+			filters, err := convertQueryList(v, currentTimestamp)
+			if err != nil {
+				errOuter = fmt.Errorf("cannot parse bool.must_not: %w", err)
+				return
+			}
+			mustNot = append(mustNot, filters...)
+		case "minimum_should_match":
+			n, err := getInt(v)
+			if err != nil {
+				errOuter = fmt.Errorf("cannot parse bool.minimum_should_match: %w", err)
+				return
+			}
+			minimumShouldMatch = n
+		case "boost", "_name":
+			// These options affect only scoring, which isn't supported.
+		default:
+			errOuter = fmt.Errorf("unsupported bool query option %q", k)
+		}
+	})
+	if errOuter != nil {
+		return "", errOuter
+	}
+
+	if minimumShouldMatch < 0 {
+		// By default should clauses are optional if must or filter clauses are present.
+		// See https://www.elastic.co/guide/en/elasticsearch/reference/current/query-dsl-bool-query.html#bool-min-should-match
+		minimumShouldMatch = 1
+		if len(must) > 0 {
+			minimumShouldMatch = 0
+		}
+	}
+	if minimumShouldMatch > 1 {
+		return "", fmt.Errorf("bool.minimum_should_match=%d isn't supported; supported values: 0 and 1", minimumShouldMatch)
+	}
+
+	var filters []string
+	for _, f := range must {
+		filters = append(filters, "("+f+")")
+	}
+	if minimumShouldMatch == 1 && len(should) > 0 {
+		filters = append(filters, "("+strings.Join(should, " OR ")+")")
+	}
+	for _, f := range mustNot {
+		filters = append(filters, "!("+f+")")
+	}
+	if len(filters) == 0 {
+		return "*", nil
+	}
+	return strings.Join(filters, " "), nil
+}
+
+func convertQueryList(v *fastjson.Value, currentTimestamp int64) ([]string, error) {
+	if v.Type() != fastjson.TypeArray {
+		f, err := convertQueryToFilter(v, currentTimestamp)
+		if err != nil {
+			return nil, err
+		}
+		return []string{f}, nil
+	}
+
+	a := v.GetArray()
+	filters := make([]string, 0, len(a))
+	for _, av := range a {
+		f, err := convertQueryToFilter(av, currentTimestamp)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+func convertTermQuery(v *fastjson.Value) (string, error) {
+	fieldName, fv, err := getSingleField(v)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse term query: %w", err)
+	}
+	if fv.Type() == fastjson.TypeObject {
+		fv = fv.Get("value")
+	}
+	value, err := getString(fv)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse term query value for field %q: %w", fieldName, err)
+	}
+	return quoteFieldName(fieldName) + ":=" + strconv.Quote(value), nil
+}
+
+func convertTermsQuery(v *fastjson.Value) (string, error) {
+	o, err := v.Object()
+	if err != nil {
+		return "", fmt.Errorf("terms query must be a JSON object; got %s", v)
+	}
+
+	var fieldName string
+	var values []string
+	var errOuter error
+	o.Visit(func(k []byte, v *fastjson.Value) {
+		if errOuter != nil {
+			return
+		}
+		if string(k) == "boost" || string(k) == "_name" {
+			return
+		}
+		if fieldName != "" {
+			errOuter = fmt.Errorf("terms query must contain a single field; got %s", o)
+			return
+		}
+		fieldName = string(k)
+		a, err := v.Array()
+		if err != nil {
+			errOuter = fmt.Errorf("terms query must contain an array of values for field %q; got %s", k, v)
+			return
+		}
+		for _, av := range a {
+			value, err := getString(av)
+			if err != nil {
+				errOuter = fmt.Errorf("cannot parse terms query value for field %q: %w", k, err)
+				return
+			}
+			values = append(values, strconv.Quote(value))
+		}
+	})
+	if errOuter != nil {
+		return "", errOuter
+	}
+	if fieldName == "" {
+		return "", fmt.Errorf("missing field in terms query")
+	}
+	return quoteFieldName(fieldName) + ":in(" + strings.Join(values, ",") + ")", nil
+}
+
+func convertMatchQuery(v *fastjson.Value) (string, error) {
+	fieldName, fv, err := getSingleField(v)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse match query: %w", err)
+	}
+	op := "OR"
+	if fv.Type() == fastjson.TypeObject {
+		if opv := fv.Get("operator"); opv != nil {
+			op = strings.ToUpper(string(opv.GetStringBytes()))
+			if op != "OR" && op != "AND" {
+				return "", fmt.Errorf("unsupported operator in match query for field %q: %q", fieldName, opv.GetStringBytes())
+			}
+		}
+		fv = fv.Get("query")
+	}
+	query, err := getString(fv)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse match query for field %q: %w", fieldName, err)
+	}
+
+	words := strings.Fields(query)
+	if len(words) == 0 {
+		return "*", nil
+	}
+	name := quoteFieldName(fieldName)
+	for i, w := range words {
+		words[i] = name + ":" + strconv.Quote(w)
+	}
+	if len(words) == 1 {
+		return words[0], nil
+	}
+	return "(" + strings.Join(words, " "+op+" ") + ")", nil
+}
+
+func convertMatchPhraseQuery(v *fastjson.Value) (string, error) {
+	fieldName, fv, err := getSingleField(v)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse match_phrase query: %w", err)
+	}
+	if fv.Type() == fastjson.TypeObject {
+		fv = fv.Get("query")
+	}
+	phrase, err := getString(fv)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse match_phrase query for field %q: %w", fieldName, err)
+	}
+	return quoteFieldName(fieldName) + ":" + strconv.Quote(phrase), nil
+}
+
+func convertPrefixQuery(v *fastjson.Value) (string, error) {
+	fieldName, fv, err := getSingleField(v)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse prefix query: %w", err)
+	}
+	if fv.Type() == fastjson.TypeObject {
+		fv = fv.Get("value")
+	}
+	prefix, err := getString(fv)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse prefix query for field %q: %w", fieldName, err)
+	}
+	return quoteFieldName(fieldName) + ":=" + strconv.Quote(prefix) + "*", nil
+}
+
+func convertWildcardQuery(v *fastjson.Value) (string, error) {
+	fieldName, fv, err := getSingleField(v)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse wildcard query: %w", err)
+	}
+	if fv.Type() == fastjson.TypeObject {
+		if vv := fv.Get("value"); vv != nil {
+			fv = vv
+		} else {
+			fv = fv.Get("wildcard")
+		}
+	}
+	pattern, err := getString(fv)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse wildcard query for field %q: %w", fieldName, err)
+	}
+	return quoteFieldName(fieldName) + ":~" + strconv.Quote(wildcardToRegexp(pattern)), nil
+}
+
+func convertExistsQuery(v *fastjson.Value) (string, error) {
+	fieldName := string(v.GetStringBytes("field"))
+	if fieldName == "" {
+		return "", fmt.Errorf("missing field in exists query")
+	}
+	return quoteFieldName(fieldName) + ":*", nil
+}
+
+func convertRangeQuery(v *fastjson.Value, currentTimestamp int64) (string, error) {
+	fieldName, fv, err := getSingleField(v)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse range query: %w", err)
+	}
+	o, err := fv.Object()
+	if err != nil {
+		return "", fmt.Errorf("range query for field %q must contain JSON object; got %s", fieldName, fv)
+	}
+
+	format := string(fv.GetStringBytes("format"))
+	isTime := isTimeField(fieldName)
+
+	var rb rangeBounds
+	var errOuter error
+	o.Visit(func(k []byte, v *fastjson.Value) {
+		if errOuter != nil {
+			return
+		}
+		switch string(k) {
+		case "gt", "gte", "lt", "lte", "from", "to":
+			if v.Type() == fastjson.TypeNull {
+				return
+			}
+			var s string
+			if isTime {
+				nsecs, err := parseTimeValue(v, format, currentTimestamp)
+				if err != nil {
+					errOuter = fmt.Errorf("cannot parse %s for range query on %q: %w", k, fieldName, err)
+					return
+				}
+				s = formatTimestamp(nsecs)
+			} else {
+				n, err := getNumber(v)
+				if err != nil {
+					errOuter = fmt.Errorf("range query on field %q supports only numeric values; got %s=%s", fieldName, k, v)
+					return
+				}
+				s = n
+			}
+			rb.set(string(k), s)
+		case "format", "time_zone", "boost", "include_lower", "include_upper", "relation", "_name":
+			// Ignore these options.
+		default:
+			errOuter = fmt.Errorf("unsupported option in range query on field %q: %q", fieldName, k)
+		}
+	})
+	if errOuter != nil {
+		return "", errOuter
+	}
+
+	if isTime {
+		return rb.timeFilter(), nil
+	}
+	return rb.numericFilter(quoteFieldName(fieldName)), nil
+}
+
+// rangeBounds holds the parsed bounds of Elasticsearch range query.
+type rangeBounds struct {
+	lower          string
+	lowerInclusive bool
+	upper          string
+	upperInclusive bool
+}
+
+func (rb *rangeBounds) set(op, value string) {
+	switch op {
+	case "gt":
+		rb.lower = value
+		rb.lowerInclusive = false
+	case "gte", "from":
+		rb.lower = value
+		rb.lowerInclusive = true
+	case "lt":
+		rb.upper = value
+		rb.upperInclusive = false
+	case "lte", "to":
+		rb.upper = value
+		rb.upperInclusive = true
+	}
+}
+
+func (rb *rangeBounds) timeFilter() string {
+	switch {
+	case rb.lower != "" && rb.upper != "":
+		return "_time:" + rb.brackets(rb.lower+", "+rb.upper)
+	case rb.lower != "":
+		return "_time:" + rb.lowerOp() + rb.lower
+	case rb.upper != "":
+		return "_time:" + rb.upperOp() + rb.upper
+	default:
+		return "*"
+	}
+}
+
+func (rb *rangeBounds) numericFilter(name string) string {
+	switch {
+	case rb.lower != "" && rb.upper != "":
+		return name + ":range" + rb.brackets(rb.lower+", "+rb.upper)
+	case rb.lower != "":
+		return name + ":" + rb.lowerOp() + rb.lower
+	case rb.upper != "":
+		return name + ":" + rb.upperOp() + rb.upper
+	default:
+		return name + ":*"
+	}
+}
+
+func (rb *rangeBounds) brackets(s string) string {
+	left := "("
+	if rb.lowerInclusive {
+		left = "["
+	}
+	right := ")"
+	if rb.upperInclusive {
+		right = "]"
+	}
+	return left + s + right
+}
+
+func (rb *rangeBounds) lowerOp() string {
+	if rb.lowerInclusive {
+		return ">="
+	}
+	return ">"
+}
+
+func (rb *rangeBounds) upperOp() string {
+	if rb.upperInclusive {
+		return "<="
+	}
+	return "<"
+}
+
+func convertQueryStringQuery(v *fastjson.Value, currentTimestamp int64) (string, error) {
+	query, err := getString(v.Get("query"))
+	if err != nil {
+		return "", fmt.Errorf("cannot parse query_string.query: %w", err)
+	}
+
+	defaultField := string(v.GetStringBytes("default_field"))
+	if defaultField == "*" {
+		defaultField = ""
+	}
+
+	defaultOperator := "OR"
+	if opv := v.Get("default_operator"); opv != nil {
+		defaultOperator = strings.ToUpper(string(opv.GetStringBytes()))
+		if defaultOperator != "OR" && defaultOperator != "AND" {
+			return "", fmt.Errorf("unsupported query_string.default_operator: %q", opv.GetStringBytes())
+		}
+	}
+
+	return convertLuceneQuery(query, defaultField, defaultOperator, currentTimestamp)
+}
+
+func getSingleField(v *fastjson.Value) (string, *fastjson.Value, error) {
+	o, err := v.Object()
+	if err != nil {
+		return "", nil, fmt.Errorf("expecting JSON object; got %s", v)
+	}
+	if o.Len() != 1 {
+		return "", nil, fmt.Errorf("expecting JSON object with a single field; got %s", v)
+	}
+	var fieldName string
+	var fv *fastjson.Value
+	o.Visit(func(k []byte, v *fastjson.Value) {
+		fieldName = string(k)
+		fv = v
+	})
+	return fieldName, fv, nil
+}
+
+func getString(v *fastjson.Value) (string, error) {
+	if v == nil {
+		return "", fmt.Errorf("missing value")
+	}
+	switch v.Type() {
+	case fastjson.TypeString:
+		return string(v.GetStringBytes()), nil
+	case fastjson.TypeNumber, fastjson.TypeTrue, fastjson.TypeFalse:
+		return v.String(), nil
+	default:
+		return "", fmt.Errorf("expecting string, number or bool; got %s", v)
+	}
+}
+
+func getNumber(v *fastjson.Value) (string, error) {
+	s, err := getString(v)
+	if err != nil {
+		return "", err
+	}
+	if _, err := strconv.ParseFloat(s, 64); err != nil {
+		return "", fmt.Errorf("cannot parse %q as number", s)
+	}
+	return s, nil
+}
+
+func getInt(v *fastjson.Value) (int, error) {
+	s, err := getString(v)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse %q as integer", s)
+	}
+	return n, nil
+}
+
+// parseTimeValue parses Elasticsearch date value at v according to the given format.
+//
+// Numeric values are treated as milliseconds since Unix epoch unless the format is set to epoch_second.
+// See https://www.elastic.co/guide/en/elasticsearch/reference/current/common-options.html#date-math
+func parseTimeValue(v *fastjson.Value, format string, currentTimestamp int64) (int64, error) {
+	s, err := getString(v)
+	if err != nil {
+		return 0, err
+	}
+	return parseTimeString(s, format, currentTimestamp)
+}
+
+func parseTimeString(s, format string, currentTimestamp int64) (int64, error) {
+	if isAllDigits(s) {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		if strings.Contains(format, "epoch_second") {
+			return n * 1e9, nil
+		}
+		return n * 1e6, nil
+	}
+
+	if strings.HasPrefix(s, "now") {
+		// Drop rounding suffix such as /d, since it isn't supported.
+		if n := strings.IndexByte(s, '/'); n >= 0 {
+			s = s[:n]
+		}
+	} else if n := strings.Index(s, "||"); n >= 0 {
+		// Date math with explicit anchor, such as 2024-01-01||+1d, isn't supported - use the anchor only.
+		s = s[:n]
+	}
+	return timeutil.ParseTimeAt(s, currentTimestamp)
+}
+
+func isAllDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func formatTimestamp(nsecs int64) string {
+	return time.Unix(0, nsecs).UTC().Format("2006-01-02T15:04:05.000000000Z07:00")
+}
+
+// isTimeField returns true if fieldName refers to the log timestamp.
+func isTimeField(fieldName string) bool {
+	return fieldName == "_time" || fieldName == *timeField
+}
+
+// quoteFieldName returns LogsQL representation for the given Elasticsearch field name.
+func quoteFieldName(fieldName string) string {
+	if isTimeField(fieldName) {
+		return "_time"
+	}
+	if fieldName == *msgField {
+		return "_msg"
+	}
+	return strconv.Quote(fieldName)
+}
+
+// wildcardToRegexp converts Elasticsearch wildcard pattern with `*` and `?` into anchored regexp.
+func wildcardToRegexp(pattern string) string {
+	var sb strings.Builder
+	sb.WriteString("^")
+	start := 0
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*':
+			sb.WriteString(regexp.QuoteMeta(pattern[start:i]))
+			sb.WriteString(".*")
+			start = i + 1
+		case '?':
+			sb.WriteString(regexp.QuoteMeta(pattern[start:i]))
+			sb.WriteString(".")
+			start = i + 1
+		}
+	}
+	sb.WriteString(regexp.QuoteMeta(pattern[start:]))
+	sb.WriteString("$")
+	return sb.String()
+}
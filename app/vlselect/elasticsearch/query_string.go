@@ -0,0 +1,511 @@
+package elasticsearch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// convertLuceneQuery converts Lucene query string syntax used in Elasticsearch query_string queries and in `q` query arg into LogsQL filter.
+//
+// defaultField is used for terms without explicit field name. Terms are searched in the _msg field if defaultField is empty.
+// defaultOperator must be either OR or AND. It is used for joining terms without explicit operator between them.
+//
+// See https://www.elastic.co/guide/en/elasticsearch/reference/current/query-dsl-query-string-query.html#query-string-syntax
+func convertLuceneQuery(s, defaultField, defaultOperator string, currentTimestamp int64) (string, error) {
+	tokens, err := tokenizeLuceneQuery(s)
+	if err != nil {
+		return "", err
+	}
+	lp := &luceneParser{
+		tokens:           tokens,
+		defaultOperator:  defaultOperator,
+		currentTimestamp: currentTimestamp,
+	}
+	f, err := lp.parseExpr(defaultField)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse query string %q: %w", s, err)
+	}
+	if len(lp.tokens) > 0 {
+		return "", fmt.Errorf("cannot parse query string %q: unexpected token %q", s, lp.tokens[0].s)
+	}
+	if f == "" {
+		return "*", nil
+	}
+	return f, nil
+}
+
+type luceneTokenKind int
+
+const (
+	luceneTokenTerm luceneTokenKind = iota
+	luceneTokenPhrase
+	luceneTokenOpenParen
+	luceneTokenCloseParen
+	luceneTokenRange
+)
+
+type luceneToken struct {
+	kind luceneTokenKind
+
+	// s contains the token value. It is unescaped for luceneTokenTerm and luceneTokenPhrase.
+	s string
+
+	// field is the optional field name the token applies to, e.g. `field:value`.
+	field string
+
+	// modifier is optional `+` or `-` in front of the token.
+	modifier byte
+
+	// hasWildcards is set to true if luceneTokenTerm contains unescaped `*` or `?`.
+	hasWildcards bool
+}
+
+func tokenizeLuceneQuery(s string) ([]luceneToken, error) {
+	var tokens []luceneToken
+	var modifier byte
+	var field string
+	for {
+		s = strings.TrimLeft(s, " \t\r\n")
+		if s == "" {
+			if modifier != 0 || field != "" {
+				return nil, fmt.Errorf("unexpected end of query string")
+			}
+			return tokens, nil
+		}
+
+		switch s[0] {
+		case '+', '-':
+			if modifier != 0 || field != "" {
+				return nil, fmt.Errorf("unexpected %q", s[0])
+			}
+			modifier = s[0]
+			s = s[1:]
+			continue
+		case '!':
+			tokens = append(tokens, luceneToken{
+				kind: luceneTokenTerm,
+				s:    "NOT",
+			})
+			s = s[1:]
+			continue
+		case '(':
+			tokens = append(tokens, luceneToken{
+				kind:     luceneTokenOpenParen,
+				s:        "(",
+				field:    field,
+				modifier: modifier,
+			})
+			modifier = 0
+			field = ""
+			s = s[1:]
+			continue
+		case ')':
+			tokens = append(tokens, luceneToken{
+				kind: luceneTokenCloseParen,
+				s:    ")",
+			})
+			s = s[1:]
+			continue
+		case '"':
+			phrase, tail, err := readLucenePhrase(s)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, luceneToken{
+				kind:     luceneTokenPhrase,
+				s:        phrase,
+				field:    field,
+				modifier: modifier,
+			})
+			modifier = 0
+			field = ""
+			s = tail
+			continue
+		case '[', '{':
+			if field == "" {
+				return nil, fmt.Errorf("range query %q must be applied to a field", s)
+			}
+			n := strings.IndexAny(s, "]}")
+			if n < 0 {
+				return nil, fmt.Errorf("missing closing bracket for range query %q", s)
+			}
+			tokens = append(tokens, luceneToken{
+				kind:     luceneTokenRange,
+				s:        s[:n+1],
+				field:    field,
+				modifier: modifier,
+			})
+			modifier = 0
+			field = ""
+			s = s[n+1:]
+			continue
+		}
+
+		if strings.HasPrefix(s, "&&") || strings.HasPrefix(s, "||") {
+			op := "AND"
+			if s[0] == '|' {
+				op = "OR"
+			}
+			tokens = append(tokens, luceneToken{
+				kind: luceneTokenTerm,
+				s:    op,
+			})
+			s = s[2:]
+			continue
+		}
+
+		term, hasWildcards, tail, isField := readLuceneTerm(s)
+		s = tail
+		if isField {
+			if field != "" {
+				return nil, fmt.Errorf("unexpected field name %q after field name %q", term, field)
+			}
+			field = term
+			continue
+		}
+		tokens = append(tokens, luceneToken{
+			kind:         luceneTokenTerm,
+			s:            term,
+			field:        field,
+			modifier:     modifier,
+			hasWildcards: hasWildcards,
+		})
+		modifier = 0
+		field = ""
+	}
+}
+
+func readLucenePhrase(s string) (string, string, error) {
+	var sb strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				sb.WriteByte(s[i])
+			}
+		case '"':
+			tail := s[i+1:]
+			// Skip optional proximity suffix such as "foo bar"~2
+			if strings.HasPrefix(tail, "~") {
+				n := 1
+				for n < len(tail) && tail[n] >= '0' && tail[n] <= '9' {
+					n++
+				}
+				tail = tail[n:]
+			}
+			return sb.String(), tail, nil
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return "", "", fmt.Errorf("missing closing quote in %s", s)
+}
+
+// readLuceneTerm reads the next term from s.
+//
+// It returns isField=true if the term ends with unescaped ':', e.g. it is a field name.
+func readLuceneTerm(s string) (string, bool, string, bool) {
+	var sb strings.Builder
+	hasWildcards := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				sb.WriteByte(s[i])
+			}
+		case ' ', '\t', '\r', '\n', '(', ')':
+			return sb.String(), hasWildcards, s[i:], false
+		case ':':
+			return sb.String(), hasWildcards, s[i+1:], true
+		case '*', '?':
+			hasWildcards = true
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), hasWildcards, "", false
+}
+
+type luceneParser struct {
+	tokens           []luceneToken
+	defaultOperator  string
+	currentTimestamp int64
+}
+
+// luceneClause is a single operand in Lucene boolean expression.
+type luceneClause struct {
+	filter   string
+	modifier byte
+}
+
+// parseExpr parses a sequence of clauses joined with AND, OR or the default operator.
+//
+// AND has higher priority than OR. Clauses with `+` modifier are required, while clauses with `-` modifier are prohibited.
+func (lp *luceneParser) parseExpr(defaultField string) (string, error) {
+	var orGroups [][]luceneClause
+	var andGroup []luceneClause
+	pendingOp := ""
+	for len(lp.tokens) > 0 {
+		t := lp.tokens[0]
+		if t.kind == luceneTokenCloseParen {
+			break
+		}
+		if t.kind == luceneTokenTerm && t.field == "" && t.modifier == 0 && (t.s == "AND" || t.s == "OR") {
+			if pendingOp != "" || len(andGroup) == 0 {
+				return "", fmt.Errorf("unexpected operator %s", t.s)
+			}
+			pendingOp = t.s
+			lp.tokens = lp.tokens[1:]
+			continue
+		}
+
+		c, err := lp.parseClause(defaultField)
+		if err != nil {
+			return "", err
+		}
+
+		op := pendingOp
+		if op == "" {
+			op = lp.defaultOperator
+		}
+		pendingOp = ""
+		if len(andGroup) > 0 && op == "OR" {
+			orGroups = append(orGroups, andGroup)
+			andGroup = nil
+		}
+		andGroup = append(andGroup, c)
+	}
+	if pendingOp != "" {
+		return "", fmt.Errorf("missing operand after %s", pendingOp)
+	}
+	if len(andGroup) > 0 {
+		orGroups = append(orGroups, andGroup)
+	}
+
+	var required, prohibited, optional []string
+	for _, g := range orGroups {
+		if len(g) == 1 {
+			c := g[0]
+			switch c.modifier {
+			case '+':
+				required = append(required, c.filter)
+			case '-':
+				prohibited = append(prohibited, c.filter)
+			default:
+				optional = append(optional, c.filter)
+			}
+			continue
+		}
+		var filters []string
+		for _, c := range g {
+			if c.modifier == '-' {
+				filters = append(filters, "!"+c.filter)
+			} else {
+				filters = append(filters, c.filter)
+			}
+		}
+		optional = append(optional, "("+strings.Join(filters, " ")+")")
+	}
+
+	var filters []string
+	filters = append(filters, required...)
+	if len(required) == 0 && len(optional) > 0 {
+		// Optional clauses are ignored if required clauses are present, since they affect only scoring.
+		if len(optional) == 1 {
+			filters = append(filters, optional[0])
+		} else {
+			filters = append(filters, "("+strings.Join(optional, " OR ")+")")
+		}
+	}
+	for _, f := range prohibited {
+		filters = append(filters, "!"+f)
+	}
+	if len(filters) == 0 {
+		return "", nil
+	}
+	return strings.Join(filters, " "), nil
+}
+
+func (lp *luceneParser) parseClause(defaultField string) (luceneClause, error) {
+	t := lp.tokens[0]
+	lp.tokens = lp.tokens[1:]
+
+	if t.kind == luceneTokenTerm && t.field == "" && t.modifier == 0 && t.s == "NOT" {
+		if len(lp.tokens) == 0 {
+			return luceneClause{}, fmt.Errorf("missing operand after NOT")
+		}
+		c, err := lp.parseClause(defaultField)
+		if err != nil {
+			return c, err
+		}
+		if c.modifier == '-' {
+			c.modifier = 0
+		} else {
+			c.filter = "!" + c.filter
+		}
+		return c, nil
+	}
+
+	field := t.field
+	if field == "" {
+		field = defaultField
+	}
+
+	switch t.kind {
+	case luceneTokenOpenParen:
+		f, err := lp.parseExpr(field)
+		if err != nil {
+			return luceneClause{}, err
+		}
+		if len(lp.tokens) == 0 || lp.tokens[0].kind != luceneTokenCloseParen {
+			return luceneClause{}, fmt.Errorf("missing closing parenthesis")
+		}
+		lp.tokens = lp.tokens[1:]
+		if f == "" {
+			f = "*"
+		}
+		return luceneClause{
+			filter:   "(" + f + ")",
+			modifier: t.modifier,
+		}, nil
+	case luceneTokenCloseParen:
+		return luceneClause{}, fmt.Errorf("unexpected closing parenthesis")
+	case luceneTokenPhrase:
+		return luceneClause{
+			filter:   luceneFieldPrefix(field) + strconv.Quote(t.s),
+			modifier: t.modifier,
+		}, nil
+	case luceneTokenRange:
+		f, err := convertLuceneRange(t.s, field, lp.currentTimestamp)
+		if err != nil {
+			return luceneClause{}, err
+		}
+		return luceneClause{
+			filter:   f,
+			modifier: t.modifier,
+		}, nil
+	default:
+		f, err := convertLuceneTerm(t, field, lp.currentTimestamp)
+		if err != nil {
+			return luceneClause{}, err
+		}
+		return luceneClause{
+			filter:   f,
+			modifier: t.modifier,
+		}, nil
+	}
+}
+
+func convertLuceneTerm(t luceneToken, field string, currentTimestamp int64) (string, error) {
+	s := t.s
+	if t.field == "_exists_" {
+		return quoteFieldName(s) + ":*", nil
+	}
+	if s == "*" {
+		if field == "" {
+			return "*", nil
+		}
+		return quoteFieldName(field) + ":*", nil
+	}
+
+	for _, op := range []string{">=", "<=", ">", "<"} {
+		if !strings.HasPrefix(s, op) || field == "" {
+			continue
+		}
+		var rb rangeBounds
+		v := s[len(op):]
+		switch op {
+		case ">=":
+			rb.set("gte", v)
+		case "<=":
+			rb.set("lte", v)
+		case ">":
+			rb.set("gt", v)
+		case "<":
+			rb.set("lt", v)
+		}
+		return convertLuceneRangeBounds(&rb, field, currentTimestamp)
+	}
+
+	prefix := luceneFieldPrefix(field)
+	if !t.hasWildcards {
+		return prefix + strconv.Quote(s), nil
+	}
+	if n := strings.IndexAny(s, "*?"); n == len(s)-1 && s[n] == '*' {
+		return prefix + strconv.Quote(s[:n]) + "*", nil
+	}
+	return prefix + "~" + strconv.Quote(wildcardToRegexp(s)), nil
+}
+
+func convertLuceneRange(s, field string, currentTimestamp int64) (string, error) {
+	inner := strings.TrimSpace(s[1 : len(s)-1])
+	n := strings.Index(inner, " TO ")
+	if n < 0 {
+		return "", fmt.Errorf("missing TO in range query %q", s)
+	}
+	lower := strings.TrimSpace(inner[:n])
+	upper := strings.TrimSpace(inner[n+len(" TO "):])
+
+	var rb rangeBounds
+	if lower != "*" {
+		if s[0] == '[' {
+			rb.set("gte", lower)
+		} else {
+			rb.set("gt", lower)
+		}
+	}
+	if upper != "*" {
+		if s[len(s)-1] == ']' {
+			rb.set("lte", upper)
+		} else {
+			rb.set("lt", upper)
+		}
+	}
+	return convertLuceneRangeBounds(&rb, field, currentTimestamp)
+}
+
+func convertLuceneRangeBounds(rb *rangeBounds, field string, currentTimestamp int64) (string, error) {
+	lower := strings.Trim(rb.lower, `"`)
+	upper := strings.Trim(rb.upper, `"`)
+	if isTimeField(field) {
+		if lower != "" {
+			nsecs, err := parseTimeString(lower, "", currentTimestamp)
+			if err != nil {
+				return "", fmt.Errorf("cannot parse lower time bound %q: %w", lower, err)
+			}
+			rb.lower = formatTimestamp(nsecs)
+		}
+		if upper != "" {
+			nsecs, err := parseTimeString(upper, "", currentTimestamp)
+			if err != nil {
+				return "", fmt.Errorf("cannot parse upper time bound %q: %w", upper, err)
+			}
+			rb.upper = formatTimestamp(nsecs)
+		}
+		return rb.timeFilter(), nil
+	}
+
+	for _, v := range []string{lower, upper} {
+		if v == "" {
+			continue
+		}
+		if _, err := strconv.ParseFloat(v, 64); err != nil {
+			return "", fmt.Errorf("range query on field %q supports only numeric values; got %q", field, v)
+		}
+	}
+	rb.lower = lower
+	rb.upper = upper
+	return rb.numericFilter(quoteFieldName(field)), nil
+}
+
+func luceneFieldPrefix(field string) string {
+	if field == "" {
+		return ""
+	}
+	return quoteFieldName(field) + ":"
+}
@@ -0,0 +1,175 @@
+package elasticsearch
+
+import (
+	"testing"
+
+	"github.com/valyala/fastjson"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/logstorage"
+)
+
+func TestConvertQueryToFilter_Success(t *testing.T) {
+	// 2024-01-02T03:04:05Z
+	const currentTimestamp = 1704164645 * 1e9
+
+	f := func(query, resultExpected string) {
+		t.Helper()
+
+		v, err := fastjson.Parse(query)
+		if err != nil {
+			t.Fatalf("cannot parse query: %s", err)
+		}
+		filterStr, err := convertQueryToFilter(v, currentTimestamp)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		// Verify that the generated filter is a valid LogsQL filter
+		lf, err := logstorage.ParseFilter(filterStr)
+		if err != nil {
+			t.Fatalf("cannot parse the generated filter [%s]: %s", filterStr, err)
+		}
+		result := lf.String()
+		if result != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	f(`{"match_all":{}}`, `*`)
+	f(`{"match_none":{}}`, `!*`)
+
+	// term and terms
+	f(`{"term":{"level":"error"}}`, `level:=error`)
+	f(`{"term":{"status":{"value":404}}}`, `status:=404`)
+	f(`{"terms":{"host":["a","b c"]}}`, `host:in(a,"b c")`)
+
+	// match and match_phrase
+	f(`{"match":{"message":"foo bar"}}`, `foo or bar`)
+	f(`{"match":{"msg":{"query":"foo bar","operator":"and"}}}`, `msg:foo msg:bar`)
+	f(`{"match_phrase":{"message":"connection refused"}}`, `"connection refused"`)
+	f(`{"match_phrase":{"error.text":{"query":"disk full"}}}`, `error.text:"disk full"`)
+
+	// prefix, wildcard and exists
+	f(`{"prefix":{"path":"/api/"}}`, `path:="/api/"*`)
+	f(`{"wildcard":{"host":{"value":"web-*.example?"}}}`, `host:~"^web-.*\\.example.$"`)
+	f(`{"exists":{"field":"user"}}`, `user:*`)
+
+	// range
+	f(`{"range":{"duration":{"gte":10,"lt":20.5}}}`, `duration:range[10, 20.5)`)
+	f(`{"range":{"duration":{"gt":"10"}}}`, `duration:>10`)
+	f(`{"range":{"@timestamp":{"gte":"2024-01-01T00:00:00Z","lte":"2024-01-01T12:00:00Z","format":"strict_date_optional_time"}}}`,
+		`_time:[2024-01-01T00:00:00.000000000Z,2024-01-01T12:00:00.000000000Z]`)
+	f(`{"range":{"@timestamp":{"gte":1704067200000,"lt":1704070800000,"format":"epoch_millis"}}}`,
+		`_time:[2024-01-01T00:00:00.000000000Z,2024-01-01T01:00:00.000000000Z)`)
+	f(`{"range":{"_time":{"gt":"now-1h/m"}}}`, `_time:>2024-01-02T02:04:05.000000000Z`)
+
+	// bool
+	f(`{"bool":{"must":[{"term":{"a":"b"}}],"must_not":{"term":{"c":"d"}}}}`, `a:=b !c:=d`)
+	f(`{"bool":{"should":[{"term":{"a":"b"}},{"term":{"c":"d"}}]}}`, `a:=b or c:=d`)
+	f(`{"bool":{"filter":[{"term":{"a":"b"}}],"should":[{"term":{"c":"d"}}]}}`, `a:=b`)
+	f(`{"bool":{"filter":[{"term":{"a":"b"}}],"should":[{"term":{"c":"d"}}],"minimum_should_match":1}}`, `a:=b c:=d`)
+	f(`{"bool":{}}`, `*`)
+
+	// query_string
+	f(`{"query_string":{"query":"error AND status:500"}}`, `error status:500`)
+	f(`{"query_string":{"query":"foo bar","default_operator":"AND","default_field":"x"}}`, `x:foo x:bar`)
+}
+
+func TestConvertQueryToFilter_Failure(t *testing.T) {
+	f := func(query string) {
+		t.Helper()
+
+		v, err := fastjson.Parse(query)
+		if err != nil {
+			t.Fatalf("cannot parse query: %s", err)
+		}
+		_, err = convertQueryToFilter(v, 0)
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// unsupported query types
+	f(`{"fuzzy":{"user":"ki"}}`)
+	f(`{"geo_distance":{}}`)
+
+	// multiple query types
+	f(`{"match_all":{},"term":{"a":"b"}}`)
+
+	// invalid term query
+	f(`{"term":{"a":"b","c":"d"}}`)
+	f(`{"term":{"a":{"value":[1]}}}`)
+
+	// non-numeric range on non-time field
+	f(`{"range":{"host":{"gte":"a"}}}`)
+
+	// unsupported minimum_should_match
+	f(`{"bool":{"should":[{"term":{"a":"b"}}],"minimum_should_match":2}}`)
+}
+
+func TestConvertLuceneQuery_Success(t *testing.T) {
+	// 2024-01-02T03:04:05Z
+	const currentTimestamp = 1704164645 * 1e9
+
+	f := func(query, defaultOperator, resultExpected string) {
+		t.Helper()
+
+		filterStr, err := convertLuceneQuery(query, "", defaultOperator, currentTimestamp)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		lf, err := logstorage.ParseFilter(filterStr)
+		if err != nil {
+			t.Fatalf("cannot parse the generated filter [%s]: %s", filterStr, err)
+		}
+		result := lf.String()
+		if result != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	f(``, "OR", `*`)
+	f(`*`, "OR", `*`)
+	f(`error`, "OR", `error`)
+	f(`foo bar`, "OR", `foo or bar`)
+	f(`foo bar`, "AND", `foo bar`)
+	f(`foo AND bar OR baz`, "OR", `foo bar or baz`)
+	f(`foo && (bar || baz)`, "OR", `foo (bar or baz)`)
+	f(`NOT foo`, "OR", `!foo`)
+	f(`!foo bar`, "AND", `!foo bar`)
+	f(`+foo -bar baz`, "OR", `foo !bar`)
+	f(`"foo bar"~2`, "OR", `"foo bar"`)
+	f(`status:200`, "OR", `status:200`)
+	f(`status:(200 OR 404)`, "OR", `status:200 or status:404`)
+	f(`message:"disk full"`, "OR", `"disk full"`)
+	f(`host:web*`, "OR", `host:web*`)
+	f(`host:w?b-*-1`, "OR", `host:~"^w.b-.*-1$"`)
+	f(`_exists_:user`, "OR", `user:*`)
+	f(`user:*`, "OR", `user:*`)
+	f(`path:\/api\/v1`, "OR", `path:"/api/v1"`)
+	f(`duration:[10 TO 20}`, "OR", `duration:range[10, 20)`)
+	f(`duration:{* TO 20]`, "OR", `duration:<=20`)
+	f(`duration:>=5`, "OR", `duration:>=5`)
+	f(`@timestamp:[2024-01-01T00:00:00Z TO now]`, "OR", `_time:[2024-01-01T00:00:00.000000000Z,2024-01-02T03:04:05.000000000Z]`)
+}
+
+func TestConvertLuceneQuery_Failure(t *testing.T) {
+	f := func(query string) {
+		t.Helper()
+
+		_, err := convertLuceneQuery(query, "", "OR", 0)
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	f(`(foo`)
+	f(`foo)`)
+	f(`"foo`)
+	f(`foo AND`)
+	f(`AND foo`)
+	f(`foo:`)
+	f(`[1 TO 2]`)
+	f(`x:[1 2]`)
+	f(`host:[a TO b]`)
+}
@@ -2,6 +2,7 @@ package internalselect
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"net/http"
@@ -82,9 +83,15 @@ var requestHandlers = map[string]func(ctx context.Context, w http.ResponseWriter
 	"/internal/select/stream_field_values": processStreamFieldValuesRequest,
 	"/internal/select/streams":             processStreamsRequest,
 	"/internal/select/stream_ids":          processStreamIDsRequest,
+	"/internal/select/estimate":            processEstimateRequest,
+	"/internal/select/analyze":             processAnalyzeRequest,
+	"/internal/select/cardinality":         processStreamCardinalityRequest,
+	"/internal/select/tenants":             processTenantsUsageRequest,
 	"/internal/delete/run_task":            processDeleteRunTask,
+	"/internal/delete/run_update_task":     processUpdateRunTask,
 	"/internal/delete/stop_task":           processDeleteStopTask,
 	"/internal/delete/active_tasks":        processDeleteActiveTasks,
+	"/internal/delete/finished_tasks":      processDeleteFinishedTasks,
 }
 
 func processQueryRequest(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
@@ -337,8 +344,56 @@ func processDeleteRunTask(ctx context.Context, w http.ResponseWriter, r *http.Re
 		return fmt.Errorf("cannot unmarshal filter=%q: %w", fStr, err)
 	}
 
+	var dryRun bool
+	if err := getBoolFromRequest(&dryRun, r, "dry_run"); err != nil {
+		return err
+	}
+
 	// Execute the delete task
-	return vlstorage.DeleteRunTask(ctx, taskID, timestamp, tenantIDs, f)
+	return vlstorage.DeleteRunTask(ctx, taskID, timestamp, tenantIDs, f, dryRun)
+}
+
+func processUpdateRunTask(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	if err := checkProtocolVersion(r, netselect.UpdateRunTaskProtocolVersion); err != nil {
+		return err
+	}
+
+	// Parse query args
+	taskID := r.FormValue("task_id")
+	if taskID == "" {
+		return fmt.Errorf("missing task_id arg")
+	}
+
+	timestamp, err := getInt64FromRequest(r, "timestamp")
+	if err != nil {
+		return err
+	}
+
+	tenantIDsStr := r.FormValue("tenant_ids")
+	tenantIDs, err := logstorage.UnmarshalTenantIDsFromJSON([]byte(tenantIDsStr))
+	if err != nil {
+		return fmt.Errorf("cannot unmarshal tenant_ids=%q: %w", tenantIDsStr, err)
+	}
+
+	fStr := r.FormValue("filter")
+	f, err := logstorage.ParseFilter(fStr)
+	if err != nil {
+		return fmt.Errorf("cannot unmarshal filter=%q: %w", fStr, err)
+	}
+
+	ruStr := r.FormValue("update")
+	ru, err := logstorage.ParseRowsUpdate(ruStr)
+	if err != nil {
+		return fmt.Errorf("cannot unmarshal update=%q: %w", ruStr, err)
+	}
+
+	var dryRun bool
+	if err := getBoolFromRequest(&dryRun, r, "dry_run"); err != nil {
+		return err
+	}
+
+	// Execute the update task
+	return vlstorage.UpdateRunTask(ctx, taskID, timestamp, tenantIDs, f, ru, dryRun)
 }
 
 func processDeleteStopTask(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
@@ -364,6 +419,23 @@ func processDeleteActiveTasks(ctx context.Context, w http.ResponseWriter, r *htt
 		return err
 	}
 
+	return writeDeleteTasks(w, tasks)
+}
+
+func processDeleteFinishedTasks(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	if err := checkProtocolVersion(r, netselect.DeleteFinishedTasksProtocolVersion); err != nil {
+		return err
+	}
+
+	tasks, err := vlstorage.DeleteFinishedTasks(ctx)
+	if err != nil {
+		return err
+	}
+
+	return writeDeleteTasks(w, tasks)
+}
+
+func writeDeleteTasks(w http.ResponseWriter, tasks []*logstorage.DeleteTask) error {
 	data := logstorage.MarshalDeleteTasksToJSON(tasks)
 
 	w.Header().Set("Content-Type", "application/json")
@@ -375,6 +447,132 @@ func processDeleteActiveTasks(ctx context.Context, w http.ResponseWriter, r *htt
 	return nil
 }
 
+func processEstimateRequest(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	cp, err := getCommonParams(r, netselect.EstimateQueryProtocolVersion)
+	if err != nil {
+		return err
+	}
+
+	qctx := cp.NewQueryContext(ctx)
+	qe, err := vlstorage.EstimateQuery(qctx)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(qe)
+	if err != nil {
+		return fmt.Errorf("cannot marshal query estimate: %w", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("cannot send response to the client: %w", err)
+	}
+
+	return nil
+}
+
+func processAnalyzeRequest(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	cp, err := getCommonParams(r, netselect.AnalyzeQueryProtocolVersion)
+	if err != nil {
+		return err
+	}
+
+	qctx := cp.NewQueryContext(ctx)
+	qa, err := vlstorage.AnalyzeQuery(qctx)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(qa)
+	if err != nil {
+		return fmt.Errorf("cannot marshal query analysis: %w", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("cannot send response to the client: %w", err)
+	}
+	return nil
+}
+
+func processStreamCardinalityRequest(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	if err := checkProtocolVersion(r, netselect.StreamCardinalityProtocolVersion); err != nil {
+		return err
+	}
+
+	tenantIDsStr := r.FormValue("tenant_ids")
+	tenantIDs, err := logstorage.UnmarshalTenantIDsFromJSON([]byte(tenantIDsStr))
+	if err != nil {
+		return fmt.Errorf("cannot unmarshal tenant_ids=%q: %w", tenantIDsStr, err)
+	}
+
+	start, err := getInt64FromRequest(r, "start")
+	if err != nil {
+		return err
+	}
+	end, err := getInt64FromRequest(r, "end")
+	if err != nil {
+		return err
+	}
+	limit, err := getInt64FromRequest(r, "limit")
+	if err != nil {
+		return err
+	}
+
+	var allowPartialResponse bool
+	if err := getBoolFromRequest(&allowPartialResponse, r, "allow_partial_response"); err != nil {
+		return err
+	}
+
+	sc, err := vlstorage.GetStreamCardinality(ctx, tenantIDs, start, end, int(limit), allowPartialResponse)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(sc)
+	if err != nil {
+		return fmt.Errorf("cannot marshal stream cardinality: %w", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("cannot send response to the client: %w", err)
+	}
+	return nil
+}
+
+func processTenantsUsageRequest(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	if err := checkProtocolVersion(r, netselect.TenantsUsageProtocolVersion); err != nil {
+		return err
+	}
+
+	var allowPartialResponse bool
+	if err := getBoolFromRequest(&allowPartialResponse, r, "allow_partial_response"); err != nil {
+		return err
+	}
+
+	tu, err := vlstorage.GetTenantsUsage(ctx, allowPartialResponse)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(tu)
+	if err != nil {
+		return fmt.Errorf("cannot marshal tenants usage: %w", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("cannot send response to the client: %w", err)
+	}
+	return nil
+}
+
 type commonParams struct {
 	TenantIDs []logstorage.TenantID
 	Query     *logstorage.Query
@@ -385,12 +583,17 @@ type commonParams struct {
 	// Whether to allow partial response when some of vlstorage nodes are unavailable.
 	AllowPartialResponse bool
 
+	// Limits contains resource limits for the Query.
+	Limits logstorage.QueryLimits
+
 	// qs contains execution statistics for the Query.
 	qs logstorage.QueryStats
 }
 
 func (cp *commonParams) NewQueryContext(ctx context.Context) *logstorage.QueryContext {
-	return logstorage.NewQueryContext(ctx, &cp.qs, cp.TenantIDs, cp.Query, cp.AllowPartialResponse)
+	qctx := logstorage.NewQueryContext(ctx, &cp.qs, cp.TenantIDs, cp.Query, cp.AllowPartialResponse)
+	qctx.Limits = cp.Limits
+	return qctx
 }
 
 func (cp *commonParams) UpdatePerQueryStatsMetrics() {
@@ -429,6 +632,15 @@ func getCommonParams(r *http.Request, expectedProtocolVersion string) (*commonPa
 		return nil, err
 	}
 
+	maxBytesRead, err := getUint64FromRequest(r, "max_bytes_read")
+	if err != nil {
+		return nil, err
+	}
+	maxRowsProcessed, err := getUint64FromRequest(r, "max_rows_processed")
+	if err != nil {
+		return nil, err
+	}
+
 	cp := &commonParams{
 		TenantIDs: tenantIDs,
 		Query:     q,
@@ -436,6 +648,11 @@ func getCommonParams(r *http.Request, expectedProtocolVersion string) (*commonPa
 		DisableCompression: disableCompression,
 
 		AllowPartialResponse: allowPartialResponse,
+
+		Limits: logstorage.QueryLimits{
+			MaxBytesRead:     maxBytesRead,
+			MaxRowsProcessed: maxRowsProcessed,
+		},
 	}
 	return cp, nil
 }
@@ -490,6 +707,15 @@ func getInt64FromRequest(r *http.Request, argName string) (int64, error) {
 	return n, nil
 }
 
+func getUint64FromRequest(r *http.Request, argName string) (uint64, error) {
+	s := r.FormValue(argName)
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse %s=%q: %w", argName, s, err)
+	}
+	return n, nil
+}
+
 func getBoolFromRequest(dst *bool, r *http.Request, argName string) error {
 	s := r.FormValue(argName)
 	if s == "" {
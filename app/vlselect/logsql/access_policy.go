@@ -0,0 +1,289 @@
+package logsql
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/logstorage"
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/prefixfilter"
+)
+
+var (
+	accessPolicyConfigPath = flag.String("search.accessPolicyConfig", "", "Optional path to JSON file with access policies for querying APIs. "+
+		"Access policies may add mandatory filters to queries and hide the given fields from query results depending on the identity passed via -search.accessPolicyIdentityHeader; "+
+		"see https://docs.victoriametrics.com/victorialogs/querying/#access-policies")
+	accessPolicyIdentityHeader = flag.String("search.accessPolicyIdentityHeader", "VL-Auth-Identity", "HTTP request header with the identity for selecting the access policy "+
+		"from -search.accessPolicyConfig. The header must be set by a trusted auth proxy such as vmauth; "+
+		"see https://docs.victoriametrics.com/victorialogs/querying/#access-policies")
+)
+
+// accessPolicies contains policies loaded from -search.accessPolicyConfig.
+//
+// nil accessPolicies means that access policies are disabled.
+var accessPolicies map[string]*accessPolicy
+
+// MustInitAccessPolicies loads access policies from -search.accessPolicyConfig.
+func MustInitAccessPolicies() {
+	if *accessPolicyConfigPath == "" {
+		return
+	}
+	data, err := os.ReadFile(*accessPolicyConfigPath)
+	if err != nil {
+		logger.Fatalf("cannot read -search.accessPolicyConfig=%q: %s", *accessPolicyConfigPath, err)
+	}
+	aps, err := parseAccessPolicyConfig(data)
+	if err != nil {
+		logger.Fatalf("cannot parse -search.accessPolicyConfig=%q: %s", *accessPolicyConfigPath, err)
+	}
+	accessPolicies = aps
+	logger.Infof("loaded %d access policies from -search.accessPolicyConfig=%q", len(aps), *accessPolicyConfigPath)
+}
+
+// accessPolicyConfig is the config for -search.accessPolicyConfig.
+type accessPolicyConfig struct {
+	Policies []accessPolicyEntryConfig `json:"policies"`
+}
+
+// accessPolicyEntryConfig is the config for a single access policy.
+type accessPolicyEntryConfig struct {
+	// Identity is the identity the policy applies to. The "*" identity applies to requests without the matching policy.
+	Identity string `json:"identity"`
+
+	// Tenants contains tenants in the form accountID:projectID, which can be queried by the identity.
+	// All the tenants can be queried if Tenants is empty.
+	Tenants []string `json:"tenants,omitempty"`
+
+	// ExtraFilters contains filters in the format of extra_filters query arg, which are added to all the queries.
+	ExtraFilters []string `json:"extra_filters,omitempty"`
+
+	// ExtraStreamFilters contains filters in the format of extra_stream_filters query arg, which are added to all the queries.
+	ExtraStreamFilters []string `json:"extra_stream_filters,omitempty"`
+
+	// DenyFields contains field names and field name prefixes ending with '*', which must be hidden from query results.
+	DenyFields []string `json:"deny_fields,omitempty"`
+}
+
+type accessPolicy struct {
+	identity string
+
+	// tenantIDs is nil if all the tenants can be queried.
+	tenantIDs []logstorage.TenantID
+
+	// extraFilters and extraStreamFilters are parsed on every request, since the parsed filters may be modified by the query.
+	extraFilters       []string
+	extraStreamFilters []string
+
+	denyFields []string
+
+	// hiddenFields contains denyFields plus _stream field, since _stream value may contain the denied stream fields.
+	hiddenFields []string
+}
+
+func parseAccessPolicyConfig(data []byte) (map[string]*accessPolicy, error) {
+	var cfg accessPolicyConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	aps := make(map[string]*accessPolicy, len(cfg.Policies))
+	for i := range cfg.Policies {
+		pc := &cfg.Policies[i]
+		ap, err := newAccessPolicy(pc)
+		if err != nil {
+			return nil, fmt.Errorf("cannot initialize policy #%d: %w", i+1, err)
+		}
+		if _, ok := aps[ap.identity]; ok {
+			return nil, fmt.Errorf("duplicate policy for identity %q", ap.identity)
+		}
+		aps[ap.identity] = ap
+	}
+	return aps, nil
+}
+
+func newAccessPolicy(pc *accessPolicyEntryConfig) (*accessPolicy, error) {
+	if pc.Identity == "" {
+		return nil, fmt.Errorf("missing identity")
+	}
+
+	ap := &accessPolicy{
+		identity: pc.Identity,
+	}
+
+	for _, s := range pc.Tenants {
+		tenantID, err := logstorage.ParseTenantID(s)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse tenant %q for identity %q: %w", s, pc.Identity, err)
+		}
+		ap.tenantIDs = append(ap.tenantIDs, tenantID)
+	}
+
+	for _, s := range pc.ExtraFilters {
+		if _, err := parseExtraFilters(s); err != nil {
+			return nil, fmt.Errorf("cannot parse extra_filters %q for identity %q: %w", s, pc.Identity, err)
+		}
+	}
+	ap.extraFilters = pc.ExtraFilters
+
+	for _, s := range pc.ExtraStreamFilters {
+		if _, err := parseExtraStreamFilters(s); err != nil {
+			return nil, fmt.Errorf("cannot parse extra_stream_filters %q for identity %q: %w", s, pc.Identity, err)
+		}
+	}
+	ap.extraStreamFilters = pc.ExtraStreamFilters
+
+	for _, s := range pc.DenyFields {
+		if s == "" {
+			return nil, fmt.Errorf("deny_fields cannot contain empty field name for identity %q", pc.Identity)
+		}
+		if prefixfilter.MatchFilter(s, "_time") {
+			return nil, fmt.Errorf("deny_fields cannot hide _time field for identity %q", pc.Identity)
+		}
+	}
+	ap.denyFields = pc.DenyFields
+	if len(ap.denyFields) > 0 {
+		ap.hiddenFields = append(slices.Clone(ap.denyFields), "_stream")
+	}
+
+	return ap, nil
+}
+
+// getAccessPolicy returns the access policy for r.
+//
+// It returns nil if access policies are disabled.
+func getAccessPolicy(r *http.Request) (*accessPolicy, error) {
+	if accessPolicies == nil {
+		return nil, nil
+	}
+
+	identity := r.Header.Get(*accessPolicyIdentityHeader)
+	if identity != "" {
+		if ap, ok := accessPolicies[identity]; ok {
+			return ap, nil
+		}
+	}
+	if ap, ok := accessPolicies["*"]; ok {
+		return ap, nil
+	}
+	return nil, &httpserver.ErrorWithStatusCode{
+		Err:        fmt.Errorf("missing access policy for identity %q passed via %q header", identity, *accessPolicyIdentityHeader),
+		StatusCode: http.StatusForbidden,
+	}
+}
+
+// ApplyAccessPolicy applies the access policy from -search.accessPolicyConfig for the given r to q.
+//
+// It returns the list of field filters, which must be hidden from the query results.
+//
+// See https://docs.victoriametrics.com/victorialogs/querying/#access-policies
+func ApplyAccessPolicy(q *logstorage.Query, r *http.Request, tenantIDs []logstorage.TenantID) ([]string, error) {
+	ap, err := getAccessPolicy(r)
+	if err != nil {
+		return nil, err
+	}
+	if ap == nil {
+		return nil, nil
+	}
+
+	if err := ap.checkTenantIDs(tenantIDs); err != nil {
+		return nil, err
+	}
+
+	// The stream_context pipe returns surrounding logs from the matching streams without applying query filters to them,
+	// so it could return logs, which must be filtered out by extra_filters. It must be the first pipe in the query,
+	// so it cannot go after the pipe for hiding fields.
+	if (len(ap.extraFilters) > 0 || len(ap.denyFields) > 0) && q.HasStreamContextPipe() {
+		return nil, &httpserver.ErrorWithStatusCode{
+			Err:        fmt.Errorf("stream_context pipe cannot be used by identity %q with extra_filters or deny_fields", ap.identity),
+			StatusCode: http.StatusForbidden,
+		}
+	}
+
+	// Verify hidden fields before adding extra filters, since extra filters from the policy may reference hidden fields.
+	if q.HasFiltersByFields(ap.denyFields) {
+		return nil, &httpserver.ErrorWithStatusCode{
+			Err:        fmt.Errorf("the query cannot filter by fields hidden for identity %q: %q", ap.identity, ap.denyFields),
+			StatusCode: http.StatusForbidden,
+		}
+	}
+	q.HideFields(ap.hiddenFields)
+
+	for _, s := range ap.extraFilters {
+		f, err := parseExtraFilters(s)
+		if err != nil {
+			logger.Panicf("BUG: cannot parse extra_filters %q verified at startup: %s", s, err)
+		}
+		q.AddExtraFilters(f)
+	}
+	for _, s := range ap.extraStreamFilters {
+		f, err := parseExtraStreamFilters(s)
+		if err != nil {
+			logger.Panicf("BUG: cannot parse extra_stream_filters %q verified at startup: %s", s, err)
+		}
+		q.AddExtraFilters(f)
+	}
+
+	return ap.denyFields, nil
+}
+
+func (ap *accessPolicy) checkTenantIDs(tenantIDs []logstorage.TenantID) error {
+	if ap.tenantIDs == nil {
+		return nil
+	}
+	for _, tenantID := range tenantIDs {
+		if !slices.Contains(ap.tenantIDs, tenantID) {
+			return &httpserver.ErrorWithStatusCode{
+				Err:        fmt.Errorf("identity %q cannot query tenant %s", ap.identity, tenantID.String()),
+				StatusCode: http.StatusForbidden,
+			}
+		}
+	}
+	return nil
+}
+
+// checkStreamsAccess returns an error if the access policy for r hides some fields.
+//
+// It is used by APIs returning _stream values and stream fields, since the hidden _stream field cannot be used there.
+func checkStreamsAccess(r *http.Request, hiddenFields []string) error {
+	if len(hiddenFields) == 0 {
+		return nil
+	}
+	ap, err := getAccessPolicy(r)
+	if err != nil {
+		return err
+	}
+	return &httpserver.ErrorWithStatusCode{
+		Err:        fmt.Errorf("%s cannot be queried by identity %q with deny_fields, since log streams may contain the hidden fields", r.URL.Path, ap.identity),
+		StatusCode: http.StatusForbidden,
+	}
+}
+
+// checkStreamCardinalityAccess verifies whether stream cardinality for the given tenantIDs can be queried with the access policy for r.
+//
+// It returns stream field filters, which must be hidden from the stream cardinality.
+// The stream cardinality is calculated from indexdb, so it cannot be limited by extra_filters and extra_stream_filters from the policy.
+func checkStreamCardinalityAccess(r *http.Request, tenantIDs []logstorage.TenantID) ([]string, error) {
+	ap, err := getAccessPolicy(r)
+	if err != nil {
+		return nil, err
+	}
+	if ap == nil {
+		return nil, nil
+	}
+	if err := ap.checkTenantIDs(tenantIDs); err != nil {
+		return nil, err
+	}
+	if len(ap.extraFilters) > 0 || len(ap.extraStreamFilters) > 0 {
+		return nil, &httpserver.ErrorWithStatusCode{
+			Err:        fmt.Errorf("stream cardinality cannot be queried by identity %q with extra_filters or extra_stream_filters", ap.identity),
+			StatusCode: http.StatusForbidden,
+		}
+	}
+	return ap.denyFields, nil
+}
@@ -0,0 +1,293 @@
+package logsql
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/logstorage"
+)
+
+func TestParseAccessPolicyConfig_Failure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+
+		if _, err := parseAccessPolicyConfig([]byte(data)); err == nil {
+			t.Fatalf("expecting non-nil error for %s", data)
+		}
+	}
+
+	// invalid JSON
+	f(`foo`)
+	f(`{"policies":{}}`)
+
+	// missing identity
+	f(`{"policies":[{"deny_fields":["foo"]}]}`)
+
+	// duplicate identity
+	f(`{"policies":[{"identity":"a"},{"identity":"a"}]}`)
+
+	// invalid tenant
+	f(`{"policies":[{"identity":"a","tenants":["foo"]}]}`)
+
+	// invalid extra_filters
+	f(`{"policies":[{"identity":"a","extra_filters":["foo:("]}]}`)
+
+	// invalid extra_stream_filters
+	f(`{"policies":[{"identity":"a","extra_stream_filters":["{foo"]}]}`)
+
+	// invalid deny_fields
+	f(`{"policies":[{"identity":"a","deny_fields":[""]}]}`)
+	f(`{"policies":[{"identity":"a","deny_fields":["_time"]}]}`)
+	f(`{"policies":[{"identity":"a","deny_fields":["*"]}]}`)
+}
+
+func TestApplyAccessPolicy_Success(t *testing.T) {
+	f := func(config, identity, tenant, qStr, resultExpected string) {
+		t.Helper()
+
+		aps, err := parseAccessPolicyConfig([]byte(config))
+		if err != nil {
+			t.Fatalf("cannot parse config: %s", err)
+		}
+		accessPolicies = aps
+		defer func() {
+			accessPolicies = nil
+		}()
+
+		r, err := http.NewRequest(http.MethodGet, "http://localhost/select/logsql/query", nil)
+		if err != nil {
+			t.Fatalf("cannot create request: %s", err)
+		}
+		if identity != "" {
+			r.Header.Set("VL-Auth-Identity", identity)
+		}
+		tenantID, err := logstorage.ParseTenantID(tenant)
+		if err != nil {
+			t.Fatalf("cannot parse tenant: %s", err)
+		}
+
+		q, err := logstorage.ParseQuery(qStr)
+		if err != nil {
+			t.Fatalf("cannot parse query: %s", err)
+		}
+		if _, err := ApplyAccessPolicy(q, r, []logstorage.TenantID{tenantID}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		result := q.String()
+		if result != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	config := `{"policies":[
+		{"identity":"support","tenants":["0:0"],"extra_filters":["-_stream:{app=\"payments\"}"],"deny_fields":["user.email"]},
+		{"identity":"*","extra_stream_filters":["{env=\"dev\"}"]}
+	]}`
+
+	// policy for the given identity
+	f(config, "support", "0:0", `error | stats count()`, `!{app="payments"} error | delete user.email, _stream | stats count(*) as "count(*)"`)
+
+	// the default policy for unknown identity
+	f(config, "foo", "1:0", `error`, `{env="dev"} error`)
+
+	// the default policy for missing identity
+	f(config, "", "1:0", `error`, `{env="dev"} error`)
+
+	// stream_context is allowed for policies without extra_filters and deny_fields
+	f(`{"policies":[{"identity":"support","tenants":["0:0"],"extra_stream_filters":["{env=\"dev\"}"]}]}`, "support", "0:0", `error | stream_context before 5`, `{env="dev"} error | stream_context before 5`)
+
+	// policy filters by hidden fields
+	f(`{"policies":[{"identity":"support","extra_filters":["user.email:*"],"deny_fields":["user.*"]}]}`, "support", "0:0", `error`, `user.email:* error | delete user.*, _stream`)
+}
+
+func TestApplyAccessPolicy_Failure(t *testing.T) {
+	f := func(config, identity, tenant, qStr string) {
+		t.Helper()
+
+		aps, err := parseAccessPolicyConfig([]byte(config))
+		if err != nil {
+			t.Fatalf("cannot parse config: %s", err)
+		}
+		accessPolicies = aps
+		defer func() {
+			accessPolicies = nil
+		}()
+
+		r, err := http.NewRequest(http.MethodGet, "http://localhost/select/logsql/query", nil)
+		if err != nil {
+			t.Fatalf("cannot create request: %s", err)
+		}
+		if identity != "" {
+			r.Header.Set("VL-Auth-Identity", identity)
+		}
+		tenantID, err := logstorage.ParseTenantID(tenant)
+		if err != nil {
+			t.Fatalf("cannot parse tenant: %s", err)
+		}
+
+		q, err := logstorage.ParseQuery(qStr)
+		if err != nil {
+			t.Fatalf("cannot parse query: %s", err)
+		}
+		if _, err := ApplyAccessPolicy(q, r, []logstorage.TenantID{tenantID}); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	config := `{"policies":[{"identity":"support","tenants":["0:0"],"deny_fields":["user.email"]}]}`
+
+	// missing policy for the identity
+	f(config, "foo", "0:0", `error`)
+	f(config, "", "0:0", `error`)
+
+	// forbidden tenant
+	f(config, "support", "1:0", `error`)
+
+	// filter by hidden field
+	f(config, "support", "0:0", `user.email:foo`)
+
+	// stream filter by hidden field
+	f(config, "support", "0:0", `{user.email="foo"} error`)
+	f(config, "support", "0:0", `_stream:{app="x" or user.email=~"foo.+"}`)
+
+	// stream_context with deny_fields
+	f(config, "support", "0:0", `error | stream_context before 5`)
+	f(config, "support", "0:0", `* | union (error | stream_context after 2)`)
+
+	// stream_context with extra_filters
+	f(`{"policies":[{"identity":"support","extra_filters":["-app:payments"]}]}`, "support", "0:0", `error | stream_context before 5`)
+}
+
+func TestProcessQueryRequestAccessPolicyStreamContext(t *testing.T) {
+	f := func(config, qStr string, statusCodeExpected int) {
+		t.Helper()
+
+		aps, err := parseAccessPolicyConfig([]byte(config))
+		if err != nil {
+			t.Fatalf("cannot parse config: %s", err)
+		}
+		accessPolicies = aps
+		defer func() {
+			accessPolicies = nil
+		}()
+
+		reqURL := "http://localhost/select/logsql/query?query=" + url.QueryEscape(qStr)
+		r, err := http.NewRequest(http.MethodGet, reqURL, nil)
+		if err != nil {
+			t.Fatalf("cannot create request: %s", err)
+		}
+		r.Header.Set("VL-Auth-Identity", "support")
+
+		w := httptest.NewRecorder()
+		ProcessQueryRequest(context.Background(), w, r)
+		if w.Code != statusCodeExpected {
+			t.Fatalf("unexpected status code; got %d; want %d; response: %s", w.Code, statusCodeExpected, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), "stream_context") {
+			t.Fatalf("the response must mention stream_context pipe; got %s", w.Body.String())
+		}
+	}
+
+	// deny_fields
+	f(`{"policies":[{"identity":"support","deny_fields":["user.email"]}]}`, `error | stream_context before 5`, http.StatusForbidden)
+
+	// extra_filters
+	f(`{"policies":[{"identity":"support","extra_filters":["-app:payments"]}]}`, `error | stream_context after 2`, http.StatusForbidden)
+}
+
+func TestCheckStreamsAccess(t *testing.T) {
+	f := func(identity string, resultExpected bool) {
+		t.Helper()
+
+		aps, err := parseAccessPolicyConfig([]byte(`{"policies":[
+			{"identity":"support","deny_fields":["user.email"]},
+			{"identity":"admin","extra_filters":["error"]}
+		]}`))
+		if err != nil {
+			t.Fatalf("cannot parse config: %s", err)
+		}
+		accessPolicies = aps
+		defer func() {
+			accessPolicies = nil
+		}()
+
+		r, err := http.NewRequest(http.MethodGet, "http://localhost/select/logsql/streams", nil)
+		if err != nil {
+			t.Fatalf("cannot create request: %s", err)
+		}
+		r.Header.Set("VL-Auth-Identity", identity)
+
+		q, err := logstorage.ParseQuery("*")
+		if err != nil {
+			t.Fatalf("cannot parse query: %s", err)
+		}
+		hiddenFields, err := ApplyAccessPolicy(q, r, []logstorage.TenantID{{}})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		err = checkStreamsAccess(r, hiddenFields)
+		if result := err == nil; result != resultExpected {
+			t.Fatalf("unexpected result; got %v; want %v; err: %v", result, resultExpected, err)
+		}
+	}
+
+	// _stream is hidden for identities with deny_fields
+	f("support", false)
+
+	// _stream is visible for identities without deny_fields
+	f("admin", true)
+}
+
+func TestCheckStreamCardinalityAccess(t *testing.T) {
+	f := func(identity, tenant string, hiddenFieldsExpected []string, okExpected bool) {
+		t.Helper()
+
+		aps, err := parseAccessPolicyConfig([]byte(`{"policies":[
+			{"identity":"support","tenants":["0:0"],"deny_fields":["user.email"]},
+			{"identity":"dev","extra_stream_filters":["{env=\"dev\"}"]}
+		]}`))
+		if err != nil {
+			t.Fatalf("cannot parse config: %s", err)
+		}
+		accessPolicies = aps
+		defer func() {
+			accessPolicies = nil
+		}()
+
+		r, err := http.NewRequest(http.MethodGet, "http://localhost/select/logsql/stream_cardinality", nil)
+		if err != nil {
+			t.Fatalf("cannot create request: %s", err)
+		}
+		r.Header.Set("VL-Auth-Identity", identity)
+		tenantID, err := logstorage.ParseTenantID(tenant)
+		if err != nil {
+			t.Fatalf("cannot parse tenant: %s", err)
+		}
+
+		hiddenFields, err := checkStreamCardinalityAccess(r, []logstorage.TenantID{tenantID})
+		if ok := err == nil; ok != okExpected {
+			t.Fatalf("unexpected result; got %v; want %v; err: %v", ok, okExpected, err)
+		}
+		if !reflect.DeepEqual(hiddenFields, hiddenFieldsExpected) {
+			t.Fatalf("unexpected hidden fields\ngot\n%q\nwant\n%q", hiddenFields, hiddenFieldsExpected)
+		}
+	}
+
+	// allowed tenant
+	f("support", "0:0", []string{"user.email"}, true)
+
+	// forbidden tenant
+	f("support", "1:0", nil, false)
+
+	// policies with extra filters cannot be enforced on cardinality stats
+	f("dev", "0:0", nil, false)
+
+	// missing policy
+	f("foo", "0:0", nil, false)
+}
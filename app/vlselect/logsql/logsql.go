@@ -2,6 +2,7 @@ package logsql
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -69,6 +70,9 @@ func ProcessFacetsRequest(ctx context.Context, w http.ResponseWriter, r *http.Re
 	// from the real logs stored in the database.
 	ca.q.DropAllPipes()
 
+	// Restore the pipe for hidden fields dropped above.
+	ca.q.HideFields(ca.hiddenFields)
+
 	ca.q.AddFacetsPipe(limit, maxValuesPerField, maxValueLen, keepConstFields)
 
 	var mLock sync.Mutex
@@ -149,6 +153,10 @@ func ProcessHitsRequest(ctx context.Context, w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	if processDryRunRequest(ctx, w, r, ca) {
+		return
+	}
+
 	// Obtain step
 	stepStr := r.FormValue("step")
 	if stepStr == "" {
@@ -322,6 +330,153 @@ func (hs *hitsSeries) Less(i, j int) bool {
 	return hs.timestamps[i] < hs.timestamps[j]
 }
 
+// ProcessExplainRequest handles /select/logsql/explain request.
+//
+// See https://docs.victoriametrics.com/victorialogs/querying/#explaining-queries
+func ProcessExplainRequest(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	ca, err := parseCommonArgs(r)
+	if err != nil {
+		httpserver.Errorf(w, r, "%s", err)
+		return
+	}
+
+	startTime := time.Now()
+	plan := ca.q.Explain()
+	if httputil.GetBool(r, "analyze") {
+		qctx := ca.newQueryContext(ctx)
+		defer ca.updatePerQueryStatsMetrics()
+
+		plan.Analysis, err = vlstorage.AnalyzeQuery(qctx)
+		if err != nil {
+			httpserver.Errorf(w, r, "cannot analyze query [%s]: %s", ca.q, err)
+			return
+		}
+	}
+
+	data, err := json.Marshal(plan)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal query plan: %s", err)
+	}
+
+	// Write response headers
+	h := w.Header()
+
+	h.Set("Content-Type", "application/json")
+	writeRequestDuration(h, startTime)
+
+	// Write results
+	fmt.Fprintf(w, "%s", data)
+}
+
+// ProcessCardinalityRequest handles /select/logsql/cardinality request.
+//
+// See https://docs.victoriametrics.com/victorialogs/querying/#querying-stream-cardinality
+func ProcessCardinalityRequest(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	tenantID, err := logstorage.GetTenantIDFromRequest(r)
+	if err != nil {
+		httpserver.Errorf(w, r, "cannot obtain tenantID: %s", err)
+		return
+	}
+	tenantIDs := []logstorage.TenantID{tenantID}
+
+	hiddenFields, err := checkStreamCardinalityAccess(r, tenantIDs)
+	if err != nil {
+		httpserver.Errorf(w, r, "%s", err)
+		return
+	}
+
+	// Parse optional start and end args. Return stats for the current day by default.
+	end, endOK, err := getTimeNsec(r, "end")
+	if err != nil {
+		httpserver.Errorf(w, r, "%s", err)
+		return
+	}
+	if !endOK {
+		end = time.Now().UnixNano()
+	}
+	start, startOK, err := getTimeNsec(r, "start")
+	if err != nil {
+		httpserver.Errorf(w, r, "%s", err)
+		return
+	}
+	if !startOK {
+		start = end
+	}
+	if start > end {
+		httpserver.Errorf(w, r, "start=%s cannot exceed end=%s", timestampToString(start), timestampToString(end))
+		return
+	}
+
+	limit, err := getPositiveInt(r, "limit")
+	if err != nil {
+		httpserver.Errorf(w, r, "%s", err)
+		return
+	}
+	if limit == 0 {
+		limit = 10
+	}
+
+	allowPartialResponse := *allowPartialResponseFlag
+	if err := getBoolFromRequest(&allowPartialResponse, r, "allow_partial_response"); err != nil {
+		httpserver.Errorf(w, r, "%s", err)
+		return
+	}
+
+	startTime := time.Now()
+	sc, err := vlstorage.GetStreamCardinality(ctx, tenantIDs, start, end, limit, allowPartialResponse)
+	if err != nil {
+		httpserver.Errorf(w, r, "cannot obtain stream cardinality: %s", err)
+		return
+	}
+	sc.HideStreamFields(hiddenFields)
+
+	data, err := json.Marshal(sc)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal stream cardinality: %s", err)
+	}
+
+	// Write response headers
+	h := w.Header()
+
+	h.Set("Content-Type", "application/json")
+	writeRequestDuration(h, startTime)
+
+	// Write results
+	fmt.Fprintf(w, "%s", data)
+}
+
+// ProcessTenantsRequest handles /select/tenants request.
+//
+// See https://docs.victoriametrics.com/victorialogs/#tenants-usage
+func ProcessTenantsRequest(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	allowPartialResponse := *allowPartialResponseFlag
+	if err := getBoolFromRequest(&allowPartialResponse, r, "allow_partial_response"); err != nil {
+		httpserver.Errorf(w, r, "%s", err)
+		return
+	}
+
+	startTime := time.Now()
+	tu, err := vlstorage.GetTenantsUsage(ctx, allowPartialResponse)
+	if err != nil {
+		httpserver.Errorf(w, r, "cannot obtain tenants usage: %s", err)
+		return
+	}
+
+	data, err := json.Marshal(tu)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal tenants usage: %s", err)
+	}
+
+	// Write response headers
+	h := w.Header()
+
+	h.Set("Content-Type", "application/json")
+	writeRequestDuration(h, startTime)
+
+	// Write results
+	fmt.Fprintf(w, "%s", data)
+}
+
 // ProcessFieldNamesRequest handles /select/logsql/field_names request.
 //
 // See https://docs.victoriametrics.com/victorialogs/querying/#querying-field-names
@@ -407,6 +562,10 @@ func ProcessStreamFieldNamesRequest(ctx context.Context, w http.ResponseWriter,
 		httpserver.Errorf(w, r, "%s", err)
 		return
 	}
+	if err := checkStreamsAccess(r, ca.hiddenFields); err != nil {
+		httpserver.Errorf(w, r, "%s", err)
+		return
+	}
 
 	qctx := ca.newQueryContext(ctx)
 	defer ca.updatePerQueryStatsMetrics()
@@ -438,6 +597,10 @@ func ProcessStreamFieldValuesRequest(ctx context.Context, w http.ResponseWriter,
 		httpserver.Errorf(w, r, "%s", err)
 		return
 	}
+	if err := checkStreamsAccess(r, ca.hiddenFields); err != nil {
+		httpserver.Errorf(w, r, "%s", err)
+		return
+	}
 
 	// Parse fieldName query arg
 	fieldName := r.FormValue("field")
@@ -521,6 +684,10 @@ func ProcessStreamsRequest(ctx context.Context, w http.ResponseWriter, r *http.R
 		httpserver.Errorf(w, r, "%s", err)
 		return
 	}
+	if err := checkStreamsAccess(r, ca.hiddenFields); err != nil {
+		httpserver.Errorf(w, r, "%s", err)
+		return
+	}
 
 	// Parse limit query arg
 	limit, err := getPositiveInt(r, "limit")
@@ -762,6 +929,10 @@ func ProcessStatsQueryRangeRequest(ctx context.Context, w http.ResponseWriter, r
 		return
 	}
 
+	if processDryRunRequest(ctx, w, r, ca) {
+		return
+	}
+
 	// Obtain step
 	stepStr := r.FormValue("step")
 	if stepStr == "" {
@@ -779,6 +950,26 @@ func ProcessStatsQueryRangeRequest(ctx context.Context, w http.ResponseWriter, r
 		return
 	}
 
+	// Obtain fill mode for steps without logs
+	fm, err := parseStatsFillMode(r)
+	if err != nil {
+		httpserver.SendPrometheusError(w, r, err)
+		return
+	}
+
+	// Obtain the limit on the number of series per every stats result
+	seriesLimit, err := getPositiveInt(r, "series_limit")
+	if err != nil {
+		httpserver.SendPrometheusError(w, r, err)
+		return
+	}
+
+	// Obtain the time range for filling steps without logs before it is modified by the query execution.
+	fillStart, fillEnd := ca.q.GetFilterTimeRange()
+	if fillEnd == math.MaxInt64 {
+		fillEnd = ca.q.GetTimestamp()
+	}
+
 	// Obtain `by(...)` fields from the last `| stats` pipe in q.
 	// Add `_time:step` to the `by(...)` list.
 	byFields, err := ca.q.GetStatsByFieldsAddGroupingByTime(int64(step))
@@ -870,10 +1061,19 @@ func ProcessStatsQueryRangeRequest(ctx context.Context, w http.ResponseWriter, r
 		})
 		rows = append(rows, ss)
 	}
+	if seriesLimit > 0 {
+		rows = limitStatsSeries(rows, seriesLimit)
+	}
 	sort.Slice(rows, func(i, j int) bool {
 		return rows[i].key < rows[j].key
 	})
 
+	// Fill steps without logs
+	if err := fillStatsSeries(rows, fillStart, fillEnd, int64(step), fm); err != nil {
+		httpserver.SendPrometheusError(w, r, err)
+		return
+	}
+
 	// Write response headers
 	h := w.Header()
 
@@ -912,6 +1112,10 @@ func ProcessStatsQueryRequest(ctx context.Context, w http.ResponseWriter, r *htt
 		return
 	}
 
+	if processDryRunRequest(ctx, w, r, ca) {
+		return
+	}
+
 	// Obtain `by(...)` fields from the last `| stats` pipe in q.
 	byFields, err := ca.q.GetStatsByFields()
 	if err != nil {
@@ -996,6 +1200,10 @@ func ProcessQueryRequest(ctx context.Context, w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	if processDryRunRequest(ctx, w, r, ca) {
+		return
+	}
+
 	// Parse offset query arg
 	offset, err := getPositiveInt(r, "offset")
 	if err != nil {
@@ -1119,12 +1327,19 @@ type commonArgs struct {
 	minTimestamp int64
 	maxTimestamp int64
 
+	// hiddenFields contains field filters, which are hidden from query results by the access policy.
+	//
+	// See https://docs.victoriametrics.com/victorialogs/querying/#access-policies
+	hiddenFields []string
+
 	// qs contains query execution statistics.
 	qs logstorage.QueryStats
 }
 
 func (ca *commonArgs) newQueryContext(ctx context.Context) *logstorage.QueryContext {
-	return logstorage.NewQueryContext(ctx, &ca.qs, ca.tenantIDs, ca.q, ca.allowPartialResponse)
+	qctx := logstorage.NewQueryContext(ctx, &ca.qs, ca.tenantIDs, ca.q, ca.allowPartialResponse)
+	qctx.Limits = GetQueryLimits(ca.tenantIDs)
+	return qctx
 }
 
 func (ca *commonArgs) updatePerQueryStatsMetrics() {
@@ -1203,22 +1418,13 @@ func parseCommonArgsWithConfig(r *http.Request, skipMaxRangeCheck bool) (*common
 		q.AddTimeFilter(start, end)
 	}
 
-	// Parse optional extra_filters
-	for _, extraFiltersStr := range r.Form["extra_filters"] {
-		extraFilters, err := parseExtraFilters(extraFiltersStr)
-		if err != nil {
-			return nil, err
-		}
-		q.AddExtraFilters(extraFilters)
+	if err := AddExtraFiltersFromRequest(q, r); err != nil {
+		return nil, err
 	}
 
-	// Parse optional extra_stream_filters
-	for _, extraStreamFiltersStr := range r.Form["extra_stream_filters"] {
-		extraStreamFilters, err := parseExtraStreamFilters(extraStreamFiltersStr)
-		if err != nil {
-			return nil, err
-		}
-		q.AddExtraFilters(extraStreamFilters)
+	hiddenFields, err := ApplyAccessPolicy(q, r, tenantIDs)
+	if err != nil {
+		return nil, err
 	}
 
 	if minTimestamp == math.MinInt64 || maxTimestamp == math.MaxInt64 {
@@ -1255,10 +1461,36 @@ func parseCommonArgsWithConfig(r *http.Request, skipMaxRangeCheck bool) (*common
 
 		minTimestamp: minTimestamp,
 		maxTimestamp: maxTimestamp,
+
+		hiddenFields: hiddenFields,
 	}
 	return ca, nil
 }
 
+// AddExtraFiltersFromRequest adds optional extra_filters and extra_stream_filters query args from r to q.
+//
+// See https://docs.victoriametrics.com/victorialogs/querying/#extra-filters
+func AddExtraFiltersFromRequest(q *logstorage.Query, r *http.Request) error {
+	// Parse optional extra_filters
+	for _, extraFiltersStr := range r.Form["extra_filters"] {
+		extraFilters, err := parseExtraFilters(extraFiltersStr)
+		if err != nil {
+			return err
+		}
+		q.AddExtraFilters(extraFilters)
+	}
+
+	// Parse optional extra_stream_filters
+	for _, extraStreamFiltersStr := range r.Form["extra_stream_filters"] {
+		extraStreamFilters, err := parseExtraStreamFilters(extraStreamFiltersStr)
+		if err != nil {
+			return err
+		}
+		q.AddExtraFilters(extraStreamFilters)
+	}
+	return nil
+}
+
 func timestampToString(nsecs int64) string {
 	t := time.Unix(nsecs/1e9, nsecs%1e9).UTC()
 	return t.Format(time.RFC3339Nano)
@@ -0,0 +1,92 @@
+package logsql
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/logstorage"
+)
+
+var (
+	lookupTablesPath = flag.String("lookup.tablesPath", "", "Path to directory with lookup tables for lookup pipe. "+
+		"Lookup tables are disabled if this flag isn't set; see https://docs.victoriametrics.com/victorialogs/logsql/#lookup-pipe")
+	lookupMaxTableSize = flagutil.NewBytes("lookup.maxTableSize", 64*1024*1024, "The maximum size of a lookup table, which can be uploaded via /select/logsql/lookup_tables; "+
+		"see https://docs.victoriametrics.com/victorialogs/logsql/#lookup-pipe")
+)
+
+// InitLookupTables initializes lookup tables at -lookup.tablesPath.
+func InitLookupTables() {
+	logstorage.SetLookupTablesPath(*lookupTablesPath)
+}
+
+// ProcessLookupTablesRequest handles /select/logsql/lookup_tables request.
+//
+// GET request returns the list of lookup tables for the tenant, while POST request uploads the lookup table for the tenant.
+//
+// See https://docs.victoriametrics.com/victorialogs/logsql/#lookup-pipe
+func ProcessLookupTablesRequest(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := logstorage.GetTenantIDFromRequest(r)
+	if err != nil {
+		httpserver.Errorf(w, r, "cannot obtain tenantID: %s", err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		tis, err := logstorage.ListLookupTables(tenantID)
+		if err != nil {
+			httpserver.Errorf(w, r, "cannot list lookup tables: %s", err)
+			return
+		}
+		if tis == nil {
+			tis = []logstorage.LookupTableInfo{}
+		}
+		data, err := json.Marshal(map[string]any{
+			"tables": tis,
+		})
+		if err != nil {
+			httpserver.Errorf(w, r, "cannot marshal lookup tables: %s", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, "%s", data)
+	case http.MethodPost:
+		// Read args from the query string, since the request body contains the lookup table.
+		args := r.URL.Query()
+		name := args.Get("name")
+		if name == "" {
+			httpserver.Errorf(w, r, "missing `name` arg")
+			return
+		}
+		format := args.Get("format")
+		if format == "" {
+			format = "csv"
+		}
+
+		maxSize := lookupMaxTableSize.IntN()
+		data, err := io.ReadAll(io.LimitReader(r.Body, int64(maxSize)+1))
+		if err != nil {
+			httpserver.Errorf(w, r, "cannot read lookup table %q: %s", name, err)
+			return
+		}
+		if len(data) > maxSize {
+			httpserver.Errorf(w, r, "too big lookup table %q; it mustn't exceed -lookup.maxTableSize=%d bytes", name, maxSize)
+			return
+		}
+
+		if err := logstorage.WriteLookupTable(tenantID, name, format, data); err != nil {
+			httpserver.Errorf(w, r, "cannot write lookup table %q: %s", name, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"status":"ok"}`)
+	default:
+		httpserver.Errorf(w, r, "unsupported method %q; supported methods: GET, POST", r.Method)
+	}
+}
@@ -0,0 +1,164 @@
+package logsql
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httputil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/app/vlstorage"
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/logstorage"
+)
+
+var (
+	maxBytesReadPerQuery = flagutil.NewBytes("search.maxBytesReadPerQuery", 0, "The maximum number of bytes, which can be read from the storage by a single query. "+
+		"Queries exceeding this limit are aborted. Zero means no limit. The limit can be overridden per tenant via -search.tenantLimitsConfig; "+
+		"see https://docs.victoriametrics.com/victorialogs/querying/#resource-usage-limits")
+	maxRowsProcessedPerQuery = flag.Uint64("search.maxRowsProcessedPerQuery", 0, "The maximum number of log rows, which can be processed by a single query. "+
+		"Queries exceeding this limit are aborted. Zero means no limit. The limit can be overridden per tenant via -search.tenantLimitsConfig; "+
+		"see https://docs.victoriametrics.com/victorialogs/querying/#resource-usage-limits")
+	tenantLimitsConfigPath = flag.String("search.tenantLimitsConfig", "", "Optional path to JSON file with per-tenant overrides for -search.maxBytesReadPerQuery "+
+		"and -search.maxRowsProcessedPerQuery; see https://docs.victoriametrics.com/victorialogs/querying/#resource-usage-limits")
+)
+
+// tenantLimits contains per-tenant limits loaded from -search.tenantLimitsConfig.
+var tenantLimits map[logstorage.TenantID]*tenantLimitsEntryConfig
+
+// MustInitQueryLimits loads per-tenant query limits from -search.tenantLimitsConfig.
+func MustInitQueryLimits() {
+	if *tenantLimitsConfigPath == "" {
+		return
+	}
+	data, err := os.ReadFile(*tenantLimitsConfigPath)
+	if err != nil {
+		logger.Fatalf("cannot read -search.tenantLimitsConfig=%q: %s", *tenantLimitsConfigPath, err)
+	}
+	m, err := parseTenantLimitsConfig(data)
+	if err != nil {
+		logger.Fatalf("cannot parse -search.tenantLimitsConfig=%q: %s", *tenantLimitsConfigPath, err)
+	}
+	tenantLimits = m
+	logger.Infof("loaded query limits for %d tenants from -search.tenantLimitsConfig=%q", len(m), *tenantLimitsConfigPath)
+}
+
+// tenantLimitsConfig is the config for -search.tenantLimitsConfig.
+type tenantLimitsConfig struct {
+	Tenants []tenantLimitsEntryConfig `json:"tenants"`
+}
+
+// tenantLimitsEntryConfig contains query limits for a single tenant.
+//
+// Missing limits are obtained from the corresponding command-line flags.
+type tenantLimitsEntryConfig struct {
+	// Tenant is the tenant in the form accountID:projectID.
+	Tenant string `json:"tenant"`
+
+	// MaxBytesReadPerQuery overrides -search.maxBytesReadPerQuery for the tenant.
+	MaxBytesReadPerQuery *uint64 `json:"max_bytes_read_per_query,omitempty"`
+
+	// MaxRowsProcessedPerQuery overrides -search.maxRowsProcessedPerQuery for the tenant.
+	MaxRowsProcessedPerQuery *uint64 `json:"max_rows_processed_per_query,omitempty"`
+}
+
+func parseTenantLimitsConfig(data []byte) (map[logstorage.TenantID]*tenantLimitsEntryConfig, error) {
+	var cfg tenantLimitsConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	m := make(map[logstorage.TenantID]*tenantLimitsEntryConfig, len(cfg.Tenants))
+	for i := range cfg.Tenants {
+		tc := &cfg.Tenants[i]
+		if tc.Tenant == "" {
+			return nil, fmt.Errorf("missing tenant at entry #%d", i+1)
+		}
+		tenantID, err := logstorage.ParseTenantID(tc.Tenant)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse tenant at entry #%d: %w", i+1, err)
+		}
+		if _, ok := m[tenantID]; ok {
+			return nil, fmt.Errorf("duplicate limits for tenant %q", tc.Tenant)
+		}
+		m[tenantID] = tc
+	}
+	return m, nil
+}
+
+// GetQueryLimits returns resource limits for queries over the given tenantIDs.
+//
+// The smallest limits across tenantIDs are returned if tenantIDs contains multiple tenants.
+//
+// See https://docs.victoriametrics.com/victorialogs/querying/#resource-usage-limits
+func GetQueryLimits(tenantIDs []logstorage.TenantID) logstorage.QueryLimits {
+	defaultLimits := logstorage.QueryLimits{
+		MaxBytesRead:     uint64(maxBytesReadPerQuery.N),
+		MaxRowsProcessed: *maxRowsProcessedPerQuery,
+	}
+	if len(tenantIDs) == 0 {
+		return defaultLimits
+	}
+
+	var limits logstorage.QueryLimits
+	for i, tenantID := range tenantIDs {
+		tl := defaultLimits
+		if tc := tenantLimits[tenantID]; tc != nil {
+			if tc.MaxBytesReadPerQuery != nil {
+				tl.MaxBytesRead = *tc.MaxBytesReadPerQuery
+			}
+			if tc.MaxRowsProcessedPerQuery != nil {
+				tl.MaxRowsProcessed = *tc.MaxRowsProcessedPerQuery
+			}
+		}
+		if i == 0 {
+			limits = tl
+			continue
+		}
+		limits.MaxBytesRead = minLimit(limits.MaxBytesRead, tl.MaxBytesRead)
+		limits.MaxRowsProcessed = minLimit(limits.MaxRowsProcessed, tl.MaxRowsProcessed)
+	}
+	return limits
+}
+
+// minLimit returns the minimum of a and b, where zero means no limit.
+func minLimit(a, b uint64) uint64 {
+	if a == 0 {
+		return b
+	}
+	if b == 0 {
+		return a
+	}
+	return min(a, b)
+}
+
+// processDryRunRequest writes the estimated amounts of data, which must be scanned by the query at ca, to w if r contains dry_run=1 query arg.
+//
+// It returns false if r doesn't contain dry_run=1 query arg, so the query must be executed by the caller.
+//
+// See https://docs.victoriametrics.com/victorialogs/querying/#dry-run
+func processDryRunRequest(ctx context.Context, w http.ResponseWriter, r *http.Request, ca *commonArgs) bool {
+	if !httputil.GetBool(r, "dry_run") {
+		return false
+	}
+
+	qctx := ca.newQueryContext(ctx)
+	qe, err := vlstorage.EstimateQuery(qctx)
+	if err != nil {
+		httpserver.Errorf(w, r, "cannot estimate query [%s]: %s", ca.q, err)
+		return true
+	}
+
+	data, err := json.Marshal(qe)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal query estimate: %s", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, "%s", data)
+	return true
+}
@@ -0,0 +1,106 @@
+package logsql
+
+import (
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/logstorage"
+)
+
+func TestParseTenantLimitsConfig_Failure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+
+		if _, err := parseTenantLimitsConfig([]byte(data)); err == nil {
+			t.Fatalf("expecting non-nil error for %s", data)
+		}
+	}
+
+	// invalid JSON
+	f(`foo`)
+	f(`{"tenants":{}}`)
+	f(`{"tenants":[{"tenant":"0:0","max_bytes_read_per_query":-1}]}`)
+
+	// missing tenant
+	f(`{"tenants":[{"max_bytes_read_per_query":100}]}`)
+
+	// invalid tenant
+	f(`{"tenants":[{"tenant":"foo"}]}`)
+
+	// duplicate tenant
+	f(`{"tenants":[{"tenant":"1:0"},{"tenant":"1"}]}`)
+}
+
+func TestGetQueryLimits(t *testing.T) {
+	f := func(config string, maxBytesRead, maxRowsProcessed uint64, tenants []string, limitsExpected logstorage.QueryLimits) {
+		t.Helper()
+
+		m, err := parseTenantLimitsConfig([]byte(config))
+		if err != nil {
+			t.Fatalf("cannot parse config: %s", err)
+		}
+
+		origMaxBytesRead := maxBytesReadPerQuery.N
+		origMaxRowsProcessed := *maxRowsProcessedPerQuery
+		tenantLimits = m
+		maxBytesReadPerQuery.N = int64(maxBytesRead)
+		*maxRowsProcessedPerQuery = maxRowsProcessed
+		defer func() {
+			tenantLimits = nil
+			maxBytesReadPerQuery.N = origMaxBytesRead
+			*maxRowsProcessedPerQuery = origMaxRowsProcessed
+		}()
+
+		var tenantIDs []logstorage.TenantID
+		for _, s := range tenants {
+			tenantID, err := logstorage.ParseTenantID(s)
+			if err != nil {
+				t.Fatalf("cannot parse tenant %q: %s", s, err)
+			}
+			tenantIDs = append(tenantIDs, tenantID)
+		}
+
+		limits := GetQueryLimits(tenantIDs)
+		if limits != limitsExpected {
+			t.Fatalf("unexpected limits\ngot\n%+v\nwant\n%+v", limits, limitsExpected)
+		}
+	}
+
+	config := `{"tenants":[
+		{"tenant":"1:0","max_bytes_read_per_query":1000},
+		{"tenant":"2:0","max_rows_processed_per_query":0},
+		{"tenant":"3:0","max_bytes_read_per_query":500,"max_rows_processed_per_query":20}
+	]}`
+
+	// no limits
+	f(`{}`, 0, 0, []string{"0:0"}, logstorage.QueryLimits{})
+
+	// limits from command-line flags
+	f(`{}`, 100, 10, []string{"0:0"}, logstorage.QueryLimits{
+		MaxBytesRead:     100,
+		MaxRowsProcessed: 10,
+	})
+	f(config, 100, 10, nil, logstorage.QueryLimits{
+		MaxBytesRead:     100,
+		MaxRowsProcessed: 10,
+	})
+
+	// per-tenant overrides
+	f(config, 100, 10, []string{"1:0"}, logstorage.QueryLimits{
+		MaxBytesRead:     1000,
+		MaxRowsProcessed: 10,
+	})
+	f(config, 100, 10, []string{"2:0"}, logstorage.QueryLimits{
+		MaxBytesRead:     100,
+		MaxRowsProcessed: 0,
+	})
+
+	// the smallest limits across multiple tenants
+	f(config, 100, 10, []string{"1:0", "3:0"}, logstorage.QueryLimits{
+		MaxBytesRead:     500,
+		MaxRowsProcessed: 10,
+	})
+	f(config, 100, 10, []string{"2:0", "3:0"}, logstorage.QueryLimits{
+		MaxBytesRead:     100,
+		MaxRowsProcessed: 20,
+	})
+}
@@ -0,0 +1,248 @@
+package logsql
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/logstorage"
+)
+
+// maxFillPointsPerSeries is the maximum number of points per series, which can be generated by fill query arg.
+const maxFillPointsPerSeries = 100_000
+
+// otherSeriesLabelValue is the label value for the series with the merged series, which exceed series_limit.
+const otherSeriesLabelValue = "__other__"
+
+// statsFillMode is the mode for filling steps without logs at /select/logsql/stats_query_range.
+type statsFillMode string
+
+const (
+	// statsFillNone leaves steps without logs empty.
+	statsFillNone = statsFillMode("")
+
+	// statsFillZero fills steps without logs with zero.
+	statsFillZero = statsFillMode("zero")
+
+	// statsFillNull fills steps without logs with NaN.
+	statsFillNull = statsFillMode("null")
+
+	// statsFillPrevious fills steps without logs with the previous value in the series.
+	statsFillPrevious = statsFillMode("previous")
+
+	// statsFillLinear fills steps without logs with the value linearly interpolated between adjacent values in the series.
+	statsFillLinear = statsFillMode("linear")
+)
+
+func parseStatsFillMode(r *http.Request) (statsFillMode, error) {
+	s := r.FormValue("fill")
+	switch fm := statsFillMode(s); fm {
+	case statsFillNone, statsFillZero, statsFillNull, statsFillPrevious, statsFillLinear:
+		return fm, nil
+	default:
+		return statsFillNone, fmt.Errorf("unsupported 'fill' arg: %q; supported values: zero, null, previous, linear", s)
+	}
+}
+
+// limitStatsSeries returns up to seriesLimit series with the biggest sum of values per every stats result name from rows.
+//
+// The remaining series per every stats result name are merged into a single series with all the labels set to __other__.
+// Values for the merged series are calculated as the sum of values for the remaining series at every timestamp.
+func limitStatsSeries(rows []*statsSeries, seriesLimit int) []*statsSeries {
+	byName := make(map[string][]*statsSeries)
+	var names []string
+	for _, ss := range rows {
+		if _, ok := byName[ss.Name]; !ok {
+			names = append(names, ss.Name)
+		}
+		byName[ss.Name] = append(byName[ss.Name], ss)
+	}
+
+	result := make([]*statsSeries, 0, len(rows))
+	for _, name := range names {
+		series := byName[name]
+		if len(series) <= seriesLimit {
+			result = append(result, series...)
+			continue
+		}
+
+		sums := make(map[*statsSeries]float64, len(series))
+		for _, ss := range series {
+			sums[ss] = getStatsSeriesSum(ss)
+		}
+		sort.SliceStable(series, func(i, j int) bool {
+			a, b := sums[series[i]], sums[series[j]]
+			if a == b {
+				return series[i].key < series[j].key
+			}
+			return a > b
+		})
+
+		result = append(result, series[:seriesLimit]...)
+		result = append(result, mergeStatsSeries(name, series[seriesLimit:]))
+	}
+	return result
+}
+
+func getStatsSeriesSum(ss *statsSeries) float64 {
+	sum := float64(0)
+	for _, p := range ss.Points {
+		if v, ok := parseStatsValue(p.Value); ok {
+			sum += v
+		}
+	}
+	return sum
+}
+
+func mergeStatsSeries(name string, series []*statsSeries) *statsSeries {
+	labels := make([]logstorage.Field, len(series[0].Labels))
+	for i, label := range series[0].Labels {
+		labels[i] = logstorage.Field{
+			Name:  label.Name,
+			Value: otherSeriesLabelValue,
+		}
+	}
+
+	m := make(map[int64]float64)
+	for _, ss := range series {
+		for _, p := range ss.Points {
+			v, ok := parseStatsValue(p.Value)
+			if !ok {
+				continue
+			}
+			m[p.Timestamp] += v
+		}
+	}
+
+	points := make([]statsPoint, 0, len(m))
+	for ts, v := range m {
+		points = append(points, statsPoint{
+			Timestamp: ts,
+			Value:     formatStatsValue(v),
+		})
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Timestamp < points[j].Timestamp
+	})
+
+	key := string(logstorage.MarshalFieldsToJSON([]byte(name), labels))
+	return &statsSeries{
+		key:    key,
+		Name:   name,
+		Labels: labels,
+		Points: points,
+	}
+}
+
+// fillStatsSeries fills steps without values at rows according to fm.
+//
+// Every series in rows gets points at every step on the [start, end] time range, which are aligned to step,
+// unless the point cannot be filled according to fm.
+// The points in rows must be sorted by timestamp.
+func fillStatsSeries(rows []*statsSeries, start, end, step int64, fm statsFillMode) error {
+	if fm == statsFillNone || len(rows) == 0 {
+		return nil
+	}
+
+	// Open-bounded time range is limited by the timestamps of the selected points.
+	if start == math.MinInt64 {
+		start = math.MaxInt64
+		for _, ss := range rows {
+			if len(ss.Points) > 0 {
+				start = min(start, ss.Points[0].Timestamp)
+			}
+		}
+	}
+	if end == math.MaxInt64 {
+		end = math.MinInt64
+		for _, ss := range rows {
+			if len(ss.Points) > 0 {
+				end = max(end, ss.Points[len(ss.Points)-1].Timestamp)
+			}
+		}
+	}
+	if start > end {
+		return nil
+	}
+
+	start = truncateTimestampToStep(start, step)
+	end = truncateTimestampToStep(end, step)
+	if n := (end-start)/step + 1; n < 0 || n > maxFillPointsPerSeries {
+		return fmt.Errorf("too many points per series must be generated for fill=%s on the selected time range with step=%dns; "+
+			"the number of points cannot exceed %d; increase 'step' arg or narrow down the time range", fm, step, maxFillPointsPerSeries)
+	}
+
+	for _, ss := range rows {
+		ss.Points = fillStatsPoints(ss.Points, start, end, step, fm)
+	}
+	return nil
+}
+
+func fillStatsPoints(points []statsPoint, start, end, step int64, fm statsFillMode) []statsPoint {
+	dst := make([]statsPoint, 0, (end-start)/step+1)
+	i := 0
+	for ts := start; ts <= end; ts += step {
+		for i < len(points) && points[i].Timestamp < ts {
+			i++
+		}
+		if i < len(points) && points[i].Timestamp == ts {
+			dst = append(dst, points[i])
+			continue
+		}
+
+		var value string
+		switch fm {
+		case statsFillZero:
+			value = "0"
+		case statsFillNull:
+			value = "NaN"
+		case statsFillPrevious:
+			if i == 0 {
+				continue
+			}
+			value = points[i-1].Value
+		case statsFillLinear:
+			if i == 0 || i >= len(points) {
+				continue
+			}
+			prev, next := &points[i-1], &points[i]
+			prevValue, ok := parseStatsValue(prev.Value)
+			if !ok {
+				continue
+			}
+			nextValue, ok := parseStatsValue(next.Value)
+			if !ok {
+				continue
+			}
+			k := float64(ts-prev.Timestamp) / float64(next.Timestamp-prev.Timestamp)
+			value = formatStatsValue(prevValue + (nextValue-prevValue)*k)
+		}
+		dst = append(dst, statsPoint{
+			Timestamp: ts,
+			Value:     value,
+		})
+	}
+	return dst
+}
+
+func truncateTimestampToStep(ts, step int64) int64 {
+	r := ts % step
+	if r < 0 {
+		r += step
+	}
+	return ts - r
+}
+
+func parseStatsValue(s string) (float64, bool) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) {
+		return 0, false
+	}
+	return v, true
+}
+
+func formatStatsValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
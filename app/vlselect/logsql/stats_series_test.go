@@ -0,0 +1,127 @@
+package logsql
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/logstorage"
+)
+
+func TestFillStatsSeries(t *testing.T) {
+	f := func(points string, start, end, step int64, fm statsFillMode, resultExpected string) {
+		t.Helper()
+
+		ss := &statsSeries{
+			Name:   "count(*)",
+			Points: parseStatsPointsForTest(t, points),
+		}
+		if err := fillStatsSeries([]*statsSeries{ss}, start, end, step, fm); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		result := formatStatsPointsForTest(ss.Points)
+		if result != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	// no fill
+	f("10:1 40:4", 0, 50, 10, statsFillNone, "10:1 40:4")
+
+	// fill with zero
+	f("10:1 40:4", 0, 50, 10, statsFillZero, "0:0 10:1 20:0 30:0 40:4 50:0")
+
+	// fill with null
+	f("10:1 40:4", 0, 50, 10, statsFillNull, "0:NaN 10:1 20:NaN 30:NaN 40:4 50:NaN")
+
+	// fill with previous value
+	f("10:1 40:4", 0, 50, 10, statsFillPrevious, "10:1 20:1 30:1 40:4 50:4")
+
+	// fill with linear interpolation
+	f("10:1 40:4", 0, 50, 10, statsFillLinear, "10:1 20:2 30:3 40:4")
+	f("10:1 20:foo 40:4", 0, 50, 10, statsFillLinear, "10:1 20:foo 40:4")
+
+	// unaligned time range
+	f("10:1 30:3", 5, 38, 10, statsFillZero, "0:0 10:1 20:0 30:3")
+
+	// open-bounded time range
+	f("10:1 40:4", math.MinInt64, math.MaxInt64, 10, statsFillZero, "10:1 20:0 30:0 40:4")
+}
+
+func TestFillStatsSeries_Failure(t *testing.T) {
+	ss := &statsSeries{
+		Name: "count(*)",
+	}
+	if err := fillStatsSeries([]*statsSeries{ss}, 0, 1e9, 1, statsFillZero); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+}
+
+func TestLimitStatsSeries(t *testing.T) {
+	f := func(series []string, seriesLimit int, resultExpected string) {
+		t.Helper()
+
+		var rows []*statsSeries
+		for _, s := range series {
+			n := strings.Index(s, "=")
+			name, points := s[:n], s[n+1:]
+			labels := []logstorage.Field{
+				{
+					Name:  "host",
+					Value: name,
+				},
+			}
+			rows = append(rows, &statsSeries{
+				key:    string(logstorage.MarshalFieldsToJSON([]byte("hits"), labels)),
+				Name:   "hits",
+				Labels: labels,
+				Points: parseStatsPointsForTest(t, points),
+			})
+		}
+
+		rows = limitStatsSeries(rows, seriesLimit)
+
+		var a []string
+		for _, ss := range rows {
+			a = append(a, fmt.Sprintf("%s=%s", ss.Labels[0].Value, formatStatsPointsForTest(ss.Points)))
+		}
+		result := strings.Join(a, ", ")
+		if result != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	// the number of series doesn't exceed the limit
+	f([]string{"a=10:1", "b=10:2"}, 2, "a=10:1, b=10:2")
+
+	// the remaining series are merged into __other__ series
+	f([]string{"a=10:1 20:1", "b=10:5", "c=10:3 20:7", "d=20:2"}, 2, "c=10:3 20:7, b=10:5, __other__=10:1 20:3")
+	f([]string{"a=10:1", "b=10:2", "c=10:3"}, 1, "c=10:3, __other__=10:3")
+}
+
+func parseStatsPointsForTest(t *testing.T, s string) []statsPoint {
+	t.Helper()
+
+	var points []statsPoint
+	for _, item := range strings.Fields(s) {
+		n := strings.Index(item, ":")
+		var ts int64
+		if _, err := fmt.Sscanf(item[:n], "%d", &ts); err != nil {
+			t.Fatalf("cannot parse timestamp at %q: %s", item, err)
+		}
+		points = append(points, statsPoint{
+			Timestamp: ts,
+			Value:     item[n+1:],
+		})
+	}
+	return points
+}
+
+func formatStatsPointsForTest(points []statsPoint) string {
+	a := make([]string, len(points))
+	for i, p := range points {
+		a[i] = fmt.Sprintf("%d:%s", p.Timestamp, p.Value)
+	}
+	return strings.Join(a, " ")
+}
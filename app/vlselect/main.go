@@ -10,11 +10,13 @@ import (
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/cgroup"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httputil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/metrics"
 
+	"github.com/VictoriaMetrics/VictoriaLogs/app/vlselect/elasticsearch"
 	"github.com/VictoriaMetrics/VictoriaLogs/app/vlselect/internalselect"
 	"github.com/VictoriaMetrics/VictoriaLogs/app/vlselect/logsql"
 	"github.com/VictoriaMetrics/VictoriaLogs/app/vlstorage"
@@ -35,6 +37,9 @@ var (
 	enableDelete         = flag.Bool("delete.enable", false, "Whether to enable /delete/* HTTP endpoints; see https://docs.victoriametrics.com/victorialogs/#how-to-delete-logs")
 	enableInternalDelete = flag.Bool("internaldelete.enable", false, "Whether to enable /internal/delete/* HTTP endpoints, which are used by vlselect for deleting logs "+
 		"via delete API at vlstorage nodes; see https://docs.victoriametrics.com/victorialogs/#how-to-delete-logs")
+
+	tenantsAuthKey = flagutil.NewPassword("tenantsAuthKey", "authKey, which must be passed in query string to /select/tenants . It overrides -httpAuth.* . "+
+		"See https://docs.victoriametrics.com/victorialogs/#tenants-usage")
 )
 
 func getDefaultMaxConcurrentRequests() int {
@@ -55,6 +60,9 @@ func getDefaultMaxConcurrentRequests() int {
 func Init() {
 	concurrencyLimitCh = make(chan struct{}, *maxConcurrentRequests)
 
+	logsql.InitLookupTables()
+	logsql.MustInitAccessPolicies()
+	logsql.MustInitQueryLimits()
 	internalselect.Init()
 }
 
@@ -253,6 +261,24 @@ func processSelectRequest(ctx context.Context, w http.ResponseWriter, r *http.Re
 		logsql.ProcessFacetsRequest(ctx, w, r)
 		logsqlFacetsDuration.UpdateDuration(startTime)
 		return true
+	case "/select/logsql/cardinality":
+		logsqlCardinalityRequests.Inc()
+		logsql.ProcessCardinalityRequest(ctx, w, r)
+		logsqlCardinalityDuration.UpdateDuration(startTime)
+		return true
+	case "/select/tenants":
+		if !httpserver.CheckAuthFlag(w, r, tenantsAuthKey) {
+			return true
+		}
+		tenantsRequests.Inc()
+		logsql.ProcessTenantsRequest(ctx, w, r)
+		tenantsDuration.UpdateDuration(startTime)
+		return true
+	case "/select/logsql/explain":
+		logsqlExplainRequests.Inc()
+		logsql.ProcessExplainRequest(ctx, w, r)
+		logsqlExplainDuration.UpdateDuration(startTime)
+		return true
 	case "/select/logsql/field_names":
 		logsqlFieldNamesRequests.Inc()
 		logsql.ProcessFieldNamesRequest(ctx, w, r)
@@ -268,6 +294,11 @@ func processSelectRequest(ctx context.Context, w http.ResponseWriter, r *http.Re
 		logsql.ProcessHitsRequest(ctx, w, r)
 		logsqlHitsDuration.UpdateDuration(startTime)
 		return true
+	case "/select/logsql/lookup_tables":
+		logsqlLookupTablesRequests.Inc()
+		logsql.ProcessLookupTablesRequest(w, r)
+		logsqlLookupTablesDuration.UpdateDuration(startTime)
+		return true
 	case "/select/logsql/query":
 		logsqlQueryRequests.Inc()
 		logsql.ProcessQueryRequest(ctx, w, r)
@@ -303,9 +334,13 @@ func processSelectRequest(ctx context.Context, w http.ResponseWriter, r *http.Re
 		logsql.ProcessStreamsRequest(ctx, w, r)
 		logsqlStreamsDuration.UpdateDuration(startTime)
 		return true
-	default:
-		return false
 	}
+
+	if strings.HasPrefix(path, "/select/elasticsearch/") {
+		return elasticsearch.RequestHandler(ctx, w, r, path)
+	}
+
+	return false
 }
 
 func deleteHandler(w http.ResponseWriter, r *http.Request, path string) {
@@ -315,12 +350,18 @@ func deleteHandler(w http.ResponseWriter, r *http.Request, path string) {
 	case "/delete/run_task":
 		deleteRunTaskRequests.Inc()
 		processDeleteRunTaskRequest(ctx, w, r)
+	case "/delete/run_update_task":
+		deleteRunUpdateTaskRequests.Inc()
+		processDeleteRunUpdateTaskRequest(ctx, w, r)
 	case "/delete/stop_task":
 		deleteStopTaskRequests.Inc()
 		processDeleteStopTaskRequest(ctx, w, r)
 	case "/delete/active_tasks":
 		deleteActiveTasksRequests.Inc()
 		processDeleteActiveTasksRequest(ctx, w, r)
+	case "/delete/finished_tasks":
+		deleteFinishedTasksRequests.Inc()
+		processDeleteFinishedTasksRequest(ctx, w, r)
 	default:
 		httpserver.Errorf(w, r, "unsupported path requested: %q", path)
 	}
@@ -344,8 +385,10 @@ func processDeleteRunTaskRequest(ctx context.Context, w http.ResponseWriter, r *
 	timestamp := time.Now().UnixNano()
 	taskID := fmt.Sprintf("%d", timestamp)
 
+	dryRun := httputil.GetBool(r, "dry_run")
+
 	tenantIDs := []logstorage.TenantID{tenantID}
-	if err := vlstorage.DeleteRunTask(ctx, taskID, timestamp, tenantIDs, f); err != nil {
+	if err := vlstorage.DeleteRunTask(ctx, taskID, timestamp, tenantIDs, f, dryRun); err != nil {
 		httpserver.Errorf(w, r, "cannot run delete task: %s", err)
 		return
 	}
@@ -354,6 +397,47 @@ func processDeleteRunTaskRequest(ctx context.Context, w http.ResponseWriter, r *
 	fmt.Fprintf(w, `{"task_id":%q}`, taskID)
 }
 
+func processDeleteRunUpdateTaskRequest(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	tenantID, err := logstorage.GetTenantIDFromRequest(r)
+	if err != nil {
+		httpserver.Errorf(w, r, "cannot obtain tenantID: %s", err)
+		return
+	}
+
+	fStr := r.FormValue("filter")
+	f, err := logstorage.ParseFilter(fStr)
+	if err != nil {
+		httpserver.Errorf(w, r, "cannot parse filter [%s]: %s", fStr, err)
+		return
+	}
+
+	ruStr := r.FormValue("update")
+	if ruStr == "" {
+		httpserver.Errorf(w, r, "missing update arg")
+		return
+	}
+	ru, err := logstorage.ParseRowsUpdate(ruStr)
+	if err != nil {
+		httpserver.Errorf(w, r, "cannot parse update [%s]: %s", ruStr, err)
+		return
+	}
+
+	// Generate taskID from the current timestamp in nanoseconds
+	timestamp := time.Now().UnixNano()
+	taskID := fmt.Sprintf("%d", timestamp)
+
+	dryRun := httputil.GetBool(r, "dry_run")
+
+	tenantIDs := []logstorage.TenantID{tenantID}
+	if err := vlstorage.UpdateRunTask(ctx, taskID, timestamp, tenantIDs, f, ru, dryRun); err != nil {
+		httpserver.Errorf(w, r, "cannot run update task: %s", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"task_id":%q}`, taskID)
+}
+
 func processDeleteStopTaskRequest(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 	taskID := r.FormValue("task_id")
 	if taskID == "" {
@@ -383,6 +467,19 @@ func processDeleteActiveTasksRequest(ctx context.Context, w http.ResponseWriter,
 	fmt.Fprintf(w, "%s", data)
 }
 
+func processDeleteFinishedTasksRequest(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	tasks, err := vlstorage.DeleteFinishedTasks(ctx)
+	if err != nil {
+		httpserver.Errorf(w, r, "cannot obtain finished delete tasks: %s", err)
+		return
+	}
+
+	data := logstorage.MarshalDeleteTasksToJSON(tasks)
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, "%s", data)
+}
+
 // getMaxQueryDuration returns the maximum duration for query from r.
 func getMaxQueryDuration(r *http.Request) time.Duration {
 	dms, err := httputil.GetDuration(r, "timeout", 0)
@@ -400,6 +497,15 @@ var (
 	logsqlFacetsRequests = metrics.NewCounter(`vl_http_requests_total{path="/select/logsql/facets"}`)
 	logsqlFacetsDuration = metrics.NewSummary(`vl_http_request_duration_seconds{path="/select/logsql/facets"}`)
 
+	logsqlCardinalityRequests = metrics.NewCounter(`vl_http_requests_total{path="/select/logsql/cardinality"}`)
+	logsqlCardinalityDuration = metrics.NewSummary(`vl_http_request_duration_seconds{path="/select/logsql/cardinality"}`)
+
+	tenantsRequests = metrics.NewCounter(`vl_http_requests_total{path="/select/tenants"}`)
+	tenantsDuration = metrics.NewSummary(`vl_http_request_duration_seconds{path="/select/tenants"}`)
+
+	logsqlExplainRequests = metrics.NewCounter(`vl_http_requests_total{path="/select/logsql/explain"}`)
+	logsqlExplainDuration = metrics.NewSummary(`vl_http_request_duration_seconds{path="/select/logsql/explain"}`)
+
 	logsqlFieldNamesRequests = metrics.NewCounter(`vl_http_requests_total{path="/select/logsql/field_names"}`)
 	logsqlFieldNamesDuration = metrics.NewSummary(`vl_http_request_duration_seconds{path="/select/logsql/field_names"}`)
 
@@ -409,6 +515,9 @@ var (
 	logsqlHitsRequests = metrics.NewCounter(`vl_http_requests_total{path="/select/logsql/hits"}`)
 	logsqlHitsDuration = metrics.NewSummary(`vl_http_request_duration_seconds{path="/select/logsql/hits"}`)
 
+	logsqlLookupTablesRequests = metrics.NewCounter(`vl_http_requests_total{path="/select/logsql/lookup_tables"}`)
+	logsqlLookupTablesDuration = metrics.NewSummary(`vl_http_request_duration_seconds{path="/select/logsql/lookup_tables"}`)
+
 	logsqlQueryRequests = metrics.NewCounter(`vl_http_requests_total{path="/select/logsql/query"}`)
 	logsqlQueryDuration = metrics.NewSummary(`vl_http_request_duration_seconds{path="/select/logsql/query"}`)
 
@@ -434,7 +543,9 @@ var (
 	logsqlTailRequests = metrics.NewCounter(`vl_http_requests_total{path="/select/logsql/tail"}`)
 
 	// no need to track duration for /delete/* requests, because they are asynchornous
-	deleteRunTaskRequests     = metrics.NewCounter(`vl_http_requests_total{path="/delete/run_task"}`)
-	deleteStopTaskRequests    = metrics.NewCounter(`vl_http_requests_total{path="/delete/stop_task"}`)
-	deleteActiveTasksRequests = metrics.NewCounter(`vl_http_requests_total{path="/delete/active_tasks"}`)
+	deleteRunTaskRequests       = metrics.NewCounter(`vl_http_requests_total{path="/delete/run_task"}`)
+	deleteRunUpdateTaskRequests = metrics.NewCounter(`vl_http_requests_total{path="/delete/run_update_task"}`)
+	deleteStopTaskRequests      = metrics.NewCounter(`vl_http_requests_total{path="/delete/stop_task"}`)
+	deleteActiveTasksRequests   = metrics.NewCounter(`vl_http_requests_total{path="/delete/active_tasks"}`)
+	deleteFinishedTasksRequests = metrics.NewCounter(`vl_http_requests_total{path="/delete/finished_tasks"}`)
 )
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/buildinfo"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/cgroup"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/envflag"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/logstorage"
+)
+
+var (
+	storageDataPath = flag.String("storageDataPath", "victoria-logs-data", "Path to VictoriaLogs data to verify, inspect or repair. "+
+		"VictoriaLogs must be stopped while vlstorage-tool runs")
+	partitions        = flagutil.NewArrayString("partition", "Optional names of partitions to process such as YYYYMMDD for per-day partitions. All the partitions at -storageDataPath are processed by default")
+	encryptionKeyFile = flag.String("storage.encryptionKeyFile", "", "Optional path to JSON file with keys for decrypting parts at -storageDataPath. "+
+		"It must match -storage.encryptionKeyFile passed to VictoriaLogs. See https://docs.victoriametrics.com/victorialogs/#encryption-at-rest")
+	concurrency = flag.Int("concurrency", cgroup.AvailableCPUs(), "The number of parts to read in parallel")
+
+	quarantine = flag.Bool("quarantine", false, "Whether to move broken parts found by 'verify' command to -quarantineDir and to remove them from parts.json. "+
+		"The 'repair' command always does this")
+	quarantineDir = flag.String("quarantineDir", "", "Path to directory where to move broken parts. By default <-storageDataPath>/quarantine is used")
+
+	columns = flag.Bool("columns", true, "Whether to collect per-column stats for every part in 'inspect' command")
+)
+
+const usage = `vlstorage-tool verifies, inspects and repairs VictoriaLogs data at -storageDataPath while VictoriaLogs is stopped.
+
+Usage: vlstorage-tool <command> [flags]
+
+Commands:
+  verify   - read every part, validate headers, block offsets and decompression; report broken parts.
+             Broken parts are moved to -quarantineDir and removed from parts.json if -quarantine is set.
+  inspect  - print partition, part and per-column stats in JSON lines format.
+  repair   - verify parts, move broken parts to -quarantineDir and rewrite parts.json without broken and missing parts.
+
+See https://docs.victoriametrics.com/victorialogs/#vlstorage-tool
+`
+
+func main() {
+	// Write flags and help message to stdout, since it is easier to grep or pipe.
+	flag.CommandLine.SetOutput(os.Stdout)
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		fmt.Fprintln(flag.CommandLine.Output(), "\nFlags:")
+		flag.PrintDefaults()
+	}
+
+	// The command goes before flags, so remove it from os.Args before parsing flags.
+	command := ""
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		command = os.Args[1]
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+	envflag.Parse()
+	buildinfo.Init()
+	logger.Init()
+
+	switch command {
+	case "verify", "inspect", "repair":
+	case "":
+		flag.Usage()
+		logger.Fatalf("missing command")
+	default:
+		flag.Usage()
+		logger.Fatalf("unknown command %q; supported commands: verify, inspect, repair", command)
+	}
+
+	if !fs.IsPathExist(*storageDataPath) {
+		logger.Fatalf("-storageDataPath=%q doesn't exist", *storageDataPath)
+	}
+
+	// Make sure VictoriaLogs doesn't run on the same -storageDataPath.
+	flockF := fs.MustCreateFlockFile(*storageDataPath)
+	defer fs.MustClose(flockF)
+
+	ek := mustLoadEncryptionKeys()
+	names := getPartitionNames()
+
+	startTime := time.Now()
+	switch command {
+	case "verify":
+		brokenParts := verify(names, ek, *quarantine)
+		logger.Infof("verified %d partitions at -storageDataPath=%q in %.3f seconds; broken parts: %d", len(names), *storageDataPath, time.Since(startTime).Seconds(), brokenParts)
+		if brokenParts > 0 && !*quarantine {
+			fs.MustClose(flockF)
+			os.Exit(1)
+		}
+	case "inspect":
+		inspect(names, ek)
+	case "repair":
+		brokenParts := verify(names, ek, true)
+		logger.Infof("repaired %d partitions at -storageDataPath=%q in %.3f seconds; removed broken parts: %d", len(names), *storageDataPath, time.Since(startTime).Seconds(), brokenParts)
+	}
+}
+
+// verify checks parts at the given partitions and prints broken parts to stdout.
+//
+// Broken parts are moved to quarantine if needRepair is set.
+// It returns the number of broken parts.
+func verify(names []string, ek *logstorage.EncryptionKeys, needRepair bool) int {
+	qPath := getQuarantinePath()
+	brokenParts := 0
+	for _, name := range names {
+		pis, err := logstorage.CheckPartition(*storageDataPath, name, ek, *concurrency, false)
+		if err != nil {
+			logger.Errorf("cannot verify partition %q: %s; it must be fixed manually", name, err)
+			brokenParts++
+			continue
+		}
+
+		n := 0
+		for _, pi := range pis {
+			if pi.IsBroken() {
+				writeJSONLine(pi)
+				n++
+			}
+		}
+		brokenParts += n
+		logger.Infof("verified partition %q; parts: %d, broken parts: %d", name, len(pis), n)
+
+		if n > 0 && needRepair {
+			removed, err := logstorage.RepairPartition(*storageDataPath, name, pis, qPath)
+			if err != nil {
+				logger.Fatalf("cannot repair partition %q: %s", name, err)
+			}
+			logger.Infof("removed %d broken parts from partition %q; the broken parts are moved to %q", removed, name, filepath.Join(qPath, name))
+		}
+	}
+	return brokenParts
+}
+
+// partitionInfo contains summary stats for a single partition returned by inspect command.
+type partitionInfo struct {
+	Partition         string    `json:"partition"`
+	Parts             int       `json:"parts"`
+	BrokenParts       int       `json:"broken_parts"`
+	Rows              uint64    `json:"rows"`
+	Blocks            uint64    `json:"blocks"`
+	CompressedBytes   uint64    `json:"compressed_bytes"`
+	UncompressedBytes uint64    `json:"uncompressed_bytes"`
+	MinTime           time.Time `json:"min_time"`
+	MaxTime           time.Time `json:"max_time"`
+}
+
+// inspect prints stats for the given partitions and their parts to stdout.
+func inspect(names []string, ek *logstorage.EncryptionKeys) {
+	for _, name := range names {
+		pis, err := logstorage.CheckPartition(*storageDataPath, name, ek, *concurrency, *columns)
+		if err != nil {
+			logger.Errorf("cannot inspect partition %q: %s", name, err)
+			continue
+		}
+
+		pti := &partitionInfo{
+			Partition: name,
+			Parts:     len(pis),
+		}
+		for _, pi := range pis {
+			if pi.IsBroken() {
+				pti.BrokenParts++
+				continue
+			}
+			if pti.Rows == 0 || pi.MinTime.Before(pti.MinTime) {
+				pti.MinTime = pi.MinTime
+			}
+			if pti.Rows == 0 || pi.MaxTime.After(pti.MaxTime) {
+				pti.MaxTime = pi.MaxTime
+			}
+			pti.Rows += pi.Rows
+			pti.Blocks += pi.Blocks
+			pti.CompressedBytes += pi.CompressedBytes
+			pti.UncompressedBytes += pi.UncompressedBytes
+		}
+		writeJSONLine(pti)
+
+		for _, pi := range pis {
+			writeJSONLine(pi)
+		}
+	}
+}
+
+func writeJSONLine(v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal %T to JSON: %s", v, err)
+	}
+	data = append(data, '\n')
+	if _, err := os.Stdout.Write(data); err != nil {
+		logger.Fatalf("cannot write to stdout: %s", err)
+	}
+}
+
+func getPartitionNames() []string {
+	all := logstorage.ListPartitions(*storageDataPath)
+	if len(*partitions) == 0 {
+		return all
+	}
+	for _, name := range *partitions {
+		if !slices.Contains(all, name) {
+			logger.Fatalf("partition %q is missing at -storageDataPath=%q", name, *storageDataPath)
+		}
+	}
+	return *partitions
+}
+
+func getQuarantinePath() string {
+	if *quarantineDir != "" {
+		return *quarantineDir
+	}
+	return filepath.Join(*storageDataPath, "quarantine")
+}
+
+func mustLoadEncryptionKeys() *logstorage.EncryptionKeys {
+	if *encryptionKeyFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(*encryptionKeyFile)
+	if err != nil {
+		logger.Fatalf("cannot read -storage.encryptionKeyFile=%q: %s", *encryptionKeyFile, err)
+	}
+	ek, err := logstorage.ParseEncryptionKeys(data)
+	if err != nil {
+		logger.Fatalf("cannot parse -storage.encryptionKeyFile=%q: %s", *encryptionKeyFile, err)
+	}
+	return ek
+}
@@ -8,6 +8,8 @@ import (
 	"io"
 	"math"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/cgroup"
@@ -21,6 +23,7 @@ import (
 
 	"github.com/VictoriaMetrics/VictoriaLogs/app/vlstorage/netinsert"
 	"github.com/VictoriaMetrics/VictoriaLogs/app/vlstorage/netselect"
+	"github.com/VictoriaMetrics/VictoriaLogs/app/vlstorage/replication"
 	"github.com/VictoriaMetrics/VictoriaLogs/lib/logstorage"
 )
 
@@ -33,9 +36,9 @@ var (
 		"higher number of readers may help increasing query performance on high-latency storage such as NFS or S3 at the cost of higher RAM usage; "+
 		"see https://docs.victoriametrics.com/victorialogs/logsql/#parallel_readers-query-option")
 
-	maxDiskSpaceUsageBytes = flagutil.NewBytes("retention.maxDiskSpaceUsageBytes", 0, "The maximum disk space usage at -storageDataPath before older per-day "+
+	maxDiskSpaceUsageBytes = flagutil.NewBytes("retention.maxDiskSpaceUsageBytes", 0, "The maximum disk space usage at -storageDataPath before older "+
 		"partitions are automatically dropped; see https://docs.victoriametrics.com/victorialogs/#retention-by-disk-space-usage ; see also -retentionPeriod")
-	maxDiskUsagePercent = flag.Int("retention.maxDiskUsagePercent", 0, "The maximum allowed disk usage percentage (1-100) for the filesystem that contains -storageDataPath before older per-day partitions are automatically dropped; mutually exclusive with -retention.maxDiskSpaceUsageBytes; see https://docs.victoriametrics.com/victorialogs/#retention-by-disk-space-usage-percent")
+	maxDiskUsagePercent = flag.Int("retention.maxDiskUsagePercent", 0, "The maximum allowed disk usage percentage (1-100) for the filesystem that contains -storageDataPath before older partitions are automatically dropped; mutually exclusive with -retention.maxDiskSpaceUsageBytes; see https://docs.victoriametrics.com/victorialogs/#retention-by-disk-space-usage-percent")
 	futureRetention     = flagutil.NewRetentionDuration("futureRetention", "2d", "Log entries with timestamps bigger than now+futureRetention are rejected during data ingestion; "+
 		"see https://docs.victoriametrics.com/victorialogs/#retention")
 	maxBackfillAge = flagutil.NewRetentionDuration("maxBackfillAge", "0", "Log entries with timestamps older than now-maxBackfillAge are rejected during data ingestion; "+
@@ -52,6 +55,14 @@ var (
 		"see https://docs.victoriametrics.com/victorialogs/data-ingestion/ ; see also -logNewStreams")
 	minFreeDiskSpaceBytes = flagutil.NewBytes("storage.minFreeDiskSpaceBytes", 10e6, "The minimum free disk space at -storageDataPath after which "+
 		"the storage stops accepting new data")
+	encryptionKeyFile = flag.String("storage.encryptionKeyFile", "", "Optional path to JSON file with keys for encryption at rest of the data stored at -storageDataPath. "+
+		"Only data parts are encrypted, while indexdb with log stream fields is stored unencrypted. See https://docs.victoriametrics.com/victorialogs/#encryption-at-rest")
+	partitionInterval = flagutil.NewExtendedDuration("storage.partitionInterval", "1d", "The time range covered by every newly created partition at -storageDataPath. "+
+		"Supported values: 1h, 6h, 1d, 1w. Smaller partitions allow freeing disk space with finer granularity at high ingestion rates. "+
+		"See https://docs.victoriametrics.com/victorialogs/#partitions-lifecycle")
+	adaptiveCompression = flag.Bool("storage.adaptiveCompression", false, "Whether to choose the best codec per each column block during background merges "+
+		"and to use higher zstd compression levels for big parts. This reduces disk space usage at the cost of higher CPU usage during merges. "+
+		"See https://docs.victoriametrics.com/victorialogs/#adaptive-compression")
 
 	logNewStreamsAuthKey = flagutil.NewPassword("logNewStreamsAuthKey", "authKey, which must be passed in query string to /internal/log_new_streams . It overrides -httpAuth.* . "+
 		"See https://docs.victoriametrics.com/victorialogs/#logging-new-streams")
@@ -63,6 +74,14 @@ var (
 	partitionManageAuthKey = flagutil.NewPassword("partitionManageAuthKey", "authKey, which must be passed in query string to /internal/partition/* . It overrides -httpAuth.* . "+
 		"See https://docs.victoriametrics.com/victorialogs/#partitions-lifecycle")
 
+	replicationPrimary = flag.String("replication.primary", "", "Optional address of the primary VictoriaLogs such as http://vlstorage-primary:9428 to replicate partitions from. "+
+		"If set, then the storage at -storageDataPath runs in read-only follower mode, which serves select queries over the replicated data. "+
+		"See https://docs.victoriametrics.com/victorialogs/#read-replicas")
+	replicationPrimaryAuthKey = flagutil.NewPassword("replication.primaryAuthKey", "authKey to pass to /internal/partition/* endpoints at -replication.primary; "+
+		"it must match the -partitionManageAuthKey at the primary")
+	replicationSyncInterval = flag.Duration("replication.syncInterval", 10*time.Second, "The interval for replicating new parts from -replication.primary. "+
+		"See https://docs.victoriametrics.com/victorialogs/#read-replicas")
+
 	storageNodeAddrs = flagutil.NewArrayString("storageNode", "Comma-separated list of TCP addresses for storage nodes to route the ingested logs to and to send select queries to. "+
 		"If the list is empty, then the ingested logs are stored and queried locally from -storageDataPath")
 	insertConcurrency        = flag.Int("insert.concurrency", 2, "The average number of concurrent data ingestion requests, which can be sent to every -storageNode")
@@ -71,6 +90,12 @@ var (
 	selectDisableCompression = flag.Bool("select.disableCompression", false, "Whether to disable compression for select query responses received from -storageNode nodes. "+
 		"Disabled compression reduces CPU usage at the cost of higher network usage")
 
+	storageNodeFollowers = flagutil.NewArrayString("storageNode.followers", "Optional |-separated list of addresses for read-only followers of the corresponding -storageNode. "+
+		"Select queries are spread among the -storageNode and its followers with the replication lag up to -storageNode.maxFollowerLag, "+
+		"while delete tasks are sent only to the -storageNode. See https://docs.victoriametrics.com/victorialogs/#read-replicas")
+	storageNodeMaxFollowerLag = flag.Duration("storageNode.maxFollowerLag", time.Minute, "The maximum replication lag for -storageNode.followers to send select queries to. "+
+		"Followers with bigger lag aren't queried until they catch up with the corresponding -storageNode")
+
 	storageNodeUsername     = flagutil.NewArrayString("storageNode.username", "Optional basic auth username to use for the corresponding -storageNode")
 	storageNodeUsernameFile = flagutil.NewArrayString("storageNode.usernameFile", "Optional path to basic auth username to use for the corresponding -storageNode. "+
 		"The file is re-read every second")
@@ -96,6 +121,8 @@ var (
 var localStorage *logstorage.Storage
 var localStorageMetrics *metrics.Set
 
+var follower *replication.Follower
+
 var netstorageInsert *netinsert.Storage
 
 var netstorageSelect *netselect.Storage
@@ -107,10 +134,30 @@ func Init() {
 	if len(*storageNodeAddrs) == 0 {
 		initLocalStorage()
 	} else {
+		if *replicationPrimary != "" {
+			logger.Fatalf("-replication.primary cannot be used together with -storageNode")
+		}
 		initNetworkStorage()
 	}
 }
 
+func mustLoadEncryptionKeys() *logstorage.EncryptionKeys {
+	if *encryptionKeyFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(*encryptionKeyFile)
+	if err != nil {
+		logger.Fatalf("cannot read -storage.encryptionKeyFile=%q: %s", *encryptionKeyFile, err)
+	}
+	ek, err := logstorage.ParseEncryptionKeys(data)
+	if err != nil {
+		logger.Fatalf("cannot parse -storage.encryptionKeyFile=%q: %s", *encryptionKeyFile, err)
+	}
+	logger.Infof("new data parts at -storageDataPath will be encrypted with the key %q from -storage.encryptionKeyFile=%q; "+
+		"indexdb with log stream fields isn't encrypted; see https://docs.victoriametrics.com/victorialogs/#encryption-at-rest", ek.CurrentKeyID(), *encryptionKeyFile)
+	return ek
+}
+
 func initLocalStorage() {
 	if localStorage != nil {
 		logger.Panicf("BUG: initLocalStorage() has been already called")
@@ -126,6 +173,9 @@ func initLocalStorage() {
 	if *maxDiskUsagePercent < 0 || *maxDiskUsagePercent > 100 {
 		logger.Fatalf("-retention.maxDiskUsagePercent must be between 1 and 100; got %d", *maxDiskUsagePercent)
 	}
+	if err := logstorage.ValidatePartitionInterval(partitionInterval.Duration()); err != nil {
+		logger.Fatalf("invalid -storage.partitionInterval: %s", err)
+	}
 	cfg := &logstorage.StorageConfig{
 		Retention:              retentionPeriod.Duration(),
 		DefaultParallelReaders: *defaultParallelReaders,
@@ -137,6 +187,10 @@ func initLocalStorage() {
 		LogNewStreams:          *logNewStreams,
 		LogIngestedRows:        *logIngestedRows,
 		MinFreeDiskSpaceBytes:  minFreeDiskSpaceBytes.N,
+		EncryptionKeys:         mustLoadEncryptionKeys(),
+		PartitionInterval:      partitionInterval.Duration(),
+		AdaptiveCompression:    *adaptiveCompression,
+		Follower:               *replicationPrimary != "",
 	}
 	logger.Infof("opening storage at -storageDataPath=%s", *storageDataPath)
 	startTime := time.Now()
@@ -149,6 +203,14 @@ func initLocalStorage() {
 		time.Since(startTime).Seconds(), ss.SmallParts, ss.BigParts, ss.SmallPartBlocks, ss.BigPartBlocks, ss.SmallPartRowsCount, ss.BigPartRowsCount,
 		ss.CompressedSmallPartSize, ss.CompressedBigPartSize)
 
+	if *replicationPrimary != "" {
+		if *replicationSyncInterval <= 0 {
+			logger.Fatalf("-replication.syncInterval must be positive; got %s", *replicationSyncInterval)
+		}
+		logger.Infof("starting replication from -replication.primary=%s every %s", *replicationPrimary, *replicationSyncInterval)
+		follower = replication.NewFollower(localStorage, *replicationPrimary, replicationPrimaryAuthKey.Get(), *replicationSyncInterval)
+	}
+
 	// register local storage metrics
 	localStorageMetrics = metrics.NewSet()
 	localStorageMetrics.RegisterMetricsWriter(func(w io.Writer) {
@@ -164,16 +226,20 @@ func initNetworkStorage() {
 
 	authCfgs := make([]*promauth.Config, len(*storageNodeAddrs))
 	isTLSs := make([]bool, len(*storageNodeAddrs))
+	followers := make([][]string, len(*storageNodeAddrs))
 	for i := range authCfgs {
 		authCfgs[i] = newAuthConfigForStorageNode(i)
 		isTLSs[i] = storageNodeTLS.GetOptionalArg(i)
+		if s := storageNodeFollowers.GetOptionalArg(i); s != "" {
+			followers[i] = strings.Split(s, "|")
+		}
 	}
 
 	logger.Infof("starting insert service for nodes %s", *storageNodeAddrs)
 	netstorageInsert = netinsert.NewStorage(*storageNodeAddrs, authCfgs, isTLSs, *insertConcurrency, *insertDisableCompression)
 
 	logger.Infof("initializing select service for nodes %s", *storageNodeAddrs)
-	netstorageSelect = netselect.NewStorage(*storageNodeAddrs, authCfgs, isTLSs, *selectDisableCompression)
+	netstorageSelect = netselect.NewStorage(*storageNodeAddrs, authCfgs, isTLSs, *selectDisableCompression, followers, *storageNodeMaxFollowerLag)
 
 	logger.Infof("initialized all the network services")
 }
@@ -221,6 +287,11 @@ func newAuthConfigForStorageNode(argIdx int) *promauth.Config {
 // Stop stops vlstorage.
 func Stop() {
 	if localStorage != nil {
+		if follower != nil {
+			follower.MustStop()
+			follower = nil
+		}
+
 		metrics.UnregisterSet(localStorageMetrics, true)
 		localStorageMetrics = nil
 
@@ -251,10 +322,20 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 		return processPartitionDetach(w, r)
 	case "/internal/partition/list":
 		return processPartitionList(w, r)
+	case "/internal/partition/parts":
+		return processPartitionParts(w, r)
 	case "/internal/partition/snapshot/create":
 		return processPartitionSnapshotCreate(w, r)
 	case "/internal/partition/snapshot/list":
 		return processPartitionSnapshotList(w, r)
+	case "/internal/partition/snapshot/delete":
+		return processPartitionSnapshotDelete(w, r)
+	case "/internal/partition/snapshot/files":
+		return processPartitionSnapshotFiles(w, r)
+	case "/internal/partition/snapshot/file":
+		return processPartitionSnapshotFile(w, r)
+	case "/internal/replication/status":
+		return processReplicationStatus(w, r)
 	}
 	return false
 }
@@ -378,6 +459,26 @@ func processPartitionList(w http.ResponseWriter, r *http.Request) bool {
 	return true
 }
 
+func processPartitionParts(w http.ResponseWriter, r *http.Request) bool {
+	if localStorage == nil {
+		// There are no partitions in non-local storage
+		return false
+	}
+
+	if !httpserver.CheckAuthFlag(w, r, partitionManageAuthKey) {
+		return true
+	}
+
+	pps, err := localStorage.PartitionPartsList()
+	if err != nil {
+		httpserver.Errorf(w, r, "%s", err)
+		return true
+	}
+
+	writeJSONResponse(w, pps)
+	return true
+}
+
 func processPartitionSnapshotCreate(w http.ResponseWriter, r *http.Request) bool {
 	if localStorage == nil {
 		// There are no partitions in non-local storage
@@ -419,6 +520,100 @@ func processPartitionSnapshotList(w http.ResponseWriter, r *http.Request) bool {
 	return true
 }
 
+func processPartitionSnapshotDelete(w http.ResponseWriter, r *http.Request) bool {
+	if localStorage == nil {
+		// There are no partitions in non-local storage
+		return false
+	}
+
+	if !httpserver.CheckAuthFlag(w, r, partitionManageAuthKey) {
+		return true
+	}
+
+	name := r.FormValue("name")
+	snapshotName := r.FormValue("snapshot")
+	if err := localStorage.PartitionSnapshotDelete(name, snapshotName); err != nil {
+		httpserver.Errorf(w, r, "%s", err)
+		return true
+	}
+
+	return true
+}
+
+func processPartitionSnapshotFiles(w http.ResponseWriter, r *http.Request) bool {
+	if localStorage == nil {
+		// There are no partitions in non-local storage
+		return false
+	}
+
+	if !httpserver.CheckAuthFlag(w, r, partitionManageAuthKey) {
+		return true
+	}
+
+	name := r.FormValue("name")
+	snapshotName := r.FormValue("snapshot")
+	files, err := localStorage.PartitionSnapshotFiles(name, snapshotName)
+	if err != nil {
+		httpserver.Errorf(w, r, "%s", err)
+		return true
+	}
+	if files == nil {
+		// This is needed in order to return `[]` instead of `null` to the client.
+		files = []logstorage.PartitionFile{}
+	}
+
+	writeJSONResponse(w, files)
+	return true
+}
+
+func processPartitionSnapshotFile(w http.ResponseWriter, r *http.Request) bool {
+	if localStorage == nil {
+		// There are no partitions in non-local storage
+		return false
+	}
+
+	if !httpserver.CheckAuthFlag(w, r, partitionManageAuthKey) {
+		return true
+	}
+
+	name := r.FormValue("name")
+	snapshotName := r.FormValue("snapshot")
+	path := r.FormValue("path")
+	filePath, err := localStorage.PartitionSnapshotFilePath(name, snapshotName, path)
+	if err != nil {
+		httpserver.Errorf(w, r, "%s", err)
+		return true
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		httpserver.Errorf(w, r, "cannot open file %q at snapshot %q for partition %q: %s", path, snapshotName, name, err)
+		return true
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if _, err := io.Copy(w, f); err != nil {
+		logger.Warnf("cannot send file %q from snapshot %q for partition %q to %s: %s", path, snapshotName, name, r.RemoteAddr, err)
+	}
+	return true
+}
+
+func processReplicationStatus(w http.ResponseWriter, _ *http.Request) bool {
+	if localStorage == nil {
+		// Replication is available only for local storage
+		return false
+	}
+
+	st := &replication.Status{}
+	if follower != nil {
+		st = follower.GetStatus()
+	}
+
+	writeJSONResponse(w, st)
+	return true
+}
+
 func writeJSONResponse(w http.ResponseWriter, response any) {
 	responseBody, err := json.Marshal(response)
 	if err != nil {
@@ -439,6 +634,12 @@ func (*Storage) CanWriteData() error {
 		return nil
 	}
 
+	if localStorage.IsFollower() {
+		return &httpserver.ErrorWithStatusCode{
+			Err:        fmt.Errorf("cannot add rows into storage in follower mode; send logs to -replication.primary=%s instead", *replicationPrimary),
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	}
 	if localStorage.IsReadOnly() {
 		return &httpserver.ErrorWithStatusCode{
 			Err: fmt.Errorf("cannot add rows into storage in read-only mode; the storage can be in read-only mode "+
@@ -532,16 +733,66 @@ func GetStreamIDs(qctx *logstorage.QueryContext, limit uint64) ([]logstorage.Val
 	return netstorageSelect.GetStreamIDs(qctx, limit)
 }
 
+// EstimateQuery estimates the amounts of data, which must be scanned by qctx.
+func EstimateQuery(qctx *logstorage.QueryContext) (*logstorage.QueryEstimate, error) {
+	if localStorage != nil {
+		return localStorage.EstimateQuery(qctx)
+	}
+	return netstorageSelect.EstimateQuery(qctx)
+}
+
+// AnalyzeQuery executes qctx and returns counters collected during the execution.
+func AnalyzeQuery(qctx *logstorage.QueryContext) (*logstorage.QueryAnalysis, error) {
+	if localStorage != nil {
+		return localStorage.AnalyzeQuery(qctx)
+	}
+	return netstorageSelect.AnalyzeQuery(qctx)
+}
+
+// GetStreamCardinality returns log streams' stats for the given tenantIDs on the given [start, end] time range.
+//
+// Up to limit entries are returned per every list in the stats.
+func GetStreamCardinality(ctx context.Context, tenantIDs []logstorage.TenantID, start, end int64, limit int, allowPartialResponse bool) (*logstorage.StreamCardinality, error) {
+	if localStorage != nil {
+		return localStorage.GetStreamCardinality(ctx, tenantIDs, start, end, limit)
+	}
+	return netstorageSelect.GetStreamCardinality(ctx, tenantIDs, start, end, limit, allowPartialResponse)
+}
+
+// GetTenantsUsage returns storage usage for all the tenants with stored rows.
+func GetTenantsUsage(ctx context.Context, allowPartialResponse bool) (*logstorage.TenantsUsage, error) {
+	if localStorage != nil {
+		return localStorage.GetTenantsUsage(ctx)
+	}
+	return netstorageSelect.GetTenantsUsage(ctx, allowPartialResponse)
+}
+
 // DeleteRunTask starts deletion of logs for the given filter f for the given tenantIDs.
 //
 // The taskID and timestamp are tracked in the list of tasks returned by DeleteActiveTasks().
-func DeleteRunTask(ctx context.Context, taskID string, timestamp int64, tenantIDs []logstorage.TenantID, f *logstorage.Filter) error {
-	logger.Infof("starting deleting logs for task_id=%q, filter=%q, tenantIDs=%s", taskID, f, tenantIDs)
+//
+// If dryRun is set, then the task only counts logs matching f without deleting them.
+func DeleteRunTask(ctx context.Context, taskID string, timestamp int64, tenantIDs []logstorage.TenantID, f *logstorage.Filter, dryRun bool) error {
+	logger.Infof("starting deleting logs for task_id=%q, filter=%q, tenantIDs=%s, dry_run=%v", taskID, f, tenantIDs, dryRun)
 
 	if localStorage != nil {
-		return localStorage.DeleteRunTask(ctx, taskID, timestamp, tenantIDs, f)
+		return localStorage.DeleteRunTask(ctx, taskID, timestamp, tenantIDs, f, dryRun)
 	}
-	return netstorageSelect.DeleteRunTask(ctx, taskID, timestamp, tenantIDs, f)
+	return netstorageSelect.DeleteRunTask(ctx, taskID, timestamp, tenantIDs, f, dryRun)
+}
+
+// UpdateRunTask starts updating of logs for the given filter f for the given tenantIDs according to the given ru.
+//
+// The task is tracked in the list of tasks returned by DeleteActiveTasks() and it can be stopped via DeleteStopTask().
+//
+// If dryRun is set, then the task only counts logs matching f without updating them.
+func UpdateRunTask(ctx context.Context, taskID string, timestamp int64, tenantIDs []logstorage.TenantID, f *logstorage.Filter, ru *logstorage.RowsUpdate, dryRun bool) error {
+	logger.Infof("starting updating logs for task_id=%q, filter=%q, update=%q, tenantIDs=%s, dry_run=%v", taskID, f, ru, tenantIDs, dryRun)
+
+	if localStorage != nil {
+		return localStorage.UpdateRunTask(ctx, taskID, timestamp, tenantIDs, f, ru, dryRun)
+	}
+	return netstorageSelect.UpdateRunTask(ctx, taskID, timestamp, tenantIDs, f, ru, dryRun)
 }
 
 // DeleteStopTask stops delete task with the given taskID.
@@ -568,6 +819,14 @@ func DeleteActiveTasks(ctx context.Context) ([]*logstorage.DeleteTask, error) {
 	return netstorageSelect.DeleteActiveTasks(ctx)
 }
 
+// DeleteFinishedTasks returns a list of finished deletion tasks started via DeleteRunTask().
+func DeleteFinishedTasks(ctx context.Context) ([]*logstorage.DeleteTask, error) {
+	if localStorage != nil {
+		return localStorage.DeleteFinishedTasks(ctx)
+	}
+	return netstorageSelect.DeleteFinishedTasks(ctx)
+}
+
 func writeStorageMetrics(w io.Writer, strg *logstorage.Storage) {
 	var ss logstorage.StorageStats
 	strg.UpdateStats(&ss)
@@ -584,6 +843,11 @@ func writeStorageMetrics(w io.Writer, strg *logstorage.Storage) {
 	}
 	metrics.WriteGaugeUint64(w, fmt.Sprintf(`vl_storage_is_read_only{path=%q}`, *storageDataPath), isReadOnly)
 
+	if follower != nil {
+		st := follower.GetStatus()
+		metrics.WriteGaugeFloat64(w, `vl_replication_lag_seconds`, st.LagSeconds)
+	}
+
 	metrics.WriteGaugeUint64(w, `vl_active_merges{type="storage/inmemory"}`, ss.ActiveInmemoryMerges)
 	metrics.WriteGaugeUint64(w, `vl_active_merges{type="storage/small"}`, ss.ActiveSmallMerges)
 	metrics.WriteGaugeUint64(w, `vl_active_merges{type="storage/big"}`, ss.ActiveBigMerges)
@@ -2,6 +2,7 @@ package netselect
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -10,6 +11,7 @@ import (
 	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
@@ -23,6 +25,7 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/slicesutil"
 	"github.com/VictoriaMetrics/metrics"
 
+	"github.com/VictoriaMetrics/VictoriaLogs/app/vlstorage/replication"
 	"github.com/VictoriaMetrics/VictoriaLogs/lib/logstorage"
 )
 
@@ -30,42 +33,67 @@ const (
 	// FieldNamesProtocolVersion is the version of the protocol used for /internal/select/field_names HTTP endpoint.
 	//
 	// It must be updated every time the protocol changes.
-	FieldNamesProtocolVersion = "v3"
+	FieldNamesProtocolVersion = "v4"
 
 	// FieldValuesProtocolVersion is the version of the protocol used for /internal/select/field_values HTTP endpoint.
 	//
 	// It must be updated every time the protocol changes.
-	FieldValuesProtocolVersion = "v3"
+	FieldValuesProtocolVersion = "v4"
 
 	// StreamFieldNamesProtocolVersion is the version of the protocol used for /internal/select/stream_field_names HTTP endpoint.
 	//
 	// It must be updated every time the protocol changes.
-	StreamFieldNamesProtocolVersion = "v3"
+	StreamFieldNamesProtocolVersion = "v4"
 
 	// StreamFieldValuesProtocolVersion is the version of the protocol used for /internal/select/stream_field_values HTTP endpoint.
 	//
 	// It must be updated every time the protocol changes.
-	StreamFieldValuesProtocolVersion = "v3"
+	StreamFieldValuesProtocolVersion = "v4"
 
 	// StreamsProtocolVersion is the version of the protocol used for /internal/select/streams HTTP endpoint.
 	//
 	// It must be updated every time the protocol changes.
-	StreamsProtocolVersion = "v3"
+	StreamsProtocolVersion = "v4"
 
 	// StreamIDsProtocolVersion is the version of the protocol used for /internal/select/stream_ids HTTP endpoint.
 	//
 	// It must be updated every time the protocol changes.
-	StreamIDsProtocolVersion = "v3"
+	StreamIDsProtocolVersion = "v4"
 
 	// QueryProtocolVersion is the version of the protocol used for /internal/select/query HTTP endpoint.
 	//
 	// It must be updated every time the protocol changes.
-	QueryProtocolVersion = "v3"
+	QueryProtocolVersion = "v4"
+
+	// EstimateQueryProtocolVersion is the version of the protocol used for /internal/select/estimate HTTP endpoint.
+	//
+	// It must be updated every time the protocol changes.
+	EstimateQueryProtocolVersion = "v1"
+
+	// AnalyzeQueryProtocolVersion is the version of the protocol used for /internal/select/analyze HTTP endpoint.
+	//
+	// It must be updated every time the protocol changes.
+	AnalyzeQueryProtocolVersion = "v1"
+
+	// StreamCardinalityProtocolVersion is the version of the protocol used for /internal/select/cardinality HTTP endpoint.
+	//
+	// It must be updated every time the protocol changes.
+	StreamCardinalityProtocolVersion = "v1"
+
+	// TenantsUsageProtocolVersion is the version of the protocol used for /internal/select/tenants HTTP endpoint.
+	//
+	// It must be updated every time the protocol changes.
+	TenantsUsageProtocolVersion = "v1"
 
 	// DeleteRunTaskProtocolVersion is the version of the protocol used for /internal/delete/run_task HTTP endpoint.
 	//
 	// It must be updated every time the protocol changes.
-	DeleteRunTaskProtocolVersion = "v1"
+	DeleteRunTaskProtocolVersion = "v2"
+
+	// UpdateRunTaskProtocolVersion is the version of the protocol used for /internal/delete/run_update_task HTTP endpoint.
+	//
+	// It must be updated every time the protocol changes.
+	UpdateRunTaskProtocolVersion = "v1"
 
 	// DeleteStopTaskProtocolVersion is the version of the protocol used for /internal/delete/stop_task HTTP endpoint.
 	//
@@ -75,7 +103,12 @@ const (
 	// DeleteActiveTasksProtocolVersion is the version of the protocol used for /internal/delete/active_tasks endpoint.
 	//
 	// It must be updated every time the protocol changes.
-	DeleteActiveTasksProtocolVersion = "v1"
+	DeleteActiveTasksProtocolVersion = "v2"
+
+	// DeleteFinishedTasksProtocolVersion is the version of the protocol used for /internal/delete/finished_tasks endpoint.
+	//
+	// It must be updated every time the protocol changes.
+	DeleteFinishedTasksProtocolVersion = "v1"
 )
 
 // Storage is a network storage for querying remote storage nodes in the cluster.
@@ -83,6 +116,15 @@ type Storage struct {
 	sns []*storageNode
 
 	disableCompression bool
+
+	// maxFollowerLag is the maximum replication lag for followers to send select queries to.
+	maxFollowerLag time.Duration
+
+	// stopCh is closed when the Storage must be stopped.
+	stopCh chan struct{}
+
+	// wg is used for waiting for background workers at MustStop().
+	wg sync.WaitGroup
 }
 
 type storageNode struct {
@@ -103,6 +145,20 @@ type storageNode struct {
 
 	// sendErrors counts failed send attempts for this storage node.
 	sendErrors *metrics.Counter
+
+	// followers contains read-only followers, which replicate data from the storage node.
+	//
+	// Select queries are spread among the storage node and its followers with the replication lag up to s.maxFollowerLag.
+	followers []*storageNode
+
+	// nextReplicaIdx is used for round-robin selection among the storage node and its followers.
+	nextReplicaIdx atomic.Uint32
+
+	// primary is the storage node, which is replicated by the given follower. It is nil for non-follower storage nodes.
+	primary *storageNode
+
+	// lag is the replication lag in nanoseconds for the follower. It is set to -1 if the follower cannot be used for querying.
+	lag atomic.Int64
 }
 
 func newStorageNode(s *Storage, addr string, ac *promauth.Config, isTLS bool) *storageNode {
@@ -129,6 +185,106 @@ func newStorageNode(s *Storage, addr string, ac *promauth.Config, isTLS bool) *s
 	return sn
 }
 
+func newFollowerStorageNode(primary *storageNode, addr string, ac *promauth.Config, isTLS bool) *storageNode {
+	sn := newStorageNode(primary.s, addr, ac, isTLS)
+	sn.primary = primary
+
+	// Do not send queries to the follower until its replication lag is known.
+	sn.lag.Store(-1)
+
+	_ = metrics.GetOrCreateGauge(fmt.Sprintf(`vl_select_remote_follower_lag_seconds{addr=%q}`, addr), func() float64 {
+		lag := sn.lag.Load()
+		if lag < 0 {
+			return -1
+		}
+		return time.Duration(lag).Seconds()
+	})
+
+	return sn
+}
+
+// getReplica returns the storage node to send select queries to instead of sn.
+//
+// It spreads queries among sn and its followers with the replication lag up to sn.s.maxFollowerLag.
+func (sn *storageNode) getReplica() *storageNode {
+	if len(sn.followers) == 0 {
+		return sn
+	}
+
+	replicasCount := uint32(len(sn.followers) + 1)
+	n := sn.nextReplicaIdx.Add(1)
+	for i := uint32(0); i < replicasCount; i++ {
+		idx := (n + i) % replicasCount
+		if idx == 0 {
+			return sn
+		}
+		follower := sn.followers[idx-1]
+		if follower.isUpToDate() {
+			return follower
+		}
+	}
+	return sn
+}
+
+// callReplica calls f for the storage node returned by sn.getReplica() and returns this storage node.
+//
+// If the returned storage node is a follower, which is unavailable, then f is retried at sn,
+// since the follower may become unavailable after its replication lag has been checked.
+// The returned storage node is sn in this case. The retry is safe, since unavailable backend errors
+// are returned before reading the response from the follower.
+func (sn *storageNode) callReplica(ctx context.Context, f func(replica *storageNode) error) (*storageNode, error) {
+	replica := sn.getReplica()
+	err := f(replica)
+	if err == nil || replica == sn || ctx.Err() != nil || !isUnavailableBackendError(err) {
+		return replica, err
+	}
+
+	// Do not send queries to the unavailable follower until its replication status is successfully checked again.
+	replica.sendErrors.Inc()
+	replica.lag.Store(-1)
+
+	return sn, f(sn)
+}
+
+// isUpToDate returns true if the follower sn can be used for querying.
+func (sn *storageNode) isUpToDate() bool {
+	lag := sn.lag.Load()
+	return lag >= 0 && lag <= sn.s.maxFollowerLag.Nanoseconds()
+}
+
+// updateLag updates the replication lag for the follower sn from its /internal/replication/status.
+func (sn *storageNode) updateLag(ctx context.Context) {
+	lag, err := sn.getLag(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Warnf("cannot obtain replication status from the follower %s of -storageNode=%s; "+
+				"queries aren't sent to it until it becomes available: %s", sn.addr, sn.primary.addr, err)
+		}
+		sn.lag.Store(-1)
+		return
+	}
+	sn.lag.Store(lag)
+}
+
+func (sn *storageNode) getLag(ctx context.Context) (int64, error) {
+	data, reqURL, err := sn.getPlainResponseBodyForPathAndArgs(ctx, "/internal/replication/status", url.Values{})
+	if err != nil {
+		return -1, err
+	}
+
+	var st replication.Status
+	if err := json.Unmarshal(data, &st); err != nil {
+		return -1, fmt.Errorf("cannot unmarshal replication status from %q: %w; response: %q", reqURL, err, data)
+	}
+	if !st.IsFollower {
+		return -1, fmt.Errorf("the node at %q doesn't run in follower mode; see https://docs.victoriametrics.com/victorialogs/#read-replicas", reqURL)
+	}
+	if st.LastSyncTimestamp <= 0 {
+		return -1, fmt.Errorf("the follower at %q hasn't been synced with the primary yet", reqURL)
+	}
+	return int64(st.LagSeconds * 1e9), nil
+}
+
 func (sn *storageNode) runQuery(qctx *logstorage.QueryContext, processBlock func(db *logstorage.DataBlock)) error {
 	args := sn.getCommonArgs(QueryProtocolVersion, qctx)
 
@@ -255,6 +411,8 @@ func (sn *storageNode) getCommonArgs(version string, qctx *logstorage.QueryConte
 	args.Set("timestamp", fmt.Sprintf("%d", qctx.Query.GetTimestamp()))
 	args.Set("disable_compression", fmt.Sprintf("%v", sn.s.disableCompression))
 	args.Set("allow_partial_response", fmt.Sprintf("%v", qctx.AllowPartialResponse))
+	args.Set("max_bytes_read", fmt.Sprintf("%d", qctx.Limits.MaxBytesRead))
+	args.Set("max_rows_processed", fmt.Sprintf("%d", qctx.Limits.MaxRowsProcessed))
 	return args
 }
 
@@ -335,25 +493,86 @@ func (sn *storageNode) getRequestURL(path string) string {
 
 // NewStorage returns new Storage for the given addrs and the given authCfgs.
 //
+// followers[i] contains optional addresses of read-only followers for addrs[i]. Select queries are spread among addrs[i]
+// and its followers with the replication lag up to maxFollowerLag. Followers use the same authCfgs[i] and isTLSs[i] as addrs[i].
+//
 // If disableCompression is set, then uncompressed responses are received from storage nodes.
 //
 // Call MustStop on the returned storage when it is no longer needed.
-func NewStorage(addrs []string, authCfgs []*promauth.Config, isTLSs []bool, disableCompression bool) *Storage {
+func NewStorage(addrs []string, authCfgs []*promauth.Config, isTLSs []bool, disableCompression bool, followers [][]string, maxFollowerLag time.Duration) *Storage {
 	s := &Storage{
 		disableCompression: disableCompression,
+		maxFollowerLag:     maxFollowerLag,
+		stopCh:             make(chan struct{}),
 	}
 
 	sns := make([]*storageNode, len(addrs))
+	var followerSNs []*storageNode
 	for i, addr := range addrs {
-		sns[i] = newStorageNode(s, addr, authCfgs[i], isTLSs[i])
+		sn := newStorageNode(s, addr, authCfgs[i], isTLSs[i])
+		if i < len(followers) {
+			for _, followerAddr := range followers[i] {
+				follower := newFollowerStorageNode(sn, followerAddr, authCfgs[i], isTLSs[i])
+				sn.followers = append(sn.followers, follower)
+				followerSNs = append(followerSNs, follower)
+			}
+		}
+		sns[i] = sn
 	}
 	s.sns = sns
 
+	if len(followerSNs) > 0 {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.watchFollowersLag(followerSNs)
+		}()
+	}
+
 	return s
 }
 
+// followerLagCheckInterval is the interval for checking the replication lag at followers.
+const followerLagCheckInterval = 5 * time.Second
+
+func (s *Storage) watchFollowersLag(followers []*storageNode) {
+	ctx, cancel := contextutil.NewStopChanContext(s.stopCh)
+	defer cancel()
+
+	updateLags := func() {
+		ctxWithTimeout, cancel := context.WithTimeout(ctx, followerLagCheckInterval)
+		defer cancel()
+
+		var wg sync.WaitGroup
+		for _, sn := range followers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sn.updateLag(ctxWithTimeout)
+			}()
+		}
+		wg.Wait()
+	}
+
+	updateLags()
+
+	t := time.NewTicker(followerLagCheckInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-t.C:
+			updateLags()
+		}
+	}
+}
+
 // MustStop stops the s.
 func (s *Storage) MustStop() {
+	close(s.stopCh)
+	s.wg.Wait()
+
 	s.sns = nil
 }
 
@@ -387,9 +606,10 @@ func (s *Storage) runQuery(stopCh <-chan struct{}, qctx *logstorage.QueryContext
 		go func(nodeIdx int) {
 			defer wg.Done()
 
-			sn := s.sns[nodeIdx]
-			err := sn.runQuery(qctxLocal, func(db *logstorage.DataBlock) {
-				writeBlock(uint(nodeIdx), db)
+			sn, err := s.sns[nodeIdx].callReplica(ctxWithCancel, func(sn *storageNode) error {
+				return sn.runQuery(qctxLocal, func(db *logstorage.DataBlock) {
+					writeBlock(uint(nodeIdx), db)
+				})
 			})
 			errs[nodeIdx] = sn.handleError(ctxWithCancel, cancel, err, qctx.AllowPartialResponse)
 		}(i)
@@ -455,8 +675,168 @@ func (s *Storage) GetStreamIDs(qctx *logstorage.QueryContext, limit uint64) ([]l
 	})
 }
 
+// EstimateQuery estimates the amounts of data, which must be scanned by qctx at all the storage nodes.
+func (s *Storage) EstimateQuery(qctx *logstorage.QueryContext) (*logstorage.QueryEstimate, error) {
+	ctxWithCancel, cancel := context.WithCancel(qctx.Context)
+	defer cancel()
+
+	results := make([]*logstorage.QueryEstimate, len(s.sns))
+	errs := make([]error, len(s.sns))
+
+	var wg sync.WaitGroup
+	for i := range s.sns {
+		wg.Add(1)
+		go func(nodeIdx int) {
+			defer wg.Done()
+
+			qctxLocal := qctx.WithContext(ctxWithCancel)
+			sn, err := s.sns[nodeIdx].callReplica(ctxWithCancel, func(sn *storageNode) error {
+				qe, err := sn.estimateQuery(qctxLocal)
+				results[nodeIdx] = qe
+				return err
+			})
+			errs[nodeIdx] = sn.handleError(ctxWithCancel, cancel, err, qctx.AllowPartialResponse)
+		}(i)
+	}
+	wg.Wait()
+
+	if err := getFirstError(errs, qctx.AllowPartialResponse); err != nil {
+		return nil, err
+	}
+
+	var qe logstorage.QueryEstimate
+	for _, qeLocal := range results {
+		if qeLocal != nil {
+			qe.Add(qeLocal)
+		}
+	}
+	return &qe, nil
+}
+
+// AnalyzeQuery executes the remote part of qctx at all the storage nodes and returns counters collected during the execution.
+func (s *Storage) AnalyzeQuery(qctx *logstorage.QueryContext) (*logstorage.QueryAnalysis, error) {
+	startTime := time.Now()
+
+	nqr, err := logstorage.NewNetQueryRunner(qctx, s.RunQuery, func(_ uint, _ *logstorage.DataBlock) {})
+	if err != nil {
+		return nil, err
+	}
+	qctxRemote := qctx.WithQuery(nqr.RemoteQuery())
+
+	ctxWithCancel, cancel := context.WithCancel(qctx.Context)
+	defer cancel()
+
+	results := make([]*logstorage.QueryAnalysis, len(s.sns))
+	nodeAddrs := make([]string, len(s.sns))
+	errs := make([]error, len(s.sns))
+
+	var wg sync.WaitGroup
+	for i := range s.sns {
+		wg.Add(1)
+		go func(nodeIdx int) {
+			defer wg.Done()
+
+			qctxLocal := qctxRemote.WithContext(ctxWithCancel)
+			sn, err := s.sns[nodeIdx].callReplica(ctxWithCancel, func(sn *storageNode) error {
+				qa, err := sn.analyzeQuery(qctxLocal)
+				results[nodeIdx] = qa
+				return err
+			})
+			nodeAddrs[nodeIdx] = sn.addr
+			errs[nodeIdx] = sn.handleError(ctxWithCancel, cancel, err, qctx.AllowPartialResponse)
+		}(i)
+	}
+	wg.Wait()
+
+	if err := getFirstError(errs, qctx.AllowPartialResponse); err != nil {
+		return nil, err
+	}
+
+	qa := &logstorage.QueryAnalysis{
+		Partitions: []*logstorage.PartitionAnalysis{},
+	}
+	for nodeIdx, qaLocal := range results {
+		if qaLocal == nil {
+			continue
+		}
+		qa.RowsReturned += qaLocal.RowsReturned
+		for _, pa := range qaLocal.Partitions {
+			pa.Node = nodeAddrs[nodeIdx]
+			qa.Partitions = append(qa.Partitions, pa)
+		}
+	}
+	qa.Duration = time.Since(startTime).String()
+	return qa, nil
+}
+
+// GetStreamCardinality returns log streams' stats for the given tenantIDs on the given [start, end] time range from all the storage nodes.
+//
+// Up to limit entries are returned per every list in the stats.
+func (s *Storage) GetStreamCardinality(ctx context.Context, tenantIDs []logstorage.TenantID, start, end int64, limit int, allowPartialResponse bool) (*logstorage.StreamCardinality, error) {
+	ctxWithCancel, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]*logstorage.StreamCardinality, len(s.sns))
+	errs := make([]error, len(s.sns))
+
+	var wg sync.WaitGroup
+	for i := range s.sns {
+		wg.Add(1)
+		go func(nodeIdx int) {
+			defer wg.Done()
+
+			sn, err := s.sns[nodeIdx].callReplica(ctxWithCancel, func(sn *storageNode) error {
+				sc, err := sn.getStreamCardinality(ctxWithCancel, tenantIDs, start, end, limit, allowPartialResponse)
+				results[nodeIdx] = sc
+				return err
+			})
+			errs[nodeIdx] = sn.handleError(ctxWithCancel, cancel, err, allowPartialResponse)
+		}(i)
+	}
+	wg.Wait()
+
+	if err := getFirstError(errs, allowPartialResponse); err != nil {
+		return nil, err
+	}
+
+	return logstorage.MergeStreamCardinality(results, limit), nil
+}
+
+// GetTenantsUsage returns storage usage for all the tenants with stored rows from all the storage nodes.
+func (s *Storage) GetTenantsUsage(ctx context.Context, allowPartialResponse bool) (*logstorage.TenantsUsage, error) {
+	ctxWithCancel, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]*logstorage.TenantsUsage, len(s.sns))
+	errs := make([]error, len(s.sns))
+
+	var wg sync.WaitGroup
+	for i := range s.sns {
+		wg.Add(1)
+		go func(nodeIdx int) {
+			defer wg.Done()
+
+			sn, err := s.sns[nodeIdx].callReplica(ctxWithCancel, func(sn *storageNode) error {
+				tu, err := sn.getTenantsUsage(ctxWithCancel, allowPartialResponse)
+				results[nodeIdx] = tu
+				return err
+			})
+			errs[nodeIdx] = sn.handleError(ctxWithCancel, cancel, err, allowPartialResponse)
+		}(i)
+	}
+	wg.Wait()
+
+	if err := getFirstError(errs, allowPartialResponse); err != nil {
+		return nil, err
+	}
+
+	return logstorage.MergeTenantsUsage(results), nil
+}
+
 // DeleteRunTask starts deletion of logs for the given filter f at the given tenantIDs.
-func (s *Storage) DeleteRunTask(ctx context.Context, taskID string, timestamp int64, tenantIDs []logstorage.TenantID, f *logstorage.Filter) error {
+//
+// If dryRun is set, then the task only counts logs matching f without deleting them.
+func (s *Storage) DeleteRunTask(ctx context.Context, taskID string, timestamp int64, tenantIDs []logstorage.TenantID, f *logstorage.Filter, dryRun bool) error {
 	ctxWithCancel, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -476,7 +856,36 @@ func (s *Storage) DeleteRunTask(ctx context.Context, taskID string, timestamp in
 			defer wg.Done()
 
 			sn := s.sns[nodeIdx]
-			err := sn.deleteRunTask(ctxWithCancel, taskID, timestamp, tenantIDs, f)
+			err := sn.deleteRunTask(ctxWithCancel, taskID, timestamp, tenantIDs, f, dryRun)
+			errs[nodeIdx] = sn.handleError(ctxWithCancel, cancel, err, allowPartialResponse)
+		}(i)
+	}
+	wg.Wait()
+
+	return getFirstError(errs, allowPartialResponse)
+}
+
+// UpdateRunTask starts updating of logs for the given filter f at the given tenantIDs according to the given ru.
+//
+// If dryRun is set, then the task only counts logs matching f without updating them.
+func (s *Storage) UpdateRunTask(ctx context.Context, taskID string, timestamp int64, tenantIDs []logstorage.TenantID, f *logstorage.Filter, ru *logstorage.RowsUpdate, dryRun bool) error {
+	ctxWithCancel, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make([]error, len(s.sns))
+
+	// Return an error to the caller when at least a single storage node is unavailable.
+	// See comments at DeleteRunTask for details.
+	allowPartialResponse := false
+
+	var wg sync.WaitGroup
+	for i := range s.sns {
+		wg.Add(1)
+		go func(nodeIdx int) {
+			defer wg.Done()
+
+			sn := s.sns[nodeIdx]
+			err := sn.updateRunTask(ctxWithCancel, taskID, timestamp, tenantIDs, f, ru, dryRun)
 			errs[nodeIdx] = sn.handleError(ctxWithCancel, cancel, err, allowPartialResponse)
 		}(i)
 	}
@@ -515,7 +924,24 @@ func (s *Storage) DeleteStopTask(ctx context.Context, taskID string) error {
 }
 
 // DeleteActiveTasks returns the list of active delete tasks started via DeleteRunTask
+//
+// The progress of the returned tasks is summed across all the storage nodes.
 func (s *Storage) DeleteActiveTasks(ctx context.Context) ([]*logstorage.DeleteTask, error) {
+	return s.getDeleteTasks(ctx, func(ctx context.Context, sn *storageNode) ([]*logstorage.DeleteTask, error) {
+		return sn.getDeleteTasks(ctx, "/internal/delete/active_tasks", DeleteActiveTasksProtocolVersion)
+	})
+}
+
+// DeleteFinishedTasks returns the list of finished delete tasks started via DeleteRunTask
+//
+// The progress of the returned tasks is summed across all the storage nodes.
+func (s *Storage) DeleteFinishedTasks(ctx context.Context) ([]*logstorage.DeleteTask, error) {
+	return s.getDeleteTasks(ctx, func(ctx context.Context, sn *storageNode) ([]*logstorage.DeleteTask, error) {
+		return sn.getDeleteTasks(ctx, "/internal/delete/finished_tasks", DeleteFinishedTasksProtocolVersion)
+	})
+}
+
+func (s *Storage) getDeleteTasks(ctx context.Context, callback func(ctx context.Context, sn *storageNode) ([]*logstorage.DeleteTask, error)) ([]*logstorage.DeleteTask, error) {
 	ctxWithCancel, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -523,7 +949,7 @@ func (s *Storage) DeleteActiveTasks(ctx context.Context) ([]*logstorage.DeleteTa
 	results := make([][]*logstorage.DeleteTask, len(s.sns))
 
 	// Return an error to the caller when at least a single storage node is unavailable,
-	// since this prevents from returning the full list of active delete tasks.
+	// since this prevents from returning the full list of delete tasks.
 	allowPartialResponse := false
 
 	var wg sync.WaitGroup
@@ -533,7 +959,7 @@ func (s *Storage) DeleteActiveTasks(ctx context.Context) ([]*logstorage.DeleteTa
 			defer wg.Done()
 
 			sn := s.sns[nodeIdx]
-			tasks, err := sn.deleteActiveTasks(ctxWithCancel)
+			tasks, err := callback(ctxWithCancel, sn)
 			results[nodeIdx] = tasks
 			errs[nodeIdx] = sn.handleError(ctxWithCancel, cancel, err, allowPartialResponse)
 		}(i)
@@ -545,20 +971,7 @@ func (s *Storage) DeleteActiveTasks(ctx context.Context) ([]*logstorage.DeleteTa
 	}
 
 	// Merge tasks received from storage nodes.
-	m := make(map[string]*logstorage.DeleteTask)
-	for _, tasks := range results {
-		for _, dt := range tasks {
-			dst := m[dt.TaskID]
-			if dst == nil {
-				m[dt.TaskID] = dt
-			}
-		}
-	}
-
-	tasks := make([]*logstorage.DeleteTask, 0, len(m))
-	for _, t := range m {
-		tasks = append(tasks, t)
-	}
+	tasks := logstorage.MergeDeleteTasks(results)
 
 	return tasks, nil
 }
@@ -578,9 +991,11 @@ func (s *Storage) getValuesWithHits(qctx *logstorage.QueryContext, limit uint64,
 		go func(nodeIdx int) {
 			defer wg.Done()
 
-			sn := s.sns[nodeIdx]
-			vhs, err := callback(ctxWithCancel, sn)
-			results[nodeIdx] = vhs
+			sn, err := s.sns[nodeIdx].callReplica(ctxWithCancel, func(sn *storageNode) error {
+				vhs, err := callback(ctxWithCancel, sn)
+				results[nodeIdx] = vhs
+				return err
+			})
 			errs[nodeIdx] = sn.handleError(ctxWithCancel, cancel, err, qctx.AllowPartialResponse)
 		}(i)
 	}
@@ -595,13 +1010,86 @@ func (s *Storage) getValuesWithHits(qctx *logstorage.QueryContext, limit uint64,
 	return vhs, nil
 }
 
-func (sn *storageNode) deleteRunTask(ctx context.Context, taskID string, timestamp int64, tenantIDs []logstorage.TenantID, f *logstorage.Filter) error {
+func (sn *storageNode) estimateQuery(qctx *logstorage.QueryContext) (*logstorage.QueryEstimate, error) {
+	args := sn.getCommonArgs(EstimateQueryProtocolVersion, qctx)
+
+	path := "/internal/select/estimate"
+	data, reqURL, err := sn.getPlainResponseBodyForPathAndArgs(qctx.Context, path, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var qe logstorage.QueryEstimate
+	if err := json.Unmarshal(data, &qe); err != nil {
+		return nil, fmt.Errorf("cannot parse response from %q: %w; response body: %q", reqURL, err, data)
+	}
+	return &qe, nil
+}
+
+func (sn *storageNode) analyzeQuery(qctx *logstorage.QueryContext) (*logstorage.QueryAnalysis, error) {
+	args := sn.getCommonArgs(AnalyzeQueryProtocolVersion, qctx)
+
+	path := "/internal/select/analyze"
+	data, reqURL, err := sn.getPlainResponseBodyForPathAndArgs(qctx.Context, path, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var qa logstorage.QueryAnalysis
+	if err := json.Unmarshal(data, &qa); err != nil {
+		return nil, fmt.Errorf("cannot parse response from %q: %w; response body: %q", reqURL, err, data)
+	}
+	return &qa, nil
+}
+
+func (sn *storageNode) getStreamCardinality(ctx context.Context, tenantIDs []logstorage.TenantID, start, end int64, limit int, allowPartialResponse bool) (*logstorage.StreamCardinality, error) {
+	args := url.Values{}
+	args.Set("version", StreamCardinalityProtocolVersion)
+	args.Set("tenant_ids", string(logstorage.MarshalTenantIDsToJSON(tenantIDs)))
+	args.Set("start", fmt.Sprintf("%d", start))
+	args.Set("end", fmt.Sprintf("%d", end))
+	args.Set("limit", fmt.Sprintf("%d", limit))
+	args.Set("allow_partial_response", fmt.Sprintf("%v", allowPartialResponse))
+
+	path := "/internal/select/cardinality"
+	data, reqURL, err := sn.getPlainResponseBodyForPathAndArgs(ctx, path, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var sc logstorage.StreamCardinality
+	if err := json.Unmarshal(data, &sc); err != nil {
+		return nil, fmt.Errorf("cannot parse response from %q: %w; response body: %q", reqURL, err, data)
+	}
+	return &sc, nil
+}
+
+func (sn *storageNode) getTenantsUsage(ctx context.Context, allowPartialResponse bool) (*logstorage.TenantsUsage, error) {
+	args := url.Values{}
+	args.Set("version", TenantsUsageProtocolVersion)
+	args.Set("allow_partial_response", fmt.Sprintf("%v", allowPartialResponse))
+
+	path := "/internal/select/tenants"
+	data, reqURL, err := sn.getPlainResponseBodyForPathAndArgs(ctx, path, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var tu logstorage.TenantsUsage
+	if err := json.Unmarshal(data, &tu); err != nil {
+		return nil, fmt.Errorf("cannot parse response from %q: %w; response body: %q", reqURL, err, data)
+	}
+	return &tu, nil
+}
+
+func (sn *storageNode) deleteRunTask(ctx context.Context, taskID string, timestamp int64, tenantIDs []logstorage.TenantID, f *logstorage.Filter, dryRun bool) error {
 	args := url.Values{}
 	args.Set("version", DeleteRunTaskProtocolVersion)
 	args.Set("task_id", taskID)
 	args.Set("timestamp", fmt.Sprintf("%d", timestamp))
 	args.Set("tenant_ids", string(logstorage.MarshalTenantIDsToJSON(tenantIDs)))
 	args.Set("filter", f.String())
+	args.Set("dry_run", fmt.Sprintf("%v", dryRun))
 
 	path := "/internal/delete/run_task"
 	data, reqURL, err := sn.getPlainResponseBodyForPathAndArgs(ctx, path, args)
@@ -615,6 +1103,28 @@ func (sn *storageNode) deleteRunTask(ctx context.Context, taskID string, timesta
 	return nil
 }
 
+func (sn *storageNode) updateRunTask(ctx context.Context, taskID string, timestamp int64, tenantIDs []logstorage.TenantID, f *logstorage.Filter, ru *logstorage.RowsUpdate, dryRun bool) error {
+	args := url.Values{}
+	args.Set("version", UpdateRunTaskProtocolVersion)
+	args.Set("task_id", taskID)
+	args.Set("timestamp", fmt.Sprintf("%d", timestamp))
+	args.Set("tenant_ids", string(logstorage.MarshalTenantIDsToJSON(tenantIDs)))
+	args.Set("filter", f.String())
+	args.Set("update", ru.String())
+	args.Set("dry_run", fmt.Sprintf("%v", dryRun))
+
+	path := "/internal/delete/run_update_task"
+	data, reqURL, err := sn.getPlainResponseBodyForPathAndArgs(ctx, path, args)
+	if err != nil {
+		return err
+	}
+	if len(data) > 0 {
+		return fmt.Errorf("unexpected response body received from %q: %q", reqURL, data)
+	}
+
+	return nil
+}
+
 func (sn *storageNode) deleteStopTask(ctx context.Context, taskID string) error {
 	args := url.Values{}
 	args.Set("version", DeleteStopTaskProtocolVersion)
@@ -632,11 +1142,10 @@ func (sn *storageNode) deleteStopTask(ctx context.Context, taskID string) error
 	return nil
 }
 
-func (sn *storageNode) deleteActiveTasks(ctx context.Context) ([]*logstorage.DeleteTask, error) {
+func (sn *storageNode) getDeleteTasks(ctx context.Context, path, version string) ([]*logstorage.DeleteTask, error) {
 	args := url.Values{}
-	args.Set("version", DeleteActiveTasksProtocolVersion)
+	args.Set("version", version)
 
-	path := "/internal/delete/active_tasks"
 	data, reqURL, err := sn.getPlainResponseBodyForPathAndArgs(ctx, path, args)
 	if err != nil {
 		return nil, err
@@ -678,6 +1187,11 @@ func (sn *storageNode) handleError(ctx context.Context, cancel func(), err error
 
 	sn.sendErrors.Inc()
 
+	if sn.primary != nil && isUnavailableBackendError(err) {
+		// Do not send queries to the unavailable follower until its replication status is successfully checked again.
+		sn.lag.Store(-1)
+	}
+
 	if !allowPartialResponse || !isUnavailableBackendError(err) {
 		// Cancel the remaining parallel queries, since the error must be returned to the client ASAP
 		// without waiting for the remaining parallel queries to other backends.
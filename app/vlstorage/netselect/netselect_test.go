@@ -0,0 +1,136 @@
+package netselect
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+func newTestStorageNode(maxFollowerLag time.Duration, followerLags []time.Duration) *storageNode {
+	ms := metrics.NewSet()
+	s := &Storage{
+		maxFollowerLag: maxFollowerLag,
+	}
+	sn := &storageNode{
+		addr:       "primary",
+		s:          s,
+		sendErrors: ms.NewCounter(`vl_select_remote_send_errors_total{addr="primary"}`),
+	}
+	for i, lag := range followerLags {
+		addr := fmt.Sprintf("follower-%d", i)
+		follower := &storageNode{
+			addr:       addr,
+			s:          s,
+			sendErrors: ms.NewCounter(fmt.Sprintf(`vl_select_remote_send_errors_total{addr=%q}`, addr)),
+			primary:    sn,
+		}
+		follower.lag.Store(lag.Nanoseconds())
+		sn.followers = append(sn.followers, follower)
+	}
+	return sn
+}
+
+func TestStorageNodeGetReplica(t *testing.T) {
+	f := func(maxFollowerLag time.Duration, followerLags []time.Duration, addrsExpected map[string]int) {
+		t.Helper()
+
+		sn := newTestStorageNode(maxFollowerLag, followerLags)
+		addrs := make(map[string]int)
+		for i := 0; i < 6; i++ {
+			replica := sn.getReplica()
+			addrs[replica.addr]++
+		}
+		if fmt.Sprintf("%v", addrs) != fmt.Sprintf("%v", addrsExpected) {
+			t.Fatalf("unexpected replicas; got %v; want %v", addrs, addrsExpected)
+		}
+	}
+
+	// no followers
+	f(time.Minute, nil, map[string]int{
+		"primary": 6,
+	})
+
+	// up-to-date followers
+	f(time.Minute, []time.Duration{time.Second, 0}, map[string]int{
+		"primary":    2,
+		"follower-0": 2,
+		"follower-1": 2,
+	})
+
+	// the lag equal to maxFollowerLag is allowed
+	f(time.Minute, []time.Duration{time.Minute}, map[string]int{
+		"primary":    3,
+		"follower-0": 3,
+	})
+
+	// the follower with the lag exceeding maxFollowerLag is skipped
+	f(time.Minute, []time.Duration{time.Minute + time.Nanosecond, time.Second}, map[string]int{
+		"primary":    2,
+		"follower-1": 4,
+	})
+
+	// the follower with unknown lag is skipped
+	f(time.Minute, []time.Duration{-1, time.Second}, map[string]int{
+		"primary":    2,
+		"follower-1": 4,
+	})
+
+	// all the followers are lagging
+	f(time.Minute, []time.Duration{time.Hour, -1}, map[string]int{
+		"primary": 6,
+	})
+}
+
+func TestStorageNodeCallReplica(t *testing.T) {
+	f := func(followerErr error, addrExpected string, isFollowerUpToDateExpected bool) {
+		t.Helper()
+
+		sn := newTestStorageNode(time.Minute, []time.Duration{time.Second})
+		follower := sn.followers[0]
+
+		// Make sure the follower is selected by getReplica()
+		sn.nextReplicaIdx.Store(0)
+
+		var addrs []string
+		replica, err := sn.callReplica(context.Background(), func(replica *storageNode) error {
+			addrs = append(addrs, replica.addr)
+			if replica == follower {
+				return followerErr
+			}
+			return nil
+		})
+		if addrs[0] != follower.addr {
+			t.Fatalf("unexpected first replica; got %q; want %q", addrs[0], follower.addr)
+		}
+		if replica.addr != addrExpected {
+			t.Fatalf("unexpected replica; got %q; want %q", replica.addr, addrExpected)
+		}
+		if addrExpected == sn.addr {
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		} else if err != followerErr {
+			t.Fatalf("unexpected error; got %v; want %v", err, followerErr)
+		}
+		if isUpToDate := follower.isUpToDate(); isUpToDate != isFollowerUpToDateExpected {
+			t.Fatalf("unexpected isUpToDate for the follower; got %v; want %v", isUpToDate, isFollowerUpToDateExpected)
+		}
+	}
+
+	// successful response from the follower
+	f(nil, "follower-0", true)
+
+	// the unavailable follower must be retried at the primary
+	f(&httpserver.ErrorWithStatusCode{
+		Err:        fmt.Errorf("cannot connect"),
+		StatusCode: http.StatusBadGateway,
+	}, "primary", false)
+
+	// other errors from the follower must be returned as is
+	f(fmt.Errorf("unexpected response"), "follower-0", true)
+}
@@ -0,0 +1,331 @@
+package replication
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/contextutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httputil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/logstorage"
+)
+
+// Status is the replication status returned by /internal/replication/status .
+type Status struct {
+	// IsFollower is set to true if the vlstorage runs in follower mode.
+	IsFollower bool `json:"is_follower"`
+
+	// LastSyncTimestamp is the unix timestamp in seconds for the start of the last successful sync with the primary.
+	//
+	// It is set to 0 if the follower hasn't been synced with the primary yet.
+	LastSyncTimestamp int64 `json:"last_sync_timestamp"`
+
+	// LagSeconds is the replication lag in seconds for the follower.
+	//
+	// The follower contains all the data, which has been flushed at the primary LagSeconds ago.
+	LagSeconds float64 `json:"lag_seconds"`
+}
+
+// Follower replicates partitions from the primary vlstorage into the local storage.
+//
+// Only partitions with changed sets of parts are replicated. Only new parts are fetched from the primary, while the already replicated parts are reused.
+type Follower struct {
+	s *logstorage.Storage
+
+	primaryURL   string
+	authKey      string
+	syncInterval time.Duration
+
+	// c is used for API calls to the primary.
+	c *http.Client
+
+	// fc is used for fetching partition files from the primary.
+	//
+	// It has no overall timeout, since partition files may be big.
+	fc *http.Client
+
+	// startTime is the time when the Follower has been started.
+	startTime time.Time
+
+	// lastSyncTime is the unix timestamp in nanoseconds for the start of the last successful sync.
+	lastSyncTime atomic.Int64
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewFollower starts replication of partitions from the vlstorage at primaryURL into s every syncInterval.
+//
+// The authKey must match -partitionManageAuthKey at the primary.
+//
+// MustStop must be called when the returned Follower is no longer needed.
+func NewFollower(s *logstorage.Storage, primaryURL, authKey string, syncInterval time.Duration) *Follower {
+	if !s.IsFollower() {
+		logger.Panicf("BUG: the storage must be opened in follower mode")
+	}
+
+	tr := httputil.NewTransport(false, "vlstorage_replication")
+	tr.TLSHandshakeTimeout = 20 * time.Second
+	tr.ResponseHeaderTimeout = requestTimeout
+
+	f := &Follower{
+		s:            s,
+		primaryURL:   strings.TrimSuffix(primaryURL, "/"),
+		authKey:      authKey,
+		syncInterval: syncInterval,
+		c: &http.Client{
+			Transport: tr,
+			Timeout:   requestTimeout,
+		},
+		fc: &http.Client{
+			Transport: tr,
+		},
+		startTime: time.Now(),
+		stopCh:    make(chan struct{}),
+	}
+
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		f.runSyncer()
+	}()
+
+	return f
+}
+
+// MustStop stops f.
+func (f *Follower) MustStop() {
+	close(f.stopCh)
+	f.wg.Wait()
+}
+
+// GetStatus returns the current replication status for f.
+func (f *Follower) GetStatus() *Status {
+	st := &Status{
+		IsFollower: true,
+	}
+	lastSyncTime := f.lastSyncTime.Load()
+	if lastSyncTime == 0 {
+		st.LagSeconds = time.Since(f.startTime).Seconds()
+		return st
+	}
+	st.LastSyncTimestamp = lastSyncTime / 1e9
+	st.LagSeconds = time.Since(time.Unix(0, lastSyncTime)).Seconds()
+	return st
+}
+
+// requestTimeout is the timeout for API calls to the primary and for waiting for response headers from the primary.
+const requestTimeout = time.Minute
+
+func (f *Follower) runSyncer() {
+	// The context is canceled on MustStop() call, so the in-flight requests to the primary are interrupted.
+	ctx, cancel := contextutil.NewStopChanContext(f.stopCh)
+	defer cancel()
+
+	f.sync(ctx)
+
+	t := time.NewTicker(f.syncInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-f.stopCh:
+			return
+		case <-t.C:
+			f.sync(ctx)
+		}
+	}
+}
+
+func (f *Follower) sync(ctx context.Context) {
+	syncsTotal.Inc()
+	startTime := time.Now()
+	if err := f.syncPartitions(ctx); err != nil {
+		if ctx.Err() != nil {
+			// The follower is stopped.
+			return
+		}
+		syncErrorsTotal.Inc()
+		logger.Warnf("cannot sync partitions with the primary %s: %s", f.primaryURL, err)
+		return
+	}
+	f.lastSyncTime.Store(startTime.UnixNano())
+}
+
+func (f *Follower) syncPartitions(ctx context.Context) error {
+	var pps []logstorage.PartitionParts
+	if err := f.callPrimary(ctx, "/internal/partition/parts", nil, &pps); err != nil {
+		return err
+	}
+
+	localPPs, err := f.s.PartitionPartsList()
+	if err != nil {
+		return err
+	}
+	localPPsByName := make(map[string]*logstorage.PartitionParts, len(localPPs))
+	for i := range localPPs {
+		localPPsByName[localPPs[i].Name] = &localPPs[i]
+	}
+
+	var firstErr error
+	for i := range pps {
+		pp := &pps[i]
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("the sync has been interrupted: %w", err)
+		}
+
+		if localPP := localPPsByName[pp.Name]; localPP != nil && localPP.HasSameParts(pp) {
+			// The partition hasn't been changed at the primary since the previous sync,
+			// so there is no need in creating a snapshot for it.
+			continue
+		}
+
+		if err := f.syncPartition(ctx, pp.Name); err != nil {
+			err = fmt.Errorf("cannot sync partition %q: %w", pp.Name, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			logger.Warnf("%s", err)
+		}
+	}
+
+	// Drop partitions, which are missing at the primary, e.g. because of retention.
+	for i := range localPPs {
+		name := localPPs[i].Name
+		if slices.ContainsFunc(pps, func(pp logstorage.PartitionParts) bool { return pp.Name == name }) {
+			continue
+		}
+		if err := f.s.PartitionDrop(name); err != nil {
+			logger.Warnf("cannot drop partition %q, which is missing at the primary: %s", name, err)
+		}
+	}
+
+	return firstErr
+}
+
+func (f *Follower) syncPartition(ctx context.Context, name string) error {
+	var snapshotPath string
+	args := url.Values{
+		"name": {name},
+	}
+	if err := f.callPrimary(ctx, "/internal/partition/snapshot/create", args, &snapshotPath); err != nil {
+		return err
+	}
+	snapshotName := filepath.Base(filepath.FromSlash(snapshotPath))
+	defer func() {
+		args := url.Values{
+			"name":     {name},
+			"snapshot": {snapshotName},
+		}
+
+		// Delete the snapshot even if ctx is canceled, since otherwise it would occupy disk space at the primary.
+		// The request duration is limited by f.c timeout.
+		resp, err := f.getPrimary(context.WithoutCancel(ctx), f.c, "/internal/partition/snapshot/delete", args)
+		if err != nil {
+			logger.Warnf("cannot delete snapshot %q for partition %q at the primary: %s", snapshotName, name, err)
+			return
+		}
+		_ = resp.Body.Close()
+	}()
+
+	var files []logstorage.PartitionFile
+	args = url.Values{
+		"name":     {name},
+		"snapshot": {snapshotName},
+	}
+	if err := f.callPrimary(ctx, "/internal/partition/snapshot/files", args, &files); err != nil {
+		return err
+	}
+
+	fetchFile := func(path string, w io.Writer) error {
+		args := url.Values{
+			"name":     {name},
+			"snapshot": {snapshotName},
+			"path":     {path},
+		}
+		resp, err := f.getPrimary(ctx, f.fc, "/internal/partition/snapshot/file", args)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		n, err := io.Copy(w, resp.Body)
+		fetchedBytesTotal.Add(int(n))
+		if err != nil {
+			return fmt.Errorf("cannot read file %q from the primary: %w", path, err)
+		}
+		return nil
+	}
+
+	startTime := time.Now()
+	isChanged, err := f.s.PartitionReplicate(name, files, fetchFile)
+	if err != nil {
+		return err
+	}
+	if isChanged {
+		logger.Infof("replicated partition %q from the primary %s in %.3f seconds", name, f.primaryURL, time.Since(startTime).Seconds())
+	}
+	return nil
+}
+
+func (f *Follower) callPrimary(ctx context.Context, path string, args url.Values, dst any) error {
+	resp, err := f.getPrimary(ctx, f.c, path, args)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("cannot read response from %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, dst); err != nil {
+		return fmt.Errorf("cannot parse response from %s: %w; response body: %q", path, err, data)
+	}
+	return nil
+}
+
+// getPrimary sends GET request to the given path with the given args to the primary via the given client c.
+//
+// The caller must close the body of the returned response.
+func (f *Follower) getPrimary(ctx context.Context, c *http.Client, path string, args url.Values) (*http.Response, error) {
+	if args == nil {
+		args = url.Values{}
+	}
+	if f.authKey != "" {
+		args.Set("authKey", f.authKey)
+	}
+	reqURL := f.primaryURL + path + "?" + args.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create a request for %s: %w", path, err)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot call %s: %w", path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("unexpected response status code from %s: %d; response body: %q", path, resp.StatusCode, data)
+	}
+	return resp, nil
+}
+
+var (
+	syncsTotal        = metrics.NewCounter(`vl_replication_syncs_total`)
+	syncErrorsTotal   = metrics.NewCounter(`vl_replication_sync_errors_total`)
+	fetchedBytesTotal = metrics.NewCounter(`vl_replication_fetched_bytes_total`)
+)
@@ -0,0 +1,401 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// The layout of the backup storage:
+//
+//	parts/<partition>/<db>/<part>/<files>     - the files for the given part
+//	parts/<partition>/<db>/<part>.json        - partManifest for the given part; it is written after all the part files are uploaded
+//	backups/<backupName>/manifest.json        - Manifest for the given backup; it is written after all the parts for the backup are uploaded
+//
+// Parts are immutable, so they are shared among backups. This allows making incremental backups,
+// which upload only the parts missing in the backup storage.
+const (
+	partitionsDirname = "partitions"
+	partsDirname      = "parts"
+	backupsDirname    = "backups"
+	manifestFilename  = "manifest.json"
+	partsFilename     = "parts.json"
+)
+
+// dbNames contains the names of per-partition databases, which must be backed up.
+var dbNames = []string{"indexdb", "datadb"}
+
+// Manifest describes a single backup.
+type Manifest struct {
+	// Name is the backup name.
+	Name string `json:"name"`
+
+	// CreatedAt is the backup creation time in RFC3339 format with nanosecond precision.
+	CreatedAt string `json:"created_at"`
+
+	// Partitions contains the backed up partitions.
+	Partitions []PartitionManifest `json:"partitions"`
+}
+
+// PartitionManifest describes a single partition in the backup.
+type PartitionManifest struct {
+	// Name is the partition name.
+	Name string `json:"name"`
+
+	// Dbs contains per-database manifests for the partition keyed by database name (indexdb, datadb).
+	Dbs map[string]DbManifest `json:"dbs"`
+}
+
+// DbManifest describes a single database inside the partition.
+type DbManifest struct {
+	// PartsJSON contains the contents of parts.json file for the database.
+	PartsJSON string `json:"parts_json"`
+
+	// Parts contains the names of parts for the database.
+	Parts []string `json:"parts"`
+}
+
+// partManifest contains checksums for all the files inside a single part.
+type partManifest struct {
+	Files []fileChecksum `json:"files"`
+}
+
+type fileChecksum struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// PartitionSnapshot is a snapshot for the partition with the given name.
+type PartitionSnapshot struct {
+	// Partition is the partition name.
+	Partition string
+
+	// Path is the path to snapshot created via /internal/partition/snapshot/create.
+	Path string
+}
+
+// Stats contains stats for Backup and Restore.
+type Stats struct {
+	// PartsCopied is the number of parts copied to the destination.
+	PartsCopied int
+
+	// PartsSkipped is the number of parts, which already exist at the destination.
+	PartsSkipped int
+
+	// BytesCopied is the number of bytes copied to the destination.
+	BytesCopied int64
+}
+
+type atomicStats struct {
+	partsCopied  atomic.Int64
+	partsSkipped atomic.Int64
+	bytesCopied  atomic.Int64
+}
+
+func (as *atomicStats) stats() *Stats {
+	return &Stats{
+		PartsCopied:  int(as.partsCopied.Load()),
+		PartsSkipped: int(as.partsSkipped.Load()),
+		BytesCopied:  as.bytesCopied.Load(),
+	}
+}
+
+// Backup uploads the given snapshots to dst under the given backupName.
+//
+// Parts, which already exist at dst with the matching checksums, aren't uploaded again.
+// An error is returned if the part already exists at dst with other contents, since it may be referred by other backups.
+// Up to concurrency parts are uploaded in parallel.
+func Backup(dst RemoteFS, backupName string, snapshots []PartitionSnapshot, concurrency int) (*Stats, error) {
+	if !isSafeName(backupName) {
+		return nil, fmt.Errorf("invalid backup name %q", backupName)
+	}
+	manifestPath := path.Join(backupsDirname, backupName, manifestFilename)
+	ok, err := dst.HasFile(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return nil, fmt.Errorf("backup %q already exists at %s", backupName, dst)
+	}
+
+	m := &Manifest{
+		Name:      backupName,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339Nano),
+	}
+
+	type partToUpload struct {
+		localPath  string
+		remotePath string
+	}
+	var parts []partToUpload
+
+	seenPartitions := make(map[string]bool)
+	for _, ps := range snapshots {
+		if !isSafeName(ps.Partition) {
+			return nil, fmt.Errorf("invalid partition name %q", ps.Partition)
+		}
+		if seenPartitions[ps.Partition] {
+			return nil, fmt.Errorf("duplicate snapshot for partition %q", ps.Partition)
+		}
+		seenPartitions[ps.Partition] = true
+
+		pm := PartitionManifest{
+			Name: ps.Partition,
+			Dbs:  make(map[string]DbManifest, len(dbNames)),
+		}
+		for _, dbName := range dbNames {
+			dbPath := filepath.Join(ps.Path, dbName)
+			partsJSON, partNames, err := readPartNames(dbPath)
+			if err != nil {
+				return nil, err
+			}
+			for _, partName := range partNames {
+				parts = append(parts, partToUpload{
+					localPath:  filepath.Join(dbPath, partName),
+					remotePath: path.Join(partsDirname, ps.Partition, dbName, partName),
+				})
+			}
+			pm.Dbs[dbName] = DbManifest{
+				PartsJSON: string(partsJSON),
+				Parts:     partNames,
+			}
+		}
+		m.Partitions = append(m.Partitions, pm)
+	}
+
+	var as atomicStats
+	err = runParallel(concurrency, len(parts), func(i int) error {
+		p := parts[i]
+		return uploadPart(dst, p.localPath, p.remotePath, &as)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Write the manifest after all the parts are uploaded, so the backup becomes visible only when it is complete.
+	data, err := json.Marshal(m)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal backup manifest: %s", err)
+	}
+	if err := writeRemoteFile(dst, manifestPath, data); err != nil {
+		return nil, err
+	}
+
+	return as.stats(), nil
+}
+
+func readPartNames(dbPath string) ([]byte, []string, error) {
+	partsPath := filepath.Join(dbPath, partsFilename)
+	data, err := os.ReadFile(partsPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot read the list of parts: %w", err)
+	}
+	var partNames []string
+	if err := json.Unmarshal(data, &partNames); err != nil {
+		return nil, nil, fmt.Errorf("cannot parse %q: %w", partsPath, err)
+	}
+	for _, partName := range partNames {
+		if !isSafeName(partName) {
+			return nil, nil, fmt.Errorf("unexpected part name %q at %q", partName, partsPath)
+		}
+	}
+	return data, partNames, nil
+}
+
+func uploadPart(dst RemoteFS, localPath, remotePath string, as *atomicStats) error {
+	files, err := listPartFiles(localPath)
+	if err != nil {
+		return err
+	}
+
+	// Skip the part if it is already uploaded with the same contents.
+	partManifestPath := remotePath + ".json"
+	ok, err := dst.HasFile(partManifestPath)
+	if err != nil {
+		return err
+	}
+	if ok {
+		pm, err := readPartManifest(dst, partManifestPath)
+		if err != nil {
+			return err
+		}
+		if err := verifyLocalPart(localPath, pm); err != nil {
+			// The part mustn't be overwritten, since it may be referred by already existing backups.
+			return fmt.Errorf("the part at %s/%s doesn't match the local part %q: %w; "+
+				"make sure the backup storage isn't shared with other VictoriaLogs instances", dst, remotePath, localPath, err)
+		}
+		as.partsSkipped.Add(1)
+		return nil
+	}
+
+	// The part manifest is missing, so the part isn't referred by backups yet.
+	// Its files may be left after the interrupted backup, so it is safe to overwrite them.
+
+	var pm partManifest
+	for _, f := range files {
+		localFilePath := filepath.Join(localPath, filepath.FromSlash(f.Path))
+		checksum, err := uploadFile(dst, localFilePath, path.Join(remotePath, f.Path), f.Size)
+		if err != nil {
+			return err
+		}
+		pm.Files = append(pm.Files, fileChecksum{
+			Path:   f.Path,
+			Size:   f.Size,
+			SHA256: checksum,
+		})
+		as.bytesCopied.Add(f.Size)
+	}
+
+	data, err := json.Marshal(&pm)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal part manifest: %s", err)
+	}
+	if err := writeRemoteFile(dst, partManifestPath, data); err != nil {
+		return err
+	}
+	as.partsCopied.Add(1)
+	return nil
+}
+
+func uploadFile(dst RemoteFS, localPath, remotePath string, size int64) (string, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	r := io.TeeReader(io.LimitReader(f, size), h)
+	if err := dst.UploadFile(remotePath, r, size); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// listPartFiles returns files with their sizes for the part at the given path. Checksums aren't calculated.
+func listPartFiles(partPath string) ([]fileChecksum, error) {
+	var files []fileChecksum
+	err := filepath.WalkDir(partPath, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return fmt.Errorf("unexpected non-regular file %q", p)
+		}
+		relPath, err := filepath.Rel(partPath, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, fileChecksum{
+			Path: filepath.ToSlash(relPath),
+			Size: fi.Size(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot list files for the part %q: %w", partPath, err)
+	}
+	return files, nil
+}
+
+func readPartManifest(rfs RemoteFS, partManifestPath string) (*partManifest, error) {
+	data, err := readRemoteFile(rfs, partManifestPath)
+	if err != nil {
+		return nil, err
+	}
+	var pm partManifest
+	if err := json.Unmarshal(data, &pm); err != nil {
+		return nil, fmt.Errorf("cannot parse %s/%s: %w", rfs, partManifestPath, err)
+	}
+	for _, f := range pm.Files {
+		if !isSafeRelativePath(f.Path) {
+			return nil, fmt.Errorf("unexpected file path %q at %s/%s", f.Path, rfs, partManifestPath)
+		}
+	}
+	return &pm, nil
+}
+
+// hasSameFiles returns true if pm contains files with the same paths and sizes as files.
+func (pm *partManifest) hasSameFiles(files []fileChecksum) bool {
+	if len(pm.Files) != len(files) {
+		return false
+	}
+	m := make(map[string]int64, len(pm.Files))
+	for _, f := range pm.Files {
+		m[f.Path] = f.Size
+	}
+	for _, f := range files {
+		size, ok := m[f.Path]
+		if !ok || size != f.Size {
+			return false
+		}
+	}
+	return true
+}
+
+func isSafeRelativePath(p string) bool {
+	if p == "" || path.IsAbs(p) || path.Clean(p) != p {
+		return false
+	}
+	return p != ".." && !strings.HasPrefix(p, "../")
+}
+
+// runParallel calls f for i in the range [0..n) using up to concurrency goroutines.
+//
+// It returns the first error returned by f.
+func runParallel(concurrency, n int, f func(i int) error) error {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		failed   atomic.Bool
+	)
+	workCh := make(chan int)
+	for range min(concurrency, n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range workCh {
+				if failed.Load() {
+					continue
+				}
+				if err := f(i); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+					})
+					failed.Store(true)
+				}
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		workCh <- i
+	}
+	close(workCh)
+	wg.Wait()
+
+	return firstErr
+}
@@ -0,0 +1,255 @@
+package backup
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+)
+
+func TestBackupRestore(t *testing.T) {
+	dir := t.TempDir()
+	dstDir := filepath.Join(dir, "backup")
+	storageDataPath := filepath.Join(dir, "storage")
+
+	rfs, err := NewRemoteFS("fs://"+dstDir, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The first backup must upload all the parts
+	snapshot1 := createTestSnapshot(t, filepath.Join(dir, "snapshot1"), map[string][]string{
+		"indexdb": {"A1"},
+		"datadb":  {"B1", "B2"},
+	})
+	stats, err := Backup(rfs, "backup1", []PartitionSnapshot{{Partition: "20250101", Path: snapshot1}}, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkStats(t, stats, 3, 0)
+
+	// The second backup must upload only new parts
+	snapshot2 := createTestSnapshot(t, filepath.Join(dir, "snapshot2"), map[string][]string{
+		"indexdb": {"A1"},
+		"datadb":  {"B2", "B3"},
+	})
+	stats, err = Backup(rfs, "backup2", []PartitionSnapshot{{Partition: "20250101", Path: snapshot2}}, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkStats(t, stats, 1, 2)
+
+	// The backup with the existing name must fail
+	if _, err := Backup(rfs, "backup2", []PartitionSnapshot{{Partition: "20250101", Path: snapshot2}}, 2); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+
+	names, err := ListBackups(rfs)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(names, []string{"backup1", "backup2"}) {
+		t.Fatalf("unexpected backups: %q", names)
+	}
+
+	// Restore the first backup into empty dir
+	stats, err = Restore(rfs, "backup1", storageDataPath, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkStats(t, stats, 3, 0)
+	checkSameDirs(t, filepath.Join(storageDataPath, "partitions", "20250101"), snapshot1)
+
+	// Restore the latest backup over the first backup. Only the missing part must be downloaded,
+	// while the outdated part and the unknown partition must be removed.
+	fs.MustMkdirIfNotExist(filepath.Join(storageDataPath, "partitions", "20250102"))
+	stats, err = Restore(rfs, "", storageDataPath, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkStats(t, stats, 1, 2)
+	checkSameDirs(t, filepath.Join(storageDataPath, "partitions", "20250101"), snapshot2)
+	if fs.IsPathExist(filepath.Join(storageDataPath, "partitions", "20250102")) {
+		t.Fatalf("unexpected partition left after the restore")
+	}
+
+	// Corrupted local part must be downloaded again
+	corruptedPath := filepath.Join(storageDataPath, "partitions", "20250101", "datadb", "B3", "index.bin")
+	if err := os.WriteFile(corruptedPath, []byte("corrupted_B3"), 0644); err != nil {
+		t.Fatalf("cannot write file: %s", err)
+	}
+	stats, err = Restore(rfs, "backup2", storageDataPath, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkStats(t, stats, 1, 2)
+	checkSameDirs(t, filepath.Join(storageDataPath, "partitions", "20250101"), snapshot2)
+
+	// Corrupted part in the backup must be detected
+	if err := os.WriteFile(filepath.Join(dstDir, "parts", "20250101", "datadb", "B3", "index.bin"), []byte("corrupted_B3"), 0644); err != nil {
+		t.Fatalf("cannot write file: %s", err)
+	}
+	fs.MustRemoveDir(filepath.Join(storageDataPath, "partitions", "20250101", "datadb", "B3"))
+	if _, err := Restore(rfs, "backup2", storageDataPath, 2); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+
+	// Missing backup
+	if _, err := Restore(rfs, "missing", storageDataPath, 2); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+}
+
+func TestBackupChangedPartContents(t *testing.T) {
+	dir := t.TempDir()
+	dstDir := filepath.Join(dir, "backup")
+
+	rfs, err := NewRemoteFS("fs://"+dstDir, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	snapshot1 := createTestSnapshot(t, filepath.Join(dir, "snapshot1"), map[string][]string{
+		"indexdb": {"A1"},
+		"datadb":  {"B1"},
+	})
+	stats, err := Backup(rfs, "backup1", []PartitionSnapshot{{Partition: "20250101", Path: snapshot1}}, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkStats(t, stats, 2, 0)
+
+	// The part with the same name and the same file sizes, but with other contents, mustn't overwrite the already uploaded part,
+	// since it is referred by backup1
+	snapshot2 := createTestSnapshot(t, filepath.Join(dir, "snapshot2"), map[string][]string{
+		"indexdb": {"A1"},
+		"datadb":  {"B1"},
+	})
+	if err := os.WriteFile(filepath.Join(snapshot2, "datadb", "B1", "index.bin"), []byte("index.bin_X1"), 0644); err != nil {
+		t.Fatalf("cannot write file: %s", err)
+	}
+	if _, err := Backup(rfs, "backup2", []PartitionSnapshot{{Partition: "20250101", Path: snapshot2}}, 2); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	checkSameDirs(t, filepath.Join(dstDir, "parts", "20250101", "datadb", "B1"), filepath.Join(snapshot1, "datadb", "B1"))
+	if fs.IsPathExist(filepath.Join(dstDir, "backups", "backup2")) {
+		t.Fatalf("the failed backup mustn't be visible")
+	}
+
+	// backup1 must remain restorable
+	storageDataPath := filepath.Join(dir, "restored")
+	if _, err := Restore(rfs, "backup1", storageDataPath, 2); err != nil {
+		t.Fatalf("cannot restore backup1: %s", err)
+	}
+	checkSameDirs(t, filepath.Join(storageDataPath, "partitions", "20250101", "datadb", "B1"), filepath.Join(snapshot1, "datadb", "B1"))
+}
+
+func TestLatestBackup(t *testing.T) {
+	dir := t.TempDir()
+	dstDir := filepath.Join(dir, "backup")
+
+	rfs, err := NewRemoteFS("fs://"+dstDir, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := LatestBackup(rfs); err == nil {
+		t.Fatalf("expecting non-nil error for empty backup storage")
+	}
+
+	snapshot := createTestSnapshot(t, filepath.Join(dir, "snapshot"), map[string][]string{
+		"indexdb": {"A1"},
+		"datadb":  {"B1"},
+	})
+
+	// The latest backup must be selected by creation time instead of name
+	for _, backupName := range []string{"daily", "custom", "adhoc"} {
+		if _, err := Backup(rfs, backupName, []PartitionSnapshot{{Partition: "20250101", Path: snapshot}}, 2); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	name, err := LatestBackup(rfs)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if name != "adhoc" {
+		t.Fatalf("unexpected latest backup; got %q; want %q", name, "adhoc")
+	}
+}
+
+func createTestSnapshot(t *testing.T, path string, parts map[string][]string) string {
+	t.Helper()
+
+	for dbName, partNames := range parts {
+		dbPath := filepath.Join(path, dbName)
+		for _, partName := range partNames {
+			partPath := filepath.Join(dbPath, partName)
+			fs.MustMkdirIfNotExist(partPath)
+			for _, filename := range []string{"metadata.json", "index.bin"} {
+				if err := os.WriteFile(filepath.Join(partPath, filename), []byte(filename+"_"+partName), 0644); err != nil {
+					t.Fatalf("cannot write file: %s", err)
+				}
+			}
+		}
+		data, err := json.Marshal(partNames)
+		if err != nil {
+			t.Fatalf("cannot marshal part names: %s", err)
+		}
+		if err := os.WriteFile(filepath.Join(dbPath, partsFilename), data, 0644); err != nil {
+			t.Fatalf("cannot write file: %s", err)
+		}
+	}
+	return path
+}
+
+func checkStats(t *testing.T, stats *Stats, partsCopiedExpected, partsSkippedExpected int) {
+	t.Helper()
+
+	if stats.PartsCopied != partsCopiedExpected {
+		t.Fatalf("unexpected PartsCopied; got %d; want %d", stats.PartsCopied, partsCopiedExpected)
+	}
+	if stats.PartsSkipped != partsSkippedExpected {
+		t.Fatalf("unexpected PartsSkipped; got %d; want %d", stats.PartsSkipped, partsSkippedExpected)
+	}
+}
+
+func checkSameDirs(t *testing.T, path, pathExpected string) {
+	t.Helper()
+
+	files := readDirFiles(t, path)
+	filesExpected := readDirFiles(t, pathExpected)
+	if !reflect.DeepEqual(files, filesExpected) {
+		t.Fatalf("unexpected files at %q\ngot\n%q\nwant\n%q", path, files, filesExpected)
+	}
+}
+
+func readDirFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	m := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		m[relPath] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("cannot read %q: %s", dir, err)
+	}
+	return m
+}
@@ -0,0 +1,108 @@
+package backup
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// localFS implements RemoteFS for a local filesystem directory.
+type localFS struct {
+	dir string
+}
+
+func newLocalFS(dir string) *localFS {
+	return &localFS{
+		dir: filepath.Clean(dir),
+	}
+}
+
+func (lfs *localFS) String() string {
+	return "fs://" + lfs.dir
+}
+
+func (lfs *localFS) fullPath(path string) string {
+	return filepath.Join(lfs.dir, filepath.FromSlash(path))
+}
+
+func (lfs *localFS) UploadFile(path string, r io.Reader, size int64) error {
+	dstPath := lfs.fullPath(path)
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+		return fmt.Errorf("cannot create directory for %q: %w", dstPath, err)
+	}
+
+	// Write the data to a temporary file at first and then atomically rename it to dstPath,
+	// so partially written files aren't visible to readers.
+	tmpPath := fmt.Sprintf("%s.tmp.%d.%d", dstPath, time.Now().UnixNano(), tmpFileIdx.Add(1))
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("cannot create %q: %w", tmpPath, err)
+	}
+	n, err := io.Copy(f, r)
+	if err == nil && n != size {
+		err = fmt.Errorf("unexpected number of bytes written; got %d; want %d", n, size)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, dstPath)
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("cannot write %q: %w", dstPath, err)
+	}
+	return nil
+}
+
+var tmpFileIdx atomic.Uint64
+
+func (lfs *localFS) DownloadFile(path string, w io.Writer) error {
+	srcPath := lfs.fullPath(path)
+	f, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(w, f); err != nil {
+		return fmt.Errorf("cannot read %q: %w", srcPath, err)
+	}
+	return nil
+}
+
+func (lfs *localFS) HasFile(path string) (bool, error) {
+	fi, err := os.Stat(lfs.fullPath(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return fi.Mode().IsRegular(), nil
+}
+
+func (lfs *localFS) ListDirs(dir string) ([]string, error) {
+	des, err := os.ReadDir(lfs.fullPath(dir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var names []string
+	for _, de := range des {
+		if de.IsDir() {
+			names = append(names, de.Name())
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
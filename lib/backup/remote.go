@@ -0,0 +1,72 @@
+package backup
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RemoteFS is a remote storage for backups.
+//
+// All the paths passed to RemoteFS methods are slash-separated and relative to the root of the storage.
+type RemoteFS interface {
+	// String returns human-readable description of the RemoteFS.
+	String() string
+
+	// UploadFile uploads size bytes from r to the file at the given path.
+	//
+	// The file must become visible to other methods only after it is fully uploaded.
+	UploadFile(path string, r io.Reader, size int64) error
+
+	// DownloadFile writes the contents of the file at the given path to w.
+	DownloadFile(path string, w io.Writer) error
+
+	// HasFile returns true if the file at the given path exists.
+	HasFile(path string) (bool, error)
+
+	// ListDirs returns sorted names of the direct subdirectories for the given dir.
+	ListDirs(dir string) ([]string, error)
+}
+
+// NewRemoteFS returns RemoteFS for the given url.
+//
+// The following urls are supported:
+//
+//   - fs:///path/to/dir - a local filesystem directory. It may be located at NFS or any other mounted filesystem.
+//   - s3://bucket/prefix - S3-compatible object storage. See S3Config for the supported options.
+func NewRemoteFS(url string, s3Cfg *S3Config) (RemoteFS, error) {
+	n := strings.Index(url, "://")
+	if n < 0 {
+		return nil, fmt.Errorf("missing scheme in %q; supported schemes: fs://, s3://", url)
+	}
+	scheme := url[:n]
+	path := url[n+len("://"):]
+	switch scheme {
+	case "fs":
+		if path == "" {
+			return nil, fmt.Errorf("missing directory path in %q", url)
+		}
+		return newLocalFS(path), nil
+	case "s3":
+		bucket, prefix, _ := strings.Cut(path, "/")
+		if bucket == "" {
+			return nil, fmt.Errorf("missing bucket name in %q", url)
+		}
+		return newS3FS(bucket, prefix, s3Cfg)
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q in %q; supported schemes: fs://, s3://", scheme, url)
+	}
+}
+
+func readRemoteFile(rfs RemoteFS, path string) ([]byte, error) {
+	var bb bytes.Buffer
+	if err := rfs.DownloadFile(path, &bb); err != nil {
+		return nil, err
+	}
+	return bb.Bytes(), nil
+}
+
+func writeRemoteFile(rfs RemoteFS, path string, data []byte) error {
+	return rfs.UploadFile(path, bytes.NewReader(data), int64(len(data)))
+}
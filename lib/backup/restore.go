@@ -0,0 +1,278 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// ListBackups returns sorted names of complete backups stored at src.
+func ListBackups(src RemoteFS) ([]string, error) {
+	names, err := src.ListDirs(backupsDirname)
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, name := range names {
+		ok, err := src.HasFile(path.Join(backupsDirname, name, manifestFilename))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			result = append(result, name)
+		}
+	}
+	return result, nil
+}
+
+// LatestBackup returns the name of the most recently created complete backup stored at src.
+//
+// Backups are ordered by the creation time stored in their manifests, since backup names can be arbitrary.
+func LatestBackup(src RemoteFS) (string, error) {
+	names, err := ListBackups(src)
+	if err != nil {
+		return "", err
+	}
+	var latestName string
+	var latestTime time.Time
+	for _, name := range names {
+		m, err := ReadManifest(src, name)
+		if err != nil {
+			return "", err
+		}
+		t, err := time.Parse(time.RFC3339Nano, m.CreatedAt)
+		if err != nil {
+			return "", fmt.Errorf("cannot parse created_at=%q in the manifest for backup %q: %w", m.CreatedAt, name, err)
+		}
+		if latestName == "" || !t.Before(latestTime) {
+			latestName = name
+			latestTime = t
+		}
+	}
+	if latestName == "" {
+		return "", fmt.Errorf("cannot find backups at %s", src)
+	}
+	return latestName, nil
+}
+
+// ReadManifest reads the manifest for the backup with the given backupName from src.
+func ReadManifest(src RemoteFS, backupName string) (*Manifest, error) {
+	if !isSafeName(backupName) {
+		return nil, fmt.Errorf("invalid backup name %q", backupName)
+	}
+	manifestPath := path.Join(backupsDirname, backupName, manifestFilename)
+	data, err := readRemoteFile(src, manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read manifest for backup %q at %s: %w", backupName, src, err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("cannot parse manifest for backup %q at %s: %w", backupName, src, err)
+	}
+	for _, pm := range m.Partitions {
+		if !isSafeName(pm.Name) {
+			return nil, fmt.Errorf("unexpected partition name %q in the manifest for backup %q", pm.Name, backupName)
+		}
+		for _, dbName := range dbNames {
+			for _, partName := range pm.Dbs[dbName].Parts {
+				if !isSafeName(partName) {
+					return nil, fmt.Errorf("unexpected part name %q in the manifest for backup %q", partName, backupName)
+				}
+			}
+		}
+	}
+	return &m, nil
+}
+
+// Restore restores the backup with the given backupName from src to storageDataPath.
+//
+// The latest backup is restored if backupName is empty.
+//
+// VictoriaLogs must be stopped during the restore. Partitions missing in the backup are removed from storageDataPath.
+// Parts, which already exist at storageDataPath with the matching checksums, aren't downloaded again.
+// Checksums for all the downloaded files are verified. Up to concurrency parts are restored in parallel.
+func Restore(src RemoteFS, backupName, storageDataPath string, concurrency int) (*Stats, error) {
+	if backupName == "" {
+		name, err := LatestBackup(src)
+		if err != nil {
+			return nil, err
+		}
+		backupName = name
+	}
+	m, err := ReadManifest(src, backupName)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("restoring backup %q created at %s from %s to %q", backupName, m.CreatedAt, src, storageDataPath)
+
+	fs.MustMkdirIfNotExist(storageDataPath)
+	flockF := fs.MustCreateFlockFile(storageDataPath)
+	defer fs.MustClose(flockF)
+
+	partitionsPath := filepath.Join(storageDataPath, partitionsDirname)
+	fs.MustMkdirIfNotExist(partitionsPath)
+
+	// Remove partitions missing in the backup.
+	partitionNames := make([]string, 0, len(m.Partitions))
+	for _, pm := range m.Partitions {
+		partitionNames = append(partitionNames, pm.Name)
+	}
+	mustRemoveUnknownDirs(partitionsPath, partitionNames)
+
+	var as atomicStats
+	for _, pm := range m.Partitions {
+		partitionPath := filepath.Join(partitionsPath, pm.Name)
+		fs.MustMkdirIfNotExist(partitionPath)
+
+		for _, dbName := range dbNames {
+			dm, ok := pm.Dbs[dbName]
+			if !ok {
+				return nil, fmt.Errorf("missing %s for partition %q in the manifest for backup %q", dbName, pm.Name, backupName)
+			}
+			dbPath := filepath.Join(partitionPath, dbName)
+			fs.MustMkdirIfNotExist(dbPath)
+			mustRemoveUnknownDirs(dbPath, dm.Parts)
+
+			err := runParallel(concurrency, len(dm.Parts), func(i int) error {
+				partName := dm.Parts[i]
+				localPath := filepath.Join(dbPath, partName)
+				remotePath := path.Join(partsDirname, pm.Name, dbName, partName)
+				return restorePart(src, remotePath, localPath, &as)
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			// Write parts.json after all the parts are restored.
+			fs.MustWriteAtomic(filepath.Join(dbPath, partsFilename), []byte(dm.PartsJSON), true)
+			fs.MustSyncPath(dbPath)
+		}
+		fs.MustSyncPath(partitionPath)
+	}
+	fs.MustSyncPathAndParentDir(partitionsPath)
+
+	return as.stats(), nil
+}
+
+func restorePart(src RemoteFS, remotePath, localPath string, as *atomicStats) error {
+	pm, err := readPartManifest(src, remotePath+".json")
+	if err != nil {
+		return err
+	}
+
+	if fs.IsPathExist(localPath) {
+		if err := verifyLocalPart(localPath, pm); err == nil {
+			as.partsSkipped.Add(1)
+			return nil
+		}
+		fs.MustRemoveDir(localPath)
+	}
+
+	fs.MustMkdirFailIfExist(localPath)
+	for _, f := range pm.Files {
+		remoteFilePath := path.Join(remotePath, f.Path)
+		localFilePath := filepath.Join(localPath, filepath.FromSlash(f.Path))
+		if err := downloadFile(src, remoteFilePath, localFilePath, &f); err != nil {
+			return err
+		}
+		as.bytesCopied.Add(f.Size)
+	}
+	fs.MustSyncPath(localPath)
+
+	as.partsCopied.Add(1)
+	return nil
+}
+
+func downloadFile(src RemoteFS, remotePath, localPath string, fc *fileChecksum) error {
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	err = src.DownloadFile(remotePath, io.MultiWriter(f, h))
+	if err == nil {
+		err = f.Sync()
+	}
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return fmt.Errorf("cannot download %s/%s to %q: %w", src, remotePath, localPath, err)
+	}
+
+	fi, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
+	if fi.Size() != fc.Size {
+		return fmt.Errorf("unexpected size for %s/%s; got %d bytes; want %d bytes", src, remotePath, fi.Size(), fc.Size)
+	}
+	checksum := hex.EncodeToString(h.Sum(nil))
+	if checksum != fc.SHA256 {
+		return fmt.Errorf("checksum mismatch for %s/%s; got %s; want %s", src, remotePath, checksum, fc.SHA256)
+	}
+	return nil
+}
+
+// verifyLocalPart verifies that the part at partPath contains exactly the files from pm with the matching checksums.
+func verifyLocalPart(partPath string, pm *partManifest) error {
+	files, err := listPartFiles(partPath)
+	if err != nil {
+		return err
+	}
+	if !pm.hasSameFiles(files) {
+		return fmt.Errorf("the part at %q contains unexpected files", partPath)
+	}
+	for _, fc := range pm.Files {
+		checksum, err := fileSHA256(filepath.Join(partPath, filepath.FromSlash(fc.Path)))
+		if err != nil {
+			return err
+		}
+		if checksum != fc.SHA256 {
+			return fmt.Errorf("checksum mismatch for %q", fc.Path)
+		}
+	}
+	return nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// mustRemoveUnknownDirs removes subdirectories at dir, which are missing in names.
+func mustRemoveUnknownDirs(dir string, names []string) {
+	des := fs.MustReadDir(dir)
+	for _, de := range des {
+		if !de.IsDir() || slices.Contains(names, de.Name()) {
+			continue
+		}
+		fs.MustRemoveDir(filepath.Join(dir, de.Name()))
+	}
+}
+
+func isSafeName(name string) bool {
+	return name != "" && name != "." && name != ".." && name == filepath.Base(name) && name == path.Base(name)
+}
@@ -0,0 +1,446 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Config contains options for S3-compatible object storage.
+type S3Config struct {
+	// Endpoint is the url of S3-compatible object storage, e.g. http://minio:9000 .
+	//
+	// https://s3.<Region>.amazonaws.com is used if Endpoint is empty.
+	Endpoint string
+
+	// Region is the region to use when signing requests. us-east-1 is used if Region is empty.
+	Region string
+
+	// ForcePathStyle enables path-style addressing (http://endpoint/bucket/key) instead of
+	// virtual-hosted-style addressing (http://bucket.endpoint/key).
+	ForcePathStyle bool
+
+	// AccessKeyID, SecretAccessKey and SessionToken are used for signing requests.
+	//
+	// Requests aren't signed if AccessKeyID is empty.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// MultipartChunkSize is the size of chunks for multipart uploads of big files.
+	//
+	// Files bigger than MultipartChunkSize are uploaded via multipart upload. 64MiB is used by default.
+	MultipartChunkSize int64
+}
+
+const defaultMultipartChunkSize = 64 * 1024 * 1024
+
+// s3FS implements RemoteFS for S3-compatible object storage.
+type s3FS struct {
+	bucket string
+	prefix string
+
+	endpoint       *url.URL
+	region         string
+	forcePathStyle bool
+
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+
+	multipartChunkSize int64
+
+	c *http.Client
+}
+
+func newS3FS(bucket, prefix string, cfg *S3Config) (*s3FS, error) {
+	if cfg == nil {
+		cfg = &S3Config{}
+	}
+
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse S3 endpoint %q: %w", endpoint, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("unsupported S3 endpoint %q; it must have http://host or https://host form", endpoint)
+	}
+
+	chunkSize := cfg.MultipartChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultMultipartChunkSize
+	}
+
+	return &s3FS{
+		bucket: bucket,
+		prefix: strings.Trim(prefix, "/"),
+
+		endpoint:       u,
+		region:         region,
+		forcePathStyle: cfg.ForcePathStyle,
+
+		accessKeyID:     cfg.AccessKeyID,
+		secretAccessKey: cfg.SecretAccessKey,
+		sessionToken:    cfg.SessionToken,
+
+		multipartChunkSize: chunkSize,
+
+		c: &http.Client{},
+	}, nil
+}
+
+func (sfs *s3FS) String() string {
+	if sfs.prefix == "" {
+		return "s3://" + sfs.bucket
+	}
+	return "s3://" + sfs.bucket + "/" + sfs.prefix
+}
+
+func (sfs *s3FS) objectKey(path string) string {
+	path = strings.Trim(path, "/")
+	if sfs.prefix == "" {
+		return path
+	}
+	if path == "" {
+		return sfs.prefix
+	}
+	return sfs.prefix + "/" + path
+}
+
+func (sfs *s3FS) UploadFile(path string, r io.Reader, size int64) error {
+	key := sfs.objectKey(path)
+	if size <= sfs.multipartChunkSize {
+		resp, err := sfs.do(http.MethodPut, key, nil, r, size)
+		if err != nil {
+			return fmt.Errorf("cannot upload %q: %w", key, err)
+		}
+		_ = resp.Body.Close()
+		return nil
+	}
+	if err := sfs.uploadMultipart(key, r, size); err != nil {
+		return fmt.Errorf("cannot upload %q: %w", key, err)
+	}
+	return nil
+}
+
+func (sfs *s3FS) uploadMultipart(key string, r io.Reader, size int64) error {
+	resp, err := sfs.do(http.MethodPost, key, url.Values{"uploads": {""}}, nil, 0)
+	if err != nil {
+		return fmt.Errorf("cannot initiate multipart upload: %w", err)
+	}
+	var imur struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&imur)
+	_ = resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("cannot parse response for multipart upload initiation: %w", err)
+	}
+
+	type completedPart struct {
+		PartNumber int
+		ETag       string
+	}
+	var cmu struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}
+
+	uploadParts := func() error {
+		for offset := int64(0); offset < size; offset += sfs.multipartChunkSize {
+			chunkSize := min(sfs.multipartChunkSize, size-offset)
+			partNumber := len(cmu.Parts) + 1
+			args := url.Values{
+				"partNumber": {strconv.Itoa(partNumber)},
+				"uploadId":   {imur.UploadID},
+			}
+			resp, err := sfs.do(http.MethodPut, key, args, io.LimitReader(r, chunkSize), chunkSize)
+			if err != nil {
+				return fmt.Errorf("cannot upload part #%d: %w", partNumber, err)
+			}
+			_ = resp.Body.Close()
+			cmu.Parts = append(cmu.Parts, completedPart{
+				PartNumber: partNumber,
+				ETag:       resp.Header.Get("ETag"),
+			})
+		}
+
+		data, err := xml.Marshal(&cmu)
+		if err != nil {
+			return fmt.Errorf("cannot marshal multipart upload completion request: %w", err)
+		}
+		resp, err := sfs.do(http.MethodPost, key, url.Values{"uploadId": {imur.UploadID}}, bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return fmt.Errorf("cannot complete multipart upload: %w", err)
+		}
+		_ = resp.Body.Close()
+		return nil
+	}
+
+	if err := uploadParts(); err != nil {
+		// Abort the upload in order to free up the storage space occupied by already uploaded parts.
+		if resp, errAbort := sfs.do(http.MethodDelete, key, url.Values{"uploadId": {imur.UploadID}}, nil, 0); errAbort == nil {
+			_ = resp.Body.Close()
+		}
+		return err
+	}
+	return nil
+}
+
+func (sfs *s3FS) DownloadFile(path string, w io.Writer) error {
+	key := sfs.objectKey(path)
+	resp, err := sfs.do(http.MethodGet, key, nil, nil, 0)
+	if err != nil {
+		return fmt.Errorf("cannot download %q: %w", key, err)
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("cannot read %q: %w", key, err)
+	}
+	return nil
+}
+
+func (sfs *s3FS) HasFile(path string) (bool, error) {
+	key := sfs.objectKey(path)
+	req, err := sfs.newRequest(http.MethodHead, key, nil, nil, 0)
+	if err != nil {
+		return false, err
+	}
+	resp, err := sfs.c.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("cannot check for %q: %w", key, err)
+	}
+	_ = resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("cannot check for %q: unexpected response status code %d", key, resp.StatusCode)
+	}
+}
+
+func (sfs *s3FS) ListDirs(dir string) ([]string, error) {
+	prefix := sfs.objectKey(dir)
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	var names []string
+	continuationToken := ""
+	for {
+		args := url.Values{
+			"list-type": {"2"},
+			"prefix":    {prefix},
+			"delimiter": {"/"},
+		}
+		if continuationToken != "" {
+			args.Set("continuation-token", continuationToken)
+		}
+		resp, err := sfs.do(http.MethodGet, "", args, nil, 0)
+		if err != nil {
+			return nil, fmt.Errorf("cannot list %q: %w", prefix, err)
+		}
+		var lbr struct {
+			CommonPrefixes []struct {
+				Prefix string
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&lbr)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse response for listing %q: %w", prefix, err)
+		}
+
+		for _, cp := range lbr.CommonPrefixes {
+			name := strings.TrimSuffix(strings.TrimPrefix(cp.Prefix, prefix), "/")
+			if name != "" {
+				names = append(names, name)
+			}
+		}
+		if !lbr.IsTruncated || lbr.NextContinuationToken == "" {
+			break
+		}
+		continuationToken = lbr.NextContinuationToken
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// do performs the given request and returns the response with 2xx status code.
+//
+// The caller must close the response body.
+func (sfs *s3FS) do(method, key string, args url.Values, body io.Reader, size int64) (*http.Response, error) {
+	req, err := sfs.newRequest(method, key, args, body, size)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := sfs.c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("unexpected response status code %d; response body: %q", resp.StatusCode, respBody)
+	}
+	return resp, nil
+}
+
+func (sfs *s3FS) newRequest(method, key string, args url.Values, body io.Reader, size int64) (*http.Request, error) {
+	host := sfs.endpoint.Host
+	var path string
+	if sfs.forcePathStyle {
+		path = "/" + sfs.bucket + "/" + key
+	} else {
+		host = sfs.bucket + "." + host
+		path = "/" + key
+	}
+	escapedPath := s3EscapePath(path)
+	query := s3CanonicalQuery(args)
+
+	reqURL := sfs.endpoint.Scheme + "://" + host + escapedPath
+	if query != "" {
+		reqURL += "?" + query
+	}
+	if body == nil || size == 0 {
+		body = http.NoBody
+	}
+	req, err := http.NewRequest(method, reqURL, body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create request to %q: %w", reqURL, err)
+	}
+	req.ContentLength = size
+	// Make sure the request path is sent exactly as it has been signed.
+	req.URL.Opaque = "//" + host + escapedPath
+
+	if sfs.accessKeyID != "" {
+		sfs.signRequest(req, host, escapedPath, query, time.Now().UTC())
+	}
+	return req, nil
+}
+
+// signRequest signs req according to https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func (sfs *s3FS) signRequest(req *http.Request, host, escapedPath, query string, t time.Time) {
+	const payloadHash = "UNSIGNED-PAYLOAD"
+	amzDate := t.Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if sfs.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", sfs.sessionToken)
+	}
+
+	headers := map[string]string{
+		"host":                 host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if sfs.sessionToken != "" {
+		headers["x-amz-security-token"] = sfs.sessionToken
+	}
+	headerNames := make([]string, 0, len(headers))
+	for name := range headers {
+		headerNames = append(headerNames, name)
+	}
+	slices.Sort(headerNames)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range headerNames {
+		canonicalHeaders.WriteString(name)
+		canonicalHeaders.WriteByte(':')
+		canonicalHeaders.WriteString(strings.TrimSpace(headers[name]))
+		canonicalHeaders.WriteByte('\n')
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		escapedPath,
+		query,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + sfs.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+sfs.secretAccessKey), date)
+	key = hmacSHA256(key, sfs.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", sfs.accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(s))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// s3EscapePath escapes path according to S3 rules. Slashes aren't escaped.
+func s3EscapePath(path string) string {
+	return s3Escape(path, false)
+}
+
+// s3CanonicalQuery returns query string with sorted args as required by S3 signature.
+func s3CanonicalQuery(args url.Values) string {
+	keys := make([]string, 0, len(args))
+	for k := range args {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	var a []string
+	for _, k := range keys {
+		for _, v := range args[k] {
+			a = append(a, s3Escape(k, true)+"="+s3Escape(v, true))
+		}
+	}
+	return strings.Join(a, "&")
+}
+
+func s3Escape(s string, escapeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' || c == '/' && !escapeSlash {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
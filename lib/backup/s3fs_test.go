@@ -0,0 +1,228 @@
+package backup
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestS3FS(t *testing.T) {
+	fss := newFakeS3Server(t, "bucket")
+	defer fss.Close()
+
+	rfs, err := NewRemoteFS("s3://bucket/backups/node1", &S3Config{
+		Endpoint:           fss.URL,
+		ForcePathStyle:     true,
+		AccessKeyID:        "foo",
+		SecretAccessKey:    "bar",
+		MultipartChunkSize: 5,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// small file
+	if err := writeRemoteFile(rfs, "a/b/small.bin", []byte("abc")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// big file, which must be uploaded via multipart upload
+	if err := writeRemoteFile(rfs, "a/c/big.bin", []byte("0123456789abcdef")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	f := func(path, dataExpected string) {
+		t.Helper()
+
+		ok, err := rfs.HasFile(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !ok {
+			t.Fatalf("missing %q", path)
+		}
+		data, err := readRemoteFile(rfs, path)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if string(data) != dataExpected {
+			t.Fatalf("unexpected data for %q; got %q; want %q", path, data, dataExpected)
+		}
+	}
+	f("a/b/small.bin", "abc")
+	f("a/c/big.bin", "0123456789abcdef")
+
+	ok, err := rfs.HasFile("a/missing.bin")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ok {
+		t.Fatalf("unexpected file found")
+	}
+	if _, err := readRemoteFile(rfs, "a/missing.bin"); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+
+	names, err := rfs.ListDirs("a")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(names, []string{"b", "c"}) {
+		t.Fatalf("unexpected dirs: %q", names)
+	}
+
+	// Verify backup and restore via S3
+	dir := t.TempDir()
+	snapshot := createTestSnapshot(t, filepath.Join(dir, "snapshot"), map[string][]string{
+		"indexdb": {"A1"},
+		"datadb":  {"B1"},
+	})
+	stats, err := Backup(rfs, "backup1", []PartitionSnapshot{{Partition: "20250101", Path: snapshot}}, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkStats(t, stats, 2, 0)
+
+	storageDataPath := filepath.Join(dir, "storage")
+	stats, err = Restore(rfs, "", storageDataPath, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkStats(t, stats, 2, 0)
+	checkSameDirs(t, filepath.Join(storageDataPath, "partitions", "20250101"), snapshot)
+}
+
+func TestS3Escape(t *testing.T) {
+	f := func(s string, escapeSlash bool, resultExpected string) {
+		t.Helper()
+
+		result := s3Escape(s, escapeSlash)
+		if result != resultExpected {
+			t.Fatalf("unexpected result; got %q; want %q", result, resultExpected)
+		}
+	}
+
+	f("", false, "")
+	f("/bucket/foo-bar_baz.~/a.json", false, "/bucket/foo-bar_baz.~/a.json")
+	f("a b/c+d=", false, "a%20b/c%2Bd%3D")
+	f("a/b", true, "a%2Fb")
+}
+
+type fakeS3Server struct {
+	*httptest.Server
+
+	t      *testing.T
+	bucket string
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	uploads map[string]map[int][]byte
+}
+
+func newFakeS3Server(t *testing.T, bucket string) *fakeS3Server {
+	fss := &fakeS3Server{
+		t:       t,
+		bucket:  bucket,
+		objects: make(map[string][]byte),
+		uploads: make(map[string]map[int][]byte),
+	}
+	fss.Server = httptest.NewServer(http.HandlerFunc(fss.handler))
+	return fss
+}
+
+func (fss *fakeS3Server) handler(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=foo/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+fss.bucket)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	key = strings.TrimPrefix(key, "/")
+	args := r.URL.Query()
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	fss.mu.Lock()
+	defer fss.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodPut && args.Has("uploadId"):
+		uploadID := args.Get("uploadId")
+		var partNumber int
+		_, _ = fmt.Sscanf(args.Get("partNumber"), "%d", &partNumber)
+		fss.uploads[uploadID][partNumber] = data
+		w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, partNumber))
+	case r.Method == http.MethodPut:
+		fss.objects[key] = data
+	case r.Method == http.MethodPost && args.Has("uploads"):
+		uploadID := fmt.Sprintf("upload-%d", len(fss.uploads))
+		fss.uploads[uploadID] = make(map[int][]byte)
+		fmt.Fprintf(w, `<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>`, uploadID)
+	case r.Method == http.MethodPost && args.Has("uploadId"):
+		var cmu struct {
+			Parts []struct {
+				PartNumber int
+				ETag       string
+			} `xml:"Part"`
+		}
+		if err := xml.Unmarshal(data, &cmu); err != nil {
+			fss.t.Errorf("cannot parse multipart upload completion request: %s", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		parts := fss.uploads[args.Get("uploadId")]
+		var obj []byte
+		for i, p := range cmu.Parts {
+			if p.PartNumber != i+1 || p.ETag != fmt.Sprintf(`"etag-%d"`, i+1) {
+				fss.t.Errorf("unexpected part #%d: %+v", i, p)
+			}
+			obj = append(obj, parts[p.PartNumber]...)
+		}
+		fss.objects[key] = obj
+	case r.Method == http.MethodGet && args.Get("list-type") == "2":
+		prefix := args.Get("prefix")
+		var prefixes []string
+		for k := range fss.objects {
+			tail, ok := strings.CutPrefix(k, prefix)
+			if !ok {
+				continue
+			}
+			if n := strings.IndexByte(tail, '/'); n >= 0 {
+				p := prefix + tail[:n+1]
+				if !slices.Contains(prefixes, p) {
+					prefixes = append(prefixes, p)
+				}
+			}
+		}
+		fmt.Fprintf(w, `<ListBucketResult><IsTruncated>false</IsTruncated>`)
+		for _, p := range prefixes {
+			fmt.Fprintf(w, `<CommonPrefixes><Prefix>%s</Prefix></CommonPrefixes>`, p)
+		}
+		fmt.Fprintf(w, `</ListBucketResult>`)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		obj, ok := fss.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(obj)
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,257 @@
+package geoip
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// Info contains GeoIP information for a single IP address.
+type Info struct {
+	// Country is ISO 3166-1 alpha-2 country code such as US or DE.
+	Country string
+
+	// CountryName is English country name.
+	CountryName string
+
+	// City is English city name.
+	City string
+
+	// ASN is autonomous system number.
+	ASN string
+
+	// ASOrg is the organization associated with ASN.
+	ASOrg string
+}
+
+// Reset resets gi.
+func (gi *Info) Reset() {
+	*gi = Info{}
+}
+
+// IsEmpty returns true if gi contains no information.
+func (gi *Info) IsEmpty() bool {
+	return *gi == Info{}
+}
+
+// DB provides GeoIP lookups over a set of MaxMind DB (.mmdb) files.
+//
+// Files are automatically re-read when they are changed.
+type DB struct {
+	files []*dbFile
+
+	wg     sync.WaitGroup
+	stopCh chan struct{}
+}
+
+type dbFile struct {
+	path string
+
+	r atomic.Pointer[reader]
+
+	modTime time.Time
+	size    int64
+}
+
+// MustOpenDB opens DB for the MaxMind DB files at the given paths.
+//
+// The files are checked for changes every checkInterval and are re-read on changes.
+//
+// MustClose must be called on the returned DB when it is no longer needed.
+func MustOpenDB(paths []string, checkInterval time.Duration) *DB {
+	db := &DB{
+		stopCh: make(chan struct{}),
+	}
+	for _, path := range paths {
+		f := &dbFile{
+			path: path,
+		}
+		if err := f.reload(); err != nil {
+			logger.Fatalf("cannot open GeoIP database: %s", err)
+		}
+		db.files = append(db.files, f)
+	}
+
+	if checkInterval > 0 {
+		db.wg.Add(1)
+		go func() {
+			defer db.wg.Done()
+			db.runReloader(checkInterval)
+		}()
+	}
+	return db
+}
+
+// MustClose stops db.
+func (db *DB) MustClose() {
+	close(db.stopCh)
+	db.wg.Wait()
+}
+
+func (db *DB) runReloader(checkInterval time.Duration) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-db.stopCh:
+			return
+		case <-ticker.C:
+			for _, f := range db.files {
+				if err := f.reloadIfChanged(); err != nil {
+					reloadErrorsTotal.Inc()
+					logger.Errorf("cannot reload GeoIP database; continuing using the previously loaded database: %s", err)
+				}
+			}
+		}
+	}
+}
+
+func (f *dbFile) reloadIfChanged() error {
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return fmt.Errorf("cannot stat %q: %w", f.path, err)
+	}
+	if fi.ModTime().Equal(f.modTime) && fi.Size() == f.size {
+		return nil
+	}
+	if err := f.reload(); err != nil {
+		return err
+	}
+	logger.Infof("reloaded GeoIP database from %q", f.path)
+	return nil
+}
+
+func (f *dbFile) reload() error {
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return fmt.Errorf("cannot stat %q: %w", f.path, err)
+	}
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return fmt.Errorf("cannot read %q: %w", f.path, err)
+	}
+	r, err := newReader(data)
+	if err != nil {
+		return fmt.Errorf("cannot parse MaxMind DB file %q: %w", f.path, err)
+	}
+	f.r.Store(r)
+	f.modTime = fi.ModTime()
+	f.size = fi.Size()
+	reloadsTotal.Inc()
+	return nil
+}
+
+// Lookup fills dst with GeoIP information for the given ip.
+//
+// It returns false if ip cannot be parsed or if no information is found for it.
+func (db *DB) Lookup(dst *Info, ip string) bool {
+	dst.Reset()
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	for _, f := range db.files {
+		r := f.r.Load()
+		v, err := r.lookup(addr)
+		if err != nil {
+			lookupErrorsTotal.Inc()
+			continue
+		}
+		if m, ok := v.(map[string]any); ok {
+			dst.updateFromRecord(m)
+		}
+	}
+	return !dst.IsEmpty()
+}
+
+// updateFromRecord updates empty gi fields from the given MaxMind DB record.
+//
+// It supports records from City, Country and ASN databases.
+func (gi *Info) updateFromRecord(m map[string]any) {
+	if gi.Country == "" {
+		gi.Country = getString(m, "country", "iso_code")
+	}
+	if gi.CountryName == "" {
+		gi.CountryName = getString(m, "country", "names", "en")
+	}
+	if gi.City == "" {
+		gi.City = getString(m, "city", "names", "en")
+	}
+	if gi.ASN == "" {
+		if n, ok := m["autonomous_system_number"].(uint64); ok {
+			gi.ASN = strconv.FormatUint(n, 10)
+		}
+	}
+	if gi.ASOrg == "" {
+		gi.ASOrg = getString(m, "autonomous_system_organization")
+	}
+}
+
+func getString(m map[string]any, path ...string) string {
+	for _, key := range path[:len(path)-1] {
+		v, ok := m[key].(map[string]any)
+		if !ok {
+			return ""
+		}
+		m = v
+	}
+	s, _ := m[path[len(path)-1]].(string)
+	return s
+}
+
+var globalDB atomic.Pointer[DB]
+
+// MustInit initializes the global GeoIP database from the MaxMind DB files at the given paths.
+//
+// It does nothing if paths are empty.
+//
+// Stop must be called when the global GeoIP database is no longer needed.
+func MustInit(paths []string, checkInterval time.Duration) {
+	if len(paths) == 0 {
+		return
+	}
+	db := MustOpenDB(paths, checkInterval)
+	if !globalDB.CompareAndSwap(nil, db) {
+		logger.Panicf("BUG: MustInit() has been already called")
+	}
+}
+
+// Stop stops the global GeoIP database initialized via MustInit.
+func Stop() {
+	db := globalDB.Swap(nil)
+	if db != nil {
+		db.MustClose()
+	}
+}
+
+// IsEnabled returns true if the global GeoIP database is initialized via MustInit.
+func IsEnabled() bool {
+	return globalDB.Load() != nil
+}
+
+// Lookup fills dst with GeoIP information for the given ip from the global GeoIP database.
+//
+// It returns false if the global GeoIP database isn't initialized, if ip cannot be parsed or if no information is found for it.
+func Lookup(dst *Info, ip string) bool {
+	db := globalDB.Load()
+	if db == nil {
+		dst.Reset()
+		return false
+	}
+	return db.Lookup(dst, ip)
+}
+
+var (
+	reloadsTotal      = metrics.NewCounter(`vl_geoip_reloads_total`)
+	reloadErrorsTotal = metrics.NewCounter(`vl_geoip_reload_errors_total`)
+	lookupErrorsTotal = metrics.NewCounter(`vl_geoip_lookup_errors_total`)
+)
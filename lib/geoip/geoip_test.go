@@ -0,0 +1,330 @@
+package geoip
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDBLookup(t *testing.T) {
+	cityRecord := testMap(
+		"city", testMap(
+			"names", testMap("en", testString("Berlin")),
+		),
+		"country", testMap(
+			"iso_code", testString("DE"),
+			"names", testMap("en", testString("Germany")),
+		),
+	)
+	asnRecord := testMap(
+		"autonomous_system_number", testUint32(3320),
+		"autonomous_system_organization", testString("Deutsche Telekom AG"),
+	)
+	ipv6Record := testMap(
+		"country", testMap(
+			"iso_code", testString("US"),
+			"names", testMap("en", testString("United States")),
+		),
+	)
+
+	dir := t.TempDir()
+	cityPath := filepath.Join(dir, "city.mmdb")
+	asnPath := filepath.Join(dir, "asn.mmdb")
+	writeTestDB(t, cityPath, "GeoLite2-City", []testNetwork{
+		{"1.2.3.0/24", cityRecord},
+		{"2001:db8::/32", ipv6Record},
+	})
+	writeTestDB(t, asnPath, "GeoLite2-ASN", []testNetwork{
+		{"1.2.0.0/16", asnRecord},
+	})
+
+	db := MustOpenDB([]string{cityPath, asnPath}, 0)
+	defer db.MustClose()
+
+	f := func(ip string, resultExpected Info) {
+		t.Helper()
+
+		var gi Info
+		ok := db.Lookup(&gi, ip)
+		if ok != !resultExpected.IsEmpty() {
+			t.Fatalf("unexpected result for Lookup(%q); got %v; want %v", ip, ok, !ok)
+		}
+		if gi != resultExpected {
+			t.Fatalf("unexpected Lookup(%q) result\ngot\n%#v\nwant\n%#v", ip, gi, resultExpected)
+		}
+	}
+
+	// IPv4 addresses
+	f("1.2.3.4", Info{
+		Country:     "DE",
+		CountryName: "Germany",
+		City:        "Berlin",
+		ASN:         "3320",
+		ASOrg:       "Deutsche Telekom AG",
+	})
+	f("1.2.4.5", Info{
+		ASN:   "3320",
+		ASOrg: "Deutsche Telekom AG",
+	})
+	f("::ffff:1.2.3.255", Info{
+		Country:     "DE",
+		CountryName: "Germany",
+		City:        "Berlin",
+		ASN:         "3320",
+		ASOrg:       "Deutsche Telekom AG",
+	})
+
+	// IPv6 address
+	f("2001:db8::1", Info{
+		Country:     "US",
+		CountryName: "United States",
+	})
+
+	// missing addresses
+	f("1.3.0.0", Info{})
+	f("2001:db9::1", Info{})
+
+	// invalid addresses
+	f("", Info{})
+	f("foobar", Info{})
+	f("1.2.3", Info{})
+}
+
+func TestDBReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "asn.mmdb")
+	writeTestDB(t, path, "GeoLite2-ASN", []testNetwork{
+		{"10.0.0.0/8", testMap("autonomous_system_number", testUint32(1))},
+	})
+
+	db := MustOpenDB([]string{path}, 0)
+	defer db.MustClose()
+
+	var gi Info
+	if !db.Lookup(&gi, "10.1.2.3") || gi.ASN != "1" {
+		t.Fatalf("unexpected lookup result before reload: %#v", gi)
+	}
+
+	writeTestDB(t, path, "GeoLite2-ASN", []testNetwork{
+		{"10.0.0.0/8", testMap("autonomous_system_number", testUint32(1234567), "autonomous_system_organization", testString("foo"))},
+	})
+	if err := db.files[0].reloadIfChanged(); err != nil {
+		t.Fatalf("unexpected error when reloading the database: %s", err)
+	}
+	if !db.Lookup(&gi, "10.1.2.3") || gi.ASN != "1234567" || gi.ASOrg != "foo" {
+		t.Fatalf("unexpected lookup result after reload: %#v", gi)
+	}
+
+	// Invalid file must be ignored, while the previously loaded database must be used.
+	if err := os.WriteFile(path, []byte("invalid mmdb file"), 0o644); err != nil {
+		t.Fatalf("cannot write %q: %s", path, err)
+	}
+	if err := db.files[0].reloadIfChanged(); err == nil {
+		t.Fatalf("expecting non-nil error when reloading invalid database")
+	}
+	if !db.Lookup(&gi, "10.1.2.3") || gi.ASN != "1234567" {
+		t.Fatalf("unexpected lookup result after failed reload: %#v", gi)
+	}
+}
+
+func TestNewReaderFailure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+
+		if _, err := newReader([]byte(data)); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// missing metadata
+	f("")
+	f("foobar")
+
+	// invalid metadata
+	f(string(metadataStartMarker))
+	f(string(metadataStartMarker) + "\xe0")
+
+	// too big node_count
+	var e testEncoder
+	metadata := testMap(
+		"node_count", testUint32(1000),
+		"record_size", testUint16(24),
+		"ip_version", testUint16(6),
+	)
+	metadata(&e)
+	f(string(metadataStartMarker) + string(e.buf))
+
+	// node_count overflowing the search tree size
+	e = testEncoder{}
+	metadata = testMap(
+		"node_count", testUint64(1<<62),
+		"record_size", testUint16(32),
+		"ip_version", testUint16(6),
+	)
+	metadata(&e)
+	f(strings.Repeat("\x00", 64) + string(metadataStartMarker) + string(e.buf))
+
+	// map and array sizes exceeding the file size
+	f(string(metadataStartMarker) + "\xff\xff\xff\xff")
+	f(string(metadataStartMarker) + "\x1f\x04\xff\xff\xff")
+}
+
+type testNetwork struct {
+	cidr   string
+	record testValue
+}
+
+// writeTestDB writes MaxMind DB file with the given networks to path.
+func writeTestDB(t *testing.T, path, databaseType string, networks []testNetwork) {
+	t.Helper()
+
+	type node struct {
+		children [2]int
+		data     [2]int
+	}
+	nodes := []node{
+		{
+			children: [2]int{-1, -1},
+			data:     [2]int{-1, -1},
+		},
+	}
+
+	var data testEncoder
+	for _, nw := range networks {
+		prefix := netip.MustParsePrefix(nw.cidr)
+		addr := prefix.Addr()
+		bits := prefix.Bits()
+		if addr.Is4() {
+			addr = netip.AddrFrom16(addr.As16())
+			// Convert ::ffff:a.b.c.d to ::a.b.c.d according to MaxMind DB conventions.
+			b := addr.As16()
+			b[10] = 0
+			b[11] = 0
+			addr = netip.AddrFrom16(b)
+			bits += 96
+		}
+		ipBytes := addr.As16()
+
+		dataOffset := len(data.buf)
+		nw.record(&data)
+
+		n := 0
+		for i := 0; i < bits; i++ {
+			bit := (ipBytes[i>>3] >> (7 - uint(i&7))) & 1
+			if i == bits-1 {
+				nodes[n].data[bit] = dataOffset
+				break
+			}
+			if nodes[n].children[bit] < 0 {
+				nodes = append(nodes, node{
+					children: [2]int{-1, -1},
+					data:     [2]int{-1, -1},
+				})
+				nodes[n].children[bit] = len(nodes) - 1
+			}
+			n = nodes[n].children[bit]
+		}
+	}
+
+	nodeCount := len(nodes)
+	var buf []byte
+	for _, nd := range nodes {
+		for bit := 0; bit < 2; bit++ {
+			record := nodeCount
+			if nd.children[bit] >= 0 {
+				record = nd.children[bit]
+			} else if nd.data[bit] >= 0 {
+				record = nodeCount + dataSectionSeparatorSize + nd.data[bit]
+			}
+			buf = append(buf, byte(record>>16), byte(record>>8), byte(record))
+		}
+	}
+	buf = append(buf, make([]byte, dataSectionSeparatorSize)...)
+	buf = append(buf, data.buf...)
+	buf = append(buf, metadataStartMarker...)
+
+	var metadata testEncoder
+	testMap(
+		"node_count", testUint32(uint32(nodeCount)),
+		"record_size", testUint16(24),
+		"ip_version", testUint16(6),
+		"database_type", testString(databaseType),
+	)(&metadata)
+	buf = append(buf, metadata.buf...)
+
+	if err := os.WriteFile(path, buf, 0o644); err != nil {
+		t.Fatalf("cannot write %q: %s", path, err)
+	}
+}
+
+// testEncoder encodes values in MaxMind DB data section format.
+type testEncoder struct {
+	buf []byte
+
+	// strings contains offsets for already encoded strings, so they could be referred via pointers.
+	strings map[string]int
+}
+
+type testValue func(e *testEncoder)
+
+func (e *testEncoder) writeCtrl(typ, size int) {
+	if typ <= 7 {
+		e.buf = append(e.buf, byte(typ<<5|size))
+	} else {
+		e.buf = append(e.buf, byte(size), byte(typ-7))
+	}
+}
+
+func testString(s string) testValue {
+	return func(e *testEncoder) {
+		if offset, ok := e.strings[s]; ok && offset < 2048 {
+			// Refer the previously encoded string via pointer
+			e.buf = append(e.buf, byte(typePointer<<5|offset>>8), byte(offset))
+			return
+		}
+		if e.strings == nil {
+			e.strings = make(map[string]int)
+		}
+		e.strings[s] = len(e.buf)
+		if len(s) < 29 {
+			e.writeCtrl(typeString, len(s))
+		} else {
+			e.writeCtrl(typeString, 29)
+			e.buf = append(e.buf, byte(len(s)-29))
+		}
+		e.buf = append(e.buf, s...)
+	}
+}
+
+func testUint16(n uint16) testValue {
+	return func(e *testEncoder) {
+		e.writeCtrl(typeUint16, 2)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, n)
+	}
+}
+
+func testUint32(n uint32) testValue {
+	return func(e *testEncoder) {
+		e.writeCtrl(typeUint32, 4)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, n)
+	}
+}
+
+func testUint64(n uint64) testValue {
+	return func(e *testEncoder) {
+		e.writeCtrl(typeUint64, 8)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, n)
+	}
+}
+
+func testMap(kvs ...any) testValue {
+	return func(e *testEncoder) {
+		e.writeCtrl(typeMap, len(kvs)/2)
+		for i := 0; i < len(kvs); i += 2 {
+			testString(kvs[i].(string))(e)
+			kvs[i+1].(testValue)(e)
+		}
+	}
+}
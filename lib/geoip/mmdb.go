@@ -0,0 +1,390 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/netip"
+)
+
+// metadataStartMarker marks the start of the metadata section in MaxMind DB files.
+//
+// See https://maxmind.github.io/MaxMind-DB/#database-metadata
+var metadataStartMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// dataSectionSeparatorSize is the size of zero bytes between the search tree and the data section.
+const dataSectionSeparatorSize = 16
+
+// reader reads MaxMind DB (.mmdb) files.
+//
+// See https://maxmind.github.io/MaxMind-DB/ for the file format description.
+type reader struct {
+	// buf contains the whole file contents.
+	buf []byte
+
+	// dataSection contains the data section of the file.
+	dataSection []byte
+
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+
+	// databaseType contains the database type from the metadata, e.g. GeoLite2-City or GeoLite2-ASN.
+	databaseType string
+
+	// ipv4Start is the node to start the search for IPv4 addresses from.
+	ipv4Start uint
+}
+
+// newReader returns a reader for the MaxMind DB file contents in buf.
+func newReader(buf []byte) (*reader, error) {
+	n := bytes.LastIndex(buf, metadataStartMarker)
+	if n < 0 {
+		return nil, fmt.Errorf("cannot find metadata start marker")
+	}
+	metadataSection := buf[n+len(metadataStartMarker):]
+	d := decoder{
+		buf: metadataSection,
+	}
+	v, _, err := d.decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode metadata: %w", err)
+	}
+	metadata, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("unexpected metadata type %T; want map", v)
+	}
+
+	nodeCount, ok := metadata["node_count"].(uint64)
+	if !ok {
+		return nil, fmt.Errorf("missing node_count in metadata")
+	}
+	recordSize, ok := metadata["record_size"].(uint64)
+	if !ok {
+		return nil, fmt.Errorf("missing record_size in metadata")
+	}
+	if recordSize != 24 && recordSize != 28 && recordSize != 32 {
+		return nil, fmt.Errorf("unsupported record_size=%d; supported values: 24, 28, 32", recordSize)
+	}
+	ipVersion, ok := metadata["ip_version"].(uint64)
+	if !ok {
+		return nil, fmt.Errorf("missing ip_version in metadata")
+	}
+	if ipVersion != 4 && ipVersion != 6 {
+		return nil, fmt.Errorf("unsupported ip_version=%d; supported values: 4, 6", ipVersion)
+	}
+	databaseType, _ := metadata["database_type"].(string)
+
+	// Every node contains two records with recordSize bits each.
+	nodeSize := recordSize / 4
+	if nodeCount > uint64(n)/nodeSize {
+		return nil, fmt.Errorf("too big node_count=%d for the file with the data size=%d", nodeCount, n)
+	}
+	searchTreeSize := nodeCount * nodeSize
+	if searchTreeSize+dataSectionSeparatorSize > uint64(n) {
+		return nil, fmt.Errorf("too big search tree size=%d for the file with the data size=%d", searchTreeSize, n)
+	}
+
+	r := &reader{
+		buf:          buf,
+		dataSection:  buf[searchTreeSize+dataSectionSeparatorSize : n],
+		nodeCount:    uint(nodeCount),
+		recordSize:   uint(recordSize),
+		ipVersion:    uint(ipVersion),
+		databaseType: databaseType,
+	}
+
+	// IPv4 addresses are stored in IPv6 databases under ::/96 prefix.
+	if ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.nodeCount; i++ {
+			node = r.readNode(node, 0)
+		}
+		r.ipv4Start = node
+	}
+
+	return r, nil
+}
+
+// lookup returns the decoded data record for the given ip.
+//
+// nil is returned if ip isn't found in r.
+func (r *reader) lookup(ip netip.Addr) (any, error) {
+	node := uint(0)
+	bitsCount := 128
+	if ip.Is4() || ip.Is4In6() {
+		ip = ip.Unmap()
+		bitsCount = 32
+		node = r.ipv4Start
+	} else if r.ipVersion == 4 {
+		// IPv6 addresses cannot be found in IPv4 databases
+		return nil, nil
+	}
+	ipBytes := ip.AsSlice()
+
+	for i := 0; i < bitsCount && node < r.nodeCount; i++ {
+		bit := uint((ipBytes[i>>3] >> (7 - uint(i&7))) & 1)
+		node = r.readNode(node, bit)
+	}
+	if node == r.nodeCount {
+		// The ip isn't found
+		return nil, nil
+	}
+	if node < r.nodeCount {
+		return nil, fmt.Errorf("invalid search tree: cannot find the leaf node for ip=%s", ip)
+	}
+
+	if node < r.nodeCount+dataSectionSeparatorSize {
+		return nil, fmt.Errorf("invalid search tree: unexpected data pointer %d for ip=%s", node, ip)
+	}
+	offset := node - r.nodeCount - dataSectionSeparatorSize
+	d := decoder{
+		buf: r.dataSection,
+	}
+	v, _, err := d.decode(offset)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode data record for ip=%s at offset %d: %w", ip, offset, err)
+	}
+	return v, nil
+}
+
+// readNode returns the left (bit=0) or the right (bit=1) record for the given node.
+func (r *reader) readNode(node, bit uint) uint {
+	switch r.recordSize {
+	case 24:
+		off := node*6 + bit*3
+		b := r.buf[off : off+3]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		off := node * 7
+		b := r.buf[off : off+7]
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		off := node*8 + bit*4
+		return uint(binary.BigEndian.Uint32(r.buf[off : off+4]))
+	}
+}
+
+// MaxMind DB data types.
+//
+// See https://maxmind.github.io/MaxMind-DB/#output-data-section
+const (
+	typeExtended  = 0
+	typePointer   = 1
+	typeString    = 2
+	typeDouble    = 3
+	typeBytes     = 4
+	typeUint16    = 5
+	typeUint32    = 6
+	typeMap       = 7
+	typeInt32     = 8
+	typeUint64    = 9
+	typeUint128   = 10
+	typeArray     = 11
+	typeContainer = 12
+	typeEndMarker = 13
+	typeBool      = 14
+	typeFloat     = 15
+)
+
+// maxDecodeDepth limits the nesting depth for decoded values in order to protect from malformed files.
+const maxDecodeDepth = 32
+
+// decoder decodes values from MaxMind DB data section.
+type decoder struct {
+	buf   []byte
+	depth int
+}
+
+// decode decodes the value at the given offset in d.buf.
+//
+// It returns the decoded value and the offset for the next value.
+//
+// The decoded value may have the following types: map[string]any, []any, string, []byte, float64, uint64, int64, bool.
+func (d *decoder) decode(offset uint) (any, uint, error) {
+	d.depth++
+	defer func() {
+		d.depth--
+	}()
+	if d.depth > maxDecodeDepth {
+		return nil, 0, fmt.Errorf("too deep nesting of values; it mustn't exceed %d", maxDecodeDepth)
+	}
+
+	if offset >= uint(len(d.buf)) {
+		return nil, 0, fmt.Errorf("unexpected end of data at offset %d", offset)
+	}
+	ctrl := d.buf[offset]
+	offset++
+
+	typ := uint(ctrl >> 5)
+	if typ == typePointer {
+		ptr, offsetNext, err := d.decodePointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, _, err := d.decode(ptr)
+		if err != nil {
+			return nil, 0, fmt.Errorf("cannot decode value at pointer %d: %w", ptr, err)
+		}
+		return v, offsetNext, nil
+	}
+	if typ == typeExtended {
+		if offset >= uint(len(d.buf)) {
+			return nil, 0, fmt.Errorf("unexpected end of data when reading extended type")
+		}
+		typ = 7 + uint(d.buf[offset])
+		offset++
+	}
+
+	size, offset, err := d.decodeSize(ctrl, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Every map entry occupies at least two bytes for the key and the value, while every array item occupies at least a single byte.
+	// Verify that the file contains enough data for the given number of items before allocating memory for them.
+	bytesLeft := uint(len(d.buf)) - offset
+	switch typ {
+	case typeMap:
+		if size > bytesLeft/2 {
+			return nil, 0, fmt.Errorf("too big map size=%d at offset %d for the remaining %d bytes", size, offset, bytesLeft)
+		}
+		m := make(map[string]any, size)
+		for i := uint(0); i < size; i++ {
+			k, offsetNext, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, fmt.Errorf("cannot decode map key: %w", err)
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("unexpected map key type %T; want string", k)
+			}
+			v, offsetNext, err := d.decode(offsetNext)
+			if err != nil {
+				return nil, 0, fmt.Errorf("cannot decode map value for key %q: %w", key, err)
+			}
+			m[key] = v
+			offset = offsetNext
+		}
+		return m, offset, nil
+	case typeArray:
+		if size > bytesLeft {
+			return nil, 0, fmt.Errorf("too big array size=%d at offset %d for the remaining %d bytes", size, offset, bytesLeft)
+		}
+		a := make([]any, 0, size)
+		for i := uint(0); i < size; i++ {
+			v, offsetNext, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, fmt.Errorf("cannot decode array item #%d: %w", i, err)
+			}
+			a = append(a, v)
+			offset = offsetNext
+		}
+		return a, offset, nil
+	case typeBool:
+		if size > 1 {
+			return nil, 0, fmt.Errorf("unexpected size for bool value: %d; want 0 or 1", size)
+		}
+		return size == 1, offset, nil
+	case typeContainer, typeEndMarker:
+		return nil, offset, nil
+	}
+
+	if offset+size > uint(len(d.buf)) {
+		return nil, 0, fmt.Errorf("unexpected end of data when reading value of type %d with size %d at offset %d", typ, size, offset)
+	}
+	b := d.buf[offset : offset+size]
+	offset += size
+
+	switch typ {
+	case typeString:
+		return string(b), offset, nil
+	case typeBytes:
+		return append([]byte{}, b...), offset, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("unexpected size for double value: %d; want 8", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("unexpected size for float value: %d; want 4", size)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), offset, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("too big size for unsigned integer value: %d; mustn't exceed 8", size)
+		}
+		return decodeUint64(b), offset, nil
+	case typeUint128:
+		if size > 16 {
+			return nil, 0, fmt.Errorf("too big size for uint128 value: %d; mustn't exceed 16", size)
+		}
+		// Values exceeding uint64 are truncated to lower 64 bits, since they aren't used in GeoIP databases.
+		if size > 8 {
+			b = b[size-8:]
+		}
+		return decodeUint64(b), offset, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("too big size for int32 value: %d; mustn't exceed 4", size)
+		}
+		return int64(int32(decodeUint64(b))), offset, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported data type %d", typ)
+	}
+}
+
+func (d *decoder) decodeSize(ctrl byte, offset uint) (uint, uint, error) {
+	size := uint(ctrl & 0x1f)
+	if size < 29 {
+		return size, offset, nil
+	}
+	n := size - 28
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, fmt.Errorf("unexpected end of data when reading size")
+	}
+	v := uint(decodeUint64(d.buf[offset : offset+n]))
+	offset += n
+	switch size {
+	case 29:
+		return 29 + v, offset, nil
+	case 30:
+		return 285 + v, offset, nil
+	default:
+		return 65821 + v, offset, nil
+	}
+}
+
+func (d *decoder) decodePointer(ctrl byte, offset uint) (uint, uint, error) {
+	n := uint((ctrl>>3)&3) + 1
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, fmt.Errorf("unexpected end of data when reading pointer")
+	}
+	b := d.buf[offset : offset+n]
+	offset += n
+
+	vvv := uint(ctrl & 7)
+	switch n {
+	case 1:
+		return vvv<<8 | uint(b[0]), offset, nil
+	case 2:
+		return (vvv<<16 | uint(b[0])<<8 | uint(b[1])) + 2048, offset, nil
+	case 3:
+		return (vvv<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])) + 526336, offset, nil
+	default:
+		return uint(binary.BigEndian.Uint32(b)), offset, nil
+	}
+}
+
+func decodeUint64(b []byte) uint64 {
+	n := uint64(0)
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n
+}
@@ -146,7 +146,8 @@ func (c *column) mustWriteTo(ch *columnHeader, sw *streamWriters) {
 	defer longTermBufPool.Put(bb)
 
 	// marshal values
-	bb.B = marshalStringsBlock(bb.B[:0], ve.values)
+	ch.valuesUncompressedSize = getStringsLen(ve.values)
+	bb.B, ch.valuesCodec = marshalValues(bb.B[:0], ve.values, ch.valueType, &sw.valuesCompression)
 	putValuesEncoder(ve)
 	ch.valuesSize = uint64(len(bb.B))
 	if ch.valuesSize > maxValuesBlockSize {
@@ -438,7 +439,7 @@ func (b *block) InitFromBlockData(bd *blockData, sbu *stringsBlockUnmarshaler, v
 		cd := &cds[i]
 		c := &cs[i]
 		c.name = sbu.copyString(cd.name)
-		c.values, err = sbu.unmarshal(c.values[:0], cd.valuesData, uint64(rowsCount))
+		c.values, err = sbu.unmarshalValues(c.values[:0], cd.valuesData, uint64(rowsCount), cd.valueType, cd.valuesCodec)
 		if err != nil {
 			return fmt.Errorf("cannot unmarshal column %d: %w", i, err)
 		}
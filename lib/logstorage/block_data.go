@@ -1,6 +1,8 @@
 package logstorage
 
 import (
+	"fmt"
+
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
@@ -119,10 +121,10 @@ func (bd *blockData) mustWriteTo(bh *blockHeader, sw *streamWriters) {
 	putColumnsHeader(csh)
 }
 
-// mustReadFrom reads block data associated with bh from sr to bd.
+// readFrom reads block data associated with bh from sr to bd.
 //
 // The bd is valid until a.reset() is called.
-func (bd *blockData) mustReadFrom(a *arena, bh *blockHeader, sr *streamReaders) {
+func (bd *blockData) readFrom(a *arena, bh *blockHeader, sr *streamReaders) error {
 	bd.reset()
 
 	bd.streamID = bh.streamID
@@ -130,60 +132,74 @@ func (bd *blockData) mustReadFrom(a *arena, bh *blockHeader, sr *streamReaders)
 	bd.rowsCount = bh.rowsCount
 
 	// Read timestamps
-	bd.timestampsData.mustReadFrom(a, &bh.timestampsHeader, sr)
+	if err := bd.timestampsData.readFrom(a, &bh.timestampsHeader, sr); err != nil {
+		return err
+	}
 
 	// Read columns
 	if bh.columnsHeaderOffset != sr.columnsHeaderReader.bytesRead {
-		logger.Panicf("FATAL: %s: unexpected columnsHeaderOffset=%d; must equal to the number of bytes read: %d",
+		return fmt.Errorf("%s: unexpected columnsHeaderOffset=%d; must equal to the number of bytes read: %d",
 			sr.columnsHeaderReader.Path(), bh.columnsHeaderOffset, sr.columnsHeaderReader.bytesRead)
 	}
 	columnsHeaderSize := bh.columnsHeaderSize
 	if columnsHeaderSize > maxColumnsHeaderSize {
-		logger.Panicf("BUG: %s: too big columnsHeaderSize: %d bytes; mustn't exceed %d bytes", sr.columnsHeaderReader.Path(), columnsHeaderSize, maxColumnsHeaderSize)
+		return fmt.Errorf("%s: too big columnsHeaderSize: %d bytes; mustn't exceed %d bytes", sr.columnsHeaderReader.Path(), columnsHeaderSize, maxColumnsHeaderSize)
 	}
 	bb := longTermBufPool.Get()
+	defer longTermBufPool.Put(bb)
+
 	bb.B = bytesutil.ResizeNoCopyMayOverallocate(bb.B, int(columnsHeaderSize))
-	sr.columnsHeaderReader.MustReadFull(bb.B)
+	if err := sr.columnsHeaderReader.ReadFull(bb.B); err != nil {
+		return err
+	}
 
 	csh := getColumnsHeader()
+	defer putColumnsHeader(csh)
+
 	if err := csh.unmarshalInplace(bb.B, sr.partFormatVersion); err != nil {
-		logger.Panicf("FATAL: %s: cannot unmarshal columnsHeader: %s", sr.columnsHeaderReader.Path(), err)
+		return fmt.Errorf("%s: cannot unmarshal columnsHeader: %w", sr.columnsHeaderReader.Path(), err)
 	}
 	if sr.partFormatVersion >= 1 {
-		readColumnNamesFromColumnsHeaderIndex(bh, sr, csh)
+		if err := readColumnNamesFromColumnsHeaderIndex(bh, sr, csh); err != nil {
+			return err
+		}
 	}
 
 	chs := csh.columnHeaders
 	cds := bd.resizeColumnsData(len(chs))
 	for i := range chs {
-		cds[i].mustReadFrom(a, &chs[i], sr)
+		if err := cds[i].readFrom(a, &chs[i], sr); err != nil {
+			return err
+		}
 	}
 	bd.constColumns = appendFields(a, bd.constColumns[:0], csh.constColumns)
-	putColumnsHeader(csh)
-	longTermBufPool.Put(bb)
+	return nil
 }
 
-func readColumnNamesFromColumnsHeaderIndex(bh *blockHeader, sr *streamReaders, csh *columnsHeader) {
+func readColumnNamesFromColumnsHeaderIndex(bh *blockHeader, sr *streamReaders, csh *columnsHeader) error {
 	bb := longTermBufPool.Get()
 	defer longTermBufPool.Put(bb)
 
 	n := bh.columnsHeaderIndexSize
 	if n > maxColumnsHeaderIndexSize {
-		logger.Panicf("BUG: %s: too big columnsHeaderIndexSize: %d bytes; mustn't exceed %d bytes", sr.columnsHeaderIndexReader.Path(), n, maxColumnsHeaderIndexSize)
+		return fmt.Errorf("%s: too big columnsHeaderIndexSize: %d bytes; mustn't exceed %d bytes", sr.columnsHeaderIndexReader.Path(), n, maxColumnsHeaderIndexSize)
 	}
 
 	bb.B = bytesutil.ResizeNoCopyMayOverallocate(bb.B, int(n))
-	sr.columnsHeaderIndexReader.MustReadFull(bb.B)
+	if err := sr.columnsHeaderIndexReader.ReadFull(bb.B); err != nil {
+		return err
+	}
 
 	cshIndex := getColumnsHeaderIndex()
+	defer putColumnsHeaderIndex(cshIndex)
+
 	if err := cshIndex.unmarshalInplace(bb.B); err != nil {
-		logger.Panicf("FATAL: %s: cannot unmarshal columnsHeaderIndex: %s", sr.columnsHeaderIndexReader.Path(), err)
+		return fmt.Errorf("%s: cannot unmarshal columnsHeaderIndex: %w", sr.columnsHeaderIndexReader.Path(), err)
 	}
 	if err := csh.setColumnNames(cshIndex, sr.columnNames); err != nil {
-		logger.Panicf("FATAL: %s: %s", sr.columnsHeaderIndexReader.Path(), err)
+		return fmt.Errorf("%s: %w", sr.columnsHeaderIndexReader.Path(), err)
 	}
-
-	putColumnsHeaderIndex(cshIndex)
+	return nil
 }
 
 // timestampsData contains the encoded timestamps data.
@@ -236,10 +252,10 @@ func (td *timestampsData) mustWriteTo(th *timestampsHeader, sw *streamWriters) {
 	sw.timestampsWriter.MustWrite(td.data)
 }
 
-// mustReadFrom reads timestamps data associated with th from sr to td.
+// readFrom reads timestamps data associated with th from sr to td.
 //
 // td is valid until a.reset() is called.
-func (td *timestampsData) mustReadFrom(a *arena, th *timestampsHeader, sr *streamReaders) {
+func (td *timestampsData) readFrom(a *arena, th *timestampsHeader, sr *streamReaders) error {
 	td.reset()
 
 	td.marshalType = th.marshalType
@@ -248,16 +264,16 @@ func (td *timestampsData) mustReadFrom(a *arena, th *timestampsHeader, sr *strea
 
 	timestampsReader := &sr.timestampsReader
 	if th.blockOffset != timestampsReader.bytesRead {
-		logger.Panicf("FATAL: %s: unexpected timestampsHeader.blockOffset=%d; must equal to the number of bytes read: %d",
+		return fmt.Errorf("%s: unexpected timestampsHeader.blockOffset=%d; must equal to the number of bytes read: %d",
 			timestampsReader.Path(), th.blockOffset, timestampsReader.bytesRead)
 	}
 	timestampsBlockSize := th.blockSize
 	if timestampsBlockSize > maxTimestampsBlockSize {
-		logger.Panicf("FATAL: %s: too big timestamps block with %d bytes; the maximum supported block size is %d bytes",
+		return fmt.Errorf("%s: too big timestamps block with %d bytes; the maximum supported block size is %d bytes",
 			timestampsReader.Path(), timestampsBlockSize, maxTimestampsBlockSize)
 	}
 	td.data = a.newBytes(int(timestampsBlockSize))
-	timestampsReader.MustReadFull(td.data)
+	return timestampsReader.ReadFull(td.data)
 }
 
 // columnData contains packed data for a single column.
@@ -268,6 +284,12 @@ type columnData struct {
 	// valueType is the type of values stored in valuesData
 	valueType valueType
 
+	// valuesCodec is the codec used for marshaling valuesData
+	valuesCodec valuesCodec
+
+	// valuesUncompressedSize is the size of the encoded values before marshaling them into valuesData
+	valuesUncompressedSize uint64
+
 	// minValue is the minimum encoded uint* or float64 value in the columnHeader
 	//
 	// It is used for fast detection of whether the given columnHeader contains values in the given range
@@ -292,6 +314,8 @@ type columnData struct {
 func (cd *columnData) reset() {
 	cd.name = ""
 	cd.valueType = 0
+	cd.valuesCodec = 0
+	cd.valuesUncompressedSize = 0
 
 	cd.minValue = 0
 	cd.maxValue = 0
@@ -309,6 +333,8 @@ func (cd *columnData) copyFrom(a *arena, src *columnData) {
 
 	cd.name = a.copyString(src.name)
 	cd.valueType = src.valueType
+	cd.valuesCodec = src.valuesCodec
+	cd.valuesUncompressedSize = src.valuesUncompressedSize
 
 	cd.minValue = src.minValue
 	cd.maxValue = src.maxValue
@@ -326,6 +352,8 @@ func (cd *columnData) mustWriteTo(ch *columnHeader, sw *streamWriters) {
 
 	ch.name = cd.name
 	ch.valueType = cd.valueType
+	ch.valuesCodec = cd.valuesCodec
+	ch.valuesUncompressedSize = cd.valuesUncompressedSize
 
 	ch.minValue = cd.minValue
 	ch.maxValue = cd.maxValue
@@ -350,45 +378,55 @@ func (cd *columnData) mustWriteTo(ch *columnHeader, sw *streamWriters) {
 	bloomValuesWriter.bloom.MustWrite(cd.bloomFilterData)
 }
 
-// mustReadFrom reads columns data associated with ch from sr to cd.
+// readFrom reads columns data associated with ch from sr to cd.
 //
 // cd is valid until a.reset() is called.
-func (cd *columnData) mustReadFrom(a *arena, ch *columnHeader, sr *streamReaders) {
+func (cd *columnData) readFrom(a *arena, ch *columnHeader, sr *streamReaders) error {
 	cd.reset()
 
 	cd.name = a.copyString(ch.name)
 	cd.valueType = ch.valueType
+	cd.valuesCodec = ch.valuesCodec
+	cd.valuesUncompressedSize = ch.valuesUncompressedSize
 
 	cd.minValue = ch.minValue
 	cd.maxValue = ch.maxValue
 	cd.valuesDict.copyFrom(a, &ch.valuesDict)
 
-	bloomValuesReader := sr.getBloomValuesReaderForColumnName(ch.name)
+	bloomValuesReader, err := sr.getBloomValuesReaderForColumnName(ch.name)
+	if err != nil {
+		return err
+	}
 
 	// read values
 	if ch.valuesOffset != bloomValuesReader.values.bytesRead {
-		logger.Panicf("FATAL: %s: unexpected columnHeader.valuesOffset=%d; must equal to the number of bytes read: %d",
+		return fmt.Errorf("%s: unexpected columnHeader.valuesOffset=%d; must equal to the number of bytes read: %d",
 			bloomValuesReader.values.Path(), ch.valuesOffset, bloomValuesReader.values.bytesRead)
 	}
 	valuesSize := ch.valuesSize
 	if valuesSize > maxValuesBlockSize {
-		logger.Panicf("FATAL: %s: values block size cannot exceed %d bytes; got %d bytes", bloomValuesReader.values.Path(), maxValuesBlockSize, valuesSize)
+		return fmt.Errorf("%s: values block size cannot exceed %d bytes; got %d bytes", bloomValuesReader.values.Path(), maxValuesBlockSize, valuesSize)
 	}
 	cd.valuesData = a.newBytes(int(valuesSize))
-	bloomValuesReader.values.MustReadFull(cd.valuesData)
+	if err := bloomValuesReader.values.ReadFull(cd.valuesData); err != nil {
+		return err
+	}
 
 	// read bloom filter
 	// bloom filter is missing in valueTypeDict.
 	if ch.valueType != valueTypeDict {
 		if ch.bloomFilterOffset != bloomValuesReader.bloom.bytesRead {
-			logger.Panicf("FATAL: %s: unexpected columnHeader.bloomFilterOffset=%d; must equal to the number of bytes read: %d",
+			return fmt.Errorf("%s: unexpected columnHeader.bloomFilterOffset=%d; must equal to the number of bytes read: %d",
 				bloomValuesReader.bloom.Path(), ch.bloomFilterOffset, bloomValuesReader.bloom.bytesRead)
 		}
 		bloomFilterSize := ch.bloomFilterSize
 		if bloomFilterSize > maxBloomFilterBlockSize {
-			logger.Panicf("FATAL: %s: bloom filter block size cannot exceed %d bytes; got %d bytes", bloomValuesReader.bloom.Path(), maxBloomFilterBlockSize, bloomFilterSize)
+			return fmt.Errorf("%s: bloom filter block size cannot exceed %d bytes; got %d bytes", bloomValuesReader.bloom.Path(), maxBloomFilterBlockSize, bloomFilterSize)
 		}
 		cd.bloomFilterData = a.newBytes(int(bloomFilterSize))
-		bloomValuesReader.bloom.MustReadFull(cd.bloomFilterData)
+		if err := bloomValuesReader.bloom.ReadFull(cd.bloomFilterData); err != nil {
+			return err
+		}
 	}
+	return nil
 }
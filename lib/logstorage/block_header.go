@@ -428,7 +428,7 @@ func (csh *columnsHeader) mustWriteTo(bh *blockHeader, sw *streamWriters) {
 
 	cshIndex := getColumnsHeaderIndex()
 
-	bb.B = csh.marshal(bb.B, cshIndex, &sw.columnNameIDGenerator)
+	bb.B = csh.marshal(bb.B, cshIndex, &sw.columnNameIDGenerator, sw.partFormatVersion)
 	columnsHeaderData := bb.B
 
 	bb.B = cshIndex.marshal(bb.B)
@@ -451,7 +451,7 @@ func (csh *columnsHeader) mustWriteTo(bh *blockHeader, sw *streamWriters) {
 	sw.columnsHeaderWriter.MustWrite(columnsHeaderData)
 }
 
-func (csh *columnsHeader) marshal(dst []byte, cshIndex *columnsHeaderIndex, g *columnNameIDGenerator) []byte {
+func (csh *columnsHeader) marshal(dst []byte, cshIndex *columnsHeaderIndex, g *columnNameIDGenerator, partFormatVersion uint) []byte {
 	dstLen := len(dst)
 
 	chs := csh.columnHeaders
@@ -460,7 +460,7 @@ func (csh *columnsHeader) marshal(dst []byte, cshIndex *columnsHeaderIndex, g *c
 	for i := range chs {
 		columnNameID := g.getColumnNameID(chs[i].name)
 		offset := len(dst) - dstLen
-		dst = chs[i].marshal(dst)
+		dst = chs[i].marshal(dst, partFormatVersion)
 		chsRefs[i] = columnHeaderRef{
 			columnNameID: columnNameID,
 			offset:       uint64(offset),
@@ -588,6 +588,14 @@ type columnHeader struct {
 	// valueType is the type of values stored in the block
 	valueType valueType
 
+	// valuesCodec is the codec used for marshaling values stored in the block
+	valuesCodec valuesCodec
+
+	// valuesUncompressedSize is the size of the encoded values before marshaling them with valuesCodec.
+	//
+	// It is zero for parts with format version below 4.
+	valuesUncompressedSize uint64
+
 	// minValue is the minimum encoded value for uint*, ipv4, timestamp and float64 value in the columnHeader
 	//
 	// It is used for fast detection of whether the given columnHeader contains values in the given range
@@ -618,6 +626,8 @@ type columnHeader struct {
 func (ch *columnHeader) reset() {
 	ch.name = ""
 	ch.valueType = 0
+	ch.valuesCodec = 0
+	ch.valuesUncompressedSize = 0
 
 	ch.minValue = 0
 	ch.maxValue = 0
@@ -630,8 +640,8 @@ func (ch *columnHeader) reset() {
 	ch.bloomFilterSize = 0
 }
 
-// marshal appends marshaled ch to dst and returns the result.
-func (ch *columnHeader) marshal(dst []byte) []byte {
+// marshal appends marshaled ch to dst in the given partFormatVersion and returns the result.
+func (ch *columnHeader) marshal(dst []byte, partFormatVersion uint) []byte {
 	// check minValue/maxValue
 	switch ch.valueType {
 	case valueTypeInt64:
@@ -660,8 +670,14 @@ func (ch *columnHeader) marshal(dst []byte) []byte {
 
 	// Do not encode ch.name, since it should be encoded at columnsHeaderIndex.columnHeadersRefs
 
-	// Encode common field - ch.valueType
+	// Encode common fields - ch.valueType, ch.valuesCodec and ch.valuesUncompressedSize
 	dst = append(dst, byte(ch.valueType))
+	if partFormatVersion >= 4 {
+		dst = append(dst, byte(ch.valuesCodec))
+		dst = encoding.MarshalVarUint64(dst, ch.valuesUncompressedSize)
+	} else if ch.valuesCodec != valuesCodecDefault {
+		logger.Panicf("BUG: valuesCodec=%s cannot be used in part format v%d", ch.valuesCodec, partFormatVersion)
+	}
 
 	// Encode other fields depending on ch.valueType
 	switch ch.valueType {
@@ -754,6 +770,25 @@ func (ch *columnHeader) unmarshalInplace(src []byte, partFormatVersion uint) ([]
 	ch.valueType = valueType(src[0])
 	src = src[1:]
 
+	// Unmarshal values codec
+	if partFormatVersion >= 4 {
+		if len(src) < 1 {
+			return srcOrig, fmt.Errorf("cannot unmarshal valuesCodec from 0 bytes for column %q; need at least 1 byte", ch.name)
+		}
+		ch.valuesCodec = valuesCodec(src[0])
+		src = src[1:]
+		if ch.valuesCodec != valuesCodecDefault && getValueSizeForCodec(ch.valueType, ch.valuesCodec) == 0 {
+			return srcOrig, fmt.Errorf("unexpected valuesCodec=%s for valueType=%s at column %q", ch.valuesCodec, ch.valueType, ch.name)
+		}
+
+		n, nSize := encoding.UnmarshalVarUint64(src)
+		if nSize <= 0 {
+			return srcOrig, fmt.Errorf("cannot unmarshal valuesUncompressedSize for column %q", ch.name)
+		}
+		ch.valuesUncompressedSize = n
+		src = src[nSize:]
+	}
+
 	// Unmarshal the rest of data depending on valueType
 	switch ch.valueType {
 	case valueTypeString:
@@ -100,7 +100,7 @@ func TestColumnsHeaderMarshalUnmarshal(t *testing.T) {
 		cshIndex := getColumnsHeaderIndex()
 		g := &columnNameIDGenerator{}
 
-		data := csh.marshal(nil, cshIndex, g)
+		data := csh.marshal(nil, cshIndex, g, partFormatLatestVersion)
 		if len(data) != marshaledLen {
 			t.Fatalf("unexpected length of the marshaled columnsHeader; got %d; want %d", len(data), marshaledLen)
 		}
@@ -145,7 +145,7 @@ func TestColumnsHeaderMarshalUnmarshal(t *testing.T) {
 				Value: "bar",
 			},
 		},
-	}, 31)
+	}, 35)
 }
 
 func TestBlockHeaderUnmarshalFailure(t *testing.T) {
@@ -280,7 +280,7 @@ func TestColumnsHeaderUnmarshalFailure(t *testing.T) {
 	}
 	cshIndex := getColumnsHeaderIndex()
 	g := &columnNameIDGenerator{}
-	data := csh.marshal(nil, cshIndex, g)
+	data := csh.marshal(nil, cshIndex, g, partFormatLatestVersion)
 	for len(data) > 0 {
 		data = data[:len(data)-1]
 		f(data)
@@ -443,7 +443,7 @@ func TestColumnHeaderMarshalUnmarshal(t *testing.T) {
 	f := func(ch *columnHeader, marshaledLen int) {
 		t.Helper()
 
-		data := ch.marshal(nil)
+		data := ch.marshal(nil, partFormatLatestVersion)
 		if len(data) != marshaledLen {
 			t.Fatalf("unexpected marshaled length of columnHeader; got %d; want %d", len(data), marshaledLen)
 		}
@@ -467,7 +467,71 @@ func TestColumnHeaderMarshalUnmarshal(t *testing.T) {
 	f(&columnHeader{
 		name:      "foo",
 		valueType: valueTypeUint8,
-	}, 7)
+	}, 9)
+	ch := &columnHeader{
+		name:                   "foobar",
+		valueType:              valueTypeDict,
+		valuesCodec:            valuesCodecDictDelta,
+		valuesUncompressedSize: 300,
+
+		valuesOffset: 12345,
+		valuesSize:   254452,
+	}
+	ch.valuesDict.getOrAdd("abc")
+	f(ch, 14)
+}
+
+func TestColumnHeaderUnmarshalV3(t *testing.T) {
+	f := func(ch *columnHeader) {
+		t.Helper()
+
+		// Part format v3 has no valuesCodec and valuesUncompressedSize after valueType
+		dataV3 := ch.marshal(nil, 3)
+		data := ch.marshal(nil, partFormatLatestVersion)
+		_, nSize := encoding.UnmarshalVarUint64(data[2:])
+		if string(dataV3) != string(append([]byte{data[0]}, data[2+nSize:]...)) {
+			t.Fatalf("unexpected columnHeader marshaled in v3 format: %X", dataV3)
+		}
+
+		var ch2 columnHeader
+		tail, err := ch2.unmarshalInplace(dataV3, 3)
+		if err != nil {
+			t.Fatalf("unexpected error in umarshal(%v): %s", ch, err)
+		}
+		if len(tail) > 0 {
+			t.Fatalf("unexpected non-empty tail after unmarshal(%v): %X", ch, tail)
+		}
+		ch2.name = ch.name
+
+		chExpected := *ch
+		chExpected.valuesCodec = valuesCodecDefault
+		chExpected.valuesUncompressedSize = 0
+		if !reflect.DeepEqual(&chExpected, &ch2) {
+			t.Fatalf("unexpected columnHeader after unmarshal;\ngot\n%v\nwant\n%v", &ch2, &chExpected)
+		}
+	}
+
+	f(&columnHeader{
+		name:                   "foo",
+		valueType:              valueTypeUint16,
+		valuesUncompressedSize: 1234,
+		minValue:               10,
+		maxValue:               1000,
+
+		valuesOffset:      123,
+		valuesSize:        456,
+		bloomFilterOffset: 789,
+		bloomFilterSize:   1011,
+	})
+	f(&columnHeader{
+		name:      "foo",
+		valueType: valueTypeString,
+
+		valuesOffset:      123,
+		valuesSize:        456,
+		bloomFilterOffset: 789,
+		bloomFilterSize:   1011,
+	})
 	ch := &columnHeader{
 		name:      "foobar",
 		valueType: valueTypeDict,
@@ -476,7 +540,7 @@ func TestColumnHeaderMarshalUnmarshal(t *testing.T) {
 		valuesSize:   254452,
 	}
 	ch.valuesDict.getOrAdd("abc")
-	f(ch, 11)
+	f(ch)
 }
 
 func TestColumnHeaderUnmarshalFailure(t *testing.T) {
@@ -502,14 +566,23 @@ func TestColumnHeaderUnmarshalFailure(t *testing.T) {
 		valueType:       valueTypeUint16,
 		bloomFilterSize: 3244,
 	}
-	data := ch.marshal(nil)
+	data := ch.marshal(nil, partFormatLatestVersion)
 	f(data[:len(data)-1])
+
+	// the codec cannot be used for the given valueType
+	ch.valuesCodec = valuesCodecXOR
+	f(ch.marshal(nil, partFormatLatestVersion))
+
+	// missing valuesUncompressedSize
+	f([]byte{byte(valueTypeString), byte(valuesCodecDefault)})
 }
 
 func TestColumnHeaderReset(t *testing.T) {
 	ch := &columnHeader{
-		name:      "foobar",
-		valueType: valueTypeUint16,
+		name:                   "foobar",
+		valueType:              valueTypeUint16,
+		valuesCodec:            valuesCodecDeltaOfDelta,
+		valuesUncompressedSize: 1234,
 
 		valuesOffset: 12345,
 		valuesSize:   254452,
@@ -217,7 +217,11 @@ func (bs *blockSearch) search(qs *QueryStats, bsw *blockSearchWork, bm *bitmap)
 	// search rows matching the given filter
 	bm.init(int(bsw.bh.rowsCount))
 	bm.setBits()
-	bs.bsw.pso.filter.applyToBlockSearch(bs, bm)
+	if pa := bsw.pso.analysis; pa != nil {
+		pa.applyFilterWithAnalysis(bs, bm, bsw.pso.filter)
+	} else {
+		bs.bsw.pso.filter.applyToBlockSearch(bs, bm)
+	}
 
 	if bm.isZero() {
 		// The filter doesn't match any logs in the current block.
@@ -319,7 +323,7 @@ func (bs *blockSearch) getColumnHeader(name string) *columnHeader {
 		b = b[cr.offset:]
 		bs.chsCache = slicesutil.SetLength(bs.chsCache, len(bs.chsCache)+1)
 		ch := &bs.chsCache[len(bs.chsCache)-1]
-		if _, err := ch.unmarshalInplace(b, partFormatLatestVersion); err != nil {
+		if _, err := ch.unmarshalInplace(b, bs.partFormatVersion()); err != nil {
 			logger.Panicf("FATAL: %s: cannot unmarshal header for column %q: %s", bs.bsw.p.path, name, err)
 		}
 		ch.name = bs.getColumnNameByID(columnNameID)
@@ -474,7 +478,7 @@ func (bs *blockSearch) getValuesForColumn(ch *columnHeader) []string {
 
 	values = getStringBucket()
 	var err error
-	values.a, err = bs.sbu.unmarshal(values.a[:0], bb.B, bs.bsw.bh.rowsCount)
+	values.a, err = bs.sbu.unmarshalValues(values.a[:0], bb.B, bs.bsw.bh.rowsCount, ch.valueType, ch.valuesCodec)
 	longTermBufPool.Put(bb)
 	if err != nil {
 		logger.Panicf("FATAL: %s: cannot unmarshal column %q: %s", bs.partPath(), ch.name, err)
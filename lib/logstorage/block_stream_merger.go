@@ -16,12 +16,13 @@ import (
 // mustMergeBlockStreams merges bsrs to bsw and updates ph accordingly.
 //
 // if dropFilter is non-nil, then rows matching dropFilter are dropped during the merge.
+// if updater is non-nil, then rows matching dropFilter are updated by updater instead of dropping.
 //
 // Finalize() is guaranteed to be called on bsw before returning from the func.
 // MustClose() is guatanteed to be called on bsrs before returning from the func.
-func mustMergeBlockStreams(ph *partHeader, idb *indexdb, bsw *blockStreamWriter, bsrs []*blockStreamReader, dropFilter *partitionSearchOptions, stopCh <-chan struct{}) {
+func mustMergeBlockStreams(ph *partHeader, idb *indexdb, bsw *blockStreamWriter, bsrs []*blockStreamReader, dropFilter *partitionSearchOptions, updater *rowsUpdater, stopCh <-chan struct{}) {
 	bsm := getBlockStreamMerger()
-	bsm.mustInit(idb, bsw, bsrs, dropFilter)
+	bsm.mustInit(idb, bsw, bsrs, dropFilter, updater)
 	for len(bsm.readersHeap) > 0 {
 		if needStop(stopCh) {
 			break
@@ -58,6 +59,11 @@ type blockStreamMerger struct {
 	// dropFilter is an optional filter for dropping matching rows during the merge.
 	dropFilter *partitionSearchOptions
 
+	// updater is an optional updater for rows matching dropFilter.
+	//
+	// If it is set, then the rows matching dropFilter are updated instead of dropping.
+	updater *rowsUpdater
+
 	// dropFilterFields contains the list of fields needed by dropFilter.
 	dropFilterFields prefixfilter.Filter
 
@@ -107,6 +113,7 @@ func (bsm *blockStreamMerger) reset() {
 	bsm.bsw = nil
 	bsm.bsrs = nil
 	bsm.dropFilter = nil
+	bsm.updater = nil
 	bsm.dropFilterFields.Reset()
 
 	rhs := bsm.readersHeap
@@ -157,7 +164,7 @@ func (bsm *blockStreamMerger) assertNoRows() {
 	}
 }
 
-func (bsm *blockStreamMerger) mustInit(idb *indexdb, bsw *blockStreamWriter, bsrs []*blockStreamReader, dropFilter *partitionSearchOptions) {
+func (bsm *blockStreamMerger) mustInit(idb *indexdb, bsw *blockStreamWriter, bsrs []*blockStreamReader, dropFilter *partitionSearchOptions, updater *rowsUpdater) {
 	bsm.reset()
 
 	bsm.idb = idb
@@ -165,6 +172,7 @@ func (bsm *blockStreamMerger) mustInit(idb *indexdb, bsw *blockStreamWriter, bsr
 	bsm.bsrs = bsrs
 
 	bsm.dropFilter = dropFilter
+	bsm.updater = updater
 	if dropFilter != nil {
 		dropFilter.filter.updateNeededFields(&bsm.dropFilterFields)
 	}
@@ -254,13 +262,13 @@ func (bsm *blockStreamMerger) mustWriteBlockData(bd *blockData) {
 
 	td := &bd.timestampsData
 	if bsm.needDropRows(&bd.streamID, td.minTimestamp, td.maxTimestamp) {
-		if _, ok := bsm.dropFilter.filter.(*filterNoop); ok {
+		if _, ok := bsm.dropFilter.filter.(*filterNoop); ok && bsm.updater == nil {
 			// Fast path - drop the whole bd.
 			// This path occurs when the dropFilter contains only stream filter - '{...}'.
 			// The stream filter goes to dropFilter.streamFilter, while dropFilter.filter becomes noop.
 			return
 		}
-		// Slow path - unpack bd and drop the needed rows before the merge.
+		// Slow path - unpack bd and drop or update the needed rows before the merge.
 		bsm.mustMergeRows(bd)
 		return
 	}
@@ -315,7 +323,11 @@ func (bsm *blockStreamMerger) mustUnmarshalRows(bd *blockData) {
 	td := &bd.timestampsData
 	if bsm.needDropRows(&bd.streamID, td.minTimestamp, td.maxTimestamp) {
 		stream, streamID := bsm.getStreamAndStreamID()
-		bsm.rows.skipRowsByDropFilter(bsm.dropFilter, &bsm.dropFilterFields, rowsLen, stream, streamID)
+		if bsm.updater != nil {
+			bsm.rows.updateRowsByFilter(bsm.dropFilter, &bsm.dropFilterFields, bsm.updater, rowsLen, stream, streamID)
+		} else {
+			bsm.rows.skipRowsByDropFilter(bsm.dropFilter, &bsm.dropFilterFields, rowsLen, stream, streamID)
+		}
 	}
 
 	bsm.uncompressedRowsSizeBytes += uncompressedRowsSizeBytes(bsm.rows.rows[rowsLen:])
@@ -1,6 +1,9 @@
 package logstorage
 
 import (
+	"fmt"
+	"io"
+	"os"
 	"path/filepath"
 	"sync"
 
@@ -16,17 +19,22 @@ import (
 type readerWithStats struct {
 	r         filestream.ReadCloser
 	bytesRead uint64
+
+	// key is used for decrypting the read data. It is nil if the data isn't encrypted.
+	key *encryptionKey
 }
 
 func (r *readerWithStats) reset() {
 	r.r = nil
 	r.bytesRead = 0
+	r.key = nil
 }
 
-func (r *readerWithStats) init(rc filestream.ReadCloser) {
+func (r *readerWithStats) init(rc filestream.ReadCloser, key *encryptionKey) {
 	r.reset()
 
 	r.r = rc
+	r.key = key
 }
 
 // Path returns the path to r file
@@ -35,9 +43,53 @@ func (r *readerWithStats) Path() string {
 }
 
 // MustReadFull reads len(data) to r.
+//
+// If r is encrypted, then the whole encrypted block is read and decrypted into data, so len(data) must equal the plaintext block size.
 func (r *readerWithStats) MustReadFull(data []byte) {
-	fs.MustReadData(r.r, data)
-	r.bytesRead += uint64(len(data))
+	if err := r.ReadFull(data); err != nil {
+		logger.Panicf("FATAL: %s", err)
+	}
+}
+
+// ReadFull reads len(data) to r.
+//
+// It works the same as MustReadFull, but returns an error instead of panicking on read failure.
+func (r *readerWithStats) ReadFull(data []byte) error {
+	if r.key == nil {
+		return r.readFull(data)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	bb := encryptedBufPool.Get()
+	defer encryptedBufPool.Put(bb)
+
+	bb.B = bytesutil.ResizeNoCopyMayOverallocate(bb.B, getEncryptedSize(len(data)))
+	if err := r.readFull(bb.B); err != nil {
+		return err
+	}
+	return decryptBlock(data, bb.B, r.key, r.r.Path())
+}
+
+func (r *readerWithStats) readFull(data []byte) error {
+	n, err := io.ReadFull(r.r, data)
+	r.bytesRead += uint64(n)
+	if err != nil {
+		return fmt.Errorf("%s: cannot read %d bytes; read only %d bytes: %w", r.r.Path(), len(data), n, err)
+	}
+	return nil
+}
+
+// ReadAll reads all the remaining data from r.
+//
+// It is used for reading files, which are written with a single writerWithStats.MustWrite call.
+func (r *readerWithStats) ReadAll() ([]byte, error) {
+	data, err := io.ReadAll(r)
+	if err != nil || r.key == nil {
+		return data, err
+	}
+	return r.key.decrypt(nil, data)
 }
 
 func (r *readerWithStats) Read(p []byte) (int, error) {
@@ -86,9 +138,9 @@ func (r *bloomValuesReader) reset() {
 	r.values.reset()
 }
 
-func (r *bloomValuesReader) init(sr bloomValuesStreamReader) {
-	r.bloom.init(sr.bloom)
-	r.values.init(sr.values)
+func (r *bloomValuesReader) init(sr bloomValuesStreamReader, key *encryptionKey) {
+	r.bloom.init(sr.bloom, key)
+	r.values.init(sr.values, key)
 }
 
 func (r *bloomValuesReader) totalBytesRead() uint64 {
@@ -130,32 +182,41 @@ func (sr *streamReaders) reset() {
 
 func (sr *streamReaders) init(partFormatVersion uint, columnNamesReader, columnIdxsReader, metaindexReader, indexReader,
 	columnsHeaderIndexReader, columnsHeaderReader, timestampsReader filestream.ReadCloser,
-	messageBloomValuesReader, oldBloomValuesReader bloomValuesStreamReader, bloomValuesShards []bloomValuesStreamReader,
-) {
+	messageBloomValuesReader, oldBloomValuesReader bloomValuesStreamReader, bloomValuesShards []bloomValuesStreamReader, key *encryptionKey,
+) error {
 	sr.partFormatVersion = partFormatVersion
 
-	sr.columnNamesReader.init(columnNamesReader)
-	sr.columnIdxsReader.init(columnIdxsReader)
-	sr.metaindexReader.init(metaindexReader)
-	sr.indexReader.init(indexReader)
-	sr.columnsHeaderIndexReader.init(columnsHeaderIndexReader)
-	sr.columnsHeaderReader.init(columnsHeaderReader)
-	sr.timestampsReader.init(timestampsReader)
+	sr.columnNamesReader.init(columnNamesReader, key)
+	sr.columnIdxsReader.init(columnIdxsReader, key)
+	sr.metaindexReader.init(metaindexReader, key)
+	sr.indexReader.init(indexReader, key)
+	sr.columnsHeaderIndexReader.init(columnsHeaderIndexReader, key)
+	sr.columnsHeaderReader.init(columnsHeaderReader, key)
+	sr.timestampsReader.init(timestampsReader, key)
 
-	sr.messageBloomValuesReader.init(messageBloomValuesReader)
-	sr.oldBloomValuesReader.init(oldBloomValuesReader)
+	sr.messageBloomValuesReader.init(messageBloomValuesReader, key)
+	sr.oldBloomValuesReader.init(oldBloomValuesReader, key)
 
 	sr.bloomValuesShards = slicesutil.SetLength(sr.bloomValuesShards, len(bloomValuesShards))
 	for i := range sr.bloomValuesShards {
-		sr.bloomValuesShards[i].init(bloomValuesShards[i])
+		sr.bloomValuesShards[i].init(bloomValuesShards[i], key)
 	}
 
 	if partFormatVersion >= 1 {
-		sr.columnNames, _ = mustReadColumnNames(&sr.columnNamesReader)
+		columnNames, _, err := readColumnNames(&sr.columnNamesReader)
+		if err != nil {
+			return err
+		}
+		sr.columnNames = columnNames
 	}
 	if partFormatVersion >= 3 {
-		sr.columnIdxs = mustReadColumnIdxs(&sr.columnIdxsReader, sr.columnNames, uint64(len(bloomValuesShards)))
+		columnIdxs, err := readColumnIdxs(&sr.columnIdxsReader, sr.columnNames, uint64(len(bloomValuesShards)))
+		if err != nil {
+			return err
+		}
+		sr.columnIdxs = columnIdxs
 	}
+	return nil
 }
 
 func (sr *streamReaders) totalBytesRead() uint64 {
@@ -200,12 +261,12 @@ func (sr *streamReaders) MustClose() {
 	fs.MustCloseParallel(cs)
 }
 
-func (sr *streamReaders) getBloomValuesReaderForColumnName(name string) *bloomValuesReader {
+func (sr *streamReaders) getBloomValuesReaderForColumnName(name string) (*bloomValuesReader, error) {
 	if name == "" {
-		return &sr.messageBloomValuesReader
+		return &sr.messageBloomValuesReader, nil
 	}
 	if sr.partFormatVersion < 1 {
-		return &sr.oldBloomValuesReader
+		return &sr.oldBloomValuesReader, nil
 	}
 	if sr.partFormatVersion < 3 {
 		n := len(sr.bloomValuesShards)
@@ -214,14 +275,17 @@ func (sr *streamReaders) getBloomValuesReaderForColumnName(name string) *bloomVa
 			h := xxhash.Sum64(bytesutil.ToUnsafeBytes(name))
 			shardIdx = h % uint64(n)
 		}
-		return &sr.bloomValuesShards[shardIdx]
+		return &sr.bloomValuesShards[shardIdx], nil
 	}
 
 	shardIdx, ok := sr.columnIdxs[name]
 	if !ok {
-		logger.Panicf("BUG: missing column index for %q; columnIdxs=%v", name, sr.columnIdxs)
+		return nil, fmt.Errorf("%s: missing column index for %q", sr.columnIdxsReader.Path(), name)
+	}
+	if shardIdx >= uint64(len(sr.bloomValuesShards)) {
+		return nil, fmt.Errorf("%s: too big shard index for column %q: %d; mustn't exceed %d", sr.columnIdxsReader.Path(), name, shardIdx, len(sr.bloomValuesShards)-1)
 	}
-	return &sr.bloomValuesShards[shardIdx]
+	return &sr.bloomValuesShards[shardIdx], nil
 }
 
 // blockStreamReader is used for reading blocks in streaming manner from a part.
@@ -326,23 +390,42 @@ func (bsr *blockStreamReader) MustInitFromInmemoryPart(mp *inmemoryPart) {
 		mp.fieldBloomValues.NewStreamReader(),
 	}
 
-	bsr.streamReaders.init(bsr.ph.FormatVersion, columnNamesReader, columnIdxsReader, metaindexReader, indexReader,
+	if err := bsr.streamReaders.init(bsr.ph.FormatVersion, columnNamesReader, columnIdxsReader, metaindexReader, indexReader,
 		columnsHeaderIndexReader, columnsHeaderReader, timestampsReader,
-		messageBloomValuesReader, oldBloomValuesReader, bloomValuesShards)
+		messageBloomValuesReader, oldBloomValuesReader, bloomValuesShards, nil); err != nil {
+		logger.Panicf("FATAL: %s", err)
+	}
 
 	// Read metaindex data
 	bsr.indexBlockHeaders = mustReadIndexBlockHeaders(bsr.indexBlockHeaders[:0], &bsr.streamReaders.metaindexReader)
 }
 
 // MustInitFromFilePart initializes bsr from file part at the given path.
-func (bsr *blockStreamReader) MustInitFromFilePart(path string) {
+//
+// ek is used for decrypting the part if it is encrypted.
+func (bsr *blockStreamReader) MustInitFromFilePart(path string, ek *EncryptionKeys) {
+	if err := bsr.initFromFilePart(path, ek); err != nil {
+		logger.Panicf("FATAL: %s", err)
+	}
+}
+
+// initFromFilePart works the same as MustInitFromFilePart, but returns an error instead of panicking on missing or invalid part data.
+//
+// bsr doesn't hold open files if an error is returned.
+func (bsr *blockStreamReader) initFromFilePart(path string, ek *EncryptionKeys) error {
 	bsr.reset()
 
 	// Files in the part are always read without OS cache pollution,
 	// since they are usually deleted after the merge.
 	const nocache = true
 
-	bsr.ph.mustReadMetadata(path)
+	if err := bsr.ph.readMetadata(path); err != nil {
+		return err
+	}
+	key, err := ek.getKeyForPart(&bsr.ph, path)
+	if err != nil {
+		return err
+	}
 
 	columnNamesPath := filepath.Join(path, columnNamesFilename)
 	columnIdxsPath := filepath.Join(path, columnIdxsFilename)
@@ -356,70 +439,91 @@ func (bsr *blockStreamReader) MustInitFromFilePart(path string) {
 	// on high-latency storage systems such as NFS or Ceph.
 
 	var pfo filestream.ParallelFileOpener
+	var paths []string
+	addFile := func(path string, rc *filestream.ReadCloser) {
+		paths = append(paths, path)
+		pfo.Add(path, rc, nocache)
+	}
 
 	var columnNamesReader filestream.ReadCloser
 	if bsr.ph.FormatVersion >= 1 {
-		pfo.Add(columnNamesPath, &columnNamesReader, nocache)
+		addFile(columnNamesPath, &columnNamesReader)
 	}
 
 	var columnIdxsReader filestream.ReadCloser
 	if bsr.ph.FormatVersion >= 3 {
-		pfo.Add(columnIdxsPath, &columnIdxsReader, nocache)
+		addFile(columnIdxsPath, &columnIdxsReader)
 	}
 
 	var metaindexReader filestream.ReadCloser
-	pfo.Add(metaindexPath, &metaindexReader, nocache)
+	addFile(metaindexPath, &metaindexReader)
 
 	var indexReader filestream.ReadCloser
-	pfo.Add(indexPath, &indexReader, nocache)
+	addFile(indexPath, &indexReader)
 
 	var columnsHeaderIndexReader filestream.ReadCloser
 	if bsr.ph.FormatVersion >= 1 {
-		pfo.Add(columnsHeaderIndexPath, &columnsHeaderIndexReader, nocache)
+		addFile(columnsHeaderIndexPath, &columnsHeaderIndexReader)
 	}
 
 	var columnsHeaderReader filestream.ReadCloser
-	pfo.Add(columnsHeaderPath, &columnsHeaderReader, nocache)
+	addFile(columnsHeaderPath, &columnsHeaderReader)
 
 	var timestampsReader filestream.ReadCloser
-	pfo.Add(timestampsPath, &timestampsReader, nocache)
+	addFile(timestampsPath, &timestampsReader)
 
 	messageBloomFilterPath := filepath.Join(path, messageBloomFilename)
 	messageValuesPath := filepath.Join(path, messageValuesFilename)
 	var messageBloomValuesReader bloomValuesStreamReader
-	pfo.Add(messageBloomFilterPath, &messageBloomValuesReader.bloom, nocache)
-	pfo.Add(messageValuesPath, &messageBloomValuesReader.values, nocache)
+	addFile(messageBloomFilterPath, &messageBloomValuesReader.bloom)
+	addFile(messageValuesPath, &messageBloomValuesReader.values)
 
 	var oldBloomValuesReader bloomValuesStreamReader
 	var bloomValuesShards []bloomValuesStreamReader
 	if bsr.ph.FormatVersion < 1 {
 		bloomPath := filepath.Join(path, oldBloomFilename)
-		pfo.Add(bloomPath, &oldBloomValuesReader.bloom, nocache)
+		addFile(bloomPath, &oldBloomValuesReader.bloom)
 
 		valuesPath := filepath.Join(path, oldValuesFilename)
-		pfo.Add(valuesPath, &oldBloomValuesReader.values, nocache)
+		addFile(valuesPath, &oldBloomValuesReader.values)
 	} else {
 		bloomValuesShards = make([]bloomValuesStreamReader, bsr.ph.BloomValuesShardsCount)
 		for i := range bloomValuesShards {
 			shard := &bloomValuesShards[i]
 
 			bloomPath := getBloomFilePath(path, uint64(i))
-			pfo.Add(bloomPath, &shard.bloom, nocache)
+			addFile(bloomPath, &shard.bloom)
 
 			valuesPath := getValuesFilePath(path, uint64(i))
-			pfo.Add(valuesPath, &shard.values, nocache)
+			addFile(valuesPath, &shard.values)
 		}
 	}
 
+	// ParallelFileOpener panics on missing files, so verify the files can be opened beforehand.
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("cannot open part file: %w", err)
+		}
+		_ = f.Close()
+	}
 	pfo.Run()
 
 	// Initialize streamReaders
-	bsr.streamReaders.init(bsr.ph.FormatVersion, columnNamesReader, columnIdxsReader, metaindexReader, indexReader,
+	if err := bsr.streamReaders.init(bsr.ph.FormatVersion, columnNamesReader, columnIdxsReader, metaindexReader, indexReader,
 		columnsHeaderIndexReader, columnsHeaderReader, timestampsReader,
-		messageBloomValuesReader, oldBloomValuesReader, bloomValuesShards)
+		messageBloomValuesReader, oldBloomValuesReader, bloomValuesShards, key); err != nil {
+		bsr.MustClose()
+		return err
+	}
 
 	// Read metaindex data
-	bsr.indexBlockHeaders = mustReadIndexBlockHeaders(bsr.indexBlockHeaders[:0], &bsr.streamReaders.metaindexReader)
+	bsr.indexBlockHeaders, err = readIndexBlockHeaders(bsr.indexBlockHeaders[:0], &bsr.streamReaders.metaindexReader)
+	if err != nil {
+		bsr.MustClose()
+		return err
+	}
+	return nil
 }
 
 // NextBlock reads the next block from bsr and puts it into bsr.blockData.
@@ -428,9 +532,22 @@ func (bsr *blockStreamReader) MustInitFromFilePart(path string) {
 //
 // bsr.blockData is valid until the next call to NextBlock().
 func (bsr *blockStreamReader) NextBlock() bool {
+	ok, err := bsr.nextBlock()
+	if err != nil {
+		logger.Panicf("FATAL: %s", err)
+	}
+	return ok
+}
+
+// nextBlock works the same as NextBlock, but returns an error instead of panicking on invalid part data.
+func (bsr *blockStreamReader) nextBlock() (bool, error) {
 	for bsr.nextBlockIdx >= len(bsr.blockHeaders) {
-		if !bsr.nextIndexBlock() {
-			return false
+		ok, err := bsr.nextIndexBlock()
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, nil
 		}
 	}
 	ih := &bsr.indexBlockHeaders[bsr.nextIndexBlockIdx-1]
@@ -439,92 +556,98 @@ func (bsr *blockStreamReader) NextBlock() bool {
 
 	// Validate bh
 	if bh.streamID.less(&bsr.sidLast) {
-		logger.Panicf("FATAL: %s: blockHeader.streamID=%s cannot be smaller than the streamID from the previously read block: %s", bsr.Path(), &bh.streamID, &bsr.sidLast)
+		return false, fmt.Errorf("%s: blockHeader.streamID=%s cannot be smaller than the streamID from the previously read block: %s", bsr.Path(), &bh.streamID, &bsr.sidLast)
 	}
 	if bh.streamID.equal(&bsr.sidLast) && th.minTimestamp < bsr.minTimestampLast {
-		logger.Panicf("FATAL: %s: timestamps.minTimestamp=%d cannot be smaller than the minTimestamp for the previously read block for the same streamID: %d",
+		return false, fmt.Errorf("%s: timestamps.minTimestamp=%d cannot be smaller than the minTimestamp for the previously read block for the same streamID: %d",
 			bsr.Path(), th.minTimestamp, bsr.minTimestampLast)
 	}
 	bsr.minTimestampLast = th.minTimestamp
 	bsr.sidLast = bh.streamID
 	if th.minTimestamp < ih.minTimestamp {
-		logger.Panicf("FATAL: %s: timestampsHeader.minTimestamp=%d cannot be smaller than indexBlockHeader.minTimestamp=%d", bsr.Path(), th.minTimestamp, ih.minTimestamp)
+		return false, fmt.Errorf("%s: timestampsHeader.minTimestamp=%d cannot be smaller than indexBlockHeader.minTimestamp=%d", bsr.Path(), th.minTimestamp, ih.minTimestamp)
 	}
 	if th.maxTimestamp > ih.maxTimestamp {
-		logger.Panicf("FATAL: %s: timestampsHeader.maxTimestamp=%d cannot be bigger than indexBlockHeader.maxTimestamp=%d", bsr.Path(), th.maxTimestamp, ih.minTimestamp)
+		return false, fmt.Errorf("%s: timestampsHeader.maxTimestamp=%d cannot be bigger than indexBlockHeader.maxTimestamp=%d", bsr.Path(), th.maxTimestamp, ih.minTimestamp)
 	}
 
 	// Read bsr.blockData
 	bsr.a.reset()
-	bsr.blockData.mustReadFrom(&bsr.a, bh, &bsr.streamReaders)
+	if err := bsr.blockData.readFrom(&bsr.a, bh, &bsr.streamReaders); err != nil {
+		return false, err
+	}
 
 	bsr.globalUncompressedSizeBytes += bh.uncompressedSizeBytes
 	bsr.globalRowsCount += bh.rowsCount
 	bsr.globalBlocksCount++
 	if bsr.globalUncompressedSizeBytes > bsr.ph.UncompressedSizeBytes {
-		logger.Panicf("FATAL: %s: too big size of entries read: %d; mustn't exceed partHeader.UncompressedSizeBytes=%d",
+		return false, fmt.Errorf("%s: too big size of entries read: %d; mustn't exceed partHeader.UncompressedSizeBytes=%d",
 			bsr.Path(), bsr.globalUncompressedSizeBytes, bsr.ph.UncompressedSizeBytes)
 	}
 	if bsr.globalRowsCount > bsr.ph.RowsCount {
-		logger.Panicf("FATAL: %s: too many log entries read so far: %d; mustn't exceed partHeader.RowsCount=%d", bsr.Path(), bsr.globalRowsCount, bsr.ph.RowsCount)
+		return false, fmt.Errorf("%s: too many log entries read so far: %d; mustn't exceed partHeader.RowsCount=%d", bsr.Path(), bsr.globalRowsCount, bsr.ph.RowsCount)
 	}
 	if bsr.globalBlocksCount > bsr.ph.BlocksCount {
-		logger.Panicf("FATAL: %s: too many blocks read so far: %d; mustn't exceed partHeader.BlocksCount=%d", bsr.Path(), bsr.globalBlocksCount, bsr.ph.BlocksCount)
+		return false, fmt.Errorf("%s: too many blocks read so far: %d; mustn't exceed partHeader.BlocksCount=%d", bsr.Path(), bsr.globalBlocksCount, bsr.ph.BlocksCount)
 	}
 
 	// The block has been successfully read
 	bsr.nextBlockIdx++
-	return true
+	return true, nil
 }
 
-func (bsr *blockStreamReader) nextIndexBlock() bool {
+func (bsr *blockStreamReader) nextIndexBlock() (bool, error) {
 	// Advance to the next indexBlockHeader
 	if bsr.nextIndexBlockIdx >= len(bsr.indexBlockHeaders) {
 		// No more blocks left
 		// Validate bsr.ph
 		totalBytesRead := bsr.streamReaders.totalBytesRead()
 		if bsr.ph.CompressedSizeBytes != totalBytesRead {
-			logger.Panicf("FATAL: %s: partHeader.CompressedSizeBytes=%d must match the size of data read: %d", bsr.Path(), bsr.ph.CompressedSizeBytes, totalBytesRead)
+			return false, fmt.Errorf("%s: partHeader.CompressedSizeBytes=%d must match the size of data read: %d", bsr.Path(), bsr.ph.CompressedSizeBytes, totalBytesRead)
 		}
 		if bsr.ph.UncompressedSizeBytes != bsr.globalUncompressedSizeBytes {
-			logger.Panicf("FATAL: %s: partHeader.UncompressedSizeBytes=%d must match the size of entries read: %d",
+			return false, fmt.Errorf("%s: partHeader.UncompressedSizeBytes=%d must match the size of entries read: %d",
 				bsr.Path(), bsr.ph.UncompressedSizeBytes, bsr.globalUncompressedSizeBytes)
 		}
 		if bsr.ph.RowsCount != bsr.globalRowsCount {
-			logger.Panicf("FATAL: %s: partHeader.RowsCount=%d must match the number of log entries read: %d", bsr.Path(), bsr.ph.RowsCount, bsr.globalRowsCount)
+			return false, fmt.Errorf("%s: partHeader.RowsCount=%d must match the number of log entries read: %d", bsr.Path(), bsr.ph.RowsCount, bsr.globalRowsCount)
 		}
 		if bsr.ph.BlocksCount != bsr.globalBlocksCount {
-			logger.Panicf("FATAL: %s: partHeader.BlocksCount=%d must match the number of blocks read: %d", bsr.Path(), bsr.ph.BlocksCount, bsr.globalBlocksCount)
+			return false, fmt.Errorf("%s: partHeader.BlocksCount=%d must match the number of blocks read: %d", bsr.Path(), bsr.ph.BlocksCount, bsr.globalBlocksCount)
 		}
-		return false
+		return false, nil
 	}
 	ih := &bsr.indexBlockHeaders[bsr.nextIndexBlockIdx]
 
 	// Validate ih
 	metaindexReader := &bsr.streamReaders.metaindexReader
 	if ih.minTimestamp < bsr.ph.MinTimestamp {
-		logger.Panicf("FATAL: %s: indexBlockHeader.minTimestamp=%d cannot be smaller than partHeader.MinTimestamp=%d",
+		return false, fmt.Errorf("%s: indexBlockHeader.minTimestamp=%d cannot be smaller than partHeader.MinTimestamp=%d",
 			metaindexReader.Path(), ih.minTimestamp, bsr.ph.MinTimestamp)
 	}
 	if ih.maxTimestamp > bsr.ph.MaxTimestamp {
-		logger.Panicf("FATAL: %s: indexBlockHeader.maxTimestamp=%d cannot be bigger than partHeader.MaxTimestamp=%d",
+		return false, fmt.Errorf("%s: indexBlockHeader.maxTimestamp=%d cannot be bigger than partHeader.MaxTimestamp=%d",
 			metaindexReader.Path(), ih.maxTimestamp, bsr.ph.MaxTimestamp)
 	}
 
 	// Read indexBlock for the given ih
 	bb := longTermBufPool.Get()
-	bb.B = ih.mustReadNextIndexBlock(bb.B[:0], &bsr.streamReaders)
-	bsr.blockHeaders = resetBlockHeaders(bsr.blockHeaders)
+	defer longTermBufPool.Put(bb)
+
 	var err error
+	bb.B, err = ih.readNextIndexBlock(bb.B[:0], &bsr.streamReaders)
+	if err != nil {
+		return false, err
+	}
+	bsr.blockHeaders = resetBlockHeaders(bsr.blockHeaders)
 	bsr.blockHeaders, err = unmarshalBlockHeaders(bsr.blockHeaders[:0], bb.B, bsr.ph.FormatVersion)
-	longTermBufPool.Put(bb)
 	if err != nil {
-		logger.Panicf("FATAL: %s: cannot unmarshal blockHeader entries: %s", bsr.streamReaders.indexReader.Path(), err)
+		return false, fmt.Errorf("%s: cannot unmarshal blockHeader entries: %w", bsr.streamReaders.indexReader.Path(), err)
 	}
 
 	bsr.nextIndexBlockIdx++
 	bsr.nextBlockIdx = 0
-	return true
+	return true, nil
 }
 
 // MustClose closes bsr.
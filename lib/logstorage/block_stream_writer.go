@@ -15,24 +15,40 @@ import (
 type writerWithStats struct {
 	w            filestream.WriteCloser
 	bytesWritten uint64
+
+	// key is used for encrypting the written data. It is nil if the data mustn't be encrypted.
+	key *encryptionKey
 }
 
 func (w *writerWithStats) reset() {
 	w.w = nil
 	w.bytesWritten = 0
+	w.key = nil
 }
 
-func (w *writerWithStats) init(wc filestream.WriteCloser) {
+func (w *writerWithStats) init(wc filestream.WriteCloser, key *encryptionKey) {
 	w.reset()
 
 	w.w = wc
+	w.key = key
 }
 
 func (w *writerWithStats) Path() string {
 	return w.w.Path()
 }
 
+// MustWrite writes data to w.
+//
+// If w is encrypted, then data is written as a single encrypted block, so it must be read with a single readerWithStats.MustReadFull call.
 func (w *writerWithStats) MustWrite(data []byte) {
+	if w.key != nil {
+		bb := encryptedBufPool.Get()
+		bb.B = w.key.encrypt(bb.B[:0], data)
+		fs.MustWriteData(w.w, bb.B)
+		w.bytesWritten += uint64(len(bb.B))
+		encryptedBufPool.Put(bb)
+		return
+	}
 	fs.MustWriteData(w.w, data)
 	w.bytesWritten += uint64(len(data))
 }
@@ -63,6 +79,15 @@ type streamWriters struct {
 
 	columnIdxs    map[uint64]uint64
 	nextColumnIdx uint64
+
+	// key is used for encrypting the written data. It is nil if the data mustn't be encrypted.
+	key *encryptionKey
+
+	// valuesCompression contains settings for marshaling column values.
+	valuesCompression valuesCompression
+
+	// partFormatVersion is the format version for the written part.
+	partFormatVersion uint
 }
 
 type bloomValuesWriter struct {
@@ -75,9 +100,9 @@ func (w *bloomValuesWriter) reset() {
 	w.values.reset()
 }
 
-func (w *bloomValuesWriter) init(sw bloomValuesStreamWriter) {
-	w.bloom.init(sw.bloom)
-	w.values.init(sw.values)
+func (w *bloomValuesWriter) init(sw bloomValuesStreamWriter, key *encryptionKey) {
+	w.bloom.init(sw.bloom, key)
+	w.values.init(sw.values, key)
 }
 
 func (w *bloomValuesWriter) totalBytesWritten() uint64 {
@@ -116,24 +141,34 @@ func (sw *streamWriters) reset() {
 	sw.columnNameIDGenerator.reset()
 	sw.columnIdxs = nil
 	sw.nextColumnIdx = 0
+
+	sw.key = nil
+
+	sw.valuesCompression = valuesCompression{}
+
+	sw.partFormatVersion = 0
 }
 
 func (sw *streamWriters) init(columnNamesWriter, columnIdxsWriter, metaindexWriter, indexWriter,
 	columnsHeaderIndexWriter, columnsHeaderWriter, timestampsWriter filestream.WriteCloser,
 	messageBloomValuesWriter bloomValuesStreamWriter, createBloomValuesWriter func(shardIdx uint64) bloomValuesStreamWriter, maxShards uint64,
+	key *encryptionKey,
 ) {
-	sw.columnNamesWriter.init(columnNamesWriter)
-	sw.columnIdxsWriter.init(columnIdxsWriter)
-	sw.metaindexWriter.init(metaindexWriter)
-	sw.indexWriter.init(indexWriter)
-	sw.columnsHeaderIndexWriter.init(columnsHeaderIndexWriter)
-	sw.columnsHeaderWriter.init(columnsHeaderWriter)
-	sw.timestampsWriter.init(timestampsWriter)
+	sw.columnNamesWriter.init(columnNamesWriter, key)
+	sw.columnIdxsWriter.init(columnIdxsWriter, key)
+	sw.metaindexWriter.init(metaindexWriter, key)
+	sw.indexWriter.init(indexWriter, key)
+	sw.columnsHeaderIndexWriter.init(columnsHeaderIndexWriter, key)
+	sw.columnsHeaderWriter.init(columnsHeaderWriter, key)
+	sw.timestampsWriter.init(timestampsWriter, key)
 
-	sw.messageBloomValuesWriter.init(messageBloomValuesWriter)
+	sw.messageBloomValuesWriter.init(messageBloomValuesWriter, key)
 
 	sw.createBloomValuesWriter = createBloomValuesWriter
 	sw.maxShards = maxShards
+
+	sw.key = key
+	sw.partFormatVersion = partFormatLatestVersion
 }
 
 func (sw *streamWriters) totalBytesWritten() uint64 {
@@ -201,7 +236,7 @@ func (sw *streamWriters) getBloomValuesWriterForColumnName(name string) *bloomVa
 		}
 		sws := sw.createBloomValuesWriter(shardIdx)
 		sw.bloomValuesShards = slicesutil.SetLength(sw.bloomValuesShards, len(sw.bloomValuesShards)+1)
-		sw.bloomValuesShards[len(sw.bloomValuesShards)-1].init(sws)
+		sw.bloomValuesShards[len(sw.bloomValuesShards)-1].init(sws, sw.key)
 	}
 	return &sw.bloomValuesShards[shardIdx]
 }
@@ -290,13 +325,15 @@ func (bsw *blockStreamWriter) MustInitForInmemoryPart(mp *inmemoryPart) {
 		return mp.fieldBloomValues.NewStreamWriter()
 	}
 
-	bsw.streamWriters.init(&mp.columnNames, &mp.columnIdxs, &mp.metaindex, &mp.index, &mp.columnsHeaderIndex, &mp.columnsHeader, &mp.timestamps, messageBloomValues, createBloomValuesWriter, 1)
+	bsw.streamWriters.init(&mp.columnNames, &mp.columnIdxs, &mp.metaindex, &mp.index, &mp.columnsHeaderIndex, &mp.columnsHeader, &mp.timestamps, messageBloomValues, createBloomValuesWriter, 1, nil)
 }
 
 // MustInitForFilePart initializes bsw for writing data to file part located at path.
 //
 // if nocache is true, then the written data doesn't go to OS page cache.
-func (bsw *blockStreamWriter) MustInitForFilePart(path string, nocache bool) {
+//
+// If key isn't nil, then the written data is encrypted with the key.
+func (bsw *blockStreamWriter) MustInitForFilePart(path string, nocache bool, key *encryptionKey) {
 	bsw.reset()
 
 	fs.MustMkdirFailIfExist(path)
@@ -359,7 +396,30 @@ func (bsw *blockStreamWriter) MustInitForFilePart(path string, nocache bool) {
 
 	bsw.streamWriters.init(columnNamesWriter, columnIdxsWriter, metaindexWriter, indexWriter,
 		columnsHeaderIndexWriter, columnsHeaderWriter, timestampsWriter, messageBloomValuesWriter,
-		createBloomValuesWriter, bloomValuesMaxShardsCount)
+		createBloomValuesWriter, bloomValuesMaxShardsCount, key)
+}
+
+// setValuesCompression sets vc for marshaling column values at blocks written to bsw.
+//
+// It must be called after bsw initialization.
+func (bsw *blockStreamWriter) setValuesCompression(vc valuesCompression) {
+	bsw.streamWriters.valuesCompression = vc
+}
+
+// setPartFormatVersion sets the format version for the part written to bsw.
+//
+// Only part formats starting from v3 can be written. Column values are always marshaled with valuesCodecDefault for part formats below v4.
+//
+// It must be called after bsw initialization.
+func (bsw *blockStreamWriter) setPartFormatVersion(partFormatVersion uint) {
+	if partFormatVersion < 3 || partFormatVersion > partFormatLatestVersion {
+		logger.Panicf("BUG: unsupported part format version for writing: %d; it must be in the range [3..%d]", partFormatVersion, partFormatLatestVersion)
+	}
+	sw := &bsw.streamWriters
+	sw.partFormatVersion = partFormatVersion
+	if partFormatVersion < 4 {
+		sw.valuesCompression = valuesCompression{}
+	}
 }
 
 // MustWriteRows writes timestamps with rows under the given sid to bsw.
@@ -466,13 +526,17 @@ func (bsw *blockStreamWriter) mustFlushIndexBlock(data []byte) {
 //
 // bsw can be reused after calling Finalize().
 func (bsw *blockStreamWriter) Finalize(ph *partHeader) {
-	ph.FormatVersion = partFormatLatestVersion
+	ph.FormatVersion = bsw.streamWriters.partFormatVersion
 	ph.UncompressedSizeBytes = bsw.globalUncompressedSizeBytes
 	ph.RowsCount = bsw.globalRowsCount
 	ph.BlocksCount = bsw.globalBlocksCount
 	ph.MinTimestamp = bsw.globalMinTimestamp
 	ph.MaxTimestamp = bsw.globalMaxTimestamp
 	ph.BloomValuesShardsCount = uint64(len(bsw.streamWriters.bloomValuesShards))
+	ph.EncryptionKeyID = ""
+	if key := bsw.streamWriters.key; key != nil {
+		ph.EncryptionKeyID = key.id
+	}
 
 	bsw.mustFlushIndexBlock(bsw.indexBlockData)
 
@@ -17,6 +17,7 @@ type chunkedAllocator struct {
 	countEmptyProcessors       []statsCountEmptyProcessor
 	countUniqProcessors        []statsCountUniqProcessor
 	countUniqHashProcessors    []statsCountUniqHashProcessor
+	covarProcessors            []statsCovarProcessor
 	histogramProcessors        []statsHistogramProcessor
 	jsonValuesProcessors       []statsJSONValuesProcessor
 	jsonValuesSortedProcessors []statsJSONValuesSortedProcessor
@@ -24,6 +25,8 @@ type chunkedAllocator struct {
 	maxProcessors              []statsMaxProcessor
 	medianProcessors           []statsMedianProcessor
 	minProcessors              []statsMinProcessor
+	modeProcessors             []statsModeProcessor
+	momentsProcessors          []statsMomentsProcessor
 	quantileProcessors         []statsQuantileProcessor
 	rateProcessors             []statsRateProcessor
 	rateSumProcessors          []statsRateSumProcessor
@@ -72,6 +75,10 @@ func (a *chunkedAllocator) newStatsCountUniqHashProcessor() (p *statsCountUniqHa
 	return addNewItem(&a.countUniqHashProcessors, a)
 }
 
+func (a *chunkedAllocator) newStatsCovarProcessor() (p *statsCovarProcessor) {
+	return addNewItem(&a.covarProcessors, a)
+}
+
 func (a *chunkedAllocator) newStatsHistogramProcessor() (p *statsHistogramProcessor) {
 	return addNewItem(&a.histogramProcessors, a)
 }
@@ -100,6 +107,14 @@ func (a *chunkedAllocator) newStatsMinProcessor() (p *statsMinProcessor) {
 	return addNewItem(&a.minProcessors, a)
 }
 
+func (a *chunkedAllocator) newStatsModeProcessor() (p *statsModeProcessor) {
+	return addNewItem(&a.modeProcessors, a)
+}
+
+func (a *chunkedAllocator) newStatsMomentsProcessor() (p *statsMomentsProcessor) {
+	return addNewItem(&a.momentsProcessors, a)
+}
+
 func (a *chunkedAllocator) newStatsQuantileProcessor() (p *statsQuantileProcessor) {
 	return addNewItem(&a.quantileProcessors, a)
 }
@@ -2,12 +2,10 @@ package logstorage
 
 import (
 	"fmt"
-	"io"
 	"math"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
-	"github.com/VictoriaMetrics/VictoriaMetrics/lib/filestream"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 )
 
@@ -16,18 +14,26 @@ func mustWriteColumnIdxs(w *writerWithStats, columnIdxs map[uint64]uint64) {
 	w.MustWrite(data)
 }
 
-func mustReadColumnIdxs(r filestream.ReadCloser, columnNames []string, shardsCount uint64) map[string]uint64 {
-	src, err := io.ReadAll(r)
+func mustReadColumnIdxs(r *readerWithStats, columnNames []string, shardsCount uint64) map[string]uint64 {
+	columnIdxs, err := readColumnIdxs(r, columnNames, shardsCount)
 	if err != nil {
-		logger.Panicf("FATAL: %s: cannot read column indexes: %s", r.Path(), err)
+		logger.Panicf("FATAL: %s", err)
+	}
+	return columnIdxs
+}
+
+func readColumnIdxs(r *readerWithStats, columnNames []string, shardsCount uint64) (map[string]uint64, error) {
+	src, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: cannot read column indexes: %w", r.Path(), err)
 	}
 
 	columnIdxs, err := unmarshalColumnIdxs(src, columnNames, shardsCount)
 	if err != nil {
-		logger.Panicf("FATAL: %s: cannot parse column indexes: %s", r.Path(), err)
+		return nil, fmt.Errorf("%s: cannot parse column indexes: %w", r.Path(), err)
 	}
 
-	return columnIdxs
+	return columnIdxs, nil
 }
 
 func marshalColumnIdxs(dst []byte, columnIdxs map[uint64]uint64) []byte {
@@ -84,18 +90,26 @@ func mustWriteColumnNames(w *writerWithStats, columnNames []string) {
 	w.MustWrite(data)
 }
 
-func mustReadColumnNames(r filestream.ReadCloser) ([]string, map[string]uint64) {
-	src, err := io.ReadAll(r)
+func mustReadColumnNames(r *readerWithStats) ([]string, map[string]uint64) {
+	columnNames, columnNameIDs, err := readColumnNames(r)
 	if err != nil {
-		logger.Panicf("FATAL: %s: cannot read column names: %s", r.Path(), err)
+		logger.Panicf("FATAL: %s", err)
+	}
+	return columnNames, columnNameIDs
+}
+
+func readColumnNames(r *readerWithStats) ([]string, map[string]uint64, error) {
+	src, err := r.ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: cannot read column names: %w", r.Path(), err)
 	}
 
 	columnNames, columnNameIDs, err := unmarshalColumnNames(src)
 	if err != nil {
-		logger.Panicf("FATAL: %s: %s", r.Path(), err)
+		return nil, nil, fmt.Errorf("%s: %w", r.Path(), err)
 	}
 
-	return columnNames, columnNameIDs
+	return columnNames, columnNameIDs, nil
 }
 
 func marshalColumnNames(dst []byte, columnNames []string) []byte {
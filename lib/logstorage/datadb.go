@@ -213,7 +213,10 @@ func mustOpenDatadb(pt *partition, path string, flushInterval time.Duration) *da
 	ddb.rb.init(&ddb.wg, ddb.mustFlushLogRows)
 	ddb.mergeIdx.Store(uint64(time.Now().UnixNano()))
 
-	ddb.startBackgroundWorkers()
+	if !pt.s.follower {
+		// The follower storage must keep parts as is, since they are replicated from the primary storage.
+		ddb.startBackgroundWorkers()
+	}
 
 	return ddb
 }
@@ -488,7 +491,7 @@ func assertIsInMerge(pws []*partWrapper) {
 // All the parts inside pws must have isInMerge field set to true.
 // The isInMerge field inside pws parts is set to false before returning from the function.
 func (ddb *datadb) mustMergeParts(pws []*partWrapper, isFinal bool) {
-	ddb.mustMergePartsInternal(pws, isFinal, nil, ddb.stopCh)
+	ddb.mustMergePartsInternal(pws, isFinal, nil, nil, ddb.stopCh)
 }
 
 // mustMergePartsInternal merges pws to a single resulting part.
@@ -496,6 +499,9 @@ func (ddb *datadb) mustMergeParts(pws []*partWrapper, isFinal bool) {
 // if isFinal is set, then the resulting part is guaranteed to be saved to disk.
 // if isFinal is set, then the merge process cannot be interrupted.
 // if dropFilter is non-nil, then rows matching this filter are dropped during the merge.
+// if updater is non-nil, then rows matching dropFilter are updated by updater instead of dropping.
+//
+// The number of dropped rows is returned.
 //
 // The pws may remain unmerged after returning from the function in the following cases:
 // - if stopCh is closed
@@ -503,10 +509,10 @@ func (ddb *datadb) mustMergeParts(pws []*partWrapper, isFinal bool) {
 //
 // All the parts inside pws must have isInMerge field set to true.
 // The isInMerge field inside pws parts is set to false before returning from the function.
-func (ddb *datadb) mustMergePartsInternal(pws []*partWrapper, isFinal bool, dropFilter *partitionSearchOptions, stopCh <-chan struct{}) {
+func (ddb *datadb) mustMergePartsInternal(pws []*partWrapper, isFinal bool, dropFilter *partitionSearchOptions, updater *rowsUpdater, stopCh <-chan struct{}) uint64 {
 	if len(pws) == 0 {
 		// Nothing to merge.
-		return
+		return 0
 	}
 
 	assertIsInMerge(pws)
@@ -524,7 +530,7 @@ func (ddb *datadb) mustMergePartsInternal(pws []*partWrapper, isFinal bool, drop
 		} else {
 			if !isFinal {
 				// There is no enough disk space for performing the non-final merge.
-				return
+				return 0
 			}
 			// Try performing final merge even if there is no enough disk space
 			// in order to persist in-memory data to disk.
@@ -553,18 +559,21 @@ func (ddb *datadb) mustMergePartsInternal(pws []*partWrapper, isFinal bool, drop
 	mergeIdx := ddb.nextMergeIdx()
 	dstPartPath := ddb.getDstPartPath(dstPartType, mergeIdx)
 
-	if isFinal && len(pws) == 1 && pws[0].mp != nil {
+	encryptionKeys := ddb.pt.s.encryptionKeys
+	if isFinal && len(pws) == 1 && pws[0].mp != nil && encryptionKeys == nil {
 		// Fast path: flush a single in-memory part to disk.
+		//
+		// It is skipped if encryption is enabled, since in-memory parts aren't encrypted.
 		mp := pws[0].mp
 		mp.MustStoreToDisk(dstPartPath)
 		pwNew := ddb.openCreatedPart(&mp.ph, pws, nil, dstPartPath)
 		ddb.swapSrcWithDstParts(pws, pwNew, dstPartType)
 		ddb.updateMergeMetrics(dstPartType, mp.ph.RowsCount, startTime, mp.ph.CompressedSizeBytes)
-		return
+		return 0
 	}
 
 	// Prepare blockStreamReaders for source parts.
-	bsrs := mustOpenBlockStreamReaders(pws)
+	bsrs := mustOpenBlockStreamReaders(pws, encryptionKeys)
 
 	// Prepare BlockStreamWriter for destination part.
 	srcSize := uint64(0)
@@ -583,7 +592,8 @@ func (ddb *datadb) mustMergePartsInternal(pws []*partWrapper, isFinal bool, drop
 		bsw.MustInitForInmemoryPart(mpNew)
 	} else {
 		nocache := dstPartType == partBig
-		bsw.MustInitForFilePart(dstPartPath, nocache)
+		bsw.MustInitForFilePart(dstPartPath, nocache, encryptionKeys.getCurrentKey())
+		bsw.setValuesCompression(getValuesCompression(ddb.pt.s.adaptiveCompression, dstPartType))
 	}
 
 	// Merge source parts to destination part.
@@ -592,7 +602,7 @@ func (ddb *datadb) mustMergePartsInternal(pws []*partWrapper, isFinal bool, drop
 		// The final merge shouldn't be stopped even if stopCh is closed.
 		stopCh = nil
 	}
-	mustMergeBlockStreams(&ph, ddb.pt.idb, bsw, bsrs, dropFilter, stopCh)
+	mustMergeBlockStreams(&ph, ddb.pt.idb, bsw, bsrs, dropFilter, updater, stopCh)
 	putBlockStreamWriter(bsw)
 	for _, bsr := range bsrs {
 		putBlockStreamReader(bsr)
@@ -611,7 +621,7 @@ func (ddb *datadb) mustMergePartsInternal(pws []*partWrapper, isFinal bool, drop
 		if dstPartType != partInmemory {
 			fs.MustRemoveDir(dstPartPath)
 		}
-		return
+		return 0
 	}
 
 	// Atomically swap the source parts with the newly created part.
@@ -629,10 +639,11 @@ func (ddb *datadb) mustMergePartsInternal(pws []*partWrapper, isFinal bool, drop
 
 	ddb.swapSrcWithDstParts(pws, pwNew, dstPartType)
 	ddb.updateMergeMetrics(dstPartType, srcRowsCount, startTime, dstSize)
+	rowsDropped := srcRowsCount - dstRowsCount
 
 	d := time.Since(startTime)
 	if d <= time.Minute {
-		return
+		return rowsDropped
 	}
 
 	// Log stats for long merges.
@@ -640,6 +651,8 @@ func (ddb *datadb) mustMergePartsInternal(pws []*partWrapper, isFinal bool, drop
 	rowsPerSec := int(float64(srcRowsCount) / durationSecs)
 	logger.Infof("merged (%d parts, %d rows, %d blocks, %d bytes) into (1 part, %d rows, %d blocks, %d bytes) in %.3f seconds at %d rows/sec to %q",
 		len(pws), srcRowsCount, srcBlocksCount, srcSize, dstRowsCount, dstBlocksCount, dstSize, durationSecs, rowsPerSec, dstPartPath)
+
+	return rowsDropped
 }
 
 func (ddb *datadb) updateMergeMetrics(partType partType, srcRowCount uint64, startTime time.Time, dstSize uint64) {
@@ -1107,14 +1120,14 @@ func removeParts(pws []*partWrapper, partsToRemove map[*partWrapper]struct{}) ([
 	return dst, len(pws) - len(dst)
 }
 
-func mustOpenBlockStreamReaders(pws []*partWrapper) []*blockStreamReader {
+func mustOpenBlockStreamReaders(pws []*partWrapper, ek *EncryptionKeys) []*blockStreamReader {
 	bsrs := make([]*blockStreamReader, 0, len(pws))
 	for _, pw := range pws {
 		bsr := getBlockStreamReader()
 		if pw.mp != nil {
 			bsr.MustInitFromInmemoryPart(pw.mp)
 		} else {
-			bsr.MustInitFromFilePart(pw.p.path)
+			bsr.MustInitFromFilePart(pw.p.path, ek)
 		}
 		bsrs = append(bsrs, bsr)
 	}
@@ -1319,7 +1332,7 @@ func mustReadPartNames(path string) []string {
 		if os.IsNotExist(err) {
 			// The parts.json file is missing. This can happen if VictoriaLogs shuts down uncleanly
 			// (via OOM crash, a panic, SIGKILL or hardware shutdown) in the middle of creating
-			// new per-day partition inside the mustCreatePartition() function.
+			// new partition inside the mustCreatePartition() function.
 			// Check if there are any part directories in the datadb directory.
 			des := fs.MustReadDir(path)
 			var partDirs []string
@@ -1526,15 +1539,31 @@ func (ddb *datadb) mustForceMergeAllParts() {
 	putWaitGroup(wg)
 }
 
-func (ddb *datadb) deleteRows(pso *partitionSearchOptions, stopCh <-chan struct{}) bool {
+func (ddb *datadb) deleteRows(pso *partitionSearchOptions, dt *DeleteTask, stopCh <-chan struct{}) bool {
 	// Get all the parts and make sure they are kept open.
 	pws, pwsDecRef := ddb.getPartsForTimeRange(pso.minTimestamp, pso.maxTimestamp)
 	defer pwsDecRef()
 
+	if dt.DryRun {
+		// Count rows matching pso without deleting or updating them.
+		for _, pw := range pws {
+			rowsCount := pw.p.countMatchingRows(pso, 0, stopCh)
+			if dt.updater != nil {
+				dt.progress.rowsUpdated.Add(rowsCount)
+			} else {
+				dt.progress.rowsDeleted.Add(rowsCount)
+			}
+			dt.progress.partsProcessed.Add(1)
+		}
+		return !needStop(stopCh)
+	}
+
 	// Search for parts, which contain logs matching pso for the deletion and which aren't in merge at the moment.
 	var pwsToMerge []*partWrapper
 	for _, pw := range pws {
-		if !pw.p.hasMatchingRows(pso, stopCh) {
+		hasMatchingRows := pw.p.countMatchingRows(pso, 1, stopCh) > 0
+		dt.progress.partsProcessed.Add(1)
+		if !hasMatchingRows {
 			continue
 		}
 
@@ -1552,10 +1581,28 @@ func (ddb *datadb) deleteRows(pso *partitionSearchOptions, stopCh <-chan struct{
 		}
 	}
 
-	// merge pwsToMerge while dropping logs matching pso.
-	ddb.mustMergePartsInternal(pwsToMerge, false, pso, stopCh)
+	if len(pwsToMerge) == 0 {
+		// Parts could be skipped because stopCh is closed.
+		return !needStop(stopCh)
+	}
 
-	return !needStop(stopCh)
+	// merge pwsToMerge while dropping or updating logs matching pso.
+	var rowsUpdatedPrev uint64
+	if dt.updater != nil {
+		rowsUpdatedPrev = dt.updater.rowsUpdated.Load()
+	}
+	rowsDropped := ddb.mustMergePartsInternal(pwsToMerge, false, pso, dt.updater, stopCh)
+	if !pwsToMerge[0].mustDrop.Load() {
+		// The merge has been stopped, so the source parts are left untouched.
+		return false
+	}
+	dt.progress.rowsDeleted.Add(rowsDropped)
+	if dt.updater != nil {
+		dt.progress.rowsUpdated.Add(dt.updater.rowsUpdated.Load() - rowsUpdatedPrev)
+	}
+
+	// Return true even if stopCh is closed after the merge, since the merged parts mustn't be processed again.
+	return true
 }
 
 func appendAllPartsForMergeLocked(dst, src []*partWrapper) []*partWrapper {
@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"os"
+	"sort"
+	"sync/atomic"
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
@@ -11,6 +13,8 @@ import (
 )
 
 // DeleteTask describes a task for logs' deletion.
+//
+// If Update is set, then the task updates the logs matching the Filter instead of deleting them.
 type DeleteTask struct {
 	// TaskID is the id of the task
 	TaskID string `json:"task_id"`
@@ -24,6 +28,39 @@ type DeleteTask struct {
 	// StartTime is the time when the task has been created
 	StartTime time.Time `json:"start_time"`
 
+	// Update contains LogsQL pipes for updating logs matching the Filter. See ParseRowsUpdate for details.
+	//
+	// Logs matching the Filter are deleted if Update is empty.
+	Update string `json:"update,omitempty"`
+
+	// DryRun is set to true if the task must only count logs matching the Filter without deleting or updating them.
+	DryRun bool `json:"dry_run,omitempty"`
+
+	// Status is the task status. See DeleteTaskStatus* constants for possible values.
+	Status string `json:"status"`
+
+	// Progress contains the task progress.
+	//
+	// It is populated for tasks returned from Storage.DeleteActiveTasks() and Storage.DeleteFinishedTasks().
+	Progress DeleteTaskProgress `json:"progress"`
+
+	// EndTime is the time when the task has been finished. It is set only for finished tasks.
+	EndTime *time.Time `json:"end_time,omitempty"`
+
+	// Error contains the error for failed tasks.
+	Error string `json:"error,omitempty"`
+
+	// FinishedPartitions contains the names of partitions, which are already processed by the task.
+	//
+	// These partitions are skipped when the task is retried, since Update may contain non-idempotent pipes such as format or copy.
+	FinishedPartitions []string `json:"finished_partitions,omitempty"`
+
+	// progress is updated during the task execution.
+	progress deleteTaskProgress
+
+	// updater is set to non-nil during execution of the task with non-empty Update.
+	updater *rowsUpdater
+
 	// ctx is set to non-nil during task execution. Pending tasks have nil ctx.
 	ctx context.Context
 
@@ -43,12 +80,209 @@ func (dt *DeleteTask) String() string {
 	return string(data)
 }
 
-func newDeleteTask(taskID string, tenantIDs []TenantID, filter string, startTime int64) *DeleteTask {
+// Possible values for DeleteTask.Status.
+const (
+	// DeleteTaskStatusPending is the status for the task waiting for the execution.
+	DeleteTaskStatusPending = "pending"
+
+	// DeleteTaskStatusRunning is the status for the task being executed.
+	DeleteTaskStatusRunning = "running"
+
+	// DeleteTaskStatusCompleted is the status for the successfully completed task.
+	DeleteTaskStatusCompleted = "completed"
+
+	// DeleteTaskStatusFailed is the status for the task, which couldn't be completed because of an error.
+	DeleteTaskStatusFailed = "failed"
+
+	// DeleteTaskStatusCanceled is the status for the task canceled via Storage.DeleteStopTask().
+	DeleteTaskStatusCanceled = "canceled"
+)
+
+// DeleteTaskProgress contains progress for the DeleteTask.
+type DeleteTaskProgress struct {
+	// PartitionsTotal is the number of partitions, which must be processed by the task.
+	PartitionsTotal uint64 `json:"partitions_total"`
+
+	// PartitionsProcessed is the number of already processed partitions.
+	PartitionsProcessed uint64 `json:"partitions_processed"`
+
+	// PartsTotal is the number of parts, which must be processed by the task.
+	PartsTotal uint64 `json:"parts_total"`
+
+	// PartsProcessed is the number of already processed parts.
+	PartsProcessed uint64 `json:"parts_processed"`
+
+	// RowsDeleted is the number of deleted rows.
+	//
+	// It contains the number of rows matching the task filter if the task is executed in dry-run mode.
+	RowsDeleted uint64 `json:"rows_deleted"`
+
+	// RowsUpdated is the number of updated rows for the task with non-empty Update.
+	//
+	// It contains the number of rows matching the task filter if the task is executed in dry-run mode.
+	RowsUpdated uint64 `json:"rows_updated"`
+}
+
+func (dtp *DeleteTaskProgress) add(src *DeleteTaskProgress) {
+	dtp.PartitionsTotal += src.PartitionsTotal
+	dtp.PartitionsProcessed += src.PartitionsProcessed
+	dtp.PartsTotal += src.PartsTotal
+	dtp.PartsProcessed += src.PartsProcessed
+	dtp.RowsDeleted += src.RowsDeleted
+	dtp.RowsUpdated += src.RowsUpdated
+}
+
+// deleteTaskProgress is updated concurrently during the DeleteTask execution.
+type deleteTaskProgress struct {
+	partitionsTotal     atomic.Uint64
+	partitionsProcessed atomic.Uint64
+	partsTotal          atomic.Uint64
+	partsProcessed      atomic.Uint64
+	rowsDeleted         atomic.Uint64
+	rowsUpdated         atomic.Uint64
+}
+
+func (dtp *deleteTaskProgress) get() DeleteTaskProgress {
+	return DeleteTaskProgress{
+		PartitionsTotal:     dtp.partitionsTotal.Load(),
+		PartitionsProcessed: dtp.partitionsProcessed.Load(),
+		PartsTotal:          dtp.partsTotal.Load(),
+		PartsProcessed:      dtp.partsProcessed.Load(),
+		RowsDeleted:         dtp.rowsDeleted.Load(),
+		RowsUpdated:         dtp.rowsUpdated.Load(),
+	}
+}
+
+// resetForAttempt resets the progress counters, which are re-calculated on every attempt to execute the task.
+//
+// rowsDeleted and rowsUpdated are preserved across attempts, since the partitions processed at the previous attempts are skipped at the next attempts.
+// All the counters are reset if dryRun is set, since the matching rows are re-counted on every attempt in dry-run mode.
+func (dtp *deleteTaskProgress) resetForAttempt(dryRun bool) {
+	dtp.partitionsTotal.Store(0)
+	dtp.partitionsProcessed.Store(0)
+	dtp.partsTotal.Store(0)
+	dtp.partsProcessed.Store(0)
+	if dryRun {
+		dtp.rowsDeleted.Store(0)
+		dtp.rowsUpdated.Store(0)
+	}
+}
+
+// restore restores the progress counters, which must be preserved across attempts, from src.
+func (dtp *deleteTaskProgress) restore(src *DeleteTaskProgress) {
+	dtp.rowsDeleted.Store(src.RowsDeleted)
+	dtp.rowsUpdated.Store(src.RowsUpdated)
+}
+
+func newDeleteTask(taskID string, tenantIDs []TenantID, filter, update string, startTime int64, dryRun bool) *DeleteTask {
 	return &DeleteTask{
 		TaskID:    taskID,
 		TenantIDs: tenantIDs,
 		Filter:    filter,
 		StartTime: time.Unix(0, startTime).UTC(),
+		Update:    update,
+		DryRun:    dryRun,
+		Status:    DeleteTaskStatusPending,
+	}
+}
+
+// clone returns a copy of dt with the Progress obtained from dt.progress for unfinished tasks.
+func (dt *DeleteTask) clone() *DeleteTask {
+	dtCopy := &DeleteTask{
+		TaskID:    dt.TaskID,
+		TenantIDs: dt.TenantIDs,
+		Filter:    dt.Filter,
+		StartTime: dt.StartTime,
+		Update:    dt.Update,
+		DryRun:    dt.DryRun,
+		Status:    dt.Status,
+		Progress:  dt.Progress,
+		EndTime:   dt.EndTime,
+		Error:     dt.Error,
+	}
+	if dt.EndTime == nil {
+		dtCopy.Progress = dt.progress.get()
+	}
+	return dtCopy
+}
+
+// finish marks dt as finished with the given status and err.
+func (dt *DeleteTask) finish(status string, err error) {
+	endTime := time.Now().UTC()
+	dt.EndTime = &endTime
+	dt.Status = status
+	if err != nil {
+		dt.Error = err.Error()
+	}
+
+	// Persist the progress for the finished task, so it survives restarts.
+	dt.Progress = dt.progress.get()
+}
+
+// MergeDeleteTasks merges delete tasks with the same TaskID obtained from multiple storage nodes.
+//
+// Progress is summed across the merged tasks. Status is set to the most important status across the merged tasks
+// in the following order: running, pending, failed, canceled, completed.
+func MergeDeleteTasks(tasksPerNode [][]*DeleteTask) []*DeleteTask {
+	m := make(map[string]*DeleteTask)
+	for _, tasks := range tasksPerNode {
+		for _, dt := range tasks {
+			dst := m[dt.TaskID]
+			if dst == nil {
+				dst = &DeleteTask{
+					TaskID:    dt.TaskID,
+					TenantIDs: dt.TenantIDs,
+					Filter:    dt.Filter,
+					StartTime: dt.StartTime,
+					Update:    dt.Update,
+					DryRun:    dt.DryRun,
+					Status:    dt.Status,
+				}
+				m[dt.TaskID] = dst
+			}
+			dst.Progress.add(&dt.Progress)
+			if getDeleteTaskStatusPriority(dt.Status) < getDeleteTaskStatusPriority(dst.Status) {
+				dst.Status = dt.Status
+			}
+			if dt.EndTime != nil && (dst.EndTime == nil || dt.EndTime.After(*dst.EndTime)) {
+				dst.EndTime = dt.EndTime
+			}
+			if dst.Error == "" {
+				dst.Error = dt.Error
+			}
+		}
+	}
+
+	tasks := make([]*DeleteTask, 0, len(m))
+	for _, dt := range m {
+		if getDeleteTaskStatusPriority(dt.Status) <= getDeleteTaskStatusPriority(DeleteTaskStatusPending) {
+			// The task is still executed at some storage nodes.
+			dt.EndTime = nil
+		}
+		tasks = append(tasks, dt)
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		a, b := tasks[i], tasks[j]
+		if !a.StartTime.Equal(b.StartTime) {
+			return a.StartTime.Before(b.StartTime)
+		}
+		return a.TaskID < b.TaskID
+	})
+	return tasks
+}
+
+func getDeleteTaskStatusPriority(status string) int {
+	switch status {
+	case DeleteTaskStatusRunning:
+		return 0
+	case DeleteTaskStatusPending:
+		return 1
+	case DeleteTaskStatusFailed:
+		return 2
+	case DeleteTaskStatusCanceled:
+		return 3
+	default:
+		return 4
 	}
 }
 
@@ -6,6 +6,7 @@ import (
 )
 
 func TestDeleteTaskMarshalUnmarshalAsJSON(t *testing.T) {
+	endTime := time.Now().UTC()
 	dts := []*DeleteTask{
 		{
 			TaskID: "task1",
@@ -21,6 +22,10 @@ func TestDeleteTaskMarshalUnmarshalAsJSON(t *testing.T) {
 			},
 			Filter:    "app:=foo",
 			StartTime: time.Now(),
+			Status:    DeleteTaskStatusPending,
+			Progress: DeleteTaskProgress{
+				RowsDeleted: 123,
+			},
 		},
 		{
 			TaskID: "task_2",
@@ -32,6 +37,11 @@ func TestDeleteTaskMarshalUnmarshalAsJSON(t *testing.T) {
 			},
 			Filter:    "app:=x",
 			StartTime: time.Now(),
+			Update:    "format 'foo' as bar",
+			DryRun:    true,
+			Status:    DeleteTaskStatusFailed,
+			EndTime:   &endTime,
+			Error:     "some error",
 		},
 	}
 
@@ -46,3 +56,80 @@ func TestDeleteTaskMarshalUnmarshalAsJSON(t *testing.T) {
 		t.Fatalf("unexpected delete_task unmarshaled\ngot %s\nwant %s", data2, data)
 	}
 }
+
+func TestMergeDeleteTasks(t *testing.T) {
+	f := func(tasksPerNode []string, resultExpected string) {
+		t.Helper()
+
+		var tasks [][]*DeleteTask
+		for _, data := range tasksPerNode {
+			dts, err := UnmarshalDeleteTasksFromJSON([]byte(data))
+			if err != nil {
+				t.Fatalf("cannot unmarshal tasks: %s", err)
+			}
+			tasks = append(tasks, dts)
+		}
+		result := MarshalDeleteTasksToJSON(MergeDeleteTasks(tasks))
+		if string(result) != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	// empty tasks
+	f(nil, `[]`)
+	f([]string{`[]`, `[]`}, `[]`)
+
+	// a single node
+	f([]string{`[{"task_id":"a","filter":"foo","start_time":"2025-01-01T00:00:00Z","status":"running","progress":{"partitions_total":2,"rows_deleted":5,"rows_updated":0}}]`},
+		`[{"task_id":"a","tenant_ids":null,"filter":"foo","start_time":"2025-01-01T00:00:00Z","status":"running",`+
+			`"progress":{"partitions_total":2,"partitions_processed":0,"parts_total":0,"parts_processed":0,"rows_deleted":5,"rows_updated":0}}]`)
+
+	// the task is still running at one of the nodes
+	f([]string{
+		`[{"task_id":"a","filter":"foo","start_time":"2025-01-01T00:00:00Z","status":"completed","end_time":"2025-01-01T00:01:00Z","progress":{"parts_total":3,"parts_processed":3,"rows_deleted":5,"rows_updated":0}}]`,
+		`[{"task_id":"a","filter":"foo","start_time":"2025-01-01T00:00:00Z","status":"running","progress":{"parts_total":4,"parts_processed":1,"rows_deleted":2}}]`,
+	}, `[{"task_id":"a","tenant_ids":null,"filter":"foo","start_time":"2025-01-01T00:00:00Z","status":"running",`+
+		`"progress":{"partitions_total":0,"partitions_processed":0,"parts_total":7,"parts_processed":4,"rows_deleted":7,"rows_updated":0}}]`)
+
+	// finished tasks are sorted by start time
+	f([]string{
+		`[{"task_id":"b","filter":"bar","start_time":"2025-01-02T00:00:00Z","status":"completed","end_time":"2025-01-02T00:01:00Z"},` +
+			`{"task_id":"a","filter":"foo","start_time":"2025-01-01T00:00:00Z","status":"completed","end_time":"2025-01-01T00:01:00Z"}]`,
+		`[{"task_id":"a","filter":"foo","start_time":"2025-01-01T00:00:00Z","status":"failed","end_time":"2025-01-01T00:02:00Z","error":"some error"}]`,
+	}, `[{"task_id":"a","tenant_ids":null,"filter":"foo","start_time":"2025-01-01T00:00:00Z","status":"failed",`+
+		`"progress":{"partitions_total":0,"partitions_processed":0,"parts_total":0,"parts_processed":0,"rows_deleted":0,"rows_updated":0},"end_time":"2025-01-01T00:02:00Z","error":"some error"},`+
+		`{"task_id":"b","tenant_ids":null,"filter":"bar","start_time":"2025-01-02T00:00:00Z","status":"completed",`+
+		`"progress":{"partitions_total":0,"partitions_processed":0,"parts_total":0,"parts_processed":0,"rows_deleted":0,"rows_updated":0},"end_time":"2025-01-02T00:01:00Z"}]`)
+}
+
+func TestDeleteTaskProgressResetForAttempt(t *testing.T) {
+	f := func(dryRun bool, rowsDeletedExpected uint64) {
+		t.Helper()
+
+		var dtp deleteTaskProgress
+		dtp.restore(&DeleteTaskProgress{
+			RowsDeleted: 10,
+		})
+		dtp.partitionsTotal.Store(2)
+		dtp.partitionsProcessed.Store(1)
+		dtp.partsTotal.Store(5)
+		dtp.partsProcessed.Store(3)
+		dtp.rowsDeleted.Add(5)
+
+		dtp.resetForAttempt(dryRun)
+
+		progress := dtp.get()
+		progressExpected := DeleteTaskProgress{
+			RowsDeleted: rowsDeletedExpected,
+		}
+		if progress != progressExpected {
+			t.Fatalf("unexpected progress\ngot\n%#v\nwant\n%#v", progress, progressExpected)
+		}
+	}
+
+	// rows deleted at the previous attempts must be preserved
+	f(false, 15)
+
+	// matching rows are re-counted on every attempt in dry-run mode
+	f(true, 0)
+}
@@ -14,6 +14,13 @@ import (
 //
 // The marshaled strings block can be unmarshaled with stringsBlockUnmarshaler.
 func marshalStringsBlock(dst []byte, a []string) []byte {
+	return marshalStringsBlockLevel(dst, a, 0)
+}
+
+// marshalStringsBlockLevel marshals a and appends the result to dst.
+//
+// The strings are compressed with at least minCompressLevel zstd level.
+func marshalStringsBlockLevel(dst []byte, a []string, minCompressLevel int) []byte {
 	// Encode string lengths
 	u64s := encoding.GetUint64s(len(a))
 	aLens := u64s.A
@@ -28,7 +35,7 @@ func marshalStringsBlock(dst []byte, a []string) []byte {
 	// Encode strings
 	if areConstValues(a) {
 		// Special case for const values
-		dst = marshalBytesBlock(dst, bytesutil.ToUnsafeBytes(a[0]))
+		dst = marshalBytesBlockLevel(dst, bytesutil.ToUnsafeBytes(a[0]), minCompressLevel)
 	} else {
 		// Regular case for non-const values
 		bb := bbPool.Get()
@@ -40,7 +47,7 @@ func marshalStringsBlock(dst []byte, a []string) []byte {
 		for _, s := range a {
 			b = append(b, s...)
 		}
-		dst = marshalBytesBlock(dst, b)
+		dst = marshalBytesBlockLevel(dst, b, minCompressLevel)
 
 		bb.B = b
 		bbPool.Put(bb)
@@ -341,6 +348,13 @@ const (
 )
 
 func marshalBytesBlock(dst, src []byte) []byte {
+	return marshalBytesBlockLevel(dst, src, 0)
+}
+
+// marshalBytesBlockLevel appends marshaled src to dst and returns the result.
+//
+// src is compressed with at least minCompressLevel zstd level if it is big enough.
+func marshalBytesBlockLevel(dst, src []byte, minCompressLevel int) []byte {
 	if len(src) < 128 {
 		// Marshal the block in plain without compression
 		dst = append(dst, marshalBytesTypePlain)
@@ -350,7 +364,7 @@ func marshalBytesBlock(dst, src []byte) []byte {
 
 	// Compress the block
 	dst = append(dst, marshalBytesTypeZSTD)
-	compressLevel := getCompressLevel(len(src))
+	compressLevel := max(getCompressLevel(len(src)), minCompressLevel)
 	bb := bbPool.Get()
 	bb.B = encoding.CompressZSTDLevel(bb.B[:0], src, compressLevel)
 	dst = encoding.MarshalVarUint64(dst, uint64(len(bb.B)))
@@ -0,0 +1,256 @@
+package logstorage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// encryptionOverhead is the number of bytes added to every encrypted block.
+//
+// Every encrypted block consists of random nonce followed by the AES-GCM ciphertext with the authentication tag.
+const encryptionOverhead = encryptionNonceSize + encryptionTagSize
+
+const (
+	encryptionNonceSize = 12
+	encryptionTagSize   = 16
+)
+
+// EncryptionKeys contains keys for encryption at rest of the data stored in parts.
+//
+// New parts are encrypted with the current key, while the remaining keys are used for decrypting
+// the parts created before key rotation. Such parts are re-encrypted with the current key during background merges.
+type EncryptionKeys struct {
+	current *encryptionKey
+	keys    map[string]*encryptionKey
+}
+
+type encryptionKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// encryptionKeysConfig is the config for ParseEncryptionKeys.
+type encryptionKeysConfig struct {
+	// CurrentKeyID is the id of the key for encrypting new parts. The last key from Keys is used if it is empty.
+	CurrentKeyID string `json:"current_key_id,omitempty"`
+
+	// Keys contains all the keys, which may be needed for decrypting the stored parts.
+	Keys []encryptionKeyConfig `json:"keys"`
+}
+
+type encryptionKeyConfig struct {
+	// ID is the unique key id. It is stored in the metadata of parts encrypted with the key.
+	ID string `json:"id"`
+
+	// Key is base64-encoded 32-byte key for AES-256-GCM.
+	Key string `json:"key"`
+}
+
+// ParseEncryptionKeys parses encryption keys from data.
+//
+// data must contain JSON in the form {"current_key_id":"...","keys":[{"id":"...","key":"<base64-encoded 32 bytes>"},...]}
+func ParseEncryptionKeys(data []byte) (*EncryptionKeys, error) {
+	var cfg encryptionKeysConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Keys) == 0 {
+		return nil, fmt.Errorf("keys cannot be empty")
+	}
+
+	ek := &EncryptionKeys{
+		keys: make(map[string]*encryptionKey, len(cfg.Keys)),
+	}
+	for _, kc := range cfg.Keys {
+		if kc.ID == "" {
+			return nil, fmt.Errorf("key id cannot be empty")
+		}
+		if _, ok := ek.keys[kc.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", kc.ID)
+		}
+		key, err := base64.StdEncoding.DecodeString(kc.Key)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode base64-encoded key with id %q: %w", kc.ID, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("unexpected length for the key with id %q; got %d bytes; want 32 bytes", kc.ID, len(key))
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("cannot initialize AES cipher for the key with id %q: %w", kc.ID, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("cannot initialize AES-GCM for the key with id %q: %w", kc.ID, err)
+		}
+		ek.keys[kc.ID] = &encryptionKey{
+			id:   kc.ID,
+			aead: aead,
+		}
+	}
+
+	currentKeyID := cfg.CurrentKeyID
+	if currentKeyID == "" {
+		currentKeyID = cfg.Keys[len(cfg.Keys)-1].ID
+	}
+	ek.current = ek.keys[currentKeyID]
+	if ek.current == nil {
+		return nil, fmt.Errorf("missing key for current_key_id=%q", currentKeyID)
+	}
+
+	return ek, nil
+}
+
+// CurrentKeyID returns the id of the key used for encrypting new parts.
+func (ek *EncryptionKeys) CurrentKeyID() string {
+	return ek.current.id
+}
+
+// getCurrentKey returns the key for encrypting new parts.
+//
+// nil is returned if ek is nil, e.g. if encryption is disabled.
+func (ek *EncryptionKeys) getCurrentKey() *encryptionKey {
+	if ek == nil {
+		return nil
+	}
+	return ek.current
+}
+
+// mustGetKeyForPart returns the key for decrypting the part with the given ph located at partPath.
+//
+// nil is returned if the part isn't encrypted.
+func (ek *EncryptionKeys) mustGetKeyForPart(ph *partHeader, partPath string) *encryptionKey {
+	key, err := ek.getKeyForPart(ph, partPath)
+	if err != nil {
+		logger.Panicf("FATAL: %s", err)
+	}
+	return key
+}
+
+// getKeyForPart works the same as mustGetKeyForPart, but returns an error instead of panicking if the key is missing.
+func (ek *EncryptionKeys) getKeyForPart(ph *partHeader, partPath string) (*encryptionKey, error) {
+	if ph.EncryptionKeyID == "" {
+		return nil, nil
+	}
+	if ek == nil {
+		return nil, fmt.Errorf("%s: the part is encrypted with the key %q, while encryption keys aren't configured", partPath, ph.EncryptionKeyID)
+	}
+	key := ek.keys[ph.EncryptionKeyID]
+	if key == nil {
+		return nil, fmt.Errorf("%s: missing encryption key %q for the part; make sure it isn't removed from encryption keys", partPath, ph.EncryptionKeyID)
+	}
+	return key, nil
+}
+
+// encrypt appends the encrypted src to dst and returns the result.
+//
+// Empty src is stored as is, since it contains no data.
+func (key *encryptionKey) encrypt(dst, src []byte) []byte {
+	if len(src) == 0 {
+		return dst
+	}
+
+	dstLen := len(dst)
+	dst = append(dst, make([]byte, encryptionNonceSize)...)
+	nonce := dst[dstLen:]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		logger.Panicf("FATAL: cannot generate nonce for encryption: %s", err)
+	}
+	return key.aead.Seal(dst, nonce, src, nil)
+}
+
+// decrypt appends the decrypted src to dst and returns the result.
+func (key *encryptionKey) decrypt(dst, src []byte) ([]byte, error) {
+	if len(src) == 0 {
+		return dst, nil
+	}
+	if len(src) < encryptionOverhead {
+		return dst, fmt.Errorf("too short encrypted data; got %d bytes; want at least %d bytes", len(src), encryptionOverhead)
+	}
+	nonce := src[:encryptionNonceSize]
+	dst, err := key.aead.Open(dst, nonce, src[encryptionNonceSize:], nil)
+	if err != nil {
+		return dst, fmt.Errorf("cannot decrypt data with the key %q: %w", key.id, err)
+	}
+	return dst, nil
+}
+
+// getEncryptedSize returns the size of the encrypted block for the plaintext block of the given size.
+func getEncryptedSize(size int) int {
+	if size == 0 {
+		return 0
+	}
+	return size + encryptionOverhead
+}
+
+// encryptedReaderAt decrypts blocks read from r with the key.
+//
+// Every MustReadAt call must read the whole encrypted block at the offset registered in the corresponding header,
+// while len(p) must equal the plaintext block size.
+type encryptedReaderAt struct {
+	r   fs.MustReadAtCloser
+	key *encryptionKey
+}
+
+func newEncryptedReaderAt(r fs.MustReadAtCloser, key *encryptionKey) fs.MustReadAtCloser {
+	if key == nil {
+		return r
+	}
+	return &encryptedReaderAt{
+		r:   r,
+		key: key,
+	}
+}
+
+// Path returns path to r.
+func (r *encryptedReaderAt) Path() string {
+	return r.r.Path()
+}
+
+// MustReadAt reads the encrypted block at off from r and puts the decrypted block into p.
+func (r *encryptedReaderAt) MustReadAt(p []byte, off int64) {
+	if len(p) == 0 {
+		return
+	}
+
+	bb := encryptedBufPool.Get()
+	bb.B = bytesutil.ResizeNoCopyMayOverallocate(bb.B, getEncryptedSize(len(p)))
+	r.r.MustReadAt(bb.B, off)
+	mustDecryptBlock(p, bb.B, r.key, r.r.Path())
+	encryptedBufPool.Put(bb)
+}
+
+// MustClose closes r.
+func (r *encryptedReaderAt) MustClose() {
+	r.r.MustClose()
+}
+
+// mustDecryptBlock decrypts src into dst, which must have the size of the plaintext block.
+func mustDecryptBlock(dst, src []byte, key *encryptionKey, path string) {
+	if err := decryptBlock(dst, src, key, path); err != nil {
+		logger.Panicf("FATAL: %s", err)
+	}
+}
+
+// decryptBlock works the same as mustDecryptBlock, but returns an error instead of panicking on decryption failure.
+func decryptBlock(dst, src []byte, key *encryptionKey, path string) error {
+	data, err := key.decrypt(dst[:0], src)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if len(data) != len(dst) {
+		return fmt.Errorf("%s: unexpected decrypted block size; got %d bytes; want %d bytes", path, len(data), len(dst))
+	}
+	return nil
+}
+
+var encryptedBufPool bytesutil.ByteBufferPool
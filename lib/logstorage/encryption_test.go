@@ -0,0 +1,247 @@
+package logstorage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	vmfs "github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+)
+
+func TestParseEncryptionKeysSuccess(t *testing.T) {
+	f := func(data, currentKeyIDExpected string) {
+		t.Helper()
+
+		ek, err := ParseEncryptionKeys([]byte(data))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if id := ek.CurrentKeyID(); id != currentKeyIDExpected {
+			t.Fatalf("unexpected current key id; got %q; want %q", id, currentKeyIDExpected)
+		}
+	}
+
+	k1 := newTestEncryptionKey(1)
+	k2 := newTestEncryptionKey(2)
+
+	f(`{"keys":[{"id":"k1","key":"`+k1+`"}]}`, "k1")
+	f(`{"keys":[{"id":"k1","key":"`+k1+`"},{"id":"k2","key":"`+k2+`"}]}`, "k2")
+	f(`{"current_key_id":"k1","keys":[{"id":"k1","key":"`+k1+`"},{"id":"k2","key":"`+k2+`"}]}`, "k1")
+}
+
+func TestParseEncryptionKeysFailure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+
+		if _, err := ParseEncryptionKeys([]byte(data)); err == nil {
+			t.Fatalf("expecting non-nil error for %s", data)
+		}
+	}
+
+	k1 := newTestEncryptionKey(1)
+
+	// invalid JSON
+	f(`foo`)
+
+	// missing keys
+	f(`{}`)
+	f(`{"keys":[]}`)
+
+	// missing key id
+	f(`{"keys":[{"key":"` + k1 + `"}]}`)
+
+	// duplicate key id
+	f(`{"keys":[{"id":"k1","key":"` + k1 + `"},{"id":"k1","key":"` + k1 + `"}]}`)
+
+	// invalid base64
+	f(`{"keys":[{"id":"k1","key":"foo!"}]}`)
+
+	// invalid key length
+	f(`{"keys":[{"id":"k1","key":"` + base64.StdEncoding.EncodeToString([]byte("short")) + `"}]}`)
+
+	// missing current key
+	f(`{"current_key_id":"k2","keys":[{"id":"k1","key":"` + k1 + `"}]}`)
+}
+
+func TestEncryptionKeyEncryptDecrypt(t *testing.T) {
+	ek, err := ParseEncryptionKeys([]byte(`{"keys":[{"id":"k1","key":"` + newTestEncryptionKey(1) + `"}]}`))
+	if err != nil {
+		t.Fatalf("cannot parse keys: %s", err)
+	}
+	key := ek.getCurrentKey()
+
+	f := func(data string) {
+		t.Helper()
+
+		encrypted := key.encrypt(nil, []byte(data))
+		if len(encrypted) != getEncryptedSize(len(data)) {
+			t.Fatalf("unexpected encrypted size; got %d; want %d", len(encrypted), getEncryptedSize(len(data)))
+		}
+		// Short data may occur in the random encrypted output by chance, so check only long enough data.
+		if len(data) >= 8 && bytes.Contains(encrypted, []byte(data)) {
+			t.Fatalf("encrypted data mustn't contain the original data")
+		}
+		decrypted, err := key.decrypt(nil, encrypted)
+		if err != nil {
+			t.Fatalf("cannot decrypt data: %s", err)
+		}
+		if string(decrypted) != data {
+			t.Fatalf("unexpected decrypted data; got %q; want %q", decrypted, data)
+		}
+
+		if len(encrypted) > 0 {
+			// Corrupted data must be detected
+			encrypted[len(encrypted)-1]++
+			if _, err := key.decrypt(nil, encrypted); err == nil {
+				t.Fatalf("expecting non-nil error when decrypting corrupted data")
+			}
+		}
+	}
+
+	f("")
+	f("a")
+	f("some log message with enough length to span multiple AES blocks")
+}
+
+func TestStorageEncryption(t *testing.T) {
+	t.Parallel()
+
+	path := t.Name()
+
+	k1 := newTestEncryptionKey(1)
+	k2 := newTestEncryptionKey(2)
+
+	mustParseKeys := func(data string) *EncryptionKeys {
+		t.Helper()
+
+		ek, err := ParseEncryptionKeys([]byte(data))
+		if err != nil {
+			t.Fatalf("cannot parse keys: %s", err)
+		}
+		return ek
+	}
+
+	tenantIDs := []TenantID{{}}
+	checkRows := func(s *Storage) {
+		t.Helper()
+
+		checkQueryResults(t, s, tenantIDs, "* | count() rows", []string{`{"rows":"100"}`})
+		checkQueryResults(t, s, tenantIDs, "secret_42 | fields _msg, host", []string{`{"_msg":"secret_42 message","host":"host-2"}`})
+	}
+
+	// Store rows encrypted with k1
+	cfg := &StorageConfig{
+		Retention:      30 * 24 * time.Hour,
+		EncryptionKeys: mustParseKeys(`{"keys":[{"id":"k1","key":"` + k1 + `"}]}`),
+	}
+	s := MustOpenStorage(path, cfg)
+
+	lr := GetLogRows([]string{"host"}, nil, nil, nil, "")
+	now := time.Now().UnixNano()
+	for i := 0; i < 100; i++ {
+		fields := []Field{
+			{
+				Name:  "host",
+				Value: fmt.Sprintf("host-%d", i%10),
+			},
+			{
+				Name:  "_msg",
+				Value: fmt.Sprintf("secret_%d message", i),
+			},
+		}
+		lr.MustAdd(TenantID{}, now+int64(i), fields, nil)
+	}
+	s.MustAddRows(lr)
+	PutLogRows(lr)
+	s.DebugFlush()
+	checkRows(s)
+	s.MustClose()
+
+	checkPartsEncryptionKeyIDs(t, path, "k1")
+
+	// Re-open the storage with the rotated key. The existing parts must remain readable.
+	cfg.EncryptionKeys = mustParseKeys(`{"current_key_id":"k2","keys":[{"id":"k1","key":"` + k1 + `"},{"id":"k2","key":"` + k2 + `"}]}`)
+	s = MustOpenStorage(path, cfg)
+	checkRows(s)
+
+	// Forced merge must re-encrypt the existing parts with the current key.
+	s.MustForceMerge("")
+	checkRows(s)
+	s.MustClose()
+
+	checkPartsEncryptionKeyIDs(t, path, "k2")
+
+	// The old key isn't needed after re-encryption.
+	cfg.EncryptionKeys = mustParseKeys(`{"keys":[{"id":"k2","key":"` + k2 + `"}]}`)
+	s = MustOpenStorage(path, cfg)
+	checkRows(s)
+	s.MustClose()
+
+	vmfs.MustRemoveDir(path)
+}
+
+// checkPartsEncryptionKeyIDs verifies that all the parts at the storage path are encrypted with the key with the given keyIDExpected.
+//
+// It also verifies that the part files do not contain the plaintext log messages.
+func checkPartsEncryptionKeyIDs(t *testing.T, path, keyIDExpected string) {
+	t.Helper()
+
+	partitionsPath := filepath.Join(path, partitionsDirname)
+	var partPaths []string
+	err := filepath.WalkDir(partitionsPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != metadataFilename {
+			return nil
+		}
+		dir := filepath.Dir(p)
+		if strings.Contains(dir, string(filepath.Separator)+datadbDirname+string(filepath.Separator)) {
+			partPaths = append(partPaths, dir)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("cannot read parts at %q: %s", partitionsPath, err)
+	}
+	if len(partPaths) == 0 {
+		t.Fatalf("missing parts at %q", partitionsPath)
+	}
+
+	for _, partPath := range partPaths {
+		var ph partHeader
+		ph.mustReadMetadata(partPath)
+		if ph.EncryptionKeyID != keyIDExpected {
+			t.Fatalf("unexpected encryption key id for the part %q; got %q; want %q", partPath, ph.EncryptionKeyID, keyIDExpected)
+		}
+
+		des := vmfs.MustReadDir(partPath)
+		for _, de := range des {
+			if !strings.HasSuffix(de.Name(), ".bin") {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(partPath, de.Name()))
+			if err != nil {
+				t.Fatalf("cannot read part file: %s", err)
+			}
+			if bytes.Contains(data, []byte("secret_")) || bytes.Contains(data, []byte("host-")) {
+				t.Fatalf("the file %q contains unencrypted data", filepath.Join(partPath, de.Name()))
+			}
+		}
+		if !slices.ContainsFunc(des, func(de os.DirEntry) bool { return de.Name() == messageValuesFilename }) {
+			t.Fatalf("missing %s at the part %q", messageValuesFilename, partPath)
+		}
+	}
+}
+
+func newTestEncryptionKey(n byte) string {
+	key := bytes.Repeat([]byte{n}, 32)
+	return base64.StdEncoding.EncodeToString(key)
+}
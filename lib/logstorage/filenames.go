@@ -18,7 +18,8 @@ const (
 	metadataFilename = "metadata.json"
 	partsFilename    = "parts.json"
 
-	deleteTasksFilename = "delete_tasks.json"
+	deleteTasksFilename         = "delete_tasks.json"
+	finishedDeleteTasksFilename = "delete_tasks_finished.json"
 
 	indexdbDirname    = "indexdb"
 	datadbDirname     = "datadb"
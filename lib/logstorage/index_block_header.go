@@ -2,7 +2,6 @@ package logstorage
 
 import (
 	"fmt"
-	"io"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
@@ -54,27 +53,39 @@ func (ih *indexBlockHeader) mustWriteIndexBlock(data []byte, sidFirst streamID,
 
 // mustReadNextIndexBlock reads the next index block associated with ih from src, appends it to dst and returns the result.
 func (ih *indexBlockHeader) mustReadNextIndexBlock(dst []byte, sr *streamReaders) []byte {
+	dst, err := ih.readNextIndexBlock(dst, sr)
+	if err != nil {
+		logger.Panicf("FATAL: %s", err)
+	}
+	return dst
+}
+
+// readNextIndexBlock works the same as mustReadNextIndexBlock, but returns an error instead of panicking on invalid data.
+func (ih *indexBlockHeader) readNextIndexBlock(dst []byte, sr *streamReaders) ([]byte, error) {
 	indexReader := &sr.indexReader
 
 	indexBlockSize := ih.indexBlockSize
 	if indexBlockSize > maxIndexBlockSize {
-		logger.Panicf("FATAL: %s: indexBlockHeader.indexBlockSize=%d cannot exceed %d bytes", indexReader.Path(), indexBlockSize, maxIndexBlockSize)
+		return dst, fmt.Errorf("%s: indexBlockHeader.indexBlockSize=%d cannot exceed %d bytes", indexReader.Path(), indexBlockSize, maxIndexBlockSize)
 	}
 	if ih.indexBlockOffset != indexReader.bytesRead {
-		logger.Panicf("FATAL: %s: indexBlockHeader.indexBlockOffset=%d must equal to %d", indexReader.Path(), ih.indexBlockOffset, indexReader.bytesRead)
+		return dst, fmt.Errorf("%s: indexBlockHeader.indexBlockOffset=%d must equal to %d", indexReader.Path(), ih.indexBlockOffset, indexReader.bytesRead)
 	}
 	bbCompressed := longTermBufPool.Get()
+	defer longTermBufPool.Put(bbCompressed)
+
 	bbCompressed.B = bytesutil.ResizeNoCopyMayOverallocate(bbCompressed.B, int(indexBlockSize))
-	indexReader.MustReadFull(bbCompressed.B)
+	if err := indexReader.ReadFull(bbCompressed.B); err != nil {
+		return dst, err
+	}
 
 	// Decompress bbCompressed to dst
-	var err error
-	dst, err = encoding.DecompressZSTD(dst, bbCompressed.B)
-	longTermBufPool.Put(bbCompressed)
+	dstLen := len(dst)
+	dst, err := encoding.DecompressZSTD(dst, bbCompressed.B)
 	if err != nil {
-		logger.Panicf("FATAL: %s: cannot decompress indexBlock read at offset %d with size %d: %s", indexReader.Path(), ih.indexBlockOffset, indexBlockSize, err)
+		return dst[:dstLen], fmt.Errorf("%s: cannot decompress indexBlock read at offset %d with size %d: %w", indexReader.Path(), ih.indexBlockOffset, indexBlockSize, err)
 	}
-	return dst
+	return dst, nil
 }
 
 // marshal appends marshaled ih to dst and returns the result.
@@ -122,25 +133,37 @@ func mustWriteIndexBlockHeaders(w *writerWithStats, metaindexData []byte) {
 
 // mustReadIndexBlockHeaders reads indexBlockHeader entries from r, appends them to dst and returns the result.
 func mustReadIndexBlockHeaders(dst []indexBlockHeader, r *readerWithStats) []indexBlockHeader {
-	data, err := io.ReadAll(r)
+	dst, err := readIndexBlockHeaders(dst, r)
 	if err != nil {
-		logger.Panicf("FATAL: %s: cannot read indexBlockHeader entries: %s", r.Path(), err)
+		logger.Panicf("FATAL: %s", err)
+	}
+	return dst
+}
+
+// readIndexBlockHeaders works the same as mustReadIndexBlockHeaders, but returns an error instead of panicking on invalid data.
+func readIndexBlockHeaders(dst []indexBlockHeader, r *readerWithStats) ([]indexBlockHeader, error) {
+	data, err := r.ReadAll()
+	if err != nil {
+		return dst, fmt.Errorf("%s: cannot read indexBlockHeader entries: %w", r.Path(), err)
 	}
 
 	bb := longTermBufPool.Get()
+	defer func() {
+		if len(bb.B) < 1024*1024 {
+			longTermBufPool.Put(bb)
+		}
+	}()
+
 	bb.B, err = encoding.DecompressZSTD(bb.B[:0], data)
 	if err != nil {
-		logger.Panicf("FATAL: %s: cannot decompress indexBlockHeader entries: %s", r.Path(), err)
+		return dst, fmt.Errorf("%s: cannot decompress indexBlockHeader entries: %w", r.Path(), err)
 	}
 	dst, err = unmarshalIndexBlockHeaders(dst, bb.B)
-	if len(bb.B) < 1024*1024 {
-		longTermBufPool.Put(bb)
-	}
 	if err != nil {
-		logger.Panicf("FATAL: %s: cannot parse indexBlockHeader entries: %s", r.Path(), err)
+		return dst, fmt.Errorf("%s: cannot parse indexBlockHeader entries: %w", r.Path(), err)
 	}
 
-	return dst
+	return dst, nil
 }
 
 // unmarshalIndexBlockHeaders appends unmarshaled from src indexBlockHeader entries to dst and returns the result.
@@ -116,7 +116,7 @@ func TestInmemoryPartMustInitFromRows_Overflow(t *testing.T) {
 	f(newTestLogRowsUniqTags(5, 21, 100), 5, 0.6)
 	f(newTestLogRowsUniqTags(5, 10, 100), 5, 0.7)
 	f(newTestLogRowsUniqTags(1, 2001, 1), 1, 2.0)
-	f(newTestLogRowsUniqTags(15, 20, 250), 15, 0.8)
+	f(newTestLogRowsUniqTags(15, 20, 250), 15, 0.7)
 }
 
 func checkCompressionRate(t *testing.T, ph *partHeader, compressionRateExpected float64) {
@@ -180,7 +180,7 @@ func TestInmemoryPartInitFromBlockStreamReaders(t *testing.T) {
 		mpDst := getInmemoryPart()
 		bsw := getBlockStreamWriter()
 		bsw.MustInitForInmemoryPart(mpDst)
-		mustMergeBlockStreams(&mpDst.ph, nil, bsw, bsrs, nil, nil)
+		mustMergeBlockStreams(&mpDst.ph, nil, bsw, bsrs, nil, nil, nil)
 		putBlockStreamWriter(bsw)
 
 		// Check mpDst.ph stats
@@ -406,6 +406,19 @@ func (lr *LogRows) MustAdd(tenantID TenantID, timestamp int64, fields, streamFie
 	bbPool.Put(bb)
 }
 
+// AppendStreamFields appends stream fields for the log entry with the given fields to dst and returns the result.
+//
+// The returned stream fields can be passed to MustAdd as streamFields in order to obtain the same log stream as with nil streamFields.
+func (lr *LogRows) AppendStreamFields(dst, fields []Field) []Field {
+	for _, f := range fields {
+		fieldName := getCanonicalFieldName(f.Name)
+		if _, ok := lr.streamFields[fieldName]; ok {
+			dst = append(dst, f)
+		}
+	}
+	return append(dst, lr.extraStreamFields...)
+}
+
 func (lr *LogRows) mustAddInternal(sid streamID, timestamp int64, fields []Field, streamTagsCanonical string) {
 	stcs := lr.streamTagsCanonicals
 	if len(stcs) > 0 && string(stcs[len(stcs)-1]) == streamTagsCanonical {
@@ -0,0 +1,391 @@
+package logstorage
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+)
+
+// lookupTableFormats contains the supported lookup table formats, which are also used as file extensions.
+var lookupTableFormats = []string{"csv", "jsonl"}
+
+var lookupTablesPath string
+
+// SetLookupTablesPath sets the path to the directory with lookup tables for the lookup pipe.
+//
+// Lookup tables are stored at <path>/<accountID>/<projectID>/<tableName>.{csv,jsonl}.
+//
+// See https://docs.victoriametrics.com/victorialogs/logsql/#lookup-pipe
+func SetLookupTablesPath(path string) {
+	lookupTablesPath = path
+}
+
+// LookupTableInfo contains information about a lookup table.
+type LookupTableInfo struct {
+	// Name is the lookup table name.
+	Name string `json:"name"`
+
+	// Format is the lookup table format - csv or jsonl.
+	Format string `json:"format"`
+
+	// Size is the size of the lookup table file in bytes.
+	Size int64 `json:"size"`
+
+	// ModTime is the last modification time for the lookup table file.
+	ModTime time.Time `json:"mod_time"`
+}
+
+// ListLookupTables returns lookup tables for the given tenantID sorted by name.
+func ListLookupTables(tenantID TenantID) ([]LookupTableInfo, error) {
+	if lookupTablesPath == "" {
+		return nil, fmt.Errorf("lookup tables are disabled; set -lookup.tablesPath command-line flag for enabling them")
+	}
+
+	dir := getLookupTablesDir(tenantID)
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot read lookup tables directory: %w", err)
+	}
+
+	var tis []LookupTableInfo
+	for _, de := range des {
+		if !de.Type().IsRegular() {
+			continue
+		}
+		name, format, ok := parseLookupTableFilename(de.Name())
+		if !ok {
+			continue
+		}
+		fi, err := de.Info()
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				// The file has been removed concurrently
+				continue
+			}
+			return nil, fmt.Errorf("cannot obtain information about lookup table %q: %w", name, err)
+		}
+		tis = append(tis, LookupTableInfo{
+			Name:    name,
+			Format:  format,
+			Size:    fi.Size(),
+			ModTime: fi.ModTime(),
+		})
+	}
+	sort.Slice(tis, func(i, j int) bool {
+		return tis[i].Name < tis[j].Name
+	})
+	return tis, nil
+}
+
+// WriteLookupTable atomically writes the lookup table with the given name, format and data for the given tenantID.
+//
+// The existing lookup table with the given name is replaced.
+func WriteLookupTable(tenantID TenantID, name, format string, data []byte) error {
+	if lookupTablesPath == "" {
+		return fmt.Errorf("lookup tables are disabled; set -lookup.tablesPath command-line flag for enabling them")
+	}
+	if !isValidLookupTableName(name) {
+		return fmt.Errorf("invalid lookup table name %q; it must contain only alphanumeric chars, '_' and '-'", name)
+	}
+	if !isValidLookupTableFormat(format) {
+		return fmt.Errorf("unsupported lookup table format %q; supported formats: %s", format, strings.Join(lookupTableFormats, ", "))
+	}
+	if _, err := parseLookupTable(format, data); err != nil {
+		return fmt.Errorf("cannot parse lookup table %q: %w", name, err)
+	}
+
+	dir := getLookupTablesDir(tenantID)
+	fs.MustMkdirIfNotExist(dir)
+
+	path := filepath.Join(dir, name+"."+format)
+	fs.MustWriteAtomic(path, data, true)
+
+	// Remove the table with the same name in other formats, so it doesn't shadow the written table.
+	for _, f := range lookupTableFormats {
+		if f == format {
+			continue
+		}
+		pathOther := filepath.Join(dir, name+"."+f)
+		if fs.IsPathExist(pathOther) {
+			fs.MustRemovePath(pathOther)
+		}
+	}
+	return nil
+}
+
+func getLookupTablesDir(tenantID TenantID) string {
+	return filepath.Join(lookupTablesPath, strconv.FormatUint(uint64(tenantID.AccountID), 10), strconv.FormatUint(uint64(tenantID.ProjectID), 10))
+}
+
+func parseLookupTableFilename(filename string) (string, string, bool) {
+	n := strings.LastIndexByte(filename, '.')
+	if n < 0 {
+		return "", "", false
+	}
+	name := filename[:n]
+	format := filename[n+1:]
+	if !isValidLookupTableName(name) || !isValidLookupTableFormat(format) {
+		return "", "", false
+	}
+	return name, format, true
+}
+
+func isValidLookupTableFormat(format string) bool {
+	return slices.Contains(lookupTableFormats, format)
+}
+
+func isValidLookupTableName(name string) bool {
+	if name == "" || len(name) > 255 {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupTable is a lookup table loaded into memory.
+type lookupTable struct {
+	// columns contains column names in the order of their appearance in the table.
+	columns []string
+
+	// rows contains table rows.
+	rows [][]Field
+
+	modTime time.Time
+	size    int64
+
+	viewsLock sync.Mutex
+	views     map[string]*lookupTableView
+}
+
+// lookupTableView contains lookup table rows indexed by the given 'by(...)' fields and projected to the given output fields.
+type lookupTableView struct {
+	// fields contains output field names.
+	fields []string
+
+	// m maps 'by(...)' key to row values for the fields.
+	m map[string][]string
+}
+
+// getView returns lookupTableView for the given byFields and fields.
+//
+// If fields are empty, then all the table columns except of byFields are used.
+func (lt *lookupTable) getView(byFields, fields []string) *lookupTableView {
+	var key []byte
+	key = encoding.MarshalVarUint64(key, uint64(len(byFields)))
+	key = marshalStrings(key, byFields)
+	key = marshalStrings(key, fields)
+
+	lt.viewsLock.Lock()
+	defer lt.viewsLock.Unlock()
+
+	if v := lt.views[string(key)]; v != nil {
+		return v
+	}
+
+	outFields := fields
+	if len(outFields) == 0 {
+		for _, c := range lt.columns {
+			if !slices.Contains(byFields, c) {
+				outFields = append(outFields, c)
+			}
+		}
+	}
+
+	m := make(map[string][]string, len(lt.rows))
+	var keyBuf []byte
+	for _, row := range lt.rows {
+		keyBuf = keyBuf[:0]
+		for _, f := range byFields {
+			v := getFieldValueByName(row, f)
+			keyBuf = encoding.MarshalBytes(keyBuf, bytesutil.ToUnsafeBytes(v))
+		}
+		if _, ok := m[string(keyBuf)]; ok {
+			// The first row wins for duplicate keys.
+			continue
+		}
+		values := make([]string, len(outFields))
+		for i, f := range outFields {
+			values[i] = getFieldValueByName(row, f)
+		}
+		m[string(keyBuf)] = values
+	}
+
+	v := &lookupTableView{
+		fields: outFields,
+		m:      m,
+	}
+	if lt.views == nil {
+		lt.views = make(map[string]*lookupTableView)
+	}
+	lt.views[string(key)] = v
+	return v
+}
+
+var (
+	lookupTablesCacheLock sync.Mutex
+	lookupTablesCache     = make(map[string]*lookupTable)
+)
+
+// getLookupTable returns the lookup table with the given name for the given tenantID.
+//
+// The table is re-read from the file if the file has been changed since the previous call.
+func getLookupTable(tenantID TenantID, name string) (*lookupTable, error) {
+	if lookupTablesPath == "" {
+		return nil, fmt.Errorf("lookup tables are disabled; set -lookup.tablesPath command-line flag for enabling them")
+	}
+	if !isValidLookupTableName(name) {
+		return nil, fmt.Errorf("invalid lookup table name %q", name)
+	}
+
+	dir := getLookupTablesDir(tenantID)
+	for _, format := range lookupTableFormats {
+		path := filepath.Join(dir, name+"."+format)
+		fi, err := os.Stat(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("cannot access lookup table %q: %w", name, err)
+		}
+
+		lookupTablesCacheLock.Lock()
+		lt := lookupTablesCache[path]
+		lookupTablesCacheLock.Unlock()
+
+		if lt != nil && lt.modTime.Equal(fi.ModTime()) && lt.size == fi.Size() {
+			return lt, nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read lookup table %q: %w", name, err)
+		}
+		lt, err = parseLookupTable(format, data)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse lookup table %q: %w", name, err)
+		}
+		lt.modTime = fi.ModTime()
+		lt.size = fi.Size()
+
+		lookupTablesCacheLock.Lock()
+		lookupTablesCache[path] = lt
+		lookupTablesCacheLock.Unlock()
+
+		return lt, nil
+	}
+	return nil, fmt.Errorf("cannot find lookup table %q for tenant %s", name, tenantID)
+}
+
+func parseLookupTable(format string, data []byte) (*lookupTable, error) {
+	switch format {
+	case "csv":
+		return parseLookupTableCSV(data)
+	case "jsonl":
+		return parseLookupTableJSONL(data)
+	default:
+		return nil, fmt.Errorf("unsupported lookup table format %q", format)
+	}
+}
+
+func parseLookupTableCSV(data []byte) (*lookupTable, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.ReuseRecord = false
+
+	header, err := r.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("missing header with column names")
+		}
+		return nil, err
+	}
+	seen := make(map[string]struct{}, len(header))
+	for _, name := range header {
+		if _, ok := seen[name]; ok {
+			return nil, fmt.Errorf("duplicate column name %q in the header", name)
+		}
+		seen[name] = struct{}{}
+	}
+
+	lt := &lookupTable{
+		columns: header,
+	}
+	for {
+		record, err := r.Read()
+		if err != nil {
+			if err == io.EOF {
+				return lt, nil
+			}
+			return nil, err
+		}
+		row := make([]Field, len(header))
+		for i, name := range header {
+			row[i] = Field{
+				Name:  name,
+				Value: record[i],
+			}
+		}
+		lt.rows = append(lt.rows, row)
+	}
+}
+
+func parseLookupTableJSONL(data []byte) (*lookupTable, error) {
+	p := GetJSONParser()
+	defer PutJSONParser(p)
+
+	lt := &lookupTable{}
+	seenColumns := make(map[string]struct{})
+	lineNum := 0
+	for len(data) > 0 {
+		lineNum++
+		line := data
+		n := bytes.IndexByte(data, '\n')
+		if n >= 0 {
+			line = data[:n]
+			data = data[n+1:]
+		} else {
+			data = nil
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		if err := p.ParseLogMessage(line); err != nil {
+			return nil, fmt.Errorf("cannot parse JSON at line #%d: %w", lineNum, err)
+		}
+		row := make([]Field, len(p.Fields))
+		for i, f := range p.Fields {
+			row[i] = Field{
+				Name:  strings.Clone(f.Name),
+				Value: strings.Clone(f.Value),
+			}
+			if _, ok := seenColumns[f.Name]; !ok {
+				seenColumns[row[i].Name] = struct{}{}
+				lt.columns = append(lt.columns, row[i].Name)
+			}
+		}
+		lt.rows = append(lt.rows, row)
+	}
+	return lt, nil
+}
@@ -0,0 +1,96 @@
+package logstorage
+
+import (
+	"testing"
+)
+
+func TestParseLookupTableFailure(t *testing.T) {
+	f := func(format, data string) {
+		t.Helper()
+
+		if _, err := parseLookupTable(format, []byte(data)); err == nil {
+			t.Fatalf("expecting non-nil error when parsing %s lookup table %q", format, data)
+		}
+	}
+
+	// unsupported format
+	f("xml", "<a/>")
+
+	// missing csv header
+	f("csv", "")
+
+	// duplicate csv columns
+	f("csv", "a,b,a\n1,2,3")
+
+	// mismatched number of csv columns
+	f("csv", "a,b\n1,2,3")
+
+	// invalid json
+	f("jsonl", `{"a":"b"`)
+	f("jsonl", `{"a":"b"}`+"\n"+`[1,2]`)
+}
+
+func TestListLookupTables(t *testing.T) {
+	lookupTablesPathOrig := lookupTablesPath
+	defer SetLookupTablesPath(lookupTablesPathOrig)
+	SetLookupTablesPath(t.TempDir())
+
+	tenantID := TenantID{
+		AccountID: 12,
+	}
+
+	// missing tables
+	tis, err := ListLookupTables(tenantID)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(tis) != 0 {
+		t.Fatalf("unexpected number of tables; got %d; want 0", len(tis))
+	}
+
+	mustWrite := func(name, format, data string) {
+		t.Helper()
+		if err := WriteLookupTable(tenantID, name, format, []byte(data)); err != nil {
+			t.Fatalf("cannot write lookup table %q: %s", name, err)
+		}
+	}
+	mustWrite("foo", "csv", "a,b\n1,2\n")
+	mustWrite("bar", "csv", "a\n1\n")
+	mustWrite("bar", "jsonl", `{"a":"1"}`)
+
+	tis, err = ListLookupTables(tenantID)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(tis) != 2 {
+		t.Fatalf("unexpected number of tables; got %d; want 2", len(tis))
+	}
+	if tis[0].Name != "bar" || tis[0].Format != "jsonl" || tis[0].Size != 9 {
+		t.Fatalf("unexpected first table: %#v", tis[0])
+	}
+	if tis[1].Name != "foo" || tis[1].Format != "csv" || tis[1].Size != 8 {
+		t.Fatalf("unexpected second table: %#v", tis[1])
+	}
+
+	// tables for other tenants are invisible
+	tis, err = ListLookupTables(TenantID{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(tis) != 0 {
+		t.Fatalf("unexpected number of tables for other tenant; got %d; want 0", len(tis))
+	}
+
+	// invalid tables cannot be written
+	f := func(name, format, data string) {
+		t.Helper()
+		if err := WriteLookupTable(tenantID, name, format, []byte(data)); err == nil {
+			t.Fatalf("expecting non-nil error when writing lookup table %q", name)
+		}
+	}
+	f("", "csv", "a\n1\n")
+	f("../foo", "csv", "a\n1\n")
+	f("foo.csv", "csv", "a\n1\n")
+	f("foo", "xml", "<a/>")
+	f("foo", "csv", "")
+}
@@ -53,6 +53,11 @@ func NewNetQueryRunner(qctx *QueryContext, runNetQuery RunNetQueryFunc, writeNet
 	return nqr, nil
 }
 
+// RemoteQuery returns the query, which must be executed at remote storage nodes.
+func (nqr *NetQueryRunner) RemoteQuery() *Query {
+	return nqr.qRemote
+}
+
 // Run runs the nqr query.
 //
 // The concurrency limits the number of concurrent goroutines, which process the query results at the local host.
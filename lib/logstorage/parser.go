@@ -866,6 +866,70 @@ func (q *Query) addExtraFiltersNoSubqueries(filters []filter) {
 	q.optimizeNoSubqueries()
 }
 
+// HideFields hides fields matching the given fieldFilters from q results.
+//
+// It adds `| delete <fieldFilters>` pipe in front of the pipes at q and at all its subqueries,
+// so the hidden fields cannot be accessed by the pipes.
+// Use HasFiltersByFields for verifying that q filters do not reference the hidden fields.
+func (q *Query) HideFields(fieldFilters []string) {
+	if len(fieldFilters) == 0 {
+		return
+	}
+
+	q.visitSubqueries(func(q *Query) {
+		if len(q.pipes) > 0 {
+			if pd, ok := q.pipes[0].(*pipeDelete); ok && slices.Equal(pd.fieldFilters, fieldFilters) {
+				// The fields are already hidden
+				return
+			}
+		}
+		pd := &pipeDelete{
+			fieldFilters: fieldFilters,
+		}
+		q.pipes = append([]pipe{pd}, q.pipes...)
+	})
+}
+
+// HasStreamContextPipe returns true if q or its subqueries contain `stream_context` pipe.
+func (q *Query) HasStreamContextPipe() bool {
+	hasStreamContext := false
+	q.visitSubqueries(func(q *Query) {
+		for _, p := range q.pipes {
+			if _, ok := p.(*pipeStreamContext); ok {
+				hasStreamContext = true
+			}
+		}
+	})
+	return hasStreamContext
+}
+
+// HasFiltersByFields returns true if filters at q or at its subqueries reference fields matching the given fieldFilters.
+func (q *Query) HasFiltersByFields(fieldFilters []string) bool {
+	if len(fieldFilters) == 0 {
+		return false
+	}
+
+	hasFilters := false
+	q.visitSubqueries(func(q *Query) {
+		var pf prefixfilter.Filter
+		q.f.updateNeededFields(&pf)
+		for _, fieldFilter := range fieldFilters {
+			if pf.MatchStringOrWildcard(fieldFilter) {
+				hasFilters = true
+			}
+		}
+
+		// Stream filters reference stream fields via _stream, so check them individually.
+		visitFilterRecursive(q.f, func(f filter) bool {
+			if fs, ok := f.(*filterStream); ok && fs.f.hasTagFilters(fieldFilters) {
+				hasFilters = true
+			}
+			return false
+		})
+	})
+	return hasFilters
+}
+
 // AddPipeSortByTimeDesc adds `| sort (_time) desc` pipe to q.
 func (q *Query) AddPipeSortByTimeDesc() {
 	s := "sort by (_time) desc"
@@ -3611,6 +3611,82 @@ func TestQueryDropAllPipes(t *testing.T) {
 	f(`foo | filter bar:baz | stats by (x) min(y)`, `foo bar:baz`)
 }
 
+func TestQueryHideFields(t *testing.T) {
+	f := func(qStr string, fieldFilters []string, resultExpected string) {
+		t.Helper()
+
+		q, err := ParseQuery(qStr)
+		if err != nil {
+			t.Fatalf("cannot parse [%s]: %s", qStr, err)
+		}
+		q.HideFields(fieldFilters)
+		result := q.String()
+		if result != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	f(`*`, nil, `*`)
+	f(`*`, []string{"user.email"}, `* | delete user.email`)
+	f(`foo | stats count()`, []string{"user.email", "secret.*"}, `foo | delete user.email, secret.* | stats count(*) as "count(*)"`)
+	f(`x:in(a | fields b) | union (c)`, []string{"user.email"}, `x:in(a | delete user.email | fields b) | delete user.email | union (c | delete user.email)`)
+
+	// already hidden fields
+	f(`x:in(a | delete user.email | fields b)`, []string{"user.email"}, `x:in(a | delete user.email | fields b) | delete user.email`)
+}
+
+func TestQueryHasStreamContextPipe(t *testing.T) {
+	f := func(qStr string, resultExpected bool) {
+		t.Helper()
+
+		q, err := ParseQuery(qStr)
+		if err != nil {
+			t.Fatalf("cannot parse [%s]: %s", qStr, err)
+		}
+		result := q.HasStreamContextPipe()
+		if result != resultExpected {
+			t.Fatalf("unexpected result; got %v; want %v", result, resultExpected)
+		}
+	}
+
+	f(`*`, false)
+	f(`error | stats count()`, false)
+	f(`error | stream_context before 5`, true)
+	f(`* | union (error | stream_context after 2)`, true)
+}
+
+func TestQueryHasFiltersByFields(t *testing.T) {
+	f := func(qStr string, fieldFilters []string, resultExpected bool) {
+		t.Helper()
+
+		q, err := ParseQuery(qStr)
+		if err != nil {
+			t.Fatalf("cannot parse [%s]: %s", qStr, err)
+		}
+		result := q.HasFiltersByFields(fieldFilters)
+		if result != resultExpected {
+			t.Fatalf("unexpected result; got %v; want %v", result, resultExpected)
+		}
+	}
+
+	f(`*`, nil, false)
+	f(`*`, []string{"user.email"}, false)
+	f(`foo | fields x | filter user.email:bar`, []string{"user.email"}, false)
+	f(`user.email:foo`, []string{"user.email"}, true)
+	f(`foo or secret.token:bar`, []string{"user.email", "secret.*"}, true)
+	f(`foo`, []string{"_msg"}, true)
+
+	// The filter pipe is merged into query filters
+	f(`foo | filter user.email:bar`, []string{"user.email"}, true)
+
+	f(`* | join by (x) (user.email:foo)`, []string{"user.email"}, true)
+
+	// Stream filters
+	f(`{app="foo"} error`, []string{"user.email"}, false)
+	f(`{user.email="foo"} error`, []string{"user.email"}, true)
+	f(`_stream:{app="foo" or secret.token=~"bar.+"}`, []string{"secret.*"}, true)
+}
+
 func TestQueryGetStatsByFieldsAddGroupingByTime_Success(t *testing.T) {
 	f := func(qStr string, step int64, fieldsExpected []string, qExpected string) {
 		t.Helper()
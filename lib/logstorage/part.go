@@ -67,19 +67,21 @@ func mustOpenInmemoryPart(pt *partition, mp *inmemoryPart) *part {
 	p.ph = mp.ph
 
 	// Read columnNames
-	columnNamesReader := mp.columnNames.NewReader()
-	p.columnNames, p.columnNameIDs = mustReadColumnNames(columnNamesReader)
-	columnNamesReader.MustClose()
+	var cnrs readerWithStats
+	cnrs.init(mp.columnNames.NewReader(), nil)
+	p.columnNames, p.columnNameIDs = mustReadColumnNames(&cnrs)
+	cnrs.MustClose()
 
 	// Read columnIdxs
-	columnIdxsReader := mp.columnIdxs.NewReader()
-	p.columnIdxs = mustReadColumnIdxs(columnIdxsReader, p.columnNames, p.ph.BloomValuesShardsCount)
-	columnIdxsReader.MustClose()
+	var cirs readerWithStats
+	cirs.init(mp.columnIdxs.NewReader(), nil)
+	p.columnIdxs = mustReadColumnIdxs(&cirs, p.columnNames, p.ph.BloomValuesShardsCount)
+	cirs.MustClose()
 
 	// Read metaindex
 	metaindexReader := mp.metaindex.NewReader()
 	var mrs readerWithStats
-	mrs.init(metaindexReader)
+	mrs.init(metaindexReader, nil)
 	p.indexBlockHeaders = mustReadIndexBlockHeaders(p.indexBlockHeaders[:0], &mrs)
 	metaindexReader.MustClose()
 
@@ -117,56 +119,64 @@ func mustOpenFilePart(pt *partition, path string) *part {
 	columnsHeaderPath := filepath.Join(path, columnsHeaderFilename)
 	timestampsPath := filepath.Join(path, timestampsFilename)
 
+	// Obtain the key for decrypting the part data if the part is encrypted
+	key := pt.s.encryptionKeys.mustGetKeyForPart(&p.ph, path)
+	mustOpenReaderAt := func(path string) fs.MustReadAtCloser {
+		return newEncryptedReaderAt(fs.MustOpenReaderAt(path), key)
+	}
+
 	// Read columnNames
 	if p.ph.FormatVersion >= 1 {
-		columnNamesReader := filestream.MustOpen(columnNamesPath, true)
-		p.columnNames, p.columnNameIDs = mustReadColumnNames(columnNamesReader)
-		columnNamesReader.MustClose()
+		var cnrs readerWithStats
+		cnrs.init(filestream.MustOpen(columnNamesPath, true), key)
+		p.columnNames, p.columnNameIDs = mustReadColumnNames(&cnrs)
+		cnrs.MustClose()
 	}
 	if p.ph.FormatVersion >= 3 {
-		columnIdxsReader := filestream.MustOpen(columnIdxsPath, true)
-		p.columnIdxs = mustReadColumnIdxs(columnIdxsReader, p.columnNames, p.ph.BloomValuesShardsCount)
-		columnIdxsReader.MustClose()
+		var cirs readerWithStats
+		cirs.init(filestream.MustOpen(columnIdxsPath, true), key)
+		p.columnIdxs = mustReadColumnIdxs(&cirs, p.columnNames, p.ph.BloomValuesShardsCount)
+		cirs.MustClose()
 	}
 
 	// Read metaindex
 	metaindexReader := filestream.MustOpen(metaindexPath, true)
 	var mrs readerWithStats
-	mrs.init(metaindexReader)
+	mrs.init(metaindexReader, key)
 	p.indexBlockHeaders = mustReadIndexBlockHeaders(p.indexBlockHeaders[:0], &mrs)
 	mrs.MustClose()
 
 	// Open data files
-	p.indexFile = fs.MustOpenReaderAt(indexPath)
+	p.indexFile = mustOpenReaderAt(indexPath)
 	if p.ph.FormatVersion >= 1 {
-		p.columnsHeaderIndexFile = fs.MustOpenReaderAt(columnsHeaderIndexPath)
+		p.columnsHeaderIndexFile = mustOpenReaderAt(columnsHeaderIndexPath)
 	}
-	p.columnsHeaderFile = fs.MustOpenReaderAt(columnsHeaderPath)
-	p.timestampsFile = fs.MustOpenReaderAt(timestampsPath)
+	p.columnsHeaderFile = mustOpenReaderAt(columnsHeaderPath)
+	p.timestampsFile = mustOpenReaderAt(timestampsPath)
 
 	// Open files with bloom filters and column values
 	messageBloomFilterPath := filepath.Join(path, messageBloomFilename)
-	p.messageBloomValues.bloom = fs.MustOpenReaderAt(messageBloomFilterPath)
+	p.messageBloomValues.bloom = mustOpenReaderAt(messageBloomFilterPath)
 
 	messageValuesPath := filepath.Join(path, messageValuesFilename)
-	p.messageBloomValues.values = fs.MustOpenReaderAt(messageValuesPath)
+	p.messageBloomValues.values = mustOpenReaderAt(messageValuesPath)
 
 	if p.ph.FormatVersion < 1 {
 		bloomPath := filepath.Join(path, oldBloomFilename)
-		p.oldBloomValues.bloom = fs.MustOpenReaderAt(bloomPath)
+		p.oldBloomValues.bloom = mustOpenReaderAt(bloomPath)
 
 		valuesPath := filepath.Join(path, oldValuesFilename)
-		p.oldBloomValues.values = fs.MustOpenReaderAt(valuesPath)
+		p.oldBloomValues.values = mustOpenReaderAt(valuesPath)
 	} else {
 		p.bloomValuesShards = make([]bloomValuesReaderAt, p.ph.BloomValuesShardsCount)
 		for i := range p.bloomValuesShards {
 			shard := &p.bloomValuesShards[i]
 
 			bloomPath := getBloomFilePath(path, uint64(i))
-			shard.bloom = fs.MustOpenReaderAt(bloomPath)
+			shard.bloom = mustOpenReaderAt(bloomPath)
 
 			valuesPath := getValuesFilePath(path, uint64(i))
-			shard.values = fs.MustOpenReaderAt(valuesPath)
+			shard.values = mustOpenReaderAt(valuesPath)
 		}
 	}
 
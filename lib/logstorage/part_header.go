@@ -37,6 +37,11 @@ type partHeader struct {
 
 	// BloomValuesShardsCount is the number of (bloom, values) shards in the part.
 	BloomValuesShardsCount uint64
+
+	// EncryptionKeyID is the id of the key used for encrypting the part data.
+	//
+	// It is empty if the part isn't encrypted.
+	EncryptionKeyID string `json:",omitempty"`
 }
 
 // reset resets ph for subsequent reuse
@@ -49,31 +54,39 @@ func (ph *partHeader) reset() {
 	ph.MinTimestamp = 0
 	ph.MaxTimestamp = 0
 	ph.BloomValuesShardsCount = 0
+	ph.EncryptionKeyID = ""
 }
 
 // String returns string representation for ph.
 func (ph *partHeader) String() string {
 	return fmt.Sprintf("{FormatVersion=%d, CompressedSizeBytes=%d, UncompressedSizeBytes=%d, RowsCount=%d, BlocksCount=%d, "+
-		"MinTimestamp=%s, MaxTimestamp=%s, BloomValuesShardsCount=%d}",
+		"MinTimestamp=%s, MaxTimestamp=%s, BloomValuesShardsCount=%d, EncryptionKeyID=%q}",
 		ph.FormatVersion, ph.CompressedSizeBytes, ph.UncompressedSizeBytes, ph.RowsCount, ph.BlocksCount,
-		timestampToString(ph.MinTimestamp), timestampToString(ph.MaxTimestamp), ph.BloomValuesShardsCount)
+		timestampToString(ph.MinTimestamp), timestampToString(ph.MaxTimestamp), ph.BloomValuesShardsCount, ph.EncryptionKeyID)
 }
 
 func (ph *partHeader) mustReadMetadata(partPath string) {
+	if err := ph.readMetadata(partPath); err != nil {
+		logger.Panicf("FATAL: %s", err)
+	}
+}
+
+// readMetadata works the same as mustReadMetadata, but returns an error instead of panicking on missing or invalid metadata.
+func (ph *partHeader) readMetadata(partPath string) error {
 	ph.reset()
 
 	metadataPath := filepath.Join(partPath, metadataFilename)
 	metadata, err := os.ReadFile(metadataPath)
 	if err != nil {
-		logger.Panicf("FATAL: cannot read %q: %s", metadataPath, err)
+		return fmt.Errorf("cannot read %q: %w", metadataPath, err)
 	}
 	if err := json.Unmarshal(metadata, ph); err != nil {
-		logger.Panicf("FATAL: cannot parse %q: %s", metadataPath, err)
+		return fmt.Errorf("cannot parse %q: %w", metadataPath, err)
 	}
 
 	if ph.FormatVersion <= 1 {
 		if ph.BloomValuesShardsCount != 0 {
-			logger.Panicf("FATAL: %s: unexpected BloomValuesShardsCount for FormatVersion<=1; got %d; want 0", metadataPath, ph.BloomValuesShardsCount)
+			return fmt.Errorf("%s: unexpected BloomValuesShardsCount for FormatVersion<=1; got %d; want 0", metadataPath, ph.BloomValuesShardsCount)
 		}
 		if ph.FormatVersion == 1 {
 			ph.BloomValuesShardsCount = 8
@@ -82,14 +95,15 @@ func (ph *partHeader) mustReadMetadata(partPath string) {
 
 	// Perform various checks
 	if ph.FormatVersion > partFormatLatestVersion {
-		logger.Panicf("FATAL: %s: unsupported part format version; got %d; mustn't exceed %d", metadataPath, ph.FormatVersion, partFormatLatestVersion)
+		return fmt.Errorf("%s: unsupported part format version; got %d; mustn't exceed %d", metadataPath, ph.FormatVersion, partFormatLatestVersion)
 	}
 	if ph.MinTimestamp > ph.MaxTimestamp {
-		logger.Panicf("FATAL: %s: MinTimestamp cannot exceed MaxTimestamp; got %d vs %d", metadataPath, ph.MinTimestamp, ph.MaxTimestamp)
+		return fmt.Errorf("%s: MinTimestamp cannot exceed MaxTimestamp; got %d vs %d", metadataPath, ph.MinTimestamp, ph.MaxTimestamp)
 	}
 	if ph.BlocksCount > ph.RowsCount {
-		logger.Panicf("FATAL: %s: BlocksCount=%d cannot exceed RowsCount=%d", metadataPath, ph.BlocksCount, ph.RowsCount)
+		return fmt.Errorf("%s: BlocksCount=%d cannot exceed RowsCount=%d", metadataPath, ph.BlocksCount, ph.RowsCount)
 	}
+	return nil
 }
 
 func (ph *partHeader) mustWriteMetadata(partPath string) {
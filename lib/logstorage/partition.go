@@ -3,7 +3,9 @@ package logstorage
 import (
 	"fmt"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -73,7 +75,13 @@ func mustDeletePartition(path string) {
 // The returned partition must be closed when no longer needed with mustClosePartition() call.
 func mustOpenPartition(s *Storage, path string) *partition {
 	name := filepath.Base(path)
+	return mustOpenPartitionWithName(s, path, name)
+}
 
+// mustOpenPartitionWithName opens partition with the given name at the given path for the given Storage.
+//
+// This function is used for opening replicated partitions, which are stored at directories with names distinct from the partition name.
+func mustOpenPartitionWithName(s *Storage, path, name string) *partition {
 	indexdbPath := filepath.Join(path, indexdbDirname)
 	isIndexDBExist := fs.IsPathExist(indexdbPath)
 
@@ -88,7 +96,7 @@ func mustOpenPartition(s *Storage, path string) *partition {
 		}
 
 		logger.Warnf("creating missing indexdb directory %s, this could happen if VictoriaLogs shuts down uncleanly "+
-			"(via OOM crash, a panic, SIGKILL or hardware shutdown) while creating new per-day partition", indexdbPath)
+			"(via OOM crash, a panic, SIGKILL or hardware shutdown) while creating new partition", indexdbPath)
 		mustCreateIndexdb(indexdbPath)
 	}
 
@@ -104,7 +112,7 @@ func mustOpenPartition(s *Storage, path string) *partition {
 
 	if !isDatadbExist {
 		logger.Warnf("creating missing datadb directory %s, this could happen if VictoriaLogs shuts down uncleanly "+
-			"(via OOM crash, a panic, SIGKILL or hardware shutdown) while creating new per-day partition", datadbPath)
+			"(via OOM crash, a panic, SIGKILL or hardware shutdown) while creating new partition", datadbPath)
 		mustCreateDatadb(datadbPath)
 	}
 
@@ -258,26 +266,125 @@ func (pt *partition) mustForceMerge() {
 	pt.ddb.mustForceMergeAllParts()
 }
 
-func (pt *partition) deleteRows(sso *storageSearchOptions, stopCh <-chan struct{}) bool {
+func (pt *partition) deleteRows(sso *storageSearchOptions, dt *DeleteTask, stopCh <-chan struct{}) bool {
 	// make recently ingested rows visible for search, so they could be deleted.
 	pt.debugFlush()
 
 	pso := pt.getSearchOptions(sso)
-	return pt.ddb.deleteRows(pso, stopCh)
+	return pt.ddb.deleteRows(pso, dt, stopCh)
 }
 
-func getPartitionDayFromName(name string) (int64, error) {
-	t, err := time.Parse(partitionNameFormat, name)
-	if err != nil {
-		return 0, fmt.Errorf("cannot parse partition name %q; it must have the format YYYYMMDD: %w", name, err)
+// partitionTimeRange is the time range covered by a partition.
+type partitionTimeRange struct {
+	minTimestamp int64
+	maxTimestamp int64
+}
+
+// hasOverlappingTimeRange returns true if some of trs overlap the given [minTimestamp, maxTimestamp] time range.
+func hasOverlappingTimeRange(trs []partitionTimeRange, minTimestamp, maxTimestamp int64) bool {
+	for _, tr := range trs {
+		if tr.minTimestamp <= maxTimestamp && tr.maxTimestamp >= minTimestamp {
+			return true
+		}
 	}
-	day := t.UTC().UnixNano() / nsecsPerDay
-	return day, nil
+	return false
+}
+
+// supportedPartitionIntervals contains the supported durations for partitions in nanoseconds.
+//
+// The maximum interval must be kept in sync with maxPartitionInterval.
+var supportedPartitionIntervals = []int64{
+	nsecsPerHour,
+	6 * nsecsPerHour,
+	nsecsPerDay,
+	nsecsPerWeek,
 }
 
-func getPartitionNameFromDay(day int64) string {
-	name := time.Unix(0, day*nsecsPerDay).UTC().Format(partitionNameFormat)
-	return name
+// maxPartitionInterval is the maximum duration for partitions in nanoseconds.
+const maxPartitionInterval = nsecsPerWeek
+
+// weekStartOffset is the offset for the start of the week (Monday) since the Unix epoch (Thursday, 1970-01-01).
+const weekStartOffset = 4 * nsecsPerDay
+
+// ValidatePartitionInterval verifies whether d can be used as StorageConfig.PartitionInterval.
+func ValidatePartitionInterval(d time.Duration) error {
+	if slices.Contains(supportedPartitionIntervals, d.Nanoseconds()) {
+		return nil
+	}
+	return fmt.Errorf("unsupported partition interval %s; supported values: 1h, 6h, 1d, 1w", d)
 }
 
-const partitionNameFormat = "20060102"
+// getPartitionMinTimestamp returns the start of the partition with the given interval for the given timestamp ts.
+//
+// Weekly partitions start on Monday, while the remaining partitions are aligned to the interval since the Unix epoch in UTC.
+func getPartitionMinTimestamp(ts, interval int64) int64 {
+	offset := int64(0)
+	if interval == nsecsPerWeek {
+		offset = weekStartOffset
+	}
+	ts -= offset
+	n := ts / interval
+	if ts < 0 && ts%interval != 0 {
+		n--
+	}
+	return n*interval + offset
+}
+
+// getPartitionName returns the partition name for the partition with the given interval starting at minTimestamp.
+//
+// Per-day partitions have the YYYYMMDD name for backwards compatibility.
+// Other partitions have the YYYYMMDDhh_1h, YYYYMMDDhh_6h and YYYYMMDD_1w names.
+func getPartitionName(minTimestamp, interval int64) string {
+	t := time.Unix(0, minTimestamp).UTC()
+	switch interval {
+	case nsecsPerHour:
+		return t.Format(partitionNameFormatHourly) + "_1h"
+	case 6 * nsecsPerHour:
+		return t.Format(partitionNameFormatHourly) + "_6h"
+	case nsecsPerDay:
+		return t.Format(partitionNameFormat)
+	case nsecsPerWeek:
+		return t.Format(partitionNameFormat) + "_1w"
+	default:
+		logger.Panicf("BUG: unsupported partition interval: %dns", interval)
+		return ""
+	}
+}
+
+// getPartitionTimeRangeFromName returns the [minTimestamp, maxTimestamp] time range for the partition with the given name.
+//
+// See getPartitionName for the supported partition names.
+func getPartitionTimeRangeFromName(name string) (int64, int64, error) {
+	format := partitionNameFormat
+	interval := int64(nsecsPerDay)
+	prefix, suffix, ok := strings.Cut(name, "_")
+	if ok {
+		switch suffix {
+		case "1h":
+			format = partitionNameFormatHourly
+			interval = nsecsPerHour
+		case "6h":
+			format = partitionNameFormatHourly
+			interval = 6 * nsecsPerHour
+		case "1w":
+			interval = nsecsPerWeek
+		default:
+			return 0, 0, fmt.Errorf("cannot parse partition name %q; unsupported interval suffix %q; supported suffixes: 1h, 6h, 1w", name, suffix)
+		}
+	}
+
+	t, err := time.Parse(format, prefix)
+	if err != nil {
+		return 0, 0, fmt.Errorf("cannot parse partition name %q; it must have one of the formats YYYYMMDD, YYYYMMDDhh_1h, YYYYMMDDhh_6h or YYYYMMDD_1w: %w", name, err)
+	}
+	minTimestamp := t.UTC().UnixNano()
+	if getPartitionMinTimestamp(minTimestamp, interval) != minTimestamp {
+		return 0, 0, fmt.Errorf("cannot parse partition name %q; its start time must be aligned to the partition interval", name)
+	}
+	return minTimestamp, minTimestamp + interval - 1, nil
+}
+
+const (
+	partitionNameFormat       = "20060102"
+	partitionNameFormatHourly = "2006010215"
+)
@@ -203,3 +203,87 @@ func closeTestStorage(s *Storage) {
 	s.streamIDCache.MustStop()
 	s.filterStreamCache.MustStop()
 }
+
+func TestGetPartitionTimeRangeFromNameSuccess(t *testing.T) {
+	f := func(name, minTimeExpected, maxTimeExpected string) {
+		t.Helper()
+
+		minTimestamp, maxTimestamp, err := getPartitionTimeRangeFromName(name)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		minTime := time.Unix(0, minTimestamp).UTC().Format(time.RFC3339Nano)
+		if minTime != minTimeExpected {
+			t.Fatalf("unexpected min time for %q; got %s; want %s", name, minTime, minTimeExpected)
+		}
+		maxTime := time.Unix(0, maxTimestamp).UTC().Format(time.RFC3339Nano)
+		if maxTime != maxTimeExpected {
+			t.Fatalf("unexpected max time for %q; got %s; want %s", name, maxTime, maxTimeExpected)
+		}
+
+		// Verify that the partition name can be restored from the time range
+		nameGot := getPartitionName(minTimestamp, maxTimestamp-minTimestamp+1)
+		if nameGot != name {
+			t.Fatalf("unexpected partition name; got %q; want %q", nameGot, name)
+		}
+	}
+
+	f("20240102", "2024-01-02T00:00:00Z", "2024-01-02T23:59:59.999999999Z")
+	f("2024010213_1h", "2024-01-02T13:00:00Z", "2024-01-02T13:59:59.999999999Z")
+	f("2024010218_6h", "2024-01-02T18:00:00Z", "2024-01-02T23:59:59.999999999Z")
+	f("20240101_1w", "2024-01-01T00:00:00Z", "2024-01-07T23:59:59.999999999Z")
+}
+
+func TestGetPartitionTimeRangeFromNameFailure(t *testing.T) {
+	f := func(name string) {
+		t.Helper()
+
+		if _, _, err := getPartitionTimeRangeFromName(name); err == nil {
+			t.Fatalf("expecting non-nil error for %q", name)
+		}
+	}
+
+	f("")
+	f("foo")
+	f("2024010")
+	f("20241302")
+
+	// unsupported interval
+	f("20240102_1d")
+	f("2024010213_2h")
+
+	// missing hour
+	f("20240102_1h")
+
+	// start time isn't aligned to the interval
+	f("2024010213_6h")
+	f("20240102_1w")
+}
+
+func TestGetPartitionMinTimestamp(t *testing.T) {
+	f := func(ts string, interval int64, resultExpected string) {
+		t.Helper()
+
+		tm, err := time.Parse(time.RFC3339, ts)
+		if err != nil {
+			t.Fatalf("cannot parse %q: %s", ts, err)
+		}
+		minTimestamp := getPartitionMinTimestamp(tm.UnixNano(), interval)
+		result := time.Unix(0, minTimestamp).UTC().Format(time.RFC3339)
+		if result != resultExpected {
+			t.Fatalf("unexpected result for ts=%s, interval=%d; got %s; want %s", ts, interval, result, resultExpected)
+		}
+	}
+
+	f("2024-01-02T13:45:10Z", nsecsPerHour, "2024-01-02T13:00:00Z")
+	f("2024-01-02T13:45:10Z", 6*nsecsPerHour, "2024-01-02T12:00:00Z")
+	f("2024-01-02T13:45:10Z", nsecsPerDay, "2024-01-02T00:00:00Z")
+
+	// Weekly partitions start on Monday
+	f("2024-01-02T13:45:10Z", nsecsPerWeek, "2024-01-01T00:00:00Z")
+	f("2024-01-07T23:59:59Z", nsecsPerWeek, "2024-01-01T00:00:00Z")
+	f("2024-01-08T00:00:00Z", nsecsPerWeek, "2024-01-08T00:00:00Z")
+
+	// Timestamps before the Unix epoch
+	f("1969-12-31T23:59:59Z", nsecsPerDay, "1969-12-31T00:00:00Z")
+}
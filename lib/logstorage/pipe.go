@@ -197,6 +197,7 @@ func initPipeParsers() {
 		"first":             parsePipeFirst,
 		"format":            parsePipeFormat,
 		"generate_sequence": parsePipeGenerateSequence,
+		"geoip":             parsePipeGeoIP,
 		"hash":              parsePipeHash,
 		"join":              parsePipeJoin,
 		"json_array_len":    parsePipeJSONArrayLen,
@@ -205,12 +206,14 @@ func initPipeParsers() {
 		"last":              parsePipeLast,
 		"len":               parsePipeLen,
 		"limit":             parsePipeLimit,
+		"lookup":            parsePipeLookup,
 		"math":              parsePipeMath,
 		"mv":                parsePipeRename,
 		"offset":            parsePipeOffset,
 		"order":             parsePipeSort,
 		"pack_json":         parsePipePackJSON,
 		"pack_logfmt":       parsePipePackLogfmt,
+		"parse_time":        parsePipeParseTime,
 		"query_stats":       parsePipeQueryStats,
 		"rename":            parsePipeRename,
 		"replace":           parsePipeReplace,
@@ -228,14 +231,19 @@ func initPipeParsers() {
 		"time_add":          parsePipeTimeAdd,
 		"top":               parsePipeTop,
 		"total_stats":       parsePipeTotalStats,
+		"transaction":       parsePipeTransaction,
 		"union":             parsePipeUnion,
 		"uniq":              parsePipeUniq,
+		"unpack_csv":        parsePipeUnpackCSV,
 		"unpack_json":       parsePipeUnpackJSON,
+		"unpack_kv":         parsePipeUnpackKV,
 		"unpack_logfmt":     parsePipeUnpackLogfmt,
 		"unpack_syslog":     parsePipeUnpackSyslog,
 		"unpack_words":      parsePipeUnpackWords,
+		"unpack_xml":        parsePipeUnpackXML,
 		"unroll":            parsePipeUnroll,
 		"where":             parsePipeFilter,
+		"window":            parsePipeWindow,
 	}
 }
 
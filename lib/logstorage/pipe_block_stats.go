@@ -2,6 +2,7 @@ package logstorage
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/atomicutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
@@ -80,7 +81,7 @@ func (psp *pipeBlockStatsProcessor) writeBlock(workerID uint, br *blockResult) {
 		}
 
 		if c.isConst {
-			shard.wctx.writeRow(c.name, "const", uint64(len(c.valuesEncoded[0])), 0, 0, 0, partPath)
+			shard.wctx.writeRow(c.name, "const", "", uint64(len(c.valuesEncoded[0])), 0, 0, 0, 0, partPath)
 			continue
 		}
 		if c.isTime {
@@ -88,11 +89,11 @@ func (psp *pipeBlockStatsProcessor) writeBlock(workerID uint, br *blockResult) {
 			if br.bs != nil {
 				blockSize = br.bs.bsw.bh.timestampsHeader.blockSize
 			}
-			shard.wctx.writeRow(c.name, "time", blockSize, 0, 0, 0, partPath)
+			shard.wctx.writeRow(c.name, "time", "", blockSize, 0, 0, 0, 0, partPath)
 			continue
 		}
 		if br.bs == nil {
-			shard.wctx.writeRow(c.name, "inmemory", 0, 0, 0, 0, partPath)
+			shard.wctx.writeRow(c.name, "inmemory", "", 0, 0, 0, 0, 0, partPath)
 			continue
 		}
 
@@ -105,7 +106,7 @@ func (psp *pipeBlockStatsProcessor) writeBlock(workerID uint, br *blockResult) {
 				dictSize += len(v)
 			}
 		}
-		shard.wctx.writeRow(c.name, typ, ch.valuesSize, ch.bloomFilterSize, uint64(dictItemsCount), uint64(dictSize), partPath)
+		shard.wctx.writeRow(c.name, typ, ch.valuesCodec.String(), ch.valuesSize, ch.valuesUncompressedSize, ch.bloomFilterSize, uint64(dictItemsCount), uint64(dictSize), partPath)
 	}
 
 	shard.wctx.flush()
@@ -168,30 +169,42 @@ func (wctx *pipeBlockStatsWriteContext) init(workerID uint, ppNext pipeProcessor
 	wctx.rowsLen = rowsLen
 }
 
-func (wctx *pipeBlockStatsWriteContext) writeRow(columnName, columnType string, valuesSize, bloomSize, dictItems, dictSize uint64, partPath string) {
+// writeRow writes stats for the given column.
+//
+// uncompressedSize is the size of the encoded column values before compression. It is used for calculating compression_ratio if it isn't zero.
+func (wctx *pipeBlockStatsWriteContext) writeRow(columnName, columnType, codec string, valuesSize, uncompressedSize, bloomSize, dictItems, dictSize uint64, partPath string) {
 	rcs := wctx.rcs
 	if len(rcs) == 0 {
-		wctx.rcs = slicesutil.SetLength(wctx.rcs, 8)
+		wctx.rcs = slicesutil.SetLength(wctx.rcs, 10)
 		rcs = wctx.rcs
 
 		rcs[0].name = "field"
 		rcs[1].name = "type"
-		rcs[2].name = "values_bytes"
-		rcs[3].name = "bloom_bytes"
-		rcs[4].name = "dict_items"
-		rcs[5].name = "dict_bytes"
-		rcs[6].name = "rows"
-		rcs[7].name = "part_path"
+		rcs[2].name = "codec"
+		rcs[3].name = "values_bytes"
+		rcs[4].name = "compression_ratio"
+		rcs[5].name = "bloom_bytes"
+		rcs[6].name = "dict_items"
+		rcs[7].name = "dict_bytes"
+		rcs[8].name = "rows"
+		rcs[9].name = "part_path"
 	}
 
 	wctx.addValue(&rcs[0], columnName)
 	wctx.addValue(&rcs[1], columnType)
-	wctx.addUint64Value(&rcs[2], valuesSize)
-	wctx.addUint64Value(&rcs[3], bloomSize)
-	wctx.addUint64Value(&rcs[4], dictItems)
-	wctx.addUint64Value(&rcs[5], dictSize)
-	wctx.addUint64Value(&rcs[6], uint64(wctx.rowsLen))
-	wctx.addValue(&rcs[7], partPath)
+	wctx.addValue(&rcs[2], codec)
+	wctx.addUint64Value(&rcs[3], valuesSize)
+	if uncompressedSize > 0 && valuesSize > 0 {
+		wctx.tmpBuf = strconv.AppendFloat(wctx.tmpBuf[:0], float64(uncompressedSize)/float64(valuesSize), 'f', 2, 64)
+		wctx.addValue(&rcs[4], bytesutil.ToUnsafeString(wctx.tmpBuf))
+	} else {
+		wctx.addValue(&rcs[4], "")
+	}
+	wctx.addUint64Value(&rcs[5], bloomSize)
+	wctx.addUint64Value(&rcs[6], dictItems)
+	wctx.addUint64Value(&rcs[7], dictSize)
+	wctx.addUint64Value(&rcs[8], uint64(wctx.rowsLen))
+	wctx.addValue(&rcs[9], partPath)
 
 	wctx.rowsCount++
 
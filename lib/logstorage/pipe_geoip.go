@@ -0,0 +1,142 @@
+package logstorage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/geoip"
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/prefixfilter"
+)
+
+// geoipLookup is used for obtaining GeoIP information for IP addresses.
+//
+// It is overridden in tests.
+var geoipLookup = geoip.Lookup
+
+// geoipFieldNames contains the names of fields generated by the geoip pipe.
+var geoipFieldNames = []string{"country", "country_name", "city", "asn", "as_org"}
+
+// pipeGeoIP processes '| geoip ...' pipe.
+//
+// See https://docs.victoriametrics.com/victorialogs/logsql/#geoip-pipe
+type pipeGeoIP struct {
+	// fromField is the field with IP addresses to look up.
+	fromField string
+
+	// resultPrefix is the prefix to add to the names of the generated fields.
+	resultPrefix string
+
+	// iff is an optional filter for skipping the lookup
+	iff *ifFilter
+}
+
+func (pg *pipeGeoIP) String() string {
+	s := "geoip"
+	if pg.iff != nil {
+		s += " " + pg.iff.String()
+	}
+	if lowerFromField := strings.ToLower(pg.fromField); lowerFromField == "from" || lowerFromField == "prefix" {
+		s += " " + strconv.Quote(pg.fromField)
+	} else {
+		s += " " + quoteTokenIfNeeded(pg.fromField)
+	}
+	if pg.resultPrefix != "" {
+		s += " prefix " + quoteTokenIfNeeded(pg.resultPrefix)
+	}
+	return s
+}
+
+func (pg *pipeGeoIP) splitToRemoteAndLocal(_ int64) (pipe, []pipe) {
+	// GeoIP databases are configured at the node, which executes the query, so the lookup is performed locally.
+	return nil, []pipe{pg}
+}
+
+func (pg *pipeGeoIP) canLiveTail() bool {
+	return true
+}
+
+func (pg *pipeGeoIP) canReturnLastNResults() bool {
+	return true
+}
+
+func (pg *pipeGeoIP) updateNeededFields(pf *prefixfilter.Filter) {
+	updateNeededFieldsForUnpackPipe(pg.fromField, pg.resultPrefix, geoipFieldNames, false, false, pg.iff, pf)
+}
+
+func (pg *pipeGeoIP) hasFilterInWithQuery() bool {
+	return pg.iff.hasFilterInWithQuery()
+}
+
+func (pg *pipeGeoIP) initFilterInValues(cache *inValuesCache, getFieldValuesFunc getFieldValuesFunc, keepSubquery bool) (pipe, error) {
+	iffNew, err := pg.iff.initFilterInValues(cache, getFieldValuesFunc, keepSubquery)
+	if err != nil {
+		return nil, err
+	}
+	pgNew := *pg
+	pgNew.iff = iffNew
+	return &pgNew, nil
+}
+
+func (pg *pipeGeoIP) visitSubqueries(visitFunc func(q *Query)) {
+	pg.iff.visitSubqueries(visitFunc)
+}
+
+func (pg *pipeGeoIP) newPipeProcessor(_ int, _ <-chan struct{}, _ func(), ppNext pipeProcessor) pipeProcessor {
+	unpackGeoIP := func(uctx *fieldsUnpackerContext, s string) {
+		var gi geoip.Info
+		geoipLookup(&gi, s)
+
+		uctx.addField("country", gi.Country)
+		uctx.addField("country_name", gi.CountryName)
+		uctx.addField("city", gi.City)
+		uctx.addField("asn", gi.ASN)
+		uctx.addField("as_org", gi.ASOrg)
+	}
+	return newPipeUnpackProcessor(unpackGeoIP, ppNext, pg.fromField, pg.resultPrefix, false, false, pg.iff)
+}
+
+func parsePipeGeoIP(lex *lexer) (pipe, error) {
+	if !lex.isKeyword("geoip") {
+		return nil, fmt.Errorf("unexpected token: %q; want %q", lex.token, "geoip")
+	}
+	lex.nextToken()
+
+	var iff *ifFilter
+	if lex.isKeyword("if") {
+		f, err := parseIfFilter(lex)
+		if err != nil {
+			return nil, err
+		}
+		iff = f
+	}
+
+	if lex.isKeyword("from") {
+		lex.nextToken()
+	}
+	if lex.isKeyword("prefix", "|", ")", "") {
+		return nil, fmt.Errorf("missing field name with IP addresses")
+	}
+	fromField, err := parseFieldName(lex)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse field name with IP addresses: %w", err)
+	}
+
+	resultPrefix := ""
+	if lex.isKeyword("prefix") {
+		lex.nextToken()
+		p, err := lex.nextCompoundToken()
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse 'prefix': %w", err)
+		}
+		resultPrefix = p
+	}
+
+	pg := &pipeGeoIP{
+		fromField:    fromField,
+		resultPrefix: resultPrefix,
+		iff:          iff,
+	}
+
+	return pg, nil
+}
@@ -0,0 +1,176 @@
+package logstorage
+
+import (
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/geoip"
+)
+
+func TestParsePipeGeoIPSuccess(t *testing.T) {
+	f := func(pipeStr string) {
+		t.Helper()
+		expectParsePipeSuccess(t, pipeStr)
+	}
+
+	f(`geoip ip`)
+	f(`geoip client_ip prefix geo.`)
+	f(`geoip "client ip" prefix "geo "`)
+	f(`geoip "prefix" prefix geo.`)
+	f(`geoip "from"`)
+	f(`geoip if (x:y) ip prefix geo.`)
+}
+
+func TestParsePipeGeoIPFailure(t *testing.T) {
+	f := func(pipeStr string) {
+		t.Helper()
+		expectParsePipeFailure(t, pipeStr)
+	}
+
+	f(`geoip`)
+	f(`geoip from`)
+	f(`geoip prefix geo.`)
+	f(`geoip ip prefix`)
+	f(`geoip ip*`)
+	f(`geoip if (foo) `)
+	f(`geoip ip foo`)
+}
+
+func TestPipeGeoIP(t *testing.T) {
+	f := func(pipeStr string, rows, rowsExpected [][]Field) {
+		t.Helper()
+		expectPipeResults(t, pipeStr, rows, rowsExpected)
+	}
+
+	geoipLookupOrig := geoipLookup
+	defer func() {
+		geoipLookup = geoipLookupOrig
+	}()
+	geoipLookup = func(dst *geoip.Info, ip string) bool {
+		dst.Reset()
+		switch ip {
+		case "1.2.3.4":
+			dst.Country = "DE"
+			dst.CountryName = "Germany"
+			dst.City = "Berlin"
+			dst.ASN = "3320"
+			dst.ASOrg = "Deutsche Telekom AG"
+		case "2001:db8::1":
+			dst.Country = "US"
+			dst.CountryName = "United States"
+		default:
+			return false
+		}
+		return true
+	}
+
+	// lookup with prefix
+	f(`geoip ip prefix "geo."`, [][]Field{
+		{
+			{"_msg", "foo"},
+			{"ip", "1.2.3.4"},
+		},
+		{
+			{"_msg", "bar"},
+			{"ip", "2001:db8::1"},
+		},
+		{
+			{"_msg", "baz"},
+			{"ip", "invalid"},
+		},
+	}, [][]Field{
+		{
+			{"_msg", "foo"},
+			{"ip", "1.2.3.4"},
+			{"geo.country", "DE"},
+			{"geo.country_name", "Germany"},
+			{"geo.city", "Berlin"},
+			{"geo.asn", "3320"},
+			{"geo.as_org", "Deutsche Telekom AG"},
+		},
+		{
+			{"_msg", "bar"},
+			{"ip", "2001:db8::1"},
+			{"geo.country", "US"},
+			{"geo.country_name", "United States"},
+			{"geo.city", ""},
+			{"geo.asn", ""},
+			{"geo.as_org", ""},
+		},
+		{
+			{"_msg", "baz"},
+			{"ip", "invalid"},
+			{"geo.country", ""},
+			{"geo.country_name", ""},
+			{"geo.city", ""},
+			{"geo.asn", ""},
+			{"geo.as_org", ""},
+		},
+	})
+
+	// lookup without prefix overwrites the existing fields
+	f(`geoip ip`, [][]Field{
+		{
+			{"ip", "1.2.3.4"},
+			{"city", "foo"},
+		},
+	}, [][]Field{
+		{
+			{"ip", "1.2.3.4"},
+			{"city", "Berlin"},
+			{"country", "DE"},
+			{"country_name", "Germany"},
+			{"asn", "3320"},
+			{"as_org", "Deutsche Telekom AG"},
+		},
+	})
+
+	// lookup with if condition
+	f(`geoip if (_msg:foo) ip prefix geo.`, [][]Field{
+		{
+			{"_msg", "foo"},
+			{"ip", "1.2.3.4"},
+		},
+		{
+			{"_msg", "bar"},
+			{"ip", "1.2.3.4"},
+		},
+	}, [][]Field{
+		{
+			{"_msg", "foo"},
+			{"ip", "1.2.3.4"},
+			{"geo.country", "DE"},
+			{"geo.country_name", "Germany"},
+			{"geo.city", "Berlin"},
+			{"geo.asn", "3320"},
+			{"geo.as_org", "Deutsche Telekom AG"},
+		},
+		{
+			{"_msg", "bar"},
+			{"ip", "1.2.3.4"},
+		},
+	})
+}
+
+func TestPipeGeoIPUpdateNeededFields(t *testing.T) {
+	f := func(s, allowFilters, denyFilters, allowFiltersExpected, denyFiltersExpected string) {
+		t.Helper()
+		expectPipeNeededFields(t, s, allowFilters, denyFilters, allowFiltersExpected, denyFiltersExpected)
+	}
+
+	// all the needed fields
+	f("geoip ip prefix geo.", "*", "", "*", "geo.as_org,geo.asn,geo.city,geo.country,geo.country_name")
+	f("geoip if (x:y) ip prefix geo.", "*", "", "*", "geo.as_org,geo.asn,geo.city,geo.country,geo.country_name")
+
+	// unneeded fields do not intersect with the ip field
+	f("geoip ip", "*", "f1,f2", "*", "as_org,asn,city,country,country_name,f1,f2")
+
+	// unneeded fields intersect with the ip field
+	f("geoip ip", "*", "ip", "*", "as_org,asn,city,country,country_name")
+
+	// needed fields do not intersect with the generated fields
+	f("geoip ip prefix geo.", "f1,f2", "", "f1,f2", "")
+
+	// needed fields intersect with the generated fields
+	f("geoip ip prefix geo.", "f1,geo.city", "", "f1,ip", "")
+	f("geoip if (x:y) ip prefix geo.", "geo.*", "", "geo.*,ip,x", "geo.as_org,geo.asn,geo.city,geo.country,geo.country_name")
+}
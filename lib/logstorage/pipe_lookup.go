@@ -0,0 +1,236 @@
+package logstorage
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/atomicutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/prefixfilter"
+)
+
+// pipeLookup processes '| lookup ...' pipe.
+//
+// See https://docs.victoriametrics.com/victorialogs/logsql/#lookup-pipe
+type pipeLookup struct {
+	// tableName is the name of the lookup table.
+	tableName string
+
+	// byFields contains fields to match the lookup table rows by.
+	byFields []string
+
+	// fields contains the lookup table columns to add to the output rows.
+	//
+	// All the lookup table columns except of byFields are added if fields are empty.
+	fields []string
+
+	// view is initialized at initLookupTable.
+	view *lookupTableView
+}
+
+func (pl *pipeLookup) String() string {
+	s := "lookup " + quoteTokenIfNeeded(pl.tableName) + " by (" + fieldNamesString(pl.byFields) + ")"
+	if len(pl.fields) > 0 {
+		s += " fields (" + fieldNamesString(pl.fields) + ")"
+	}
+	return s
+}
+
+func (pl *pipeLookup) splitToRemoteAndLocal(_ int64) (pipe, []pipe) {
+	// Lookup tables are stored at the node, which executes the query, so the lookup is performed locally.
+	return nil, []pipe{pl}
+}
+
+func (pl *pipeLookup) canLiveTail() bool {
+	return false
+}
+
+func (pl *pipeLookup) canReturnLastNResults() bool {
+	// The lookup table may contain _time column, which overwrites the original _time field.
+	return len(pl.fields) > 0 && !slices.Contains(pl.fields, "_time")
+}
+
+func (pl *pipeLookup) updateNeededFields(pf *prefixfilter.Filter) {
+	if len(pl.fields) == 0 {
+		// The output fields are unknown until the lookup table is loaded.
+		pf.AddAllowFilters(pl.byFields)
+		return
+	}
+
+	needByFields := false
+	for _, f := range pl.fields {
+		if pf.MatchString(f) {
+			needByFields = true
+		}
+		pf.AddDenyFilter(f)
+	}
+	if needByFields {
+		pf.AddAllowFilters(pl.byFields)
+	}
+}
+
+func (pl *pipeLookup) hasFilterInWithQuery() bool {
+	return false
+}
+
+func (pl *pipeLookup) initFilterInValues(_ *inValuesCache, _ getFieldValuesFunc, _ bool) (pipe, error) {
+	return pl, nil
+}
+
+func (pl *pipeLookup) visitSubqueries(_ func(q *Query)) {
+	// nothing to do
+}
+
+// initLookupTable returns a copy of pl with the lookup table loaded for the given tenantIDs.
+func (pl *pipeLookup) initLookupTable(tenantIDs []TenantID) (pipe, error) {
+	if len(tenantIDs) != 1 {
+		return nil, fmt.Errorf("[%s] pipe can be executed only for a single tenant; got %d tenants", pl, len(tenantIDs))
+	}
+	lt, err := getLookupTable(tenantIDs[0], pl.tableName)
+	if err != nil {
+		return nil, fmt.Errorf("cannot initialize [%s] pipe: %w", pl, err)
+	}
+	plNew := *pl
+	plNew.view = lt.getView(pl.byFields, pl.fields)
+	return &plNew, nil
+}
+
+func (pl *pipeLookup) newPipeProcessor(_ int, _ <-chan struct{}, _ func(), ppNext pipeProcessor) pipeProcessor {
+	if pl.view == nil {
+		logger.Panicf("BUG: initLookupTable() must be called before newPipeProcessor() for [%s]", pl)
+	}
+	return &pipeLookupProcessor{
+		pl:     pl,
+		ppNext: ppNext,
+	}
+}
+
+type pipeLookupProcessor struct {
+	pl     *pipeLookup
+	ppNext pipeProcessor
+
+	shards atomicutil.Slice[pipeLookupProcessorShard]
+}
+
+type pipeLookupProcessorShard struct {
+	wctx pipeUnpackWriteContext
+
+	keyBuf       []byte
+	byValues     []string
+	byColumns    [][]string
+	extraFields  []Field
+	emptyResults []string
+}
+
+func (plp *pipeLookupProcessor) writeBlock(workerID uint, br *blockResult) {
+	if br.rowsLen == 0 {
+		return
+	}
+
+	shard := plp.shards.Get(workerID)
+	shard.wctx.init(workerID, plp.ppNext, false, false, br)
+
+	pl := plp.pl
+	view := pl.view
+
+	byColumns := shard.byColumns[:0]
+	for _, f := range pl.byFields {
+		c := br.getColumnByName(f)
+		byColumns = append(byColumns, c.getValues(br))
+	}
+	shard.byColumns = byColumns
+
+	if len(shard.emptyResults) != len(view.fields) {
+		shard.emptyResults = make([]string, len(view.fields))
+	}
+
+	extraFields := shard.extraFields[:0]
+	for _, f := range view.fields {
+		extraFields = append(extraFields, Field{
+			Name: f,
+		})
+	}
+	shard.extraFields = extraFields
+
+	for rowIdx := 0; rowIdx < br.rowsLen; rowIdx++ {
+		byValues := shard.byValues[:0]
+		for _, values := range byColumns {
+			byValues = append(byValues, values[rowIdx])
+		}
+		shard.byValues = byValues
+
+		shard.keyBuf = marshalStrings(shard.keyBuf[:0], byValues)
+		results, ok := view.m[string(shard.keyBuf)]
+		if !ok {
+			results = shard.emptyResults
+		}
+		for i := range extraFields {
+			extraFields[i].Value = results[i]
+		}
+		shard.wctx.writeRow(rowIdx, extraFields)
+	}
+
+	shard.wctx.flush()
+	shard.wctx.reset()
+}
+
+func (plp *pipeLookupProcessor) flush() error {
+	return nil
+}
+
+func parsePipeLookup(lex *lexer) (pipe, error) {
+	if !lex.isKeyword("lookup") {
+		return nil, fmt.Errorf("unexpected token: %q; want %q", lex.token, "lookup")
+	}
+	lex.nextToken()
+
+	if lex.isKeyword("by", "fields", "|", ")", "") {
+		return nil, fmt.Errorf("missing lookup table name")
+	}
+	tableName, err := lex.nextCompoundToken()
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse lookup table name: %w", err)
+	}
+	if !isValidLookupTableName(tableName) {
+		return nil, fmt.Errorf("invalid lookup table name %q; it must contain only alphanumeric chars, '_' and '-'", tableName)
+	}
+
+	if !lex.isKeyword("by") {
+		return nil, fmt.Errorf("missing 'by (...)' clause after the lookup table name %q", tableName)
+	}
+	lex.nextToken()
+	byFields, err := parseFieldNamesInParens(lex)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse 'by(...)' clause: %w", err)
+	}
+	if len(byFields) == 0 {
+		return nil, fmt.Errorf("'by(...)' clause must contain at least a single field")
+	}
+
+	var fields []string
+	if lex.isKeyword("fields") {
+		lex.nextToken()
+		fs, err := parseFieldNamesInParens(lex)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse 'fields(...)' clause: %w", err)
+		}
+		if len(fs) == 0 {
+			return nil, fmt.Errorf("'fields(...)' clause must contain at least a single field")
+		}
+		for _, f := range fs {
+			if slices.Contains(byFields, f) {
+				return nil, fmt.Errorf("'fields(...)' clause cannot contain field %q from 'by(...)' clause", f)
+			}
+		}
+		fields = fs
+	}
+
+	pl := &pipeLookup{
+		tableName: tableName,
+		byFields:  byFields,
+		fields:    fields,
+	}
+
+	return pl, nil
+}
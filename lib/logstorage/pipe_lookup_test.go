@@ -0,0 +1,201 @@
+package logstorage
+
+import (
+	"testing"
+)
+
+func TestParsePipeLookupSuccess(t *testing.T) {
+	f := func(pipeStr string) {
+		t.Helper()
+		expectParsePipeSuccess(t, pipeStr)
+	}
+
+	f(`lookup services by (service)`)
+	f(`lookup services by (service) fields (team, env)`)
+	f(`lookup "services-v2" by (host, service) fields (owner)`)
+	f(`lookup services by ("service id") fields ("owner team")`)
+}
+
+func TestParsePipeLookupFailure(t *testing.T) {
+	f := func(pipeStr string) {
+		t.Helper()
+		expectParsePipeFailure(t, pipeStr)
+	}
+
+	f(`lookup`)
+	f(`lookup by (x)`)
+	f(`lookup services`)
+	f(`lookup services by`)
+	f(`lookup services by ()`)
+	f(`lookup services by (x*)`)
+	f(`lookup services by (x) fields`)
+	f(`lookup services by (x) fields ()`)
+	f(`lookup services by (x) fields (a*)`)
+	f(`lookup services by (x) fields (x)`)
+	f(`lookup "foo/bar" by (x)`)
+	f(`lookup services by (x) foo`)
+}
+
+func TestPipeLookup(t *testing.T) {
+	lookupTablesPathOrig := lookupTablesPath
+	defer SetLookupTablesPath(lookupTablesPathOrig)
+	SetLookupTablesPath(t.TempDir())
+
+	tenantID := TenantID{
+		AccountID: 1,
+		ProjectID: 2,
+	}
+	mustWriteLookupTable := func(name, format, data string) {
+		t.Helper()
+		if err := WriteLookupTable(tenantID, name, format, []byte(data)); err != nil {
+			t.Fatalf("cannot write lookup table %q: %s", name, err)
+		}
+	}
+	mustWriteLookupTable("services", "csv", "service,team,env\napi,core,prod\nweb,frontend,staging\napi,duplicate,dev\n")
+	mustWriteLookupTable("hosts", "jsonl", `{"host":"h1","service":"api","owner":"alice"}`+"\n"+`{"host":"h2","service":"api","owner":"bob"}`)
+
+	f := func(pipeStr string, rows, rowsExpected [][]Field) {
+		t.Helper()
+
+		lex := newLexer(pipeStr, 0)
+		p, err := parsePipe(lex)
+		if err != nil {
+			t.Fatalf("unexpected error when parsing %q: %s", pipeStr, err)
+		}
+		p, err = p.(*pipeLookup).initLookupTable([]TenantID{tenantID})
+		if err != nil {
+			t.Fatalf("cannot initialize lookup table: %s", err)
+		}
+		expectPipeResultsForPipe(t, p, rows, rowsExpected)
+	}
+
+	rows := [][]Field{
+		{
+			{"_msg", "foo"},
+			{"service", "api"},
+			{"host", "h2"},
+		},
+		{
+			{"_msg", "bar"},
+			{"service", "web"},
+			{"host", "h1"},
+		},
+		{
+			{"_msg", "baz"},
+			{"service", "db"},
+		},
+	}
+
+	// lookup all the table columns
+	f(`lookup services by (service)`, rows, [][]Field{
+		{
+			{"_msg", "foo"},
+			{"service", "api"},
+			{"host", "h2"},
+			{"team", "core"},
+			{"env", "prod"},
+		},
+		{
+			{"_msg", "bar"},
+			{"service", "web"},
+			{"host", "h1"},
+			{"team", "frontend"},
+			{"env", "staging"},
+		},
+		{
+			{"_msg", "baz"},
+			{"service", "db"},
+			{"team", ""},
+			{"env", ""},
+		},
+	})
+
+	// lookup the given columns
+	f(`lookup services by (service) fields (env, missing)`, rows, [][]Field{
+		{
+			{"_msg", "foo"},
+			{"service", "api"},
+			{"host", "h2"},
+			{"env", "prod"},
+			{"missing", ""},
+		},
+		{
+			{"_msg", "bar"},
+			{"service", "web"},
+			{"host", "h1"},
+			{"env", "staging"},
+			{"missing", ""},
+		},
+		{
+			{"_msg", "baz"},
+			{"service", "db"},
+			{"env", ""},
+			{"missing", ""},
+		},
+	})
+
+	// lookup by multiple fields in jsonl table
+	f(`lookup hosts by (host, service) fields (owner)`, rows, [][]Field{
+		{
+			{"_msg", "foo"},
+			{"service", "api"},
+			{"host", "h2"},
+			{"owner", "bob"},
+		},
+		{
+			{"_msg", "bar"},
+			{"service", "web"},
+			{"host", "h1"},
+			{"owner", ""},
+		},
+		{
+			{"_msg", "baz"},
+			{"service", "db"},
+			{"owner", ""},
+		},
+	})
+
+	// lookup table reload on change
+	mustWriteLookupTable("services", "jsonl", `{"service":"db","team":"storage"}`)
+	f(`lookup services by (service) fields (team)`, rows, [][]Field{
+		{
+			{"_msg", "foo"},
+			{"service", "api"},
+			{"host", "h2"},
+			{"team", ""},
+		},
+		{
+			{"_msg", "bar"},
+			{"service", "web"},
+			{"host", "h1"},
+			{"team", ""},
+		},
+		{
+			{"_msg", "baz"},
+			{"service", "db"},
+			{"team", "storage"},
+		},
+	})
+}
+
+func TestPipeLookupUpdateNeededFields(t *testing.T) {
+	f := func(s, allowFilters, denyFilters, allowFiltersExpected, denyFiltersExpected string) {
+		t.Helper()
+		expectPipeNeededFields(t, s, allowFilters, denyFilters, allowFiltersExpected, denyFiltersExpected)
+	}
+
+	// all the needed fields
+	f("lookup t by (s)", "*", "", "*", "")
+	f("lookup t by (s) fields (a, b)", "*", "", "*", "a,b")
+
+	// unneeded fields intersect with by fields
+	f("lookup t by (s)", "*", "s,x", "*", "x")
+	f("lookup t by (s) fields (a)", "*", "s,x", "*", "a,x")
+
+	// needed fields do not intersect with lookup fields
+	f("lookup t by (s) fields (a)", "x,y", "", "x,y", "")
+
+	// needed fields intersect with lookup fields
+	f("lookup t by (s) fields (a, b)", "a,x", "", "s,x", "")
+	f("lookup t by (s)", "a,x", "", "a,s,x", "")
+}
@@ -0,0 +1,172 @@
+package logstorage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/prefixfilter"
+)
+
+// pipeParseTime processes '| parse_time ...' pipe.
+//
+// See https://docs.victoriametrics.com/victorialogs/logsql/#parse_time-pipe
+type pipeParseTime struct {
+	// fromField is the field to parse timestamps from
+	fromField string
+
+	// tp is the parser for timestamps at fromField
+	tp *TimeParser
+
+	// resultField is the field to store the parsed timestamps to
+	resultField string
+
+	// iff is an optional filter for skipping timestamps parsing
+	iff *ifFilter
+}
+
+func (pp *pipeParseTime) String() string {
+	s := "parse_time"
+	if pp.iff != nil {
+		s += " " + pp.iff.String()
+	}
+	if lowerFromField := strings.ToLower(pp.fromField); lowerFromField == "from" || lowerFromField == "format" || lowerFromField == "timezone" || lowerFromField == "as" {
+		s += " " + strconv.Quote(pp.fromField)
+	} else {
+		s += " " + quoteTokenIfNeeded(pp.fromField)
+	}
+	if format := pp.tp.Format(); format != "" {
+		s += " format " + strconv.Quote(format)
+	}
+	if timezone := pp.tp.Timezone(); timezone != "" {
+		s += " timezone " + strconv.Quote(timezone)
+	}
+	if pp.resultField != "_time" {
+		s += " as " + quoteTokenIfNeeded(pp.resultField)
+	}
+	return s
+}
+
+func (pp *pipeParseTime) splitToRemoteAndLocal(_ int64) (pipe, []pipe) {
+	return pp, nil
+}
+
+func (pp *pipeParseTime) canLiveTail() bool {
+	return true
+}
+
+func (pp *pipeParseTime) canReturnLastNResults() bool {
+	return pp.resultField != "_time"
+}
+
+func (pp *pipeParseTime) updateNeededFields(pf *prefixfilter.Filter) {
+	updateNeededFieldsForUnpackPipe(pp.fromField, "", []string{pp.resultField}, false, true, pp.iff, pf)
+}
+
+func (pp *pipeParseTime) hasFilterInWithQuery() bool {
+	return pp.iff.hasFilterInWithQuery()
+}
+
+func (pp *pipeParseTime) initFilterInValues(cache *inValuesCache, getFieldValuesFunc getFieldValuesFunc, keepSubquery bool) (pipe, error) {
+	iffNew, err := pp.iff.initFilterInValues(cache, getFieldValuesFunc, keepSubquery)
+	if err != nil {
+		return nil, err
+	}
+	ppNew := *pp
+	ppNew.iff = iffNew
+	return &ppNew, nil
+}
+
+func (pp *pipeParseTime) visitSubqueries(visitFunc func(q *Query)) {
+	pp.iff.visitSubqueries(visitFunc)
+}
+
+func (pp *pipeParseTime) newPipeProcessor(_ int, _ <-chan struct{}, _ func(), ppNext pipeProcessor) pipeProcessor {
+	parseTime := func(uctx *fieldsUnpackerContext, s string) {
+		nsecs, ok := pp.tp.TryParse(s)
+		if !ok {
+			// The original value for the resultField is preserved, since the pipe processor skips empty results.
+			uctx.addField(pp.resultField, "")
+			return
+		}
+		var buf [64]byte
+		b := marshalTimestampRFC3339NanoString(buf[:0], nsecs)
+		uctx.addField(pp.resultField, bytesutil.ToUnsafeString(b))
+	}
+
+	// Skip empty results, so the resultField isn't overwritten with empty value for unparsable timestamps.
+	return newPipeUnpackProcessor(parseTime, ppNext, pp.fromField, "", false, true, pp.iff)
+}
+
+func parsePipeParseTime(lex *lexer) (pipe, error) {
+	if !lex.isKeyword("parse_time") {
+		return nil, fmt.Errorf("unexpected token: %q; want %q", lex.token, "parse_time")
+	}
+	lex.nextToken()
+
+	var iff *ifFilter
+	if lex.isKeyword("if") {
+		f, err := parseIfFilter(lex)
+		if err != nil {
+			return nil, err
+		}
+		iff = f
+	}
+
+	if lex.isKeyword("from") {
+		lex.nextToken()
+	}
+	if lex.isKeyword("format", "timezone", "as", "|", ")", "") {
+		return nil, fmt.Errorf("missing field name with timestamps")
+	}
+	fromField, err := parseFieldName(lex)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse field name with timestamps: %w", err)
+	}
+
+	format := ""
+	if lex.isKeyword("format") {
+		lex.nextToken()
+		s, err := lex.nextCompoundToken()
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse 'format': %w", err)
+		}
+		format = s
+	}
+
+	timezone := ""
+	if lex.isKeyword("timezone") {
+		lex.nextToken()
+		s, err := lex.nextCompoundToken()
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse 'timezone': %w", err)
+		}
+		timezone = s
+	}
+
+	tp, err := NewTimeParser(format, timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	resultField := "_time"
+	if lex.isKeyword("as") {
+		lex.nextToken()
+		f, err := parseFieldName(lex)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse result field name: %w", err)
+		}
+		resultField = f
+	}
+
+	pp := &pipeParseTime{
+		fromField:   fromField,
+		tp:          tp,
+		resultField: resultField,
+		iff:         iff,
+	}
+
+	return pp, nil
+}
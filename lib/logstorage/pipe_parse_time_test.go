@@ -0,0 +1,141 @@
+package logstorage
+
+import (
+	"testing"
+)
+
+func TestParsePipeParseTimeSuccess(t *testing.T) {
+	f := func(pipeStr string) {
+		t.Helper()
+		expectParsePipeSuccess(t, pipeStr)
+	}
+
+	f(`parse_time x`)
+	f(`parse_time "from"`)
+	f(`parse_time "format" format "unix_ms"`)
+	f(`parse_time x format "unix_s"`)
+	f(`parse_time x format "02/Jan/2006:15:04:05 -0700"`)
+	f(`parse_time x format "%d/%b/%Y:%H:%M:%S %z"`)
+	f(`parse_time x format "2006-01-02 15:04:05" timezone "Europe/Berlin"`)
+	f(`parse_time x as y`)
+	f(`parse_time if (x:y) x format "rfc3339" as y`)
+}
+
+func TestParsePipeParseTimeFailure(t *testing.T) {
+	f := func(pipeStr string) {
+		t.Helper()
+		expectParsePipeFailure(t, pipeStr)
+	}
+
+	f(`parse_time`)
+	f(`parse_time from`)
+	f(`parse_time if`)
+	f(`parse_time if (x:y)`)
+	f(`parse_time x format`)
+	f(`parse_time x format foobar`)
+	f(`parse_time x format "%Q"`)
+	f(`parse_time x timezone`)
+	f(`parse_time x timezone "UTC"`)
+	f(`parse_time x format "unix_s" timezone "foo/bar"`)
+	f(`parse_time x as`)
+	f(`parse_time x as *`)
+}
+
+func TestPipeParseTime(t *testing.T) {
+	f := func(pipeStr string, rows, rowsExpected [][]Field) {
+		t.Helper()
+		expectPipeResults(t, pipeStr, rows, rowsExpected)
+	}
+
+	// parse Go layout into _time
+	f(`parse_time ts format "02/Jan/2006:15:04:05 -0700"`, [][]Field{
+		{
+			{"ts", "18/Jun/2024:23:37:20 +0200"},
+			{"_time", "2025-01-01T00:00:00Z"},
+		},
+		{
+			{"ts", "foobar"},
+			{"_time", "2025-01-01T00:00:00Z"},
+		},
+	}, [][]Field{
+		{
+			{"ts", "18/Jun/2024:23:37:20 +0200"},
+			{"_time", "2024-06-18T21:37:20Z"},
+		},
+		{
+			{"ts", "foobar"},
+			{"_time", "2025-01-01T00:00:00Z"},
+		},
+	})
+
+	// parse unix timestamps into another field
+	f(`parse_time ts format "unix_ms" as t`, [][]Field{
+		{
+			{"ts", "1718753840123"},
+		},
+		{
+			{"ts", ""},
+		},
+	}, [][]Field{
+		{
+			{"ts", "1718753840123"},
+			{"t", "2024-06-18T23:37:20.123Z"},
+		},
+		{
+			{"ts", ""},
+			{"t", ""},
+		},
+	})
+
+	// strftime format with timezone
+	f(`parse_time ts format "%Y-%m-%d %H:%M:%S" timezone "Europe/Berlin" as ts`, [][]Field{
+		{
+			{"ts", "2024-06-18 23:37:20"},
+		},
+	}, [][]Field{
+		{
+			{"ts", "2024-06-18T21:37:20Z"},
+		},
+	})
+
+	// conditional parsing
+	f(`parse_time if (x:y) ts as t`, [][]Field{
+		{
+			{"ts", "1718753840"},
+			{"x", "y"},
+		},
+		{
+			{"ts", "1718753840"},
+			{"x", "z"},
+		},
+	}, [][]Field{
+		{
+			{"ts", "1718753840"},
+			{"x", "y"},
+			{"t", "2024-06-18T23:37:20Z"},
+		},
+		{
+			{"ts", "1718753840"},
+			{"x", "z"},
+		},
+	})
+}
+
+func TestPipeParseTimeUpdateNeededFields(t *testing.T) {
+	f := func(s string, allowFilters, denyFilters, allowFiltersExpected, denyFiltersExpected string) {
+		t.Helper()
+		expectPipeNeededFields(t, s, allowFilters, denyFilters, allowFiltersExpected, denyFiltersExpected)
+	}
+
+	// all the needed fields
+	f("parse_time x", "*", "", "*", "")
+	f("parse_time if (y:z) x as t", "*", "", "*", "")
+
+	// unneeded result field
+	f("parse_time x as t", "*", "t", "*", "t")
+	f("parse_time x as t", "a", "", "a", "")
+
+	// needed result field
+	f("parse_time x as t", "t", "", "t,x", "")
+	f("parse_time if (y:z) x as t", "t", "", "t,x,y", "")
+}
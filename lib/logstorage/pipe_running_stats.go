@@ -469,9 +469,16 @@ func getRunningStatsFuncParsers() map[string]runningStatsFuncParser {
 
 func initRunningStatsFuncParsers() {
 	runningStatsFuncParsers = map[string]runningStatsFuncParser{
-		"count": parseRunningStatsCount,
-		"max":   parseRunningStatsMax,
-		"min":   parseRunningStatsMin,
-		"sum":   parseRunningStatsSum,
+		"corr":     parseRunningStatsCorr,
+		"count":    parseRunningStatsCount,
+		"covar":    parseRunningStatsCovar,
+		"kurtosis": parseRunningStatsKurtosis,
+		"max":      parseRunningStatsMax,
+		"min":      parseRunningStatsMin,
+		"mode":     parseRunningStatsMode,
+		"skew":     parseRunningStatsSkew,
+		"stddev":   parseRunningStatsStddev,
+		"stdvar":   parseRunningStatsStdvar,
+		"sum":      parseRunningStatsSum,
 	}
 }
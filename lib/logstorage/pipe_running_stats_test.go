@@ -13,6 +13,8 @@ func TestParsePipeRunningStatsSuccess(t *testing.T) {
 	f(`running_stats count(*) as rows`)
 	f(`running_stats count(a*, b) as rows`)
 	f(`running_stats by (x) count(*) as rows, sum(x) as running_sum`)
+	f(`running_stats stddev(x) as x_stddev, stdvar(x*) as x_stdvar, skew(x) as x_skew, kurtosis(x) as x_kurtosis`)
+	f(`running_stats mode(x) as x_mode, covar(x, y) as xy_covar, corr(x, y) as xy_corr`)
 }
 
 func TestParsePipeRunningStatsFailure(t *testing.T) {
@@ -33,6 +35,8 @@ func TestParsePipeRunningStatsFailure(t *testing.T) {
 
 	// duplicate output name
 	f(`running_stats sum() x, count() x`)
+	f(`running_stats covar(x) y`)
+	f(`running_stats corr(x, y, z) y`)
 }
 
 func TestPipeRunningStats(t *testing.T) {
@@ -230,6 +234,50 @@ func TestPipeRunningStats(t *testing.T) {
 			{"min_c", ""},
 		},
 	})
+
+	// statistical functions
+	f("running_stats stdvar(a) a_stdvar, covar(a, b) ab_covar, mode(b) b_mode", [][]Field{
+		{
+			{"_time", "2025-07-26T10:20:30Z"},
+			{"a", "5"},
+			{"b", "4"},
+		},
+		{
+			{"_time", "2025-07-24T10:20:30Z"},
+			{"a", "1"},
+			{"b", "6"},
+		},
+		{
+			{"_time", "2025-07-25T10:20:30Z"},
+			{"a", "3"},
+			{"b", "2"},
+		},
+	}, [][]Field{
+		{
+			{"_time", "2025-07-24T10:20:30Z"},
+			{"a", "1"},
+			{"b", "6"},
+			{"a_stdvar", "0"},
+			{"ab_covar", "0"},
+			{"b_mode", "6"},
+		},
+		{
+			{"_time", "2025-07-25T10:20:30Z"},
+			{"a", "3"},
+			{"b", "2"},
+			{"a_stdvar", "1"},
+			{"ab_covar", "-2"},
+			{"b_mode", "2"},
+		},
+		{
+			{"_time", "2025-07-26T10:20:30Z"},
+			{"a", "5"},
+			{"b", "4"},
+			{"a_stdvar", "2.6666666666666665"},
+			{"ab_covar", "-1.3333333333333333"},
+			{"b_mode", "2"},
+		},
+	})
 }
 
 func TestPipeRunningStatsUpdateNeededFields(t *testing.T) {
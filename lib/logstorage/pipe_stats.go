@@ -1377,21 +1377,28 @@ func getStatsFuncParsers() map[string]statsFuncParser {
 func initStatsFuncParsers() {
 	statsFuncParsers = map[string]statsFuncParser{
 		"avg":             parseStatsAvg,
+		"corr":            parseStatsCorr,
 		"count":           parseStatsCount,
 		"count_empty":     parseStatsCountEmpty,
 		"count_uniq":      parseStatsCountUniq,
 		"count_uniq_hash": parseStatsCountUniqHash,
+		"covar":           parseStatsCovar,
 		"histogram":       parseStatsHistogram,
 		"json_values":     parseStatsJSONValues,
+		"kurtosis":        parseStatsKurtosis,
 		"max":             parseStatsMax,
 		"median":          parseStatsMedian,
 		"min":             parseStatsMin,
+		"mode":            parseStatsMode,
 		"quantile":        parseStatsQuantile,
 		"rate":            parseStatsRate,
 		"rate_sum":        parseStatsRateSum,
 		"row_any":         parseStatsRowAny,
 		"row_max":         parseStatsRowMax,
 		"row_min":         parseStatsRowMin,
+		"skew":            parseStatsSkew,
+		"stddev":          parseStatsStddev,
+		"stdvar":          parseStatsStdvar,
 		"sum":             parseStatsSum,
 		"sum_len":         parseStatsSumLen,
 		"uniq_values":     parseStatsUniqValues,
@@ -13,6 +13,8 @@ func TestParsePipeTotalStatsSuccess(t *testing.T) {
 	f(`total_stats count(*) as rows`)
 	f(`total_stats count(a*, b) as rows`)
 	f(`total_stats by (x, y) count(*) as rows, sum(n) as total_sum`)
+	f(`total_stats stddev(x) as x_stddev, stdvar(x*) as x_stdvar, skew(x) as x_skew, kurtosis(x) as x_kurtosis`)
+	f(`total_stats mode(x) as x_mode, covar(x, y) as xy_covar, corr(x, y) as xy_corr`)
 }
 
 func TestParsePipeTotalStatsFailure(t *testing.T) {
@@ -33,6 +35,8 @@ func TestParsePipeTotalStatsFailure(t *testing.T) {
 
 	// duplicate output name
 	f(`total_stats sum() x, count() x`)
+	f(`total_stats covar(x) y`)
+	f(`total_stats corr(x, y, z) y`)
 }
 
 func TestPipeTotalStats(t *testing.T) {
@@ -230,6 +234,50 @@ func TestPipeTotalStats(t *testing.T) {
 			{"min_c", ""},
 		},
 	})
+
+	// statistical functions
+	f("total_stats stdvar(a) a_stdvar, covar(a, b) ab_covar, mode(b) b_mode", [][]Field{
+		{
+			{"_time", "2025-07-26T10:20:30Z"},
+			{"a", "5"},
+			{"b", "4"},
+		},
+		{
+			{"_time", "2025-07-24T10:20:30Z"},
+			{"a", "1"},
+			{"b", "6"},
+		},
+		{
+			{"_time", "2025-07-25T10:20:30Z"},
+			{"a", "3"},
+			{"b", "2"},
+		},
+	}, [][]Field{
+		{
+			{"_time", "2025-07-24T10:20:30Z"},
+			{"a", "1"},
+			{"b", "6"},
+			{"a_stdvar", "2.6666666666666665"},
+			{"ab_covar", "-1.3333333333333333"},
+			{"b_mode", "2"},
+		},
+		{
+			{"_time", "2025-07-25T10:20:30Z"},
+			{"a", "3"},
+			{"b", "2"},
+			{"a_stdvar", "2.6666666666666665"},
+			{"ab_covar", "-1.3333333333333333"},
+			{"b_mode", "2"},
+		},
+		{
+			{"_time", "2025-07-26T10:20:30Z"},
+			{"a", "5"},
+			{"b", "4"},
+			{"a_stdvar", "2.6666666666666665"},
+			{"ab_covar", "-1.3333333333333333"},
+			{"b_mode", "2"},
+		},
+	})
 }
 
 func TestPipeTotalStatsUpdateNeededFields(t *testing.T) {
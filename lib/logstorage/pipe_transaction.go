@@ -0,0 +1,522 @@
+package logstorage
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/atomicutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/memory"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/slicesutil"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/prefixfilter"
+)
+
+// pipeTransaction processes '| transaction ...' queries.
+//
+// See https://docs.victoriametrics.com/victorialogs/logsql/#transaction-pipe
+type pipeTransaction struct {
+	// byFields contains field names from 'by(...)' clause.
+	byFields []string
+
+	// maxSpan is the maximum duration in nanoseconds between the first and the last log in a transaction.
+	//
+	// maxSpan is ignored if it is zero.
+	maxSpan    int64
+	maxSpanStr string
+
+	// maxPause is the maximum duration in nanoseconds between adjacent logs in a transaction.
+	//
+	// maxPause is ignored if it is zero.
+	maxPause    int64
+	maxPauseStr string
+
+	// startWith is an optional filter for logs, which start a new transaction.
+	startWith *ifFilter
+
+	// endWith is an optional filter for logs, which end the transaction.
+	endWith *ifFilter
+}
+
+// The names of fields generated by the transaction pipe in addition to by(...) fields and _time.
+const (
+	transactionDurationFieldName   = "duration"
+	transactionEventCountFieldName = "event_count"
+	transactionFirstMsgFieldName   = "first_msg"
+	transactionLastMsgFieldName    = "last_msg"
+	transactionEventsFieldName     = "events"
+)
+
+func (pt *pipeTransaction) String() string {
+	s := "transaction"
+	if len(pt.byFields) > 0 {
+		s += " by (" + fieldNamesString(pt.byFields) + ")"
+	}
+	if pt.maxSpan > 0 {
+		s += " max_span " + pt.maxSpanStr
+	}
+	if pt.maxPause > 0 {
+		s += " max_pause " + pt.maxPauseStr
+	}
+	if pt.startWith != nil {
+		s += " start_with (" + pt.startWith.f.String() + ")"
+	}
+	if pt.endWith != nil {
+		s += " end_with (" + pt.endWith.f.String() + ")"
+	}
+	return s
+}
+
+func (pt *pipeTransaction) splitToRemoteAndLocal(_ int64) (pipe, []pipe) {
+	return nil, []pipe{pt}
+}
+
+func (pt *pipeTransaction) canLiveTail() bool {
+	return false
+}
+
+func (pt *pipeTransaction) canReturnLastNResults() bool {
+	return false
+}
+
+func (pt *pipeTransaction) updateNeededFields(pf *prefixfilter.Filter) {
+	pfOrig := pf.Clone()
+	pf.Reset()
+
+	if pfOrig.MatchString(transactionEventsFieldName) {
+		pf.AddAllowFilter("*")
+	} else if pfOrig.MatchString(transactionFirstMsgFieldName) || pfOrig.MatchString(transactionLastMsgFieldName) {
+		pf.AddAllowFilter("_msg")
+	}
+
+	// _time, byFields and the fields used in start_with and end_with filters are needed unconditionally,
+	// since the output number of rows depends on them.
+	pf.AddAllowFilter("_time")
+	pf.AddAllowFilters(pt.byFields)
+	if pt.startWith != nil {
+		pf.AddAllowFilters(pt.startWith.allowFilters)
+	}
+	if pt.endWith != nil {
+		pf.AddAllowFilters(pt.endWith.allowFilters)
+	}
+}
+
+func (pt *pipeTransaction) hasFilterInWithQuery() bool {
+	return pt.startWith.hasFilterInWithQuery() || pt.endWith.hasFilterInWithQuery()
+}
+
+func (pt *pipeTransaction) initFilterInValues(cache *inValuesCache, getFieldValuesFunc getFieldValuesFunc, keepSubquery bool) (pipe, error) {
+	startWithNew, err := pt.startWith.initFilterInValues(cache, getFieldValuesFunc, keepSubquery)
+	if err != nil {
+		return nil, err
+	}
+	endWithNew, err := pt.endWith.initFilterInValues(cache, getFieldValuesFunc, keepSubquery)
+	if err != nil {
+		return nil, err
+	}
+	ptNew := *pt
+	ptNew.startWith = startWithNew
+	ptNew.endWith = endWithNew
+	return &ptNew, nil
+}
+
+func (pt *pipeTransaction) visitSubqueries(visitFunc func(q *Query)) {
+	pt.startWith.visitSubqueries(visitFunc)
+	pt.endWith.visitSubqueries(visitFunc)
+}
+
+func (pt *pipeTransaction) newPipeProcessor(_ int, stopCh <-chan struct{}, cancel func(), ppNext pipeProcessor) pipeProcessor {
+	maxStateSize := int64(float64(memory.Allowed()) * 0.4)
+
+	ptp := &pipeTransactionProcessor{
+		pt:     pt,
+		stopCh: stopCh,
+		cancel: cancel,
+		ppNext: ppNext,
+
+		maxStateSize: maxStateSize,
+	}
+	ptp.stateSizeBudget.Store(maxStateSize)
+
+	return ptp
+}
+
+type pipeTransactionProcessor struct {
+	pt     *pipeTransaction
+	stopCh <-chan struct{}
+	cancel func()
+	ppNext pipeProcessor
+
+	shards atomicutil.Slice[pipeTransactionProcessorShard]
+
+	maxStateSize    int64
+	stateSizeBudget atomic.Int64
+}
+
+type pipeTransactionProcessorShard struct {
+	// rows contains all the rows collected by the shard.
+	rows []transactionRow
+
+	bmStart bitmap
+	bmEnd   bitmap
+
+	columnValues [][]string
+
+	stateSizeBudget int
+}
+
+// transactionRow is a single log entry collected by the transaction pipe.
+type transactionRow struct {
+	timestamp int64
+	fields    []Field
+
+	// isStart is set if the row matches start_with filter.
+	isStart bool
+
+	// isEnd is set if the row matches end_with filter.
+	isEnd bool
+}
+
+func (shard *pipeTransactionProcessorShard) writeBlock(pt *pipeTransaction, br *blockResult) {
+	bmStart := &shard.bmStart
+	if pt.startWith != nil {
+		bmStart.init(br.rowsLen)
+		bmStart.setBits()
+		pt.startWith.f.applyToBlockResult(br, bmStart)
+	}
+	bmEnd := &shard.bmEnd
+	if pt.endWith != nil {
+		bmEnd.init(br.rowsLen)
+		bmEnd.setBits()
+		pt.endWith.f.applyToBlockResult(br, bmEnd)
+	}
+
+	cs := br.getColumns()
+	columnValues := slicesutil.SetLength(shard.columnValues, len(cs))
+	for i, c := range cs {
+		columnValues[i] = c.getValues(br)
+	}
+	shard.columnValues = columnValues
+
+	timestamps := br.getTimestamps()
+	for rowIdx := 0; rowIdx < br.rowsLen; rowIdx++ {
+		fields := make([]Field, len(cs))
+		shard.stateSizeBudget -= int(unsafe.Sizeof(fields[0])) * len(fields)
+
+		for j, c := range cs {
+			v := columnValues[j][rowIdx]
+			fields[j] = Field{
+				Name:  strings.Clone(c.name),
+				Value: strings.Clone(v),
+			}
+			shard.stateSizeBudget -= len(c.name) + len(v)
+		}
+
+		shard.rows = append(shard.rows, transactionRow{
+			timestamp: timestamps[rowIdx],
+			fields:    fields,
+			isStart:   pt.startWith != nil && bmStart.isSetBit(rowIdx),
+			isEnd:     pt.endWith != nil && bmEnd.isSetBit(rowIdx),
+		})
+		shard.stateSizeBudget -= int(unsafe.Sizeof(shard.rows[0]))
+	}
+}
+
+func (ptp *pipeTransactionProcessor) writeBlock(workerID uint, br *blockResult) {
+	if br.rowsLen == 0 {
+		return
+	}
+
+	shard := ptp.shards.Get(workerID)
+
+	for shard.stateSizeBudget < 0 {
+		// steal some budget for the state size from the global budget.
+		remaining := ptp.stateSizeBudget.Add(-stateSizeBudgetChunk)
+		if remaining < 0 {
+			// The state size is too big. Stop processing data in order to avoid OOM crash.
+			if remaining+stateSizeBudgetChunk >= 0 {
+				// Notify worker goroutines to stop calling writeBlock() in order to save CPU time.
+				ptp.cancel()
+			}
+			return
+		}
+		shard.stateSizeBudget += stateSizeBudgetChunk
+	}
+
+	shard.writeBlock(ptp.pt, br)
+}
+
+func (ptp *pipeTransactionProcessor) flush() error {
+	if n := ptp.stateSizeBudget.Load(); n <= 0 {
+		return fmt.Errorf("cannot calculate [%s], since it requires more than %dMB of memory", ptp.pt.String(), ptp.maxStateSize/(1<<20))
+	}
+
+	pt := ptp.pt
+
+	// Group rows by byFields
+	var keyBuf []byte
+	m := make(map[string][]*transactionRow)
+	shards := ptp.shards.All()
+	for _, shard := range shards {
+		for i := range shard.rows {
+			if needStop(ptp.stopCh) {
+				return nil
+			}
+
+			row := &shard.rows[i]
+			keyBuf = keyBuf[:0]
+			for _, bf := range pt.byFields {
+				v := getFieldValueByName(row.fields, bf)
+				keyBuf = encoding.MarshalBytes(keyBuf, bytesutil.ToUnsafeBytes(v))
+			}
+			m[string(keyBuf)] = append(m[string(keyBuf)], row)
+		}
+	}
+
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Write transactions per every group
+	tw := &transactionWriter{
+		pt: pt,
+		wctx: pipeRunningStatsWriter{
+			ppNext: ptp.ppNext,
+		},
+	}
+	for _, key := range keys {
+		if needStop(ptp.stopCh) {
+			return nil
+		}
+
+		rows := m[key]
+		sort.SliceStable(rows, func(i, j int) bool {
+			return rows[i].timestamp < rows[j].timestamp
+		})
+
+		start := -1
+		for i, row := range rows {
+			if start >= 0 {
+				first := rows[start]
+				last := rows[i-1]
+				if pt.maxSpan > 0 && row.timestamp-first.timestamp > pt.maxSpan || pt.maxPause > 0 && row.timestamp-last.timestamp > pt.maxPause {
+					// The transaction exceeds max_span or max_pause, so it is evicted.
+					tw.writeTransaction(rows[start:i], false)
+					start = -1
+				} else if row.isStart {
+					// The new transaction starts before the end of the current transaction.
+					tw.writeTransaction(rows[start:i], false)
+					start = -1
+				}
+			}
+			if start < 0 {
+				if pt.startWith != nil && !row.isStart {
+					// Skip rows outside transactions
+					continue
+				}
+				start = i
+			}
+			if row.isEnd {
+				tw.writeTransaction(rows[start:i+1], true)
+				start = -1
+			}
+		}
+		if start >= 0 {
+			tw.writeTransaction(rows[start:], false)
+		}
+	}
+
+	tw.wctx.flush()
+
+	return nil
+}
+
+// transactionWriter writes transactions to the next pipe.
+type transactionWriter struct {
+	pt *pipeTransaction
+
+	fields []Field
+
+	wctx pipeRunningStatsWriter
+}
+
+// writeTransaction writes a transaction consisting of the given rows sorted by time.
+//
+// isComplete must be set if the last row matches end_with filter.
+func (tw *transactionWriter) writeTransaction(rows []*transactionRow, isComplete bool) {
+	if tw.pt.endWith != nil && !isComplete {
+		// Drop incomplete transactions if end_with filter is set.
+		return
+	}
+
+	first := rows[0]
+	last := rows[len(rows)-1]
+
+	// Do not reuse buf between transactions, since the values referring it are stored in wctx until the flush.
+	var buf []byte
+	fields := tw.fields[:0]
+
+	for _, bf := range tw.pt.byFields {
+		fields = append(fields, Field{
+			Name:  bf,
+			Value: getFieldValueByName(first.fields, bf),
+		})
+	}
+
+	bufLen := len(buf)
+	buf = marshalTimestampRFC3339NanoString(buf, first.timestamp)
+	timeStr := bytesutil.ToUnsafeString(buf[bufLen:])
+
+	bufLen = len(buf)
+	buf = marshalFloat64String(buf, float64(last.timestamp-first.timestamp)/1e9)
+	durationStr := bytesutil.ToUnsafeString(buf[bufLen:])
+
+	bufLen = len(buf)
+	buf = marshalUint64String(buf, uint64(len(rows)))
+	eventCountStr := bytesutil.ToUnsafeString(buf[bufLen:])
+
+	bufLen = len(buf)
+	buf = append(buf, '[')
+	for i, row := range rows {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = MarshalFieldsToJSON(buf, row.fields)
+	}
+	buf = append(buf, ']')
+	eventsStr := bytesutil.ToUnsafeString(buf[bufLen:])
+
+	fields = append(fields, Field{
+		Name:  "_time",
+		Value: timeStr,
+	}, Field{
+		Name:  transactionDurationFieldName,
+		Value: durationStr,
+	}, Field{
+		Name:  transactionEventCountFieldName,
+		Value: eventCountStr,
+	}, Field{
+		Name:  transactionFirstMsgFieldName,
+		Value: getFieldValueByName(first.fields, "_msg"),
+	}, Field{
+		Name:  transactionLastMsgFieldName,
+		Value: getFieldValueByName(last.fields, "_msg"),
+	}, Field{
+		Name:  transactionEventsFieldName,
+		Value: eventsStr,
+	})
+
+	tw.wctx.writeRow(fields)
+
+	tw.fields = fields
+}
+
+func parsePipeTransaction(lex *lexer) (pipe, error) {
+	if !lex.isKeyword("transaction") {
+		return nil, fmt.Errorf("expecting `transaction`; got %q", lex.token)
+	}
+	lex.nextToken()
+
+	var pt pipeTransaction
+	if lex.isKeyword("by", "(") {
+		if lex.isKeyword("by") {
+			lex.nextToken()
+		}
+		bfs, err := parseFieldNamesInParens(lex)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse 'by' clause: %w", err)
+		}
+		if slices.Contains(bfs, "_time") {
+			return nil, fmt.Errorf("'by' clause cannot contain _time field")
+		}
+		pt.byFields = bfs
+	}
+
+	for {
+		switch {
+		case lex.isKeyword("max_span"):
+			if pt.maxSpan > 0 {
+				return nil, fmt.Errorf("duplicate 'max_span'")
+			}
+			lex.nextToken()
+			d, s, err := parseDuration(lex)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse 'max_span': %w", err)
+			}
+			if d <= 0 {
+				return nil, fmt.Errorf("'max_span' must be positive; got %s", s)
+			}
+			pt.maxSpan = d
+			pt.maxSpanStr = s
+		case lex.isKeyword("max_pause"):
+			if pt.maxPause > 0 {
+				return nil, fmt.Errorf("duplicate 'max_pause'")
+			}
+			lex.nextToken()
+			d, s, err := parseDuration(lex)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse 'max_pause': %w", err)
+			}
+			if d <= 0 {
+				return nil, fmt.Errorf("'max_pause' must be positive; got %s", s)
+			}
+			pt.maxPause = d
+			pt.maxPauseStr = s
+		case lex.isKeyword("start_with"):
+			if pt.startWith != nil {
+				return nil, fmt.Errorf("duplicate 'start_with'")
+			}
+			iff, err := parseTransactionFilter(lex, "start_with")
+			if err != nil {
+				return nil, err
+			}
+			pt.startWith = iff
+		case lex.isKeyword("end_with"):
+			if pt.endWith != nil {
+				return nil, fmt.Errorf("duplicate 'end_with'")
+			}
+			iff, err := parseTransactionFilter(lex, "end_with")
+			if err != nil {
+				return nil, err
+			}
+			pt.endWith = iff
+		default:
+			return &pt, nil
+		}
+	}
+}
+
+func parseTransactionFilter(lex *lexer, keyword string) (*ifFilter, error) {
+	if !lex.isKeyword(keyword) {
+		return nil, fmt.Errorf("unexpected keyword %q; expecting %q", lex.token, keyword)
+	}
+	lex.nextToken()
+	if !lex.isKeyword("(") {
+		return nil, fmt.Errorf("unexpected token %q after %q; expecting '('", lex.token, keyword)
+	}
+	lex.nextToken()
+
+	f, err := parseFilter(lex, true)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %q filter: %w", keyword, err)
+	}
+	if !lex.isKeyword(")") {
+		return nil, fmt.Errorf("unexpected token %q after %q filter; expecting ')'", lex.token, keyword)
+	}
+	lex.nextToken()
+
+	var pf prefixfilter.Filter
+	f.updateNeededFields(&pf)
+	iff := &ifFilter{
+		f:            f,
+		allowFilters: pf.GetAllowFilters(),
+	}
+	return iff, nil
+}
@@ -0,0 +1,235 @@
+package logstorage
+
+import (
+	"testing"
+)
+
+func TestParsePipeTransactionSuccess(t *testing.T) {
+	f := func(pipeStr string) {
+		t.Helper()
+		expectParsePipeSuccess(t, pipeStr)
+	}
+
+	f(`transaction`)
+	f(`transaction by (session_id)`)
+	f(`transaction by (a, b) max_span 30m`)
+	f(`transaction by (a) max_pause 5m`)
+	f(`transaction by (a) max_span 1h max_pause 5m start_with (action:=login) end_with (action:=logout)`)
+	f(`transaction start_with (foo or bar)`)
+	f(`transaction end_with (error)`)
+}
+
+func TestParsePipeTransactionFailure(t *testing.T) {
+	f := func(pipeStr string) {
+		t.Helper()
+		expectParsePipeFailure(t, pipeStr)
+	}
+
+	f(`transaction by`)
+	f(`transaction by (*)`)
+	f(`transaction by (a*)`)
+	f(`transaction by (_time)`)
+	f(`transaction max_span`)
+	f(`transaction max_span foo`)
+	f(`transaction max_span -5m`)
+	f(`transaction max_span 5m max_span 10m`)
+	f(`transaction max_pause`)
+	f(`transaction max_pause 0s`)
+	f(`transaction max_pause 1m max_pause 2m`)
+	f(`transaction start_with`)
+	f(`transaction start_with foo`)
+	f(`transaction start_with (foo`)
+	f(`transaction start_with (foo) start_with (bar)`)
+	f(`transaction end_with ()`)
+	f(`transaction end_with (foo) end_with (bar)`)
+	f(`transaction foo`)
+}
+
+func TestPipeTransaction(t *testing.T) {
+	f := func(pipeStr string, rows, rowsExpected [][]Field) {
+		t.Helper()
+		expectPipeResults(t, pipeStr, rows, rowsExpected)
+	}
+
+	rows := [][]Field{
+		{
+			{"_time", "2025-07-24T10:20:35Z"},
+			{"_msg", "view"},
+			{"session", "a"},
+		},
+		{
+			{"_time", "2025-07-24T10:20:30Z"},
+			{"_msg", "login"},
+			{"session", "a"},
+		},
+		{
+			{"_time", "2025-07-24T10:21:00Z"},
+			{"_msg", "logout"},
+			{"session", "a"},
+		},
+		{
+			{"_time", "2025-07-24T10:30:00Z"},
+			{"_msg", "login"},
+			{"session", "a"},
+		},
+		{
+			{"_time", "2025-07-24T10:20:31Z"},
+			{"_msg", "view"},
+			{"session", "b"},
+		},
+	}
+
+	login1 := `{"_time":"2025-07-24T10:20:30Z","_msg":"login","session":"a"}`
+	view := `{"_time":"2025-07-24T10:20:35Z","_msg":"view","session":"a"}`
+	logout := `{"_time":"2025-07-24T10:21:00Z","_msg":"logout","session":"a"}`
+	login2 := `{"_time":"2025-07-24T10:30:00Z","_msg":"login","session":"a"}`
+	viewB := `{"_time":"2025-07-24T10:20:31Z","_msg":"view","session":"b"}`
+
+	// transactions without options
+	f("transaction by (session)", rows, [][]Field{
+		{
+			{"session", "a"},
+			{"_time", "2025-07-24T10:20:30Z"},
+			{"duration", "570"},
+			{"event_count", "4"},
+			{"first_msg", "login"},
+			{"last_msg", "login"},
+			{"events", "[" + login1 + "," + view + "," + logout + "," + login2 + "]"},
+		},
+		{
+			{"session", "b"},
+			{"_time", "2025-07-24T10:20:31Z"},
+			{"duration", "0"},
+			{"event_count", "1"},
+			{"first_msg", "view"},
+			{"last_msg", "view"},
+			{"events", "[" + viewB + "]"},
+		},
+	})
+
+	// max_pause splits transactions
+	f("transaction by (session) max_pause 5m", rows, [][]Field{
+		{
+			{"session", "a"},
+			{"_time", "2025-07-24T10:20:30Z"},
+			{"duration", "30"},
+			{"event_count", "3"},
+			{"first_msg", "login"},
+			{"last_msg", "logout"},
+			{"events", "[" + login1 + "," + view + "," + logout + "]"},
+		},
+		{
+			{"session", "a"},
+			{"_time", "2025-07-24T10:30:00Z"},
+			{"duration", "0"},
+			{"event_count", "1"},
+			{"first_msg", "login"},
+			{"last_msg", "login"},
+			{"events", "[" + login2 + "]"},
+		},
+		{
+			{"session", "b"},
+			{"_time", "2025-07-24T10:20:31Z"},
+			{"duration", "0"},
+			{"event_count", "1"},
+			{"first_msg", "view"},
+			{"last_msg", "view"},
+			{"events", "[" + viewB + "]"},
+		},
+	})
+
+	// max_span splits transactions
+	f("transaction by (session) max_span 10s", rows, [][]Field{
+		{
+			{"session", "a"},
+			{"_time", "2025-07-24T10:20:30Z"},
+			{"duration", "5"},
+			{"event_count", "2"},
+			{"first_msg", "login"},
+			{"last_msg", "view"},
+			{"events", "[" + login1 + "," + view + "]"},
+		},
+		{
+			{"session", "a"},
+			{"_time", "2025-07-24T10:21:00Z"},
+			{"duration", "0"},
+			{"event_count", "1"},
+			{"first_msg", "logout"},
+			{"last_msg", "logout"},
+			{"events", "[" + logout + "]"},
+		},
+		{
+			{"session", "a"},
+			{"_time", "2025-07-24T10:30:00Z"},
+			{"duration", "0"},
+			{"event_count", "1"},
+			{"first_msg", "login"},
+			{"last_msg", "login"},
+			{"events", "[" + login2 + "]"},
+		},
+		{
+			{"session", "b"},
+			{"_time", "2025-07-24T10:20:31Z"},
+			{"duration", "0"},
+			{"event_count", "1"},
+			{"first_msg", "view"},
+			{"last_msg", "view"},
+			{"events", "[" + viewB + "]"},
+		},
+	})
+
+	// start_with skips logs outside transactions
+	f("transaction by (session) start_with (_msg:=login)", rows, [][]Field{
+		{
+			{"session", "a"},
+			{"_time", "2025-07-24T10:20:30Z"},
+			{"duration", "30"},
+			{"event_count", "3"},
+			{"first_msg", "login"},
+			{"last_msg", "logout"},
+			{"events", "[" + login1 + "," + view + "," + logout + "]"},
+		},
+		{
+			{"session", "a"},
+			{"_time", "2025-07-24T10:30:00Z"},
+			{"duration", "0"},
+			{"event_count", "1"},
+			{"first_msg", "login"},
+			{"last_msg", "login"},
+			{"events", "[" + login2 + "]"},
+		},
+	})
+
+	// end_with drops incomplete transactions
+	f("transaction by (session) start_with (_msg:=login) end_with (_msg:=logout)", rows, [][]Field{
+		{
+			{"session", "a"},
+			{"_time", "2025-07-24T10:20:30Z"},
+			{"duration", "30"},
+			{"event_count", "3"},
+			{"first_msg", "login"},
+			{"last_msg", "logout"},
+			{"events", "[" + login1 + "," + view + "," + logout + "]"},
+		},
+	})
+}
+
+func TestPipeTransactionUpdateNeededFields(t *testing.T) {
+	f := func(s, allowFilters, denyFilters, allowFiltersExpected, denyFiltersExpected string) {
+		t.Helper()
+		expectPipeNeededFields(t, s, allowFilters, denyFilters, allowFiltersExpected, denyFiltersExpected)
+	}
+
+	// all the needed fields
+	f("transaction", "*", "", "*", "")
+	f("transaction by (s)", "*", "", "*", "")
+
+	// the events field isn't needed
+	f("transaction by (s)", "*", "events", "_msg,_time,s", "")
+	f("transaction by (s) start_with (foo:bar)", "*", "events,first_msg,last_msg", "_time,foo,s", "")
+
+	// needed fields
+	f("transaction by (s)", "duration", "", "_time,s", "")
+	f("transaction by (s) end_with (x:=y)", "event_count,first_msg", "", "_msg,_time,s,x", "")
+	f("transaction by (s)", "events", "", "*", "")
+}
@@ -298,3 +298,31 @@ func (wctx *pipeUnpackWriteContext) flush() {
 		rcs[i].resetValues()
 	}
 }
+
+// addFieldsWithFilters adds fields matching fieldFilters to uctx.
+//
+// Empty values are added for non-wildcard fieldFilters, which are missing in fields.
+func (uctx *fieldsUnpackerContext) addFieldsWithFilters(fields []Field, fieldFilters []string) {
+	for _, f := range fields {
+		if prefixfilter.MatchFilters(fieldFilters, f.Name) {
+			uctx.addField(f.Name, f.Value)
+		}
+	}
+
+	for _, filter := range fieldFilters {
+		if prefixfilter.IsWildcardFilter(filter) {
+			continue
+		}
+
+		addEmptyField := true
+		for _, f := range fields {
+			if f.Name == filter {
+				addEmptyField = false
+				break
+			}
+		}
+		if addEmptyField {
+			uctx.addField(filter, "")
+		}
+	}
+}
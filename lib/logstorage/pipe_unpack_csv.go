@@ -0,0 +1,279 @@
+package logstorage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/prefixfilter"
+)
+
+// pipeUnpackCSV processes '| unpack_csv ...' pipe.
+//
+// See https://docs.victoriametrics.com/victorialogs/logsql/#unpack_csv-pipe
+type pipeUnpackCSV struct {
+	// fromField is the field to unpack CSV columns from
+	fromField string
+
+	// fields contains names for the CSV columns in the order of their appearance.
+	fields []string
+
+	// delimiter is the delimiter between CSV columns
+	delimiter string
+
+	// resultPrefix is prefix to add to unpacked field names
+	resultPrefix string
+
+	keepOriginalFields bool
+	skipEmptyResults   bool
+
+	// iff is an optional filter for skipping unpacking CSV
+	iff *ifFilter
+}
+
+func (pu *pipeUnpackCSV) String() string {
+	s := "unpack_csv"
+	if pu.iff != nil {
+		s += " " + pu.iff.String()
+	}
+	if !isMsgFieldName(pu.fromField) {
+		s += " from " + quoteTokenIfNeeded(pu.fromField)
+	}
+	s += " fields (" + fieldNamesString(pu.fields) + ")"
+	if pu.delimiter != "," {
+		s += " delimiter " + strconv.Quote(pu.delimiter)
+	}
+	if pu.resultPrefix != "" {
+		s += " result_prefix " + quoteTokenIfNeeded(pu.resultPrefix)
+	}
+	if pu.keepOriginalFields {
+		s += " keep_original_fields"
+	}
+	if pu.skipEmptyResults {
+		s += " skip_empty_results"
+	}
+	return s
+}
+
+func (pu *pipeUnpackCSV) splitToRemoteAndLocal(_ int64) (pipe, []pipe) {
+	return pu, nil
+}
+
+func (pu *pipeUnpackCSV) canLiveTail() bool {
+	return true
+}
+
+func (pu *pipeUnpackCSV) canReturnLastNResults() bool {
+	// TODO: verify that the unpacked fields do not overwrite _time with non-timestamp values.
+
+	return true
+}
+
+func (pu *pipeUnpackCSV) updateNeededFields(pf *prefixfilter.Filter) {
+	updateNeededFieldsForUnpackPipe(pu.fromField, pu.resultPrefix, pu.fields, pu.keepOriginalFields, pu.skipEmptyResults, pu.iff, pf)
+}
+
+func (pu *pipeUnpackCSV) hasFilterInWithQuery() bool {
+	return pu.iff.hasFilterInWithQuery()
+}
+
+func (pu *pipeUnpackCSV) initFilterInValues(cache *inValuesCache, getFieldValuesFunc getFieldValuesFunc, keepSubquery bool) (pipe, error) {
+	iffNew, err := pu.iff.initFilterInValues(cache, getFieldValuesFunc, keepSubquery)
+	if err != nil {
+		return nil, err
+	}
+	puNew := *pu
+	puNew.iff = iffNew
+	return &puNew, nil
+}
+
+func (pu *pipeUnpackCSV) visitSubqueries(visitFunc func(q *Query)) {
+	pu.iff.visitSubqueries(visitFunc)
+}
+
+func (pu *pipeUnpackCSV) newPipeProcessor(_ int, _ <-chan struct{}, _ func(), ppNext pipeProcessor) pipeProcessor {
+	unpackCSV := func(uctx *fieldsUnpackerContext, s string) {
+		p := getCSVParser()
+
+		p.parse(s, pu.delimiter)
+
+		for i, name := range pu.fields {
+			value := ""
+			if i < len(p.values) {
+				value = p.values[i]
+			}
+			uctx.addField(name, value)
+		}
+
+		putCSVParser(p)
+	}
+
+	return newPipeUnpackProcessor(unpackCSV, ppNext, pu.fromField, pu.resultPrefix, pu.keepOriginalFields, pu.skipEmptyResults, pu.iff)
+}
+
+type csvParser struct {
+	values []string
+}
+
+func (p *csvParser) reset() {
+	clear(p.values)
+	p.values = p.values[:0]
+}
+
+// parse parses a single CSV line s with the given delimiter between columns.
+//
+// Columns may be quoted with double quotes according to RFC 4180. Double quotes inside quoted columns must be escaped with another double quote.
+func (p *csvParser) parse(s, delimiter string) {
+	p.reset()
+	for {
+		if !strings.HasPrefix(s, `"`) {
+			n := strings.Index(s, delimiter)
+			if n < 0 {
+				p.values = append(p.values, s)
+				return
+			}
+			p.values = append(p.values, s[:n])
+			s = s[n+len(delimiter):]
+			continue
+		}
+
+		// Parse quoted column
+		s = s[1:]
+		hasEscapedQuotes := false
+		n := 0
+		for {
+			m := strings.IndexByte(s[n:], '"')
+			if m < 0 {
+				// Missing closing quote - return the remaining string as is.
+				n = len(s)
+				break
+			}
+			n += m
+			if n+1 < len(s) && s[n+1] == '"' {
+				hasEscapedQuotes = true
+				n += 2
+				continue
+			}
+			break
+		}
+		value := s[:n]
+		if hasEscapedQuotes {
+			value = strings.ReplaceAll(value, `""`, `"`)
+		}
+		p.values = append(p.values, value)
+		if n >= len(s) {
+			return
+		}
+
+		// Skip the closing quote and the remaining chars until the next delimiter
+		s = s[n+1:]
+		n = strings.Index(s, delimiter)
+		if n < 0 {
+			return
+		}
+		s = s[n+len(delimiter):]
+	}
+}
+
+func getCSVParser() *csvParser {
+	v := csvParserPool.Get()
+	if v == nil {
+		return &csvParser{}
+	}
+	return v.(*csvParser)
+}
+
+func putCSVParser(p *csvParser) {
+	p.reset()
+	csvParserPool.Put(p)
+}
+
+var csvParserPool sync.Pool
+
+func parsePipeUnpackCSV(lex *lexer) (pipe, error) {
+	if !lex.isKeyword("unpack_csv") {
+		return nil, fmt.Errorf("unexpected token: %q; want %q", lex.token, "unpack_csv")
+	}
+	lex.nextToken()
+
+	var iff *ifFilter
+	if lex.isKeyword("if") {
+		f, err := parseIfFilter(lex)
+		if err != nil {
+			return nil, err
+		}
+		iff = f
+	}
+
+	fromField := "_msg"
+	if !lex.isKeyword("fields", ")", "|", "") {
+		if lex.isKeyword("from") {
+			lex.nextToken()
+		}
+		f, err := parseFieldName(lex)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse 'from' field name: %w", err)
+		}
+		fromField = f
+	}
+
+	if !lex.isKeyword("fields") {
+		return nil, fmt.Errorf("missing 'fields (...)' with CSV column names")
+	}
+	lex.nextToken()
+	fields, err := parseFieldNamesInParens(lex)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse 'fields': %w", err)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("'fields (...)' must contain at least a single CSV column name")
+	}
+
+	delimiter := ","
+	if lex.isKeyword("delimiter") {
+		lex.nextToken()
+		d, err := lex.nextCompoundToken()
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse 'delimiter': %w", err)
+		}
+		if utf8.RuneCountInString(d) != 1 || d == `"` || d == "\n" || d == "\r" {
+			return nil, fmt.Errorf("'delimiter' must contain a single char except of double quote and newline; got %q", d)
+		}
+		delimiter = d
+	}
+
+	resultPrefix := ""
+	if lex.isKeyword("result_prefix") {
+		lex.nextToken()
+		p, err := lex.nextCompoundToken()
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse 'result_prefix': %w", err)
+		}
+		resultPrefix = p
+	}
+
+	keepOriginalFields := false
+	skipEmptyResults := false
+	switch {
+	case lex.isKeyword("keep_original_fields"):
+		lex.nextToken()
+		keepOriginalFields = true
+	case lex.isKeyword("skip_empty_results"):
+		lex.nextToken()
+		skipEmptyResults = true
+	}
+
+	pu := &pipeUnpackCSV{
+		fromField:          fromField,
+		fields:             fields,
+		delimiter:          delimiter,
+		resultPrefix:       resultPrefix,
+		keepOriginalFields: keepOriginalFields,
+		skipEmptyResults:   skipEmptyResults,
+		iff:                iff,
+	}
+
+	return pu, nil
+}
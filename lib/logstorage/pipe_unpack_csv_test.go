@@ -0,0 +1,201 @@
+package logstorage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePipeUnpackCSVSuccess(t *testing.T) {
+	f := func(pipeStr string) {
+		t.Helper()
+		expectParsePipeSuccess(t, pipeStr)
+	}
+
+	f(`unpack_csv fields (a)`)
+	f(`unpack_csv fields (a, b)`)
+	f(`unpack_csv fields (a, b) delimiter ";"`)
+	f(`unpack_csv fields (a, b) delimiter "\t"`)
+	f(`unpack_csv fields (a, b) skip_empty_results`)
+	f(`unpack_csv fields (a, b) keep_original_fields`)
+	f(`unpack_csv if (a:x) fields (a, b)`)
+	f(`unpack_csv from x fields (a, b)`)
+	f(`unpack_csv from x fields (a, b) delimiter "|" result_prefix abc`)
+	f(`unpack_csv if (a:x) from x fields (a, b) delimiter ";" result_prefix abc skip_empty_results`)
+	f(`unpack_csv if (a:x) from x fields (a, b) result_prefix abc keep_original_fields`)
+}
+
+func TestParsePipeUnpackCSVFailure(t *testing.T) {
+	f := func(pipeStr string) {
+		t.Helper()
+		expectParsePipeFailure(t, pipeStr)
+	}
+
+	f(`unpack_csv`)
+	f(`unpack_csv from x`)
+	f(`unpack_csv fields`)
+	f(`unpack_csv fields ()`)
+	f(`unpack_csv fields (a*)`)
+	f(`unpack_csv if`)
+	f(`unpack_csv from`)
+	f(`unpack_csv from x y`)
+	f(`unpack_csv fields (a) delimiter`)
+	f(`unpack_csv fields (a) delimiter ""`)
+	f(`unpack_csv fields (a) delimiter ";;"`)
+	f(`unpack_csv fields (a) delimiter '"'`)
+	f(`unpack_csv fields (a) result_prefix`)
+	f(`unpack_csv fields (a) result_prefix a b`)
+}
+
+func TestPipeUnpackCSV(t *testing.T) {
+	f := func(pipeStr string, rows, rowsExpected [][]Field) {
+		t.Helper()
+		expectPipeResults(t, pipeStr, rows, rowsExpected)
+	}
+
+	// unpack from _msg
+	f("unpack_csv fields (a, b, c)", [][]Field{
+		{
+			{"_msg", `foo,"bar, ""baz""",`},
+			{"a", "xxx"},
+		},
+	}, [][]Field{
+		{
+			{"_msg", `foo,"bar, ""baz""",`},
+			{"a", "foo"},
+			{"b", `bar, "baz"`},
+			{"c", ""},
+		},
+	})
+
+	// missing and extra columns
+	f("unpack_csv from x fields (a, b, c)", [][]Field{
+		{
+			{"x", `1,2,3,4`},
+		},
+		{
+			{"x", `1`},
+		},
+	}, [][]Field{
+		{
+			{"x", `1,2,3,4`},
+			{"a", "1"},
+			{"b", "2"},
+			{"c", "3"},
+		},
+		{
+			{"x", `1`},
+			{"a", "1"},
+			{"b", ""},
+			{"c", ""},
+		},
+	})
+
+	// custom delimiter and result_prefix
+	f(`unpack_csv fields (a, b) delimiter ";" result_prefix "qwe_"`, [][]Field{
+		{
+			{"_msg", `foo;bar,baz`},
+		},
+	}, [][]Field{
+		{
+			{"_msg", `foo;bar,baz`},
+			{"qwe_a", "foo"},
+			{"qwe_b", "bar,baz"},
+		},
+	})
+
+	// skip empty results
+	f("unpack_csv fields (a, b) skip_empty_results", [][]Field{
+		{
+			{"_msg", `,x`},
+			{"a", "foo"},
+			{"b", "bar"},
+		},
+	}, [][]Field{
+		{
+			{"_msg", `,x`},
+			{"a", "foo"},
+			{"b", "x"},
+		},
+	})
+
+	// keep original fields
+	f("unpack_csv fields (a, b) keep_original_fields", [][]Field{
+		{
+			{"_msg", `x,y`},
+			{"a", "foo"},
+		},
+	}, [][]Field{
+		{
+			{"_msg", `x,y`},
+			{"a", "foo"},
+			{"b", "y"},
+		},
+	})
+
+	// if condition
+	f("unpack_csv if (x:foo) from x fields (a, b)", [][]Field{
+		{
+			{"x", `foo,bar`},
+		},
+		{
+			{"x", `baz,bar`},
+		},
+	}, [][]Field{
+		{
+			{"x", `foo,bar`},
+			{"a", "foo"},
+			{"b", "bar"},
+		},
+		{
+			{"x", `baz,bar`},
+		},
+	})
+}
+
+func TestPipeUnpackCSVUpdateNeededFields(t *testing.T) {
+	f := func(s string, allowFilters, denyFilters, allowFiltersExpected, denyFiltersExpected string) {
+		t.Helper()
+		expectPipeNeededFields(t, s, allowFilters, denyFilters, allowFiltersExpected, denyFiltersExpected)
+	}
+
+	// all the needed fields
+	f("unpack_csv fields (f1, f2)", "*", "", "*", "f1,f2")
+	f("unpack_csv fields (f1, f2) skip_empty_results", "*", "", "*", "")
+	f("unpack_csv fields (f1, f2) keep_original_fields", "*", "", "*", "")
+	f("unpack_csv if (y:z) from x fields (f1, f2)", "*", "", "*", "f1,f2")
+
+	// needed fields do not intersect with the unpacked fields
+	f("unpack_csv from x fields (f1, f2)", "a", "", "a", "")
+
+	// needed fields intersect with the unpacked fields
+	f("unpack_csv from x fields (f1, f2)", "f2,a", "", "a,x", "")
+	f("unpack_csv if (y:z) from x fields (f1, f2)", "f2", "", "x,y", "")
+
+	// query contains 'result_prefix'
+	f("unpack_csv from x fields (f1, f2) result_prefix foo_", "*", "", "*", "foo_f1,foo_f2")
+}
+
+func TestCSVParser(t *testing.T) {
+	f := func(s, delimiter string, valuesExpected []string) {
+		t.Helper()
+
+		p := getCSVParser()
+		defer putCSVParser(p)
+
+		p.parse(s, delimiter)
+		if !reflect.DeepEqual(p.values, valuesExpected) {
+			t.Fatalf("unexpected values when parsing [%s]\ngot\n%q\nwant\n%q", s, p.values, valuesExpected)
+		}
+	}
+
+	f(``, ",", []string{""})
+	f(`foo`, ",", []string{"foo"})
+	f(`foo,bar`, ",", []string{"foo", "bar"})
+	f(`foo,,bar,`, ",", []string{"foo", "", "bar", ""})
+	f(`"foo,bar",baz`, ",", []string{"foo,bar", "baz"})
+	f(`"a ""b"" c",d`, ",", []string{`a "b" c`, "d"})
+	f(`"foo"bar,baz`, ",", []string{"foo", "baz"})
+	f(`"foo,bar`, ",", []string{"foo,bar"})
+	f(`a	b	"c	d"`, "\t", []string{"a", "b", "c\td"})
+	f(`a¦b`, "¦", []string{"a", "b"})
+}
@@ -0,0 +1,306 @@
+package logstorage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/prefixfilter"
+)
+
+// pipeUnpackKV processes '| unpack_kv ...' pipe.
+//
+// See https://docs.victoriametrics.com/victorialogs/logsql/#unpack_kv-pipe
+type pipeUnpackKV struct {
+	// fromField is the field to unpack key-value pairs from
+	fromField string
+
+	// filterFields is list of field filters to extract from key-value pairs.
+	fieldFilters []string
+
+	// pairDelim is the delimiter between key-value pairs
+	pairDelim string
+
+	// kvDelim is the delimiter between the key and the value
+	kvDelim string
+
+	// resultPrefix is prefix to add to unpacked field names
+	resultPrefix string
+
+	keepOriginalFields bool
+	skipEmptyResults   bool
+
+	// iff is an optional filter for skipping unpacking key-value pairs
+	iff *ifFilter
+}
+
+func (pu *pipeUnpackKV) String() string {
+	s := "unpack_kv"
+	if pu.iff != nil {
+		s += " " + pu.iff.String()
+	}
+	if !isMsgFieldName(pu.fromField) {
+		s += " from " + quoteTokenIfNeeded(pu.fromField)
+	}
+	if !prefixfilter.MatchAll(pu.fieldFilters) {
+		s += " fields (" + fieldNamesString(pu.fieldFilters) + ")"
+	}
+	if pu.pairDelim != " " {
+		s += " pair_delim " + strconv.Quote(pu.pairDelim)
+	}
+	if pu.kvDelim != "=" {
+		s += " kv_delim " + strconv.Quote(pu.kvDelim)
+	}
+	if pu.resultPrefix != "" {
+		s += " result_prefix " + quoteTokenIfNeeded(pu.resultPrefix)
+	}
+	if pu.keepOriginalFields {
+		s += " keep_original_fields"
+	}
+	if pu.skipEmptyResults {
+		s += " skip_empty_results"
+	}
+	return s
+}
+
+func (pu *pipeUnpackKV) splitToRemoteAndLocal(_ int64) (pipe, []pipe) {
+	return pu, nil
+}
+
+func (pu *pipeUnpackKV) canLiveTail() bool {
+	return true
+}
+
+func (pu *pipeUnpackKV) canReturnLastNResults() bool {
+	// TODO: verify that the unpacked fields do not overwrite _time with non-timestamp values.
+
+	return true
+}
+
+func (pu *pipeUnpackKV) updateNeededFields(pf *prefixfilter.Filter) {
+	updateNeededFieldsForUnpackPipe(pu.fromField, pu.resultPrefix, pu.fieldFilters, pu.keepOriginalFields, pu.skipEmptyResults, pu.iff, pf)
+}
+
+func (pu *pipeUnpackKV) hasFilterInWithQuery() bool {
+	return pu.iff.hasFilterInWithQuery()
+}
+
+func (pu *pipeUnpackKV) initFilterInValues(cache *inValuesCache, getFieldValuesFunc getFieldValuesFunc, keepSubquery bool) (pipe, error) {
+	iffNew, err := pu.iff.initFilterInValues(cache, getFieldValuesFunc, keepSubquery)
+	if err != nil {
+		return nil, err
+	}
+	puNew := *pu
+	puNew.iff = iffNew
+	return &puNew, nil
+}
+
+func (pu *pipeUnpackKV) visitSubqueries(visitFunc func(q *Query)) {
+	pu.iff.visitSubqueries(visitFunc)
+}
+
+func (pu *pipeUnpackKV) newPipeProcessor(_ int, _ <-chan struct{}, _ func(), ppNext pipeProcessor) pipeProcessor {
+	unpackKV := func(uctx *fieldsUnpackerContext, s string) {
+		p := getKVParser()
+
+		p.parse(s, pu.pairDelim, pu.kvDelim)
+		uctx.addFieldsWithFilters(p.fields, pu.fieldFilters)
+
+		putKVParser(p)
+	}
+
+	return newPipeUnpackProcessor(unpackKV, ppNext, pu.fromField, pu.resultPrefix, pu.keepOriginalFields, pu.skipEmptyResults, pu.iff)
+}
+
+type kvParser struct {
+	fields []Field
+}
+
+func (p *kvParser) reset() {
+	clear(p.fields)
+	p.fields = p.fields[:0]
+}
+
+// parse parses key-value pairs from s.
+//
+// Pairs are delimited by pairDelim, while keys are delimited from values by kvDelim.
+// Values may be quoted. Pairs without kvDelim are skipped.
+func (p *kvParser) parse(s, pairDelim, kvDelim string) {
+	p.reset()
+
+	// Whitespace around keys and values is ignored, unless it is a part of delimiters.
+	trimSpace := !strings.HasPrefix(pairDelim, " ") && !strings.HasPrefix(kvDelim, " ")
+
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ")
+
+		n := strings.Index(s, kvDelim)
+		m := strings.Index(s, pairDelim)
+		if n < 0 || m >= 0 && m < n {
+			// The pair without kvDelim
+			if m < 0 {
+				return
+			}
+			s = s[m+len(pairDelim):]
+			continue
+		}
+
+		name := strings.TrimSpace(s[:n])
+		s = s[n+len(kvDelim):]
+		if trimSpace {
+			s = strings.TrimLeft(s, " ")
+		}
+
+		value := ""
+		if v, nOffset := tryUnquoteString(s, ""); nOffset >= 0 {
+			value = v
+			s = s[nOffset:]
+			m = strings.Index(s, pairDelim)
+			if m < 0 {
+				s = ""
+			} else {
+				s = s[m+len(pairDelim):]
+			}
+		} else {
+			m = strings.Index(s, pairDelim)
+			if m < 0 {
+				value = s
+				s = ""
+			} else {
+				value = s[:m]
+				s = s[m+len(pairDelim):]
+			}
+			if trimSpace {
+				value = strings.TrimRight(value, " ")
+			}
+		}
+
+		if name != "" {
+			p.fields = append(p.fields, Field{
+				Name:  name,
+				Value: value,
+			})
+		}
+	}
+}
+
+func getKVParser() *kvParser {
+	v := kvParserPool.Get()
+	if v == nil {
+		return &kvParser{}
+	}
+	return v.(*kvParser)
+}
+
+func putKVParser(p *kvParser) {
+	p.reset()
+	kvParserPool.Put(p)
+}
+
+var kvParserPool sync.Pool
+
+func parsePipeUnpackKV(lex *lexer) (pipe, error) {
+	if !lex.isKeyword("unpack_kv") {
+		return nil, fmt.Errorf("unexpected token: %q; want %q", lex.token, "unpack_kv")
+	}
+	lex.nextToken()
+
+	var iff *ifFilter
+	if lex.isKeyword("if") {
+		f, err := parseIfFilter(lex)
+		if err != nil {
+			return nil, err
+		}
+		iff = f
+	}
+
+	fromField := "_msg"
+	if !lex.isKeyword("fields", "pair_delim", "kv_delim", "result_prefix", "keep_original_fields", "skip_empty_results", ")", "|", "") {
+		if lex.isKeyword("from") {
+			lex.nextToken()
+		}
+		f, err := parseFieldName(lex)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse 'from' field name: %w", err)
+		}
+		fromField = f
+	}
+
+	var fieldFilters []string
+	if lex.isKeyword("fields") {
+		lex.nextToken()
+		fs, err := parseFieldFiltersInParens(lex)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse 'fields': %w", err)
+		}
+		fieldFilters = fs
+	}
+	if len(fieldFilters) == 0 {
+		fieldFilters = []string{"*"}
+	}
+
+	pairDelim := " "
+	if lex.isKeyword("pair_delim") {
+		lex.nextToken()
+		d, err := lex.nextCompoundToken()
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse 'pair_delim': %w", err)
+		}
+		if d == "" {
+			return nil, fmt.Errorf("'pair_delim' cannot be empty")
+		}
+		pairDelim = d
+	}
+
+	kvDelim := "="
+	if lex.isKeyword("kv_delim") {
+		lex.nextToken()
+		d, err := lex.nextCompoundToken()
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse 'kv_delim': %w", err)
+		}
+		if d == "" {
+			return nil, fmt.Errorf("'kv_delim' cannot be empty")
+		}
+		kvDelim = d
+	}
+
+	if pairDelim == kvDelim {
+		return nil, fmt.Errorf("'pair_delim' and 'kv_delim' must differ; got %q for both of them", pairDelim)
+	}
+
+	resultPrefix := ""
+	if lex.isKeyword("result_prefix") {
+		lex.nextToken()
+		p, err := lex.nextCompoundToken()
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse 'result_prefix': %w", err)
+		}
+		resultPrefix = p
+	}
+
+	keepOriginalFields := false
+	skipEmptyResults := false
+	switch {
+	case lex.isKeyword("keep_original_fields"):
+		lex.nextToken()
+		keepOriginalFields = true
+	case lex.isKeyword("skip_empty_results"):
+		lex.nextToken()
+		skipEmptyResults = true
+	}
+
+	pu := &pipeUnpackKV{
+		fromField:          fromField,
+		fieldFilters:       fieldFilters,
+		pairDelim:          pairDelim,
+		kvDelim:            kvDelim,
+		resultPrefix:       resultPrefix,
+		keepOriginalFields: keepOriginalFields,
+		skipEmptyResults:   skipEmptyResults,
+		iff:                iff,
+	}
+
+	return pu, nil
+}
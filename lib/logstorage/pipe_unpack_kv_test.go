@@ -0,0 +1,169 @@
+package logstorage
+
+import (
+	"testing"
+)
+
+func TestParsePipeUnpackKVSuccess(t *testing.T) {
+	f := func(pipeStr string) {
+		t.Helper()
+		expectParsePipeSuccess(t, pipeStr)
+	}
+
+	f(`unpack_kv`)
+	f(`unpack_kv skip_empty_results`)
+	f(`unpack_kv keep_original_fields`)
+	f(`unpack_kv fields (a, b*)`)
+	f(`unpack_kv pair_delim ";"`)
+	f(`unpack_kv kv_delim ":"`)
+	f(`unpack_kv pair_delim ", " kv_delim "=>"`)
+	f(`unpack_kv if (a:x) from x fields (a, b) pair_delim ";" kv_delim ":" result_prefix abc skip_empty_results`)
+	f(`unpack_kv if (a:x) from x fields (a, b) result_prefix abc keep_original_fields`)
+	f(`unpack_kv from x pair_delim "&"`)
+}
+
+func TestParsePipeUnpackKVFailure(t *testing.T) {
+	f := func(pipeStr string) {
+		t.Helper()
+		expectParsePipeFailure(t, pipeStr)
+	}
+
+	f(`unpack_kv foo,`)
+	f(`unpack_kv fields`)
+	f(`unpack_kv if`)
+	f(`unpack_kv from`)
+	f(`unpack_kv from x y`)
+	f(`unpack_kv pair_delim`)
+	f(`unpack_kv pair_delim ""`)
+	f(`unpack_kv kv_delim`)
+	f(`unpack_kv kv_delim ""`)
+	f(`unpack_kv pair_delim ";" kv_delim ";"`)
+	f(`unpack_kv kv_delim " "`)
+	f(`unpack_kv result_prefix`)
+	f(`unpack_kv result_prefix a b`)
+}
+
+func TestPipeUnpackKV(t *testing.T) {
+	f := func(pipeStr string, rows, rowsExpected [][]Field) {
+		t.Helper()
+		expectPipeResults(t, pipeStr, rows, rowsExpected)
+	}
+
+	// default delimiters
+	f("unpack_kv", [][]Field{
+		{
+			{"_msg", `foo=bar baz="x y=z" a=b`},
+			{"baz", "abcdef"},
+		},
+	}, [][]Field{
+		{
+			{"_msg", `foo=bar baz="x y=z" a=b`},
+			{"foo", "bar"},
+			{"baz", "x y=z"},
+			{"a", "b"},
+		},
+	})
+
+	// custom delimiters
+	f(`unpack_kv pair_delim ";" kv_delim ":"`, [][]Field{
+		{
+			{"_msg", `user: john doe; action:"login; ok" ;  ip:1.2.3.4;broken`},
+		},
+	}, [][]Field{
+		{
+			{"_msg", `user: john doe; action:"login; ok" ;  ip:1.2.3.4;broken`},
+			{"user", "john doe"},
+			{"action", "login; ok"},
+			{"ip", "1.2.3.4"},
+		},
+	})
+
+	// a subset of fields
+	f(`unpack_kv from x fields (foo, a, b) pair_delim "&"`, [][]Field{
+		{
+			{"x", `foo=bar&baz=x&a=b`},
+		},
+	}, [][]Field{
+		{
+			{"x", `foo=bar&baz=x&a=b`},
+			{"foo", "bar"},
+			{"a", "b"},
+			{"b", ""},
+		},
+	})
+
+	// result_prefix and skip_empty_results
+	f(`unpack_kv result_prefix "qwe_" skip_empty_results`, [][]Field{
+		{
+			{"_msg", `foo= a=b`},
+			{"qwe_foo", "abc"},
+		},
+	}, [][]Field{
+		{
+			{"_msg", `foo= a=b`},
+			{"qwe_foo", "abc"},
+			{"qwe_a", "b"},
+		},
+	})
+
+	// keep original fields
+	f("unpack_kv keep_original_fields", [][]Field{
+		{
+			{"_msg", `foo=bar a=b`},
+			{"a", "xxx"},
+		},
+	}, [][]Field{
+		{
+			{"_msg", `foo=bar a=b`},
+			{"foo", "bar"},
+			{"a", "xxx"},
+		},
+	})
+}
+
+func TestPipeUnpackKVUpdateNeededFields(t *testing.T) {
+	f := func(s string, allowFilters, denyFilters, allowFiltersExpected, denyFiltersExpected string) {
+		t.Helper()
+		expectPipeNeededFields(t, s, allowFilters, denyFilters, allowFiltersExpected, denyFiltersExpected)
+	}
+
+	// all the needed fields
+	f("unpack_kv", "*", "", "*", "")
+	f("unpack_kv fields (f1, f2)", "*", "", "*", "f1,f2")
+	f("unpack_kv fields (f1, f2) keep_original_fields", "*", "", "*", "")
+	f(`unpack_kv if (y:z) from x pair_delim ";"`, "*", "", "*", "")
+
+	// needed fields intersect with src
+	f("unpack_kv from x", "f2,x", "", "f2,x", "")
+	f("unpack_kv if (y:z) from x", "f2,x", "", "f2,x,y", "")
+
+	// query contains 'result_prefix'
+	f("unpack_kv from x result_prefix foo_", "foo*", "", "foo*,x", "")
+	f("unpack_kv from x fields (f1,f2) result_prefix foo_", "*", "", "*", "foo_f1,foo_f2")
+}
+
+func TestKVParser(t *testing.T) {
+	f := func(s, pairDelim, kvDelim, resultExpected string) {
+		t.Helper()
+
+		p := getKVParser()
+		defer putKVParser(p)
+
+		p.parse(s, pairDelim, kvDelim)
+		result := MarshalFieldsToLogfmt(nil, p.fields)
+		if string(result) != resultExpected {
+			t.Fatalf("unexpected result when parsing [%s]\ngot\n%s\nwant\n%s", s, result, resultExpected)
+		}
+	}
+
+	f(``, " ", "=", ``)
+	f(`foo`, " ", "=", ``)
+	f(`foo=bar`, " ", "=", `foo=bar`)
+	f(`foo= bar=baz`, " ", "=", `foo= bar=baz`)
+	f(`  foo=bar  x y=z =q`, " ", "=", `foo=bar y=z`)
+	f(`foo="bar baz" x='y z'`, " ", "=", `foo="bar baz" x="y z"`)
+	f(`foo="bar"baz x=y`, " ", "=", `foo=bar x=y`)
+	f(`a = 1 ; b= "x;y" ;c=`, ";", "=", `a=1 b=x;y c=`)
+	f(`a=>1, b=>2`, ", ", "=>", `a=1 b=2`)
+	f(`a:1|b:2:3`, "|", ":", `a=1 b=2:3`)
+}
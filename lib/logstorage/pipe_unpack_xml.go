@@ -0,0 +1,292 @@
+package logstorage
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/prefixfilter"
+)
+
+// pipeUnpackXML processes '| unpack_xml ...' pipe.
+//
+// See https://docs.victoriametrics.com/victorialogs/logsql/#unpack_xml-pipe
+type pipeUnpackXML struct {
+	// fromField is the field to unpack XML fields from
+	fromField string
+
+	// filterFields is list of field filters to extract from XML.
+	fieldFilters []string
+
+	// resultPrefix is prefix to add to unpacked field names
+	resultPrefix string
+
+	keepOriginalFields bool
+	skipEmptyResults   bool
+
+	// iff is an optional filter for skipping unpacking XML
+	iff *ifFilter
+}
+
+func (pu *pipeUnpackXML) String() string {
+	s := "unpack_xml"
+	if pu.iff != nil {
+		s += " " + pu.iff.String()
+	}
+	if !isMsgFieldName(pu.fromField) {
+		s += " from " + quoteTokenIfNeeded(pu.fromField)
+	}
+	if !prefixfilter.MatchAll(pu.fieldFilters) {
+		s += " fields (" + fieldNamesString(pu.fieldFilters) + ")"
+	}
+	if pu.resultPrefix != "" {
+		s += " result_prefix " + quoteTokenIfNeeded(pu.resultPrefix)
+	}
+	if pu.keepOriginalFields {
+		s += " keep_original_fields"
+	}
+	if pu.skipEmptyResults {
+		s += " skip_empty_results"
+	}
+	return s
+}
+
+func (pu *pipeUnpackXML) splitToRemoteAndLocal(_ int64) (pipe, []pipe) {
+	return pu, nil
+}
+
+func (pu *pipeUnpackXML) canLiveTail() bool {
+	return true
+}
+
+func (pu *pipeUnpackXML) canReturnLastNResults() bool {
+	// TODO: verify that the unpacked fields do not overwrite _time with non-timestamp values.
+
+	return true
+}
+
+func (pu *pipeUnpackXML) updateNeededFields(pf *prefixfilter.Filter) {
+	updateNeededFieldsForUnpackPipe(pu.fromField, pu.resultPrefix, pu.fieldFilters, pu.keepOriginalFields, pu.skipEmptyResults, pu.iff, pf)
+}
+
+func (pu *pipeUnpackXML) hasFilterInWithQuery() bool {
+	return pu.iff.hasFilterInWithQuery()
+}
+
+func (pu *pipeUnpackXML) initFilterInValues(cache *inValuesCache, getFieldValuesFunc getFieldValuesFunc, keepSubquery bool) (pipe, error) {
+	iffNew, err := pu.iff.initFilterInValues(cache, getFieldValuesFunc, keepSubquery)
+	if err != nil {
+		return nil, err
+	}
+	puNew := *pu
+	puNew.iff = iffNew
+	return &puNew, nil
+}
+
+func (pu *pipeUnpackXML) visitSubqueries(visitFunc func(q *Query)) {
+	pu.iff.visitSubqueries(visitFunc)
+}
+
+func (pu *pipeUnpackXML) newPipeProcessor(_ int, _ <-chan struct{}, _ func(), ppNext pipeProcessor) pipeProcessor {
+	unpackXML := func(uctx *fieldsUnpackerContext, s string) {
+		p := getXMLParser()
+
+		if err := p.parse(s); err != nil {
+			// Add empty values for the requested fields on invalid XML
+			p.reset()
+		}
+		uctx.addFieldsWithFilters(p.fields, pu.fieldFilters)
+
+		putXMLParser(p)
+	}
+
+	return newPipeUnpackProcessor(unpackXML, ppNext, pu.fromField, pu.resultPrefix, pu.keepOriginalFields, pu.skipEmptyResults, pu.iff)
+}
+
+// xmlParser flattens XML documents into fields.
+//
+// Nested element names are joined with '.' starting from the root element, e.g. <a><b>foo</b></a> is converted to a.b=foo.
+// Attributes are stored in fields with the '<element_path>.<attr_name>' names.
+// Repeated sibling elements with the same name get numeric suffixes starting from 1, e.g. a.b, a.b.1, a.b.2.
+type xmlParser struct {
+	fields []Field
+
+	stack []xmlParserElement
+}
+
+type xmlParserElement struct {
+	path        string
+	text        []byte
+	hasChildren bool
+	hasAttrs    bool
+
+	// childNames contains the number of children per each child name.
+	childNames map[string]int
+}
+
+func (p *xmlParser) reset() {
+	clear(p.fields)
+	p.fields = p.fields[:0]
+
+	clear(p.stack)
+	p.stack = p.stack[:0]
+}
+
+func (p *xmlParser) addField(name, value string) {
+	p.fields = append(p.fields, Field{
+		Name:  name,
+		Value: value,
+	})
+}
+
+func (p *xmlParser) parse(s string) error {
+	p.reset()
+
+	d := xml.NewDecoder(strings.NewReader(s))
+	for {
+		t, err := d.Token()
+		if err != nil {
+			if err == io.EOF {
+				if len(p.stack) > 0 {
+					return fmt.Errorf("missing closing tag for %q", p.stack[len(p.stack)-1].path)
+				}
+				return nil
+			}
+			return err
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			path := t.Name.Local
+			if len(p.stack) > 0 {
+				parent := &p.stack[len(p.stack)-1]
+				parent.hasChildren = true
+				if parent.childNames == nil {
+					parent.childNames = make(map[string]int)
+				}
+				n := parent.childNames[path]
+				parent.childNames[path] = n + 1
+				path = parent.path + "." + path
+				if n > 0 {
+					path += "." + strconv.Itoa(n)
+				}
+			}
+			hasAttrs := false
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "xmlns" || attr.Name.Space == "" && attr.Name.Local == "xmlns" {
+					// Skip namespace declarations
+					continue
+				}
+				p.addField(path+"."+attr.Name.Local, attr.Value)
+				hasAttrs = true
+			}
+			p.stack = append(p.stack, xmlParserElement{
+				path:     path,
+				hasAttrs: hasAttrs,
+			})
+		case xml.CharData:
+			if len(p.stack) > 0 {
+				e := &p.stack[len(p.stack)-1]
+				e.text = append(e.text, t...)
+			}
+		case xml.EndElement:
+			e := &p.stack[len(p.stack)-1]
+			text := strings.TrimSpace(string(e.text))
+			if text != "" || !e.hasChildren && !e.hasAttrs {
+				p.addField(e.path, text)
+			}
+			p.stack = p.stack[:len(p.stack)-1]
+		}
+	}
+}
+
+func getXMLParser() *xmlParser {
+	v := xmlParserPool.Get()
+	if v == nil {
+		return &xmlParser{}
+	}
+	return v.(*xmlParser)
+}
+
+func putXMLParser(p *xmlParser) {
+	p.reset()
+	xmlParserPool.Put(p)
+}
+
+var xmlParserPool sync.Pool
+
+func parsePipeUnpackXML(lex *lexer) (pipe, error) {
+	if !lex.isKeyword("unpack_xml") {
+		return nil, fmt.Errorf("unexpected token: %q; want %q", lex.token, "unpack_xml")
+	}
+	lex.nextToken()
+
+	var iff *ifFilter
+	if lex.isKeyword("if") {
+		f, err := parseIfFilter(lex)
+		if err != nil {
+			return nil, err
+		}
+		iff = f
+	}
+
+	fromField := "_msg"
+	if !lex.isKeyword("fields", "result_prefix", "keep_original_fields", "skip_empty_results", ")", "|", "") {
+		if lex.isKeyword("from") {
+			lex.nextToken()
+		}
+		f, err := parseFieldName(lex)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse 'from' field name: %w", err)
+		}
+		fromField = f
+	}
+
+	var fieldFilters []string
+	if lex.isKeyword("fields") {
+		lex.nextToken()
+		fs, err := parseFieldFiltersInParens(lex)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse 'fields': %w", err)
+		}
+		fieldFilters = fs
+	}
+	if len(fieldFilters) == 0 {
+		fieldFilters = []string{"*"}
+	}
+
+	resultPrefix := ""
+	if lex.isKeyword("result_prefix") {
+		lex.nextToken()
+		p, err := lex.nextCompoundToken()
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse 'result_prefix': %w", err)
+		}
+		resultPrefix = p
+	}
+
+	keepOriginalFields := false
+	skipEmptyResults := false
+	switch {
+	case lex.isKeyword("keep_original_fields"):
+		lex.nextToken()
+		keepOriginalFields = true
+	case lex.isKeyword("skip_empty_results"):
+		lex.nextToken()
+		skipEmptyResults = true
+	}
+
+	pu := &pipeUnpackXML{
+		fromField:          fromField,
+		fieldFilters:       fieldFilters,
+		resultPrefix:       resultPrefix,
+		keepOriginalFields: keepOriginalFields,
+		skipEmptyResults:   skipEmptyResults,
+		iff:                iff,
+	}
+
+	return pu, nil
+}
@@ -0,0 +1,138 @@
+package logstorage
+
+import (
+	"testing"
+)
+
+func TestParsePipeUnpackXMLSuccess(t *testing.T) {
+	f := func(pipeStr string) {
+		t.Helper()
+		expectParsePipeSuccess(t, pipeStr)
+	}
+
+	f(`unpack_xml`)
+	f(`unpack_xml skip_empty_results`)
+	f(`unpack_xml keep_original_fields`)
+	f(`unpack_xml fields (a, b*)`)
+	f(`unpack_xml if (a:x)`)
+	f(`unpack_xml from x`)
+	f(`unpack_xml if (a:x) from x fields (a, b) result_prefix abc skip_empty_results`)
+	f(`unpack_xml if (a:x) from x fields (a, b) result_prefix abc keep_original_fields`)
+	f(`unpack_xml result_prefix abc`)
+}
+
+func TestParsePipeUnpackXMLFailure(t *testing.T) {
+	f := func(pipeStr string) {
+		t.Helper()
+		expectParsePipeFailure(t, pipeStr)
+	}
+
+	f(`unpack_xml foo,`)
+	f(`unpack_xml fields`)
+	f(`unpack_xml if`)
+	f(`unpack_xml from`)
+	f(`unpack_xml from x y`)
+	f(`unpack_xml result_prefix`)
+	f(`unpack_xml result_prefix a b`)
+}
+
+func TestPipeUnpackXML(t *testing.T) {
+	f := func(pipeStr string, rows, rowsExpected [][]Field) {
+		t.Helper()
+		expectPipeResults(t, pipeStr, rows, rowsExpected)
+	}
+
+	// Windows event
+	f("unpack_xml", [][]Field{
+		{
+			{"_msg", `<Event xmlns="http://schemas.microsoft.com/win/2004/08/events/event"><System><Provider Name="Security"/><EventID>4624</EventID></System>` +
+				`<EventData><Data Name="TargetUserName">bob</Data><Data Name="LogonType">3</Data></EventData></Event>`},
+		},
+	}, [][]Field{
+		{
+			{"_msg", `<Event xmlns="http://schemas.microsoft.com/win/2004/08/events/event"><System><Provider Name="Security"/><EventID>4624</EventID></System>` +
+				`<EventData><Data Name="TargetUserName">bob</Data><Data Name="LogonType">3</Data></EventData></Event>`},
+			{"Event.System.Provider.Name", "Security"},
+			{"Event.System.EventID", "4624"},
+			{"Event.EventData.Data.Name", "TargetUserName"},
+			{"Event.EventData.Data", "bob"},
+			{"Event.EventData.Data.1.Name", "LogonType"},
+			{"Event.EventData.Data.1", "3"},
+		},
+	})
+
+	// a subset of fields from the given field
+	f("unpack_xml from x fields (a.b, a.c, a.d)", [][]Field{
+		{
+			{"x", `<a><b>foo</b><c/><e>bar</e></a>`},
+		},
+	}, [][]Field{
+		{
+			{"x", `<a><b>foo</b><c/><e>bar</e></a>`},
+			{"a.b", "foo"},
+			{"a.c", ""},
+			{"a.d", ""},
+		},
+	})
+
+	// invalid XML
+	f("unpack_xml fields (a.b)", [][]Field{
+		{
+			{"_msg", `<a><b>foo</b>`},
+			{"a.b", "bar"},
+		},
+	}, [][]Field{
+		{
+			{"_msg", `<a><b>foo</b>`},
+			{"a.b", ""},
+		},
+	})
+
+	// result_prefix and skip_empty_results
+	f(`unpack_xml result_prefix "qwe_" skip_empty_results`, [][]Field{
+		{
+			{"_msg", `<a><b> foo </b><c></c></a>`},
+			{"qwe_a.c", "abc"},
+		},
+	}, [][]Field{
+		{
+			{"_msg", `<a><b> foo </b><c></c></a>`},
+			{"qwe_a.b", "foo"},
+			{"qwe_a.c", "abc"},
+		},
+	})
+
+	// keep original fields
+	f("unpack_xml keep_original_fields", [][]Field{
+		{
+			{"_msg", `<a x="1"><b>foo</b></a>`},
+			{"a.b", "bar"},
+		},
+	}, [][]Field{
+		{
+			{"_msg", `<a x="1"><b>foo</b></a>`},
+			{"a.x", "1"},
+			{"a.b", "bar"},
+		},
+	})
+}
+
+func TestPipeUnpackXMLUpdateNeededFields(t *testing.T) {
+	f := func(s string, allowFilters, denyFilters, allowFiltersExpected, denyFiltersExpected string) {
+		t.Helper()
+		expectPipeNeededFields(t, s, allowFilters, denyFilters, allowFiltersExpected, denyFiltersExpected)
+	}
+
+	// all the needed fields
+	f("unpack_xml", "*", "", "*", "")
+	f("unpack_xml fields (f1, f2)", "*", "", "*", "f1,f2")
+	f("unpack_xml fields (f1, f2) skip_empty_results", "*", "", "*", "")
+	f("unpack_xml if (y:z) from x", "*", "", "*", "")
+
+	// needed fields intersect with src
+	f("unpack_xml from x", "f2,x", "", "f2,x", "")
+	f("unpack_xml if (y:z) from x", "f2,x", "", "f2,x,y", "")
+
+	// query contains 'result_prefix'
+	f("unpack_xml from x result_prefix foo_", "foo*", "", "foo*,x", "")
+}
@@ -51,6 +51,12 @@ func expectPipeResults(t *testing.T, pipeStr string, rows, rowsExpected [][]Fiel
 		t.Fatalf("unexpected error when parsing %q: %s", pipeStr, err)
 	}
 
+	expectPipeResultsForPipe(t, p, rows, rowsExpected)
+}
+
+func expectPipeResultsForPipe(t *testing.T, p pipe, rows, rowsExpected [][]Field) {
+	t.Helper()
+
 	workersCount := 5
 	stopCh := make(chan struct{})
 	cancel := func() {}
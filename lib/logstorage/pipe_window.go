@@ -0,0 +1,379 @@
+package logstorage
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/memory"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/slicesutil"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/prefixfilter"
+)
+
+// pipeWindow processes '| window ...' queries.
+//
+// See https://docs.victoriametrics.com/victorialogs/logsql/#window-pipe
+type pipeWindow struct {
+	// byFields contains field names from 'by(...)' clause.
+	byFields []string
+
+	// funcs contains window functions to execute.
+	funcs []pipeWindowFunc
+
+	// ps sorts the rows by byFields and then by _time, so window functions could be applied to rows in the sorted order.
+	ps *pipeSort
+}
+
+type pipeWindowFunc struct {
+	// f is window function to execute
+	f windowFunc
+
+	// resultName is the name of the output generated by f
+	resultName string
+}
+
+type windowFunc interface {
+	// String returns string representation of windowFunc
+	String() string
+
+	// updateNeededFields must update pf with the fields needed for calculating the given window function
+	updateNeededFields(pf *prefixfilter.Filter)
+
+	// getResult must return the result for rows[rowIdx].
+	//
+	// rows contain all the rows for the current 'by(...)' group sorted by _time.
+	getResult(rows [][]Field, rowIdx int) string
+}
+
+func (pw *pipeWindow) String() string {
+	s := "window"
+	if len(pw.byFields) > 0 {
+		s += " by (" + fieldNamesString(pw.byFields) + ")"
+	}
+
+	funcs := pw.funcs
+	if len(funcs) == 0 {
+		logger.Panicf("BUG: pipeWindow must contain at least a single windowFunc")
+	}
+	a := make([]string, len(funcs))
+	for i, f := range funcs {
+		a[i] = fmt.Sprintf("%s as %s", f.f.String(), quoteTokenIfNeeded(f.resultName))
+	}
+	s += " " + strings.Join(a, ", ")
+	return s
+}
+
+func (pw *pipeWindow) splitToRemoteAndLocal(_ int64) (pipe, []pipe) {
+	return nil, []pipe{pw}
+}
+
+func (pw *pipeWindow) canLiveTail() bool {
+	return false
+}
+
+func (pw *pipeWindow) canReturnLastNResults() bool {
+	return false
+}
+
+func (pw *pipeWindow) updateNeededFields(pf *prefixfilter.Filter) {
+	pfOrig := pf.Clone()
+
+	for _, f := range pw.funcs {
+		pf.AddDenyFilter(f.resultName)
+		if pfOrig.MatchString(f.resultName) {
+			f.f.updateNeededFields(pf)
+		}
+	}
+
+	// byFields and _time are needed unconditionally, since they define the order of rows.
+	pf.AddAllowFilters(pw.byFields)
+	pf.AddAllowFilter("_time")
+}
+
+func (pw *pipeWindow) hasFilterInWithQuery() bool {
+	return false
+}
+
+func (pw *pipeWindow) initFilterInValues(_ *inValuesCache, _ getFieldValuesFunc, _ bool) (pipe, error) {
+	return pw, nil
+}
+
+func (pw *pipeWindow) visitSubqueries(_ func(q *Query)) {
+	// nothing to do
+}
+
+func (pw *pipeWindow) newPipeProcessor(_ int, stopCh <-chan struct{}, cancel func(), ppNext pipeProcessor) pipeProcessor {
+	maxStateSize := int64(float64(memory.Allowed()) * 0.2)
+
+	pww := &pipeWindowWriter{
+		pw:     pw,
+		stopCh: stopCh,
+		cancel: cancel,
+
+		maxStateSize: maxStateSize,
+
+		wctx: pipeRunningStatsWriter{
+			ppNext: ppNext,
+		},
+	}
+	return &pipeWindowProcessor{
+		psp: newPipeSortProcessor(pw.ps, stopCh, cancel, pww),
+		pww: pww,
+	}
+}
+
+// pipeWindowProcessor sorts the incoming rows via psp and then passes them in the sorted order to pww.
+type pipeWindowProcessor struct {
+	psp pipeProcessor
+	pww *pipeWindowWriter
+}
+
+func (pwp *pipeWindowProcessor) writeBlock(workerID uint, br *blockResult) {
+	pwp.psp.writeBlock(workerID, br)
+}
+
+func (pwp *pipeWindowProcessor) flush() error {
+	if err := pwp.psp.flush(); err != nil {
+		return err
+	}
+	return pwp.pww.flush()
+}
+
+// pipeWindowWriter receives rows sorted by 'by(...)' fields and by _time,
+// applies window functions to them and writes the results to the next pipe.
+//
+// All the pipeWindowWriter methods are called from a single goroutine.
+type pipeWindowWriter struct {
+	pw     *pipeWindow
+	stopCh <-chan struct{}
+	cancel func()
+
+	// maxStateSize is the maximum size of rows, which can be collected for a single 'by(...)' key.
+	maxStateSize int64
+
+	// stateSize is the size of the collected rows for the current key.
+	stateSize int64
+
+	// stateSizeExceeded is set to true when the collected rows for the current key exceed maxStateSize.
+	stateSizeExceeded bool
+
+	// key is the 'by(...)' key for the currently collected rows.
+	key []byte
+
+	// keyBuf is a temporary buffer for constructing 'by(...)' keys.
+	keyBuf []byte
+
+	// rows contains rows for the current key.
+	rows [][]Field
+
+	columnValues [][]string
+
+	wctx pipeRunningStatsWriter
+}
+
+func (pww *pipeWindowWriter) writeBlock(_ uint, br *blockResult) {
+	if br.rowsLen == 0 || pww.stateSizeExceeded || needStop(pww.stopCh) {
+		return
+	}
+
+	cs := br.getColumns()
+	columnValues := slicesutil.SetLength(pww.columnValues, len(cs))
+	for i, c := range cs {
+		columnValues[i] = c.getValues(br)
+	}
+	pww.columnValues = columnValues
+
+	byColumnValues := make([][]string, len(pww.pw.byFields))
+	for i, bf := range pww.pw.byFields {
+		c := br.getColumnByName(bf)
+		byColumnValues[i] = c.getValues(br)
+	}
+
+	for rowIdx := 0; rowIdx < br.rowsLen; rowIdx++ {
+		keyBuf := pww.keyBuf[:0]
+		for _, values := range byColumnValues {
+			keyBuf = encoding.MarshalBytes(keyBuf, bytesutil.ToUnsafeBytes(values[rowIdx]))
+		}
+		pww.keyBuf = keyBuf
+
+		if len(pww.rows) > 0 && string(pww.key) != string(keyBuf) {
+			pww.flushRows()
+		}
+		pww.key = append(pww.key[:0], keyBuf...)
+
+		fields := make([]Field, len(cs))
+		for j, c := range cs {
+			v := columnValues[j][rowIdx]
+			fields[j] = Field{
+				Name:  strings.Clone(c.name),
+				Value: strings.Clone(v),
+			}
+			pww.stateSize += int64(len(c.name) + len(v))
+		}
+		pww.rows = append(pww.rows, fields)
+		pww.stateSize += int64(unsafe.Sizeof(fields)) + int64(len(fields))*int64(unsafe.Sizeof(fields[0]))
+
+		if pww.stateSize > pww.maxStateSize {
+			// The rows for the current key are too big. Stop processing data in order to avoid OOM crash.
+			pww.stateSizeExceeded = true
+			pww.resetRows()
+			pww.cancel()
+			return
+		}
+	}
+}
+
+// flushRows applies window functions to the collected rows for the current key and writes the results to the next pipe.
+func (pww *pipeWindowWriter) flushRows() {
+	funcs := pww.pw.funcs
+	rows := pww.rows
+	for rowIdx, row := range rows {
+		if needStop(pww.stopCh) {
+			break
+		}
+
+		fields := make([]Field, 0, len(row)+len(funcs))
+		fields = append(fields, row...)
+		for _, f := range funcs {
+			fields = append(fields, Field{
+				Name:  f.resultName,
+				Value: f.f.getResult(rows, rowIdx),
+			})
+		}
+		pww.wctx.writeRow(fields)
+	}
+
+	pww.resetRows()
+}
+
+func (pww *pipeWindowWriter) resetRows() {
+	clear(pww.rows)
+	pww.rows = pww.rows[:0]
+	pww.stateSize = 0
+}
+
+func (pww *pipeWindowWriter) flush() error {
+	if pww.stateSizeExceeded {
+		return fmt.Errorf("cannot calculate [%s], since it requires more than %dMB of memory", pww.pw.String(), pww.maxStateSize/(1<<20))
+	}
+	if needStop(pww.stopCh) {
+		return nil
+	}
+	pww.flushRows()
+	pww.wctx.flush()
+	return nil
+}
+
+func parsePipeWindow(lex *lexer) (pipe, error) {
+	if !lex.isKeyword("window") {
+		return nil, fmt.Errorf("expecting `window`; got %q", lex.token)
+	}
+	lex.nextToken()
+
+	var pw pipeWindow
+	if lex.isKeyword("by", "(") {
+		if lex.isKeyword("by") {
+			lex.nextToken()
+		}
+		bfs, err := parseFieldNamesInParens(lex)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse 'by' clause: %w", err)
+		}
+		pw.byFields = bfs
+	}
+
+	seenResultNames := make(map[string]windowFunc)
+
+	for {
+		var f pipeWindowFunc
+
+		wf, err := parseWindowFunc(lex)
+		if err != nil {
+			return nil, err
+		}
+		f.f = wf
+
+		resultName := ""
+		if lex.isKeyword(",", "|", ")", "") {
+			resultName = wf.String()
+		} else {
+			if lex.isKeyword("as") {
+				lex.nextToken()
+			}
+			fieldName, err := parseFieldName(lex)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse result name for [%s]: %w", wf, err)
+			}
+			resultName = fieldName
+		}
+		if wfPrev := seenResultNames[resultName]; wfPrev != nil {
+			return nil, fmt.Errorf("cannot use identical result name %q for [%s] and [%s]", resultName, wfPrev, wf)
+		}
+		seenResultNames[resultName] = wf
+		f.resultName = resultName
+
+		pw.funcs = append(pw.funcs, f)
+
+		if lex.isKeyword("|", ")", "") {
+			break
+		}
+		if !lex.isKeyword(",") {
+			return nil, fmt.Errorf("unexpected token %q after [%s]; want ',', '|' or ')'", lex.token, wf)
+		}
+		lex.nextToken()
+	}
+
+	sortFields := make([]*bySortField, 0, len(pw.byFields)+1)
+	for _, bf := range pw.byFields {
+		sortFields = append(sortFields, &bySortField{
+			name: bf,
+		})
+	}
+	sortFields = append(sortFields, &bySortField{
+		name: "_time",
+	})
+	pw.ps = &pipeSort{
+		byFields: sortFields,
+	}
+
+	return &pw, nil
+}
+
+func parseWindowFunc(lex *lexer) (windowFunc, error) {
+	wps := getWindowFuncParsers()
+	for funcName, parserFunc := range wps {
+		if !lex.isKeyword(funcName) {
+			continue
+		}
+		wf, err := parserFunc(lex)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %q func: %w", funcName, err)
+		}
+		return wf, nil
+	}
+	return nil, fmt.Errorf("unknown window func %q", lex.token)
+}
+
+var windowFuncParsers map[string]windowFuncParser
+var windowFuncParsersOnce sync.Once
+
+type windowFuncParser func(lex *lexer) (windowFunc, error)
+
+func getWindowFuncParsers() map[string]windowFuncParser {
+	windowFuncParsersOnce.Do(initWindowFuncParsers)
+	return windowFuncParsers
+}
+
+func initWindowFuncParsers() {
+	windowFuncParsers = map[string]windowFuncParser{
+		"delta":           parseWindowDelta,
+		"lag":             parseWindowLag,
+		"lead":            parseWindowLead,
+		"time_since_prev": parseWindowTimeSincePrev,
+	}
+}
@@ -0,0 +1,246 @@
+package logstorage
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParsePipeWindowSuccess(t *testing.T) {
+	f := func(pipeStr string) {
+		t.Helper()
+		expectParsePipeSuccess(t, pipeStr)
+	}
+
+	f(`window lag(x) as prev_x`)
+	f(`window by (_stream) lag(x, 2) as prev_x, lead(x) as next_x`)
+	f(`window by (a, b) delta(x) as x_delta, time_since_prev() as gap`)
+	f(`window by (a) lead("foo bar", 3) as "next foo"`)
+}
+
+func TestParsePipeWindowFailure(t *testing.T) {
+	f := func(pipeStr string) {
+		t.Helper()
+		expectParsePipeFailure(t, pipeStr)
+	}
+
+	f(`window`)
+	f(`window by`)
+	f(`window by (x)`)
+	f(`window foo`)
+	f(`window lag`)
+	f(`window lag()`)
+	f(`window lag(x, 0)`)
+	f(`window lag(x, -1)`)
+	f(`window lag(x, foo)`)
+	f(`window lag(x, 1, 2)`)
+	f(`window lead(x*)`)
+	f(`window delta()`)
+	f(`window delta(x, y)`)
+	f(`window time_since_prev(x)`)
+	f(`window by (*) lag(x)`)
+	f(`window by (x*) lag(x)`)
+	f(`window lag(x) as *`)
+	f(`window lag(x) as x*`)
+
+	// duplicate output name
+	f(`window lag(x) y, lead(x) y`)
+}
+
+func TestPipeWindow(t *testing.T) {
+	f := func(pipeStr string, rows, rowsExpected [][]Field) {
+		t.Helper()
+		expectPipeResults(t, pipeStr, rows, rowsExpected)
+	}
+
+	// missing result names
+	f("window lag(a), lead(a, 2), delta(a), time_since_prev()", [][]Field{
+		{
+			{"_time", "2025-07-24T10:20:31Z"},
+			{"a", "5"},
+		},
+		{
+			{"_time", "2025-07-24T10:20:30Z"},
+			{"a", "3"},
+		},
+		{
+			{"_time", "2025-07-24T10:20:32.5Z"},
+			{"a", "foo"},
+		},
+	}, [][]Field{
+		{
+			{"_time", "2025-07-24T10:20:30Z"},
+			{"a", "3"},
+			{"lag(a)", ""},
+			{"lead(a, 2)", "foo"},
+			{"delta(a)", ""},
+			{"time_since_prev()", ""},
+		},
+		{
+			{"_time", "2025-07-24T10:20:31Z"},
+			{"a", "5"},
+			{"lag(a)", "3"},
+			{"lead(a, 2)", ""},
+			{"delta(a)", "2"},
+			{"time_since_prev()", "1"},
+		},
+		{
+			{"_time", "2025-07-24T10:20:32.5Z"},
+			{"a", "foo"},
+			{"lag(a)", "5"},
+			{"lead(a, 2)", ""},
+			{"delta(a)", ""},
+			{"time_since_prev()", "1.5"},
+		},
+	})
+
+	// window functions with groupings
+	f("window by (host) lag(status) prev_status, lead(status) next_status, delta(counter) counter_delta, time_since_prev() gap", [][]Field{
+		{
+			{"_time", "2025-07-25T10:20:30Z"},
+			{"host", "foo"},
+			{"status", "running"},
+			{"counter", "10"},
+		},
+		{
+			{"_time", "2025-07-24T10:20:30Z"},
+			{"host", "bar"},
+			{"status", "started"},
+			{"counter", "3"},
+		},
+		{
+			{"_time", "2025-07-24T10:20:30Z"},
+			{"host", "foo"},
+			{"status", "started"},
+			{"counter", "4"},
+		},
+		{
+			{"_time", "2025-07-26T10:20:30Z"},
+			{"host", "foo"},
+			{"status", "stopped"},
+			{"counter", "7.5"},
+		},
+	}, [][]Field{
+		{
+			{"_time", "2025-07-24T10:20:30Z"},
+			{"host", "bar"},
+			{"status", "started"},
+			{"counter", "3"},
+			{"prev_status", ""},
+			{"next_status", ""},
+			{"counter_delta", ""},
+			{"gap", ""},
+		},
+		{
+			{"_time", "2025-07-24T10:20:30Z"},
+			{"host", "foo"},
+			{"status", "started"},
+			{"counter", "4"},
+			{"prev_status", ""},
+			{"next_status", "running"},
+			{"counter_delta", ""},
+			{"gap", ""},
+		},
+		{
+			{"_time", "2025-07-25T10:20:30Z"},
+			{"host", "foo"},
+			{"status", "running"},
+			{"counter", "10"},
+			{"prev_status", "started"},
+			{"next_status", "stopped"},
+			{"counter_delta", "6"},
+			{"gap", "86400"},
+		},
+		{
+			{"_time", "2025-07-26T10:20:30Z"},
+			{"host", "foo"},
+			{"status", "stopped"},
+			{"counter", "7.5"},
+			{"prev_status", "running"},
+			{"next_status", ""},
+			{"counter_delta", "-2.5"},
+			{"gap", "86400"},
+		},
+	})
+}
+
+func TestPipeWindowMaxStateSize(t *testing.T) {
+	lex := newLexer(`window by (a) lag(x) as prev_x`, 0)
+	p, err := parsePipe(lex)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	cancelCalls := 0
+	ppTest := newTestPipeProcessor()
+	pww := &pipeWindowWriter{
+		pw:     p.(*pipeWindow),
+		stopCh: make(chan struct{}),
+		cancel: func() {
+			cancelCalls++
+		},
+		maxStateSize: 1000,
+		wctx: pipeRunningStatsWriter{
+			ppNext: ppTest,
+		},
+	}
+
+	// Rows for distinct keys must fit the limit, since they are flushed individually.
+	brw := newTestBlockResultWriter(1, pww)
+	for i := range 10 {
+		brw.writeRow([]Field{
+			{Name: "a", Value: fmt.Sprintf("key_%d", i)},
+			{Name: "x", Value: "foo"},
+		})
+	}
+	brw.flush()
+	if err := pww.flush(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cancelCalls != 0 {
+		t.Fatalf("unexpected cancel calls: %d", cancelCalls)
+	}
+
+	// Rows for a single key must exceed the limit
+	for range 100 {
+		brw.writeRow([]Field{
+			{Name: "a", Value: "key"},
+			{Name: "x", Value: "foo"},
+		})
+	}
+	brw.flush()
+	if err := pww.flush(); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	if cancelCalls != 1 {
+		t.Fatalf("unexpected cancel calls; got %d; want 1", cancelCalls)
+	}
+}
+
+func TestPipeWindowUpdateNeededFields(t *testing.T) {
+	f := func(s, allowFilters, denyFilters, allowFiltersExpected, denyFiltersExpected string) {
+		t.Helper()
+		expectPipeNeededFields(t, s, allowFilters, denyFilters, allowFiltersExpected, denyFiltersExpected)
+	}
+
+	// all the needed fields
+	f("window lag(f1) r1", "*", "", "*", "r1")
+	f("window lag(r1) r1", "*", "", "*", "")
+	f("window by (b1) lag(f1) r1, delta(f2) r2", "*", "", "*", "r1,r2")
+
+	// all the needed fields, unneeded fields do not intersect with window fields
+	f("window lag(f1) r1", "*", "f2,f3", "*", "f2,f3,r1")
+	f("window by (f3) lag(f1) r1", "*", "f1,f2,f3", "*", "f2,r1")
+	f("window time_since_prev() r1", "*", "_time,f1", "*", "f1,r1")
+
+	// all the needed fields, unneeded fields intersect with window fields
+	f("window lag(f1) r1", "*", "r1,r2", "*", "r1,r2")
+	f("window by (b1) lag(f1) r1", "*", "r1,b1", "*", "r1")
+
+	// needed fields do not intersect with window fields
+	f("window lag(f1) r1", "r2", "", "_time,r2", "")
+	f("window by (b1,b2) lag(f1) r1", "r2", "", "_time,b1,b2,r2", "")
+
+	// needed fields intersect with window fields
+	f("window lag(f1) r1", "r1,r2", "", "_time,f1,r2", "")
+	f("window by (b1) lag(f1) r1, time_since_prev() r2", "r1,r2", "", "_time,b1,f1", "")
+}
@@ -0,0 +1,292 @@
+package logstorage
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// QueryPlan is the execution plan for the query.
+type QueryPlan struct {
+	// Query is the optimized query.
+	Query string `json:"query"`
+
+	// MinTimestamp is the minimum timestamp selected by the query filters in RFC3339 format.
+	//
+	// It is empty if the query doesn't limit the minimum timestamp.
+	MinTimestamp string `json:"min_timestamp,omitempty"`
+
+	// MaxTimestamp is the maximum timestamp selected by the query filters in RFC3339 format.
+	//
+	// It is empty if the query doesn't limit the maximum timestamp.
+	MaxTimestamp string `json:"max_timestamp,omitempty"`
+
+	// StreamFilter is the stream filter, which is used for selecting log streams via the index before scanning the data.
+	//
+	// It is empty if the query doesn't contain top-level stream filter.
+	StreamFilter string `json:"stream_filter,omitempty"`
+
+	// HasNestedStreamFilters is set to true if the query contains stream filters, which are resolved via the index per every partition
+	// and then are applied to every scanned block.
+	HasNestedStreamFilters bool `json:"has_nested_stream_filters"`
+
+	// Filter is the tree of the filters, which are applied to every scanned block after the StreamFilter.
+	Filter *FilterPlan `json:"filter"`
+
+	// RemotePipes contains pipes, which are executed at storage nodes in cluster setup.
+	RemotePipes []string `json:"remote_pipes"`
+
+	// LocalPipes contains pipes, which are executed locally at vlselect in cluster setup after receiving the results from storage nodes.
+	LocalPipes []string `json:"local_pipes"`
+
+	// Analysis contains counters collected during the query execution.
+	//
+	// It is set only if the query is executed in analyze mode.
+	Analysis *QueryAnalysis `json:"analysis,omitempty"`
+}
+
+// FilterPlan is the plan for a single filter.
+type FilterPlan struct {
+	// Type is the filter type such as and, or, not, phrase, etc.
+	Type string `json:"type"`
+
+	// Filter is the string representation of the filter.
+	Filter string `json:"filter"`
+
+	// Children contains child filters for and, or and not filters.
+	Children []*FilterPlan `json:"children,omitempty"`
+}
+
+// Explain returns the execution plan for q.
+func (q *Query) Explain() *QueryPlan {
+	minTimestamp, maxTimestamp := q.GetFilterTimeRange()
+	sf, f := getCommonStreamFilter(q.f)
+
+	qp := &QueryPlan{
+		Query:                  q.String(),
+		HasNestedStreamFilters: hasStreamFilters(f),
+		Filter:                 newFilterPlan(f),
+		RemotePipes:            []string{},
+		LocalPipes:             []string{},
+	}
+	if minTimestamp != math.MinInt64 {
+		qp.MinTimestamp = string(marshalTimestampRFC3339NanoString(nil, minTimestamp))
+	}
+	if maxTimestamp != math.MaxInt64 {
+		qp.MaxTimestamp = string(marshalTimestampRFC3339NanoString(nil, maxTimestamp))
+	}
+	if sf != nil {
+		qp.StreamFilter = sf.String()
+	}
+
+	qRemote, pipesLocal := splitQueryToRemoteAndLocal(q)
+	for _, p := range qRemote.pipes {
+		qp.RemotePipes = append(qp.RemotePipes, p.String())
+	}
+	for _, p := range pipesLocal {
+		qp.LocalPipes = append(qp.LocalPipes, p.String())
+	}
+
+	return qp
+}
+
+func newFilterPlan(f filter) *FilterPlan {
+	fp := &FilterPlan{
+		Type:   getFilterType(f),
+		Filter: f.String(),
+	}
+	switch t := f.(type) {
+	case *filterAnd:
+		for _, f := range t.filters {
+			fp.Children = append(fp.Children, newFilterPlan(f))
+		}
+	case *filterOr:
+		for _, f := range t.filters {
+			fp.Children = append(fp.Children, newFilterPlan(f))
+		}
+	case *filterNot:
+		fp.Children = append(fp.Children, newFilterPlan(t.f))
+	}
+	return fp
+}
+
+// getFilterType returns the type of f without filter prefix, e.g. `phrase` for *filterPhrase.
+func getFilterType(f filter) string {
+	s := fmt.Sprintf("%T", f)
+	s = strings.TrimPrefix(s, "*logstorage.filter")
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[size:]
+}
+
+// QueryAnalysis contains counters collected during the query execution.
+type QueryAnalysis struct {
+	// Duration is the query execution duration.
+	Duration string `json:"duration"`
+
+	// RowsReturned is the number of rows returned by the query.
+	RowsReturned uint64 `json:"rows_returned"`
+
+	// Partitions contains per-partition counters.
+	Partitions []*PartitionAnalysis `json:"partitions"`
+}
+
+// PartitionAnalysis contains counters for the search in a single partition.
+//
+// All the counters must be updated atomically during the search.
+type PartitionAnalysis struct {
+	// Node is the storage node address, which contains the partition. It is set only in cluster setup.
+	Node string `json:"node,omitempty"`
+
+	// Partition is the partition name.
+	Partition string `json:"partition"`
+
+	// StreamIDs is the number of log streams selected via the index for the query stream filter.
+	//
+	// It is set to -1 if the log streams aren't selected via the index.
+	StreamIDs int `json:"stream_ids"`
+
+	// PartsSearched is the number of parts, which match the query time range.
+	PartsSearched uint64 `json:"parts_searched"`
+
+	// IndexBlocksSkippedByHeader is the number of index blocks skipped because their min/max timestamps do not match the query time range.
+	IndexBlocksSkippedByHeader uint64 `json:"index_blocks_skipped_by_header"`
+
+	// BlocksConsidered is the number of blocks, which match the query tenants and log streams.
+	BlocksConsidered uint64 `json:"blocks_considered"`
+
+	// BlocksSkippedByHeader is the number of blocks skipped because their min/max timestamps do not match the query time range.
+	BlocksSkippedByHeader uint64 `json:"blocks_skipped_by_header"`
+
+	// BlocksSkippedByBloom is the number of blocks skipped by bloom filters and dictionary values before applying the filters.
+	BlocksSkippedByBloom uint64 `json:"blocks_skipped_by_bloom"`
+
+	// RowsConsidered is the number of rows in blocks, which were scanned by the filters.
+	RowsConsidered uint64 `json:"rows_considered"`
+
+	// RowsMatched is the number of rows matching all the filters.
+	RowsMatched uint64 `json:"rows_matched"`
+
+	// Filters contains per-filter counters for top-level filters applied to every scanned block in the order of their execution.
+	Filters []*FilterAnalysis `json:"filters"`
+}
+
+// FilterAnalysis contains counters for a single top-level filter.
+//
+// All the counters must be updated atomically during the search.
+type FilterAnalysis struct {
+	// Filter is the string representation of the filter.
+	Filter string `json:"filter"`
+
+	// BlocksEvaluated is the number of blocks the filter was applied to.
+	BlocksEvaluated uint64 `json:"blocks_evaluated"`
+
+	// BlocksSkipped is the number of blocks without matching rows after applying the filter.
+	BlocksSkipped uint64 `json:"blocks_skipped"`
+
+	// RowsEvaluated is the number of rows the filter was applied to.
+	RowsEvaluated uint64 `json:"rows_evaluated"`
+
+	// RowsMatched is the number of rows matching the filter.
+	RowsMatched uint64 `json:"rows_matched"`
+}
+
+// queryAnalyzer collects PartitionAnalysis during the query execution.
+type queryAnalyzer struct {
+	mu         sync.Mutex
+	partitions []*PartitionAnalysis
+}
+
+// newPartitionAnalysis registers new PartitionAnalysis for the search in pt with the given pso.
+func (qa *queryAnalyzer) newPartitionAnalysis(pt *partition, pso *partitionSearchOptions) *PartitionAnalysis {
+	pa := &PartitionAnalysis{
+		Partition: pt.name,
+		StreamIDs: -1,
+	}
+	if len(pso.tenantIDs) == 0 {
+		pa.StreamIDs = len(pso.streamIDs)
+	}
+	for _, f := range getTopLevelFilters(pso.filter) {
+		pa.Filters = append(pa.Filters, &FilterAnalysis{
+			Filter: f.String(),
+		})
+	}
+
+	qa.mu.Lock()
+	qa.partitions = append(qa.partitions, pa)
+	qa.mu.Unlock()
+
+	return pa
+}
+
+// getTopLevelFilters returns filters, which are applied sequentially to every scanned block.
+func getTopLevelFilters(f filter) []filter {
+	if fa, ok := f.(*filterAnd); ok {
+		return fa.filters
+	}
+	return []filter{f}
+}
+
+// applyFilterWithAnalysis applies pso.filter to bs and bm in the same way as filter.applyToBlockSearch does, while updating pa counters.
+func (pa *PartitionAnalysis) applyFilterWithAnalysis(bs *blockSearch, bm *bitmap, f filter) {
+	atomic.AddUint64(&pa.RowsConsidered, uint64(bm.bitsLen))
+
+	if fa, ok := f.(*filterAnd); ok && !fa.matchBloomFilters(bs) {
+		atomic.AddUint64(&pa.BlocksSkippedByBloom, 1)
+		bm.resetBits()
+		return
+	}
+
+	for i, f := range getTopLevelFilters(f) {
+		fa := pa.Filters[i]
+		atomic.AddUint64(&fa.BlocksEvaluated, 1)
+		atomic.AddUint64(&fa.RowsEvaluated, uint64(bm.onesCount()))
+
+		f.applyToBlockSearch(bs, bm)
+
+		rowsMatched := bm.onesCount()
+		atomic.AddUint64(&fa.RowsMatched, uint64(rowsMatched))
+		if rowsMatched == 0 {
+			atomic.AddUint64(&fa.BlocksSkipped, 1)
+			return
+		}
+	}
+	atomic.AddUint64(&pa.RowsMatched, uint64(bm.onesCount()))
+}
+
+// AnalyzeQuery executes qctx and returns counters collected during the execution.
+//
+// The query results are dropped.
+func (s *Storage) AnalyzeQuery(qctx *QueryContext) (*QueryAnalysis, error) {
+	startTime := time.Now()
+
+	qa := &queryAnalyzer{
+		partitions: []*PartitionAnalysis{},
+	}
+	qctxLocal := qctx.WithContext(qctx.Context)
+	qctxLocal.analyzer = qa
+
+	var rowsReturned atomic.Uint64
+	writeBlock := func(_ uint, br *blockResult) {
+		rowsReturned.Add(uint64(br.rowsLen))
+	}
+	if err := s.runQuery(qctxLocal, writeBlock); err != nil {
+		return nil, err
+	}
+
+	partitions := qa.partitions
+	sort.Slice(partitions, func(i, j int) bool {
+		return partitions[i].Partition < partitions[j].Partition
+	})
+
+	return &QueryAnalysis{
+		Duration:     time.Since(startTime).String(),
+		RowsReturned: rowsReturned.Load(),
+		Partitions:   partitions,
+	}, nil
+}
@@ -0,0 +1,40 @@
+package logstorage
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestQueryExplain(t *testing.T) {
+	f := func(qStr, resultExpected string) {
+		t.Helper()
+
+		q, err := ParseQueryAtTimestamp(qStr, 0)
+		if err != nil {
+			t.Fatalf("cannot parse [%s]: %s", qStr, err)
+		}
+		qp := q.Explain()
+		data, err := json.Marshal(qp)
+		if err != nil {
+			t.Fatalf("cannot marshal query plan: %s", err)
+		}
+		result := string(data)
+		if result != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	f(`*`, `{"query":"*","has_nested_stream_filters":false,"filter":{"type":"noop","filter":"*"},"remote_pipes":[],"local_pipes":[]}`)
+
+	f(`{app="nginx"} _time:[2024-01-01Z, 2024-01-02Z) error -foo:bar | stats by (host) count() x | sort by (x)`,
+		`{"query":"{app=\"nginx\"} _time:[2024-01-01Z,2024-01-02Z) error !foo:bar | stats by (host) count(*) as x | sort by (x)",`+
+			`"min_timestamp":"2024-01-01T00:00:00Z","max_timestamp":"2024-01-01T23:59:59.999999999Z","stream_filter":"{app=\"nginx\"}","has_nested_stream_filters":false,`+
+			`"filter":{"type":"and","filter":"_time:[2024-01-01Z,2024-01-02Z) error !foo:bar","children":[`+
+			`{"type":"time","filter":"_time:[2024-01-01Z,2024-01-02Z)"},{"type":"phrase","filter":"error"},{"type":"not","filter":"!foo:bar","children":[{"type":"phrase","filter":"foo:bar"}]}]},`+
+			`"remote_pipes":["stats_remote by (host) count(*) as x","fields host, x"],"local_pipes":["stats_local by (host) import_state(x) as x","sort by (x)"]}`)
+
+	// nested stream filters
+	f(`error or {app="nginx"}`, `{"query":"error or {app=\"nginx\"}","has_nested_stream_filters":true,`+
+		`"filter":{"type":"or","filter":"error or {app=\"nginx\"}","children":[{"type":"phrase","filter":"error"},{"type":"stream","filter":"{app=\"nginx\"}"}]},`+
+		`"remote_pipes":[],"local_pipes":[]}`)
+}
@@ -0,0 +1,196 @@
+package logstorage
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// QueryLimits contains resource limits for a single query.
+//
+// The limits are applied to every search in the storage performed by the query.
+type QueryLimits struct {
+	// MaxBytesRead is the maximum number of bytes, which can be read from the storage by the query.
+	//
+	// Zero means no limit.
+	MaxBytesRead uint64
+
+	// MaxRowsProcessed is the maximum number of log rows, which can be processed by the query.
+	//
+	// Zero means no limit.
+	MaxRowsProcessed uint64
+}
+
+func (ql *QueryLimits) isEnabled() bool {
+	return ql.MaxBytesRead > 0 || ql.MaxRowsProcessed > 0
+}
+
+// queryLimiter tracks resources used by the search and stops the search when some of QueryLimits is exceeded.
+type queryLimiter struct {
+	limits QueryLimits
+
+	bytesRead     atomic.Uint64
+	rowsProcessed atomic.Uint64
+
+	// exceededCh is closed when some of limits is exceeded.
+	exceededCh chan struct{}
+	once       sync.Once
+	err        error
+}
+
+func newQueryLimiter(limits *QueryLimits) *queryLimiter {
+	return &queryLimiter{
+		limits:     *limits,
+		exceededCh: make(chan struct{}),
+	}
+}
+
+// update adds bytesRead and rowsProcessed to ql.
+//
+// It returns false if some of the limits is exceeded.
+func (ql *queryLimiter) update(bytesRead, rowsProcessed uint64) bool {
+	n := ql.bytesRead.Add(bytesRead)
+	if maxBytesRead := ql.limits.MaxBytesRead; maxBytesRead > 0 && n > maxBytesRead {
+		ql.setError(fmt.Errorf("the query has read more than %d bytes from the storage; narrow down the time range or add more specific filters to the query; "+
+			"see https://docs.victoriametrics.com/victorialogs/querying/#resource-usage-limits", maxBytesRead))
+		return false
+	}
+
+	n = ql.rowsProcessed.Add(rowsProcessed)
+	if maxRowsProcessed := ql.limits.MaxRowsProcessed; maxRowsProcessed > 0 && n > maxRowsProcessed {
+		ql.setError(fmt.Errorf("the query has processed more than %d log rows; narrow down the time range or add more specific filters to the query; "+
+			"see https://docs.victoriametrics.com/victorialogs/querying/#resource-usage-limits", maxRowsProcessed))
+		return false
+	}
+
+	return true
+}
+
+func (ql *queryLimiter) setError(err error) {
+	ql.once.Do(func() {
+		ql.err = err
+		close(ql.exceededCh)
+	})
+}
+
+// getError returns non-nil error if some of the limits has been exceeded.
+//
+// It must be called after the search is finished.
+func (ql *queryLimiter) getError() error {
+	select {
+	case <-ql.exceededCh:
+		return ql.err
+	default:
+		return nil
+	}
+}
+
+// newStopCh returns a channel, which is closed when either stopCh is closed or some of ql limits is exceeded.
+//
+// The caller must call the returned release func when the channel is no longer needed.
+func (ql *queryLimiter) newStopCh(stopCh <-chan struct{}) (<-chan struct{}, func()) {
+	ch := make(chan struct{})
+	doneCh := make(chan struct{})
+	go func() {
+		select {
+		case <-stopCh:
+		case <-ql.exceededCh:
+		case <-doneCh:
+		}
+		close(ch)
+	}()
+	release := func() {
+		close(doneCh)
+	}
+	return ch, release
+}
+
+// QueryEstimate contains the estimated amounts of data, which must be scanned by the query.
+type QueryEstimate struct {
+	// PartsCount is the number of parts, which must be scanned by the query.
+	PartsCount uint64 `json:"parts"`
+
+	// BlocksCount is the number of blocks, which must be scanned by the query.
+	BlocksCount uint64 `json:"blocks"`
+
+	// RowsCount is the number of log rows, which must be scanned by the query.
+	RowsCount uint64 `json:"rows"`
+
+	// UncompressedBytes is the original size of log rows, which must be scanned by the query.
+	UncompressedBytes uint64 `json:"uncompressed_bytes"`
+
+	// CompressedBytes is the estimated size of the data, which may be read from disk by the query.
+	//
+	// The estimation is based on compression ratios for the scanned parts.
+	// The real number of bytes read is usually smaller, since the query reads only the needed columns
+	// and skips blocks with the help of bloom filters.
+	CompressedBytes uint64 `json:"compressed_bytes"`
+}
+
+// Add adds src to qe.
+func (qe *QueryEstimate) Add(src *QueryEstimate) {
+	qe.PartsCount += src.PartsCount
+	qe.BlocksCount += src.BlocksCount
+	qe.RowsCount += src.RowsCount
+	qe.UncompressedBytes += src.UncompressedBytes
+	qe.CompressedBytes += src.CompressedBytes
+}
+
+// EstimateQuery estimates the amounts of data, which must be scanned by the query at qctx without executing the query.
+//
+// The estimation is based on part headers and block headers matching tenants, log streams and the time range of the query.
+// Other filters and subqueries are ignored during the estimation.
+func (s *Storage) EstimateQuery(qctx *QueryContext) (*QueryEstimate, error) {
+	q := qctx.Query
+	sso := s.getSearchOptions(qctx.TenantIDs, q)
+
+	stopCh := qctx.Context.Done()
+	workCh := make(chan *blockSearchWorkBatch, 1)
+
+	var qe QueryEstimate
+	doneCh := make(chan struct{})
+	go func() {
+		parts := make(map[*part]struct{})
+		for bswb := range workCh {
+			bsws := bswb.bsws
+			for i := range bsws {
+				bsw := &bsws[i]
+				bh := &bsw.bh
+				ph := &bsw.p.ph
+
+				parts[bsw.p] = struct{}{}
+				qe.BlocksCount++
+				qe.RowsCount += bh.rowsCount
+				qe.UncompressedBytes += bh.uncompressedSizeBytes
+				if ph.UncompressedSizeBytes > 0 {
+					qe.CompressedBytes += uint64(float64(bh.uncompressedSizeBytes) * float64(ph.CompressedSizeBytes) / float64(ph.UncompressedSizeBytes))
+				}
+
+				bsw.reset()
+			}
+			bswb.bsws = bswb.bsws[:0]
+			putBlockSearchWorkBatch(bswb)
+		}
+		qe.PartsCount = uint64(len(parts))
+		close(doneCh)
+	}()
+
+	ptws, ptwsDecRef := s.getPartitionsForTimeRange(sso.minTimestamp, sso.maxTimestamp)
+	psfs := make([]partitionSearchFinalizer, len(ptws))
+	var qs QueryStats
+	for i, ptw := range ptws {
+		psfs[i] = ptw.pt.search(sso, &qs, workCh, stopCh)
+	}
+	close(workCh)
+	<-doneCh
+
+	for _, psf := range psfs {
+		psf()
+	}
+	ptwsDecRef()
+
+	if err := qctx.Context.Err(); err != nil {
+		return nil, err
+	}
+	return &qe, nil
+}
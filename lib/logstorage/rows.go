@@ -319,6 +319,56 @@ func (rs *rows) skipRowsByDropFilter(dropFilter *partitionSearchOptions, dropFil
 	rs.rows = dstRows
 }
 
+// updateRowsByFilter updates rows starting from the given offset, which match the given filter, with the given updater.
+func (rs *rows) updateRowsByFilter(filter *partitionSearchOptions, filterFields *prefixfilter.Filter, updater *rowsUpdater, offset int, stream, streamID string) {
+	tmpFields := GetFields()
+	defer PutFields(tmpFields)
+
+	tmpFields.Fields = addFieldIfNeeded(tmpFields.Fields, filterFields, "_stream", stream)
+	tmpFields.Fields = addFieldIfNeeded(tmpFields.Fields, filterFields, "_stream_id", streamID)
+	tmpFieldsBaseLen := len(tmpFields.Fields)
+
+	var rowIdxs []int
+	var rowsToUpdate [][]Field
+
+	timestamps := rs.timestamps[offset:]
+	rows := rs.rows[offset:]
+
+	bb := bbPool.Get()
+	for i, timestamp := range timestamps {
+		if timestamp < filter.minTimestamp || timestamp > filter.maxTimestamp {
+			// Fast path - skip row outside the filter time range
+			continue
+		}
+
+		if filterFields.MatchString("_time") {
+			bb.B = marshalTimestampISO8601String(bb.B[:0], timestamp)
+			tmpFields.Fields = append(tmpFields.Fields, Field{
+				Name:  "_time",
+				Value: bytesutil.ToUnsafeString(bb.B),
+			})
+		}
+
+		for _, f := range rows[i] {
+			tmpFields.Fields = addFieldIfNeeded(tmpFields.Fields, filterFields, f.Name, f.Value)
+		}
+
+		if filter.filter.matchRow(tmpFields.Fields) {
+			rowIdxs = append(rowIdxs, i)
+			rowsToUpdate = append(rowsToUpdate, rows[i])
+		}
+
+		clear(tmpFields.Fields[tmpFieldsBaseLen:])
+		tmpFields.Fields = tmpFields.Fields[:tmpFieldsBaseLen]
+	}
+	bbPool.Put(bb)
+
+	updater.updateRows(rowsToUpdate)
+	for i, rowIdx := range rowIdxs {
+		rows[rowIdx] = rowsToUpdate[i]
+	}
+}
+
 func addFieldIfNeeded(dst []Field, pf *prefixfilter.Filter, name, value string) []Field {
 	name = getCanonicalColumnName(name)
 	if pf.MatchString(name) {
@@ -0,0 +1,154 @@
+package logstorage
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/slicesutil"
+)
+
+// RowsUpdate is an update for the stored log rows.
+//
+// It is applied to the rows matching update task filter during background rewrite of the parts containing these rows.
+type RowsUpdate struct {
+	pipes []pipe
+}
+
+// String returns string representation of ru.
+func (ru *RowsUpdate) String() string {
+	a := make([]string, len(ru.pipes))
+	for i, p := range ru.pipes {
+		a[i] = p.String()
+	}
+	return strings.Join(a, " | ")
+}
+
+// ParseRowsUpdate parses rows update from s.
+//
+// The s must contain LogsQL pipes, which modify log fields without changing the number of rows.
+// The following pipes are supported: copy, delete, fields, format, rename, replace and replace_regexp.
+func ParseRowsUpdate(s string) (*RowsUpdate, error) {
+	lex := newLexer(s, 0)
+	pipes, err := parsePipes(lex)
+	if err != nil {
+		return nil, err
+	}
+	if !lex.isEnd() {
+		return nil, fmt.Errorf("unexpected tail left after parsing [%s]: %s", s, lex.context())
+	}
+
+	for _, p := range pipes {
+		switch p.(type) {
+		case *pipeCopy, *pipeDelete, *pipeFields, *pipeFormat, *pipeRename, *pipeReplace, *pipeReplaceRegexp:
+		default:
+			return nil, fmt.Errorf("unsupported pipe [%s]; supported pipes: copy, delete, fields, format, rename, replace, replace_regexp", p)
+		}
+		if p.hasFilterInWithQuery() {
+			return nil, fmt.Errorf("the pipe [%s] cannot contain in(subquery) filters", p)
+		}
+	}
+
+	ru := &RowsUpdate{
+		pipes: pipes,
+	}
+	return ru, nil
+}
+
+// rowsUpdater applies RowsUpdate to rows during the merge.
+type rowsUpdater struct {
+	ru *RowsUpdate
+
+	// rowsUpdated is the number of rows updated by the rowsUpdater.
+	rowsUpdated atomic.Uint64
+}
+
+func newRowsUpdater(ru *RowsUpdate) *rowsUpdater {
+	return &rowsUpdater{
+		ru: ru,
+	}
+}
+
+// updateRows applies ru to rows in place.
+//
+// _time, _stream and _stream_id fields cannot be updated, so they are dropped from the updated rows.
+func (ru *rowsUpdater) updateRows(rows [][]Field) {
+	if len(rows) == 0 {
+		return
+	}
+
+	// Convert rows to blockResult.
+	var rcs []resultColumn
+	columnIdxs := make(map[string]int)
+	for _, fields := range rows {
+		for _, f := range fields {
+			name := getCanonicalColumnName(f.Name)
+			if _, ok := columnIdxs[name]; !ok {
+				columnIdxs[name] = len(rcs)
+				rcs = appendResultColumnWithName(rcs, name)
+			}
+		}
+	}
+	for i := range rcs {
+		rc := &rcs[i]
+		rc.values = slicesutil.SetLength(rc.values, len(rows))
+		clear(rc.values)
+	}
+	for rowIdx, fields := range rows {
+		for _, f := range fields {
+			idx := columnIdxs[getCanonicalColumnName(f.Name)]
+			rcs[idx].values[rowIdx] = f.Value
+		}
+	}
+
+	br := getBlockResult()
+	br.setResultColumns(rcs, len(rows))
+
+	// Apply pipes to br and convert the result back to rows.
+	rowsUpdated := 0
+	ppFinal := newNoopPipeProcessor(nil, func(_ uint, br *blockResult) {
+		if rowsUpdated+br.rowsLen > len(rows) {
+			logger.Panicf("BUG: [%s] returned more than %d rows", ru.ru, len(rows))
+		}
+		cs := br.getColumns()
+		for rowIdx := 0; rowIdx < br.rowsLen; rowIdx++ {
+			fields := make([]Field, 0, len(cs))
+			for _, c := range cs {
+				if c.name == "_time" || c.name == "_stream" || c.name == "_stream_id" {
+					continue
+				}
+				v := c.getValueAtRow(br, rowIdx)
+				if v == "" {
+					// Empty fields are equivalent to missing fields.
+					continue
+				}
+				fields = append(fields, Field{
+					Name:  getCanonicalFieldName(c.name),
+					Value: strings.Clone(v),
+				})
+			}
+			rows[rowsUpdated] = fields
+			rowsUpdated++
+		}
+	})
+
+	pps := make([]pipeProcessor, len(ru.ru.pipes))
+	ppNext := ppFinal
+	for i := len(ru.ru.pipes) - 1; i >= 0; i-- {
+		ppNext = ru.ru.pipes[i].newPipeProcessor(1, nil, func() {}, ppNext)
+		pps[i] = ppNext
+	}
+	ppNext.writeBlock(0, br)
+	for _, pp := range pps {
+		if err := pp.flush(); err != nil {
+			logger.Panicf("BUG: unexpected error when applying [%s]: %s", ru.ru, err)
+		}
+	}
+	putBlockResult(br)
+
+	if rowsUpdated != len(rows) {
+		logger.Panicf("BUG: [%s] returned %d rows; want %d rows", ru.ru, rowsUpdated, len(rows))
+	}
+	ru.rowsUpdated.Add(uint64(len(rows)))
+}
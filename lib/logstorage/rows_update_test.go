@@ -0,0 +1,52 @@
+package logstorage
+
+import (
+	"testing"
+)
+
+func TestParseRowsUpdateSuccess(t *testing.T) {
+	f := func(s, resultExpected string) {
+		t.Helper()
+
+		ru, err := ParseRowsUpdate(s)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		result := ru.String()
+		if result != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	f(`delete foo`, `delete foo`)
+	f(`drop foo, bar`, `delete foo, bar`)
+	f(`replace_regexp ("secret=[^ ]+", "secret=***") at _msg`, `replace_regexp ("secret=[^ ]+", "secret=***")`)
+	f(`format "redacted" as password | rename foo as bar`, `format redacted as password | rename foo as bar`)
+	f(`copy a as b | replace (x, y) at b | fields a, b`, `copy a as b | replace (x, y) at b | fields a, b`)
+}
+
+func TestParseRowsUpdateFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+
+		ru, err := ParseRowsUpdate(s)
+		if err == nil {
+			t.Fatalf("expecting non-nil error; got %s", ru)
+		}
+	}
+
+	// missing pipes
+	f(``)
+
+	// filter instead of pipes
+	f(`foo:bar`)
+
+	// pipes, which can change the number of rows
+	f(`filter foo`)
+	f(`limit 10`)
+	f(`stats count()`)
+	f(`delete foo | sort by (bar)`)
+
+	// in(subquery)
+	f(`format if (foo:in(bar:baz | keep x)) "x" as y`)
+}
@@ -0,0 +1,68 @@
+package logstorage
+
+import (
+	"strconv"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/prefixfilter"
+)
+
+// runningStatsCovar calculates running covar or corr between two numeric fields.
+type runningStatsCovar struct {
+	sc *statsCovar
+}
+
+func (sc *runningStatsCovar) String() string {
+	return sc.sc.String()
+}
+
+func (sc *runningStatsCovar) updateNeededFields(pf *prefixfilter.Filter) {
+	sc.sc.updateNeededFields(pf)
+}
+
+func (sc *runningStatsCovar) newRunningStatsProcessor() runningStatsProcessor {
+	return &runningStatsCovarProcessor{
+		funcName: sc.sc.funcName,
+	}
+}
+
+type runningStatsCovarProcessor struct {
+	funcName string
+	cs       covarState
+}
+
+func (scp *runningStatsCovarProcessor) updateRunningStats(sf runningStatsFunc, row []Field) {
+	sc := sf.(*runningStatsCovar)
+
+	x, ok := tryParseFloat64(getFieldValueByName(row, sc.sc.fieldX))
+	if !ok {
+		return
+	}
+	y, ok := tryParseFloat64(getFieldValueByName(row, sc.sc.fieldY))
+	if !ok {
+		return
+	}
+	scp.cs.update(x, y)
+}
+
+func (scp *runningStatsCovarProcessor) getRunningStats() string {
+	f := scp.cs.getResult(scp.funcName)
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func parseRunningStatsCovar(lex *lexer) (runningStatsFunc, error) {
+	return parseRunningStatsCovarExt(lex, "covar")
+}
+
+func parseRunningStatsCorr(lex *lexer) (runningStatsFunc, error) {
+	return parseRunningStatsCovarExt(lex, "corr")
+}
+
+func parseRunningStatsCovarExt(lex *lexer, funcName string) (runningStatsFunc, error) {
+	sc, err := parseStatsCovarExt(lex, funcName)
+	if err != nil {
+		return nil, err
+	}
+	return &runningStatsCovar{
+		sc: sc,
+	}, nil
+}
@@ -0,0 +1,58 @@
+package logstorage
+
+import (
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/prefixfilter"
+)
+
+type runningStatsMode struct {
+	fieldFilters []string
+}
+
+func (sm *runningStatsMode) String() string {
+	return "mode(" + fieldNamesString(sm.fieldFilters) + ")"
+}
+
+func (sm *runningStatsMode) updateNeededFields(pf *prefixfilter.Filter) {
+	pf.AddAllowFilters(sm.fieldFilters)
+}
+
+func (sm *runningStatsMode) newRunningStatsProcessor() runningStatsProcessor {
+	return &runningStatsModeProcessor{
+		m: make(map[string]uint64),
+	}
+}
+
+type runningStatsModeProcessor struct {
+	m map[string]uint64
+}
+
+func (smp *runningStatsModeProcessor) updateRunningStats(sf runningStatsFunc, row []Field) {
+	sm := sf.(*runningStatsMode)
+
+	forEachMatchingField(row, sm.fieldFilters, func(v string) {
+		if v == "" {
+			return
+		}
+		if _, ok := smp.m[v]; !ok {
+			v = strings.Clone(v)
+		}
+		smp.m[v]++
+	})
+}
+
+func (smp *runningStatsModeProcessor) getRunningStats() string {
+	return getModeValue(smp.m)
+}
+
+func parseRunningStatsMode(lex *lexer) (runningStatsFunc, error) {
+	fieldFilters, err := parseStatsFuncFieldFilters(lex, "mode")
+	if err != nil {
+		return nil, err
+	}
+	sm := &runningStatsMode{
+		fieldFilters: fieldFilters,
+	}
+	return sm, nil
+}
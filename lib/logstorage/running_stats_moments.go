@@ -0,0 +1,78 @@
+package logstorage
+
+import (
+	"strconv"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/prefixfilter"
+)
+
+// runningStatsMoments calculates running stddev, stdvar, skew or kurtosis.
+type runningStatsMoments struct {
+	// funcName is one of stddev, stdvar, skew or kurtosis
+	funcName string
+
+	fieldFilters []string
+}
+
+func (sm *runningStatsMoments) String() string {
+	return sm.funcName + "(" + fieldNamesString(sm.fieldFilters) + ")"
+}
+
+func (sm *runningStatsMoments) updateNeededFields(pf *prefixfilter.Filter) {
+	pf.AddAllowFilters(sm.fieldFilters)
+}
+
+func (sm *runningStatsMoments) newRunningStatsProcessor() runningStatsProcessor {
+	return &runningStatsMomentsProcessor{
+		funcName: sm.funcName,
+	}
+}
+
+type runningStatsMomentsProcessor struct {
+	funcName string
+	ms       momentsState
+}
+
+func (smp *runningStatsMomentsProcessor) updateRunningStats(sf runningStatsFunc, row []Field) {
+	sm := sf.(*runningStatsMoments)
+
+	forEachMatchingField(row, sm.fieldFilters, func(v string) {
+		f, ok := tryParseFloat64(v)
+		if ok {
+			smp.ms.update(f)
+		}
+	})
+}
+
+func (smp *runningStatsMomentsProcessor) getRunningStats() string {
+	f := smp.ms.getResult(smp.funcName)
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func parseRunningStatsStddev(lex *lexer) (runningStatsFunc, error) {
+	return parseRunningStatsMoments(lex, "stddev")
+}
+
+func parseRunningStatsStdvar(lex *lexer) (runningStatsFunc, error) {
+	return parseRunningStatsMoments(lex, "stdvar")
+}
+
+func parseRunningStatsSkew(lex *lexer) (runningStatsFunc, error) {
+	return parseRunningStatsMoments(lex, "skew")
+}
+
+func parseRunningStatsKurtosis(lex *lexer) (runningStatsFunc, error) {
+	return parseRunningStatsMoments(lex, "kurtosis")
+}
+
+func parseRunningStatsMoments(lex *lexer, funcName string) (runningStatsFunc, error) {
+	fieldFilters, err := parseStatsFuncFieldFilters(lex, funcName)
+	if err != nil {
+		return nil, err
+	}
+	sm := &runningStatsMoments{
+		funcName:     funcName,
+		fieldFilters: fieldFilters,
+	}
+	return sm, nil
+}
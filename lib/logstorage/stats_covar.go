@@ -0,0 +1,218 @@
+package logstorage
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/prefixfilter"
+)
+
+// statsCovar calculates covariance (covar) or Pearson correlation coefficient (corr) between two numeric fields.
+type statsCovar struct {
+	// funcName is either covar or corr
+	funcName string
+
+	fieldX string
+	fieldY string
+}
+
+func (sc *statsCovar) String() string {
+	return sc.funcName + "(" + quoteTokenIfNeeded(sc.fieldX) + ", " + quoteTokenIfNeeded(sc.fieldY) + ")"
+}
+
+func (sc *statsCovar) updateNeededFields(pf *prefixfilter.Filter) {
+	pf.AddAllowFilter(sc.fieldX)
+	pf.AddAllowFilter(sc.fieldY)
+}
+
+func (sc *statsCovar) newStatsProcessor(a *chunkedAllocator) statsProcessor {
+	return a.newStatsCovarProcessor()
+}
+
+type statsCovarProcessor struct {
+	cs covarState
+}
+
+func (scp *statsCovarProcessor) updateStatsForAllRows(sf statsFunc, br *blockResult) int {
+	sc := sf.(*statsCovar)
+
+	cX := br.getColumnByName(sc.fieldX)
+	cY := br.getColumnByName(sc.fieldY)
+	for rowIdx := 0; rowIdx < br.rowsLen; rowIdx++ {
+		scp.updateState(br, cX, cY, rowIdx)
+	}
+
+	return 0
+}
+
+func (scp *statsCovarProcessor) updateStatsForRow(sf statsFunc, br *blockResult, rowIdx int) int {
+	sc := sf.(*statsCovar)
+
+	cX := br.getColumnByName(sc.fieldX)
+	cY := br.getColumnByName(sc.fieldY)
+	scp.updateState(br, cX, cY, rowIdx)
+
+	return 0
+}
+
+func (scp *statsCovarProcessor) updateState(br *blockResult, cX, cY *blockResultColumn, rowIdx int) {
+	x, ok := cX.getFloatValueAtRow(br, rowIdx)
+	if !ok {
+		return
+	}
+	y, ok := cY.getFloatValueAtRow(br, rowIdx)
+	if !ok {
+		return
+	}
+	scp.cs.update(x, y)
+}
+
+func (scp *statsCovarProcessor) mergeState(_ *chunkedAllocator, _ statsFunc, sfp statsProcessor) {
+	src := sfp.(*statsCovarProcessor)
+	scp.cs.merge(&src.cs)
+}
+
+func (scp *statsCovarProcessor) exportState(dst []byte, _ <-chan struct{}) []byte {
+	return scp.cs.marshal(dst)
+}
+
+func (scp *statsCovarProcessor) importState(src []byte, _ <-chan struct{}) (int, error) {
+	tail, err := scp.cs.unmarshal(src)
+	if err != nil {
+		return 0, err
+	}
+	if len(tail) > 0 {
+		return 0, fmt.Errorf("unexpected tail left; len(tail)=%d", len(tail))
+	}
+	return 0, nil
+}
+
+func (scp *statsCovarProcessor) finalizeStats(sf statsFunc, dst []byte, _ <-chan struct{}) []byte {
+	sc := sf.(*statsCovar)
+	f := scp.cs.getResult(sc.funcName)
+	return strconv.AppendFloat(dst, f, 'f', -1, 64)
+}
+
+func parseStatsCovar(lex *lexer) (statsFunc, error) {
+	return parseStatsCovarExt(lex, "covar")
+}
+
+func parseStatsCorr(lex *lexer) (statsFunc, error) {
+	return parseStatsCovarExt(lex, "corr")
+}
+
+func parseStatsCovarExt(lex *lexer, funcName string) (*statsCovar, error) {
+	fields, err := parseStatsFuncFields(lex, funcName)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("%s() must contain exactly two fields; got %d fields", funcName, len(fields))
+	}
+	sc := &statsCovar{
+		funcName: funcName,
+		fieldX:   fields[0],
+		fieldY:   fields[1],
+	}
+	return sc, nil
+}
+
+// covarState holds the state for calculating covariance and correlation between two series of values.
+//
+// The state is updated and merged with numerically stable algorithms from https://en.wikipedia.org/wiki/Algorithms_for_calculating_variance#Covariance
+type covarState struct {
+	n     uint64
+	meanX float64
+	meanY float64
+	m2X   float64
+	m2Y   float64
+	cXY   float64
+}
+
+func (cs *covarState) update(x, y float64) {
+	cs.n++
+	n := float64(cs.n)
+
+	dx := x - cs.meanX
+	dy := y - cs.meanY
+	cs.meanX += dx / n
+	cs.meanY += dy / n
+	cs.m2X += dx * (x - cs.meanX)
+	cs.m2Y += dy * (y - cs.meanY)
+	cs.cXY += dx * (y - cs.meanY)
+}
+
+func (cs *covarState) merge(src *covarState) {
+	if src.n == 0 {
+		return
+	}
+	if cs.n == 0 {
+		*cs = *src
+		return
+	}
+
+	na := float64(cs.n)
+	nb := float64(src.n)
+	n := na + nb
+
+	dx := src.meanX - cs.meanX
+	dy := src.meanY - cs.meanY
+
+	cs.n += src.n
+	cs.meanX += dx * nb / n
+	cs.meanY += dy * nb / n
+	cs.m2X += src.m2X + dx*dx*na*nb/n
+	cs.m2Y += src.m2Y + dy*dy*na*nb/n
+	cs.cXY += src.cXY + dx*dy*na*nb/n
+}
+
+// getResult returns the result for the given funcName.
+//
+// NaN is returned if there are no values.
+func (cs *covarState) getResult(funcName string) float64 {
+	if cs.n == 0 {
+		return nan
+	}
+	switch funcName {
+	case "covar":
+		return cs.cXY / float64(cs.n)
+	case "corr":
+		return cs.cXY / math.Sqrt(cs.m2X*cs.m2Y)
+	default:
+		return nan
+	}
+}
+
+func (cs *covarState) marshal(dst []byte) []byte {
+	dst = encoding.MarshalVarUint64(dst, cs.n)
+	dst = marshalFloat64(dst, cs.meanX)
+	dst = marshalFloat64(dst, cs.meanY)
+	dst = marshalFloat64(dst, cs.m2X)
+	dst = marshalFloat64(dst, cs.m2Y)
+	dst = marshalFloat64(dst, cs.cXY)
+	return dst
+}
+
+func (cs *covarState) unmarshal(src []byte) ([]byte, error) {
+	n, nSize := encoding.UnmarshalVarUint64(src)
+	if nSize <= 0 {
+		return nil, fmt.Errorf("cannot unmarshal the number of values")
+	}
+	src = src[nSize:]
+
+	if len(src) < 5*8 {
+		return nil, fmt.Errorf("cannot unmarshal covariance state from %d bytes; need %d bytes", len(src), 5*8)
+	}
+	cs.n = n
+	cs.meanX = unmarshalFloat64(bytesutil.ToUnsafeString(src))
+	cs.meanY = unmarshalFloat64(bytesutil.ToUnsafeString(src[8:]))
+	cs.m2X = unmarshalFloat64(bytesutil.ToUnsafeString(src[16:]))
+	cs.m2Y = unmarshalFloat64(bytesutil.ToUnsafeString(src[24:]))
+	cs.cXY = unmarshalFloat64(bytesutil.ToUnsafeString(src[32:]))
+
+	return src[40:], nil
+}
@@ -0,0 +1,151 @@
+package logstorage
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestParseStatsCovarSuccess(t *testing.T) {
+	f := func(pipeStr string) {
+		t.Helper()
+		expectParseStatsFuncSuccess(t, pipeStr)
+	}
+
+	f(`covar(a, b)`)
+	f(`corr(a, b)`)
+	f(`corr("foo bar", b)`)
+}
+
+func TestParseStatsCovarFailure(t *testing.T) {
+	f := func(pipeStr string) {
+		t.Helper()
+		expectParseStatsFuncFailure(t, pipeStr)
+	}
+
+	f(`covar`)
+	f(`covar()`)
+	f(`covar(a)`)
+	f(`covar(a, b, c)`)
+	f(`corr(a*, b)`)
+	f(`corr(*)`)
+}
+
+func TestStatsCovar(t *testing.T) {
+	f := func(pipeStr string, rows, rowsExpected [][]Field) {
+		t.Helper()
+		expectPipeResults(t, pipeStr, rows, rowsExpected)
+	}
+
+	// Use only two numeric pairs, so the results do not depend on the order of merging the per-block states
+	rows := [][]Field{
+		{
+			{"x", "1"},
+			{"y", "2"},
+			{"z", "4"},
+		},
+		{
+			{"x", "3"},
+			{"y", "6"},
+			{"z", "0"},
+		},
+		{
+			{"x", "5"},
+			{"y", "foo"},
+		},
+		{
+			{"y", "10"},
+		},
+	}
+
+	f("stats covar(x, y) as r", rows, [][]Field{
+		{
+			{"r", "2"},
+		},
+	})
+	f("stats corr(x, y) as r", rows, [][]Field{
+		{
+			{"r", "1"},
+		},
+	})
+	f("stats corr(x, z) as r", rows, [][]Field{
+		{
+			{"r", "-1"},
+		},
+	})
+	f("stats covar(x, missing) as r", rows, [][]Field{
+		{
+			{"r", "NaN"},
+		},
+	})
+}
+
+func TestCovarStateMerge(t *testing.T) {
+	xs := []float64{1.5, -3, 8, 2, 2, 11.25, 0, -7, 4, 3}
+	ys := []float64{2, 1, 7, -4, 3, 10, 0.5, -6, 5, 1}
+
+	var csExpected covarState
+	for i := range xs {
+		csExpected.update(xs[i], ys[i])
+	}
+
+	for i := 0; i <= len(xs); i++ {
+		var cs1, cs2 covarState
+		for j := 0; j < i; j++ {
+			cs1.update(xs[j], ys[j])
+		}
+		for j := i; j < len(xs); j++ {
+			cs2.update(xs[j], ys[j])
+		}
+		cs1.merge(&cs2)
+
+		for _, funcName := range []string{"covar", "corr"} {
+			result := cs1.getResult(funcName)
+			resultExpected := csExpected.getResult(funcName)
+			if math.Abs(result-resultExpected) > 1e-9 {
+				t.Fatalf("unexpected %s result after merging at %d; got %v; want %v", funcName, i, result, resultExpected)
+			}
+		}
+	}
+}
+
+func TestStatsCovar_ExportImportState(t *testing.T) {
+	f := func(scp *statsCovarProcessor, dataLenExpected int) {
+		t.Helper()
+
+		data := scp.exportState(nil, nil)
+		dataLen := len(data)
+		if dataLen != dataLenExpected {
+			t.Fatalf("unexpected dataLen; got %d; want %d", dataLen, dataLenExpected)
+		}
+
+		var scp2 statsCovarProcessor
+		stateSize, err := scp2.importState(data, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if stateSize != 0 {
+			t.Fatalf("unexpected state size; got %d bytes; want 0 bytes", stateSize)
+		}
+
+		if !reflect.DeepEqual(scp, &scp2) {
+			t.Fatalf("unexpected state imported; got %#v; want %#v", &scp2, scp)
+		}
+	}
+
+	var scp statsCovarProcessor
+
+	f(&scp, 41)
+
+	scp = statsCovarProcessor{
+		cs: covarState{
+			n:     234,
+			meanX: 12.5,
+			meanY: -3.25,
+			m2X:   1.5,
+			m2Y:   8.125,
+			cXY:   -0.5,
+		},
+	}
+	f(&scp, 42)
+}
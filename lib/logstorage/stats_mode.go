@@ -0,0 +1,203 @@
+package logstorage
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/prefixfilter"
+)
+
+// statsMode returns the most frequent non-empty value across the given fields.
+type statsMode struct {
+	fieldFilters []string
+}
+
+func (sm *statsMode) String() string {
+	return "mode(" + fieldNamesString(sm.fieldFilters) + ")"
+}
+
+func (sm *statsMode) updateNeededFields(pf *prefixfilter.Filter) {
+	pf.AddAllowFilters(sm.fieldFilters)
+}
+
+func (sm *statsMode) newStatsProcessor(a *chunkedAllocator) statsProcessor {
+	smp := a.newStatsModeProcessor()
+	smp.a = a
+	smp.m = make(map[string]uint64)
+	return smp
+}
+
+type statsModeProcessor struct {
+	a *chunkedAllocator
+
+	// m contains the number of hits per each value
+	m map[string]uint64
+}
+
+func (smp *statsModeProcessor) updateStatsForAllRows(sf statsFunc, br *blockResult) int {
+	sm := sf.(*statsMode)
+
+	stateSizeIncrease := 0
+
+	mc := getMatchingColumns(br, sm.fieldFilters)
+	for _, c := range mc.cs {
+		stateSizeIncrease += smp.updateStatsForAllRowsColumn(c, br)
+	}
+	putMatchingColumns(mc)
+
+	return stateSizeIncrease
+}
+
+func (smp *statsModeProcessor) updateStatsForAllRowsColumn(c *blockResultColumn, br *blockResult) int {
+	if c.isConst {
+		v := c.valuesEncoded[0]
+		return smp.updateState(v, uint64(br.rowsLen))
+	}
+
+	stateSizeIncrease := 0
+	if c.valueType == valueTypeDict {
+		c.forEachDictValueWithHits(br, func(v string, hits uint64) {
+			stateSizeIncrease += smp.updateState(v, hits)
+		})
+		return stateSizeIncrease
+	}
+
+	// slow path - count hits for runs of identical values
+	values := c.getValues(br)
+	hits := uint64(0)
+	for i, v := range values {
+		if i > 0 && values[i-1] != v {
+			stateSizeIncrease += smp.updateState(values[i-1], hits)
+			hits = 0
+		}
+		hits++
+	}
+	if len(values) > 0 {
+		stateSizeIncrease += smp.updateState(values[len(values)-1], hits)
+	}
+	return stateSizeIncrease
+}
+
+func (smp *statsModeProcessor) updateStatsForRow(sf statsFunc, br *blockResult, rowIdx int) int {
+	sm := sf.(*statsMode)
+
+	stateSizeIncrease := 0
+
+	mc := getMatchingColumns(br, sm.fieldFilters)
+	for _, c := range mc.cs {
+		v := c.getValueAtRow(br, rowIdx)
+		stateSizeIncrease += smp.updateState(v, 1)
+	}
+	putMatchingColumns(mc)
+
+	return stateSizeIncrease
+}
+
+func (smp *statsModeProcessor) updateState(v string, hits uint64) int {
+	if v == "" {
+		// Skip empty values
+		return 0
+	}
+	if _, ok := smp.m[v]; ok {
+		smp.m[v] += hits
+		return 0
+	}
+	vCopy := smp.a.cloneString(v)
+	smp.m[vCopy] = hits
+	return len(vCopy) + int(unsafe.Sizeof(vCopy)) + int(unsafe.Sizeof(hits))
+}
+
+func (smp *statsModeProcessor) mergeState(_ *chunkedAllocator, _ statsFunc, sfp statsProcessor) {
+	src := sfp.(*statsModeProcessor)
+	for k, hits := range src.m {
+		smp.m[k] += hits
+	}
+}
+
+func (smp *statsModeProcessor) exportState(dst []byte, stopCh <-chan struct{}) []byte {
+	dst = encoding.MarshalVarUint64(dst, uint64(len(smp.m)))
+	for k, hits := range smp.m {
+		if needStop(stopCh) {
+			return dst
+		}
+		dst = encoding.MarshalBytes(dst, bytesutil.ToUnsafeBytes(k))
+		dst = encoding.MarshalVarUint64(dst, hits)
+	}
+	return dst
+}
+
+func (smp *statsModeProcessor) importState(src []byte, stopCh <-chan struct{}) (int, error) {
+	itemsLen, n := encoding.UnmarshalVarUint64(src)
+	if n <= 0 {
+		return 0, fmt.Errorf("cannot unmarshal itemsLen")
+	}
+	src = src[n:]
+	if itemsLen > uint64(len(src)) {
+		return 0, fmt.Errorf("too big itemsLen=%d; it mustn't exceed %d", itemsLen, len(src))
+	}
+
+	m := make(map[string]uint64, itemsLen)
+	stateSize := 0
+	for i := uint64(0); i < itemsLen; i++ {
+		v, n := encoding.UnmarshalBytes(src)
+		if n <= 0 {
+			return 0, fmt.Errorf("cannot unmarshal value")
+		}
+		src = src[n:]
+
+		hits, n := encoding.UnmarshalVarUint64(src)
+		if n <= 0 {
+			return 0, fmt.Errorf("cannot unmarshal hits")
+		}
+		src = src[n:]
+
+		value := smp.a.cloneBytesToString(v)
+		m[value] = hits
+		stateSize += len(value) + int(unsafe.Sizeof(value)) + int(unsafe.Sizeof(hits))
+
+		if needStop(stopCh) {
+			return 0, nil
+		}
+	}
+	smp.m = m
+
+	if len(src) > 0 {
+		return 0, fmt.Errorf("unexpected non-empty tail; len(tail)=%d", len(src))
+	}
+
+	return stateSize, nil
+}
+
+func (smp *statsModeProcessor) finalizeStats(_ statsFunc, dst []byte, _ <-chan struct{}) []byte {
+	v := getModeValue(smp.m)
+	return append(dst, v...)
+}
+
+// getModeValue returns the value with the maximum number of hits in m.
+//
+// The smallest value is returned if multiple values have the same maximum number of hits.
+func getModeValue(m map[string]uint64) string {
+	modeValue := ""
+	modeHits := uint64(0)
+	for v, hits := range m {
+		if hits > modeHits || hits == modeHits && lessString(v, modeValue) {
+			modeValue = v
+			modeHits = hits
+		}
+	}
+	return modeValue
+}
+
+func parseStatsMode(lex *lexer) (statsFunc, error) {
+	fieldFilters, err := parseStatsFuncFieldFilters(lex, "mode")
+	if err != nil {
+		return nil, err
+	}
+	sm := &statsMode{
+		fieldFilters: fieldFilters,
+	}
+	return sm, nil
+}
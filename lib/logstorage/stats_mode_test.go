@@ -0,0 +1,136 @@
+package logstorage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseStatsModeSuccess(t *testing.T) {
+	f := func(pipeStr string) {
+		t.Helper()
+		expectParseStatsFuncSuccess(t, pipeStr)
+	}
+
+	f(`mode(*)`)
+	f(`mode(a)`)
+	f(`mode(a, b*)`)
+}
+
+func TestParseStatsModeFailure(t *testing.T) {
+	f := func(pipeStr string) {
+		t.Helper()
+		expectParseStatsFuncFailure(t, pipeStr)
+	}
+
+	f(`mode`)
+	f(`mode(a b)`)
+	f(`mode(x) y`)
+}
+
+func TestStatsMode(t *testing.T) {
+	f := func(pipeStr string, rows, rowsExpected [][]Field) {
+		t.Helper()
+		expectPipeResults(t, pipeStr, rows, rowsExpected)
+	}
+
+	rows := [][]Field{
+		{
+			{"a", "foo"},
+			{"b", "x"},
+		},
+		{
+			{"a", "bar"},
+			{"b", "x"},
+		},
+		{
+			{"a", "foo"},
+			{"b", "y"},
+		},
+		{
+			{"a", ""},
+			{"b", "y"},
+		},
+		{
+			{"b", "y"},
+		},
+		{
+			{"a", "baz"},
+		},
+	}
+
+	f("stats mode(a) as x", rows, [][]Field{
+		{
+			{"x", "foo"},
+		},
+	})
+	f("stats mode(b) as x", rows, [][]Field{
+		{
+			{"x", "y"},
+		},
+	})
+	f("stats mode(a, b) as x", rows, [][]Field{
+		{
+			{"x", "y"},
+		},
+	})
+	f("stats mode(c) as x", rows, [][]Field{
+		{
+			{"x", ""},
+		},
+	})
+	f("stats by (b) mode(a) as x", rows, [][]Field{
+		{
+			{"b", "x"},
+			{"x", "bar"},
+		},
+		{
+			{"b", "y"},
+			{"x", "foo"},
+		},
+		{
+			{"b", ""},
+			{"x", "baz"},
+		},
+	})
+}
+
+func TestStatsMode_ExportImportState(t *testing.T) {
+	f := func(smp *statsModeProcessor, dataLenExpected, stateSizeExpected int) {
+		t.Helper()
+
+		data := smp.exportState(nil, nil)
+		dataLen := len(data)
+		if dataLen != dataLenExpected {
+			t.Fatalf("unexpected dataLen; got %d; want %d", dataLen, dataLenExpected)
+		}
+
+		var a chunkedAllocator
+		smp2 := &statsModeProcessor{
+			a: &a,
+		}
+		stateSize, err := smp2.importState(data, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if stateSize != stateSizeExpected {
+			t.Fatalf("unexpected state size; got %d bytes; want %d bytes", stateSize, stateSizeExpected)
+		}
+
+		if !reflect.DeepEqual(smp.m, smp2.m) {
+			t.Fatalf("unexpected state imported; got %#v; want %#v", smp2.m, smp.m)
+		}
+	}
+
+	smp := &statsModeProcessor{
+		m: map[string]uint64{},
+	}
+	f(smp, 1, 0)
+
+	smp = &statsModeProcessor{
+		m: map[string]uint64{
+			"foo": 3,
+			"bar": 200,
+		},
+	}
+	f(smp, 12, 54)
+}
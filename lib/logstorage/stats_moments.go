@@ -0,0 +1,239 @@
+package logstorage
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/prefixfilter"
+)
+
+// statsMoments calculates stats based on central moments - stddev, stdvar, skew and kurtosis.
+type statsMoments struct {
+	// funcName is one of stddev, stdvar, skew or kurtosis
+	funcName string
+
+	fieldFilters []string
+}
+
+func (sm *statsMoments) String() string {
+	return sm.funcName + "(" + fieldNamesString(sm.fieldFilters) + ")"
+}
+
+func (sm *statsMoments) updateNeededFields(pf *prefixfilter.Filter) {
+	pf.AddAllowFilters(sm.fieldFilters)
+}
+
+func (sm *statsMoments) newStatsProcessor(a *chunkedAllocator) statsProcessor {
+	return a.newStatsMomentsProcessor()
+}
+
+type statsMomentsProcessor struct {
+	ms momentsState
+}
+
+func (smp *statsMomentsProcessor) updateStatsForAllRows(sf statsFunc, br *blockResult) int {
+	sm := sf.(*statsMoments)
+
+	mc := getMatchingColumns(br, sm.fieldFilters)
+	for _, c := range mc.cs {
+		if c.isConst {
+			f, ok := tryParseFloat64(c.valuesEncoded[0])
+			if ok {
+				smp.ms.merge(&momentsState{
+					n:    uint64(br.rowsLen),
+					mean: f,
+				})
+			}
+			continue
+		}
+		for rowIdx := 0; rowIdx < br.rowsLen; rowIdx++ {
+			f, ok := c.getFloatValueAtRow(br, rowIdx)
+			if ok {
+				smp.ms.update(f)
+			}
+		}
+	}
+	putMatchingColumns(mc)
+
+	return 0
+}
+
+func (smp *statsMomentsProcessor) updateStatsForRow(sf statsFunc, br *blockResult, rowIdx int) int {
+	sm := sf.(*statsMoments)
+
+	mc := getMatchingColumns(br, sm.fieldFilters)
+	for _, c := range mc.cs {
+		f, ok := c.getFloatValueAtRow(br, rowIdx)
+		if ok {
+			smp.ms.update(f)
+		}
+	}
+	putMatchingColumns(mc)
+
+	return 0
+}
+
+func (smp *statsMomentsProcessor) mergeState(_ *chunkedAllocator, _ statsFunc, sfp statsProcessor) {
+	src := sfp.(*statsMomentsProcessor)
+	smp.ms.merge(&src.ms)
+}
+
+func (smp *statsMomentsProcessor) exportState(dst []byte, _ <-chan struct{}) []byte {
+	return smp.ms.marshal(dst)
+}
+
+func (smp *statsMomentsProcessor) importState(src []byte, _ <-chan struct{}) (int, error) {
+	tail, err := smp.ms.unmarshal(src)
+	if err != nil {
+		return 0, err
+	}
+	if len(tail) > 0 {
+		return 0, fmt.Errorf("unexpected tail left; len(tail)=%d", len(tail))
+	}
+	return 0, nil
+}
+
+func (smp *statsMomentsProcessor) finalizeStats(sf statsFunc, dst []byte, _ <-chan struct{}) []byte {
+	sm := sf.(*statsMoments)
+	f := smp.ms.getResult(sm.funcName)
+	return strconv.AppendFloat(dst, f, 'f', -1, 64)
+}
+
+func parseStatsStddev(lex *lexer) (statsFunc, error) {
+	return parseStatsMoments(lex, "stddev")
+}
+
+func parseStatsStdvar(lex *lexer) (statsFunc, error) {
+	return parseStatsMoments(lex, "stdvar")
+}
+
+func parseStatsSkew(lex *lexer) (statsFunc, error) {
+	return parseStatsMoments(lex, "skew")
+}
+
+func parseStatsKurtosis(lex *lexer) (statsFunc, error) {
+	return parseStatsMoments(lex, "kurtosis")
+}
+
+func parseStatsMoments(lex *lexer, funcName string) (statsFunc, error) {
+	fieldFilters, err := parseStatsFuncFieldFilters(lex, funcName)
+	if err != nil {
+		return nil, err
+	}
+	sm := &statsMoments{
+		funcName:     funcName,
+		fieldFilters: fieldFilters,
+	}
+	return sm, nil
+}
+
+// momentsState holds the number of values, their mean and the sums of powers of differences from the mean (central moments) up to the 4th order.
+//
+// The state is updated and merged with numerically stable algorithms from https://en.wikipedia.org/wiki/Algorithms_for_calculating_variance#Higher-order_statistics
+type momentsState struct {
+	n    uint64
+	mean float64
+	m2   float64
+	m3   float64
+	m4   float64
+}
+
+func (ms *momentsState) update(x float64) {
+	n1 := float64(ms.n)
+	ms.n++
+	n := float64(ms.n)
+
+	delta := x - ms.mean
+	deltaN := delta / n
+	deltaN2 := deltaN * deltaN
+	term1 := delta * deltaN * n1
+
+	ms.mean += deltaN
+	ms.m4 += term1*deltaN2*(n*n-3*n+3) + 6*deltaN2*ms.m2 - 4*deltaN*ms.m3
+	ms.m3 += term1*deltaN*(n-2) - 3*deltaN*ms.m2
+	ms.m2 += term1
+}
+
+func (ms *momentsState) merge(src *momentsState) {
+	if src.n == 0 {
+		return
+	}
+	if ms.n == 0 {
+		*ms = *src
+		return
+	}
+
+	na := float64(ms.n)
+	nb := float64(src.n)
+	n := na + nb
+
+	delta := src.mean - ms.mean
+	delta2 := delta * delta
+	delta3 := delta2 * delta
+	delta4 := delta2 * delta2
+
+	mean := ms.mean + delta*nb/n
+	m2 := ms.m2 + src.m2 + delta2*na*nb/n
+	m3 := ms.m3 + src.m3 + delta3*na*nb*(na-nb)/(n*n) + 3*delta*(na*src.m2-nb*ms.m2)/n
+	m4 := ms.m4 + src.m4 + delta4*na*nb*(na*na-na*nb+nb*nb)/(n*n*n) + 6*delta2*(na*na*src.m2+nb*nb*ms.m2)/(n*n) + 4*delta*(na*src.m3-nb*ms.m3)/n
+
+	ms.n += src.n
+	ms.mean = mean
+	ms.m2 = m2
+	ms.m3 = m3
+	ms.m4 = m4
+}
+
+// getResult returns the result for the given funcName.
+//
+// NaN is returned if there are no values.
+func (ms *momentsState) getResult(funcName string) float64 {
+	if ms.n == 0 {
+		return nan
+	}
+	n := float64(ms.n)
+	switch funcName {
+	case "stddev":
+		return math.Sqrt(ms.m2 / n)
+	case "stdvar":
+		return ms.m2 / n
+	case "skew":
+		return math.Sqrt(n) * ms.m3 / math.Pow(ms.m2, 1.5)
+	case "kurtosis":
+		return n*ms.m4/(ms.m2*ms.m2) - 3
+	default:
+		return nan
+	}
+}
+
+func (ms *momentsState) marshal(dst []byte) []byte {
+	dst = encoding.MarshalVarUint64(dst, ms.n)
+	dst = marshalFloat64(dst, ms.mean)
+	dst = marshalFloat64(dst, ms.m2)
+	dst = marshalFloat64(dst, ms.m3)
+	dst = marshalFloat64(dst, ms.m4)
+	return dst
+}
+
+func (ms *momentsState) unmarshal(src []byte) ([]byte, error) {
+	n, nSize := encoding.UnmarshalVarUint64(src)
+	if nSize <= 0 {
+		return nil, fmt.Errorf("cannot unmarshal the number of values")
+	}
+	src = src[nSize:]
+
+	if len(src) < 4*8 {
+		return nil, fmt.Errorf("cannot unmarshal moments from %d bytes; need %d bytes", len(src), 4*8)
+	}
+	ms.n = n
+	ms.mean = unmarshalFloat64(bytesutil.ToUnsafeString(src))
+	ms.m2 = unmarshalFloat64(bytesutil.ToUnsafeString(src[8:]))
+	ms.m3 = unmarshalFloat64(bytesutil.ToUnsafeString(src[16:]))
+	ms.m4 = unmarshalFloat64(bytesutil.ToUnsafeString(src[24:]))
+
+	return src[32:], nil
+}
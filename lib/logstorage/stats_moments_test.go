@@ -0,0 +1,159 @@
+package logstorage
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestParseStatsMomentsSuccess(t *testing.T) {
+	f := func(pipeStr string) {
+		t.Helper()
+		expectParseStatsFuncSuccess(t, pipeStr)
+	}
+
+	f(`stddev(*)`)
+	f(`stddev(a)`)
+	f(`stdvar(a, b)`)
+	f(`skew(a*, b)`)
+	f(`kurtosis(a)`)
+}
+
+func TestParseStatsMomentsFailure(t *testing.T) {
+	f := func(pipeStr string) {
+		t.Helper()
+		expectParseStatsFuncFailure(t, pipeStr)
+	}
+
+	f(`stddev`)
+	f(`stdvar(a b)`)
+	f(`skew(x) y`)
+	f(`kurtosis(`)
+}
+
+func TestStatsMoments(t *testing.T) {
+	f := func(pipeStr string, rows, rowsExpected [][]Field) {
+		t.Helper()
+		expectPipeResults(t, pipeStr, rows, rowsExpected)
+	}
+
+	// Use only two numeric values, so the results do not depend on the order of merging the per-block states
+	rows := [][]Field{
+		{
+			{"_msg", "abc"},
+			{"a", "2"},
+		},
+		{
+			{"_msg", "def"},
+			{"a", "4"},
+		},
+		{
+			{"a", "foo"},
+			{"b", "3"},
+		},
+	}
+
+	f("stats stddev(a) as x", rows, [][]Field{
+		{
+			{"x", "1"},
+		},
+	})
+	f("stats stdvar(a) as x", rows, [][]Field{
+		{
+			{"x", "1"},
+		},
+	})
+	f("stats skew(a) as x", rows, [][]Field{
+		{
+			{"x", "0"},
+		},
+	})
+	f("stats kurtosis(a) as x", rows, [][]Field{
+		{
+			{"x", "-2"},
+		},
+	})
+	f("stats stddev(c) as x", rows, [][]Field{
+		{
+			{"x", "NaN"},
+		},
+	})
+	f("stats by (b) stdvar(a, b) as x", rows, [][]Field{
+		{
+			{"b", ""},
+			{"x", "1"},
+		},
+		{
+			{"b", "3"},
+			{"x", "0"},
+		},
+	})
+}
+
+func TestMomentsStateMerge(t *testing.T) {
+	values := []float64{1.5, -3, 8, 2, 2, 11.25, 0, -7, 4, 3}
+
+	var msExpected momentsState
+	for _, v := range values {
+		msExpected.update(v)
+	}
+
+	for i := 0; i <= len(values); i++ {
+		var ms1, ms2 momentsState
+		for _, v := range values[:i] {
+			ms1.update(v)
+		}
+		for _, v := range values[i:] {
+			ms2.update(v)
+		}
+		ms1.merge(&ms2)
+
+		for _, funcName := range []string{"stddev", "stdvar", "skew", "kurtosis"} {
+			result := ms1.getResult(funcName)
+			resultExpected := msExpected.getResult(funcName)
+			if math.Abs(result-resultExpected) > 1e-9 {
+				t.Fatalf("unexpected %s result after merging at %d; got %v; want %v", funcName, i, result, resultExpected)
+			}
+		}
+	}
+}
+
+func TestStatsMoments_ExportImportState(t *testing.T) {
+	f := func(smp *statsMomentsProcessor, dataLenExpected int) {
+		t.Helper()
+
+		data := smp.exportState(nil, nil)
+		dataLen := len(data)
+		if dataLen != dataLenExpected {
+			t.Fatalf("unexpected dataLen; got %d; want %d", dataLen, dataLenExpected)
+		}
+
+		var smp2 statsMomentsProcessor
+		stateSize, err := smp2.importState(data, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if stateSize != 0 {
+			t.Fatalf("unexpected state size; got %d bytes; want 0 bytes", stateSize)
+		}
+
+		if !reflect.DeepEqual(smp, &smp2) {
+			t.Fatalf("unexpected state imported; got %#v; want %#v", &smp2, smp)
+		}
+	}
+
+	var smp statsMomentsProcessor
+
+	f(&smp, 33)
+
+	smp = statsMomentsProcessor{
+		ms: momentsState{
+			n:    234,
+			mean: 12.5,
+			m2:   3.25,
+			m3:   -1.5,
+			m4:   8.125,
+		},
+	}
+	f(&smp, 34)
+}
@@ -69,11 +69,11 @@ type StorageConfig struct {
 
 	// MaxDiskSpaceUsageBytes is an optional maximum disk space logs can use.
 	//
-	// The oldest per-day partitions are automatically dropped if the total disk space usage exceeds this limit.
+	// The oldest partitions are automatically dropped if the total disk space usage exceeds this limit.
 	MaxDiskSpaceUsageBytes int64
 
 	// MaxDiskUsagePercent is an optional threshold in percentage (1-100) for disk usage of the filesystem holding the storage path.
-	// When the current disk usage exceeds this percentage, the oldest per-day partitions are automatically dropped.
+	// When the current disk usage exceeds this percentage, the oldest partitions are automatically dropped.
 	MaxDiskUsagePercent int
 
 	// FlushInterval is the interval for flushing the in-memory data to disk at the Storage.
@@ -103,6 +103,28 @@ type StorageConfig struct {
 	//
 	// This can be useful for debugging of data ingestion.
 	LogIngestedRows bool
+
+	// EncryptionKeys is an optional keys for encryption at rest of the data stored in parts.
+	//
+	// If it is nil, then the newly created parts aren't encrypted.
+	EncryptionKeys *EncryptionKeys
+
+	// PartitionInterval is the time range covered by every newly created partition.
+	//
+	// Supported values are 1h, 6h, 1d and 1w. Per-day partitions are used if it is zero.
+	// Partitions created with other intervals remain readable after changing PartitionInterval.
+	PartitionInterval time.Duration
+
+	// AdaptiveCompression enables choosing the best codec per each column block when writing file parts.
+	//
+	// Big parts are additionally compressed with higher zstd levels. This reduces disk space usage at the cost of higher CPU usage during merges.
+	AdaptiveCompression bool
+
+	// Follower opens the storage in read-only follower mode.
+	//
+	// The follower storage doesn't accept new logs, doesn't merge parts and doesn't apply retention and delete tasks.
+	// Its partitions are replicated from the primary storage via PartitionReplicate() and dropped via PartitionDrop().
+	Follower bool
 }
 
 // Storage is the storage for log entries.
@@ -125,7 +147,7 @@ type Storage struct {
 
 	// maxDiskSpaceUsageBytes is an optional maximum disk space logs can use.
 	//
-	// The oldest per-day partitions are automatically dropped if the total disk space usage exceeds this limit.
+	// The oldest partitions are automatically dropped if the total disk space usage exceeds this limit.
 	maxDiskSpaceUsageBytes int64
 
 	// maxDiskUsagePercent is an optional threshold for disk usage percentage at which the oldest partitions are automatically dropped.
@@ -149,6 +171,18 @@ type Storage struct {
 	// logIngestedRows instructs to log all the ingested log entries if it is set to true
 	logIngestedRows bool
 
+	// encryptionKeys contains keys for encryption at rest of the data stored in parts. It is nil if encryption is disabled.
+	encryptionKeys *EncryptionKeys
+
+	// partitionInterval is the time range in nanoseconds covered by every newly created partition.
+	partitionInterval int64
+
+	// adaptiveCompression enables choosing the best codec per each column block when writing file parts.
+	adaptiveCompression bool
+
+	// follower indicates whether the storage is opened in read-only follower mode.
+	follower bool
+
 	// flockF is a file, which makes sure that the Storage is opened by a single process
 	flockF *os.File
 
@@ -164,12 +198,12 @@ type Storage struct {
 	// It must be accessed under partitionsLock.
 	ptwHot *partitionWrapper
 
-	// deletedPartitions contains days for the deleted partitions.
+	// deletedPartitions contains time ranges for the deleted partitions.
 	//
-	// It prevents from re-creating already deleted partitions.
+	// It prevents from re-creating already deleted partitions, including partitions with other intervals overlapping them.
 	//
 	// It must be accessed under partitionsLock.
-	deletedPartitions []int64
+	deletedPartitions []partitionTimeRange
 
 	// partitionsLock protects partitions, ptwHot, deletedPartitions.
 	partitionsLock sync.Mutex
@@ -191,20 +225,27 @@ type Storage struct {
 	// It reduces the load on persistent storage during querying by _stream:{...} filter.
 	filterStreamCache *cache
 
-	// deleteTasksLock protects deleteTasks
+	// deleteTasksLock protects deleteTasks and finishedDeleteTasks
 	deleteTasksLock sync.Mutex
 
 	// deleteTasks contains a list of active and pending delete tasks
 	deleteTasks []*DeleteTask
+
+	// finishedDeleteTasks contains up to maxFinishedDeleteTasks the most recently finished delete tasks
+	finishedDeleteTasks []*DeleteTask
 }
 
+// maxFinishedDeleteTasks is the maximum number of finished delete tasks to keep in the history.
+const maxFinishedDeleteTasks = 1000
+
 // PartitionAttach attaches the partition with the given name to s.
 //
-// The name must have the YYYYMMDD format.
+// The name must have the YYYYMMDD format for per-day partitions, the YYYYMMDDhh_1h or YYYYMMDDhh_6h format for hourly partitions
+// and the YYYYMMDD_1w format for weekly partitions.
 //
 // The attached partition can be detached via PartitionDetach() call.
 func (s *Storage) PartitionAttach(name string) error {
-	day, err := getPartitionDayFromName(name)
+	minTimestamp, maxTimestamp, err := getPartitionTimeRangeFromName(name)
 	if err != nil {
 		return err
 	}
@@ -212,7 +253,7 @@ func (s *Storage) PartitionAttach(name string) error {
 	s.partitionsLock.Lock()
 	defer s.partitionsLock.Unlock()
 
-	if slices.Contains(s.deletedPartitions, day) {
+	if hasOverlappingTimeRange(s.deletedPartitions, minTimestamp, maxTimestamp) {
 		return fmt.Errorf("cannot attach the partition %q, since it is automatically deleted because of retention; see https://docs.victoriametrics.com/victorialogs/#retention", name)
 	}
 
@@ -223,6 +264,11 @@ func (s *Storage) PartitionAttach(name string) error {
 		}
 	}
 
+	// Partitions mustn't overlap, since every log entry must belong to a single partition.
+	if ptws := s.getPartitionsForTimeRangeLocked(minTimestamp, maxTimestamp); len(ptws) > 0 {
+		return fmt.Errorf("cannot attach the partition %q, because it overlaps the already attached partition %q", name, ptws[0].pt.name)
+	}
+
 	// Open the partition and add it to the s.partitions.
 	partitionsPath := filepath.Join(s.path, partitionsDirname)
 	partitionPath := filepath.Join(partitionsPath, name)
@@ -231,7 +277,7 @@ func (s *Storage) PartitionAttach(name string) error {
 	}
 
 	pt := mustOpenPartition(s, partitionPath)
-	ptw := newPartitionWrapper(pt, day)
+	ptw := newPartitionWrapper(pt, minTimestamp, maxTimestamp)
 
 	s.partitions = append(s.partitions, ptw)
 	sortPartitions(s.partitions)
@@ -243,7 +289,7 @@ func (s *Storage) PartitionAttach(name string) error {
 
 // PartitionDetach detaches the partition with the given name from s.
 //
-// The name must have the YYYYMMDD format.
+// The name must have one of the formats supported by PartitionAttach().
 //
 // The detached partition can be attached again via PartitionAttach() call.
 func (s *Storage) PartitionDetach(name string) error {
@@ -283,7 +329,7 @@ func (s *Storage) PartitionDetach(name string) error {
 
 // PartitionList returns the list of the names for the currently attached partitions.
 //
-// Every partition name has YYYYMMDD format.
+// Every partition name has one of the formats supported by PartitionAttach().
 func (s *Storage) PartitionList() []string {
 	s.partitionsLock.Lock()
 	ptNames := make([]string, len(s.partitions))
@@ -297,7 +343,7 @@ func (s *Storage) PartitionList() []string {
 
 // PartitionSnapshotCreate creates a snapshot for the partition with the given name
 //
-// The snaphsot name must have YYYYMMDD format.
+// The partition name must have one of the formats supported by PartitionAttach().
 //
 // The function returns an absolute path to the created snapshot on success.
 func (s *Storage) PartitionSnapshotCreate(name string) (string, error) {
@@ -369,17 +415,39 @@ func (s *Storage) PartitionSnapshotList() []string {
 //
 // The taskID must contain an unique id of the task. It is used for tracking the task at the list returned by DeleteActiveTasks().
 // The timestamp must contain the timestamp in seconds when the task is started.
-func (s *Storage) DeleteRunTask(_ context.Context, taskID string, timestamp int64, tenantIDs []TenantID, f *Filter) error {
-	// Register the task in the list of active delete tasks, so it survives application restarts and crashes.
-	dt := newDeleteTask(taskID, tenantIDs, f.String(), timestamp)
+//
+// If dryRun is set, then the task only counts logs matching f without deleting them.
+func (s *Storage) DeleteRunTask(_ context.Context, taskID string, timestamp int64, tenantIDs []TenantID, f *Filter, dryRun bool) error {
+	if s.follower {
+		return fmt.Errorf("cannot run delete task at the follower storage; run it at the primary storage instead")
+	}
+	dt := newDeleteTask(taskID, tenantIDs, f.String(), "", timestamp, dryRun)
+	return s.registerDeleteTask(dt)
+}
 
+// UpdateRunTask starts updating of logs according to the given filter f for the given tenantIDs with the given ru.
+//
+// The task is executed in the same queue as the tasks started via DeleteRunTask(), so it can be tracked and canceled in the same way.
+// The timestamp must contain the timestamp in seconds when the task is started.
+//
+// If dryRun is set, then the task only counts logs matching f without updating them.
+func (s *Storage) UpdateRunTask(_ context.Context, taskID string, timestamp int64, tenantIDs []TenantID, f *Filter, ru *RowsUpdate, dryRun bool) error {
+	if s.follower {
+		return fmt.Errorf("cannot run update task at the follower storage; run it at the primary storage instead")
+	}
+	dt := newDeleteTask(taskID, tenantIDs, f.String(), ru.String(), timestamp, dryRun)
+	return s.registerDeleteTask(dt)
+}
+
+func (s *Storage) registerDeleteTask(dt *DeleteTask) error {
+	// Register the task in the list of active delete tasks, so it survives application restarts and crashes.
 	s.deleteTasksLock.Lock()
 	defer s.deleteTasksLock.Unlock()
 
 	// Verify that the task with the given taskID doesn't exist yet
-	for _, dt := range s.deleteTasks {
-		if dt.TaskID == taskID {
-			return fmt.Errorf("the delete task with task_id=%q is already registered", taskID)
+	for _, dtExisting := range s.deleteTasks {
+		if dtExisting.TaskID == dt.TaskID {
+			return fmt.Errorf("the delete task with task_id=%q is already registered", dt.TaskID)
 		}
 	}
 
@@ -398,6 +466,19 @@ func (s *Storage) mustSaveDeleteTasksLocked() {
 	mustWriteDeleteTasksToFile(deleteTasksPath, s.deleteTasks)
 }
 
+// addFinishedDeleteTaskLocked adds the finished dt to s.finishedDeleteTasks and persists them to file.
+//
+// The s.deleteTaskLock must be locked while calling this function.
+func (s *Storage) addFinishedDeleteTaskLocked(dt *DeleteTask) {
+	s.finishedDeleteTasks = append(s.finishedDeleteTasks, dt)
+	if n := len(s.finishedDeleteTasks) - maxFinishedDeleteTasks; n > 0 {
+		s.finishedDeleteTasks = append(s.finishedDeleteTasks[:0], s.finishedDeleteTasks[n:]...)
+	}
+
+	finishedDeleteTasksPath := filepath.Join(s.path, finishedDeleteTasksFilename)
+	mustWriteDeleteTasksToFile(finishedDeleteTasksPath, s.finishedDeleteTasks)
+}
+
 // DeleteStopTask stops the delete task with the given taskID.
 //
 // It waits until the task is stopped before returning.
@@ -420,6 +501,9 @@ func (s *Storage) DeleteStopTask(ctx context.Context, taskID string) error {
 			// The task is waiting to be executed. Drop it.
 			s.deleteTasks = append(s.deleteTasks[:i], s.deleteTasks[i+1:]...)
 			s.mustSaveDeleteTasksLocked()
+
+			dt.finish(DeleteTaskStatusCanceled, nil)
+			s.addFinishedDeleteTaskLocked(dt)
 		}
 		break
 	}
@@ -442,12 +526,31 @@ func (s *Storage) DeleteStopTask(ctx context.Context, taskID string) error {
 // DeleteActiveTasks returns currently running active delete tasks, which were started via DeleteRunTask().
 func (s *Storage) DeleteActiveTasks(_ context.Context) ([]*DeleteTask, error) {
 	s.deleteTasksLock.Lock()
-	dts := append([]*DeleteTask{}, s.deleteTasks...)
+	dts := cloneDeleteTasks(s.deleteTasks)
 	s.deleteTasksLock.Unlock()
 
 	return dts, nil
 }
 
+// DeleteFinishedTasks returns up to 1000 the most recently finished delete tasks, which were started via DeleteRunTask().
+//
+// The returned tasks include completed, failed and canceled tasks.
+func (s *Storage) DeleteFinishedTasks(_ context.Context) ([]*DeleteTask, error) {
+	s.deleteTasksLock.Lock()
+	dts := cloneDeleteTasks(s.finishedDeleteTasks)
+	s.deleteTasksLock.Unlock()
+
+	return dts, nil
+}
+
+func cloneDeleteTasks(dts []*DeleteTask) []*DeleteTask {
+	result := make([]*DeleteTask, len(dts))
+	for i, dt := range dts {
+		result[i] = dt.clone()
+	}
+	return result
+}
+
 // EnableLogNewStreams enables logging newly ingested streams during the given number of seconds
 func (s *Storage) EnableLogNewStreams(seconds int) {
 	if seconds <= 0 {
@@ -478,8 +581,11 @@ type partitionWrapper struct {
 	// mustDrop is set when the partition must be deleted after refCount reaches zero.
 	mustDrop atomic.Bool
 
-	// day is the day for the partition in the unix timestamp divided by the number of seconds in the day.
-	day int64
+	// minTimestamp is the minimum timestamp in nanoseconds covered by the partition.
+	minTimestamp int64
+
+	// maxTimestamp is the maximum timestamp in nanoseconds covered by the partition.
+	maxTimestamp int64
 
 	// pt is the wrapped partition.
 	pt *partition
@@ -488,11 +594,12 @@ type partitionWrapper struct {
 	doneCh chan struct{}
 }
 
-func newPartitionWrapper(pt *partition, day int64) *partitionWrapper {
+func newPartitionWrapper(pt *partition, minTimestamp, maxTimestamp int64) *partitionWrapper {
 	pw := &partitionWrapper{
-		day:    day,
-		pt:     pt,
-		doneCh: make(chan struct{}),
+		minTimestamp: minTimestamp,
+		maxTimestamp: maxTimestamp,
+		pt:           pt,
+		doneCh:       make(chan struct{}),
 	}
 	pw.incRef()
 	return pw
@@ -526,11 +633,24 @@ func (ptw *partitionWrapper) decRef() {
 	close(ptw.doneCh)
 }
 
+// isLess returns true if ptw must be put before other in the sorted list of partitions.
+//
+// Partitions are sorted by minTimestamp. Partitions with the same minTimestamp are sorted by maxTimestamp.
+func (ptw *partitionWrapper) isLess(other *partitionWrapper) bool {
+	if ptw.minTimestamp != other.minTimestamp {
+		return ptw.minTimestamp < other.minTimestamp
+	}
+	return ptw.maxTimestamp < other.maxTimestamp
+}
+
+// overlapsTimeRange returns true if ptw may contain logs on the given [minTimestamp, maxTimestamp] time range.
+func (ptw *partitionWrapper) overlapsTimeRange(minTimestamp, maxTimestamp int64) bool {
+	return ptw.minTimestamp <= maxTimestamp && ptw.maxTimestamp >= minTimestamp
+}
+
 func (ptw *partitionWrapper) canAddAllRows(lr *LogRows) bool {
-	minTimestamp := ptw.day * nsecsPerDay
-	maxTimestamp := minTimestamp + nsecsPerDay - 1
 	for _, ts := range lr.timestamps {
-		if ts < minTimestamp || ts > maxTimestamp {
+		if ts < ptw.minTimestamp || ts > ptw.maxTimestamp {
 			return false
 		}
 	}
@@ -576,6 +696,14 @@ func MustOpenStorage(path string, cfg *StorageConfig) *Storage {
 		minFreeDiskSpaceBytes = uint64(cfg.MinFreeDiskSpaceBytes)
 	}
 
+	partitionInterval := cfg.PartitionInterval
+	if partitionInterval == 0 {
+		partitionInterval = 24 * time.Hour
+	}
+	if err := ValidatePartitionInterval(partitionInterval); err != nil {
+		logger.Panicf("BUG: %s", err)
+	}
+
 	if !fs.IsPathExist(path) {
 		mustCreateStorage(path)
 	}
@@ -589,6 +717,13 @@ func MustOpenStorage(path string, cfg *StorageConfig) *Storage {
 	// Load delete tasks which may be left since the previous restart
 	deleteTasksPath := filepath.Join(path, deleteTasksFilename)
 	deleteTasks := mustReadDeleteTasksFromFile(deleteTasksPath)
+	for _, dt := range deleteTasks {
+		// The task could be running or could be saved by the previous releases without the status.
+		dt.Status = DeleteTaskStatusPending
+		dt.progress.restore(&dt.Progress)
+	}
+	finishedDeleteTasksPath := filepath.Join(path, finishedDeleteTasksFilename)
+	finishedDeleteTasks := mustReadDeleteTasksFromFile(finishedDeleteTasksPath)
 
 	s := &Storage{
 		path:                   path,
@@ -601,13 +736,18 @@ func MustOpenStorage(path string, cfg *StorageConfig) *Storage {
 		maxBackfillAge:         maxBackfillAge,
 		minFreeDiskSpaceBytes:  minFreeDiskSpaceBytes,
 		logIngestedRows:        cfg.LogIngestedRows,
+		encryptionKeys:         cfg.EncryptionKeys,
+		partitionInterval:      partitionInterval.Nanoseconds(),
+		adaptiveCompression:    cfg.AdaptiveCompression,
+		follower:               cfg.Follower,
 		flockF:                 flockF,
 		stopCh:                 make(chan struct{}),
 
 		streamIDCache:     streamIDCache,
 		filterStreamCache: filterStreamCache,
 
-		deleteTasks: deleteTasks,
+		deleteTasks:         deleteTasks,
+		finishedDeleteTasks: finishedDeleteTasks,
 	}
 	s.logNewStreams.Store(cfg.LogNewStreams)
 
@@ -615,6 +755,9 @@ func MustOpenStorage(path string, cfg *StorageConfig) *Storage {
 	fs.MustMkdirIfNotExist(partitionsPath)
 	fs.MustSyncPath(path)
 
+	// Replace partitions with their most recent replicas, which could be received before the restart.
+	mustRestoreReplicas(path)
+
 	des := fs.MustReadDir(partitionsPath)
 	ptws := make([]*partitionWrapper, len(des))
 
@@ -640,14 +783,14 @@ func MustOpenStorage(path string, cfg *StorageConfig) *Storage {
 				wg.Done()
 			}()
 
-			day, err := getPartitionDayFromName(fname)
+			minTimestamp, maxTimestamp, err := getPartitionTimeRangeFromName(fname)
 			if err != nil {
 				logger.Panicf("FATAL: cannot parse partition filename %q at %q: %s", fname, partitionsPath, err)
 			}
 
 			partitionPath := filepath.Join(partitionsPath, fname)
 			pt := mustOpenPartition(s, partitionPath)
-			ptws[idx] = newPartitionWrapper(pt, day)
+			ptws[idx] = newPartitionWrapper(pt, minTimestamp, maxTimestamp)
 		}(i)
 	}
 	wg.Wait()
@@ -656,11 +799,11 @@ func MustOpenStorage(path string, cfg *StorageConfig) *Storage {
 
 	// Delete partitions from the future if needed
 	now := time.Now().UnixNano()
-	maxAllowedDay := s.getMaxAllowedDay(now)
+	maxAllowedTimestamp := s.getMaxAllowedTimestamp(now)
 	j := len(ptws) - 1
 	for j >= 0 {
 		ptw := ptws[j]
-		if ptw.day <= maxAllowedDay {
+		if ptw.minTimestamp <= maxAllowedTimestamp {
 			break
 		}
 		logger.Infof("the partition %s is scheduled to be deleted because it is outside the -futureRetention=%dd", ptw.pt.path, durationToDays(s.futureRetention))
@@ -675,18 +818,54 @@ func MustOpenStorage(path string, cfg *StorageConfig) *Storage {
 	ptws = ptws[:j]
 
 	s.partitions = ptws
-	s.runRetentionWatcher()
-	s.runMaxDiskSpaceUsageWatcher()
-	s.runDeleteTasksWatcher()
+	if !s.follower {
+		// The follower storage mirrors partitions from the primary storage,
+		// which applies retention and delete tasks on its own.
+		s.runRetentionWatcher()
+		s.runMaxDiskSpaceUsageWatcher()
+		s.runDeleteTasksWatcher()
+	}
 	return s
 }
 
 func sortPartitions(ptws []*partitionWrapper) {
 	sort.Slice(ptws, func(i, j int) bool {
-		return ptws[i].day < ptws[j].day
+		return ptws[i].isLess(ptws[j])
 	})
 }
 
+// getPartitionsForTimeRangeLocked returns partitions from s.partitions, which overlap the given [minTimestamp, maxTimestamp] time range.
+//
+// The returned slice doesn't refer to s.partitions, so it can be modified by the caller.
+// s.partitionsLock must be locked when calling this function.
+func (s *Storage) getPartitionsForTimeRangeLocked(minTimestamp, maxTimestamp int64) []*partitionWrapper {
+	// s.partitions are sorted by minTimestamp. Use binary search for finding partitions for the given [minTimestamp, maxTimestamp] time range.
+	// Partitions may have distinct intervals if the partition interval has been changed,
+	// so start the search from the partitions, which may contain minTimestamp for the maximum partition interval.
+	ptws := s.partitions
+	minPartitionTimestamp := minTimestamp - maxPartitionInterval
+	if minPartitionTimestamp > minTimestamp {
+		// Overflow
+		minPartitionTimestamp = math.MinInt64
+	}
+	n := sort.Search(len(ptws), func(i int) bool {
+		return ptws[i].minTimestamp > minPartitionTimestamp
+	})
+	ptws = ptws[n:]
+	n = sort.Search(len(ptws), func(i int) bool {
+		return ptws[i].minTimestamp > maxTimestamp
+	})
+	ptws = ptws[:n]
+
+	var result []*partitionWrapper
+	for _, ptw := range ptws {
+		if ptw.overlapsTimeRange(minTimestamp, maxTimestamp) {
+			result = append(result, ptw)
+		}
+	}
+	return result
+}
+
 func (s *Storage) runRetentionWatcher() {
 	s.wg.Add(1)
 	go func() {
@@ -721,29 +900,29 @@ func (s *Storage) watchRetention() {
 	for {
 		var ptwsToDelete []*partitionWrapper
 		now := time.Now().UnixNano()
-		minAllowedDay := s.getMinAllowedDay(now)
+		minAllowedTimestamp := s.getMinAllowedTimestamp(now)
 
 		s.partitionsLock.Lock()
 
 		// Delete outdated partitions.
-		// s.partitions are sorted by day, so the partitions, which can become outdated, are located at the beginning of the list
-		ptws := s.partitions
-		for i, ptw := range ptws {
-			if ptw.day < minAllowedDay {
-				continue
+		// s.partitions may contain partitions with distinct intervals if the partition interval has been changed,
+		// so check all the partitions instead of stopping at the first partition within the retention.
+		var ptwsToKeep []*partitionWrapper
+		for _, ptw := range s.partitions {
+			if ptw.maxTimestamp < minAllowedTimestamp {
+				ptwsToDelete = append(ptwsToDelete, ptw)
+			} else {
+				ptwsToKeep = append(ptwsToKeep, ptw)
 			}
-
-			// ptws are sorted by time, so just drop all the partitions until i.
-			ptwsToDelete = ptws[:i]
-			s.partitions = ptws[i:]
+		}
+		if len(ptwsToDelete) > 0 {
+			s.partitions = ptwsToKeep
 			s.updateDeletedPartitionsLocked(ptwsToDelete)
 
 			// Remove reference to deleted partitions from s.ptwHot
 			if slices.Contains(ptwsToDelete, s.ptwHot) {
 				s.ptwHot = nil
 			}
-
-			break
 		}
 
 		s.partitionsLock.Unlock()
@@ -801,7 +980,7 @@ func (s *Storage) watchMaxDiskSpaceUsage() {
 				continue
 			}
 			if i >= len(ptws)-2 {
-				// Keep the last two per-day partitions, so logs could be queried for one day time range.
+				// Keep the last two partitions, so logs could be queried for the last partition interval.
 				continue
 			}
 
@@ -864,6 +1043,8 @@ func (s *Storage) watchDeleteTasks() {
 			// with canceling the task at Storage.DeleteStopTask()
 			dt.ctx, dt.cancel = contextutil.NewStopChanContext(s.stopCh)
 			dt.doneCh = make(chan struct{})
+			dt.Status = DeleteTaskStatusRunning
+			dt.progress.resetForAttempt(dt.DryRun)
 		}
 		s.deleteTasksLock.Unlock()
 
@@ -874,12 +1055,24 @@ func (s *Storage) watchDeleteTasks() {
 
 		// Process delete tasks sequentially in order to limit resource usage needed for the logs' deletion.
 
-		ok := s.processDeleteTask(dt.ctx, dt)
+		ok, err := s.processDeleteTask(dt.ctx, dt)
+		isCanceled := needStop(dt.ctx.Done())
 		close(dt.doneCh)
 		dt.cancel()
 
 		s.deleteTasksLock.Lock()
 
+		if ok {
+			switch {
+			case err != nil:
+				dt.finish(DeleteTaskStatusFailed, err)
+			case isCanceled:
+				dt.finish(DeleteTaskStatusCanceled, nil)
+			default:
+				dt.finish(DeleteTaskStatusCompleted, nil)
+			}
+		}
+
 		// Set dt.ctx and dt.cancel to nil under the lock in order to avoid races
 		// with canceling the task at Storage.DeleteStopTask().
 		dt.ctx = nil
@@ -889,9 +1082,15 @@ func (s *Storage) watchDeleteTasks() {
 		s.deleteTasks = s.deleteTasks[1:]
 		if !ok {
 			// The delete task coudn't be completed now. Try it later.
+			// Persist the progress, so the number of already deleted rows survives restarts.
+			dt.Status = DeleteTaskStatusPending
+			dt.Progress = dt.progress.get()
 			s.deleteTasks = append(s.deleteTasks, dt)
 		}
 		s.mustSaveDeleteTasksLocked()
+		if ok {
+			s.addFinishedDeleteTaskLocked(dt)
+		}
 
 		s.deleteTasksLock.Unlock()
 	}
@@ -899,9 +1098,9 @@ func (s *Storage) watchDeleteTasks() {
 
 // processDeleteTask processes dt.
 //
-// true is returned on successfully processed dt or on explicitly canceled dt.
+// true is returned on successfully processed dt, on explicitly canceled dt or on dt, which cannot be processed because of the returned error.
 // false is returned if dt couldn't be processed at the moment, so it must be processed later.
-func (s *Storage) processDeleteTask(ctx context.Context, dt *DeleteTask) bool {
+func (s *Storage) processDeleteTask(ctx context.Context, dt *DeleteTask) (bool, error) {
 	logger.Infof("started processing delete task %s", dt)
 	startTime := time.Now()
 
@@ -910,6 +1109,15 @@ func (s *Storage) processDeleteTask(ctx context.Context, dt *DeleteTask) bool {
 		logger.Panicf("BUG: cannot parse filter from delete task: [%s]", dt.Filter)
 	}
 
+	dt.updater = nil
+	if dt.Update != "" {
+		ru, err := ParseRowsUpdate(dt.Update)
+		if err != nil {
+			logger.Panicf("BUG: cannot parse update from delete task: [%s]", dt.Update)
+		}
+		dt.updater = newRowsUpdater(ru)
+	}
+
 	q := &Query{
 		f:         f.f,
 		timestamp: dt.StartTime.UnixNano(),
@@ -927,8 +1135,16 @@ func (s *Storage) processDeleteTask(ctx context.Context, dt *DeleteTask) bool {
 	// Initialize subqueries
 	qNew, err := initSubqueries(qctx, s.runQuery, true)
 	if err != nil {
-		logger.Errorf("cannot process delete task with task_id=%q while initializing subqueries: %s; retrying later", dt.TaskID, err)
-		return false
+		if needStop(s.stopCh) {
+			logger.Infof("the storage is stopped while initializing subqueries for the delete task with task_id=%q; postponing the task for later execution", dt.TaskID)
+			return false, nil
+		}
+		if needStop(ctx.Done()) {
+			logger.Infof("the delete task with task_id=%q is explicitly canceled after %.3f seconds", dt.TaskID, time.Since(startTime).Seconds())
+			return true, nil
+		}
+		logger.Errorf("cannot process delete task with task_id=%q while initializing subqueries: %s", dt.TaskID, err)
+		return true, fmt.Errorf("cannot initialize subqueries: %w", err)
 	}
 	q = qNew
 
@@ -940,58 +1156,95 @@ func (s *Storage) processDeleteTask(ctx context.Context, dt *DeleteTask) bool {
 
 	// delete rows matching q.f
 	stopCh := ctx.Done()
-	if !s.deleteRows(sso, stopCh) {
+	if !s.deleteRows(sso, dt, stopCh) {
 		if needStop(s.stopCh) {
 			logger.Infof("the storage is stopped while executing the delete task with task_id=%q; postponing the task for later execution", dt.TaskID)
-			return false
+			return false, nil
 		}
 
 		if needStop(stopCh) {
 			// The task has been canceled explicitly. Return true, so it isn't re-scheduled for later execution.
 			logger.Infof("the delete task with task_id=%q is explicitly canceled after %.3f seconds", dt.TaskID, time.Since(startTime).Seconds())
-			return true
+			return true, nil
 		}
 
 		// The task couldn't be processed at the moment
 		logger.Warnf("cannot proceeed with the delete task with task_id=%q in %.3f seconds; retrying it later", dt.TaskID, time.Since(startTime).Seconds())
-		return false
+		return false, nil
 	}
 
-	logger.Infof("finished processing delete task %s in %.3f seconds", dt, time.Since(startTime).Seconds())
-	return true
+	logger.Infof("finished processing delete task %s in %.3f seconds; rows deleted: %d; rows updated: %d",
+		dt, time.Since(startTime).Seconds(), dt.progress.rowsDeleted.Load(), dt.progress.rowsUpdated.Load())
+	return true, nil
 }
 
-func (s *Storage) deleteRows(sso *storageSearchOptions, stopCh <-chan struct{}) bool {
+func (s *Storage) deleteRows(sso *storageSearchOptions, dt *DeleteTask, stopCh <-chan struct{}) bool {
 	ptws, ptwsDecRef := s.getPartitionsForTimeRange(sso.minTimestamp, sso.maxTimestamp)
 	defer ptwsDecRef()
 
+	// Skip partitions processed at the previous attempts, since the update may be non-idempotent.
+	// Rows are re-counted at all the partitions in dry-run mode.
+	if !dt.DryRun {
+		ptws = slices.DeleteFunc(slices.Clone(ptws), func(ptw *partitionWrapper) bool {
+			return slices.Contains(dt.FinishedPartitions, ptw.pt.name)
+		})
+	}
+
+	// Calculate the total number of partitions and parts to process in order to track the progress.
+	dt.progress.partitionsTotal.Store(uint64(len(ptws)))
+	for _, ptw := range ptws {
+		pws, pwsDecRef := ptw.pt.ddb.getPartsForTimeRange(sso.minTimestamp, sso.maxTimestamp)
+		dt.progress.partsTotal.Add(uint64(len(pws)))
+		pwsDecRef()
+	}
+
 	// Delete rows sequentially in every partition in order to limit resource usage needed for the logs' deletion.
 	ok := true
 	for _, ptw := range ptws {
-		if !ptw.pt.deleteRows(sso, stopCh) {
+		if !ptw.pt.deleteRows(sso, dt, stopCh) {
 			// Return false if at least a single deletion was unsuccessful.
 			// Continue deletion of rows at other partitions, since they may be successful.
 			ok = false
+		} else if !dt.DryRun {
+			s.addDeleteTaskFinishedPartition(dt, ptw.pt.name)
 		}
+		dt.progress.partitionsProcessed.Add(1)
 	}
 
 	return ok
 }
 
+// addDeleteTaskFinishedPartition registers the partition with the given name as processed by dt.
+//
+// The delete tasks are persisted immediately, so the partition isn't processed again by dt after unclean shutdown.
+func (s *Storage) addDeleteTaskFinishedPartition(dt *DeleteTask, partitionName string) {
+	s.deleteTasksLock.Lock()
+	dt.FinishedPartitions = append(dt.FinishedPartitions, partitionName)
+	dt.Progress = dt.progress.get()
+	s.mustSaveDeleteTasksLocked()
+	s.deleteTasksLock.Unlock()
+}
+
 func (s *Storage) updateDeletedPartitionsLocked(ptwsToDelete []*partitionWrapper) {
 	for _, ptw := range ptwsToDelete {
-		if !slices.Contains(s.deletedPartitions, ptw.day) {
-			s.deletedPartitions = append(s.deletedPartitions, ptw.day)
+		tr := partitionTimeRange{
+			minTimestamp: ptw.minTimestamp,
+			maxTimestamp: ptw.maxTimestamp,
+		}
+		if !slices.Contains(s.deletedPartitions, tr) {
+			s.deletedPartitions = append(s.deletedPartitions, tr)
 		}
 	}
 }
 
-func (s *Storage) getMinAllowedDay(now int64) int64 {
-	return (now - s.retention.Nanoseconds()) / nsecsPerDay
+// getMinAllowedTimestamp returns the start of the oldest partition, which may be stored according to the configured retention.
+func (s *Storage) getMinAllowedTimestamp(now int64) int64 {
+	return getPartitionMinTimestamp(now-s.retention.Nanoseconds(), s.partitionInterval)
 }
 
-func (s *Storage) getMaxAllowedDay(now int64) int64 {
-	return (now + s.futureRetention.Nanoseconds()) / nsecsPerDay
+// getMaxAllowedTimestamp returns the end of the newest partition, which may be stored according to the configured future retention.
+func (s *Storage) getMaxAllowedTimestamp(now int64) int64 {
+	return getPartitionMinTimestamp(now+s.futureRetention.Nanoseconds(), s.partitionInterval) + s.partitionInterval - 1
 }
 
 // MustClose closes s.
@@ -1036,6 +1289,11 @@ func (s *Storage) MustClose() {
 //
 // Partitions are merged sequentially in order to reduce load on the system.
 func (s *Storage) MustForceMerge(partitionNamePrefix string) {
+	if s.follower {
+		logger.Infof("skipping force merge at the follower storage, since it receives already merged parts from the primary storage")
+		return
+	}
+
 	var ptws []*partitionWrapper
 
 	s.partitionsLock.Lock()
@@ -1067,6 +1325,11 @@ func (s *Storage) MustForceMerge(partitionNamePrefix string) {
 // The added rows become visible for search after small duration of time.
 // Call DebugFlush if the added rows must be queried immediately (for example, in tests).
 func (s *Storage) MustAddRows(lr *LogRows) {
+	if s.follower {
+		followerRowsLogger.Warnf("dropping %d rows, since the storage is in follower mode; send logs to the primary storage instead", len(lr.timestamps))
+		return
+	}
+
 	// Fast path - try adding all the rows to the hot partition
 	s.partitionsLock.Lock()
 	ptwHot := s.ptwHot
@@ -1086,27 +1349,33 @@ func (s *Storage) MustAddRows(lr *LogRows) {
 
 	// Slow path - rows cannot be added to the hot partition, so split rows among available partitions
 	now := time.Now().UnixNano()
-	minAllowedDay := s.getMinAllowedDay(now)
-	maxAllowedDay := s.getMaxAllowedDay(now)
+	minRetentionTimestamp := s.getMinAllowedTimestamp(now)
+	maxRetentionTimestamp := s.getMaxAllowedTimestamp(now)
 	minAllowedTimestamp := now - s.maxBackfillAge.Nanoseconds()
 
-	m := make(map[int64]*LogRows)
+	// Partitions may have distinct intervals if the partition interval has been changed,
+	// so group rows by the partitions containing them instead of grouping them by the configured partition interval.
+	var ptws []*partitionWrapper
+	var lrParts []*LogRows
+
+	// inactiveHours contains hourly time ranges, which cannot be written because of inactive partitions.
+	// Hourly partitions are the smallest ones, so all the timestamps within the hour belong to the same partition.
+	var inactiveHours map[int64]struct{}
 	for i, ts := range lr.timestamps {
-		day := ts / nsecsPerDay
-		if day < minAllowedDay {
+		if ts < minRetentionTimestamp {
 			line := MarshalFieldsToJSON(nil, lr.rows[i])
 			tsf := TimeFormatter(ts)
-			minAllowedTsf := TimeFormatter(minAllowedDay * nsecsPerDay)
+			minAllowedTsf := TimeFormatter(minRetentionTimestamp)
 			tooSmallTimestampLogger.Warnf("skipping log entry with too small timestamp=%s; it must be bigger than %s according "+
 				"to the configured -retentionPeriod=%dd. See https://docs.victoriametrics.com/victorialogs/#retention ; "+
 				"log entry: %s", &tsf, &minAllowedTsf, durationToDays(s.retention), line)
 			s.rowsDroppedTooSmallTimestamp.Add(1)
 			continue
 		}
-		if day > maxAllowedDay {
+		if ts > maxRetentionTimestamp {
 			line := MarshalFieldsToJSON(nil, lr.rows[i])
 			tsf := TimeFormatter(ts)
-			maxAllowedTsf := TimeFormatter(maxAllowedDay * nsecsPerDay)
+			maxAllowedTsf := TimeFormatter(maxRetentionTimestamp)
 			tooBigTimestampLogger.Warnf("skipping log entry with too big timestamp=%s; it must be smaller than %s according "+
 				"to the configured -futureRetention=%dd; see https://docs.victoriametrics.com/victorialogs/#retention ; "+
 				"log entry: %s", &tsf, &maxAllowedTsf, durationToDays(s.futureRetention), line)
@@ -1124,24 +1393,35 @@ func (s *Storage) MustAddRows(lr *LogRows) {
 			continue
 		}
 
-		lrPart := m[day]
-		if lrPart == nil {
-			lrPart = GetLogRows(nil, nil, nil, nil, "")
-			m[day] = lrPart
+		idx := slices.IndexFunc(ptws, func(ptw *partitionWrapper) bool {
+			return ts >= ptw.minTimestamp && ts <= ptw.maxTimestamp
+		})
+		if idx < 0 {
+			hour := getPartitionMinTimestamp(ts, nsecsPerHour)
+			if _, ok := inactiveHours[hour]; ok {
+				continue
+			}
+			ptw := s.getPartitionForWriting(ts)
+			if ptw == nil {
+				if inactiveHours == nil {
+					inactiveHours = make(map[int64]struct{})
+				}
+				inactiveHours[hour] = struct{}{}
+				line := MarshalFieldsToJSON(nil, lr.rows[i])
+				inactivePartitionLogger.Warnf("skipping log entry because it cannot be saved into inactive partition; "+
+					"see https://docs.victoriametrics.com/victorialogs/#partitions-lifecycle; log entry %s", line)
+				continue
+			}
+			idx = len(ptws)
+			ptws = append(ptws, ptw)
+			lrParts = append(lrParts, GetLogRows(nil, nil, nil, nil, ""))
 		}
-		lrPart.mustAddInternal(lr.streamIDs[i], ts, lr.rows[i], lr.streamTagsCanonicals[i])
+		lrParts[idx].mustAddInternal(lr.streamIDs[i], ts, lr.rows[i], lr.streamTagsCanonicals[i])
 	}
-	for day, lrPart := range m {
-		ptw := s.getPartitionForWriting(day)
-		if ptw != nil {
-			ptw.pt.mustAddRows(lrPart)
-			ptw.decRef()
-		} else {
-			// the lrPart must contain at least a single row, so log it.
-			line := MarshalFieldsToJSON(nil, lrPart.rows[0])
-			inactivePartitionLogger.Warnf("skipping log entry because it cannot be saved into inactive per-day partition; "+
-				"see https://docs.victoriametrics.com/victorialogs/#partitions-lifecycle; log entry %s", line)
-		}
+	for i, ptw := range ptws {
+		lrPart := lrParts[i]
+		ptw.pt.mustAddRows(lrPart)
+		ptw.decRef()
 		PutLogRows(lrPart)
 	}
 }
@@ -1149,6 +1429,7 @@ func (s *Storage) MustAddRows(lr *LogRows) {
 var tooSmallTimestampLogger = logger.WithThrottler("too_small_timestamp", 5*time.Second)
 var tooBigTimestampLogger = logger.WithThrottler("too_big_timestamp", 5*time.Second)
 var inactivePartitionLogger = logger.WithThrottler("inactive_partition", 5*time.Second)
+var followerRowsLogger = logger.WithThrottler("follower_rows", 5*time.Second)
 
 // TimeFormatter implements fmt.Stringer for timestamp in nanoseconds
 type TimeFormatter int64
@@ -1160,66 +1441,101 @@ func (tf *TimeFormatter) String() string {
 	return t.Format(time.RFC3339Nano)
 }
 
-// getPartitionForWriting returns the partition for the given day for writing.
+// getPartitionForWriting returns the partition containing the given timestamp ts for writing.
 //
-// The partition is automatically created if it didn't exist.
+// The already existing partition containing ts is returned independently of its interval,
+// so logs continue to be written into partitions created before changing the partition interval.
+// Otherwise the partition with the configured interval is created. If it overlaps existing, deleted or detached partitions
+// (this may happen after increasing the partition interval), then the partition with the biggest smaller interval,
+// which doesn't overlap them, is created instead.
 //
 // nil is returned in the following cases:
 //
-//   - When the partition is outside the configured retention.
-//   - When the partition has been detached via Storage.PartitionDetach().
-//   - When the partition directory has been manually added, but wasn't attached yet via Storage.PartitionAttach().
+//   - When the partition containing ts is outside the configured retention.
+//   - When the partition containing ts has been detached via Storage.PartitionDetach().
+//   - When the partition directory containing ts has been manually added, but wasn't attached yet via Storage.PartitionAttach().
 //
 // The caller must log this case and drop pending logs for this partition.
-func (s *Storage) getPartitionForWriting(day int64) *partitionWrapper {
+func (s *Storage) getPartitionForWriting(ts int64) *partitionWrapper {
 	s.partitionsLock.Lock()
 	defer s.partitionsLock.Unlock()
 
-	// Search for the partition using binary search
-	ptws := s.partitions
-	n := sort.Search(len(ptws), func(i int) bool {
-		return ptws[i].day >= day
-	})
-	var ptw *partitionWrapper
-	if n < len(ptws) {
-		ptw = ptws[n]
-		if ptw.day != day {
-			ptw = nil
-		}
+	if ptws := s.getPartitionsForTimeRangeLocked(ts, ts); len(ptws) > 0 {
+		ptw := ptws[0]
+		s.ptwHot = ptw
+		ptw.incRef()
+		return ptw
 	}
-	if ptw == nil {
-		// Missing partition for the given day.
-		if slices.Contains(s.deletedPartitions, day) {
-			// The partition has been already deleted.
-			return nil
-		}
 
-		fname := getPartitionNameFromDay(day)
-		partitionPath := filepath.Join(s.path, partitionsDirname, fname)
-		if fs.IsPathExist(partitionPath) {
-			// The partition directory exists. This can happen in the following cases:
-			// - When the partition directory has been manually added, but it wasn't attached yet via Storage.PartitionAttach().
-			// - When the partition has been detached via Storage.PartitionDetach().
-			return nil
+	// Missing partition for the given timestamp.
+	inactiveRanges := s.getInactivePartitionTimeRangesLocked()
+	if hasOverlappingTimeRange(inactiveRanges, ts, ts) {
+		// The partition has been already deleted or it is detached.
+		return nil
+	}
+
+	// Create missing partition with the biggest interval up to the configured one, which doesn't overlap other partitions.
+	// Partition intervals are nested, so the hourly partition for ts cannot overlap other partitions.
+	for i := len(supportedPartitionIntervals) - 1; i >= 0; i-- {
+		interval := supportedPartitionIntervals[i]
+		if interval > s.partitionInterval {
+			continue
+		}
+		minTimestamp := getPartitionMinTimestamp(ts, interval)
+		maxTimestamp := minTimestamp + interval - 1
+		if len(s.getPartitionsForTimeRangeLocked(minTimestamp, maxTimestamp)) > 0 || hasOverlappingTimeRange(inactiveRanges, minTimestamp, maxTimestamp) {
+			continue
 		}
 
-		// Create missing partition.
+		fname := getPartitionName(minTimestamp, interval)
+		partitionPath := filepath.Join(s.path, partitionsDirname, fname)
 		mustCreatePartition(partitionPath)
 		pt := mustOpenPartition(s, partitionPath)
-		ptw = newPartitionWrapper(pt, day)
-		if n == len(ptws) {
-			ptws = append(ptws, ptw)
-		} else {
-			ptws = append(ptws[:n+1], ptws[n:]...)
-			ptws[n] = ptw
-		}
-		s.partitions = ptws
+		ptw := newPartitionWrapper(pt, minTimestamp, maxTimestamp)
+		s.partitions = append(s.partitions, ptw)
+		sortPartitions(s.partitions)
+
+		s.ptwHot = ptw
+		ptw.incRef()
+		return ptw
 	}
 
-	s.ptwHot = ptw
-	ptw.incRef()
+	logger.Panicf("BUG: cannot find non-overlapping partition for the timestamp %d", ts)
+	return nil
+}
+
+// getInactivePartitionTimeRangesLocked returns time ranges for partitions, which mustn't be created again.
+//
+// These are partitions deleted because of retention, partitions detached via Storage.PartitionDetach()
+// and partition directories manually added without attaching them via Storage.PartitionAttach().
+//
+// s.partitionsLock must be locked when calling this function.
+func (s *Storage) getInactivePartitionTimeRangesLocked() []partitionTimeRange {
+	trs := append([]partitionTimeRange{}, s.deletedPartitions...)
 
-	return ptw
+	partitionsPath := filepath.Join(s.path, partitionsDirname)
+	des := fs.MustReadDir(partitionsPath)
+	for _, de := range des {
+		if !fs.IsDirOrSymlink(de) {
+			continue
+		}
+		name := de.Name()
+		if slices.ContainsFunc(s.partitions, func(ptw *partitionWrapper) bool {
+			return ptw.pt.name == name
+		}) {
+			continue
+		}
+		minTimestamp, maxTimestamp, err := getPartitionTimeRangeFromName(name)
+		if err != nil {
+			// Skip directories with unexpected names.
+			continue
+		}
+		trs = append(trs, partitionTimeRange{
+			minTimestamp: minTimestamp,
+			maxTimestamp: maxTimestamp,
+		})
+	}
+	return trs
 }
 
 // UpdateStats updates ss for the given s.
@@ -1252,6 +1568,11 @@ func (s *Storage) UpdateStats(ss *StorageStats) {
 	ss.IsReadOnly = s.IsReadOnly()
 }
 
+// IsFollower returns true if s is opened in read-only follower mode.
+func (s *Storage) IsFollower() bool {
+	return s.follower
+}
+
 // IsReadOnly returns true if s is in read-only mode.
 func (s *Storage) IsReadOnly() bool {
 	available := fs.MustGetFreeSpace(s.path)
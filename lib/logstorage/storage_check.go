@@ -0,0 +1,383 @@
+package logstorage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+)
+
+// PartInfo contains the information about a single part obtained via CheckPartition.
+type PartInfo struct {
+	// Partition is the name of the partition for the part.
+	Partition string `json:"partition"`
+
+	// Part is the name of the part directory.
+	Part string `json:"part"`
+
+	// Path is the path to the part directory.
+	Path string `json:"path"`
+
+	// Missing is set to true if the part is listed in parts.json, but is missing on disk.
+	Missing bool `json:"missing,omitempty"`
+
+	// Error contains the reason why the part is broken. It is empty for valid parts.
+	Error string `json:"error,omitempty"`
+
+	// FormatVersion is the version of the part format.
+	FormatVersion uint `json:"format_version"`
+
+	// Rows is the number of rows in the part.
+	Rows uint64 `json:"rows"`
+
+	// Blocks is the number of blocks in the part.
+	Blocks uint64 `json:"blocks"`
+
+	// CompressedBytes is the on-disk size of the part.
+	CompressedBytes uint64 `json:"compressed_bytes"`
+
+	// UncompressedBytes is the uncompressed size of rows in the part.
+	UncompressedBytes uint64 `json:"uncompressed_bytes"`
+
+	// MinTime is the timestamp of the oldest row in the part.
+	MinTime time.Time `json:"min_time"`
+
+	// MaxTime is the timestamp of the newest row in the part.
+	MaxTime time.Time `json:"max_time"`
+
+	// EncryptionKeyID is the id of the key used for encrypting the part. It is empty for unencrypted parts.
+	EncryptionKeyID string `json:"encryption_key_id,omitempty"`
+
+	// Columns contains per-column stats for the part sorted by field name and type.
+	//
+	// It is collected only if CheckPartition is called with collectColumns=true.
+	Columns []*ColumnInfo `json:"columns,omitempty"`
+}
+
+// IsBroken returns true if pi refers to broken or missing part.
+func (pi *PartInfo) IsBroken() bool {
+	return pi.Missing || pi.Error != ""
+}
+
+// ColumnInfo contains stats for a single column of the given type across all the blocks in the part.
+//
+// The stats have the same meaning as the stats returned by block_stats pipe.
+type ColumnInfo struct {
+	// Field is the field name.
+	Field string `json:"field"`
+
+	// Type is the internal storage type for the field such as const, dict, string, uint64, etc.
+	Type string `json:"type"`
+
+	// Blocks is the number of blocks with the field of the given type.
+	Blocks uint64 `json:"blocks"`
+
+	// Rows is the number of rows in the blocks with the field of the given type.
+	Rows uint64 `json:"rows"`
+
+	// ValuesBytes is the on-disk size of the data for the field.
+	ValuesBytes uint64 `json:"values_bytes"`
+
+	// BloomBytes is the on-disk size of bloom filter data for the field.
+	BloomBytes uint64 `json:"bloom_bytes"`
+
+	// DictItems is the total number of unique values in the per-block dictionaries for the field.
+	DictItems uint64 `json:"dict_items"`
+
+	// DictBytes is the total size of the per-block dictionaries for the field.
+	DictBytes uint64 `json:"dict_bytes"`
+}
+
+// ListPartitions returns sorted names of partitions stored at storagePath.
+//
+// The storage at storagePath mustn't be opened while the returned partitions are used.
+func ListPartitions(storagePath string) []string {
+	partitionsPath := filepath.Join(storagePath, partitionsDirname)
+	if !fs.IsPathExist(partitionsPath) {
+		return nil
+	}
+
+	var names []string
+	des := fs.MustReadDir(partitionsPath)
+	for _, de := range des {
+		if !fs.IsDirOrSymlink(de) {
+			continue
+		}
+		partitionPath := filepath.Join(partitionsPath, de.Name())
+		if fs.IsPartiallyRemovedDir(partitionPath) {
+			continue
+		}
+		names = append(names, de.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CheckPartition reads all the parts listed in parts.json for the given partition at storagePath and verifies them.
+//
+// It validates part headers, block offsets and sizes, and decodes all the blocks with timestamps, values and bloom filters.
+// ek is used for decrypting encrypted parts. Up to concurrency parts are checked in parallel.
+//
+// If collectColumns is set, then per-column stats are collected for every valid part.
+//
+// The storage at storagePath mustn't be opened during the check.
+func CheckPartition(storagePath, partition string, ek *EncryptionKeys, concurrency int, collectColumns bool) ([]*PartInfo, error) {
+	datadbPath := getDatadbPath(storagePath, partition)
+	partNames, err := readPartNames(datadbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	pis := make([]*PartInfo, len(partNames))
+	workCh := make(chan int, len(partNames))
+	for i := range partNames {
+		workCh <- i
+	}
+	close(workCh)
+
+	var wg sync.WaitGroup
+	for i := 0; i < max(concurrency, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range workCh {
+				partName := partNames[idx]
+				pi := &PartInfo{
+					Partition: partition,
+					Part:      partName,
+					Path:      filepath.Join(datadbPath, partName),
+				}
+				checkPart(pi, ek, collectColumns)
+				pis[idx] = pi
+			}
+		}()
+	}
+	wg.Wait()
+
+	return pis, nil
+}
+
+// RepairPartition removes the broken and missing parts from pis obtained via CheckPartition from parts.json of the given partition at storagePath.
+//
+// Broken parts are moved to quarantinePath/<partition>/<part>, so they could be inspected later.
+// The storage at storagePath mustn't be opened during the repair.
+//
+// It returns the number of parts removed from parts.json.
+func RepairPartition(storagePath, partition string, pis []*PartInfo, quarantinePath string) (int, error) {
+	datadbPath := getDatadbPath(storagePath, partition)
+	partNames, err := readPartNames(datadbPath)
+	if err != nil {
+		return 0, err
+	}
+
+	broken := make(map[string]*PartInfo)
+	for _, pi := range pis {
+		if pi.Partition == partition && pi.IsBroken() {
+			broken[pi.Part] = pi
+		}
+	}
+	if len(broken) == 0 {
+		return 0, nil
+	}
+
+	// Move broken parts to quarantine before updating parts.json,
+	// since parts missing in parts.json are automatically removed when the partition is opened.
+	for _, pi := range broken {
+		if pi.Missing {
+			continue
+		}
+		dstPath := filepath.Join(quarantinePath, partition, pi.Part)
+		fs.MustMkdirIfNotExist(filepath.Dir(dstPath))
+		if fs.IsPathExist(dstPath) {
+			return 0, fmt.Errorf("cannot move the part %q to quarantine, since %q already exists", pi.Path, dstPath)
+		}
+		if err := os.Rename(pi.Path, dstPath); err != nil {
+			return 0, fmt.Errorf("cannot move the part %q to quarantine: %w", pi.Path, err)
+		}
+		fs.MustSyncPath(filepath.Dir(dstPath))
+	}
+
+	var partNamesNew []string
+	for _, partName := range partNames {
+		if _, ok := broken[partName]; !ok {
+			partNamesNew = append(partNamesNew, partName)
+		}
+	}
+	mustWritePartNames(datadbPath, partNamesNew)
+	fs.MustSyncPath(datadbPath)
+
+	return len(partNames) - len(partNamesNew), nil
+}
+
+func getDatadbPath(storagePath, partition string) string {
+	return filepath.Join(storagePath, partitionsDirname, partition, datadbDirname)
+}
+
+// readPartNames reads part names from parts.json at datadbPath.
+//
+// Unlike mustReadPartNames, it returns an error instead of panicking if parts.json is missing or broken.
+func readPartNames(datadbPath string) ([]string, error) {
+	partNamesPath := filepath.Join(datadbPath, partsFilename)
+	data, err := os.ReadFile(partNamesPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read parts list: %w", err)
+	}
+	var partNames []string
+	if err := json.Unmarshal(data, &partNames); err != nil {
+		return nil, fmt.Errorf("cannot parse parts list from %q: %w", partNamesPath, err)
+	}
+	return partNames, nil
+}
+
+// checkPart verifies the part at pi.Path and fills pi with the part information.
+//
+// pi.Error is set if the part is broken.
+func checkPart(pi *PartInfo, ek *EncryptionKeys, collectColumns bool) {
+	if !fs.IsPathExist(pi.Path) {
+		pi.Missing = true
+		pi.Error = "the part is listed in parts.json, but is missing on disk"
+		return
+	}
+
+	// Use error-returning readers instead of the Must* ones, since the latter terminate the process on corrupted data,
+	// while the remaining parts must be checked.
+	var ph partHeader
+	if err := ph.readMetadata(pi.Path); err != nil {
+		pi.Error = err.Error()
+		return
+	}
+	pi.FormatVersion = ph.FormatVersion
+	pi.Rows = ph.RowsCount
+	pi.Blocks = ph.BlocksCount
+	pi.CompressedBytes = ph.CompressedSizeBytes
+	pi.UncompressedBytes = ph.UncompressedSizeBytes
+	pi.MinTime = time.Unix(0, ph.MinTimestamp).UTC()
+	pi.MaxTime = time.Unix(0, ph.MaxTimestamp).UTC()
+	pi.EncryptionKeyID = ph.EncryptionKeyID
+
+	bsr := getBlockStreamReader()
+	defer putBlockStreamReader(bsr)
+	if err := bsr.initFromFilePart(pi.Path, ek); err != nil {
+		pi.Error = err.Error()
+		return
+	}
+	defer bsr.MustClose()
+
+	var cc columnsCollector
+	sbu := getStringsBlockUnmarshaler()
+	defer putStringsBlockUnmarshaler(sbu)
+	vd := getValuesDecoder()
+	defer putValuesDecoder(vd)
+	b := getBlock()
+	defer putBlock(b)
+	var bf bloomFilter
+
+	for {
+		ok, err := bsr.nextBlock()
+		if err != nil {
+			pi.Error = err.Error()
+			return
+		}
+		if !ok {
+			break
+		}
+		bd := &bsr.blockData
+		if err := b.InitFromBlockData(bd, sbu, vd); err != nil {
+			pi.Error = fmt.Sprintf("%s: cannot decode block for streamID=%s: %s", pi.Path, &bd.streamID, err)
+			return
+		}
+		for i := range bd.columnsData {
+			cd := &bd.columnsData[i]
+			if err := bf.unmarshal(cd.bloomFilterData); err != nil {
+				pi.Error = fmt.Sprintf("%s: cannot unmarshal bloom filter for the field %q: %s", pi.Path, cd.name, err)
+				return
+			}
+		}
+		if collectColumns {
+			cc.addBlockData(bd)
+		}
+		sbu.reset()
+		vd.reset()
+	}
+
+	if collectColumns {
+		pi.Columns = cc.getColumns()
+	}
+}
+
+// columnsCollector collects per-column stats for block_stats-like output.
+type columnsCollector struct {
+	m map[columnsCollectorKey]*ColumnInfo
+}
+
+type columnsCollectorKey struct {
+	field string
+	typ   string
+}
+
+func (cc *columnsCollector) getColumnInfo(field, typ string) *ColumnInfo {
+	if cc.m == nil {
+		cc.m = make(map[columnsCollectorKey]*ColumnInfo)
+	}
+	k := columnsCollectorKey{
+		field: field,
+		typ:   typ,
+	}
+	ci := cc.m[k]
+	if ci == nil {
+		ci = &ColumnInfo{
+			Field: field,
+			Type:  typ,
+		}
+		cc.m[k] = ci
+	}
+	return ci
+}
+
+func (cc *columnsCollector) addBlockData(bd *blockData) {
+	ci := cc.getColumnInfo("_time", "time")
+	ci.Blocks++
+	ci.Rows += bd.rowsCount
+	ci.ValuesBytes += uint64(len(bd.timestampsData.data))
+
+	for _, f := range bd.constColumns {
+		ci := cc.getColumnInfo(getCanonicalColumnName(f.Name), "const")
+		ci.Blocks++
+		ci.Rows += bd.rowsCount
+		ci.ValuesBytes += uint64(len(f.Value))
+	}
+
+	for i := range bd.columnsData {
+		cd := &bd.columnsData[i]
+		ci := cc.getColumnInfo(getCanonicalColumnName(cd.name), cd.valueType.String())
+		ci.Blocks++
+		ci.Rows += bd.rowsCount
+		ci.ValuesBytes += uint64(len(cd.valuesData))
+		ci.BloomBytes += uint64(len(cd.bloomFilterData))
+		ci.DictItems += uint64(len(cd.valuesDict.values))
+		if cd.valueType == valueTypeDict {
+			for _, v := range cd.valuesDict.values {
+				ci.DictBytes += uint64(len(v))
+			}
+		}
+	}
+}
+
+func (cc *columnsCollector) getColumns() []*ColumnInfo {
+	cis := make([]*ColumnInfo, 0, len(cc.m))
+	for _, ci := range cc.m {
+		cis = append(cis, ci)
+	}
+	sort.Slice(cis, func(i, j int) bool {
+		if cis[i].Field != cis[j].Field {
+			return cis[i].Field < cis[j].Field
+		}
+		return cis[i].Type < cis[j].Type
+	})
+	return cis
+}
@@ -0,0 +1,224 @@
+package logstorage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+)
+
+func TestCheckAndRepairPartition(t *testing.T) {
+	t.Parallel()
+
+	path := t.Name()
+
+	cfg := &StorageConfig{
+		Retention: 30 * 24 * time.Hour,
+	}
+	s := MustOpenStorage(path, cfg)
+
+	// Store rows into two per-day partitions
+	lr := GetLogRows([]string{"host"}, nil, nil, nil, "")
+	now := time.Now().UnixNano()
+	for i := 0; i < 100; i++ {
+		ts := now + int64(i)
+		if i%2 == 0 {
+			ts -= nsecsPerDay
+		}
+		fields := []Field{
+			{
+				Name:  "host",
+				Value: fmt.Sprintf("host-%d", i%10),
+			},
+			{
+				Name:  "_msg",
+				Value: fmt.Sprintf("message %d", i),
+			},
+		}
+		lr.MustAdd(TenantID{}, ts, fields, nil)
+	}
+	s.MustAddRows(lr)
+	PutLogRows(lr)
+	s.DebugFlush()
+	s.MustClose()
+
+	partitions := ListPartitions(path)
+	if len(partitions) != 2 {
+		t.Fatalf("unexpected number of partitions; got %d; want 2; partitions: %q", len(partitions), partitions)
+	}
+
+	// All the parts must be valid
+	rows := uint64(0)
+	for _, partition := range partitions {
+		pis, err := CheckPartition(path, partition, nil, 2, true)
+		if err != nil {
+			t.Fatalf("cannot check partition %q: %s", partition, err)
+		}
+		for _, pi := range pis {
+			if pi.IsBroken() {
+				t.Fatalf("unexpected broken part %q: %s", pi.Path, pi.Error)
+			}
+			if len(pi.Columns) == 0 {
+				t.Fatalf("missing columns stats for the part %q", pi.Path)
+			}
+			rows += pi.Rows
+		}
+	}
+	if rows != 100 {
+		t.Fatalf("unexpected number of rows; got %d; want 100", rows)
+	}
+
+	// Corrupt all the parts at the first partition
+	pis, err := CheckPartition(path, partitions[0], nil, 2, false)
+	if err != nil {
+		t.Fatalf("cannot check partition %q: %s", partitions[0], err)
+	}
+	for _, pi := range pis {
+		if err := os.Truncate(filepath.Join(pi.Path, messageValuesFilename), 10); err != nil {
+			t.Fatalf("cannot truncate message values file: %s", err)
+		}
+	}
+	pis, err = CheckPartition(path, partitions[0], nil, 2, false)
+	if err != nil {
+		t.Fatalf("cannot check partition %q: %s", partitions[0], err)
+	}
+	for _, pi := range pis {
+		if !pi.IsBroken() {
+			t.Fatalf("expecting broken part %q", pi.Path)
+		}
+	}
+
+	// Repair must move the broken parts to quarantine and remove them from parts.json
+	quarantinePath := filepath.Join(path, "quarantine")
+	n, err := RepairPartition(path, partitions[0], pis, quarantinePath)
+	if err != nil {
+		t.Fatalf("cannot repair partition %q: %s", partitions[0], err)
+	}
+	if n != len(pis) {
+		t.Fatalf("unexpected number of removed parts; got %d; want %d", n, len(pis))
+	}
+	for _, pi := range pis {
+		if fs.IsPathExist(pi.Path) {
+			t.Fatalf("the broken part %q must be moved to quarantine", pi.Path)
+		}
+		if !fs.IsPathExist(filepath.Join(quarantinePath, partitions[0], pi.Part)) {
+			t.Fatalf("missing the broken part %q at quarantine", pi.Part)
+		}
+	}
+
+	// The storage must open after the repair and contain only the rows from valid parts
+	s = MustOpenStorage(path, cfg)
+	checkQueryResults(t, s, []TenantID{{}}, "* | count() rows", []string{`{"rows":"50"}`})
+	s.MustClose()
+
+	fs.MustRemoveDir(path)
+}
+
+func TestCheckPartCorrupted(t *testing.T) {
+	t.Parallel()
+
+	f := func(corrupt func(partPath string)) {
+		t.Helper()
+
+		path := filepath.Join(t.Name(), fmt.Sprintf("%d", time.Now().UnixNano()))
+		s := MustOpenStorage(path, &StorageConfig{
+			Retention: 30 * 24 * time.Hour,
+		})
+		lr := GetLogRows(nil, nil, nil, nil, "")
+		now := time.Now().UnixNano()
+		for i := 0; i < 100; i++ {
+			fields := []Field{
+				{
+					Name:  "host",
+					Value: fmt.Sprintf("host-%d", i%10),
+				},
+				{
+					Name:  "_msg",
+					Value: fmt.Sprintf("message %d", i),
+				},
+			}
+			lr.MustAdd(TenantID{}, now+int64(i), fields, nil)
+		}
+		s.MustAddRows(lr)
+		PutLogRows(lr)
+		s.DebugFlush()
+		s.MustClose()
+
+		partitions := ListPartitions(path)
+		if len(partitions) != 1 {
+			t.Fatalf("unexpected number of partitions; got %d; want 1", len(partitions))
+		}
+		pis, err := CheckPartition(path, partitions[0], nil, 1, false)
+		if err != nil {
+			t.Fatalf("cannot check partition %q: %s", partitions[0], err)
+		}
+		for _, pi := range pis {
+			corrupt(pi.Path)
+		}
+
+		// The check must report the corrupted parts as broken instead of terminating the process
+		pis, err = CheckPartition(path, partitions[0], nil, 1, true)
+		if err != nil {
+			t.Fatalf("cannot check partition %q: %s", partitions[0], err)
+		}
+		if len(pis) == 0 {
+			t.Fatalf("expecting non-empty list of parts")
+		}
+		for _, pi := range pis {
+			if !pi.IsBroken() {
+				t.Fatalf("expecting broken part %q", pi.Path)
+			}
+			if pi.Columns != nil {
+				t.Fatalf("unexpected columns for the broken part %q: %v", pi.Path, pi.Columns)
+			}
+		}
+
+		fs.MustRemoveDir(t.Name())
+	}
+
+	writeFile := func(path, data string) {
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatalf("cannot write %q: %s", path, err)
+		}
+	}
+
+	// invalid metadata
+	f(func(partPath string) {
+		writeFile(filepath.Join(partPath, metadataFilename), "foobar")
+	})
+
+	// inconsistent metadata
+	f(func(partPath string) {
+		writeFile(filepath.Join(partPath, metadataFilename), `{"FormatVersion":3,"RowsCount":1,"BlocksCount":2}`)
+	})
+
+	// missing data file
+	f(func(partPath string) {
+		fs.MustRemovePath(filepath.Join(partPath, timestampsFilename))
+	})
+
+	// corrupted column names
+	f(func(partPath string) {
+		writeFile(filepath.Join(partPath, columnNamesFilename), "foobar")
+	})
+
+	// corrupted metaindex
+	f(func(partPath string) {
+		writeFile(filepath.Join(partPath, metaindexFilename), "foobar")
+	})
+
+	// corrupted index
+	f(func(partPath string) {
+		writeFile(filepath.Join(partPath, indexFilename), "foobar")
+	})
+
+	// truncated columns header
+	f(func(partPath string) {
+		if err := os.Truncate(filepath.Join(partPath, columnsHeaderFilename), 1); err != nil {
+			t.Fatalf("cannot truncate columns header file: %s", err)
+		}
+	})
+}
@@ -0,0 +1,491 @@
+package logstorage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	vmfs "github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/snapshot/snapshotutil"
+)
+
+const (
+	// replicasDirname is the directory with partitions replicated from the primary storage.
+	//
+	// Every replicated partition is stored at replicas/<partitionName>/<replicaName>,
+	// since the previous replica of the partition may be still in use by queries.
+	replicasDirname = "replicas"
+
+	// replicasTmpDirname is the directory for replicas, which are in the process of replication.
+	replicasTmpDirname = "replicas_tmp"
+)
+
+// PartitionFile describes a file in the partition snapshot.
+type PartitionFile struct {
+	// Path is the path to the file relative to the snapshot directory. It uses forward slashes as path separators.
+	Path string `json:"path"`
+
+	// Size is the file size in bytes.
+	Size uint64 `json:"size"`
+}
+
+// PartitionParts contains the names of parts for the partition with the given Name.
+type PartitionParts struct {
+	// Name is the partition name.
+	Name string `json:"name"`
+
+	// DatadbParts contains the names of parts from datadb/parts.json at the partition.
+	DatadbParts []string `json:"datadb_parts"`
+
+	// IndexdbParts contains the names of parts from indexdb/parts.json at the partition.
+	IndexdbParts []string `json:"indexdb_parts"`
+}
+
+// HasSameParts returns true if pp and other contain the same sets of parts.
+func (pp *PartitionParts) HasSameParts(other *PartitionParts) bool {
+	return isSamePartNames(pp.DatadbParts, other.DatadbParts) && isSamePartNames(pp.IndexdbParts, other.IndexdbParts)
+}
+
+func isSamePartNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = slices.Clone(a)
+	b = slices.Clone(b)
+	sort.Strings(a)
+	sort.Strings(b)
+	return slices.Equal(a, b)
+}
+
+// PartitionPartsList returns the names of parts for all the partitions at s.
+//
+// Parts are read from parts.json files, so in-memory parts aren't returned.
+// The returned lists change only when the set of parts stored on disk changes, so they can be used
+// for detecting partitions, which must be replicated via PartitionReplicate().
+func (s *Storage) PartitionPartsList() ([]PartitionParts, error) {
+	var ptws []*partitionWrapper
+	s.partitionsLock.Lock()
+	for _, ptw := range s.partitions {
+		ptw.incRef()
+		ptws = append(ptws, ptw)
+	}
+	s.partitionsLock.Unlock()
+
+	defer func() {
+		for _, ptw := range ptws {
+			ptw.decRef()
+		}
+	}()
+
+	pps := make([]PartitionParts, 0, len(ptws))
+	for _, ptw := range ptws {
+		datadbParts, err := readPartNamesIfExist(filepath.Join(ptw.pt.path, datadbDirname))
+		if err != nil {
+			return nil, fmt.Errorf("cannot read datadb parts for partition %q: %w", ptw.pt.name, err)
+		}
+		indexdbParts, err := readPartNamesIfExist(filepath.Join(ptw.pt.path, indexdbDirname))
+		if err != nil {
+			return nil, fmt.Errorf("cannot read indexdb parts for partition %q: %w", ptw.pt.name, err)
+		}
+		pps = append(pps, PartitionParts{
+			Name:         ptw.pt.name,
+			DatadbParts:  datadbParts,
+			IndexdbParts: indexdbParts,
+		})
+	}
+	return pps, nil
+}
+
+// readPartNamesIfExist reads part names from parts.json at path.
+//
+// It returns an empty list if parts.json is missing.
+func readPartNamesIfExist(path string) ([]string, error) {
+	partNames, err := readPartNames(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	if partNames == nil {
+		// This is needed in order to return `[]` instead of `null` to the client.
+		partNames = []string{}
+	}
+	return partNames, nil
+}
+
+// PartitionSnapshotFiles returns the list of files for the snapshot with the given snapshotName at the partition with the given name.
+//
+// The snapshot must be created via PartitionSnapshotCreate() call.
+func (s *Storage) PartitionSnapshotFiles(name, snapshotName string) ([]PartitionFile, error) {
+	snapshotPath, err := s.getPartitionSnapshotPath(name, snapshotName)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []PartitionFile
+	err = filepath.WalkDir(snapshotPath, func(path string, de fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if de.IsDir() {
+			return nil
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(snapshotPath, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, PartitionFile{
+			Path: filepath.ToSlash(relPath),
+			Size: uint64(fi.Size()),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot list files at the snapshot %q: %w", snapshotPath, err)
+	}
+	return files, nil
+}
+
+// PartitionSnapshotFilePath returns the path to the file with the given relative path at the snapshot with the given snapshotName
+// at the partition with the given name.
+//
+// The relative path must be obtained from PartitionSnapshotFiles().
+func (s *Storage) PartitionSnapshotFilePath(name, snapshotName, path string) (string, error) {
+	snapshotPath, err := s.getPartitionSnapshotPath(name, snapshotName)
+	if err != nil {
+		return "", err
+	}
+	relPath, err := getPartitionFileRelPath(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(snapshotPath, relPath), nil
+}
+
+// PartitionSnapshotDelete deletes the snapshot with the given snapshotName at the partition with the given name.
+func (s *Storage) PartitionSnapshotDelete(name, snapshotName string) error {
+	snapshotPath, err := s.getPartitionSnapshotPath(name, snapshotName)
+	if err != nil {
+		return err
+	}
+	vmfs.MustRemoveDir(snapshotPath)
+	vmfs.MustSyncPath(filepath.Dir(snapshotPath))
+	return nil
+}
+
+func (s *Storage) getPartitionSnapshotPath(name, snapshotName string) (string, error) {
+	if err := snapshotutil.Validate(snapshotName); err != nil {
+		return "", err
+	}
+
+	ptw := s.getPartitionByName(name)
+	if ptw == nil {
+		return "", fmt.Errorf("cannot find partition %q", name)
+	}
+	snapshotPath := filepath.Join(ptw.pt.path, snapshotsDirname, snapshotName)
+	ptw.decRef()
+
+	if !vmfs.IsPathExist(snapshotPath) {
+		return "", fmt.Errorf("cannot find snapshot %q at partition %q", snapshotName, name)
+	}
+	return snapshotPath, nil
+}
+
+// getPartitionByName returns the partition with the given name.
+//
+// It returns nil if the partition is missing. Otherwise the returned partition must be released via decRef() call.
+func (s *Storage) getPartitionByName(name string) *partitionWrapper {
+	s.partitionsLock.Lock()
+	defer s.partitionsLock.Unlock()
+
+	for _, ptw := range s.partitions {
+		if ptw.pt.name == name {
+			ptw.incRef()
+			return ptw
+		}
+	}
+	return nil
+}
+
+// getPartitionFileRelPath validates the given path from PartitionFile and converts it to the local relative path.
+func getPartitionFileRelPath(path string) (string, error) {
+	relPath := filepath.FromSlash(path)
+	if !filepath.IsLocal(relPath) {
+		return "", fmt.Errorf("unexpected partition file path %q; it must be relative path inside the partition", path)
+	}
+	return relPath, nil
+}
+
+// isImmutablePartitionFile returns true if the file at the given relPath inside partition never changes after it is created.
+//
+// Files inside part directories are immutable, since parts are never modified after creation, and part names are never reused.
+// Other files such as parts.json are updated when the set of parts changes.
+func isImmutablePartitionFile(relPath string) bool {
+	return strings.Count(relPath, string(filepath.Separator)) >= 2
+}
+
+// PartitionReplicate replicates the partition with the given name from the snapshot with the given files at the primary storage.
+//
+// The fetchFile must write the contents of the file with the given path from files to w.
+//
+// Files inside parts, which already exist at the current partition with the given name, are hard-linked instead of fetching them again,
+// so only newly created parts are fetched. The current partition is atomically replaced with the replicated partition
+// if the set of parts has been changed. The replaced partition is deleted when it is no longer used by queries.
+//
+// The function returns true if the partition has been changed.
+//
+// This function is intended for storage opened in follower mode. It mustn't be called concurrently for the same partition.
+func (s *Storage) PartitionReplicate(name string, files []PartitionFile, fetchFile func(path string, w io.Writer) error) (bool, error) {
+	minTimestamp, maxTimestamp, err := getPartitionTimeRangeFromName(name)
+	if err != nil {
+		return false, err
+	}
+	for _, f := range files {
+		if _, err := getPartitionFileRelPath(f.Path); err != nil {
+			return false, err
+		}
+	}
+
+	ptwOld := s.getPartitionByName(name)
+	if ptwOld != nil {
+		defer ptwOld.decRef()
+	}
+
+	tmpPath := filepath.Join(s.path, replicasTmpDirname, snapshotutil.NewName())
+	vmfs.MustMkdirIfNotExist(tmpPath)
+	ok := false
+	defer func() {
+		if !ok {
+			vmfs.MustRemoveDir(tmpPath)
+		}
+	}()
+
+	// Fetch mutable files at first. They are small, and they contain the list of parts,
+	// so they are enough for detecting whether the partition has been changed.
+	isChanged := ptwOld == nil
+	for _, f := range files {
+		relPath, _ := getPartitionFileRelPath(f.Path)
+		if isImmutablePartitionFile(relPath) {
+			continue
+		}
+		dstPath := filepath.Join(tmpPath, relPath)
+		if err := fetchPartitionFile(dstPath, f, fetchFile); err != nil {
+			return false, err
+		}
+		if ptwOld != nil && !isSameFileContents(dstPath, filepath.Join(ptwOld.pt.path, relPath)) {
+			isChanged = true
+		}
+	}
+	if !isChanged {
+		return false, nil
+	}
+
+	// Hard-link already existing immutable files from the current partition and fetch the missing files.
+	for _, f := range files {
+		relPath, _ := getPartitionFileRelPath(f.Path)
+		if !isImmutablePartitionFile(relPath) {
+			continue
+		}
+		dstPath := filepath.Join(tmpPath, relPath)
+		if ptwOld != nil && tryLinkPartitionFile(dstPath, filepath.Join(ptwOld.pt.path, relPath), f.Size) {
+			continue
+		}
+		if err := fetchPartitionFile(dstPath, f, fetchFile); err != nil {
+			return false, err
+		}
+	}
+	vmfs.MustSyncPath(tmpPath)
+
+	// Move the replicated partition to its final place and open it.
+	replicasPath := filepath.Join(s.path, replicasDirname, name)
+	vmfs.MustMkdirIfNotExist(replicasPath)
+	replicaPath := filepath.Join(replicasPath, filepath.Base(tmpPath))
+	if err := os.Rename(tmpPath, replicaPath); err != nil {
+		logger.Panicf("FATAL: cannot move replicated partition from %q to %q: %s", tmpPath, replicaPath, err)
+	}
+	ok = true
+	vmfs.MustSyncPathAndParentDir(replicasPath)
+
+	pt := mustOpenPartitionWithName(s, replicaPath, name)
+	ptwNew := newPartitionWrapper(pt, minTimestamp, maxTimestamp)
+
+	s.partitionsLock.Lock()
+	var ptwPrev *partitionWrapper
+	idx := slices.IndexFunc(s.partitions, func(ptw *partitionWrapper) bool {
+		return ptw.pt.name == name
+	})
+	if idx >= 0 {
+		ptwPrev = s.partitions[idx]
+		s.partitions[idx] = ptwNew
+	} else {
+		s.partitions = append(s.partitions, ptwNew)
+		sortPartitions(s.partitions)
+	}
+	if ptwPrev != nil && ptwPrev == s.ptwHot {
+		s.ptwHot = nil
+	}
+	s.partitionsLock.Unlock()
+
+	if ptwPrev != nil {
+		// The previous partition is deleted after all the queries stop using it.
+		ptwPrev.mustDrop.Store(true)
+		ptwPrev.decRef()
+	}
+
+	return true, nil
+}
+
+// PartitionDrop detaches the partition with the given name from s and deletes its data after all the queries stop using it.
+//
+// This function is intended for storage opened in follower mode for dropping partitions, which were deleted at the primary storage.
+func (s *Storage) PartitionDrop(name string) error {
+	ptw := func() *partitionWrapper {
+		s.partitionsLock.Lock()
+		defer s.partitionsLock.Unlock()
+
+		for i, ptw := range s.partitions {
+			if ptw.pt.name != name {
+				continue
+			}
+			s.partitions = append(s.partitions[:i], s.partitions[i+1:]...)
+			if ptw == s.ptwHot {
+				s.ptwHot = nil
+			}
+			return ptw
+		}
+		return nil
+	}()
+
+	if ptw == nil {
+		return fmt.Errorf("cannot drop the partition %q, because it isn't attached", name)
+	}
+
+	logger.Infof("dropping partition %q at %q", name, ptw.pt.path)
+	ptw.mustDrop.Store(true)
+	ptw.decRef()
+
+	return nil
+}
+
+func fetchPartitionFile(dstPath string, f PartitionFile, fetchFile func(path string, w io.Writer) error) error {
+	vmfs.MustMkdirIfNotExist(filepath.Dir(dstPath))
+
+	file, err := os.Create(dstPath)
+	if err != nil {
+		logger.Panicf("FATAL: cannot create file %q: %s", dstPath, err)
+	}
+	cw := &countingWriter{
+		w: file,
+	}
+	err = fetchFile(f.Path, cw)
+	if err := file.Sync(); err != nil {
+		logger.Panicf("FATAL: cannot sync file %q: %s", dstPath, err)
+	}
+	vmfs.MustClose(file)
+	if err != nil {
+		return fmt.Errorf("cannot fetch file %q: %w", f.Path, err)
+	}
+	if cw.n != f.Size {
+		return fmt.Errorf("unexpected size of the fetched file %q; got %d bytes; want %d bytes", f.Path, cw.n, f.Size)
+	}
+	return nil
+}
+
+func tryLinkPartitionFile(dstPath, srcPath string, size uint64) bool {
+	fi, err := os.Stat(srcPath)
+	if err != nil || uint64(fi.Size()) != size {
+		return false
+	}
+	vmfs.MustMkdirIfNotExist(filepath.Dir(dstPath))
+	if err := os.Link(srcPath, dstPath); err != nil {
+		logger.Warnf("cannot create hard link from %q to %q: %s; fetching the file from the primary storage", srcPath, dstPath, err)
+		return false
+	}
+	return true
+}
+
+func isSameFileContents(path1, path2 string) bool {
+	data1, err := os.ReadFile(path1)
+	if err != nil {
+		return false
+	}
+	data2, err := os.ReadFile(path2)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(data1, data2)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n uint64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += uint64(n)
+	return n, err
+}
+
+// mustRestoreReplicas replaces partitions at the storage with the given path with their most recent replicas.
+//
+// Replicas are left at replicasDirname when the storage in follower mode is stopped, since the replaced partitions
+// may be still in use at the moment of replication.
+func mustRestoreReplicas(path string) {
+	vmfs.MustRemoveDir(filepath.Join(path, replicasTmpDirname))
+
+	replicasPath := filepath.Join(path, replicasDirname)
+	if !vmfs.IsPathExist(replicasPath) {
+		return
+	}
+
+	partitionsPath := filepath.Join(path, partitionsDirname)
+	for _, de := range vmfs.MustReadDir(replicasPath) {
+		name := de.Name()
+		partitionReplicasPath := filepath.Join(replicasPath, name)
+		if !vmfs.IsDirOrSymlink(de) {
+			continue
+		}
+
+		var replicaNames []string
+		for _, de := range vmfs.MustReadDir(partitionReplicasPath) {
+			replicaName := de.Name()
+			if !vmfs.IsDirOrSymlink(de) || snapshotutil.Validate(replicaName) != nil {
+				continue
+			}
+			if vmfs.IsPartiallyRemovedDir(filepath.Join(partitionReplicasPath, replicaName)) {
+				continue
+			}
+			replicaNames = append(replicaNames, replicaName)
+		}
+
+		if len(replicaNames) > 0 {
+			sort.Strings(replicaNames)
+			replicaPath := filepath.Join(partitionReplicasPath, replicaNames[len(replicaNames)-1])
+			partitionPath := filepath.Join(partitionsPath, name)
+			vmfs.MustRemoveDir(partitionPath)
+			if err := os.Rename(replicaPath, partitionPath); err != nil {
+				logger.Panicf("FATAL: cannot move replicated partition from %q to %q: %s", replicaPath, partitionPath, err)
+			}
+			logger.Infof("restored replicated partition %q from %q", name, replicaPath)
+		}
+
+		vmfs.MustRemoveDir(partitionReplicasPath)
+	}
+	vmfs.MustRemoveDir(replicasPath)
+	vmfs.MustSyncPath(partitionsPath)
+	vmfs.MustSyncPath(path)
+}
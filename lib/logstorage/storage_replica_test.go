@@ -0,0 +1,202 @@
+package logstorage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+)
+
+func TestStoragePartitionReplicate(t *testing.T) {
+	t.Parallel()
+
+	path := t.Name()
+	primaryPath := filepath.Join(path, "primary")
+	followerPath := filepath.Join(path, "follower")
+
+	cfg := &StorageConfig{
+		Retention: 30 * 24 * time.Hour,
+	}
+	primary := MustOpenStorage(primaryPath, cfg)
+	followerCfg := &StorageConfig{
+		Retention: 30 * 24 * time.Hour,
+		Follower:  true,
+	}
+	follower := MustOpenStorage(followerPath, followerCfg)
+
+	day := 24 * time.Hour.Nanoseconds()
+	now := time.Now().UnixNano()
+	addRows := func(timestamp int64, rowsCount int) {
+		lr := GetLogRows(nil, nil, nil, nil, "")
+		for i := 0; i < rowsCount; i++ {
+			fields := []Field{
+				{
+					Name:  "_msg",
+					Value: fmt.Sprintf("message %d", i),
+				},
+			}
+			lr.MustAdd(TenantID{}, timestamp+int64(i), fields, nil)
+		}
+		primary.MustAddRows(lr)
+		PutLogRows(lr)
+		primary.DebugFlush()
+	}
+
+	replicate := func(name string) bool {
+		t.Helper()
+
+		snapshotPath, err := primary.PartitionSnapshotCreate(name)
+		if err != nil {
+			t.Fatalf("cannot create snapshot: %s", err)
+		}
+		snapshotName := filepath.Base(snapshotPath)
+		files, err := primary.PartitionSnapshotFiles(name, snapshotName)
+		if err != nil {
+			t.Fatalf("cannot list snapshot files: %s", err)
+		}
+		fetchFile := func(path string, w io.Writer) error {
+			filePath, err := primary.PartitionSnapshotFilePath(name, snapshotName, path)
+			if err != nil {
+				return err
+			}
+			data, err := os.ReadFile(filePath)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(data)
+			return err
+		}
+		isChanged, err := follower.PartitionReplicate(name, files, fetchFile)
+		if err != nil {
+			t.Fatalf("cannot replicate partition %q: %s", name, err)
+		}
+		if err := primary.PartitionSnapshotDelete(name, snapshotName); err != nil {
+			t.Fatalf("cannot delete snapshot: %s", err)
+		}
+		return isChanged
+	}
+
+	flushToDisk := func(name string) {
+		t.Helper()
+
+		// Snapshot creation flushes in-memory parts to disk
+		snapshotPath, err := primary.PartitionSnapshotCreate(name)
+		if err != nil {
+			t.Fatalf("cannot create snapshot: %s", err)
+		}
+		if err := primary.PartitionSnapshotDelete(name, filepath.Base(snapshotPath)); err != nil {
+			t.Fatalf("cannot delete snapshot: %s", err)
+		}
+	}
+
+	checkSameParts := func(name string, expected bool) {
+		t.Helper()
+
+		getParts := func(s *Storage) *PartitionParts {
+			t.Helper()
+
+			pps, err := s.PartitionPartsList()
+			if err != nil {
+				t.Fatalf("cannot obtain partition parts: %s", err)
+			}
+			for i := range pps {
+				if pps[i].Name == name {
+					return &pps[i]
+				}
+			}
+			return &PartitionParts{}
+		}
+
+		pp := getParts(primary)
+		if len(pp.DatadbParts) == 0 || len(pp.IndexdbParts) == 0 {
+			t.Fatalf("expecting non-empty parts for partition %q at the primary storage; got %#v", name, pp)
+		}
+		if result := pp.HasSameParts(getParts(follower)); result != expected {
+			t.Fatalf("unexpected HasSameParts result for partition %q; got %v; want %v", name, result, expected)
+		}
+	}
+
+	checkRowsCount := func(expected int) {
+		t.Helper()
+		checkQueryResults(t, follower, []TenantID{{}}, "* | count() rows", []string{
+			fmt.Sprintf(`{"rows":"%d"}`, expected),
+		})
+	}
+
+	// Replicate two partitions
+	addRows(now-day, 10)
+	addRows(now, 20)
+	partitionNames := primary.PartitionList()
+	if len(partitionNames) != 2 {
+		t.Fatalf("unexpected number of partitions at the primary storage; got %d; want 2", len(partitionNames))
+	}
+	for _, name := range partitionNames {
+		if !replicate(name) {
+			t.Fatalf("expecting changed partition %q after the initial replication", name)
+		}
+		checkSameParts(name, true)
+	}
+	checkRowsCount(30)
+
+	// Replication of unchanged partitions mustn't change them
+	for _, name := range partitionNames {
+		if replicate(name) {
+			t.Fatalf("unexpected change of partition %q without new data", name)
+		}
+	}
+
+	// New parts must be replicated
+	addRows(now, 5)
+	flushToDisk(partitionNames[1])
+	checkSameParts(partitionNames[0], true)
+	checkSameParts(partitionNames[1], false)
+	if !replicate(partitionNames[1]) {
+		t.Fatalf("expecting changed partition %q after adding new rows", partitionNames[1])
+	}
+	checkSameParts(partitionNames[1], true)
+	checkRowsCount(35)
+
+	// The follower storage must ignore new rows
+	lr := GetLogRows(nil, nil, nil, nil, "")
+	lr.MustAdd(TenantID{}, now, []Field{{Name: "_msg", Value: "foo"}}, nil)
+	follower.MustAddRows(lr)
+	PutLogRows(lr)
+	follower.DebugFlush()
+	checkRowsCount(35)
+
+	// Replicated partitions must survive restart
+	follower.MustClose()
+	follower = MustOpenStorage(followerPath, followerCfg)
+	checkRowsCount(35)
+	if fs.IsPathExist(filepath.Join(followerPath, replicasDirname)) {
+		t.Fatalf("replicas must be moved to partitions on startup")
+	}
+	if replicate(partitionNames[1]) {
+		t.Fatalf("unexpected change of partition %q after restart", partitionNames[1])
+	}
+
+	// Drop the partition
+	if err := follower.PartitionDrop(partitionNames[0]); err != nil {
+		t.Fatalf("cannot drop partition: %s", err)
+	}
+	checkRowsCount(25)
+	if err := follower.PartitionDrop(partitionNames[0]); err == nil {
+		t.Fatalf("expecting non-nil error when dropping missing partition")
+	}
+
+	// Invalid file paths must be rejected
+	if _, err := follower.PartitionReplicate(partitionNames[0], []PartitionFile{{Path: "../foo"}}, nil); err == nil {
+		t.Fatalf("expecting non-nil error for file path outside the partition")
+	}
+	if _, err := primary.PartitionSnapshotFilePath(partitionNames[0], "invalid-snapshot", "datadb/parts.json"); err == nil {
+		t.Fatalf("expecting non-nil error for invalid snapshot name")
+	}
+
+	follower.MustClose()
+	primary.MustClose()
+	fs.MustRemoveDir(path)
+}
@@ -38,6 +38,14 @@ type QueryContext struct {
 	// AllowPartialResponse indicates whether to allow partial response. This flag is used only in cluster setup when vlselect queries vlstorage nodes.
 	AllowPartialResponse bool
 
+	// Limits contains optional resource limits for the Query.
+	Limits QueryLimits
+
+	// analyzer is an optional analyzer for collecting counters during the search.
+	//
+	// It isn't propagated to the derived QueryContext, so subqueries aren't analyzed.
+	analyzer *queryAnalyzer
+
 	// startTime is creation time for the QueryContext.
 	//
 	// It is used for calculating query druation.
@@ -52,17 +60,19 @@ func NewQueryContext(ctx context.Context, qs *QueryStats, tenantIDs []TenantID,
 
 // WithQuery returns new QueryContext with the given q, while preserving other fields from qctx.
 func (qctx *QueryContext) WithQuery(q *Query) *QueryContext {
-	return newQueryContext(qctx.Context, qctx.QueryStats, qctx.TenantIDs, q, qctx.AllowPartialResponse, qctx.startTime)
+	return qctx.WithContextAndQuery(qctx.Context, q)
 }
 
 // WithContext returns new QueryContext with the given ctx, while preserving other fields from qctx.
 func (qctx *QueryContext) WithContext(ctx context.Context) *QueryContext {
-	return newQueryContext(ctx, qctx.QueryStats, qctx.TenantIDs, qctx.Query, qctx.AllowPartialResponse, qctx.startTime)
+	return qctx.WithContextAndQuery(ctx, qctx.Query)
 }
 
 // WithContextAndQuery returns new QueryContext with the given ctx and q, while preserving other fields from qctx.
 func (qctx *QueryContext) WithContextAndQuery(ctx context.Context, q *Query) *QueryContext {
-	return newQueryContext(ctx, qctx.QueryStats, qctx.TenantIDs, q, qctx.AllowPartialResponse, qctx.startTime)
+	qctxNew := newQueryContext(ctx, qctx.QueryStats, qctx.TenantIDs, q, qctx.AllowPartialResponse, qctx.startTime)
+	qctxNew.Limits = qctx.Limits
+	return qctxNew
 }
 
 // QueryDurationNsecs returns the duration in nanoseconds since the NewQueryContext call.
@@ -118,6 +128,12 @@ type storageSearchOptions struct {
 
 	// timeOffset is the offset in nanoseconds, which must be subtracted from the selected the _time values before these values are passed to query pipes.
 	timeOffset int64
+
+	// limiter is an optional limiter for resources used by the search.
+	limiter *queryLimiter
+
+	// analyzer is an optional analyzer for collecting counters during the search.
+	analyzer *queryAnalyzer
 }
 
 // partitionSearchOptions is search options for the partition.
@@ -143,6 +159,9 @@ type partitionSearchOptions struct {
 
 	// fieldsFilter is the filter of fields to return in the result
 	fieldsFilter *prefixfilter.Filter
+
+	// analysis is an optional counters for the search in the partition.
+	analysis *PartitionAnalysis
 }
 
 func (pso *partitionSearchOptions) matchStreamID(sid *streamID) bool {
@@ -208,11 +227,21 @@ func (s *Storage) runQuery(qctx *QueryContext, writeBlock writeBlockResultFunc)
 	q := qNew
 
 	sso := s.getSearchOptions(qctx.TenantIDs, q)
+	sso.analyzer = qctx.analyzer
 
 	search := func(stopCh <-chan struct{}, writeBlockToPipes writeBlockResultFunc) error {
 		workersCount := q.GetParallelReaders(s.defaultParallelReaders)
-		s.searchParallel(workersCount, sso, qctx.QueryStats, stopCh, writeBlockToPipes)
-		return nil
+		if !qctx.Limits.isEnabled() {
+			s.searchParallel(workersCount, sso, qctx.QueryStats, stopCh, writeBlockToPipes)
+			return nil
+		}
+
+		ssoLocal := *sso
+		ssoLocal.limiter = newQueryLimiter(&qctx.Limits)
+		stopChLocal, release := ssoLocal.limiter.newStopCh(stopCh)
+		s.searchParallel(workersCount, &ssoLocal, qctx.QueryStats, stopChLocal, writeBlockToPipes)
+		release()
+		return ssoLocal.limiter.getError()
 	}
 
 	concurrency := q.GetConcurrency()
@@ -646,21 +675,7 @@ func (s *Storage) getTenantIDs(ctx context.Context, start, end int64) ([]TenantI
 
 	// Select partitions according to the selected time range
 	s.partitionsLock.Lock()
-	ptws := s.partitions
-	minDay := start / nsecsPerDay
-	n := sort.Search(len(ptws), func(i int) bool {
-		return ptws[i].day >= minDay
-	})
-	ptws = ptws[n:]
-	maxDay := end / nsecsPerDay
-	n = sort.Search(len(ptws), func(i int) bool {
-		return ptws[i].day > maxDay
-	})
-	ptws = ptws[:n]
-
-	// Copy the selected partitions, so they don't interfere with s.partitions.
-	ptws = append([]*partitionWrapper{}, ptws...)
-
+	ptws := s.getPartitionsForTimeRangeLocked(start, end)
 	for _, ptw := range ptws {
 		ptw.incRef()
 	}
@@ -752,6 +767,11 @@ func initSubqueries(qctx *QueryContext, runQuery runQueryFunc, keepInSubquery bo
 		return nil, fmt.Errorf("cannot initialize `join` subqueries: %w", err)
 	}
 
+	qNew, err = initLookupTables(qNew, qctx.TenantIDs)
+	if err != nil {
+		return nil, fmt.Errorf("cannot initialize `lookup` tables: %w", err)
+	}
+
 	runUnionQuery := func(ctx context.Context, q *Query, writeBlock writeBlockResultFunc) error {
 		qctxLocal := qctx.WithContextAndQuery(ctx, q)
 		return runQuery(qctxLocal, writeBlock)
@@ -878,6 +898,38 @@ func hasJoinPipes(pipes []pipe) bool {
 	return false
 }
 
+func initLookupTables(q *Query, tenantIDs []TenantID) (*Query, error) {
+	if !hasLookupPipes(q.pipes) {
+		return q, nil
+	}
+
+	pipesNew := make([]pipe, len(q.pipes))
+	for i, p := range q.pipes {
+		if pl, ok := p.(*pipeLookup); ok {
+			pNew, err := pl.initLookupTable(tenantIDs)
+			if err != nil {
+				return nil, err
+			}
+			p = pNew
+		}
+		pipesNew[i] = p
+	}
+
+	qNew := q.cloneShallow()
+	qNew.pipes = pipesNew
+
+	return qNew, nil
+}
+
+func hasLookupPipes(pipes []pipe) bool {
+	for _, p := range pipes {
+		if _, ok := p.(*pipeLookup); ok {
+			return true
+		}
+	}
+	return false
+}
+
 func (iff *ifFilter) visitSubqueries(visitFunc func(q *Query)) {
 	if iff != nil {
 		visitSubqueriesInFilter(iff.f, visitFunc)
@@ -1263,6 +1315,7 @@ func (s *Storage) searchParallel(workersCount int, sso *storageSearchOptions, qs
 			qsLocal := &QueryStats{}
 			bs := getBlockSearch()
 			bm := getBitmap(0)
+			bytesReadPrev := uint64(0)
 
 			for bswb := range workCh {
 				bsws := bswb.bsws
@@ -1288,6 +1341,12 @@ func (s *Storage) searchParallel(workersCount int, sso *storageSearchOptions, qs
 					qsLocal.BlocksProcessed++
 					qsLocal.RowsProcessed += rowsProcessed
 					qsLocal.RowsFound += uint64(bs.br.rowsLen)
+
+					if sso.limiter != nil {
+						bytesRead := qsLocal.GetBytesReadTotal()
+						sso.limiter.update(bytesRead-bytesReadPrev, rowsProcessed)
+						bytesReadPrev = bytesRead
+					}
 				}
 				bswb.bsws = bswb.bsws[:0]
 				putBlockSearchWorkBatch(bswb)
@@ -1339,22 +1398,7 @@ func (s *Storage) searchParallel(workersCount int, sso *storageSearchOptions, qs
 func (s *Storage) getPartitionsForTimeRange(minTimestamp, maxTimestamp int64) (ptws []*partitionWrapper, ptwsDecRef func()) {
 	s.partitionsLock.Lock()
 
-	// s.partitions are sorted by s.day. Use binary search for finding partitions for the given [minTimestamp, maxTimestamp] time range.
-	ptwsTmp := s.partitions
-	minDay := minTimestamp / nsecsPerDay
-	n := sort.Search(len(ptwsTmp), func(i int) bool {
-		return ptwsTmp[i].day >= minDay
-	})
-	ptwsTmp = ptwsTmp[n:]
-	maxDay := maxTimestamp / nsecsPerDay
-	n = sort.Search(len(ptwsTmp), func(i int) bool {
-		return ptwsTmp[i].day > maxDay
-	})
-	ptwsTmp = ptwsTmp[:n]
-
-	// Copy the selected partitions, so they don't interfere with s.partitions.
-	ptws = append([]*partitionWrapper{}, ptwsTmp...)
-
+	ptws = s.getPartitionsForTimeRangeLocked(minTimestamp, maxTimestamp)
 	for _, ptw := range ptws {
 		ptw.incRef()
 	}
@@ -1406,7 +1450,7 @@ func (pt *partition) getSearchOptions(sso *storageSearchOptions) *partitionSearc
 	if hasStreamFilters(f) {
 		f = initStreamFilters(sso.tenantIDs, pt.idb, f)
 	}
-	return &partitionSearchOptions{
+	pso := &partitionSearchOptions{
 		tenantIDs:    tenantIDs,
 		streamIDs:    streamIDs,
 		minTimestamp: sso.minTimestamp,
@@ -1414,6 +1458,10 @@ func (pt *partition) getSearchOptions(sso *storageSearchOptions) *partitionSearc
 		filter:       f,
 		fieldsFilter: sso.fieldsFilter,
 	}
+	if sso.analyzer != nil {
+		pso.analysis = sso.analyzer.newPartitionAnalysis(pt, pso)
+	}
+	return pso
 }
 
 func intersectStreamIDs(a, b []streamID) []streamID {
@@ -1479,6 +1527,10 @@ func (ddb *datadb) search(pso *partitionSearchOptions, qs *QueryStats, workCh ch
 	// Select parts with data for the given time range
 	pws, pwsDecRef := ddb.getPartsForTimeRange(pso.minTimestamp, pso.maxTimestamp)
 
+	if pso.analysis != nil {
+		atomic.AddUint64(&pso.analysis.PartsSearched, uint64(len(pws)))
+	}
+
 	// Apply search to matching parts
 	for _, pw := range pws {
 		pw.p.search(pso, qs, workCh, stopCh)
@@ -1520,8 +1572,11 @@ func (p *part) search(pso *partitionSearchOptions, qs *QueryStats, workCh chan<-
 	putBlockHeaders(bhss)
 }
 
-func (p *part) hasMatchingRows(pso *partitionSearchOptions, stopCh <-chan struct{}) bool {
-	var hasMatch atomic.Bool
+// countMatchingRows returns the number of rows in p matching pso.
+//
+// If maxRows > 0, then the counting is stopped after maxRows matching rows are found.
+func (p *part) countMatchingRows(pso *partitionSearchOptions, maxRows uint64, stopCh <-chan struct{}) uint64 {
+	var rowsCount atomic.Uint64
 
 	// spin up workers
 	var wg sync.WaitGroup
@@ -1541,11 +1596,9 @@ func (p *part) hasMatchingRows(pso *partitionSearchOptions, stopCh <-chan struct
 				for i := range bsws {
 					bsw := &bsws[i]
 
-					if !hasMatch.Load() && !needStop(stopCh) {
+					if (maxRows == 0 || rowsCount.Load() < maxRows) && !needStop(stopCh) {
 						bs.search(qsLocal, bsw, bm)
-						if bs.br.rowsLen > 0 {
-							hasMatch.Store(true)
-						}
+						rowsCount.Add(uint64(bs.br.rowsLen))
 					}
 
 					bsw.reset()
@@ -1568,7 +1621,7 @@ func (p *part) hasMatchingRows(pso *partitionSearchOptions, stopCh <-chan struct
 	close(workCh)
 	wg.Wait()
 
-	return hasMatch.Load()
+	return rowsCount.Load()
 }
 
 func getBlockHeaders() *blockHeaders {
@@ -1652,6 +1705,9 @@ func (p *part) searchByTenantIDs(pso *partitionSearchOptions, qs *QueryStats, bh
 
 		if pso.minTimestamp > ibh.maxTimestamp || pso.maxTimestamp < ibh.minTimestamp {
 			// Skip the ibh, since it doesn't contain entries on the requested time range
+			if pso.analysis != nil {
+				atomic.AddUint64(&pso.analysis.IndexBlocksSkippedByHeader, 1)
+			}
 			continue
 		}
 
@@ -1667,8 +1723,14 @@ func (p *part) searchByTenantIDs(pso *partitionSearchOptions, qs *QueryStats, bh
 			for len(bhs) > 0 && bhs[0].streamID.tenantID.equal(tenantID) {
 				bh := &bhs[0]
 				bhs = bhs[1:]
+				if pso.analysis != nil {
+					atomic.AddUint64(&pso.analysis.BlocksConsidered, 1)
+				}
 				th := &bh.timestampsHeader
 				if pso.minTimestamp > th.maxTimestamp || pso.maxTimestamp < th.minTimestamp {
+					if pso.analysis != nil {
+						atomic.AddUint64(&pso.analysis.BlocksSkippedByHeader, 1)
+					}
 					continue
 				}
 				if !scheduleBlockSearch(bh) {
@@ -1755,6 +1817,9 @@ func (p *part) searchByStreamIDs(pso *partitionSearchOptions, qs *QueryStats, bh
 
 		if pso.minTimestamp > ibh.maxTimestamp || pso.maxTimestamp < ibh.minTimestamp {
 			// Skip the ibh, since it doesn't contain entries on the requested time range
+			if pso.analysis != nil {
+				atomic.AddUint64(&pso.analysis.IndexBlocksSkippedByHeader, 1)
+			}
 			continue
 		}
 
@@ -1770,8 +1835,14 @@ func (p *part) searchByStreamIDs(pso *partitionSearchOptions, qs *QueryStats, bh
 			for len(bhs) > 0 && bhs[0].streamID.equal(streamID) {
 				bh := &bhs[0]
 				bhs = bhs[1:]
+				if pso.analysis != nil {
+					atomic.AddUint64(&pso.analysis.BlocksConsidered, 1)
+				}
 				th := &bh.timestampsHeader
 				if pso.minTimestamp > th.maxTimestamp || pso.maxTimestamp < th.minTimestamp {
+					if pso.analysis != nil {
+						atomic.AddUint64(&pso.analysis.BlocksSkippedByHeader, 1)
+					}
 					continue
 				}
 				if !scheduleBlockSearch(bh) {
@@ -1848,6 +1919,26 @@ func forEachStreamField(streams []ValueWithHits, f func(f Field, hits uint64)) {
 	}
 }
 
+// hideStreamFieldsInStreamName returns the _stream value s without the stream fields matching fieldFilters.
+//
+// An empty stream name is returned if s cannot be parsed, so the hidden fields cannot leak.
+func hideStreamFieldsInStreamName(s string, fieldFilters []string) string {
+	fields, err := parseStreamFields(nil, s)
+	if err != nil {
+		return "{}"
+	}
+
+	st := GetStreamTags()
+	defer PutStreamTags(st)
+
+	for _, f := range fields {
+		if !prefixfilter.MatchFilters(fieldFilters, f.Name) {
+			st.Add(f.Name, f.Value)
+		}
+	}
+	return st.String()
+}
+
 func parseStreamFields(dst []Field, s string) ([]Field, error) {
 	if len(s) == 0 || s[0] != '{' {
 		return dst, fmt.Errorf("missing '{' at the beginning of stream name")
@@ -985,6 +985,132 @@ func TestStorageRunQuery(t *testing.T) {
 			},
 		})
 	})
+	t.Run("query-limits", func(t *testing.T) {
+		f := func(t *testing.T, limits QueryLimits, expectError bool) {
+			t.Helper()
+
+			q := mustParseQuery(`*`)
+			qctx := newTestQueryContext(allTenantIDs, q)
+			qctx.Limits = limits
+			err := s.RunQuery(qctx, func(_ uint, _ *DataBlock) {})
+			if expectError && err == nil {
+				t.Fatalf("expecting non-nil error")
+			}
+			if !expectError && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		}
+
+		const rowsTotal = tenantsCount * streamsPerTenant * blocksPerStream * rowsPerBlock
+
+		// no limits
+		f(t, QueryLimits{}, false)
+
+		// limits aren't exceeded
+		f(t, QueryLimits{MaxRowsProcessed: rowsTotal}, false)
+		f(t, QueryLimits{MaxBytesRead: 1e9}, false)
+
+		// limits are exceeded
+		f(t, QueryLimits{MaxRowsProcessed: rowsTotal - 1}, true)
+		f(t, QueryLimits{MaxBytesRead: 1}, true)
+	})
+	t.Run("estimate-query", func(t *testing.T) {
+		f := func(t *testing.T, query string, tenantIDs []TenantID, rowsExpected uint64) {
+			t.Helper()
+
+			q := mustParseQuery(query)
+			qctx := newTestQueryContext(tenantIDs, q)
+			qe, err := s.EstimateQuery(qctx)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if qe.RowsCount != rowsExpected {
+				t.Fatalf("unexpected number of rows; got %d; want %d", qe.RowsCount, rowsExpected)
+			}
+			if rowsExpected > 0 && (qe.PartsCount == 0 || qe.BlocksCount == 0 || qe.UncompressedBytes == 0 || qe.CompressedBytes == 0) {
+				t.Fatalf("unexpected zero values in the estimate: %#v", qe)
+			}
+		}
+
+		const rowsPerTenant = streamsPerTenant * blocksPerStream * rowsPerBlock
+
+		// other filters are ignored during the estimation
+		f(t, `*`, allTenantIDs, tenantsCount*rowsPerTenant)
+		f(t, `foobar`, allTenantIDs[:2], 2*rowsPerTenant)
+
+		// stream filter
+		f(t, `{instance="host-1:234"}`, allTenantIDs, tenantsCount*rowsPerTenant/streamsPerTenant)
+
+		// missing tenant
+		f(t, `*`, []TenantID{{AccountID: 1234}}, 0)
+
+		// time range mismatch
+		f(t, `_time:<1d offset 2d`, allTenantIDs, 0)
+	})
+
+	t.Run("analyze-query", func(t *testing.T) {
+		f := func(t *testing.T, query string, tenantIDs []TenantID, streamIDsExpected int, rowsMatchedExpected uint64, filtersExpected []string) {
+			t.Helper()
+
+			q := mustParseQuery(query)
+			qctx := newTestQueryContext(tenantIDs, q)
+			qa, err := s.AnalyzeQuery(qctx)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if qa.RowsReturned != rowsMatchedExpected {
+				t.Fatalf("unexpected number of returned rows; got %d; want %d", qa.RowsReturned, rowsMatchedExpected)
+			}
+			if len(qa.Partitions) != 1 {
+				t.Fatalf("unexpected number of partitions; got %d; want 1", len(qa.Partitions))
+			}
+			pa := qa.Partitions[0]
+			if pa.StreamIDs != streamIDsExpected {
+				t.Fatalf("unexpected number of stream ids; got %d; want %d", pa.StreamIDs, streamIDsExpected)
+			}
+			if pa.RowsMatched != rowsMatchedExpected {
+				t.Fatalf("unexpected number of matched rows; got %d; want %d", pa.RowsMatched, rowsMatchedExpected)
+			}
+			if pa.PartsSearched == 0 || pa.BlocksConsidered == 0 {
+				t.Fatalf("unexpected zero counters: %#v", pa)
+			}
+			var filters []string
+			for _, fa := range pa.Filters {
+				filters = append(filters, fa.Filter)
+			}
+			if !reflect.DeepEqual(filters, filtersExpected) {
+				t.Fatalf("unexpected filters; got %q; want %q", filters, filtersExpected)
+			}
+			if pa.BlocksSkippedByBloom == 0 && len(filters) > 0 && rowsMatchedExpected > 0 {
+				if fa := pa.Filters[len(pa.Filters)-1]; fa.RowsMatched != rowsMatchedExpected {
+					t.Fatalf("unexpected number of rows matched by the last filter; got %d; want %d", fa.RowsMatched, rowsMatchedExpected)
+				}
+			}
+		}
+
+		const rowsPerTenant = streamsPerTenant * blocksPerStream * rowsPerBlock
+
+		f(t, `*`, allTenantIDs, -1, tenantsCount*rowsPerTenant, []string{"*"})
+		f(t, `"message 3"`, allTenantIDs, -1, tenantsCount*streamsPerTenant*blocksPerStream, []string{`"message 3"`})
+		f(t, `{instance="host-1:234"} "message 3"`, allTenantIDs, tenantsCount, tenantsCount*blocksPerStream, []string{`"message 3"`})
+		f(t, `"message 3" "block 2"`, allTenantIDs[:1], -1, streamsPerTenant, []string{`"message 3"`, `"block 2"`})
+	})
+
+	t.Run("analyze-query-bloom", func(t *testing.T) {
+		q := mustParseQuery(`foo bar`)
+		qctx := newTestQueryContext(allTenantIDs, q)
+		qa, err := s.AnalyzeQuery(qctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		pa := qa.Partitions[0]
+		if pa.RowsMatched != 0 {
+			t.Fatalf("unexpected number of matched rows; got %d; want 0", pa.RowsMatched)
+		}
+		if n := pa.BlocksConsidered - pa.BlocksSkippedByHeader; pa.BlocksSkippedByBloom != n {
+			t.Fatalf("unexpected number of blocks skipped by bloom filters; got %d; want %d", pa.BlocksSkippedByBloom, n)
+		}
+	})
 
 	// Close the storage and delete its data
 	s.MustClose()
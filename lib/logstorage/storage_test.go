@@ -2,6 +2,9 @@ package logstorage
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"sync"
 	"testing"
@@ -110,6 +113,229 @@ func TestStorageMustAddRows(t *testing.T) {
 	fs.MustRemoveDir(path)
 }
 
+func TestStoragePartitionInterval(t *testing.T) {
+	t.Parallel()
+
+	path := t.Name()
+	tenantIDs := []TenantID{{}}
+
+	addRows := func(s *Storage, timestamps []int64) {
+		t.Helper()
+
+		lr := GetLogRows(nil, nil, nil, nil, "")
+		for i, ts := range timestamps {
+			fields := []Field{
+				{
+					Name:  "_msg",
+					Value: fmt.Sprintf("message %d", i),
+				},
+			}
+			lr.MustAdd(TenantID{}, ts, fields, nil)
+		}
+		s.MustAddRows(lr)
+		PutLogRows(lr)
+		s.DebugFlush()
+	}
+
+	// Store rows into per-day partitions
+	today := getPartitionMinTimestamp(time.Now().UnixNano(), nsecsPerDay)
+	cfg := &StorageConfig{
+		Retention: 7 * 24 * time.Hour,
+	}
+	s := MustOpenStorage(path, cfg)
+	addRows(s, []int64{today - nsecsPerDay, today + 1})
+	checkPartitionNames(t, s, []string{
+		getPartitionName(today-nsecsPerDay, nsecsPerDay),
+		getPartitionName(today, nsecsPerDay),
+	})
+	s.MustClose()
+
+	// Switch to hourly partitions. The existing per-day partitions must remain readable and writable,
+	// while new partitions must be hourly.
+	cfg.PartitionInterval = time.Hour
+	s = MustOpenStorage(path, cfg)
+	ts := today + 12*nsecsPerHour
+	tsHourly := today - 2*nsecsPerDay + 12*nsecsPerHour
+	addRows(s, []int64{ts, ts + 1, tsHourly})
+	checkPartitionNames(t, s, []string{
+		getPartitionName(tsHourly, nsecsPerHour),
+		getPartitionName(today-nsecsPerDay, nsecsPerDay),
+		getPartitionName(today, nsecsPerDay),
+	})
+	checkQueryResults(t, s, tenantIDs, "* | count() rows", []string{`{"rows":"5"}`})
+
+	tr := fmt.Sprintf("_time:[%d, %d]", ts, ts+nsecsPerHour-1)
+	checkQueryResults(t, s, tenantIDs, tr+" | count() rows", []string{`{"rows":"2"}`})
+	s.MustClose()
+
+	// The partitions must be properly opened after the restart
+	s = MustOpenStorage(path, cfg)
+	checkQueryResults(t, s, tenantIDs, "* | count() rows", []string{`{"rows":"5"}`})
+	checkQueryResults(t, s, tenantIDs, tr+" | count() rows", []string{`{"rows":"2"}`})
+
+	// Detach and attach the hourly partition
+	name := getPartitionName(tsHourly, nsecsPerHour)
+	if err := s.PartitionDetach(name); err != nil {
+		t.Fatalf("cannot detach partition %q: %s", name, err)
+	}
+	checkQueryResults(t, s, tenantIDs, "* | count() rows", []string{`{"rows":"4"}`})
+
+	// Rows for the detached partition must be dropped
+	addRows(s, []int64{tsHourly + 1})
+	checkQueryResults(t, s, tenantIDs, "* | count() rows", []string{`{"rows":"4"}`})
+
+	if err := s.PartitionAttach(name); err != nil {
+		t.Fatalf("cannot attach partition %q: %s", name, err)
+	}
+	checkQueryResults(t, s, tenantIDs, "* | count() rows", []string{`{"rows":"5"}`})
+	s.MustClose()
+
+	// Switch to weekly partitions. The new partition mustn't overlap the existing partitions,
+	// so it must fall back to per-day partition if the week overlaps them.
+	cfg.PartitionInterval = 7 * 24 * time.Hour
+	s = MustOpenStorage(path, cfg)
+	tsWeekly := today - 3*nsecsPerDay + 1
+	addRows(s, []int64{tsWeekly, ts + 2, today - nsecsPerDay + 2})
+	weekStart := getPartitionMinTimestamp(tsWeekly, nsecsPerWeek)
+	nameWeekly := getPartitionName(weekStart, nsecsPerWeek)
+	if weekStart+nsecsPerWeek > tsHourly {
+		nameWeekly = getPartitionName(today-3*nsecsPerDay, nsecsPerDay)
+	}
+	checkPartitionNames(t, s, []string{
+		nameWeekly,
+		getPartitionName(tsHourly, nsecsPerHour),
+		getPartitionName(today-nsecsPerDay, nsecsPerDay),
+		getPartitionName(today, nsecsPerDay),
+	})
+	checkQueryResults(t, s, tenantIDs, "* | count() rows", []string{`{"rows":"8"}`})
+	checkQueryResults(t, s, tenantIDs, tr+" | count() rows", []string{`{"rows":"3"}`})
+
+	// Partitions overlapping the attached partitions cannot be attached
+	nameOverlapping := getPartitionName(today, nsecsPerHour)
+	mustCreatePartition(filepath.Join(path, partitionsDirname, nameOverlapping))
+	if err := s.PartitionAttach(nameOverlapping); err == nil {
+		t.Fatalf("expecting non-nil error when attaching overlapping partition %q", nameOverlapping)
+	}
+	s.MustClose()
+
+	fs.MustRemoveDir(path)
+}
+
+func TestStorageReadPartFormatV3(t *testing.T) {
+	t.Parallel()
+
+	path := t.Name()
+	tenantIDs := []TenantID{{}}
+
+	cfg := &StorageConfig{
+		Retention: 7 * 24 * time.Hour,
+	}
+	s := MustOpenStorage(path, cfg)
+	lr := GetLogRows([]string{"host"}, nil, nil, nil, "")
+	now := time.Now().UnixNano()
+	for i := 0; i < 100; i++ {
+		fields := []Field{
+			{
+				Name:  "host",
+				Value: fmt.Sprintf("host-%d", i%5),
+			},
+			{
+				Name:  "level",
+				Value: []string{"info", "warn"}[i%2],
+			},
+			{
+				Name:  "n",
+				Value: fmt.Sprintf("%d", i),
+			},
+			{
+				Name:  "_msg",
+				Value: fmt.Sprintf("message %d", i),
+			},
+		}
+		lr.MustAdd(TenantID{}, now+int64(i), fields, nil)
+	}
+	s.MustAddRows(lr)
+	PutLogRows(lr)
+	s.DebugFlush()
+	s.MustClose()
+
+	// Rewrite all the parts in the v3 format, which has no valuesCodec and valuesUncompressedSize at column headers
+	for _, partition := range ListPartitions(path) {
+		datadbPath := filepath.Join(path, partitionsDirname, partition, datadbDirname)
+		for _, partName := range mustReadPartNames(datadbPath) {
+			mustRewritePartWithFormatVersion(t, filepath.Join(datadbPath, partName), 3)
+		}
+	}
+
+	// The v3 parts must be readable
+	s = MustOpenStorage(path, cfg)
+	checkQueryResults(t, s, tenantIDs, "* | count() rows", []string{`{"rows":"100"}`})
+	checkQueryResults(t, s, tenantIDs, "level:warn n:>=90 | count() rows", []string{`{"rows":"5"}`})
+	checkQueryResults(t, s, tenantIDs, "host:=host-1 | sum(n) n", []string{`{"n":"970"}`})
+	checkQueryResults(t, s, tenantIDs, "* | block_stats | filter field:=n | uniq by (codec, compression_ratio)", []string{`{"codec":"default"}`})
+
+	// Merging v3 parts must produce parts in the latest format
+	s.MustForceMerge("")
+	checkQueryResults(t, s, tenantIDs, "* | count() rows", []string{`{"rows":"100"}`})
+	checkQueryResults(t, s, tenantIDs, "host:=host-1 | sum(n) n", []string{`{"n":"970"}`})
+	s.MustClose()
+
+	fs.MustRemoveDir(path)
+}
+
+// mustRewritePartWithFormatVersion rewrites the part at partPath in the given partFormatVersion.
+func mustRewritePartWithFormatVersion(t *testing.T, partPath string, partFormatVersion uint) {
+	t.Helper()
+
+	tmpPath := partPath + ".tmp"
+
+	var bsr blockStreamReader
+	bsr.MustInitFromFilePart(partPath, nil)
+
+	var bsw blockStreamWriter
+	bsw.MustInitForFilePart(tmpPath, false, nil)
+	bsw.setPartFormatVersion(partFormatVersion)
+
+	sbu := getStringsBlockUnmarshaler()
+	defer putStringsBlockUnmarshaler(sbu)
+	vd := getValuesDecoder()
+	defer putValuesDecoder(vd)
+
+	var tmp rows
+	for bsr.NextBlock() {
+		bd := &bsr.blockData
+		if err := bd.unmarshalRows(&tmp, sbu, vd); err != nil {
+			t.Fatalf("cannot unmarshal rows from %q: %s", partPath, err)
+		}
+		bsw.MustWriteRows(&bd.streamID, tmp.timestamps, tmp.rows)
+		tmp.reset()
+		sbu.reset()
+		vd.reset()
+	}
+	bsr.MustClose()
+
+	var ph partHeader
+	bsw.Finalize(&ph)
+	ph.mustWriteMetadata(tmpPath)
+	if ph.FormatVersion != partFormatVersion {
+		t.Fatalf("unexpected part format version; got %d; want %d", ph.FormatVersion, partFormatVersion)
+	}
+
+	fs.MustRemoveDir(partPath)
+	if err := os.Rename(tmpPath, partPath); err != nil {
+		t.Fatalf("cannot rename %q to %q: %s", tmpPath, partPath, err)
+	}
+}
+
+func checkPartitionNames(t *testing.T, s *Storage, namesExpected []string) {
+	t.Helper()
+
+	names := s.PartitionList()
+	if !reflect.DeepEqual(names, namesExpected) {
+		t.Fatalf("unexpected partitions; got %q; want %q", names, namesExpected)
+	}
+}
+
 func TestStorageDeleteTaskOps(t *testing.T) {
 	t.Parallel()
 
@@ -132,7 +358,7 @@ func TestStorageDeleteTaskOps(t *testing.T) {
 	}
 
 	// Register delete task
-	if err := s.DeleteRunTask(ctx, taskID, timestamp, tenantIDs, f); err != nil {
+	if err := s.DeleteRunTask(ctx, taskID, timestamp, tenantIDs, f, false); err != nil {
 		t.Fatalf("unexpected error in DeleteRunTask: %s", err)
 	}
 
@@ -142,7 +368,8 @@ func TestStorageDeleteTaskOps(t *testing.T) {
 		t.Fatalf("unexpected error in DeleteActiveTasks: %s", err)
 	}
 	result := MarshalDeleteTasksToJSON(dts)
-	resultExpected := `[{"task_id":"task_id_1","tenant_ids":[{"account_id":123,"project_id":456}],"filter":"app:=foo SECRET","start_time":"2009-02-13T23:31:30.123456789Z"}]`
+	resultExpected := `[{"task_id":"task_id_1","tenant_ids":[{"account_id":123,"project_id":456}],"filter":"app:=foo SECRET","start_time":"2009-02-13T23:31:30.123456789Z",` +
+		`"status":"pending","progress":{"partitions_total":0,"partitions_processed":0,"parts_total":0,"parts_processed":0,"rows_deleted":0,"rows_updated":0}}]`
 	if string(result) != resultExpected {
 		t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
 	}
@@ -161,6 +388,29 @@ func TestStorageDeleteTaskOps(t *testing.T) {
 		t.Fatalf("unexpected number of deleted tasks: %d; want 0; tasks: %s", len(dts), MarshalDeleteTasksToJSON(dts))
 	}
 
+	// Verify that the stopped task is registered in the list of finished tasks
+	dts, err = s.DeleteFinishedTasks(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error in DeleteFinishedTasks: %s", err)
+	}
+	if len(dts) != 1 {
+		t.Fatalf("unexpected number of finished tasks: %d; want 1; tasks: %s", len(dts), MarshalDeleteTasksToJSON(dts))
+	}
+	if dts[0].TaskID != taskID || dts[0].Status != DeleteTaskStatusCanceled || dts[0].EndTime == nil {
+		t.Fatalf("unexpected finished task: %s", dts[0])
+	}
+
+	// Verify that the finished tasks survive the restart
+	s.MustClose()
+	s = MustOpenStorage(path, cfg)
+	dts, err = s.DeleteFinishedTasks(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error in DeleteFinishedTasks: %s", err)
+	}
+	if len(dts) != 1 || dts[0].TaskID != taskID {
+		t.Fatalf("unexpected finished tasks after the restart: %s", MarshalDeleteTasksToJSON(dts))
+	}
+
 	s.MustClose()
 
 	fs.MustRemoveDir(path)
@@ -184,14 +434,36 @@ func TestStorageProcessDeleteTask(t *testing.T) {
 		checkQueryResults(t, s, tenantIDs, filters, rowsExpected)
 	}
 
-	deleteRows := func(tenantIDs []TenantID, filters string) {
+	processDeleteTask := func(tenantIDs []TenantID, filters string, dryRun bool) uint64 {
 		t.Helper()
-		dt := newDeleteTask("task_id_x", tenantIDs, filters, now)
-		for !s.processDeleteTask(ctx, dt) {
+		dt := newDeleteTask("task_id_x", tenantIDs, filters, "", now, dryRun)
+		for {
+			dt.progress.resetForAttempt(dt.DryRun)
+			ok, err := s.processDeleteTask(ctx, dt)
+			if err != nil {
+				t.Fatalf("unexpected error in processDeleteTask: %s", err)
+			}
+			if ok {
+				break
+			}
 			// Unsuccessful attempt because of concurrently executed background merges.
 			// Wait for a bit and try again.
 			time.Sleep(10 * time.Millisecond)
 		}
+		return dt.progress.rowsDeleted.Load()
+	}
+
+	deleteRows := func(tenantIDs []TenantID, filters string) {
+		t.Helper()
+		processDeleteTask(tenantIDs, filters, false)
+	}
+
+	checkRowsDeleted := func(tenantIDs []TenantID, filters string, dryRun bool, rowsDeletedExpected uint64) {
+		t.Helper()
+		rowsDeleted := processDeleteTask(tenantIDs, filters, dryRun)
+		if rowsDeleted != rowsDeletedExpected {
+			t.Fatalf("unexpected number of deleted rows for filter [%s] with dryRun=%v; got %d; want %d", filters, dryRun, rowsDeleted, rowsDeletedExpected)
+		}
 	}
 
 	allTenantIDs := []TenantID{
@@ -223,9 +495,13 @@ func TestStorageProcessDeleteTask(t *testing.T) {
 	deleteRows(allTenantIDs, "row_id:=foobar")
 	check(allTenantIDs, "* | count() rows", []string{`{"rows":"10500"}`})
 
+	// Count logs with the given row_id across all the tenants in dry-run mode
+	checkRowsDeleted(allTenantIDs, "row_id:=42", true, 105)
+	check(allTenantIDs, "* | count() rows", []string{`{"rows":"10500"}`})
+
 	// Delete logs with the given row_id across all the tenants
 	check(allTenantIDs, "row_id:=42 | count() rows", []string{`{"rows":"105"}`})
-	deleteRows(allTenantIDs, "row_id:=42")
+	checkRowsDeleted(allTenantIDs, "row_id:=42", false, 105)
 	check(allTenantIDs, "row_id:=42 | count() rows", []string{`{"rows":"0"}`})
 	check(allTenantIDs, "row_id:!=42 | count() rows", []string{`{"rows":"10395"}`})
 	check(allTenantIDs, "* | count() rows", []string{`{"rows":"10395"}`})
@@ -283,6 +559,131 @@ func TestStorageProcessDeleteTask(t *testing.T) {
 	fs.MustRemoveDir(path)
 }
 
+func TestStorageProcessUpdateTask(t *testing.T) {
+	t.Parallel()
+
+	path := t.Name()
+	ctx := t.Context()
+
+	cfg := &StorageConfig{
+		Retention: 30 * 24 * time.Hour,
+	}
+	s := MustOpenStorage(path, cfg)
+
+	now := time.Now().UnixNano()
+
+	check := func(tenantIDs []TenantID, filters string, rowsExpected []string) {
+		t.Helper()
+		checkQueryResults(t, s, tenantIDs, filters, rowsExpected)
+	}
+
+	processUpdateTask := func(dt *DeleteTask) {
+		t.Helper()
+		for {
+			dt.progress.resetForAttempt(dt.DryRun)
+			ok, err := s.processDeleteTask(ctx, dt)
+			if err != nil {
+				t.Fatalf("unexpected error in processDeleteTask: %s", err)
+			}
+			if ok {
+				break
+			}
+			// Unsuccessful attempt because of concurrently executed background merges.
+			// Wait for a bit and try again.
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	checkRowsUpdated := func(tenantIDs []TenantID, filters, update string, dryRun bool, rowsUpdatedExpected uint64) {
+		t.Helper()
+
+		ru, err := ParseRowsUpdate(update)
+		if err != nil {
+			t.Fatalf("cannot parse update [%s]: %s", update, err)
+		}
+		dt := newDeleteTask("task_id_x", tenantIDs, filters, ru.String(), now, dryRun)
+		processUpdateTask(dt)
+		if n := dt.progress.rowsDeleted.Load(); n != 0 {
+			t.Fatalf("unexpected number of deleted rows for update [%s]; got %d; want 0", update, n)
+		}
+		if n := dt.progress.rowsUpdated.Load(); n != rowsUpdatedExpected {
+			t.Fatalf("unexpected number of updated rows for filter [%s], update [%s] with dryRun=%v; got %d; want %d", filters, update, dryRun, n, rowsUpdatedExpected)
+		}
+	}
+
+	allTenantIDs := []TenantID{
+		{
+			AccountID: 0,
+			ProjectID: 100,
+		},
+		{
+			AccountID: 123,
+			ProjectID: 0,
+		},
+	}
+
+	storeRowsForProcessUpdateTaskTest(s, allTenantIDs, now)
+	check(allTenantIDs, "* | count() rows", []string{`{"rows":"7000"}`})
+
+	// Count logs to update in dry-run mode
+	checkRowsUpdated(allTenantIDs, "row_id:=42", "delete tenant_id", true, 70)
+	check(allTenantIDs, "row_id:=42 tenant_id:* | count() rows", []string{`{"rows":"70"}`})
+
+	// Drop the field from the matching logs
+	checkRowsUpdated(allTenantIDs, "row_id:=42", "delete tenant_id", false, 70)
+	check(allTenantIDs, "row_id:=42 tenant_id:* | count() rows", []string{`{"rows":"0"}`})
+	check(allTenantIDs, "tenant_id:* | count() rows", []string{`{"rows":"6930"}`})
+	check(allTenantIDs, "* | count() rows", []string{`{"rows":"7000"}`})
+
+	// Redact the message at the particular tenant and stream
+	tenantIDs := []TenantID{
+		allTenantIDs[0],
+	}
+	filter := `{host="host-3"} row_id:=10 _time:1d`
+	update := `replace_regexp ("tenantID=[^ ]+", "tenantID=***")`
+	checkRowsUpdated(tenantIDs, filter, update, false, 1)
+	check(allTenantIDs, filter+" | fields _msg, row_id, app | sort by (_msg)", []string{
+		`{"_msg":"value #10 at the day 0 for the tenantID=*** and streamID=3","row_id":"10","app":"app-203"}`,
+		`{"_msg":"value #10 at the day 0 for the tenantID={accountID=123,projectID=0} and streamID=3","row_id":"10","app":"app-203"}`,
+	})
+	check(allTenantIDs, `"tenantID=***" | count() rows`, []string{`{"rows":"1"}`})
+	check(allTenantIDs, "* | count() rows", []string{`{"rows":"7000"}`})
+
+	// Set the field value for all the logs at the particular tenant
+	tenantIDs = []TenantID{
+		allTenantIDs[1],
+	}
+	checkRowsUpdated(tenantIDs, "*", `format "redacted" as row_id`, false, 3500)
+	check(tenantIDs, "row_id:=redacted | count() rows", []string{`{"rows":"3500"}`})
+	check(allTenantIDs, "row_id:=redacted | count() rows", []string{`{"rows":"3500"}`})
+	check(allTenantIDs, "* | count() rows", []string{`{"rows":"7000"}`})
+
+	// Non-idempotent update mustn't be applied again to the already processed partitions when the task is retried
+	tenantIDs = []TenantID{
+		allTenantIDs[0],
+	}
+	filter = `{host="host-4"} row_id:=20`
+	check(tenantIDs, filter+" | count() rows", []string{`{"rows":"7"}`})
+	dt := newDeleteTask("task_id_y", tenantIDs, filter, `format "<_msg>!" as _msg`, now, false)
+	processUpdateTask(dt)
+	processUpdateTask(dt)
+	if n := dt.progress.rowsUpdated.Load(); n != 7 {
+		t.Fatalf("unexpected number of updated rows after the retry; got %d; want 7", n)
+	}
+	check(tenantIDs, filter+` _msg:~"[^!]!$" | count() rows`, []string{`{"rows":"7"}`})
+	check(tenantIDs, filter+` _msg:~"!!$" | count() rows`, []string{`{"rows":"0"}`})
+
+	// Verify that the updated logs survive storage restart
+	s.MustClose()
+	s = MustOpenStorage(path, cfg)
+	check(allTenantIDs, "row_id:=redacted | count() rows", []string{`{"rows":"3500"}`})
+	check(allTenantIDs, "tenant_id:* | count() rows", []string{`{"rows":"6930"}`})
+
+	s.MustClose()
+
+	fs.MustRemoveDir(path)
+}
+
 func checkQueryResults(t *testing.T, s *Storage, tenantIDs []TenantID, qStr string, resultsExpected []string) {
 	t.Helper()
 
@@ -333,6 +734,60 @@ func checkQueryResults(t *testing.T, s *Storage, tenantIDs []TenantID, qStr stri
 	}
 }
 
+func storeRowsForProcessUpdateTaskTest(s *Storage, tenantIDs []TenantID, now int64) {
+	streamTags := []string{
+		"host",
+		"app",
+	}
+
+	lr := GetLogRows(streamTags, nil, nil, nil, "")
+
+	const days = 7
+	const streamsPerTenant = 5
+	const rowsPerDayPerStream = 100
+
+	for _, tenantID := range tenantIDs {
+		for dayID := int64(0); dayID < days; dayID++ {
+			for streamID := 0; streamID < streamsPerTenant; streamID++ {
+				for rowID := 0; rowID < rowsPerDayPerStream; rowID++ {
+					fields := []Field{
+						{
+							Name:  "host",
+							Value: fmt.Sprintf("host-%d", streamID),
+						},
+						{
+							Name:  "app",
+							Value: fmt.Sprintf("app-%d", 200+streamID),
+						},
+						{
+							Name:  "_msg",
+							Value: fmt.Sprintf("value #%d at the day %d for the tenantID=%s and streamID=%d", rowID, dayID, tenantID, streamID),
+						},
+						{
+							Name:  "row_id",
+							Value: fmt.Sprintf("%d", rowID),
+						},
+						{
+							Name:  "tenant_id",
+							Value: tenantID.String(),
+						},
+					}
+					timestamp := now - dayID*nsecsPerDay
+					lr.MustAdd(tenantID, timestamp, fields, nil)
+					if lr.NeedFlush() {
+						s.MustAddRows(lr)
+						lr.ResetKeepSettings()
+					}
+				}
+			}
+		}
+	}
+	s.MustAddRows(lr)
+	PutLogRows(lr)
+
+	s.DebugFlush()
+}
+
 func storeRowsForProcessDeleteTaskTest(s *Storage, tenantIDs []TenantID, now int64) {
 	// Generate rows and put them in the storage
 
@@ -0,0 +1,570 @@
+package logstorage
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/cgroup"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/prefixfilter"
+)
+
+// StreamCardinality contains log streams' stats returned by Storage.GetStreamCardinality.
+type StreamCardinality struct {
+	// Partitions contains partitions' stats sorted by partition name.
+	Partitions []*PartitionStreamCardinality `json:"partitions"`
+}
+
+// PartitionStreamCardinality contains log streams' stats for a single partition.
+type PartitionStreamCardinality struct {
+	// Partition is the partition name. See Storage.PartitionAttach for the supported partition name formats.
+	Partition string `json:"partition"`
+
+	// Streams is the number of log streams in the partition.
+	Streams uint64 `json:"streams"`
+
+	// NewStreams is the number of log streams in the partition, which are missing in the previous partition.
+	NewStreams uint64 `json:"new_streams"`
+
+	// StreamFieldNames contains stream field names with the biggest number of streams.
+	StreamFieldNames []StreamFieldNameCardinality `json:"stream_field_names"`
+
+	// StreamFieldValues contains stream field values with the biggest number of streams.
+	StreamFieldValues []StreamFieldValueCardinality `json:"stream_field_values"`
+
+	// TopStreamsByRows contains log streams with the biggest number of rows.
+	TopStreamsByRows []StreamUsage `json:"top_streams_by_rows"`
+
+	// TopStreamsByBytes contains log streams with the biggest uncompressed size of rows.
+	TopStreamsByBytes []StreamUsage `json:"top_streams_by_bytes"`
+}
+
+// StreamFieldNameCardinality contains stats for a single stream field name.
+type StreamFieldNameCardinality struct {
+	// Name is the stream field name.
+	Name string `json:"name"`
+
+	// Streams is the number of log streams with the given stream field.
+	Streams uint64 `json:"streams"`
+
+	// Values is the number of unique values for the given stream field.
+	Values uint64 `json:"values"`
+}
+
+// StreamFieldValueCardinality contains stats for a single stream field value.
+type StreamFieldValueCardinality struct {
+	// Name is the stream field name.
+	Name string `json:"name"`
+
+	// Value is the stream field value.
+	Value string `json:"value"`
+
+	// Streams is the number of log streams with the given Name=Value stream field.
+	Streams uint64 `json:"streams"`
+}
+
+// StreamUsage contains the number of rows and the uncompressed size of rows for a single log stream.
+type StreamUsage struct {
+	// Stream is the _stream value for the log stream.
+	Stream string `json:"stream"`
+
+	// Rows is the number of rows in the log stream.
+	Rows uint64 `json:"rows"`
+
+	// Bytes is the uncompressed size of rows in the log stream.
+	Bytes uint64 `json:"bytes"`
+
+	// sid is the streamID for the log stream. It is used for obtaining Stream for the top log streams.
+	sid streamID
+}
+
+// HideStreamFields removes stream fields matching fieldFilters from sc.
+func (sc *StreamCardinality) HideStreamFields(fieldFilters []string) {
+	if len(fieldFilters) == 0 {
+		return
+	}
+	for _, psc := range sc.Partitions {
+		psc.StreamFieldNames = slices.DeleteFunc(psc.StreamFieldNames, func(c StreamFieldNameCardinality) bool {
+			return prefixfilter.MatchFilters(fieldFilters, c.Name)
+		})
+		psc.StreamFieldValues = slices.DeleteFunc(psc.StreamFieldValues, func(c StreamFieldValueCardinality) bool {
+			return prefixfilter.MatchFilters(fieldFilters, c.Name)
+		})
+		for i := range psc.TopStreamsByRows {
+			su := &psc.TopStreamsByRows[i]
+			su.Stream = hideStreamFieldsInStreamName(su.Stream, fieldFilters)
+		}
+		for i := range psc.TopStreamsByBytes {
+			su := &psc.TopStreamsByBytes[i]
+			su.Stream = hideStreamFieldsInStreamName(su.Stream, fieldFilters)
+		}
+	}
+}
+
+// GetStreamCardinality returns log streams' stats for the given tenantIDs at partitions overlapping the given [start, end] time range.
+//
+// The stats is calculated from indexdb and from block headers, so it doesn't need reading the stored logs.
+// Up to limit entries with the biggest values are returned per every list in the partition stats. All the entries are returned if limit <= 0.
+func (s *Storage) GetStreamCardinality(ctx context.Context, tenantIDs []TenantID, start, end int64, limit int) (*StreamCardinality, error) {
+	workersCount := cgroup.AvailableCPUs()
+	stopCh := ctx.Done()
+
+	// Select partitions according to the selected time range.
+	// Also select the partitions just before the selected partitions, since they are needed for calculating new streams.
+	s.partitionsLock.Lock()
+	ptws := s.getPartitionsForTimeRangeLocked(start, end)
+	if len(ptws) > 0 {
+		ptws = s.getPartitionsForTimeRangeLocked(ptws[0].minTimestamp-1, end)
+	}
+	for _, ptw := range ptws {
+		ptw.incRef()
+	}
+	s.partitionsLock.Unlock()
+
+	defer func() {
+		for _, ptw := range ptws {
+			ptw.decRef()
+		}
+	}()
+
+	results := make([]*PartitionStreamCardinality, len(ptws))
+
+	// spin up workers
+	var wg sync.WaitGroup
+	workCh := make(chan int, workersCount)
+	for i := 0; i < workersCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range workCh {
+				if needStop(stopCh) {
+					// The search has been canceled. Just skip all the scheduled work in order to save CPU time.
+					continue
+				}
+				ptPrev := getPrevPartition(ptws, idx)
+				results[idx] = ptws[idx].pt.getStreamCardinality(ptPrev, tenantIDs, limit, stopCh)
+			}
+		}()
+	}
+
+	// Schedule concurrent work across the selected partitions.
+	for idx, ptw := range ptws {
+		if !ptw.overlapsTimeRange(start, end) {
+			// Skip the previous partition.
+			continue
+		}
+		workCh <- idx
+	}
+
+	// Wait until workers finish their work
+	close(workCh)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sc := &StreamCardinality{
+		Partitions: []*PartitionStreamCardinality{},
+	}
+	for _, psc := range results {
+		if psc != nil && psc.Streams > 0 {
+			sc.Partitions = append(sc.Partitions, psc)
+		}
+	}
+	return sc, nil
+}
+
+// getPrevPartition returns the partition from ptws, which ends right before the start of ptws[idx].
+//
+// nil is returned if there is no such partition.
+func getPrevPartition(ptws []*partitionWrapper, idx int) *partition {
+	minTimestamp := ptws[idx].minTimestamp
+	for i := idx - 1; i >= 0; i-- {
+		if ptws[i].maxTimestamp == minTimestamp-1 {
+			return ptws[i].pt
+		}
+	}
+	return nil
+}
+
+func (pt *partition) getStreamCardinality(ptPrev *partition, tenantIDs []TenantID, limit int, stopCh <-chan struct{}) *PartitionStreamCardinality {
+	psc := &PartitionStreamCardinality{
+		Partition: pt.name,
+	}
+
+	// Collect stats from indexdb.
+	is := pt.idb.getIndexSearch()
+	defer pt.idb.putIndexSearch(is)
+
+	var isPrev *indexSearch
+	if ptPrev != nil {
+		isPrev = ptPrev.idb.getIndexSearch()
+		defer ptPrev.idb.putIndexSearch(isPrev)
+	}
+
+	fieldsStats := make(map[string]*streamFieldStats)
+	for _, tenantID := range tenantIDs {
+		if needStop(stopCh) {
+			return nil
+		}
+
+		ids := is.getStreamIDsForTenant(tenantID)
+		psc.Streams += uint64(len(ids))
+
+		if isPrev != nil {
+			idsPrev := isPrev.getStreamIDsForTenant(tenantID)
+			for id := range ids {
+				if _, ok := idsPrev[id]; !ok {
+					psc.NewStreams++
+				}
+			}
+		} else {
+			psc.NewStreams += uint64(len(ids))
+		}
+
+		is.updateStreamFieldStats(fieldsStats, tenantID)
+	}
+
+	for name, fs := range fieldsStats {
+		psc.StreamFieldNames = append(psc.StreamFieldNames, StreamFieldNameCardinality{
+			Name:    name,
+			Streams: fs.streams,
+			Values:  uint64(len(fs.values)),
+		})
+		for value, streams := range fs.values {
+			psc.StreamFieldValues = append(psc.StreamFieldValues, StreamFieldValueCardinality{
+				Name:    name,
+				Value:   value,
+				Streams: streams,
+			})
+		}
+	}
+
+	// Collect stats from block headers.
+	m := pt.getStreamUsages(tenantIDs, stopCh)
+	for _, su := range m {
+		psc.TopStreamsByRows = append(psc.TopStreamsByRows, *su)
+	}
+	psc.TopStreamsByBytes = append([]StreamUsage{}, psc.TopStreamsByRows...)
+
+	psc.sortAndLimit(limit)
+
+	// Convert streamIDs to _stream values only for the selected top streams, since this may be slow.
+	var buf []byte
+	setStreams := func(sus []StreamUsage) {
+		for i := range sus {
+			su := &sus[i]
+			buf = pt.idb.appendStreamString(buf[:0], &su.sid)
+			su.Stream = string(buf)
+		}
+	}
+	setStreams(psc.TopStreamsByRows)
+	setStreams(psc.TopStreamsByBytes)
+
+	return psc
+}
+
+func (pt *partition) getStreamUsages(tenantIDs []TenantID, stopCh <-chan struct{}) map[streamID]*StreamUsage {
+	m := make(map[streamID]*StreamUsage)
+	if len(tenantIDs) == 0 {
+		return m
+	}
+
+	minTenantID := &tenantIDs[0]
+	maxTenantID := &tenantIDs[0]
+	tenantIDsMap := make(map[TenantID]struct{}, len(tenantIDs))
+	for i := range tenantIDs {
+		tenantID := &tenantIDs[i]
+		if tenantID.less(minTenantID) {
+			minTenantID = tenantID
+		}
+		if maxTenantID.less(tenantID) {
+			maxTenantID = tenantID
+		}
+		tenantIDsMap[*tenantID] = struct{}{}
+	}
+
+	pws, pwsDecRef := pt.ddb.getPartsForTimeRange(math.MinInt64, math.MaxInt64)
+	defer pwsDecRef()
+
+	var qs QueryStats
+	bhss := getBlockHeaders()
+	defer putBlockHeaders(bhss)
+
+	for _, pw := range pws {
+		p := pw.p
+		ibhs := p.indexBlockHeaders
+		for i := range ibhs {
+			if needStop(stopCh) {
+				return m
+			}
+
+			ibh := &ibhs[i]
+			if maxTenantID.less(&ibh.streamID.tenantID) {
+				// The remaining index blocks contain only tenants bigger than the requested tenants.
+				break
+			}
+			if i+1 < len(ibhs) && ibhs[i+1].streamID.tenantID.less(minTenantID) {
+				// The next index block starts with tenant smaller than the requested tenants,
+				// so the current index block cannot contain the requested tenants.
+				continue
+			}
+
+			bhss.bhs = ibh.mustReadBlockHeaders(bhss.bhs[:0], p, &qs)
+			for j := range bhss.bhs {
+				bh := &bhss.bhs[j]
+				if _, ok := tenantIDsMap[bh.streamID.tenantID]; !ok {
+					continue
+				}
+				su := m[bh.streamID]
+				if su == nil {
+					su = &StreamUsage{
+						sid: bh.streamID,
+					}
+					m[bh.streamID] = su
+				}
+				su.Rows += bh.rowsCount
+				su.Bytes += bh.uncompressedSizeBytes
+			}
+		}
+	}
+
+	return m
+}
+
+type streamFieldStats struct {
+	// streams is the number of streams with the given field.
+	streams uint64
+
+	// values contains the number of streams per every field value.
+	values map[string]uint64
+}
+
+// updateStreamFieldStats updates dst with stats from (tenantID:name:value -> streamIDs) entries for the given tenantID.
+func (is *indexSearch) updateStreamFieldStats(dst map[string]*streamFieldStats, tenantID TenantID) {
+	var sp tagToStreamIDsRowParser
+	var tagPrev streamTag
+	ids := make(map[u128]struct{})
+
+	flush := func() {
+		if len(ids) == 0 {
+			return
+		}
+		fs := dst[string(tagPrev.Name)]
+		if fs == nil {
+			fs = &streamFieldStats{
+				values: make(map[string]uint64),
+			}
+			dst[string(tagPrev.Name)] = fs
+		}
+		n := uint64(len(ids))
+		fs.streams += n
+		fs.values[string(tagPrev.Value)] += n
+		clear(ids)
+	}
+
+	ts := &is.ts
+	kb := &is.kb
+	kb.B = marshalCommonPrefix(kb.B[:0], nsPrefixTagToStreamIDs, tenantID)
+	prefix := kb.B
+	ts.Seek(prefix)
+	for ts.NextItem() {
+		item := ts.Item
+		if !bytes.HasPrefix(item, prefix) {
+			break
+		}
+		sp.Reset()
+		if err := sp.Init(item); err != nil {
+			logger.Panicf("FATAL: cannot parse (tenantID:name:value -> streamIDs) entry: %s", err)
+		}
+		if !sp.Tag.equal(&tagPrev) {
+			// The (tenantID:name:value -> streamIDs) entries for the same name:value may be split into multiple items,
+			// which may contain duplicate streamIDs before the merge. So count unique streamIDs per every name:value.
+			flush()
+			tagPrev.Name = append(tagPrev.Name[:0], sp.Tag.Name...)
+			tagPrev.Value = append(tagPrev.Value[:0], sp.Tag.Value...)
+		}
+		sp.ParseStreamIDs()
+		for _, id := range sp.StreamIDs {
+			ids[id] = struct{}{}
+		}
+	}
+	if err := ts.Error(); err != nil {
+		logger.Panicf("FATAL: unexpected error: %s", err)
+	}
+	flush()
+}
+
+// MergeStreamCardinality merges scs obtained from multiple storage nodes.
+//
+// Up to limit entries with the biggest values are left per every list in the partition stats. All the entries are left if limit <= 0.
+func MergeStreamCardinality(scs []*StreamCardinality, limit int) *StreamCardinality {
+	m := make(map[string]*PartitionStreamCardinality)
+	for _, sc := range scs {
+		if sc == nil {
+			continue
+		}
+		for _, psc := range sc.Partitions {
+			dst := m[psc.Partition]
+			if dst == nil {
+				dst = &PartitionStreamCardinality{
+					Partition: psc.Partition,
+				}
+				m[psc.Partition] = dst
+			}
+			dst.Streams += psc.Streams
+			dst.NewStreams += psc.NewStreams
+			dst.StreamFieldNames = append(dst.StreamFieldNames, psc.StreamFieldNames...)
+			dst.StreamFieldValues = append(dst.StreamFieldValues, psc.StreamFieldValues...)
+			dst.TopStreamsByRows = append(dst.TopStreamsByRows, psc.TopStreamsByRows...)
+			dst.TopStreamsByBytes = append(dst.TopStreamsByBytes, psc.TopStreamsByBytes...)
+		}
+	}
+
+	result := &StreamCardinality{
+		Partitions: make([]*PartitionStreamCardinality, 0, len(m)),
+	}
+	for _, psc := range m {
+		psc.StreamFieldNames = mergeStreamFieldNameCardinality(psc.StreamFieldNames)
+		psc.StreamFieldValues = mergeStreamFieldValueCardinality(psc.StreamFieldValues)
+		psc.TopStreamsByRows = mergeStreamUsages(psc.TopStreamsByRows)
+		psc.TopStreamsByBytes = mergeStreamUsages(psc.TopStreamsByBytes)
+		psc.sortAndLimit(limit)
+		result.Partitions = append(result.Partitions, psc)
+	}
+	sort.Slice(result.Partitions, func(i, j int) bool {
+		return result.Partitions[i].Partition < result.Partitions[j].Partition
+	})
+	return result
+}
+
+func mergeStreamFieldNameCardinality(a []StreamFieldNameCardinality) []StreamFieldNameCardinality {
+	m := make(map[string]*StreamFieldNameCardinality)
+	var result []StreamFieldNameCardinality
+	for _, x := range a {
+		if dst := m[x.Name]; dst != nil {
+			dst.Streams += x.Streams
+			dst.Values += x.Values
+			continue
+		}
+		xCopy := x
+		m[x.Name] = &xCopy
+	}
+	for _, x := range m {
+		result = append(result, *x)
+	}
+	return result
+}
+
+func mergeStreamFieldValueCardinality(a []StreamFieldValueCardinality) []StreamFieldValueCardinality {
+	type key struct {
+		name  string
+		value string
+	}
+	m := make(map[key]uint64)
+	for _, x := range a {
+		m[key{x.Name, x.Value}] += x.Streams
+	}
+	var result []StreamFieldValueCardinality
+	for k, streams := range m {
+		result = append(result, StreamFieldValueCardinality{
+			Name:    k.name,
+			Value:   k.value,
+			Streams: streams,
+		})
+	}
+	return result
+}
+
+func mergeStreamUsages(a []StreamUsage) []StreamUsage {
+	m := make(map[string]*StreamUsage)
+	for _, x := range a {
+		if dst := m[x.Stream]; dst != nil {
+			dst.Rows += x.Rows
+			dst.Bytes += x.Bytes
+			continue
+		}
+		xCopy := x
+		m[x.Stream] = &xCopy
+	}
+	var result []StreamUsage
+	for _, x := range m {
+		result = append(result, *x)
+	}
+	return result
+}
+
+// sortAndLimit sorts psc lists by the number of streams, rows and bytes in descending order and leaves up to limit top entries in every list.
+func (psc *PartitionStreamCardinality) sortAndLimit(limit int) {
+	sort.Slice(psc.StreamFieldNames, func(i, j int) bool {
+		a, b := &psc.StreamFieldNames[i], &psc.StreamFieldNames[j]
+		if a.Streams != b.Streams {
+			return a.Streams > b.Streams
+		}
+		return a.Name < b.Name
+	})
+	psc.StreamFieldNames = limitSlice(psc.StreamFieldNames, limit)
+
+	sort.Slice(psc.StreamFieldValues, func(i, j int) bool {
+		a, b := &psc.StreamFieldValues[i], &psc.StreamFieldValues[j]
+		if a.Streams != b.Streams {
+			return a.Streams > b.Streams
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Value < b.Value
+	})
+	psc.StreamFieldValues = limitSlice(psc.StreamFieldValues, limit)
+
+	sort.Slice(psc.TopStreamsByRows, func(i, j int) bool {
+		a, b := &psc.TopStreamsByRows[i], &psc.TopStreamsByRows[j]
+		if a.Rows != b.Rows {
+			return a.Rows > b.Rows
+		}
+		if a.Stream != b.Stream {
+			return a.Stream < b.Stream
+		}
+		return a.sid.less(&b.sid)
+	})
+	psc.TopStreamsByRows = limitSlice(psc.TopStreamsByRows, limit)
+
+	sort.Slice(psc.TopStreamsByBytes, func(i, j int) bool {
+		a, b := &psc.TopStreamsByBytes[i], &psc.TopStreamsByBytes[j]
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		if a.Stream != b.Stream {
+			return a.Stream < b.Stream
+		}
+		return a.sid.less(&b.sid)
+	})
+	psc.TopStreamsByBytes = limitSlice(psc.TopStreamsByBytes, limit)
+
+	// Make sure the lists are marshaled to JSON as empty arrays instead of null.
+	if psc.StreamFieldNames == nil {
+		psc.StreamFieldNames = []StreamFieldNameCardinality{}
+	}
+	if psc.StreamFieldValues == nil {
+		psc.StreamFieldValues = []StreamFieldValueCardinality{}
+	}
+	if psc.TopStreamsByRows == nil {
+		psc.TopStreamsByRows = []StreamUsage{}
+	}
+	if psc.TopStreamsByBytes == nil {
+		psc.TopStreamsByBytes = []StreamUsage{}
+	}
+}
+
+func limitSlice[T any](a []T, limit int) []T {
+	if limit > 0 && len(a) > limit {
+		return a[:limit]
+	}
+	return a
+}
@@ -0,0 +1,226 @@
+package logstorage
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+)
+
+func TestStorageGetStreamCardinality(t *testing.T) {
+	t.Parallel()
+
+	path := t.Name()
+	ctx := t.Context()
+
+	cfg := &StorageConfig{
+		Retention: 30 * 24 * time.Hour,
+	}
+	s := MustOpenStorage(path, cfg)
+
+	tenantID := TenantID{
+		AccountID: 1,
+		ProjectID: 2,
+	}
+	otherTenantID := TenantID{
+		AccountID: 3,
+		ProjectID: 4,
+	}
+
+	// Use the middle of the current day, so the ingested logs do not cross day boundaries.
+	day := time.Now().UnixNano() / nsecsPerDay
+	now := day*nsecsPerDay + nsecsPerDay/2
+
+	lr := GetLogRows([]string{"host", "app"}, nil, nil, nil, "")
+	addRows := func(tenantID TenantID, timestamp int64, host, app string, rowsCount int) {
+		for i := 0; i < rowsCount; i++ {
+			fields := []Field{
+				{
+					Name:  "host",
+					Value: host,
+				},
+				{
+					Name:  "app",
+					Value: app,
+				},
+				{
+					Name:  "_msg",
+					Value: "some message",
+				},
+			}
+			lr.MustAdd(tenantID, timestamp+int64(i), fields, nil)
+		}
+	}
+
+	// The previous day contains host-0 and host-1 streams.
+	addRows(tenantID, now-nsecsPerDay, "host-0", "nginx", 1)
+	addRows(tenantID, now-nsecsPerDay, "host-1", "nginx", 1)
+
+	// The current day contains host-0, host-1 and host-2 streams plus a stream for another app.
+	addRows(tenantID, now, "host-0", "nginx", 5)
+	addRows(tenantID, now, "host-1", "nginx", 3)
+	addRows(tenantID, now, "host-2", "nginx", 1)
+	addRows(tenantID, now, "host-2", "redis", 2)
+
+	// Streams for other tenants must be ignored.
+	addRows(otherTenantID, now, "host-3", "nginx", 10)
+
+	s.MustAddRows(lr)
+	PutLogRows(lr)
+	s.DebugFlush()
+
+	sc, err := s.GetStreamCardinality(ctx, []TenantID{tenantID}, now, now, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(sc.Partitions) != 1 {
+		t.Fatalf("unexpected number of partitions; got %d; want 1", len(sc.Partitions))
+	}
+	psc := sc.Partitions[0]
+
+	if psc.Partition != getPartitionName(day*nsecsPerDay, nsecsPerDay) {
+		t.Fatalf("unexpected partition; got %q; want %q", psc.Partition, getPartitionName(day*nsecsPerDay, nsecsPerDay))
+	}
+	if psc.Streams != 4 {
+		t.Fatalf("unexpected number of streams; got %d; want 4", psc.Streams)
+	}
+	if psc.NewStreams != 2 {
+		t.Fatalf("unexpected number of new streams; got %d; want 2", psc.NewStreams)
+	}
+
+	f := func(v any, resultExpected string) {
+		t.Helper()
+
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("cannot marshal %v: %s", v, err)
+		}
+		if string(data) != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", data, resultExpected)
+		}
+	}
+
+	f(psc.StreamFieldNames, `[{"name":"app","streams":4,"values":2},{"name":"host","streams":4,"values":3}]`)
+	f(psc.StreamFieldValues, `[{"name":"app","value":"nginx","streams":3},{"name":"host","value":"host-2","streams":2}]`)
+
+	if len(psc.TopStreamsByRows) != 2 {
+		t.Fatalf("unexpected number of top streams by rows; got %d; want 2", len(psc.TopStreamsByRows))
+	}
+	bytesPerRow := psc.TopStreamsByRows[0].Bytes / psc.TopStreamsByRows[0].Rows
+	f(psc.TopStreamsByRows, fmt.Sprintf(`[{"stream":"{app=\"nginx\",host=\"host-0\"}","rows":5,"bytes":%d},`+
+		`{"stream":"{app=\"nginx\",host=\"host-1\"}","rows":3,"bytes":%d}]`, 5*bytesPerRow, 3*bytesPerRow))
+	f(psc.TopStreamsByBytes, fmt.Sprintf(`[{"stream":"{app=\"nginx\",host=\"host-0\"}","rows":5,"bytes":%d},`+
+		`{"stream":"{app=\"nginx\",host=\"host-1\"}","rows":3,"bytes":%d}]`, 5*bytesPerRow, 3*bytesPerRow))
+
+	// Both days are selected. All the streams at the previous day are new, since there are no logs for the day before it.
+	sc, err = s.GetStreamCardinality(ctx, []TenantID{tenantID}, now-nsecsPerDay, now, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(sc.Partitions) != 2 {
+		t.Fatalf("unexpected number of partitions; got %d; want 2", len(sc.Partitions))
+	}
+	psc = sc.Partitions[0]
+	if psc.Partition != getPartitionName((day-1)*nsecsPerDay, nsecsPerDay) {
+		t.Fatalf("unexpected partition; got %q; want %q", psc.Partition, getPartitionName((day-1)*nsecsPerDay, nsecsPerDay))
+	}
+	if psc.Streams != 2 || psc.NewStreams != 2 {
+		t.Fatalf("unexpected streams for the previous day; got streams=%d, new_streams=%d; want streams=2, new_streams=2", psc.Streams, psc.NewStreams)
+	}
+	if len(sc.Partitions[1].TopStreamsByRows) != 4 {
+		t.Fatalf("unexpected number of top streams without limit; got %d; want 4", len(sc.Partitions[1].TopStreamsByRows))
+	}
+
+	// Missing tenant
+	sc, err = s.GetStreamCardinality(ctx, []TenantID{{AccountID: 123}}, now-nsecsPerDay, now, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	f(sc, `{"partitions":[]}`)
+
+	s.MustClose()
+
+	fs.MustRemoveDir(path)
+}
+
+func TestMergeStreamCardinality(t *testing.T) {
+	f := func(scsJSON []string, limit int, resultExpected string) {
+		t.Helper()
+
+		var scs []*StreamCardinality
+		for _, data := range scsJSON {
+			var sc StreamCardinality
+			if err := json.Unmarshal([]byte(data), &sc); err != nil {
+				t.Fatalf("cannot unmarshal %s: %s", data, err)
+			}
+			scs = append(scs, &sc)
+		}
+		result, err := json.Marshal(MergeStreamCardinality(scs, limit))
+		if err != nil {
+			t.Fatalf("cannot marshal result: %s", err)
+		}
+		if string(result) != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	f(nil, 10, `{"partitions":[]}`)
+
+	f([]string{
+		`{"partitions":[{"partition":"20250102","streams":3,"new_streams":1,` +
+			`"stream_field_names":[{"name":"host","streams":3,"values":3}],` +
+			`"stream_field_values":[{"name":"host","value":"a","streams":2},{"name":"host","value":"b","streams":1}],` +
+			`"top_streams_by_rows":[{"stream":"{host=\"a\"}","rows":10,"bytes":100}],` +
+			`"top_streams_by_bytes":[{"stream":"{host=\"a\"}","rows":10,"bytes":100}]}]}`,
+		`{"partitions":[{"partition":"20250102","streams":2,"new_streams":2,` +
+			`"stream_field_names":[{"name":"host","streams":2,"values":2},{"name":"app","streams":1,"values":1}],` +
+			`"stream_field_values":[{"name":"host","value":"b","streams":2}],` +
+			`"top_streams_by_rows":[{"stream":"{host=\"b\"}","rows":20,"bytes":50},{"stream":"{host=\"a\"}","rows":5,"bytes":60}],` +
+			`"top_streams_by_bytes":[{"stream":"{host=\"a\"}","rows":5,"bytes":60}]},` +
+			`{"partition":"20250101","streams":1,"new_streams":1}]}`,
+	}, 1, `{"partitions":[`+
+		`{"partition":"20250101","streams":1,"new_streams":1,"stream_field_names":[],"stream_field_values":[],"top_streams_by_rows":[],"top_streams_by_bytes":[]},`+
+		`{"partition":"20250102","streams":5,"new_streams":3,`+
+		`"stream_field_names":[{"name":"host","streams":5,"values":5}],`+
+		`"stream_field_values":[{"name":"host","value":"b","streams":3}],`+
+		`"top_streams_by_rows":[{"stream":"{host=\"b\"}","rows":20,"bytes":50}],`+
+		`"top_streams_by_bytes":[{"stream":"{host=\"a\"}","rows":15,"bytes":160}]}]}`)
+}
+
+func TestStreamCardinalityHideStreamFields(t *testing.T) {
+	f := func(scJSON string, fieldFilters []string, resultExpected string) {
+		t.Helper()
+
+		var sc StreamCardinality
+		if err := json.Unmarshal([]byte(scJSON), &sc); err != nil {
+			t.Fatalf("cannot unmarshal %s: %s", scJSON, err)
+		}
+		sc.HideStreamFields(fieldFilters)
+		result, err := json.Marshal(&sc)
+		if err != nil {
+			t.Fatalf("cannot marshal result: %s", err)
+		}
+		if string(result) != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	scJSON := `{"partitions":[{"partition":"20250101","streams":2,"new_streams":1,` +
+		`"stream_field_names":[{"name":"app","streams":2,"values":1},{"name":"user.email","streams":2,"values":2}],` +
+		`"stream_field_values":[{"name":"app","value":"x","streams":2},{"name":"user.email","value":"a@b","streams":1}],` +
+		`"top_streams_by_rows":[{"stream":"{app=\"x\",user.email=\"a@b\"}","rows":10,"bytes":100}],` +
+		`"top_streams_by_bytes":[{"stream":"{user.email=\"a@b\"}","rows":10,"bytes":100}]}]}`
+
+	// nothing to hide
+	f(scJSON, nil, scJSON)
+	f(scJSON, []string{"host"}, scJSON)
+
+	// hide the stream field
+	f(scJSON, []string{"user.*"}, `{"partitions":[{"partition":"20250101","streams":2,"new_streams":1,`+
+		`"stream_field_names":[{"name":"app","streams":2,"values":1}],`+
+		`"stream_field_values":[{"name":"app","value":"x","streams":2}],`+
+		`"top_streams_by_rows":[{"stream":"{app=\"x\"}","rows":10,"bytes":100}],`+
+		`"top_streams_by_bytes":[{"stream":"{}","rows":10,"bytes":100}]}]}`)
+}
@@ -11,6 +11,8 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/regexutil"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/prefixfilter"
 )
 
 // StreamFilter is a filter for streams, e.g. `_stream:{...}`
@@ -41,6 +43,18 @@ func (sf *StreamFilter) matchStreamName(s string) bool {
 	return false
 }
 
+// hasTagFilters returns true if sf contains filters on tags matching the given fieldFilters.
+func (sf *StreamFilter) hasTagFilters(fieldFilters []string) bool {
+	for _, af := range sf.orFilters {
+		for _, tf := range af.tagFilters {
+			if prefixfilter.MatchFilters(fieldFilters, tf.tagName) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (sf *StreamFilter) isEmpty() bool {
 	for _, af := range sf.orFilters {
 		if len(af.tagFilters) > 0 {
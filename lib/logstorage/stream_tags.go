@@ -99,6 +99,20 @@ func (st *StreamTags) Add(name, value string) {
 	})
 }
 
+// AppendFields appends (name:value) tags from st to dst as fields and returns the result.
+//
+// The returned fields are valid until st is modified.
+func (st *StreamTags) AppendFields(dst []Field) []Field {
+	for i := range st.tags {
+		tag := &st.tags[i]
+		dst = append(dst, Field{
+			Name:  bytesutil.ToUnsafeString(tag.Name),
+			Value: bytesutil.ToUnsafeString(tag.Value),
+		})
+	}
+	return dst
+}
+
 // MarshalCanonical marshal st in a canonical way
 func (st *StreamTags) MarshalCanonical(dst []byte) []byte {
 	sort.Sort(st)
@@ -0,0 +1,274 @@
+package logstorage
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/cgroup"
+)
+
+// TenantsUsage contains per-tenant storage usage returned by Storage.GetTenantsUsage.
+type TenantsUsage struct {
+	// Tenants contains usage for tenants with stored rows sorted by tenant id.
+	Tenants []*TenantUsage `json:"tenants"`
+}
+
+// TenantUsage contains storage usage for a single tenant.
+type TenantUsage struct {
+	// TenantID is the tenant id.
+	TenantID TenantID `json:"tenant_id"`
+
+	// Rows is the number of rows stored for the tenant across all the partitions.
+	Rows uint64 `json:"rows"`
+
+	// CompressedBytes is the estimated on-disk size of rows stored for the tenant across all the partitions.
+	CompressedBytes uint64 `json:"compressed_bytes"`
+
+	// UncompressedBytes is the uncompressed size of rows stored for the tenant across all the partitions.
+	UncompressedBytes uint64 `json:"uncompressed_bytes"`
+
+	// MinTime is the timestamp of the oldest row stored for the tenant.
+	MinTime time.Time `json:"min_time"`
+
+	// MaxTime is the timestamp of the newest row stored for the tenant.
+	MaxTime time.Time `json:"max_time"`
+
+	// Partitions contains partitions' usage for the tenant sorted by partition name.
+	Partitions []*PartitionTenantUsage `json:"partitions"`
+}
+
+// PartitionTenantUsage contains storage usage for a single tenant at a single partition.
+type PartitionTenantUsage struct {
+	// Partition is the partition name. See Storage.PartitionAttach for the supported partition name formats.
+	Partition string `json:"partition"`
+
+	// Rows is the number of rows stored for the tenant in the partition.
+	Rows uint64 `json:"rows"`
+
+	// CompressedBytes is the estimated on-disk size of rows stored for the tenant in the partition.
+	//
+	// It is calculated by splitting the on-disk size of every part among its blocks proportionally to their uncompressed size.
+	CompressedBytes uint64 `json:"compressed_bytes"`
+
+	// UncompressedBytes is the uncompressed size of rows stored for the tenant in the partition.
+	UncompressedBytes uint64 `json:"uncompressed_bytes"`
+
+	// Streams is the number of log streams registered for the tenant in the partition.
+	Streams uint64 `json:"streams"`
+
+	// MinTime is the timestamp of the oldest row stored for the tenant in the partition.
+	MinTime time.Time `json:"min_time"`
+
+	// MaxTime is the timestamp of the newest row stored for the tenant in the partition.
+	MaxTime time.Time `json:"max_time"`
+
+	// minTimestamp and maxTimestamp are MinTime and MaxTime in nanoseconds.
+	minTimestamp int64
+	maxTimestamp int64
+}
+
+// GetTenantsUsage returns storage usage for all the tenants with stored rows across all the partitions.
+//
+// The usage is calculated from indexdb and from block headers, so it doesn't need reading the stored logs.
+func (s *Storage) GetTenantsUsage(ctx context.Context) (*TenantsUsage, error) {
+	workersCount := cgroup.AvailableCPUs()
+	stopCh := ctx.Done()
+
+	// Copy all the partitions, so they don't interfere with s.partitions.
+	s.partitionsLock.Lock()
+	ptws := append([]*partitionWrapper{}, s.partitions...)
+	for _, ptw := range ptws {
+		ptw.incRef()
+	}
+	s.partitionsLock.Unlock()
+
+	defer func() {
+		for _, ptw := range ptws {
+			ptw.decRef()
+		}
+	}()
+
+	results := make([]map[TenantID]*PartitionTenantUsage, len(ptws))
+
+	// spin up workers
+	var wg sync.WaitGroup
+	workCh := make(chan int, workersCount)
+	for i := 0; i < workersCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range workCh {
+				if needStop(stopCh) {
+					// The search has been canceled. Just skip all the scheduled work in order to save CPU time.
+					continue
+				}
+				results[idx] = ptws[idx].pt.getTenantsUsage(stopCh)
+			}
+		}()
+	}
+
+	// Schedule concurrent work across all the partitions.
+	for idx := range ptws {
+		workCh <- idx
+	}
+
+	// Wait until workers finish their work
+	close(workCh)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m := make(map[TenantID][]*PartitionTenantUsage)
+	for _, ptus := range results {
+		for tenantID, ptu := range ptus {
+			m[tenantID] = append(m[tenantID], ptu)
+		}
+	}
+	return newTenantsUsage(m), nil
+}
+
+func (pt *partition) getTenantsUsage(stopCh <-chan struct{}) map[TenantID]*PartitionTenantUsage {
+	m := make(map[TenantID]*PartitionTenantUsage)
+
+	// Collect rows and bytes from block headers.
+	pws, pwsDecRef := pt.ddb.getPartsForTimeRange(math.MinInt64, math.MaxInt64)
+	defer pwsDecRef()
+
+	var qs QueryStats
+	bhss := getBlockHeaders()
+	defer putBlockHeaders(bhss)
+
+	for _, pw := range pws {
+		p := pw.p
+		ph := &p.ph
+		ibhs := p.indexBlockHeaders
+		for i := range ibhs {
+			if needStop(stopCh) {
+				return nil
+			}
+
+			bhss.bhs = ibhs[i].mustReadBlockHeaders(bhss.bhs[:0], p, &qs)
+			for j := range bhss.bhs {
+				bh := &bhss.bhs[j]
+				ptu := m[bh.streamID.tenantID]
+				if ptu == nil {
+					ptu = &PartitionTenantUsage{
+						Partition:    pt.name,
+						minTimestamp: math.MaxInt64,
+						maxTimestamp: math.MinInt64,
+					}
+					m[bh.streamID.tenantID] = ptu
+				}
+				ptu.Rows += bh.rowsCount
+				ptu.UncompressedBytes += bh.uncompressedSizeBytes
+				if ph.UncompressedSizeBytes > 0 {
+					ptu.CompressedBytes += uint64(float64(bh.uncompressedSizeBytes) * float64(ph.CompressedSizeBytes) / float64(ph.UncompressedSizeBytes))
+				}
+				ptu.minTimestamp = min(ptu.minTimestamp, bh.timestampsHeader.minTimestamp)
+				ptu.maxTimestamp = max(ptu.maxTimestamp, bh.timestampsHeader.maxTimestamp)
+			}
+		}
+	}
+
+	// Collect the number of streams from indexdb only for tenants with stored rows.
+	is := pt.idb.getIndexSearch()
+	defer pt.idb.putIndexSearch(is)
+
+	for tenantID, ptu := range m {
+		if needStop(stopCh) {
+			return nil
+		}
+		ptu.Streams = uint64(len(is.getStreamIDsForTenant(tenantID)))
+		ptu.MinTime = time.Unix(0, ptu.minTimestamp).UTC()
+		ptu.MaxTime = time.Unix(0, ptu.maxTimestamp).UTC()
+	}
+
+	return m
+}
+
+// MergeTenantsUsage merges tus obtained from multiple storage nodes.
+func MergeTenantsUsage(tus []*TenantsUsage) *TenantsUsage {
+	type key struct {
+		tenantID  TenantID
+		partition string
+	}
+	m := make(map[key]*PartitionTenantUsage)
+	for _, tu := range tus {
+		if tu == nil {
+			continue
+		}
+		for _, u := range tu.Tenants {
+			for _, ptu := range u.Partitions {
+				k := key{
+					tenantID:  u.TenantID,
+					partition: ptu.Partition,
+				}
+				dst := m[k]
+				if dst == nil {
+					m[k] = &PartitionTenantUsage{
+						Partition:         ptu.Partition,
+						Rows:              ptu.Rows,
+						CompressedBytes:   ptu.CompressedBytes,
+						UncompressedBytes: ptu.UncompressedBytes,
+						Streams:           ptu.Streams,
+						MinTime:           ptu.MinTime,
+						MaxTime:           ptu.MaxTime,
+					}
+					continue
+				}
+				dst.Rows += ptu.Rows
+				dst.CompressedBytes += ptu.CompressedBytes
+				dst.UncompressedBytes += ptu.UncompressedBytes
+				dst.Streams += ptu.Streams
+				if ptu.MinTime.Before(dst.MinTime) {
+					dst.MinTime = ptu.MinTime
+				}
+				if ptu.MaxTime.After(dst.MaxTime) {
+					dst.MaxTime = ptu.MaxTime
+				}
+			}
+		}
+	}
+
+	ptus := make(map[TenantID][]*PartitionTenantUsage)
+	for k, ptu := range m {
+		ptus[k.tenantID] = append(ptus[k.tenantID], ptu)
+	}
+	return newTenantsUsage(ptus)
+}
+
+func newTenantsUsage(m map[TenantID][]*PartitionTenantUsage) *TenantsUsage {
+	tu := &TenantsUsage{
+		Tenants: make([]*TenantUsage, 0, len(m)),
+	}
+	for tenantID, ptus := range m {
+		sort.Slice(ptus, func(i, j int) bool {
+			return ptus[i].Partition < ptus[j].Partition
+		})
+		u := &TenantUsage{
+			TenantID:   tenantID,
+			Partitions: ptus,
+		}
+		for i, ptu := range ptus {
+			u.Rows += ptu.Rows
+			u.CompressedBytes += ptu.CompressedBytes
+			u.UncompressedBytes += ptu.UncompressedBytes
+			if i == 0 || ptu.MinTime.Before(u.MinTime) {
+				u.MinTime = ptu.MinTime
+			}
+			if i == 0 || ptu.MaxTime.After(u.MaxTime) {
+				u.MaxTime = ptu.MaxTime
+			}
+		}
+		tu.Tenants = append(tu.Tenants, u)
+	}
+	sort.Slice(tu.Tenants, func(i, j int) bool {
+		return tu.Tenants[i].TenantID.less(&tu.Tenants[j].TenantID)
+	})
+	return tu
+}
@@ -0,0 +1,155 @@
+package logstorage
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+)
+
+func TestStorageGetTenantsUsage(t *testing.T) {
+	t.Parallel()
+
+	path := t.Name()
+	ctx := t.Context()
+
+	cfg := &StorageConfig{
+		Retention: 30 * 24 * time.Hour,
+	}
+	s := MustOpenStorage(path, cfg)
+
+	tenantID := TenantID{
+		AccountID: 1,
+		ProjectID: 2,
+	}
+	otherTenantID := TenantID{
+		AccountID: 3,
+		ProjectID: 4,
+	}
+
+	// Use the middle of the current day, so the ingested logs do not cross day boundaries.
+	day := time.Now().UnixNano() / nsecsPerDay
+	now := day*nsecsPerDay + nsecsPerDay/2
+
+	lr := GetLogRows([]string{"host"}, nil, nil, nil, "")
+	addRows := func(tenantID TenantID, timestamp int64, host string, rowsCount int) {
+		for i := 0; i < rowsCount; i++ {
+			fields := []Field{
+				{
+					Name:  "host",
+					Value: host,
+				},
+				{
+					Name:  "_msg",
+					Value: "some message",
+				},
+			}
+			lr.MustAdd(tenantID, timestamp+int64(i), fields, nil)
+		}
+	}
+
+	addRows(tenantID, now-nsecsPerDay, "host-0", 2)
+	addRows(tenantID, now, "host-0", 3)
+	addRows(tenantID, now, "host-1", 4)
+	addRows(otherTenantID, now, "host-2", 5)
+
+	s.MustAddRows(lr)
+	PutLogRows(lr)
+	s.DebugFlush()
+
+	tu, err := s.GetTenantsUsage(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(tu.Tenants) != 2 {
+		t.Fatalf("unexpected number of tenants; got %d; want 2", len(tu.Tenants))
+	}
+
+	f := func(u *TenantUsage, tenantIDExpected TenantID, rowsExpected uint64, minTimestampExpected, maxTimestampExpected int64, partitionsExpected []uint64) {
+		t.Helper()
+
+		if u.TenantID != tenantIDExpected {
+			t.Fatalf("unexpected tenant; got %s; want %s", u.TenantID, tenantIDExpected)
+		}
+		if u.Rows != rowsExpected {
+			t.Fatalf("unexpected rows; got %d; want %d", u.Rows, rowsExpected)
+		}
+		if u.UncompressedBytes == 0 || u.CompressedBytes == 0 {
+			t.Fatalf("unexpected zero size; uncompressed_bytes=%d, compressed_bytes=%d", u.UncompressedBytes, u.CompressedBytes)
+		}
+		if !u.MinTime.Equal(time.Unix(0, minTimestampExpected)) {
+			t.Fatalf("unexpected min_time; got %s; want %s", u.MinTime, time.Unix(0, minTimestampExpected).UTC())
+		}
+		if !u.MaxTime.Equal(time.Unix(0, maxTimestampExpected)) {
+			t.Fatalf("unexpected max_time; got %s; want %s", u.MaxTime, time.Unix(0, maxTimestampExpected).UTC())
+		}
+
+		// partitionsExpected contains pairs of (rows, streams) per every partition.
+		if len(u.Partitions)*2 != len(partitionsExpected) {
+			t.Fatalf("unexpected number of partitions; got %d; want %d", len(u.Partitions), len(partitionsExpected)/2)
+		}
+		for i, ptu := range u.Partitions {
+			if ptu.Rows != partitionsExpected[2*i] || ptu.Streams != partitionsExpected[2*i+1] {
+				t.Fatalf("unexpected usage at partition %q; got rows=%d, streams=%d; want rows=%d, streams=%d",
+					ptu.Partition, ptu.Rows, ptu.Streams, partitionsExpected[2*i], partitionsExpected[2*i+1])
+			}
+		}
+	}
+
+	f(tu.Tenants[0], tenantID, 9, now-nsecsPerDay, now+3, []uint64{2, 1, 7, 2})
+	f(tu.Tenants[1], otherTenantID, 5, now, now+4, []uint64{5, 1})
+
+	if tu.Tenants[0].Partitions[0].Partition != getPartitionName((day-1)*nsecsPerDay, nsecsPerDay) {
+		t.Fatalf("unexpected partition; got %q; want %q", tu.Tenants[0].Partitions[0].Partition, getPartitionName((day-1)*nsecsPerDay, nsecsPerDay))
+	}
+
+	s.MustClose()
+
+	fs.MustRemoveDir(path)
+}
+
+func TestMergeTenantsUsage(t *testing.T) {
+	f := func(tusJSON []string, resultExpected string) {
+		t.Helper()
+
+		var tus []*TenantsUsage
+		for _, data := range tusJSON {
+			var tu TenantsUsage
+			if err := json.Unmarshal([]byte(data), &tu); err != nil {
+				t.Fatalf("cannot unmarshal %s: %s", data, err)
+			}
+			tus = append(tus, &tu)
+		}
+		result, err := json.Marshal(MergeTenantsUsage(tus))
+		if err != nil {
+			t.Fatalf("cannot marshal result: %s", err)
+		}
+		if string(result) != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	f(nil, `{"tenants":[]}`)
+
+	f([]string{
+		`{"tenants":[{"tenant_id":{"account_id":1,"project_id":0},"partitions":[` +
+			`{"partition":"20250102","rows":10,"compressed_bytes":5,"uncompressed_bytes":100,"streams":2,` +
+			`"min_time":"2025-01-02T10:00:00Z","max_time":"2025-01-02T11:00:00Z"}]}]}`,
+		`{"tenants":[{"tenant_id":{"account_id":1,"project_id":0},"partitions":[` +
+			`{"partition":"20250102","rows":20,"compressed_bytes":7,"uncompressed_bytes":200,"streams":3,` +
+			`"min_time":"2025-01-02T09:00:00Z","max_time":"2025-01-02T10:30:00Z"},` +
+			`{"partition":"20250101","rows":1,"compressed_bytes":1,"uncompressed_bytes":10,"streams":1,` +
+			`"min_time":"2025-01-01T00:00:00Z","max_time":"2025-01-01T00:00:00Z"}]},` +
+			`{"tenant_id":{"account_id":0,"project_id":5},"partitions":[` +
+			`{"partition":"20250102","rows":3,"compressed_bytes":2,"uncompressed_bytes":30,"streams":1,` +
+			`"min_time":"2025-01-02T00:00:00Z","max_time":"2025-01-02T01:00:00Z"}]}]}`,
+	}, `{"tenants":[`+
+		`{"tenant_id":{"account_id":0,"project_id":5},"rows":3,"compressed_bytes":2,"uncompressed_bytes":30,`+
+		`"min_time":"2025-01-02T00:00:00Z","max_time":"2025-01-02T01:00:00Z","partitions":[`+
+		`{"partition":"20250102","rows":3,"compressed_bytes":2,"uncompressed_bytes":30,"streams":1,"min_time":"2025-01-02T00:00:00Z","max_time":"2025-01-02T01:00:00Z"}]},`+
+		`{"tenant_id":{"account_id":1,"project_id":0},"rows":31,"compressed_bytes":13,"uncompressed_bytes":310,`+
+		`"min_time":"2025-01-01T00:00:00Z","max_time":"2025-01-02T11:00:00Z","partitions":[`+
+		`{"partition":"20250101","rows":1,"compressed_bytes":1,"uncompressed_bytes":10,"streams":1,"min_time":"2025-01-01T00:00:00Z","max_time":"2025-01-01T00:00:00Z"},`+
+		`{"partition":"20250102","rows":30,"compressed_bytes":12,"uncompressed_bytes":300,"streams":5,"min_time":"2025-01-02T09:00:00Z","max_time":"2025-01-02T11:00:00Z"}]}]}`)
+}
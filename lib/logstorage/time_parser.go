@@ -0,0 +1,222 @@
+package logstorage
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timeutil"
+)
+
+// TimeParser parses timestamps in the given format.
+//
+// See https://docs.victoriametrics.com/victorialogs/logsql/#parse_time-pipe
+type TimeParser struct {
+	// format is the original format passed to NewTimeParser.
+	format string
+
+	// timezone is the original timezone passed to NewTimeParser.
+	timezone string
+
+	kind timeFormatKind
+
+	// layout is Go time layout for kind=timeFormatLayout.
+	layout string
+
+	// loc is the location for timestamps without timezone information.
+	loc *time.Location
+}
+
+type timeFormatKind int
+
+const (
+	timeFormatAuto timeFormatKind = iota
+	timeFormatRFC3339
+	timeFormatUnixS
+	timeFormatUnixMs
+	timeFormatUnixUs
+	timeFormatUnixNs
+	timeFormatLayout
+)
+
+// NewTimeParser returns TimeParser for the given format and timezone.
+//
+// The format may be empty, one of rfc3339, unix_s, unix_ms, unix_us, unix_ns, strftime format such as %d/%b/%Y:%H:%M:%S %z,
+// or Go time layout such as 02/Jan/2006:15:04:05 -0700. Empty format means the automatic detection of unix timestamps and RFC3339 timestamps.
+//
+// The timezone is applied to timestamps without timezone information. It must be empty if the format is empty.
+func NewTimeParser(format, timezone string) (*TimeParser, error) {
+	tp := &TimeParser{
+		format:   format,
+		timezone: timezone,
+		loc:      time.UTC,
+	}
+
+	switch format {
+	case "":
+		tp.kind = timeFormatAuto
+	case "rfc3339":
+		tp.kind = timeFormatRFC3339
+	case "unix_s":
+		tp.kind = timeFormatUnixS
+	case "unix_ms":
+		tp.kind = timeFormatUnixMs
+	case "unix_us":
+		tp.kind = timeFormatUnixUs
+	case "unix_ns":
+		tp.kind = timeFormatUnixNs
+	default:
+		layout := format
+		if strings.Contains(format, "%") {
+			l, err := strftimeToLayout(format)
+			if err != nil {
+				return nil, err
+			}
+			layout = l
+		}
+		if s := time.Unix(0, 0).UTC().Format(layout); s == layout {
+			return nil, fmt.Errorf("time format %q doesn't contain time components", format)
+		}
+		tp.kind = timeFormatLayout
+		tp.layout = layout
+	}
+
+	if timezone != "" {
+		if tp.kind == timeFormatAuto {
+			return nil, fmt.Errorf("timezone %q cannot be set without time format", timezone)
+		}
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load timezone %q: %w", timezone, err)
+		}
+		tp.loc = loc
+	}
+
+	return tp, nil
+}
+
+// Format returns the format passed to NewTimeParser.
+func (tp *TimeParser) Format() string {
+	return tp.format
+}
+
+// Timezone returns the timezone passed to NewTimeParser.
+func (tp *TimeParser) Timezone() string {
+	return tp.timezone
+}
+
+// TryParse parses s and returns unix timestamp in nanoseconds.
+//
+// nil tp parses s in the automatic mode - see NewTimeParser for details.
+func (tp *TimeParser) TryParse(s string) (int64, bool) {
+	if tp == nil {
+		return tryParseTimestampAuto(s)
+	}
+
+	switch tp.kind {
+	case timeFormatAuto:
+		return tryParseTimestampAuto(s)
+	case timeFormatRFC3339:
+		if nsecs, ok := TryParseTimestampRFC3339Nano(s); ok {
+			return nsecs, true
+		}
+		return tp.tryParseLayout(s, time.RFC3339Nano)
+	case timeFormatUnixS:
+		return tryParseUnixTimestampWithScale(s, 1e9)
+	case timeFormatUnixMs:
+		return tryParseUnixTimestampWithScale(s, 1e6)
+	case timeFormatUnixUs:
+		return tryParseUnixTimestampWithScale(s, 1e3)
+	case timeFormatUnixNs:
+		return tryParseUnixTimestampWithScale(s, 1)
+	default:
+		return tp.tryParseLayout(s, tp.layout)
+	}
+}
+
+func (tp *TimeParser) tryParseLayout(s, layout string) (int64, bool) {
+	t, err := time.ParseInLocation(layout, s, tp.loc)
+	if err != nil {
+		return 0, false
+	}
+	return t.UnixNano(), true
+}
+
+// tryParseTimestampAuto parses s either as unix timestamp with automatically detected precision or as RFC3339 timestamp.
+func tryParseTimestampAuto(s string) (int64, bool) {
+	if len(s) <= len("YYYY") || s[len("YYYY")] != '-' {
+		return timeutil.TryParseUnixTimestamp(s)
+	}
+	return TryParseTimestampRFC3339Nano(s)
+}
+
+func tryParseUnixTimestampWithScale(s string, scale int64) (int64, bool) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if n > math.MaxInt64/scale || n < math.MinInt64/scale {
+			return 0, false
+		}
+		return n * scale, true
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	f *= float64(scale)
+	if math.IsNaN(f) || f > math.MaxInt64 || f < math.MinInt64 {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+var strftimeDirectives = map[byte]string{
+	'a': "Mon",
+	'A': "Monday",
+	'b': "Jan",
+	'B': "January",
+	'd': "02",
+	'D': "01/02/06",
+	'e': "_2",
+	'f': "000000",
+	'F': "2006-01-02",
+	'h': "Jan",
+	'H': "15",
+	'I': "03",
+	'j': "002",
+	'L': "000",
+	'm': "01",
+	'M': "04",
+	'N': "000000000",
+	'p': "PM",
+	'S': "05",
+	'T': "15:04:05",
+	'y': "06",
+	'Y': "2006",
+	'z': "-0700",
+	'Z': "MST",
+	'%': "%",
+}
+
+// strftimeToLayout converts strftime format to Go time layout.
+func strftimeToLayout(format string) (string, error) {
+	var sb strings.Builder
+	for {
+		n := strings.IndexByte(format, '%')
+		if n < 0 {
+			sb.WriteString(format)
+			return sb.String(), nil
+		}
+		sb.WriteString(format[:n])
+		format = format[n+1:]
+		if len(format) == 0 {
+			return "", fmt.Errorf("missing directive after the trailing '%%' in time format")
+		}
+		layout, ok := strftimeDirectives[format[0]]
+		if !ok {
+			return "", fmt.Errorf("unsupported directive %%%c in time format", format[0])
+		}
+		sb.WriteString(layout)
+		format = format[1:]
+	}
+}
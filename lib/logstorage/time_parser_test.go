@@ -0,0 +1,97 @@
+package logstorage
+
+import (
+	"testing"
+)
+
+func TestTimeParserSuccess(t *testing.T) {
+	f := func(format, timezone, s string, nsecsExpected int64) {
+		t.Helper()
+
+		tp, err := NewTimeParser(format, timezone)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		nsecs, ok := tp.TryParse(s)
+		if !ok {
+			t.Fatalf("cannot parse %q with format %q", s, format)
+		}
+		if nsecs != nsecsExpected {
+			t.Fatalf("unexpected nsecs when parsing %q with format %q; got %d; want %d", s, format, nsecs, nsecsExpected)
+		}
+	}
+
+	// automatic detection
+	f("", "", "2024-06-18T23:37:20Z", 1718753840000000000)
+	f("", "", "2024-06-18 23:37:20.123-05:30", 1718773640123000000)
+	f("", "", "1718773640", 1718773640000000000)
+	f("", "", "1718773640123", 1718773640123000000)
+
+	// rfc3339
+	f("rfc3339", "", "2024-06-18T23:37:20+08:00", 1718725040000000000)
+
+	// unix timestamps
+	f("unix_s", "", "1718773640", 1718773640000000000)
+	f("unix_s", "", "1718773640.5", 1718773640500000000)
+	f("unix_ms", "", "1718773640123", 1718773640123000000)
+	f("unix_us", "", "1718773640123456", 1718773640123456000)
+	f("unix_ns", "", "1718773640123456789", 1718773640123456789)
+	f("unix_ms", "", "10", 10000000)
+
+	// Go layout
+	f("02/Jan/2006:15:04:05 -0700", "", "18/Jun/2024:23:37:20 +0200", 1718746640000000000)
+	f("2006-01-02 15:04:05", "", "2024-06-18 23:37:20", 1718753840000000000)
+	f("2006-01-02 15:04:05", "Europe/Berlin", "2024-06-18 23:37:20", 1718746640000000000)
+
+	// strftime
+	f("%d/%b/%Y:%H:%M:%S %z", "", "18/Jun/2024:23:37:20 +0200", 1718746640000000000)
+	f("%Y-%m-%d %H:%M:%S.%f", "Europe/Berlin", "2024-06-18 23:37:20.123456", 1718746640123456000)
+	f("%F %T", "UTC", "2024-06-18 23:37:20", 1718753840000000000)
+}
+
+func TestTimeParserTryParseFailure(t *testing.T) {
+	f := func(format, s string) {
+		t.Helper()
+
+		tp, err := NewTimeParser(format, "")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if nsecs, ok := tp.TryParse(s); ok {
+			t.Fatalf("expecting failure when parsing %q with format %q; got %d", s, format, nsecs)
+		}
+	}
+
+	f("", "")
+	f("", "foobar")
+	f("", "2024-06-18")
+	f("rfc3339", "1718773640")
+	f("unix_s", "2024-06-18T23:37:20Z")
+	f("unix_ns", "1e30")
+	f("02/Jan/2006:15:04:05 -0700", "2024-06-18T23:37:20Z")
+	f("%Y-%m-%d", "18/Jun/2024")
+}
+
+func TestNewTimeParserFailure(t *testing.T) {
+	f := func(format, timezone string) {
+		t.Helper()
+
+		if _, err := NewTimeParser(format, timezone); err == nil {
+			t.Fatalf("expecting non-nil error for format=%q, timezone=%q", format, timezone)
+		}
+	}
+
+	// missing time components
+	f("foobar", "")
+	f("%%", "")
+
+	// unsupported strftime directives
+	f("%Y-%Q", "")
+	f("%Y-%", "")
+
+	// invalid timezone
+	f("unix_s", "foo/bar")
+
+	// timezone without format
+	f("", "UTC")
+}
@@ -0,0 +1,337 @@
+package logstorage
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/slicesutil"
+)
+
+// valuesCodec is the codec used for marshaling encoded column values into values block.
+//
+// Every codec reproduces the original encoded values on unmarshaling, so filters and pipes work with them as usual.
+type valuesCodec byte
+
+const (
+	// valuesCodecDefault marshals values with marshalStringsBlock().
+	valuesCodecDefault = valuesCodec(0)
+
+	// valuesCodecDictDelta marshals valueTypeDict ids as deltas between adjacent ids.
+	valuesCodecDictDelta = valuesCodec(1)
+
+	// valuesCodecDeltaOfDelta marshals fixed-width integer values as zigzag-encoded deltas of deltas between adjacent values.
+	//
+	// It compresses well monotonic integers such as counters, ids and timestamps.
+	valuesCodecDeltaOfDelta = valuesCodec(2)
+
+	// valuesCodecXOR marshals float64 values as XOR with the previous value like Gorilla does, but with byte granularity.
+	valuesCodecXOR = valuesCodec(3)
+)
+
+func (vc valuesCodec) String() string {
+	switch vc {
+	case valuesCodecDefault:
+		return "default"
+	case valuesCodecDictDelta:
+		return "dict_delta"
+	case valuesCodecDeltaOfDelta:
+		return "delta_of_delta"
+	case valuesCodecXOR:
+		return "xor"
+	default:
+		return fmt.Sprintf("unknown(%d)", byte(vc))
+	}
+}
+
+// bigPartsCompressLevel is the minimum zstd compression level for column values at big parts if adaptive compression is enabled.
+const bigPartsCompressLevel = 5
+
+// valuesCompression contains settings for marshaling column values.
+//
+// The zero valuesCompression marshals all the values with valuesCodecDefault.
+type valuesCompression struct {
+	// adaptive enables trying alternative codecs for column values and choosing the codec with the smallest result.
+	adaptive bool
+
+	// minCompressLevel is the minimum zstd compression level for column values.
+	minCompressLevel int
+}
+
+// getValuesCompression returns values compression for the part of the given type.
+func getValuesCompression(adaptive bool, pt partType) valuesCompression {
+	if !adaptive {
+		return valuesCompression{}
+	}
+	vc := valuesCompression{
+		adaptive: true,
+	}
+	if pt == partBig {
+		vc.minCompressLevel = bigPartsCompressLevel
+	}
+	return vc
+}
+
+// marshalValues marshals the encoded values of the given type according to vc, appends the result to dst and returns it together with the used codec.
+//
+// The marshaled values can be unmarshaled with stringsBlockUnmarshaler.unmarshalValues().
+func marshalValues(dst []byte, values []string, vt valueType, vc *valuesCompression) ([]byte, valuesCodec) {
+	dstLen := len(dst)
+	dst = marshalStringsBlockLevel(dst, values, vc.minCompressLevel)
+	if !vc.adaptive || areConstValues(values) {
+		// Constant values are already compressed to a few bytes by marshalStringsBlock().
+		return dst, valuesCodecDefault
+	}
+
+	codec := getAlternativeValuesCodec(vt)
+	if codec == valuesCodecDefault {
+		return dst, valuesCodecDefault
+	}
+
+	bb := bbPool.Get()
+	bb.B = marshalValuesWithCodec(bb.B[:0], values, vt, codec, vc.minCompressLevel)
+	if len(bb.B) < len(dst)-dstLen {
+		dst = append(dst[:dstLen], bb.B...)
+	} else {
+		codec = valuesCodecDefault
+	}
+	bbPool.Put(bb)
+
+	return dst, codec
+}
+
+// getAlternativeValuesCodec returns the codec, which may compress values of the given type better than valuesCodecDefault.
+func getAlternativeValuesCodec(vt valueType) valuesCodec {
+	switch vt {
+	case valueTypeDict:
+		return valuesCodecDictDelta
+	case valueTypeUint8, valueTypeUint16, valueTypeUint32, valueTypeUint64, valueTypeInt64, valueTypeTimestampISO8601:
+		return valuesCodecDeltaOfDelta
+	case valueTypeFloat64:
+		return valuesCodecXOR
+	default:
+		return valuesCodecDefault
+	}
+}
+
+// getValueSizeForCodec returns the size in bytes of every encoded value of type vt marshaled with the given codec.
+//
+// It returns 0 if the codec cannot be used for vt.
+func getValueSizeForCodec(vt valueType, codec valuesCodec) int {
+	switch codec {
+	case valuesCodecDictDelta:
+		if vt == valueTypeDict {
+			return 1
+		}
+	case valuesCodecDeltaOfDelta:
+		switch vt {
+		case valueTypeUint8:
+			return 1
+		case valueTypeUint16:
+			return 2
+		case valueTypeUint32:
+			return 4
+		case valueTypeUint64, valueTypeInt64, valueTypeTimestampISO8601:
+			return 8
+		}
+	case valuesCodecXOR:
+		if vt == valueTypeFloat64 {
+			return 8
+		}
+	}
+	return 0
+}
+
+func marshalValuesWithCodec(dst []byte, values []string, vt valueType, codec valuesCodec, minCompressLevel int) []byte {
+	valueSize := getValueSizeForCodec(vt, codec)
+	if valueSize == 0 {
+		logger.Panicf("BUG: codec %s cannot be used for valueType=%s", codec, vt)
+	}
+
+	bb := bbPool.Get()
+	b := bb.B[:0]
+
+	switch codec {
+	case valuesCodecDictDelta:
+		prevID := byte(0)
+		for _, v := range values {
+			id := getFixedWidthValue(v, valueSize)
+			b = append(b, byte(id)-prevID)
+			prevID = byte(id)
+		}
+	case valuesCodecDeltaOfDelta:
+		prevValue := uint64(0)
+		prevDelta := uint64(0)
+		for _, v := range values {
+			n := getFixedWidthValue(v, valueSize)
+			delta := n - prevValue
+			b = encoding.MarshalVarInt64(b, int64(delta-prevDelta))
+			prevValue = n
+			prevDelta = delta
+		}
+	case valuesCodecXOR:
+		prevValue := uint64(0)
+		for _, v := range values {
+			n := getFixedWidthValue(v, valueSize)
+			b = marshalXORValue(b, n^prevValue)
+			prevValue = n
+		}
+	default:
+		logger.Panicf("BUG: unexpected codec %s", codec)
+	}
+
+	dst = marshalBytesBlockLevel(dst, b, minCompressLevel)
+
+	bb.B = b
+	bbPool.Put(bb)
+
+	return dst
+}
+
+// marshalXORValue appends x to dst as a control byte with the number of leading and trailing zero bytes followed by the remaining bytes.
+func marshalXORValue(dst []byte, x uint64) []byte {
+	if x == 0 {
+		return append(dst, 8<<4)
+	}
+	leading := bits.LeadingZeros64(x) / 8
+	trailing := bits.TrailingZeros64(x) / 8
+	dst = append(dst, byte(leading<<4|trailing))
+	for i := 7 - leading; i >= trailing; i-- {
+		dst = append(dst, byte(x>>(8*i)))
+	}
+	return dst
+}
+
+func unmarshalXORValue(src []byte) (uint64, []byte, error) {
+	if len(src) < 1 {
+		return 0, src, fmt.Errorf("cannot unmarshal control byte from empty src")
+	}
+	leading := int(src[0] >> 4)
+	trailing := int(src[0] & 0x0f)
+	src = src[1:]
+	if leading+trailing > 8 {
+		return 0, src, fmt.Errorf("unexpected number of zero bytes; leading=%d, trailing=%d; their sum mustn't exceed 8", leading, trailing)
+	}
+	n := 8 - leading - trailing
+	if len(src) < n {
+		return 0, src, fmt.Errorf("cannot unmarshal %d bytes from %d bytes", n, len(src))
+	}
+	x := uint64(0)
+	for _, c := range src[:n] {
+		x = x<<8 | uint64(c)
+	}
+	x <<= 8 * trailing
+	return x, src[n:], nil
+}
+
+// getFixedWidthValue returns big-endian unsigned integer stored in v with the given size.
+func getFixedWidthValue(v string, size int) uint64 {
+	if len(v) != size {
+		logger.Panicf("BUG: unexpected encoded value size; got %d bytes; want %d bytes", len(v), size)
+	}
+	n := uint64(0)
+	for i := 0; i < len(v); i++ {
+		n = n<<8 | uint64(v[i])
+	}
+	return n
+}
+
+func appendFixedWidthValue(dst []byte, n uint64, size int) []byte {
+	for i := size - 1; i >= 0; i-- {
+		dst = append(dst, byte(n>>(8*i)))
+	}
+	return dst
+}
+
+// unmarshalValues unmarshals itemsCount values of the given type marshaled with the given codec from src, appends them to dst and returns the result.
+//
+// The returned strings are valid until sbu.reset() call.
+func (sbu *stringsBlockUnmarshaler) unmarshalValues(dst []string, src []byte, itemsCount uint64, vt valueType, codec valuesCodec) ([]string, error) {
+	if codec == valuesCodecDefault {
+		return sbu.unmarshal(dst, src, itemsCount)
+	}
+
+	valueSize := getValueSizeForCodec(vt, codec)
+	if valueSize == 0 {
+		return dst, fmt.Errorf("codec %s cannot be used for valueType=%s", codec, vt)
+	}
+
+	bb := bbPool.Get()
+	defer bbPool.Put(bb)
+
+	var tail []byte
+	var err error
+	bb.B, tail, err = unmarshalBytesBlock(bb.B[:0], src)
+	if err != nil {
+		return dst, fmt.Errorf("cannot unmarshal bytes block for codec %s: %w", codec, err)
+	}
+	if len(tail) > 0 {
+		return dst, fmt.Errorf("unexpected non-empty tail after reading bytes block for codec %s; len(tail)=%d", codec, len(tail))
+	}
+	src = bb.B
+
+	// Decode values into sbu.data
+	dataLen := len(sbu.data)
+	switch codec {
+	case valuesCodecDictDelta:
+		if uint64(len(src)) != itemsCount {
+			return dst, fmt.Errorf("unexpected number of dict ids; got %d; want %d", len(src), itemsCount)
+		}
+		id := byte(0)
+		for _, delta := range src {
+			id += delta
+			sbu.data = append(sbu.data, id)
+		}
+	case valuesCodecDeltaOfDelta:
+		prevValue := uint64(0)
+		prevDelta := uint64(0)
+		for i := uint64(0); i < itemsCount; i++ {
+			dod, nSize := encoding.UnmarshalVarInt64(src)
+			if nSize <= 0 {
+				return dst, fmt.Errorf("cannot unmarshal delta of delta for the value #%d", i)
+			}
+			src = src[nSize:]
+			delta := prevDelta + uint64(dod)
+			n := prevValue + delta
+			if valueSize < 8 && n>>(8*valueSize) != 0 {
+				return dst, fmt.Errorf("the value #%d doesn't fit %d bytes: %d", i, valueSize, n)
+			}
+			sbu.data = appendFixedWidthValue(sbu.data, n, valueSize)
+			prevValue = n
+			prevDelta = delta
+		}
+		if len(src) > 0 {
+			return dst, fmt.Errorf("unexpected non-empty tail after reading %d delta of delta values; len(tail)=%d", itemsCount, len(src))
+		}
+	case valuesCodecXOR:
+		prevValue := uint64(0)
+		for i := uint64(0); i < itemsCount; i++ {
+			x, tail, err := unmarshalXORValue(src)
+			if err != nil {
+				return dst, fmt.Errorf("cannot unmarshal the value #%d: %w", i, err)
+			}
+			src = tail
+			n := prevValue ^ x
+			sbu.data = appendFixedWidthValue(sbu.data, n, valueSize)
+			prevValue = n
+		}
+		if len(src) > 0 {
+			return dst, fmt.Errorf("unexpected non-empty tail after reading %d xor values; len(tail)=%d", itemsCount, len(src))
+		}
+	default:
+		return dst, fmt.Errorf("unexpected codec %s", codec)
+	}
+
+	// Split sbu.data into values
+	data := sbu.data[dataLen:]
+	dst = slicesutil.SetLength(dst, len(dst)+int(itemsCount))
+	dstA := dst[len(dst)-int(itemsCount):]
+	for i := range dstA {
+		dstA[i] = bytesutil.ToUnsafeString(data[:valueSize])
+		data = data[valueSize:]
+	}
+
+	return dst, nil
+}
@@ -0,0 +1,255 @@
+package logstorage
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+)
+
+func TestMarshalUnmarshalValues(t *testing.T) {
+	f := func(values []string, adaptive bool, expectedValueType valueType, expectedCodec valuesCodec) {
+		t.Helper()
+
+		ve := getValuesEncoder()
+		var dict valuesDict
+		vt, _, _ := ve.encode(values, &dict)
+		if vt != expectedValueType {
+			t.Fatalf("unexpected value type; got %s; want %s", vt, expectedValueType)
+		}
+
+		vc := getValuesCompression(adaptive, partBig)
+		data, codec := marshalValues(nil, ve.values, vt, &vc)
+		if codec != expectedCodec {
+			t.Fatalf("unexpected codec; got %s; want %s", codec, expectedCodec)
+		}
+		if adaptive && codec != valuesCodecDefault {
+			dataDefault := marshalStringsBlock(nil, ve.values)
+			if len(data) >= len(dataDefault) {
+				t.Fatalf("codec %s must produce smaller data than the default codec; got %d bytes; default codec: %d bytes", codec, len(data), len(dataDefault))
+			}
+		}
+
+		sbu := getStringsBlockUnmarshaler()
+		defer putStringsBlockUnmarshaler(sbu)
+		encodedValues, err := sbu.unmarshalValues(nil, data, uint64(len(values)), vt, codec)
+		if err != nil {
+			t.Fatalf("unexpected error in unmarshalValues(): %s", err)
+		}
+		if !reflect.DeepEqual(encodedValues, ve.values) {
+			t.Fatalf("unexpected encoded values unmarshaled\ngot\n%q\nwant\n%q", encodedValues, ve.values)
+		}
+		putValuesEncoder(ve)
+
+		vd := getValuesDecoder()
+		defer putValuesDecoder(vd)
+		if err := vd.decodeInplace(encodedValues, vt, dict.values); err != nil {
+			t.Fatalf("unexpected error in decodeInplace(): %s", err)
+		}
+		if !reflect.DeepEqual(encodedValues, values) {
+			t.Fatalf("unexpected values decoded\ngot\n%q\nwant\n%q", encodedValues, values)
+		}
+	}
+
+	values := make([]string, 1000)
+
+	// dict values
+	for i := range values {
+		values[i] = fmt.Sprintf("level_%d", (i/10)%3)
+	}
+	f(values, false, valueTypeDict, valuesCodecDefault)
+	f(values, true, valueTypeDict, valuesCodecDictDelta)
+
+	// monotonic uint values
+	for i := range values {
+		values[i] = fmt.Sprintf("%d", 1_000_000+i*7)
+	}
+	f(values, false, valueTypeUint32, valuesCodecDefault)
+	f(values, true, valueTypeUint32, valuesCodecDeltaOfDelta)
+
+	// monotonic int values
+	for i := range values {
+		values[i] = fmt.Sprintf("%d", -500_000+i*1_003)
+	}
+	f(values, true, valueTypeInt64, valuesCodecDeltaOfDelta)
+
+	// iso8601 timestamps
+	for i := range values {
+		values[i] = fmt.Sprintf("2011-04-19T03:44:%02d.%03dZ", i/1000, i%1000)
+	}
+	f(values, true, valueTypeTimestampISO8601, valuesCodecDeltaOfDelta)
+
+	// slowly changing float values
+	for i := range values {
+		values[i] = fmt.Sprintf("%g", 100+float64(i/50)*0.5)
+	}
+	f(values, false, valueTypeFloat64, valuesCodecDefault)
+	f(values, true, valueTypeFloat64, valuesCodecXOR)
+
+	// const values are always marshaled with the default codec
+	for i := range values {
+		values[i] = "12345"
+	}
+	f(values, true, valueTypeDict, valuesCodecDefault)
+
+	// string values are always marshaled with the default codec
+	for i := range values {
+		values[i] = fmt.Sprintf("value_%d", i)
+	}
+	f(values, true, valueTypeString, valuesCodecDefault)
+}
+
+func TestMarshalValuesWithCodec(t *testing.T) {
+	f := func(values []string, vt valueType, codec valuesCodec) {
+		t.Helper()
+
+		data := marshalValuesWithCodec(nil, values, vt, codec, 0)
+
+		sbu := getStringsBlockUnmarshaler()
+		defer putStringsBlockUnmarshaler(sbu)
+		result, err := sbu.unmarshalValues(nil, data, uint64(len(values)), vt, codec)
+		if err != nil {
+			t.Fatalf("unexpected error in unmarshalValues(): %s", err)
+		}
+		if len(values) == 0 {
+			values = nil
+		}
+		if !reflect.DeepEqual(result, values) {
+			t.Fatalf("unexpected values unmarshaled\ngot\n%q\nwant\n%q", result, values)
+		}
+
+		// Unmarshaling of truncated data must fail
+		if len(data) > 2 {
+			if _, err := sbu.unmarshalValues(nil, data[:len(data)-1], uint64(len(values)), vt, codec); err == nil {
+				t.Fatalf("expecting non-nil error when unmarshaling truncated data")
+			}
+		}
+	}
+
+	uint8s := func(a ...uint8) []string {
+		var values []string
+		for _, n := range a {
+			values = append(values, string(appendFixedWidthValue(nil, uint64(n), 1)))
+		}
+		return values
+	}
+	uint64s := func(a ...uint64) []string {
+		var values []string
+		for _, n := range a {
+			values = append(values, string(appendFixedWidthValue(nil, n, 8)))
+		}
+		return values
+	}
+	float64s := func(a ...float64) []string {
+		var values []string
+		for _, f := range a {
+			values = append(values, string(marshalFloat64(nil, f)))
+		}
+		return values
+	}
+
+	// empty values
+	f(nil, valueTypeDict, valuesCodecDictDelta)
+	f(nil, valueTypeUint64, valuesCodecDeltaOfDelta)
+	f(nil, valueTypeFloat64, valuesCodecXOR)
+
+	// dict ids with wrapping deltas
+	f(uint8s(0, 1, 7, 3, 0, 7, 7, 1), valueTypeDict, valuesCodecDictDelta)
+
+	// integers with wrapping deltas
+	f(uint8s(0, 255, 1, 254, 128, 128), valueTypeUint8, valuesCodecDeltaOfDelta)
+	f(uint64s(0, math.MaxUint64, 1, math.MaxUint64-1, 1<<63, 1<<63, 12345), valueTypeUint64, valuesCodecDeltaOfDelta)
+	f(uint64s(100, 200, 300, 400, 500, 600), valueTypeTimestampISO8601, valuesCodecDeltaOfDelta)
+
+	// floats
+	f(float64s(0, 1, 1, -1, 1.5, math.Inf(1), math.Inf(-1), math.NaN(), math.MaxFloat64, math.SmallestNonzeroFloat64, 0), valueTypeFloat64, valuesCodecXOR)
+}
+
+func TestUnmarshalValuesFailure(t *testing.T) {
+	f := func(data []byte, itemsCount uint64, vt valueType, codec valuesCodec) {
+		t.Helper()
+
+		sbu := getStringsBlockUnmarshaler()
+		defer putStringsBlockUnmarshaler(sbu)
+		if _, err := sbu.unmarshalValues(nil, data, itemsCount, vt, codec); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	uint16Values := []string{"\x01\x02", "\x01\x03", "\x01\x04"}
+
+	// the codec cannot be used for the given valueType
+	f(marshalValuesWithCodec(nil, uint16Values, valueTypeUint16, valuesCodecDeltaOfDelta, 0), 3, valueTypeString, valuesCodecDeltaOfDelta)
+	f(marshalValuesWithCodec(nil, uint16Values, valueTypeUint16, valuesCodecDeltaOfDelta, 0), 3, valueTypeUint16, valuesCodecXOR)
+
+	// unexpected number of items
+	f(marshalValuesWithCodec(nil, uint16Values, valueTypeUint16, valuesCodecDeltaOfDelta, 0), 2, valueTypeUint16, valuesCodecDeltaOfDelta)
+	f(marshalValuesWithCodec(nil, uint16Values, valueTypeUint16, valuesCodecDeltaOfDelta, 0), 4, valueTypeUint16, valuesCodecDeltaOfDelta)
+	f(marshalValuesWithCodec(nil, []string{"a", "b"}, valueTypeDict, valuesCodecDictDelta, 0), 3, valueTypeDict, valuesCodecDictDelta)
+
+	// values do not fit the valueType
+	f(marshalValuesWithCodec(nil, uint16Values, valueTypeUint16, valuesCodecDeltaOfDelta, 0), 3, valueTypeUint8, valuesCodecDeltaOfDelta)
+}
+
+func TestStorageAdaptiveCompression(t *testing.T) {
+	t.Parallel()
+
+	path := t.Name()
+
+	cfg := &StorageConfig{
+		Retention:           30 * 24 * time.Hour,
+		AdaptiveCompression: true,
+	}
+	s := MustOpenStorage(path, cfg)
+
+	// Store rows in two parts, so they are merged with adaptive compression into a single part
+	now := time.Now().UnixNano()
+	for i := 0; i < 2; i++ {
+		lr := GetLogRows(nil, nil, nil, nil, "")
+		for j := 0; j < 500; j++ {
+			n := i*500 + j
+			fields := []Field{
+				{
+					Name:  "_msg",
+					Value: fmt.Sprintf("message %d", n),
+				},
+				{
+					Name:  "counter",
+					Value: fmt.Sprintf("%d", 1_000_000+n),
+				},
+				{
+					Name:  "value",
+					Value: fmt.Sprintf("%g", 100+float64(n/50)*0.5),
+				},
+				{
+					Name:  "level",
+					Value: fmt.Sprintf("level_%d", (n/20)%3),
+				},
+			}
+			lr.MustAdd(TenantID{}, now+int64(n), fields, nil)
+		}
+		s.MustAddRows(lr)
+		PutLogRows(lr)
+		s.DebugFlush()
+	}
+	s.MustForceMerge("")
+
+	tenantIDs := []TenantID{{}}
+	checkQueryResults(t, s, tenantIDs, "* | block_stats | filter field:in(counter, level, value) | uniq (field, codec) | sort by (field)", []string{
+		`{"field":"counter","codec":"delta_of_delta"}`,
+		`{"field":"level","codec":"dict_delta"}`,
+		`{"field":"value","codec":"xor"}`,
+	})
+	checkQueryResults(t, s, tenantIDs, "* | stats count() rows, sum(counter) counters, max(value) max_value, count_uniq(level) levels", []string{
+		`{"rows":"1000","counters":"1000499500","max_value":"109.5","levels":"3"}`,
+	})
+	checkQueryResults(t, s, tenantIDs, "counter:=1000777 | fields value, level", []string{
+		`{"value":"107.5","level":"level_2"}`,
+	})
+
+	s.MustClose()
+	fs.MustRemoveDir(path)
+}
@@ -0,0 +1,49 @@
+package logstorage
+
+import (
+	"fmt"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/prefixfilter"
+)
+
+// windowDelta returns the difference between the numeric field value at the current row and at the previous row.
+type windowDelta struct {
+	fieldName string
+}
+
+func (wd *windowDelta) String() string {
+	return "delta(" + quoteTokenIfNeeded(wd.fieldName) + ")"
+}
+
+func (wd *windowDelta) updateNeededFields(pf *prefixfilter.Filter) {
+	pf.AddAllowFilter(wd.fieldName)
+}
+
+func (wd *windowDelta) getResult(rows [][]Field, rowIdx int) string {
+	if rowIdx == 0 {
+		return ""
+	}
+	f, ok := tryParseFloat64(getFieldValueByName(rows[rowIdx], wd.fieldName))
+	if !ok {
+		return ""
+	}
+	fPrev, ok := tryParseFloat64(getFieldValueByName(rows[rowIdx-1], wd.fieldName))
+	if !ok {
+		return ""
+	}
+	return string(marshalFloat64String(nil, f-fPrev))
+}
+
+func parseWindowDelta(lex *lexer) (windowFunc, error) {
+	args, err := parseStatsFuncFields(lex, "delta")
+	if err != nil {
+		return nil, err
+	}
+	if len(args) != 1 {
+		return nil, fmt.Errorf("delta() must contain exactly one field; got %d fields", len(args))
+	}
+	wd := &windowDelta{
+		fieldName: args[0],
+	}
+	return wd, nil
+}
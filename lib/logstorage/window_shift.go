@@ -0,0 +1,75 @@
+package logstorage
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/prefixfilter"
+)
+
+// windowShift returns the field value from the row located the given number of rows before (lag) or after (lead) the current row.
+type windowShift struct {
+	// funcName is either lag or lead
+	funcName string
+
+	fieldName string
+	offset    int
+}
+
+func (ws *windowShift) String() string {
+	s := ws.funcName + "(" + quoteTokenIfNeeded(ws.fieldName)
+	if ws.offset != 1 {
+		s += ", " + strconv.Itoa(ws.offset)
+	}
+	return s + ")"
+}
+
+func (ws *windowShift) updateNeededFields(pf *prefixfilter.Filter) {
+	pf.AddAllowFilter(ws.fieldName)
+}
+
+func (ws *windowShift) getResult(rows [][]Field, rowIdx int) string {
+	if ws.funcName == "lag" {
+		rowIdx -= ws.offset
+	} else {
+		rowIdx += ws.offset
+	}
+	if rowIdx < 0 || rowIdx >= len(rows) {
+		return ""
+	}
+	return getFieldValueByName(rows[rowIdx], ws.fieldName)
+}
+
+func parseWindowLag(lex *lexer) (windowFunc, error) {
+	return parseWindowShift(lex, "lag")
+}
+
+func parseWindowLead(lex *lexer) (windowFunc, error) {
+	return parseWindowShift(lex, "lead")
+}
+
+func parseWindowShift(lex *lexer, funcName string) (windowFunc, error) {
+	args, err := parseStatsFuncFields(lex, funcName)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 || len(args) > 2 {
+		return nil, fmt.Errorf("%s() must contain a field name and an optional offset; got %d args", funcName, len(args))
+	}
+
+	offset := 1
+	if len(args) == 2 {
+		n, ok := tryParseUint64(args[1])
+		if !ok || n == 0 || n > 1<<31 {
+			return nil, fmt.Errorf("offset in %s() must be a positive integer; got %q", funcName, args[1])
+		}
+		offset = int(n)
+	}
+
+	ws := &windowShift{
+		funcName:  funcName,
+		fieldName: args[0],
+		offset:    offset,
+	}
+	return ws, nil
+}
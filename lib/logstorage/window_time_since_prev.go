@@ -0,0 +1,44 @@
+package logstorage
+
+import (
+	"fmt"
+
+	"github.com/VictoriaMetrics/VictoriaLogs/lib/prefixfilter"
+)
+
+// windowTimeSincePrev returns the duration in seconds between the _time of the current row and the _time of the previous row.
+type windowTimeSincePrev struct{}
+
+func (wt *windowTimeSincePrev) String() string {
+	return "time_since_prev()"
+}
+
+func (wt *windowTimeSincePrev) updateNeededFields(pf *prefixfilter.Filter) {
+	pf.AddAllowFilter("_time")
+}
+
+func (wt *windowTimeSincePrev) getResult(rows [][]Field, rowIdx int) string {
+	if rowIdx == 0 {
+		return ""
+	}
+	t, ok := TryParseTimestampRFC3339Nano(getFieldValueByName(rows[rowIdx], "_time"))
+	if !ok {
+		return ""
+	}
+	tPrev, ok := TryParseTimestampRFC3339Nano(getFieldValueByName(rows[rowIdx-1], "_time"))
+	if !ok {
+		return ""
+	}
+	return string(marshalFloat64String(nil, float64(t-tPrev)/1e9))
+}
+
+func parseWindowTimeSincePrev(lex *lexer) (windowFunc, error) {
+	args, err := parseStatsFuncFields(lex, "time_since_prev")
+	if err != nil {
+		return nil, err
+	}
+	if len(args) > 0 {
+		return nil, fmt.Errorf("time_since_prev() mustn't contain args; got %q", args)
+	}
+	return &windowTimeSincePrev{}, nil
+}